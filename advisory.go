@@ -0,0 +1,150 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// Advisory is a known problem, typically a vulnerability, affecting a range of
+// released versions of a project.
+type Advisory struct {
+	// ID identifies the advisory in the database it came from, such as a CVE
+	// or GHSA identifier.
+	ID string
+	// ProjectRoot is the project the advisory is about.
+	ProjectRoot gps.ProjectRoot
+	// Affected is a semver constraint matching the affected versions.
+	Affected gps.Constraint
+	// Summary is a short, human-readable description of the problem.
+	Summary string
+}
+
+// Advisories is a database of advisories.
+type Advisories []Advisory
+
+type rawAdvisories struct {
+	Advisories []rawAdvisory `toml:"advisory"`
+}
+
+type rawAdvisory struct {
+	ID       string `toml:"id"`
+	Name     string `toml:"name"`
+	Affected string `toml:"affected"`
+	Summary  string `toml:"summary"`
+}
+
+// ReadAdvisories returns the advisories read from r, which holds a TOML
+// document with an [[advisory]] table for each of them:
+//
+//	[[advisory]]
+//	  id = "GHSA-xxxx-xxxx-xxxx"
+//	  name = "github.com/foo/bar"
+//	  affected = ">=1.2.0, <1.2.5"
+//	  summary = "Path traversal in archive extraction"
+func ReadAdvisories(r io.Reader) (Advisories, error) {
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, errors.Wrap(err, "unable to read byte stream")
+	}
+
+	var raw rawAdvisories
+	if err := toml.Unmarshal(buf.Bytes(), &raw); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the advisories as TOML")
+	}
+
+	as := make(Advisories, 0, len(raw.Advisories))
+	for _, ra := range raw.Advisories {
+		if ra.ID == "" || ra.Name == "" {
+			return nil, errors.New("every advisory must have an id and a name")
+		}
+		c, err := gps.NewSemverConstraint(ra.Affected)
+		if err != nil || ra.Affected == "" {
+			return nil, errors.Errorf("advisory %s: %q is not a semver range of affected versions", ra.ID, ra.Affected)
+		}
+		as = append(as, Advisory{
+			ID:          ra.ID,
+			ProjectRoot: gps.ProjectRoot(ra.Name),
+			Affected:    c,
+			Summary:     ra.Summary,
+		})
+	}
+	return as, nil
+}
+
+// advisoryClient fetches advisories from http and https URLs.
+var advisoryClient = &http.Client{Timeout: 30 * time.Second}
+
+// LoadAdvisories reads the advisories at location, which is either the path of
+// a file or an http or https URL. A URL is only fetched if policy allows its
+// host to be contacted, and the fetch is abandoned if ctx is done first.
+func LoadAdvisories(ctx context.Context, location string, policy gps.NetworkPolicy) (Advisories, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid advisories URL")
+		}
+		if !policy.Permits(u.Host) {
+			return nil, errors.Errorf("unable to fetch advisories from %s: the network policy does not allow contacting %s", location, u.Host)
+		}
+		req, err := http.NewRequest(http.MethodGet, location, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to fetch advisories")
+		}
+		resp, err := advisoryClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to fetch advisories")
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, errors.Errorf("unable to fetch advisories from %s: %s", location, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open advisories")
+		}
+		r = f
+	}
+	defer r.Close()
+
+	as, err := ReadAdvisories(r)
+	return as, errors.Wrapf(err, "invalid advisories at %s", location)
+}
+
+// Affecting returns the advisories that affect the locked version of lp. The
+// versions of projects locked to a branch or revision are never affected.
+func (as Advisories) Affecting(lp gps.LockedProject) []Advisory {
+	var affecting []Advisory
+	for _, a := range as {
+		if a.ProjectRoot == lp.Ident().ProjectRoot && a.Affected.Matches(lp.Version()) {
+			affecting = append(affecting, a)
+		}
+	}
+	return affecting
+}
+
+// Exclusions returns the affected versions of each project, in the form taken
+// by gps.SolveParameters.Exclude.
+func (as Advisories) Exclusions() map[gps.ProjectRoot][]gps.Constraint {
+	excl := make(map[gps.ProjectRoot][]gps.Constraint)
+	for _, a := range as {
+		excl[a.ProjectRoot] = append(excl[a.ProjectRoot], a.Affected)
+	}
+	return excl
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+const testAdvisories = `
+[[advisory]]
+  id = "GHSA-0001"
+  name = "github.com/foo/bar"
+  affected = ">=1.2.0, <1.2.5"
+  summary = "Path traversal in archive extraction"
+
+[[advisory]]
+  id = "GHSA-0002"
+  name = "github.com/foo/bar"
+  affected = "<1.0.0"
+  summary = "Panic on empty input"
+`
+
+func TestReadAdvisories(t *testing.T) {
+	as, err := ReadAdvisories(strings.NewReader(testAdvisories))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 2 {
+		t.Fatalf("expected 2 advisories, got %d", len(as))
+	}
+
+	pi := gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}
+	for v, want := range map[string]string{
+		"1.2.3": "GHSA-0001",
+		"0.9.0": "GHSA-0002",
+		"1.2.5": "",
+	} {
+		lp := gps.NewLockedProject(pi, gps.NewVersion(v).Pair("abc123"), nil)
+		var got string
+		for _, a := range as.Affecting(lp) {
+			got = a.ID
+		}
+		if got != want {
+			t.Errorf("expected %s to be affected by %q, got %q", v, want, got)
+		}
+	}
+
+	lp := gps.NewLockedProject(pi, gps.NewBranch("master").Pair("abc123"), nil)
+	if affecting := as.Affecting(lp); len(affecting) != 0 {
+		t.Errorf("expected a branch never to be affected, got %v", affecting)
+	}
+
+	excl := as.Exclusions()
+	if len(excl) != 1 || len(excl["github.com/foo/bar"]) != 2 {
+		t.Errorf("expected both ranges to be excluded for github.com/foo/bar, got %v", excl)
+	}
+}
+
+func TestReadAdvisoriesInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"no id":          "[[advisory]]\nname = \"github.com/foo/bar\"\naffected = \"<1.0.0\"\n",
+		"no name":        "[[advisory]]\nid = \"GHSA-0001\"\naffected = \"<1.0.0\"\n",
+		"no range":       "[[advisory]]\nid = \"GHSA-0001\"\nname = \"github.com/foo/bar\"\n",
+		"invalid range":  "[[advisory]]\nid = \"GHSA-0001\"\nname = \"github.com/foo/bar\"\naffected = \"master\"\n",
+		"not TOML":       "[[advisory",
+		"wrong key type": "advisory = 1\n",
+	} {
+		if _, err := ReadAdvisories(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadAdvisoriesURL(t *testing.T) {
+	fetched := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		w.Write([]byte(testAdvisories))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	as, err := LoadAdvisories(context.Background(), srv.URL, gps.NetworkPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 2 {
+		t.Errorf("expected 2 advisories, got %d", len(as))
+	}
+
+	if _, err := LoadAdvisories(context.Background(), srv.URL, gps.NetworkPolicy{Deny: []string{u.Hostname()}}); err == nil {
+		t.Error("expected the network policy to forbid fetching the advisories")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LoadAdvisories(ctx, srv.URL, gps.NetworkPolicy{}); err == nil {
+		t.Error("expected fetching to be abandoned once the context is done")
+	}
+	if fetched != 1 {
+		t.Errorf("expected the advisories to be fetched once, got %d", fetched)
+	}
+}
@@ -23,27 +23,50 @@ func (a Analyzer) HasDepMetadata(path string) bool {
 }
 
 // DeriveManifestAndLock reads and returns the manifest at path/ManifestName or nil if one is not found.
+// If the manifest does not declare a minimum Go version, it is taken from the go
+// directive of a go.mod file, if there is one.
 // The Lock is always nil for now.
 func (a Analyzer) DeriveManifestAndLock(path string, n gps.ProjectRoot) (gps.Manifest, gps.Lock, error) {
-	if !a.HasDepMetadata(path) {
-		return nil, nil, nil
-	}
+	var m *Manifest
+	if a.HasDepMetadata(path) {
+		f, err := os.Open(filepath.Join(path, ManifestName))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
 
-	f, err := os.Open(filepath.Join(path, ManifestName))
-	if err != nil {
-		return nil, nil, err
+		// Ignore warnings irrelevant to user.
+		m, _, err = readManifest(f)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
-	defer f.Close()
 
-	// Ignore warnings irrelevant to user.
-	m, _, err := readManifest(f)
-	if err != nil {
-		return nil, nil, err
+	if m == nil || m.GoVersion == "" {
+		gv, err := readGoDirective(filepath.Join(path, modFileName))
+		if err != nil {
+			return nil, nil, err
+		}
+		if gv != "" {
+			if m == nil {
+				m = NewManifest()
+			}
+			m.GoVersion = gv
+		}
 	}
 
+	if m == nil {
+		return nil, nil, nil
+	}
 	return m, nil, nil
 }
 
+// MetadataFiles implements gps.MetadataFilesAnalyzer: DeriveManifestAndLock
+// reads only the manifest and go.mod.
+func (a Analyzer) MetadataFiles() []string {
+	return []string{ManifestName, modFileName}
+}
+
 // Info returns Analyzer's name and version info.
 func (a Analyzer) Info() gps.ProjectAnalyzerInfo {
 	return gps.ProjectAnalyzerInfo{
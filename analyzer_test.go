@@ -102,6 +102,34 @@ func TestAnalyzerDeriveManifestAndLockInvalidManifest(t *testing.T) {
 	}
 }
 
+func TestAnalyzerDeriveManifestAndLockGoDirective(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("dep")
+	h.TempFile(filepath.Join("dep", modFileName), "module github.com/my/project\n\ngo 1.12\n")
+
+	a := Analyzer{}
+
+	m, _, err := a.DeriveManifestAndLock(h.Path("dep"), "my/fake/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.(*Manifest).GoVersion != "1.12" {
+		t.Fatalf("expected a manifest with the Go version from go.mod, got %#v", m)
+	}
+
+	// A Go version in the manifest takes precedence.
+	h.TempFile(filepath.Join("dep", ManifestName), "go = \">=1.10\"\n")
+	m, _, err = a.DeriveManifestAndLock(h.Path("dep"), "my/fake/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.(*Manifest).GoVersion; got != "1.10" {
+		t.Fatalf("expected the Go version from the manifest, got %q", got)
+	}
+}
+
 func TestAnalyzerInfo(t *testing.T) {
 	a := Analyzer{}
 
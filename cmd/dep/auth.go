@@ -0,0 +1,109 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// authFromEnv builds the providers of the credentials for sources from
+// $DEPAUTH, a comma-separated list of host=provider pairs, where host is a host
+// pattern and provider one of github-app, gitlab-ci or token-exchange, each of
+// which is configured by further variables.
+func authFromEnv(env []string) (gps.AuthProviders, error) {
+	v := getEnv(env, "DEPAUTH")
+	if v == "" {
+		return nil, nil
+	}
+
+	a := make(gps.AuthProviders)
+	for _, pair := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("failed to parse $DEPAUTH: %q is not of the form host=provider", pair)
+		}
+		host, provider := kv[0], kv[1]
+		var err error
+		switch provider {
+		case "github-app":
+			a[host], err = githubAppAuthFromEnv(env, host)
+		case "gitlab-ci":
+			token := getEnv(env, "CI_JOB_TOKEN")
+			if token == "" {
+				err = errors.New("$CI_JOB_TOKEN is not set")
+			}
+			a[host] = gps.GitLabJobTokenAuth{Token: token}
+		case "token-exchange":
+			te := gps.TokenExchangeAuth{
+				URL:      getEnv(env, "DEPAUTHEXCHANGEURL"),
+				IDToken:  getEnv(env, "DEPAUTHIDTOKEN"),
+				Username: getEnv(env, "DEPAUTHUSER"),
+			}
+			if te.URL == "" || te.IDToken == "" {
+				err = errors.New("$DEPAUTHEXCHANGEURL and $DEPAUTHIDTOKEN must both be set")
+			}
+			a[host] = te
+		default:
+			err = errors.Errorf("unknown provider %q, expected github-app, gitlab-ci or token-exchange", provider)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse $DEPAUTH for %s", host)
+		}
+	}
+	return a, nil
+}
+
+// githubAppAuthFromEnv configures a GitHub App provider for the hosts matching
+// host from $DEPGITHUBAPPID, $DEPGITHUBAPPINSTALLATION, $DEPGITHUBAPPKEY, the
+// path to the app's private key, and $DEPGITHUBAPIURL. If the last is not
+// set, the API is that of github.com, or of GitHub Enterprise on host.
+func githubAppAuthFromEnv(env []string, host string) (gps.GitHubAppAuth, error) {
+	g := gps.GitHubAppAuth{
+		AppID:          getEnv(env, "DEPGITHUBAPPID"),
+		InstallationID: getEnv(env, "DEPGITHUBAPPINSTALLATION"),
+		APIURL:         getEnv(env, "DEPGITHUBAPIURL"),
+	}
+	if g.AppID == "" || g.InstallationID == "" {
+		return g, errors.New("$DEPGITHUBAPPID and $DEPGITHUBAPPINSTALLATION must both be set")
+	}
+	if g.APIURL == "" && host != "github.com" {
+		if strings.HasPrefix(host, "*.") {
+			return g, errors.New("$DEPGITHUBAPIURL must be set for a wildcard host")
+		}
+		g.APIURL = "https://" + host + "/api/v3"
+	}
+
+	path := getEnv(env, "DEPGITHUBAPPKEY")
+	if path == "" {
+		return g, errors.New("$DEPGITHUBAPPKEY is not set")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return g, errors.Wrap(err, "failed to read $DEPGITHUBAPPKEY")
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return g, errors.Errorf("no PEM-encoded key in %s", path)
+	}
+	if g.Key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return g, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return g, errors.Wrapf(err, "failed to parse the private key in %s", path)
+	}
+	var ok bool
+	if g.Key, ok = k.(*rsa.PrivateKey); !ok {
+		return g, errors.Errorf("the private key in %s is not an RSA key", path)
+	}
+	return g, nil
+}
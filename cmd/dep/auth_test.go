@@ -0,0 +1,58 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestAuthFromEnv(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.TempFile("app.pem", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+
+	env := []string{
+		"DEPAUTH=github.com=github-app, github.example.com=github-app, gitlab.com=gitlab-ci, *.corp.example.com=token-exchange",
+		"DEPGITHUBAPPID=7", "DEPGITHUBAPPINSTALLATION=42", "DEPGITHUBAPPKEY=" + h.Path("app.pem"),
+		"CI_JOB_TOKEN=job",
+		"DEPAUTHEXCHANGEURL=https://sts.corp.example.com/token", "DEPAUTHIDTOKEN=id-token",
+	}
+	a, err := authFromEnv(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := a["github.com"].(gps.GitHubAppAuth); !ok || g.AppID != "7" || g.InstallationID != "42" || g.Key == nil || g.APIURL != "" {
+		t.Errorf("unexpected provider for github.com: %#v", a["github.com"])
+	}
+	if g, ok := a["github.example.com"].(gps.GitHubAppAuth); !ok || g.APIURL != "https://github.example.com/api/v3" {
+		t.Errorf("expected the GitHub Enterprise API of github.example.com, got %#v", a["github.example.com"])
+	}
+	if want := (gps.GitLabJobTokenAuth{Token: "job"}); a["gitlab.com"] != want {
+		t.Errorf("expected %v for gitlab.com, got %v", want, a["gitlab.com"])
+	}
+	if want := (gps.TokenExchangeAuth{URL: "https://sts.corp.example.com/token", IDToken: "id-token"}); a["*.corp.example.com"] != want {
+		t.Errorf("expected %v for *.corp.example.com, got %v", want, a["*.corp.example.com"])
+	}
+
+	if a, err := authFromEnv(nil); err != nil || a != nil {
+		t.Errorf("expected no providers without the variable, got %v, %v", a, err)
+	}
+	for _, v := range []string{"github.com", "github.com=pat", "gitlab.com=gitlab-ci", "*.example.com=github-app"} {
+		if _, err := authFromEnv(append(env[1:4:4], "DEPAUTH="+v)); err == nil {
+			t.Errorf("expected %q to fail to parse", v)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+const bundleSourcesShortHelp = `Bundle the locked sources of dependencies for offline use`
+const bundleSourcesLongHelp = `
+Write an archive of the source of each project in Gopkg.lock, at its locked
+version, into the given directory.
+
+The directory can then be carried to a machine without network access, where
+setting $DEPBUNDLEDIR to it makes dep serve every project from the archives
+instead of from upstream sources. There, commands such as dep ensure and
+dep status work as long as the lock does not need to change; projects or
+versions missing from the bundle cannot be found.
+
+Existing archives in the directory are replaced, and archives for projects no
+longer in the lock are left in place.
+`
+
+func (cmd *bundleSourcesCommand) Name() string      { return "bundle-sources" }
+func (cmd *bundleSourcesCommand) Args() string      { return "<dir>" }
+func (cmd *bundleSourcesCommand) ShortHelp() string { return bundleSourcesShortHelp }
+func (cmd *bundleSourcesCommand) LongHelp() string  { return bundleSourcesLongHelp }
+func (cmd *bundleSourcesCommand) Hidden() bool      { return false }
+
+func (cmd *bundleSourcesCommand) Register(fs *flag.FlagSet) {}
+
+type bundleSourcesCommand struct{}
+
+func (cmd *bundleSourcesCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.New("a single bundle directory must be specified")
+	}
+	dir := args[0]
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found. Run `dep ensure` to generate lock file", dep.LockName)
+	}
+
+	if err = fs.EnsureDir(dir, 0777); err != nil {
+		return errors.Wrapf(err, "failed to create bundle directory %s", dir)
+	}
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	for _, lp := range p.Lock.Projects() {
+		if ctx.Verbose {
+			ctx.Err.Printf("Bundling %s at %s\n", lp.Ident(), lp.Version())
+		}
+		if err := gps.WriteSourceBundle(context.TODO(), sm, dir, lp.Ident(), []gps.Version{lp.Version()}); err != nil {
+			return err
+		}
+	}
+
+	ctx.Out.Printf("Bundled %d projects into %s\n", len(p.Lock.Projects()), dir)
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const cacheShortHelp = `Report on, or check, the local cache`
+const cacheLongHelp = `
+Report on the state of the local cache of sources, or check its integrity:
+
+  sources    the health of the upstream sources dep has tried to reach
+  verify     check the cache for corruption, quarantining what is corrupted
+
+For each source URL, dep records whether the last attempt to reach it
+succeeded, the number of attempts that have failed since the last successful
+one, how long that successful one took, and when it was made. When a project
+can be fetched from several URLs, such as over https and over ssh, those that
+failed on earlier runs are tried after the others.
+
+Verify checks the structure of the cached metadata database, and each cached
+repository with its version control system, as with git fsck. Corrupted
+entries are moved into the quarantine directory of the cache, for
+inspection; the database is replaced by an empty one, and sources are
+retrieved afresh when next needed. Run within a project, verify also
+compares the tree of each project in Gopkg.lock, as exported from the cache,
+with the digest recorded for it, quarantining and retrieving afresh the
+sources of those that differ. It fails if any still differ.
+
+With -json, the same information is written as JSON.
+`
+
+func (cmd *cacheCommand) Name() string      { return "cache" }
+func (cmd *cacheCommand) Args() string      { return "[-json] sources | verify" }
+func (cmd *cacheCommand) ShortHelp() string { return cacheShortHelp }
+func (cmd *cacheCommand) LongHelp() string  { return cacheLongHelp }
+func (cmd *cacheCommand) Hidden() bool      { return false }
+
+func (cmd *cacheCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+type cacheCommand struct {
+	json bool
+}
+
+func (cmd *cacheCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 || args[0] != "sources" && args[0] != "verify" {
+		return errors.New("expected sources, for a report on them, or verify")
+	}
+	if args[0] == "verify" {
+		return cmd.runVerify(ctx)
+	}
+
+	hs, err := gps.ReadSourceHealth(ctx.CacheDir())
+	if err != nil {
+		return errors.Wrap(err, "failed to read the health of sources")
+	}
+
+	if cmd.json {
+		if hs == nil {
+			hs = []gps.SourceHealth{}
+		}
+		out, err := json.Marshal(hs)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the health of sources")
+		}
+		ctx.Out.Println(string(out))
+		return nil
+	}
+	ctx.Out.Print(formatSourceHealth(hs))
+	return nil
+}
+
+// formatSourceHealth renders hs as a table.
+func formatSourceHealth(hs []gps.SourceHealth) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tHEALTH\tLATENCY\tLAST SUCCESS\tLAST ERROR")
+	for _, h := range hs {
+		health := "ok"
+		if !h.Healthy() {
+			health = fmt.Sprintf("failing (%d)", h.Failures)
+		}
+		latency, success := "-", "never"
+		if !h.LastSuccess.IsZero() {
+			latency = h.Latency.Round(time.Millisecond).String()
+			success = h.LastSuccess.Format(time.RFC3339)
+		}
+		lastErr := "-"
+		if !h.Healthy() {
+			lastErr = h.LastError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", h.URL, health, latency, success, lastErr)
+	}
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestFormatSourceHealth(t *testing.T) {
+	at := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	got := formatSourceHealth([]gps.SourceHealth{
+		{URL: "https://github.com/foo/bar", LastSuccess: at, Latency: 1234567 * time.Microsecond},
+		{URL: "ssh://git@github.com/foo/bar", Failures: 2, LastFailure: at, LastError: "permission denied"},
+	})
+	want := strings.Join([]string{
+		"SOURCE                        HEALTH       LATENCY  LAST SUCCESS          LAST ERROR",
+		"https://github.com/foo/bar    ok           1.235s   2018-06-01T12:00:00Z  -",
+		"ssh://git@github.com/foo/bar  failing (2)  -        never                 permission denied",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("unexpected table:\n%s\nwanted:\n%s", got, want)
+	}
+}
+
+func TestCacheRequiresReport(t *testing.T) {
+	cmd := &cacheCommand{}
+	for _, args := range [][]string{nil, {"other"}, {"sources", "extra"}} {
+		if err := cmd.Run(&dep.Ctx{}, args); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// cacheVerification is the result of dep cache verify.
+type cacheVerification struct {
+	Sources  int                  `json:"sources"`
+	Problems []cacheProblem       `json:"problems"`
+	Trees    []lockedTreeMismatch `json:"trees,omitempty"`
+}
+
+// cacheProblem is a corrupted entry of the cache, as reported by dep cache
+// verify.
+type cacheProblem struct {
+	Entry      string `json:"entry"`
+	Error      string `json:"error"`
+	Quarantine string `json:"quarantine"`
+}
+
+// lockedTreeMismatch is a locked project whose tree, as exported from the
+// cache, did not match the digest recorded in the lock.
+type lockedTreeMismatch struct {
+	ProjectRoot string `json:"projectRoot"`
+	Version     string `json:"version"`
+	Quarantine  string `json:"quarantine"`
+	// Fixed is set if the tree matched once the source was retrieved afresh.
+	Fixed bool `json:"fixed"`
+}
+
+func (cmd *cacheCommand) runVerify(ctx *dep.Ctx) error {
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	report, err := sm.VerifyCache(context.TODO())
+	if err != nil {
+		return errors.Wrap(err, "failed to verify the cache")
+	}
+	res := cacheVerification{Sources: report.Sources, Problems: []cacheProblem{}}
+	for _, p := range report.Problems {
+		res.Problems = append(res.Problems, cacheProblem{Entry: p.Entry, Error: p.Err.Error(), Quarantine: p.Quarantine})
+	}
+
+	// The trees of the locked projects can only be checked within a project.
+	if p, err := ctx.LoadProject(); err == nil && p.Lock != nil {
+		if res.Trees, err = verifyLockedTrees(sm, p.Lock); err != nil {
+			return err
+		}
+	}
+
+	if cmd.json {
+		out, err := json.Marshal(res)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the verification of the cache")
+		}
+		ctx.Out.Println(string(out))
+	} else {
+		ctx.Out.Printf("Checked %d cached source(s)\n", res.Sources)
+		for _, p := range res.Problems {
+			ctx.Out.Printf("Quarantined %s to %s: %s\n", p.Entry, p.Quarantine, p.Error)
+		}
+		for _, t := range res.Trees {
+			ctx.Out.Printf("The cached tree of %s@%s did not match its digest in %s; quarantined its source to %s\n", t.ProjectRoot, t.Version, dep.LockName, t.Quarantine)
+			if !t.Fixed {
+				ctx.Out.Printf("  ✗ the tree still does not match, having retrieved the source afresh\n")
+			}
+		}
+	}
+
+	var unfixed int
+	for _, t := range res.Trees {
+		if !t.Fixed {
+			unfixed++
+		}
+	}
+	if unfixed > 0 {
+		return errors.Errorf("%d project(s) do not match their digests in %s, even as retrieved afresh from their sources", unfixed, dep.LockName)
+	}
+	return nil
+}
+
+// verifyLockedTrees compares the tree of each project in l that has a digest,
+// as exported from the cache with its recorded prune options, with that
+// digest. The sources of those that differ are quarantined and retrieved
+// afresh, then compared again.
+func verifyLockedTrees(sm *gps.SourceMgr, l *dep.Lock) ([]lockedTreeMismatch, error) {
+	td, err := ioutil.TempDir("", "dep-cache-verify")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	var mismatches []lockedTreeMismatch
+	for _, lp := range l.P {
+		vp, ok := lp.(verify.VerifiableProject)
+		if !ok || vp.Digest.IsEmpty() || vp.Digest.HashVersion != verify.HashVersion {
+			continue
+		}
+		pr := lp.Ident().ProjectRoot
+		match, err := cachedTreeMatches(sm, l, vp, filepath.Join(td, string(pr)))
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			continue
+		}
+
+		m := lockedTreeMismatch{ProjectRoot: string(pr), Version: lp.Version().String()}
+		if m.Quarantine, err = sm.QuarantineSource(context.TODO(), lp.Ident()); err != nil {
+			return nil, errors.Wrapf(err, "failed to quarantine the source of %s", pr)
+		}
+		if m.Fixed, err = cachedTreeMatches(sm, l, vp, filepath.Join(td, string(pr))); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, m)
+	}
+	return mismatches, nil
+}
+
+// cachedTreeMatches reports whether the tree of vp, exported to scratch, has
+// the digest recorded for it in l.
+func cachedTreeMatches(sm *gps.SourceMgr, l *dep.Lock, vp verify.VerifiableProject, scratch string) (bool, error) {
+	pr := vp.Ident().ProjectRoot
+	defer os.RemoveAll(scratch)
+	if err := sm.ExportPrunedProject(context.TODO(), vp, vp.PruneOpts, scratch); err != nil {
+		return false, errors.Wrapf(err, "failed to export %s", pr)
+	}
+	got, err := verify.DigestFromDirectoryExcluding(scratch, verify.DigestExclusions{
+		Nested:    verify.NestedProjects(pr, l),
+		Generated: vp.Generated,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "error while hashing tree of %s", pr)
+	}
+	return bytes.Equal(got.Digest, vp.Digest.Digest), nil
+}
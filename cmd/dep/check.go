@@ -0,0 +1,664 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/depcheck"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+const checkShortHelp = `Check if imports, Gopkg.toml, Gopkg.lock, and vendor/ are in sync`
+const checkLongHelp = `
+Check determines if the project is in a good state: that Gopkg.lock satisfies
+the imports and rules in Gopkg.toml, and that the contents of vendor/ match the
+hash digests recorded in Gopkg.lock. If any of these are out of sync, check
+lists the problems and exits non-zero. Nothing is solved or written.
+
+Each vendored project also records its source, revision and digest in a
+.dep-provenance file, written along with it. Check reports a project whose
+record disagrees with Gopkg.lock as altered, so that a project copied into
+vendor/ from elsewhere, with a lock edited to match its digest, is caught.
+
+Check also fails while any [[override]] in Gopkg.toml has expired: one
+recorded by 'dep ensure -override -until=<date>', or given an expires date by
+hand, as a temporary workaround. This blocks -plan and -fix, and is not
+affected by -fail-on; renew the override with a later date, or remove it.
+
+With -plan, check instead prints the ordered list of actions needed to bring
+the project back in sync: re-solving Gopkg.lock, re-vendoring individual
+projects, and removing orphaned directories from vendor/.
+
+With -fix, check carries out that plan.
+
+With -sources, check also deduces the source of each project in Gopkg.lock
+afresh, and reports any project whose source now resolves to a different URL
+than the one recorded when it was vendored, such as after a change to a vanity
+import path's meta tags or a redirect. This requires network access. Neither
+-plan nor -fix will proceed while such a project is reported; once the new
+source has been confirmed to be trustworthy, run 'dep ensure' to record it.
+
+With -reachable, check also asks upstream whether the revision of each project
+locked to a branch or tag can still be reached from it: it must be an ancestor
+of the branch's current revision, or the revision the tag still points to.
+This finds branches that were force-pushed, and tags that were moved or
+deleted, which would otherwise only break a build on a machine without the
+revision in its cache. This requires network access, and blocks -plan and
+-fix in the same way as -sources; run 'dep ensure -update' on the projects
+reported to move them to revisions that are still published.
+
+With -internal, check also lists the packages in vendor/ that the root
+project's imports reach, and reports each import of a package inside an
+internal directory of another project, with the chain of imports leading to
+it. The go tool allows such an import wherever the importing package's path
+is within the directory holding the internal directory, as for
+github.com/foo/bar/v2 importing github.com/foo/bar/internal/x, but the two are
+separate projects, and the import breaks once internal packages are enforced
+relative to project roots. Like -sources, it blocks -plan and -fix.
+
+With -require-signed, check also requires the revision of each project in
+Gopkg.lock to have a valid signature in Gopkg.sigs, the file of detached
+signatures next to it, by one of the keys in the PEM file at $DEPKEYRING, and
+reports each project without one. A revision is signed by signing, with
+openssl dgst -sha256 -sign, the lines "dep-signature-v1", the project root and
+the revision. Like -sources, it blocks -plan and -fix.
+
+The exit code tells the classes of problem found apart, so that CI can treat
+them differently without parsing the output. Each class sets a bit of it:
+
+  2   lock        Gopkg.lock is stale: the imports or Go versions changed
+  4   vendor      vendor/ was altered: code differs from its digest, a
+                  directory is not in Gopkg.lock, or vendor/ was written
+                  from a different Gopkg.lock
+  8   missing     a project in Gopkg.lock is missing from vendor/
+  16  constraint  a locked version does not satisfy Gopkg.toml's rules
+
+so that, for example, 6 means the lock is stale and vendor/ was altered. An
+exit code of 1 means check itself failed, or a moved source or expired override
+was found.
+
+With -fail-on, check only fails for the given comma-separated classes. Problems
+of other classes are still reported, but do not make check, or -plan, fail.
+`
+
+func (cmd *checkCommand) Name() string { return "check" }
+func (cmd *checkCommand) Args() string {
+	return "[-plan | -fix] [-sources] [-reachable] [-internal] [-require-signed] [-fail-on <classes>]"
+}
+func (cmd *checkCommand) ShortHelp() string { return checkShortHelp }
+func (cmd *checkCommand) LongHelp() string  { return checkLongHelp }
+func (cmd *checkCommand) Hidden() bool      { return false }
+
+func (cmd *checkCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.plan, "plan", false, "print the actions needed to bring the project back in sync")
+	fs.BoolVar(&cmd.fix, "fix", false, "carry out the actions needed to bring the project back in sync")
+	fs.BoolVar(&cmd.sources, "sources", false, "report projects whose source now resolves to a different URL than the one recorded in Gopkg.lock")
+	fs.BoolVar(&cmd.reachable, "reachable", false, "report projects whose locked revision is no longer reachable from its branch or tag upstream")
+	fs.BoolVar(&cmd.internal, "internal", false, "report imports of internal packages of other projects, with the chains of imports leading to them")
+	fs.BoolVar(&cmd.requireSigned, "require-signed", false, "report projects whose locked revision has no valid signature by a key in $DEPKEYRING")
+	fs.StringVar(&cmd.failOn, "fail-on", "", "only fail for the given comma-separated classes of problem: lock, vendor, missing, constraint")
+	cmd.perf.register(fs)
+}
+
+type checkCommand struct {
+	plan          bool
+	fix           bool
+	sources       bool
+	reachable     bool
+	internal      bool
+	requireSigned bool
+	failOn        string
+	perf          perfFlags
+}
+
+// checkFailure is a set of the classes of problem reported by check. Each
+// class is a bit of the exit code.
+type checkFailure int
+
+const (
+	checkLockStale checkFailure = 1 << (iota + 1)
+	checkVendorAltered
+	checkMissingProject
+	checkConstraintMismatch
+
+	checkAllFailures = checkLockStale | checkVendorAltered | checkMissingProject | checkConstraintMismatch
+)
+
+var checkFailureNames = []struct {
+	name string
+	f    checkFailure
+}{
+	{"lock", checkLockStale},
+	{"vendor", checkVendorAltered},
+	{"missing", checkMissingProject},
+	{"constraint", checkConstraintMismatch},
+}
+
+// parseCheckFailures parses the value of -fail-on, a comma-separated list of
+// class names. The empty string selects all of the classes.
+func parseCheckFailures(s string) (checkFailure, error) {
+	if s == "" {
+		return checkAllFailures, nil
+	}
+
+	var fs checkFailure
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, cf := range checkFailureNames {
+			if cf.name == name {
+				fs |= cf.f
+				found = true
+			}
+		}
+		if !found {
+			return 0, errors.Errorf("unknown class %q for -fail-on; must be one of lock, vendor, missing or constraint", name)
+		}
+	}
+	return fs, nil
+}
+
+// checkFailures returns the classes of the problems found in lsat and status.
+func checkFailures(lsat verify.LockSatisfaction, status map[string]verify.VendorStatus) checkFailure {
+	var fs checkFailure
+	if !lsat.LockExisted || len(lsat.MissingImports) > 0 || len(lsat.ExcessImports) > 0 || lsat.ChangedGoVersions != nil || lsat.ChangedBuildContext != nil {
+		fs |= checkLockStale
+	}
+	if len(lsat.UnmetOverrides) > 0 || len(lsat.UnmetConstraints) > 0 {
+		fs |= checkConstraintMismatch
+	}
+	for _, stat := range status {
+		switch stat {
+		case verify.NotInTree:
+			fs |= checkMissingProject
+		case verify.NotInLock, verify.DigestMismatchInLock, verify.HashVersionMismatch, verify.EmptyDigestInLock:
+			fs |= checkVendorAltered
+		}
+	}
+	return fs
+}
+
+// checkError is the error returned by check for problems of the classes in
+// failures, which it exits with.
+type checkError struct {
+	error
+	failures checkFailure
+}
+
+func (e *checkError) ExitCode() int {
+	return int(e.failures)
+}
+
+func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	if cmd.plan && cmd.fix {
+		return errors.New("cannot pass both -plan and -fix")
+	}
+	if cmd.fix && cmd.failOn != "" {
+		return errors.New("-fail-on cannot be passed with -fix, which fixes problems of every class")
+	}
+	failOn, err := parseCheckFailures(cmd.failOn)
+	if err != nil {
+		return err
+	}
+
+	stopPerf, err := cmd.perf.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer stopPerf()
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no %s found. Run `dep ensure` to generate lock file", dep.LockName)
+	}
+
+	params := p.MakeParams()
+	if ctx.Verbose {
+		params.TraceLogger = ctx.Err
+	}
+
+	lsat := verify.LockSatisfiesInputs(p.Lock, p.Manifest, params.RootPackageTree)
+	printLockSatisfactionWarnings(ctx.Err, lsat)
+
+	vc, err := checkVendor(ctx.Err, p)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.CheckRevisionLedger(p.Lock); err != nil {
+		ctx.Err.Printf("Warning: failed to check the revision ledger: %v\n", err)
+	}
+
+	if expired := expiredOverrides(p.Manifest, time.Now()); len(expired) > 0 {
+		ctx.Err.Printf("# Some overrides in %s have expired:\n", dep.ManifestName)
+		for _, e := range expired {
+			ctx.Err.Println(e)
+		}
+		ctx.Err.Println()
+		return errors.Errorf("found %d expired override(s); renew them with `dep ensure -override -until=<date>`, or remove them from %s", len(expired), dep.ManifestName)
+	}
+
+	if cmd.sources {
+		moved, err := checkSourceURLs(ctx, p.Lock)
+		if err != nil {
+			return err
+		}
+		if len(moved) > 0 {
+			ctx.Err.Printf("# The sources of some projects in %s have moved:\n", dep.LockName)
+			for _, div := range moved {
+				ctx.Err.Println(div)
+			}
+			ctx.Err.Println()
+			return errors.Errorf("found %d moved source(s); run `dep ensure` to record them, once they are confirmed to be trustworthy", len(moved))
+		}
+	}
+
+	if cmd.reachable {
+		sm, err := ctx.ProjectSourceManager(p)
+		if err != nil {
+			return err
+		}
+		sm.UseDefaultSignalHandling()
+		unreachable, err := checkReachability(p.Lock, sm)
+		sm.Release()
+		if err != nil {
+			return err
+		}
+		if len(unreachable) > 0 {
+			ctx.Err.Printf("# Some revisions in %s can no longer be reached upstream:\n", dep.LockName)
+			for _, div := range unreachable {
+				ctx.Err.Println(div)
+			}
+			ctx.Err.Println()
+			return errors.Errorf("found %d unreachable revision(s); run `dep ensure -update` on those projects to lock revisions that are still published", len(unreachable))
+		}
+	}
+
+	if cmd.internal {
+		imports, err := checkInternalImports(p, params.RootPackageTree)
+		if err != nil {
+			return err
+		}
+		if len(imports) > 0 {
+			ctx.Err.Println("# Some imports of internal packages cross project boundaries:")
+			for _, ii := range imports {
+				ctx.Err.Println(ii)
+			}
+			ctx.Err.Println()
+			return errors.Errorf("found %d import(s) of internal packages of other projects", len(imports))
+		}
+	}
+
+	if cmd.requireSigned {
+		unsigned, err := checkSignatures(ctx, p)
+		if err != nil {
+			return err
+		}
+		if len(unsigned) > 0 {
+			ctx.Err.Printf("# Some revisions in %s have no valid signature:\n", dep.LockName)
+			for _, u := range unsigned {
+				ctx.Err.Println(u)
+			}
+			ctx.Err.Println()
+			return errors.Errorf("found %d project(s) without a valid signature in %s", len(unsigned), dep.SignaturesName)
+		}
+	}
+
+	plan := verify.MakeRemediationPlan(lsat, vc.status)
+	if !plan.NeedsResolve() {
+		plan = append(plan, provenancePlan(vc.provenance)...)
+	}
+	if vc.stale && len(plan) == 0 {
+		// Writing vendor records the snapshot of the current lock.
+		plan = append(plan, verify.Action{Kind: verify.ActionRevendor, Reason: staleVendorReason})
+	}
+	if len(plan) == 0 {
+		if ctx.Verbose {
+			ctx.Out.Printf("%s and vendor/ are in sync with %s and project code\n", dep.LockName, dep.ManifestName)
+		}
+		return nil
+	}
+
+	failures := checkFailures(lsat, vc.status)
+	if vc.stale || len(vc.provenance) > 0 {
+		failures |= checkVendorAltered
+	}
+	failures &= failOn
+	switch {
+	case cmd.fix:
+		// Projects with a mismatched provenance are re-vendored as if their
+		// code did not match the lock.
+		for pr := range vc.provenance {
+			vc.status[string(pr)] = verify.DigestMismatchInLock
+		}
+		return cmd.runFix(ctx, p, params, plan, vc.status)
+	case cmd.plan:
+		printPlan(ctx.Out, plan)
+		if failures == 0 {
+			return nil
+		}
+		return &checkError{errors.Errorf("%d action(s) needed to bring the project back in sync", len(plan)), failures}
+	}
+
+	divs := append(appendVerifyErrors(nil, lsat.Err()), vc.divergences()...)
+	ctx.Err.Printf("# %s or vendor/ is out of sync with %s and project code:\n", dep.LockName, dep.ManifestName)
+	for _, div := range divs {
+		ctx.Err.Println(div)
+	}
+	ctx.Err.Println()
+	if failures == 0 {
+		ctx.Err.Printf("None of these are of the classes passed to -fail-on (%s), so check does not fail.\n", cmd.failOn)
+		return nil
+	}
+	return &checkError{errors.Errorf("found %d problem(s); run `dep check -plan` to see how to fix them", len(divs)), failures}
+}
+
+// runFix carries out plan, which must have been computed for p's lock and the
+// given vendor status.
+func (cmd *checkCommand) runFix(ctx *dep.Ctx, p *dep.Project, params gps.SolveParameters, plan verify.Plan, status map[string]verify.VendorStatus) error {
+	if ctx.Verbose {
+		printPlan(ctx.Err, plan)
+	}
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	// Re-vendoring and orphan removal fall out of writing the (possibly new)
+	// lock against the current vendor status, so only a re-solve needs
+	// handling of its own.
+	lock := p.Lock
+	if plan.NeedsResolve() {
+		if err := ctx.ValidateParams(sm, params); err != nil {
+			return err
+		}
+
+		solver, err := gps.Prepare(params, sm)
+		if err != nil {
+			return errors.Wrap(err, "prepare solver")
+		}
+
+		solution, err := solver.Solve(context.TODO())
+		if err != nil {
+			return handleAllTheFailuresOfTheWorld(err)
+		}
+		lock = dep.LockFromSolution(solution, p.Manifest.PruneOptions)
+	}
+
+	dw, err := dep.NewDeltaWriter(p.Lock, lock, status, p.Manifest.PruneOptions, p.VendorDir(), dep.VendorOnChanged)
+	if err != nil {
+		return err
+	}
+
+	var logger *log.Logger
+	if ctx.Verbose {
+		logger = ctx.Err
+	}
+	if err := dw.Write(p.AbsRoot, sm, true, logger); err != nil {
+		return errors.WithMessage(err, "grouped write of manifest, lock and vendor")
+	}
+	return p.UpdateGoSum()
+}
+
+const staleVendorReason = "written from a different " + dep.LockName + " than the one in the working tree"
+
+// vendorCheck is the result of checking a project's vendor directory against
+// its lock, which dep check and dep ensure -frozen share so that they agree
+// on what is out of sync.
+type vendorCheck struct {
+	// status is the status of each project, as returned by
+	// depcheck.VendorStatus.
+	status map[string]verify.VendorStatus
+	// provenance holds the mismatches found by checkVendorProvenance.
+	provenance map[gps.ProjectRoot][]string
+	// stale is set if vendor/ was written from another lock; see
+	// depcheck.VendorStale.
+	stale bool
+}
+
+// checkVendor checks p's vendor directory against its lock, without creating
+// the directory if it is absent. The projects whose verification is skipped
+// are reported to logger.
+func checkVendor(logger *log.Logger, p *dep.Project) (vendorCheck, error) {
+	var vc vendorCheck
+	var err error
+	if vc.status, err = depcheck.VendorStatus(p); err != nil {
+		return vc, err
+	}
+	printSkippedVerifications(logger, vc.status)
+	if vc.stale, err = depcheck.VendorStale(p); err != nil {
+		return vc, err
+	}
+	vc.provenance, err = checkVendorProvenance(p, vc.status)
+	return vc, err
+}
+
+// divergences describes every way in which the vendor directory differs from
+// the lock, ordered by project root.
+func (vc vendorCheck) divergences() []string {
+	divs := appendVerifyErrors(nil, verify.VendorStatusErr(vc.status))
+	for _, a := range provenancePlan(vc.provenance) {
+		divs = append(divs, fmt.Sprintf("%s: %s", a.ProjectRoot, a.Reason))
+	}
+	if vc.stale {
+		divs = append(divs, "vendor/: "+staleVendorReason)
+	}
+	return divs
+}
+
+// checkVendorProvenance returns, for each project in p's lock whose vendored
+// code matches its digest, the ways in which the provenance recorded with the
+// code disagrees with the lock. Projects vendored without a provenance record
+// are not reported.
+func checkVendorProvenance(p *dep.Project, status map[string]verify.VendorStatus) (map[gps.ProjectRoot][]string, error) {
+	diffs := make(map[gps.ProjectRoot][]string)
+	for _, lp := range p.Lock.Projects() {
+		pr := lp.Ident().ProjectRoot
+		if status[string(pr)] != verify.NoMismatch {
+			continue
+		}
+		prov, err := verify.ReadProvenance(filepath.Join(p.VendorDir(), string(pr)))
+		if err != nil {
+			return nil, err
+		}
+		if prov == nil {
+			continue
+		}
+		vp, ok := lp.(verify.VerifiableProject)
+		if !ok {
+			vp = verify.VerifiableProject{LockedProject: lp}
+		}
+		if d := prov.Mismatches(vp); len(d) > 0 {
+			diffs[pr] = d
+		}
+	}
+	return diffs, nil
+}
+
+// provenancePlan returns the actions re-vendoring each project reported by
+// checkVendorProvenance, in order.
+func provenancePlan(provenance map[gps.ProjectRoot][]string) verify.Plan {
+	plan := make(verify.Plan, 0, len(provenance))
+	for pr, d := range provenance {
+		plan = append(plan, verify.Action{Kind: verify.ActionRevendor, ProjectRoot: pr, Reason: strings.Join(d, "; ")})
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].ProjectRoot < plan[j].ProjectRoot })
+	return plan
+}
+
+// checkInternalImports returns the imports of internal packages of one project
+// by another, among the packages reachable from rpt, the root project's
+// package tree, through those of the projects in p's vendor directory.
+// Projects in p's lock that are missing from vendor/ are left out.
+func checkInternalImports(p *dep.Project, rpt pkgtree.PackageTree) ([]verify.InternalImport, error) {
+	vendored := make(map[gps.ProjectRoot]pkgtree.PackageTree, len(p.Lock.Projects()))
+	for _, lp := range p.Lock.Projects() {
+		pr := lp.Ident().ProjectRoot
+		dir := filepath.Join(p.VendorDir(), string(pr))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		ptree, err := pkgtree.ListPackages(dir, string(pr))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the vendored packages of %s", pr)
+		}
+		vendored[pr] = ptree
+	}
+	return verify.CrossProjectInternalImports(rpt, vendored), nil
+}
+
+// checkSignatures returns the projects in p's lock whose revisions are not
+// validly signed in p's signatures file by a key in the keyring of ctx.
+func checkSignatures(ctx *dep.Ctx, p *dep.Project) ([]dep.UnsignedProject, error) {
+	if ctx.Keyring == "" {
+		return nil, errors.New("-require-signed needs a keyring of trusted keys; set $DEPKEYRING to its path")
+	}
+	kr, err := dep.ReadKeyring(ctx.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := dep.ReadSignatures(filepath.Join(p.AbsRoot, dep.SignaturesName))
+	if err != nil {
+		return nil, err
+	}
+	return dep.CheckSignatures(p.Lock, sigs, kr), nil
+}
+
+// checkSourceURLs deduces the source of each project in l that records the URL
+// it was retrieved from, returning a description of each whose source now
+// resolves elsewhere.
+func checkSourceURLs(ctx *dep.Ctx, l *dep.Lock) ([]string, error) {
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return nil, err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	var moved []string
+	for _, lp := range l.Projects() {
+		vp, ok := lp.(verify.VerifiableProject)
+		if !ok || vp.SourceURL == "" {
+			continue
+		}
+		id := lp.Ident()
+		src := id.Source
+		if src == "" {
+			src = string(id.ProjectRoot)
+		}
+		urls, err := sm.SourceURLsForPath(src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not deduce the source of %s", id.ProjectRoot)
+		}
+		if !sourceURLMatches(vp.SourceURL, urls) {
+			var now string
+			if len(urls) > 0 {
+				now = urls[0].String()
+			}
+			moved = append(moved, fmt.Sprintf("%s: retrieved from %s, but its source now resolves to %s", id.ProjectRoot, vp.SourceURL, now))
+		}
+	}
+	return moved, nil
+}
+
+// revisionReacher is the subset of *gps.SourceMgr needed by checkReachability.
+type revisionReacher interface {
+	ListVersions(gps.ProjectIdentifier) ([]gps.PairedVersion, error)
+	RevisionReachableFrom(id gps.ProjectIdentifier, r, head gps.Revision) (bool, error)
+}
+
+// checkReachability returns a description of each project in l that is locked
+// to a branch or tag from which its locked revision can no longer be reached
+// upstream. Projects locked to a bare revision are not checked.
+func checkReachability(l *dep.Lock, sm revisionReacher) ([]string, error) {
+	var unreachable []string
+	for _, lp := range l.Projects() {
+		pv, ok := lp.Version().(gps.PairedVersion)
+		if !ok {
+			continue
+		}
+		id, uv, rev := lp.Ident(), pv.Unpair(), pv.Revision()
+		kind := "tag"
+		if uv.Type() == gps.IsBranch {
+			kind = "branch"
+		}
+
+		pvs, err := sm.ListVersions(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list the versions of %s", id.ProjectRoot)
+		}
+		var head gps.Revision
+		for _, v := range pvs {
+			if v.Type() == uv.Type() && v.String() == uv.String() {
+				head = v.Revision()
+				break
+			}
+		}
+
+		switch {
+		case head == "":
+			unreachable = append(unreachable, fmt.Sprintf("%s: %s %s no longer exists upstream", id.ProjectRoot, kind, uv))
+		case head == rev:
+		case kind == "branch":
+			reachable, err := sm.RevisionReachableFrom(id, rev, head)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not check the ancestry of %s", id.ProjectRoot)
+			}
+			if !reachable {
+				unreachable = append(unreachable, fmt.Sprintf("%s: %s is no longer reachable from branch %s, which is now at %s", id.ProjectRoot, rev, uv, head))
+			}
+		default:
+			unreachable = append(unreachable, fmt.Sprintf("%s: tag %s now points to %s, not %s", id.ProjectRoot, uv, head, rev))
+		}
+	}
+	return unreachable, nil
+}
+
+// sourceURLMatches reports whether recorded refers to the same location as
+// any of the deduced urls. As deduction yields the same location over several
+// protocols, only the host and path are compared.
+func sourceURLMatches(recorded string, urls []*url.URL) bool {
+	ru, err := url.Parse(recorded)
+	if err != nil {
+		return false
+	}
+	for _, u := range urls {
+		if strings.EqualFold(u.Host, ru.Host) && strings.TrimSuffix(u.Path, ".git") == strings.TrimSuffix(ru.Path, ".git") {
+			return true
+		}
+	}
+	return false
+}
+
+// printPlan writes a numbered list of the actions in plan to logger.
+func printPlan(logger *log.Logger, plan verify.Plan) {
+	for i, a := range plan {
+		logger.Printf("(%d/%d) %s\n", i+1, len(plan), a)
+	}
+}
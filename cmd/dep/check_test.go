@@ -0,0 +1,231 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/verify"
+)
+
+func TestSourceURLMatches(t *testing.T) {
+	var deduced []*url.URL
+	for _, s := range []string{"https://github.com/foo/bar", "ssh://git@github.com/foo/bar", "git://github.com/foo/bar"} {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deduced = append(deduced, u)
+	}
+
+	testCases := map[string]bool{
+		"https://github.com/foo/bar":     true,
+		"ssh://git@github.com/foo/bar":   true,
+		"https://GitHub.com/foo/bar.git": true,
+		"https://github.com/baz/bar":     false,
+		"https://gitlab.com/foo/bar":     false,
+		"::not a url":                    false,
+	}
+	for recorded, want := range testCases {
+		if got := sourceURLMatches(recorded, deduced); got != want {
+			t.Errorf("sourceURLMatches(%q): expected %v, got %v", recorded, want, got)
+		}
+	}
+}
+
+func TestParseCheckFailures(t *testing.T) {
+	testCases := map[string]checkFailure{
+		"":                 checkAllFailures,
+		"lock":             checkLockStale,
+		"vendor, missing":  checkVendorAltered | checkMissingProject,
+		"constraint,lock":  checkConstraintMismatch | checkLockStale,
+		"lock,vendor,lock": checkLockStale | checkVendorAltered,
+	}
+	for in, want := range testCases {
+		got, err := parseCheckFailures(in)
+		if err != nil {
+			t.Errorf("parseCheckFailures(%q): unexpected error: %s", in, err)
+		} else if got != want {
+			t.Errorf("parseCheckFailures(%q): expected %d, got %d", in, want, got)
+		}
+	}
+
+	if _, err := parseCheckFailures("lock,bogus"); err == nil {
+		t.Error("expected an unknown class to be rejected")
+	}
+}
+
+func TestCheckFailures(t *testing.T) {
+	lsat := verify.LockSatisfaction{
+		LockExisted:      true,
+		UnmetConstraints: map[gps.ProjectRoot]verify.ConstraintMismatch{"github.com/foo/bar": {}},
+	}
+	status := map[string]verify.VendorStatus{
+		"github.com/foo/bar":     verify.NoMismatch,
+		"github.com/foo/missing": verify.NotInTree,
+		"github.com/foo/patched": verify.DigestMismatchSkipped,
+	}
+
+	want := checkConstraintMismatch | checkMissingProject
+	if got := checkFailures(lsat, status); got != want {
+		t.Errorf("expected failures %d, got %d", want, got)
+	}
+
+	lsat = verify.LockSatisfaction{LockExisted: true, MissingImports: []string{"github.com/foo/new"}}
+	status = map[string]verify.VendorStatus{"github.com/foo/orphan": verify.NotInLock}
+	want = checkLockStale | checkVendorAltered
+	if got := checkFailures(lsat, status); got != want {
+		t.Errorf("expected failures %d, got %d", want, got)
+	}
+}
+
+func TestCheckVendorProvenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ident := gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}
+	vendored := verify.VerifiableProject{
+		LockedProject: gps.NewLockedProject(ident, gps.NewVersion("v1.0.0").Pair("abc123"), []string{"."}),
+		SourceURL:     "https://github.com/foo/bar",
+	}
+	pdir := filepath.Join(dir, "vendor", "github.com", "foo", "bar")
+	if err := os.MkdirAll(pdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify.WriteProvenance(pdir, verify.NewProvenance(vendored, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+
+	status := map[string]verify.VendorStatus{"github.com/foo/bar": verify.NoMismatch}
+	p := &dep.Project{AbsRoot: dir, Lock: &dep.Lock{P: []gps.LockedProject{vendored}}}
+	if prov, err := checkVendorProvenance(p, status); err != nil || len(prov) != 0 {
+		t.Fatalf("expected no mismatches with the lock vendored from, got %v, %v", prov, err)
+	}
+
+	// The lock was edited to another revision, without re-vendoring.
+	edited := vendored
+	edited.LockedProject = gps.NewLockedProject(ident, gps.NewVersion("v1.0.0").Pair("def456"), []string{"."})
+	p.Lock = &dep.Lock{P: []gps.LockedProject{edited}}
+	prov, err := checkVendorProvenance(p, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := provenancePlan(prov)
+	if len(plan) != 1 || plan[0].Kind != verify.ActionRevendor || plan[0].ProjectRoot != "github.com/foo/bar" {
+		t.Errorf("expected github.com/foo/bar to be re-vendored, got %v", plan)
+	}
+
+	// Projects whose code does not match the lock are already reported.
+	status["github.com/foo/bar"] = verify.DigestMismatchInLock
+	if prov, err := checkVendorProvenance(p, status); err != nil || len(prov) != 0 {
+		t.Errorf("expected a project with mismatched code not to be checked, got %v, %v", prov, err)
+	}
+}
+
+func TestCheckInternalImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for path, contents := range map[string]string{
+		"github.com/foo/bar/bar.go":                  "package bar\n",
+		"github.com/foo/bar/internal/codec/codec.go": "package codec\n",
+		"github.com/foo/bar/v2/bar.go":               "package bar\n\nimport _ \"github.com/foo/bar/internal/codec\"\n",
+	} {
+		path = filepath.Join(dir, "vendor", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lps []gps.LockedProject
+	for _, pr := range []gps.ProjectRoot{"github.com/foo/bar", "github.com/foo/bar/v2", "github.com/missing/dep"} {
+		lps = append(lps, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.Revision("abc123"), []string{"."}))
+	}
+	p := &dep.Project{AbsRoot: dir, Lock: &dep.Lock{P: lps}}
+	rpt := pkgtree.PackageTree{
+		ImportRoot: "root",
+		Packages: map[string]pkgtree.PackageOrErr{
+			"root": {P: pkgtree.Package{ImportPath: "root", Imports: []string{"github.com/foo/bar", "github.com/foo/bar/v2"}}},
+		},
+	}
+
+	imports, err := checkInternalImports(p, rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imports) != 1 || imports[0].Importer != "github.com/foo/bar/v2" || imports[0].Imported != "github.com/foo/bar" {
+		t.Errorf("expected the import across the major versions to be reported, got %v", imports)
+	}
+}
+
+type fakeReacher struct {
+	versions  map[gps.ProjectRoot][]gps.PairedVersion
+	ancestors map[gps.Revision]gps.Revision
+}
+
+func (r fakeReacher) ListVersions(id gps.ProjectIdentifier) ([]gps.PairedVersion, error) {
+	return r.versions[id.ProjectRoot], nil
+}
+
+func (r fakeReacher) RevisionReachableFrom(id gps.ProjectIdentifier, rev, head gps.Revision) (bool, error) {
+	return r.ancestors[head] == rev, nil
+}
+
+func TestCheckReachability(t *testing.T) {
+	lp := func(root string, v gps.Version) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, v, nil)
+	}
+	lock := &dep.Lock{
+		P: []gps.LockedProject{
+			lp("github.com/foo/advanced", gps.NewBranch("master").Pair("aaa")),
+			lp("github.com/foo/forced", gps.NewBranch("master").Pair("bbb")),
+			lp("github.com/foo/deleted", gps.NewBranch("feature").Pair("ccc")),
+			lp("github.com/foo/moved", gps.NewVersion("v1.0.0").Pair("ddd")),
+			lp("github.com/foo/tagged", gps.NewVersion("v1.0.0").Pair("eee")),
+			lp("github.com/foo/bare", gps.Revision("fff")),
+		},
+	}
+	sm := fakeReacher{
+		versions: map[gps.ProjectRoot][]gps.PairedVersion{
+			"github.com/foo/advanced": {gps.NewBranch("master").Pair("aaa2")},
+			"github.com/foo/forced":   {gps.NewBranch("master").Pair("bbb2")},
+			"github.com/foo/deleted":  {gps.NewBranch("master").Pair("ccc")},
+			"github.com/foo/moved":    {gps.NewVersion("v1.0.0").Pair("ddd2")},
+			"github.com/foo/tagged":   {gps.NewBranch("v1.0.0").Pair("xxx"), gps.NewVersion("v1.0.0").Pair("eee")},
+		},
+		ancestors: map[gps.Revision]gps.Revision{"aaa2": "aaa"},
+	}
+
+	got, err := checkReachability(lock, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"github.com/foo/forced: bbb is no longer reachable from branch master, which is now at bbb2",
+		"github.com/foo/deleted: branch feature no longer exists upstream",
+		"github.com/foo/moved: tag v1.0.0 now points to ddd2, not ddd",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected unreachable revisions:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const completionShortHelp = `Generate a shell completion script`
+const completionLongHelp = `
+Completion writes a script to standard output that sets up the completion of
+dep's commands and flags in bash, zsh or fish. The project roots of the
+current project's dependencies are completed as the arguments of
+"dep ensure -update" and "dep status", and the values of "dep status
+-who-constrains" and "-why-version", by running "dep completion -projects"
+each time, so the names always follow Gopkg.lock.
+
+To enable completion, load the script from your shell's startup file:
+
+  bash:  source <(dep completion bash)           (in ~/.bashrc)
+  zsh:   source <(dep completion zsh)            (in ~/.zshrc)
+  fish:  dep completion fish | source            (in ~/.config/fish/config.fish)
+
+With -projects, completion instead lists the project roots in the Gopkg.lock
+of the current project, one per line. Nothing is listed outside of a project.
+`
+
+func (cmd *completionCommand) Name() string      { return "completion" }
+func (cmd *completionCommand) Args() string      { return "[-projects] <bash|zsh|fish>" }
+func (cmd *completionCommand) ShortHelp() string { return completionShortHelp }
+func (cmd *completionCommand) LongHelp() string  { return completionLongHelp }
+func (cmd *completionCommand) Hidden() bool      { return false }
+
+func (cmd *completionCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.projects, "projects", false, "list the project roots in the current project's Gopkg.lock, for completion scripts")
+}
+
+type completionCommand struct {
+	projects bool
+}
+
+func (cmd *completionCommand) Run(ctx *dep.Ctx, args []string) error {
+	if cmd.projects {
+		if len(args) > 0 {
+			return errors.New("-projects takes no arguments")
+		}
+		return listLockedProjectRoots(ctx)
+	}
+
+	if len(args) != 1 {
+		return errors.New("completion takes the name of a shell: bash, zsh or fish")
+	}
+	tmpl, has := completionTemplates[args[0]]
+	if !has {
+		return errors.Errorf("no completion for the %s shell; must be one of bash, zsh or fish", args[0])
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, completionCommands()); err != nil {
+		return errors.Wrap(err, "failed to generate the completion script")
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// listLockedProjectRoots prints the roots of the projects in the lock of the
+// current project. Nothing is printed if there is no project or lock: it runs
+// each time a shell completes a project, which can be from anywhere.
+func listLockedProjectRoots(ctx *dep.Ctx) error {
+	mp, err := ctx.ManifestPath()
+	if err != nil {
+		return nil
+	}
+	lf, err := os.Open(filepath.Join(filepath.Dir(mp), dep.LockName))
+	if err != nil {
+		return nil
+	}
+	defer lf.Close()
+
+	l, err := dep.ReadLock(lf)
+	if err != nil {
+		return errors.Wrapf(err, "error while parsing %s", dep.LockName)
+	}
+	for _, lp := range l.Projects() {
+		ctx.Out.Println(lp.Ident().ProjectRoot)
+	}
+	return nil
+}
+
+// completionCmd describes a dep command for a completion script.
+type completionCmd struct {
+	Name, Help string
+	Flags      []completionFlag
+}
+
+type completionFlag struct {
+	Name, Usage string
+}
+
+// completionCommands describes the visible commands of dep, with their flags,
+// in the order they are listed in dep's usage.
+func completionCommands() []completionCmd {
+	var cmds []completionCmd
+	for _, c := range commandList() {
+		if c.Hidden() {
+			continue
+		}
+		fs := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		fs.Bool("v", false, "enable verbose logging")
+		c.Register(fs)
+
+		cc := completionCmd{Name: c.Name(), Help: c.ShortHelp()}
+		fs.VisitAll(func(f *flag.Flag) {
+			cc.Flags = append(cc.Flags, completionFlag{Name: f.Name, Usage: f.Usage})
+		})
+		sort.Slice(cc.Flags, func(i, j int) bool { return cc.Flags[i].Name < cc.Flags[j].Name })
+		cmds = append(cmds, cc)
+	}
+	return cmds
+}
+
+var completionFuncs = template.FuncMap{
+	// flagNames joins the flags, with their dashes, for a list of words.
+	"flagNames": func(flags []completionFlag) string {
+		names := make([]string, len(flags))
+		for i, f := range flags {
+			names[i] = "-" + f.Name
+		}
+		return strings.Join(names, " ")
+	},
+	// zshDescribe escapes s for use as either part of an entry of _describe,
+	// within single quotes.
+	"zshDescribe": func(s string) string {
+		return strings.NewReplacer(`'`, `'\''`, `:`, `\:`).Replace(s)
+	},
+	// fishQuote escapes s for use within single quotes in fish.
+	"fishQuote": func(s string) string {
+		return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+	},
+}
+
+var completionTemplates = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Funcs(completionFuncs).Parse(bashCompletion)),
+	"zsh":  template.Must(template.New("zsh").Funcs(completionFuncs).Parse(zshCompletion)),
+	"fish": template.Must(template.New("fish").Funcs(completionFuncs).Parse(fishCompletion)),
+}
+
+const bashCompletion = `# bash completion for dep, generated by "dep completion bash".
+
+_dep() {
+	local cur cmd
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "{{range $i, $c := .}}{{if $i}} {{end}}{{$c.Name}}{{end}} help" -- "$cur"))
+		return
+	fi
+
+	cmd="${COMP_WORDS[1]}"
+	if [ "$cmd" = "status" ] && { [ "${COMP_WORDS[COMP_CWORD-1]}" = "-who-constrains" ] || [ "${COMP_WORDS[COMP_CWORD-1]}" = "-why-version" ]; }; then
+		COMPREPLY=($(compgen -W "$(dep completion -projects 2>/dev/null)" -- "$cur"))
+		return
+	fi
+	if [[ "$cur" == -* ]]; then
+		case "$cmd" in
+{{- range .}}
+		{{.Name}}) COMPREPLY=($(compgen -W "{{flagNames .Flags}}" -- "$cur")) ;;
+{{- end}}
+		esac
+		return
+	fi
+
+	case "$cmd" in
+	ensure)
+		if [[ " ${COMP_WORDS[*]} " == *" -update "* ]]; then
+			COMPREPLY=($(compgen -W "$(dep completion -projects 2>/dev/null)" -- "$cur"))
+		fi
+		;;
+	status)
+		COMPREPLY=($(compgen -W "$(dep completion -projects 2>/dev/null)" -- "$cur"))
+		;;
+	help)
+		COMPREPLY=($(compgen -W "{{range $i, $c := .}}{{if $i}} {{end}}{{$c.Name}}{{end}}" -- "$cur"))
+		;;
+	esac
+}
+
+complete -o default -F _dep dep
+`
+
+const zshCompletion = `#compdef dep
+# zsh completion for dep, generated by "dep completion zsh".
+
+_dep() {
+	local -a cmds flags projects
+	if (( CURRENT == 2 )); then
+		cmds=(
+{{- range .}}
+			'{{zshDescribe .Name}}:{{zshDescribe .Help}}'
+{{- end}}
+			'help:Show the help for a command'
+		)
+		_describe 'command' cmds
+		return
+	fi
+
+	if [[ $words[2] == status && ( $words[CURRENT-1] == -who-constrains || $words[CURRENT-1] == -why-version ) ]]; then
+		projects=(${(f)"$(dep completion -projects 2>/dev/null)"})
+		compadd -a projects
+		return
+	fi
+	if [[ $words[CURRENT] == -* ]]; then
+		case $words[2] in
+{{- range .}}
+		{{.Name}}) flags=({{flagNames .Flags}}) ;;
+{{- end}}
+		esac
+		compadd -a flags
+		return
+	fi
+
+	case $words[2] in
+	ensure)
+		if (( ${words[(I)-update]} )); then
+			projects=(${(f)"$(dep completion -projects 2>/dev/null)"})
+			compadd -a projects
+		fi
+		;;
+	status)
+		projects=(${(f)"$(dep completion -projects 2>/dev/null)"})
+		compadd -a projects
+		;;
+	help)
+		cmds=({{range $i, $c := .}}{{if $i}} {{end}}{{$c.Name}}{{end}})
+		compadd -a cmds
+		;;
+	*)
+		_files
+		;;
+	esac
+}
+
+compdef _dep dep
+`
+
+const fishCompletion = `# fish completion for dep, generated by "dep completion fish".
+
+complete -c dep -f
+{{- range .}}
+complete -c dep -n '__fish_use_subcommand' -a '{{fishQuote .Name}}' -d '{{fishQuote .Help}}'
+{{- end}}
+complete -c dep -n '__fish_use_subcommand' -a 'help' -d 'Show the help for a command'
+{{range $c := .}}
+{{- range .Flags}}
+complete -c dep -n '__fish_seen_subcommand_from {{$c.Name}}' -o '{{fishQuote .Name}}' -d '{{fishQuote .Usage}}'
+{{- end}}
+{{- end}}
+
+complete -c dep -n '__fish_seen_subcommand_from ensure; and __fish_contains_opt -o update' -a '(dep completion -projects 2>/dev/null)'
+complete -c dep -n '__fish_seen_subcommand_from status' -a '(dep completion -projects 2>/dev/null)'
+complete -c dep -n '__fish_seen_subcommand_from status' -o 'who-constrains' -x -a '(dep completion -projects 2>/dev/null)'
+complete -c dep -n '__fish_seen_subcommand_from status' -o 'why-version' -x -a '(dep completion -projects 2>/dev/null)'
+`
@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	for shell, tmpl := range completionTemplates {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, completionCommands()); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		for _, want := range []string{"ensure", "update", "who-constrains", "dep completion -projects"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: expected the script to contain %q", shell, want)
+			}
+		}
+	}
+}
+
+func TestListLockedProjectRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-completion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lock := `[[projects]]
+  name = "github.com/pkg/errors"
+  packages = ["."]
+  revision = "645ef00459ed84a119197bfb8d8205042c6df63d"
+  version = "v0.8.0"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, dep.ManifestName), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, dep.LockName), []byte(lock), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	ctx := &dep.Ctx{
+		WorkingDir: dir,
+		Out:        log.New(&out, "", 0),
+		Err:        log.New(ioutil.Discard, "", 0),
+	}
+	if err := listLockedProjectRoots(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "github.com/pkg/errors\n"; got != want {
+		t.Errorf("expected %q to be listed, got %q", want, got)
+	}
+
+	// Outside of a project, nothing is listed.
+	out.Reset()
+	ctx.WorkingDir = filepath.Dir(dir)
+	if err := listLockedProjectRoots(ctx); err != nil || out.Len() != 0 {
+		t.Errorf("expected nothing to be listed outside a project, got %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const daemonShortHelp = `Keep cached version lists fresh in the background`
+const daemonLongHelp = `
+Periodically refresh the version lists held in the persistent cache for the
+projects in the Gopkg.lock files of recently used projects, so that commands
+such as dep status -old and dep ensure -update can answer from the cache
+instead of waiting on the network to list the versions of each source.
+
+The persistent cache is only used when DEPCACHEAGE is set; dep records each
+project whose Gopkg.lock it loads while it is. Versions refreshed by the
+daemon count as fetched when they were refreshed, so an interval shorter than
+DEPCACHEAGE keeps them from ever going stale.
+
+The daemon runs in the foreground until interrupted. The cache is only locked
+while a refresh is under way, so other dep commands may need to wait for one
+to finish.
+`
+
+func (cmd *daemonCommand) Name() string      { return "daemon" }
+func (cmd *daemonCommand) Args() string      { return "[-interval duration] [-recent duration] [-once]" }
+func (cmd *daemonCommand) ShortHelp() string { return daemonShortHelp }
+func (cmd *daemonCommand) LongHelp() string  { return daemonLongHelp }
+func (cmd *daemonCommand) Hidden() bool      { return false }
+
+func (cmd *daemonCommand) Register(fs *flag.FlagSet) {
+	fs.DurationVar(&cmd.interval, "interval", 15*time.Minute, "time between refreshes")
+	fs.DurationVar(&cmd.recent, "recent", 7*24*time.Hour, "only refresh the projects in locks loaded within this long")
+	fs.BoolVar(&cmd.once, "once", false, "refresh once, then exit")
+}
+
+type daemonCommand struct {
+	interval time.Duration
+	recent   time.Duration
+	once     bool
+}
+
+func (cmd *daemonCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	if ctx.CacheAge <= 0 {
+		return errors.New("the daemon refreshes the persistent cache, which is only used when DEPCACHEAGE is set")
+	}
+	if cmd.interval <= 0 || cmd.recent <= 0 {
+		return errors.New("-interval and -recent must be positive")
+	}
+
+	// Disregard what is cached, so that every version list is fetched again
+	// and cached afresh.
+	rctx := *ctx
+	rctx.RefreshCache = true
+
+	for {
+		if err := cmd.refresh(&rctx); err != nil {
+			if errors.Cause(err) == gps.ErrSourceManagerIsReleased {
+				// Interrupted.
+				return nil
+			}
+			return err
+		}
+		if cmd.once {
+			return nil
+		}
+		time.Sleep(cmd.interval)
+	}
+}
+
+// refresh fetches the version lists of the projects in the locks of the
+// recently used projects, caching them.
+func (cmd *daemonCommand) refresh(ctx *dep.Ctx) error {
+	roots, err := dep.RecentProjects(ctx.CacheDir(), time.Now().Add(-cmd.recent))
+	if err != nil {
+		return errors.Wrap(err, "failed to read the recently used projects")
+	}
+
+	ids := lockedIdentifiers(ctx, roots)
+	if len(ids) == 0 {
+		if ctx.Verbose {
+			ctx.Err.Println("No recently used projects to refresh.")
+		}
+		return nil
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id gps.ProjectIdentifier) {
+			defer wg.Done()
+			_, errs[i] = sm.ListVersions(id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	var failed int
+	for i, err := range errs {
+		if errors.Cause(err) == gps.ErrSourceManagerIsReleased {
+			return err
+		} else if err != nil {
+			failed++
+			ctx.Err.Printf("Unable to refresh the versions of %s: %s\n", ids[i], err)
+		}
+	}
+	if ctx.Verbose {
+		ctx.Err.Printf("Refreshed the versions of %d of %d projects from %d locks.\n", len(ids)-failed, len(ids), len(roots))
+	}
+	return nil
+}
+
+// lockedIdentifiers returns the identifiers of the projects in the locks of the
+// projects at roots, without duplicates and sorted. Projects whose locks cannot
+// be read are skipped.
+func lockedIdentifiers(ctx *dep.Ctx, roots []string) []gps.ProjectIdentifier {
+	seen := make(map[gps.ProjectIdentifier]bool)
+	var ids []gps.ProjectIdentifier
+	for _, root := range roots {
+		f, err := os.Open(filepath.Join(root, dep.LockName))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				ctx.Err.Printf("Unable to read the lock of %s: %s\n", root, err)
+			}
+			continue
+		}
+		l, err := dep.ReadLock(f)
+		f.Close()
+		if err != nil {
+			ctx.Err.Printf("Unable to read the lock of %s: %s\n", root, err)
+			continue
+		}
+
+		for _, lp := range l.Projects() {
+			if id := lp.Ident(); !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+	return ids
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestLockedIdentifiers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	locks := map[string]string{
+		"a": `
+[[projects]]
+  name = "github.com/foo/bar"
+  revision = "abc123"
+  version = "v1.0.0"
+
+[[projects]]
+  name = "github.com/foo/baz"
+  revision = "def456"
+`,
+		"b": `
+[[projects]]
+  name = "github.com/foo/bar"
+  revision = "abc123"
+  version = "v1.0.0"
+
+[[projects]]
+  name = "github.com/foo/qux"
+  source = "https://example.com/qux.git"
+  revision = "0123ab"
+`,
+		"bad": "[[projects",
+	}
+	var roots []string
+	for name, lock := range locks {
+		root := filepath.Join(dir, name)
+		if err := os.Mkdir(root, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, dep.LockName), []byte(lock), 0666); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	// A project that no longer has a lock is skipped quietly.
+	roots = append(roots, filepath.Join(dir, "gone"))
+
+	var stderr bytes.Buffer
+	ctx := &dep.Ctx{Err: log.New(&stderr, "", 0)}
+	got := lockedIdentifiers(ctx, roots)
+	want := []gps.ProjectIdentifier{
+		{ProjectRoot: "github.com/foo/bar"},
+		{ProjectRoot: "github.com/foo/baz"},
+		{ProjectRoot: "github.com/foo/qux", Source: "https://example.com/qux.git"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if out := stderr.String(); !strings.Contains(out, filepath.Join(dir, "bad")) || strings.Contains(out, "gone") {
+		t.Errorf("expected only the unreadable lock to be reported, got %q", out)
+	}
+}
+
+func TestDaemonRequiresCache(t *testing.T) {
+	cmd := &daemonCommand{interval: 1, recent: 1, once: true}
+	if err := cmd.Run(&dep.Ctx{}, nil); err == nil {
+		t.Error("expected an error without a persistent cache")
+	}
+}
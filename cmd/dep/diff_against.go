@@ -0,0 +1,311 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+const diffAgainstShortHelp = `Show how dependency resolution differs from another git ref`
+const diffAgainstLongHelp = `
+Compare the project's Gopkg.toml and Gopkg.lock to those at the given git
+ref, such as a branch, tag or commit, reading the latter from the repository
+without touching the working tree. Each project whose locked version,
+revision, source or set of packages differs is listed, as are projects whose
+constraint or override alone has changed:
+
+  +  added since ref
+  -  removed since ref
+  ~  locked differently than at ref
+  =  locked the same, under a different rule in Gopkg.toml
+
+With -json, the changes are written as a JSON array, each with its
+ProjectRoot, Change (added, removed, updated or constraint), the Before and
+After resolution, and the changes to its rule and packages.
+`
+
+func (cmd *diffAgainstCommand) Name() string      { return "diff-against" }
+func (cmd *diffAgainstCommand) Args() string      { return "[-json] <ref>" }
+func (cmd *diffAgainstCommand) ShortHelp() string { return diffAgainstShortHelp }
+func (cmd *diffAgainstCommand) LongHelp() string  { return diffAgainstLongHelp }
+func (cmd *diffAgainstCommand) Hidden() bool      { return false }
+
+func (cmd *diffAgainstCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+type diffAgainstCommand struct {
+	json bool
+}
+
+// ProjectResolution is how a project is locked.
+type ProjectResolution struct {
+	Version  string `json:",omitempty"`
+	Revision string
+	Source   string `json:",omitempty"`
+}
+
+func (pr *ProjectResolution) String() string {
+	rev := pr.Revision
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	s := rev
+	if pr.Version != "" {
+		s = fmt.Sprintf("%s (%s)", pr.Version, rev)
+	}
+	if pr.Source != "" {
+		s += " from " + pr.Source
+	}
+	return s
+}
+
+// ResolutionChange describes how a project is resolved differently in one
+// revision of the root project than in another.
+type ResolutionChange struct {
+	ProjectRoot string
+	// Change is added, removed, updated for a project locked differently, or
+	// constraint for one only constrained differently.
+	Change           string
+	Before           *ProjectResolution `json:",omitempty"`
+	After            *ProjectResolution `json:",omitempty"`
+	ConstraintBefore string             `json:",omitempty"`
+	ConstraintAfter  string             `json:",omitempty"`
+	PackagesAdded    []string           `json:",omitempty"`
+	PackagesRemoved  []string           `json:",omitempty"`
+}
+
+func (cmd *diffAgainstCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected the git ref to compare against")
+	}
+	ref := args[0]
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if err := gitCommand(p.AbsRoot, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run(); err != nil {
+		return errors.Errorf("%s is not a commit in the repository of %s", ref, p.AbsRoot)
+	}
+
+	var fromM *dep.Manifest
+	data, err := gitShowFile(p.AbsRoot, ref, dep.ManifestName)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if fromM, _, err = dep.ReadManifest(bytes.NewReader(data)); err != nil {
+			return errors.Wrapf(err, "error while parsing %s at %s", dep.ManifestName, ref)
+		}
+	}
+
+	var fromL *dep.Lock
+	data, err = gitShowFile(p.AbsRoot, ref, dep.LockName)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if fromL, err = dep.ReadLock(bytes.NewReader(data)); err != nil {
+			return errors.Wrapf(err, "error while parsing %s at %s", dep.LockName, ref)
+		}
+	}
+
+	changes := diffResolutions(fromM, fromL, p.Manifest, p.Lock)
+	if cmd.json {
+		if changes == nil {
+			changes = []ResolutionChange{}
+		}
+		out, err := json.Marshal(changes)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the changes in resolution")
+		}
+		ctx.Out.Println(string(out))
+		return nil
+	}
+	if len(changes) == 0 {
+		ctx.Out.Printf("Dependencies are resolved the same as at %s.\n", ref)
+		return nil
+	}
+	ctx.Out.Print(formatResolutionChanges(changes))
+	return nil
+}
+
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	return c
+}
+
+// gitShowFile returns the contents, as of the git ref, of the file with the
+// given name in dir, or nil if it did not exist then.
+func gitShowFile(dir, ref, name string) ([]byte, error) {
+	spec := ref + ":./" + name
+	if err := gitCommand(dir, "cat-file", "-e", spec).Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to run git")
+	}
+	out, err := gitCommand(dir, "show", spec).Output()
+	return out, errors.Wrapf(err, "failed to read %s at %s", name, ref)
+}
+
+// diffResolutions compares how each project is resolved by the manifest and
+// lock from, to those of to, either of which may be nil. The changes are
+// ordered by project root.
+func diffResolutions(fromM *dep.Manifest, fromL *dep.Lock, toM *dep.Manifest, toL *dep.Lock) []ResolutionChange {
+	byRoot := func(l *dep.Lock) map[gps.ProjectRoot]gps.LockedProject {
+		m := make(map[gps.ProjectRoot]gps.LockedProject)
+		for _, lp := range l.Projects() {
+			m[lp.Ident().ProjectRoot] = lp
+		}
+		return m
+	}
+	from, to := byRoot(fromL), byRoot(toL)
+
+	roots := make(map[gps.ProjectRoot]bool)
+	for _, m := range []map[gps.ProjectRoot]gps.LockedProject{from, to} {
+		for pr := range m {
+			roots[pr] = true
+		}
+	}
+	for _, m := range []*dep.Manifest{fromM, toM} {
+		if m == nil {
+			continue
+		}
+		for pr := range m.Constraints {
+			roots[pr] = true
+		}
+		for pr := range m.Ovr {
+			roots[pr] = true
+		}
+	}
+
+	var changes []ResolutionChange
+	for pr := range roots {
+		rc := ResolutionChange{
+			ProjectRoot:      string(pr),
+			Before:           newProjectResolution(from[pr]),
+			After:            newProjectResolution(to[pr]),
+			ConstraintBefore: manifestRule(fromM, pr),
+			ConstraintAfter:  manifestRule(toM, pr),
+		}
+
+		switch {
+		case rc.Before == nil && rc.After == nil:
+			// Only constrained, on at least one side.
+			if rc.ConstraintBefore == rc.ConstraintAfter {
+				continue
+			}
+			rc.Change = "constraint"
+		case rc.Before == nil:
+			rc.Change = "added"
+		case rc.After == nil:
+			rc.Change = "removed"
+		default:
+			pd := verify.DiffLockedProjectProperties(from[pr], to[pr])
+			rc.PackagesAdded, rc.PackagesRemoved = pd.PackagesAdded, pd.PackagesRemoved
+			if pd.Changed(verify.SourceChanged | verify.VersionChanged | verify.RevisionChanged | verify.PackagesChanged) {
+				rc.Change = "updated"
+			} else if rc.ConstraintBefore != rc.ConstraintAfter {
+				rc.Change = "constraint"
+			} else {
+				continue
+			}
+		}
+		changes = append(changes, rc)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ProjectRoot < changes[j].ProjectRoot })
+	return changes
+}
+
+func newProjectResolution(lp gps.LockedProject) *ProjectResolution {
+	if lp == nil {
+		return nil
+	}
+	pr := &ProjectResolution{Source: lp.Ident().Source}
+	switch v := lp.Version().(type) {
+	case gps.PairedVersion:
+		pr.Version, pr.Revision = formatVersion(v.Unpair()), string(v.Revision())
+	case gps.Revision:
+		pr.Revision = string(v)
+	default:
+		pr.Version = formatVersion(v)
+	}
+	return pr
+}
+
+// manifestRule describes the override or constraint that m declares on pr,
+// if any.
+func manifestRule(m *dep.Manifest, pr gps.ProjectRoot) string {
+	if m == nil {
+		return ""
+	}
+	if pp, has := m.Ovr[pr]; has && pp.Constraint != nil {
+		return formatConstraint(pp.Constraint) + " (override)"
+	}
+	if pp, has := m.Constraints[pr]; has && pp.Constraint != nil {
+		return formatConstraint(pp.Constraint)
+	}
+	return ""
+}
+
+// formatResolutionChanges renders changes as text, a line for each with the
+// changes to its rule and packages below it.
+func formatResolutionChanges(changes []ResolutionChange) string {
+	var buf bytes.Buffer
+	for _, rc := range changes {
+		switch rc.Change {
+		case "added":
+			fmt.Fprintf(&buf, "+ %s %s\n", rc.ProjectRoot, rc.After)
+		case "removed":
+			fmt.Fprintf(&buf, "- %s %s\n", rc.ProjectRoot, rc.Before)
+		case "updated":
+			fmt.Fprintf(&buf, "~ %s %s -> %s\n", rc.ProjectRoot, rc.Before, rc.After)
+		default:
+			if rc.After != nil {
+				fmt.Fprintf(&buf, "= %s %s\n", rc.ProjectRoot, rc.After)
+			} else {
+				fmt.Fprintf(&buf, "= %s\n", rc.ProjectRoot)
+			}
+		}
+
+		if rc.ConstraintBefore != rc.ConstraintAfter {
+			fmt.Fprintf(&buf, "    constraint: %s -> %s\n", orNone(rc.ConstraintBefore), orNone(rc.ConstraintAfter))
+		}
+		if len(rc.PackagesAdded) > 0 || len(rc.PackagesRemoved) > 0 {
+			var pkgs []string
+			for _, pkg := range rc.PackagesAdded {
+				pkgs = append(pkgs, "+"+pkg)
+			}
+			for _, pkg := range rc.PackagesRemoved {
+				pkgs = append(pkgs, "-"+pkg)
+			}
+			fmt.Fprintf(&buf, "    packages: %s\n", strings.Join(pkgs, " "))
+		}
+	}
+	return buf.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
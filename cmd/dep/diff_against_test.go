@@ -0,0 +1,58 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestDiffResolutions(t *testing.T) {
+	lp := func(root, version, rev string, pkgs ...string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, gps.NewVersion(version).Pair(gps.Revision(rev)), pkgs)
+	}
+	constrain := func(m *dep.Manifest, root, c string) {
+		m.Constraints[gps.ProjectRoot(root)] = gps.ProjectProperties{Constraint: gps.NewVersion(c)}
+	}
+
+	fromM, toM := dep.NewManifest(), dep.NewManifest()
+	constrain(fromM, "github.com/foo/bar", "v1.0.0")
+	constrain(toM, "github.com/foo/bar", "v1.1.0")
+	constrain(fromM, "github.com/foo/same", "v1.0.0")
+	constrain(toM, "github.com/foo/same", "v1.0.0")
+	constrain(fromM, "github.com/foo/loose", "v1.0.0")
+	constrain(toM, "github.com/foo/loose", "v2.0.0")
+
+	fromL := &dep.Lock{P: []gps.LockedProject{
+		lp("github.com/foo/bar", "v1.0.0", "1234567890", ".", "b"),
+		lp("github.com/foo/loose", "v1.0.0", "abcdefabcd", "."),
+		lp("github.com/foo/old", "v0.1.0", "defdefdefd", "."),
+		lp("github.com/foo/same", "v1.0.0", "0000000000", "."),
+	}}
+	toL := &dep.Lock{P: []gps.LockedProject{
+		lp("github.com/foo/bar", "v1.1.0", "89abcdef01", ".", "a"),
+		lp("github.com/foo/loose", "v1.0.0", "abcdefabcd", "."),
+		lp("github.com/foo/new", "v1.2.0", "fedcbafedc", "."),
+		lp("github.com/foo/same", "v1.0.0", "0000000000", "."),
+	}}
+
+	got := formatResolutionChanges(diffResolutions(fromM, fromL, toM, toL))
+	want := "~ github.com/foo/bar v1.0.0 (1234567) -> v1.1.0 (89abcde)\n" +
+		"    constraint: v1.0.0 -> v1.1.0\n" +
+		"    packages: +a -b\n" +
+		"= github.com/foo/loose v1.0.0 (abcdefa)\n" +
+		"    constraint: v1.0.0 -> v2.0.0\n" +
+		"+ github.com/foo/new v1.2.0 (fedcbaf)\n" +
+		"- github.com/foo/old v0.1.0 (defdefd)\n"
+	if got != want {
+		t.Errorf("unexpected changes:\n%s\nwanted:\n%s", got, want)
+	}
+
+	if changes := diffResolutions(nil, nil, toM, toL); len(changes) != len(toL.P) {
+		t.Errorf("expected every project to be added against a ref without a lock, got %v", changes)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/golang/dep"
+)
+
+// dryRunChangesExitCode is the exit code of a command passed -dry-run that
+// would have made changes. It exits with successExitCode if none are needed,
+// so that each such command can serve as a check in CI.
+const dryRunChangesExitCode = 2
+
+// errDryRunChanges is returned by commands passed -dry-run that would have
+// made changes.
+var errDryRunChanges error = dryRunChangesError{}
+
+type dryRunChangesError struct{}
+
+func (dryRunChangesError) Error() string {
+	return "changes are needed; none were made, as -dry-run was passed"
+}
+
+func (dryRunChangesError) ExitCode() int {
+	return dryRunChangesExitCode
+}
+
+// printDryRun prints the actions tw would perform, returning errDryRunChanges
+// if there are any.
+func printDryRun(ctx *dep.Ctx, tw dep.TreeWriter) error {
+	if err := tw.PrintPreparedActions(ctx.Out, ctx.Verbose); err != nil {
+		return err
+	}
+	if tw.HasChanges() {
+		return errDryRunChanges
+	}
+	return nil
+}
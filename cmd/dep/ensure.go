@@ -5,16 +5,19 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
 	"go/build"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
@@ -93,6 +96,20 @@ dep ensure -add github.com/pkg/foo:git.internal.com/alt/foo
 
     Specify an alternate location to treat as the upstream source for a dependency.
 
+dep ensure -add -reason "parses the config files" github.com/pkg/foo
+
+    Record why the dependency was added in the metadata of its new constraint
+    in Gopkg.toml. It is shown by "dep status -detail".
+
+dep ensure -override -until=2024-06-01 github.com/pkg/foo@fix-leak
+
+    Record an [[override]] of a dependency in Gopkg.toml, here to the
+    fix-leak branch, and solve with it. With -until, the override expires on
+    the given date: it still applies afterwards, but ensure warns of it, and
+    "dep check" fails until it is renewed by running this again with a later
+    date, or removed, so that a temporary workaround cannot quietly become
+    permanent. Without -until, any expiry of an existing override is dropped.
+
 dep ensure -update github.com/pkg/foo github.com/pkg/bar
 
     Update a list of dependencies to the latest versions allowed by Gopkg.toml,
@@ -106,15 +123,97 @@ dep ensure -update
     changes. (NOTE: Not recommended. Updating one/some dependencies at a time is
     preferred.)
 
+dep ensure -update -update-strategy=minimize-changes github.com/pkg/foo
+
+    As above, but move each dependency the smallest step up from its locked
+    version that is allowed. With -update-strategy=security-only, only newer
+    patch releases of the locked major and minor version are tried first,
+    falling back to other versions if none can be used. The strategy is
+    recorded in the solve-meta section of Gopkg.lock.
+
+dep ensure -update -security
+
+    Update only the dependencies whose locked versions are affected by a known
+    advisory, each to the smallest newer version that is affected by none and
+    is allowed by Gopkg.toml. The advisories are read from the file or URL in
+    $DEPADVISORIES. Every other dependency keeps its version in Gopkg.lock.
+
+    Whenever -update would change the license of a dependency, as detected
+    from its license file, the change is reported and must be confirmed at a
+    prompt, or by passing -accept-license-changes. $DEPLICENSEPOLICY=fail
+    forbids such updates instead.
+
+dep ensure -update -force github.com/pkg/foo
+
+    Update a dependency listed in the freeze list of Gopkg.toml. Frozen
+    dependencies are otherwise kept at their versions in Gopkg.lock by every
+    form of ensure, and naming one for -update is an error.
+
+dep ensure -update -i
+
+    List the dependencies that can be updated within the constraints in
+    Gopkg.toml, with their current and newest allowed versions and, for
+    projects on GitHub or GitLab, a link to the changes between the two.
+    Then prompt for which of them to update, and update those together, as
+    if they had been named as arguments.
+
 dep ensure -update -no-vendor
 
     As above, but only modify Gopkg.lock; leave vendor/ unchanged.
 
+dep ensure -update -no-vendor -metadata-only
+
+    As above, but solve without cloning the sources of dependencies wherever
+    they can be read from their hosts instead: versions are listed with
+    ls-remote, manifests are fetched as single files, and the packages of
+    revisions not already cached are read from archives of their Go files.
+    GitHub, GitLab and Bitbucket serve these; projects elsewhere, or whose
+    files cannot be fetched, such as private ones, are cloned as usual. Without
+    -no-vendor, sources are still cloned to write vendor/, after solving.
+
 dep ensure -no-vendor -dry-run
 
-    This fails with a non zero exit code if Gopkg.lock is not up to date with
-    the Gopkg.toml or the project imports. It can be useful to run this during
-    CI to check if Gopkg.lock is up to date.
+    This fails with exit code 2 if Gopkg.lock is not up to date with the
+    Gopkg.toml or the project imports. It can be useful to run this during
+    CI to check if Gopkg.lock is up to date. Every form of ensure given
+    -dry-run, like init and prune, exits 0 if it would change nothing, 2 if
+    it would make changes, and 1 if it fails.
+
+dep ensure -frozen
+
+    Fail with a non zero exit code, listing every divergence found, if either
+    Gopkg.lock is not up to date with Gopkg.toml and the project imports, or
+    vendor/ does not match Gopkg.lock, by the same checks as dep check.
+    Nothing is solved or written; this is intended as a strict CI gate. Add
+    -no-vendor to check only Gopkg.lock.
+
+dep ensure -update -typecheck
+
+    Update all dependencies, then type-check the project's packages against
+    the new vendor/, failing with the compile errors if the chosen versions
+    do not build together. If they do not, Gopkg.lock and vendor/ are
+    restored to what they were before.
+
+dep ensure -estimate
+
+    Report, without solving or downloading anything, how much each project in
+    Gopkg.lock, and each direct dependency, would download into the cache,
+    then exit. Sources already cached download only what changed, and are
+    shown as cached. Sizes are only known for projects on hosts whose APIs
+    report them: GitHub, Bitbucket, and GitLab (for authorized users).
+
+dep ensure -max-download=500MB
+
+    As with a plain "dep ensure", but first estimate the download as above,
+    and fail before fetching anything if it would exceed the given size.
+    Projects of unknown size do not count towards the limit.
+
+dep ensure -vendor-only -hermetic=fail
+
+    Populate vendor/ from Gopkg.lock, but first fail if the versions of git,
+    hg, bzr or svn on PATH differ from those recorded in Gopkg.lock by more
+    than the tool-tolerance set in Gopkg.toml allows. Use -hermetic=warn to
+    only warn of them. The recorded versions are left as they are.
 
 `
 
@@ -125,7 +224,7 @@ var (
 
 func (cmd *ensureCommand) Name() string { return "ensure" }
 func (cmd *ensureCommand) Args() string {
-	return "[-update | -add] [-no-vendor | -vendor-only] [-dry-run] [-v] [<spec>...]"
+	return "[-update [-i] [-update-strategy=<strategy>] | -add [-reason=<reason>] | -override [-until=<date>] | -frozen] [-no-vendor | -vendor-only] [-metadata-only] [-dry-run] [-typecheck] [-estimate | -max-download=<size>] [-hermetic=<warn|fail>] [-v] [<spec>...]"
 }
 func (cmd *ensureCommand) ShortHelp() string { return ensureShortHelp }
 func (cmd *ensureCommand) LongHelp() string  { return ensureLongHelp }
@@ -134,19 +233,60 @@ func (cmd *ensureCommand) Hidden() bool      { return false }
 func (cmd *ensureCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.examples, "examples", false, "print detailed usage examples")
 	fs.BoolVar(&cmd.update, "update", false, "update the named dependencies (or all, if none are named) in Gopkg.lock to the latest allowed by Gopkg.toml")
+	fs.BoolVar(&cmd.interactive, "i", false, "with -update, list the available updates and choose which of them to apply")
+	fs.BoolVar(&cmd.security, "security", false, "with -update, only update the dependencies affected by the advisories in $DEPADVISORIES, each to the nearest unaffected version")
+	fs.BoolVar(&cmd.force, "force", false, "with -update, also update the dependencies frozen in Gopkg.toml")
+	fs.BoolVar(&cmd.acceptLicenseChanges, "accept-license-changes", false, "with -update, update dependencies even if their licenses change, without asking")
+	fs.StringVar(&cmd.updateStrategy, "update-strategy", "", "with -update, how to choose new versions: maximize-freshness (default), minimize-changes or security-only")
 	fs.BoolVar(&cmd.add, "add", false, "add new dependencies, or populate Gopkg.toml with constraints for existing dependencies")
+	fs.StringVar(&cmd.reason, "reason", "", "with -add, record why the dependencies are needed in the metadata of their constraints")
+	fs.BoolVar(&cmd.override, "override", false, "record [[override]]s in Gopkg.toml for the named dependencies, replacing any already there")
+	fs.StringVar(&cmd.until, "until", "", "with -override, the `date`, such as 2024-06-01, on which the overrides expire")
 	fs.BoolVar(&cmd.vendorOnly, "vendor-only", false, "populate vendor/ from Gopkg.lock without updating it first")
 	fs.BoolVar(&cmd.noVendor, "no-vendor", false, "update Gopkg.lock (if needed), but do not update vendor/")
+	fs.BoolVar(&cmd.metadataOnly, "metadata-only", false, "solve from metadata served by the hosts of dependencies, without cloning their sources where possible")
 	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+	fs.BoolVar(&cmd.frozen, "frozen", false, "fail, without writing anything, if Gopkg.lock or vendor/ would need to change")
+	fs.BoolVar(&cmd.typecheck, "typecheck", false, "after writing vendor/, type-check the project against it and fail if it does not compile")
+	fs.BoolVar(&cmd.parallel, "parallel", false, "solve groups of dependencies that share no projects concurrently")
+	fs.IntVar(&cmd.maxAttempts, "max-attempts", 0, "give up solving after this many attempts, reporting the best partial solution found (0 means no limit)")
+	fs.BoolVar(&cmd.estimate, "estimate", false, "report how much each project would download into a cold cache, then exit")
+	fs.StringVar(&cmd.maxDownload, "max-download", "", "fail before fetching if projects not in the cache would download more than this `size`, such as 500MB")
+	fs.StringVar(&cmd.hermetic, "hermetic", "", "warn, or fail, if the versions of git, hg or bzr differ from those recorded in Gopkg.lock by more than tool-tolerance allows")
+	cmd.perf.register(fs)
 }
 
 type ensureCommand struct {
-	examples   bool
-	update     bool
-	add        bool
-	noVendor   bool
-	vendorOnly bool
-	dryRun     bool
+	examples             bool
+	update               bool
+	force                bool
+	security             bool
+	updateStrategy       string
+	acceptLicenseChanges bool
+	add                  bool
+	reason               string
+	override             bool
+	until                string
+	untilDate            time.Time
+	noVendor             bool
+	vendorOnly           bool
+	metadataOnly         bool
+	dryRun               bool
+	frozen               bool
+	typecheck            bool
+	interactive          bool
+	maxAttempts          int
+	parallel             bool
+	estimate             bool
+	maxDownload          string
+	maxDownloadBytes     int64
+	hermetic             string
+	perf                 perfFlags
+
+	// input is read for the answers to ensure's prompts, instead of
+	// os.Stdin, through reader.
+	input  io.Reader
+	reader *bufio.Reader
 }
 
 func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -159,12 +299,24 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	stopPerf, err := cmd.perf.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer stopPerf()
+
+	if cmd.metadataOnly {
+		mctx := *ctx
+		mctx.MetadataOnly = true
+		ctx = &mctx
+	}
+
 	p, err := ctx.LoadProject()
 	if err != nil {
 		return err
 	}
 
-	sm, err := ctx.SourceManager()
+	sm, err := ctx.ProjectSourceManager(p)
 	if err != nil {
 		return err
 	}
@@ -179,9 +331,32 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 	if ctx.Verbose {
 		params.TraceLogger = ctx.Err
 	}
+	if cmd.force {
+		params.Frozen = nil
+	}
+	params.MaxAttempts = cmd.maxAttempts
+	params.Parallel = cmd.parallel
+
+	if err := ctx.CheckRevisionLedger(p.Lock); err != nil {
+		ctx.Err.Printf("Warning: failed to check the revision ledger: %v\n", err)
+	}
+
+	if cmd.hermetic != "" {
+		if err := cmd.checkHermetic(ctx, p); err != nil {
+			return err
+		}
+	}
+
+	if cmd.estimate || cmd.maxDownload != "" {
+		if err := cmd.checkDownload(ctx, p, sm); err != nil || cmd.estimate {
+			return err
+		}
+	}
 
 	if cmd.vendorOnly {
 		return cmd.runVendorOnly(ctx, args, p, sm, params)
+	} else if cmd.frozen {
+		return cmd.runFrozen(ctx, args, p, params)
 	}
 
 	if fatal, err := checkErrors(params.RootPackageTree.Packages, p.Manifest.IgnoredPackages()); err != nil {
@@ -210,12 +385,48 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 	// paths from here will need it, whether or not they end up solving.
 	go p.VerifyVendor()
 
+	if expired := expiredOverrides(p.Manifest, time.Now()); len(expired) > 0 {
+		ctx.Err.Printf("Warning: the following override(s) in %s have expired:\n\n", dep.ManifestName)
+		for _, e := range expired {
+			ctx.Err.Println("  ✗ ", e)
+		}
+		ctx.Err.Printf("\nThey still apply, but dep check fails until each is renewed with\n")
+		ctx.Err.Printf("\"dep ensure -override -until=<date>\", or removed.\n\n")
+	}
+
 	if cmd.add {
-		return cmd.runAdd(ctx, args, p, sm, params)
+		err = cmd.runAdd(ctx, args, p, sm, params)
+	} else if cmd.override {
+		err = cmd.runOverride(ctx, args, p, sm, params)
+	} else if cmd.update && cmd.interactive {
+		err = cmd.runInteractiveUpdate(ctx, args, p, sm, params)
 	} else if cmd.update {
-		return cmd.runUpdate(ctx, args, p, sm, params)
+		err = cmd.runUpdate(ctx, args, p, sm, params)
+	} else {
+		err = cmd.runDefault(ctx, args, p, sm, params)
+	}
+	if err == nil && !cmd.dryRun {
+		cmd.checkWrittenLock(ctx, p)
+	}
+	return err
+}
+
+// checkWrittenLock checks the lock written by ensure against the revision
+// ledger, so that versions newly locked are recorded in it, and a tag moved
+// since it was last locked is reported.
+func (cmd *ensureCommand) checkWrittenLock(ctx *dep.Ctx, p *dep.Project) {
+	f, err := os.Open(filepath.Join(p.AbsRoot, dep.LockName))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	l, err := dep.ReadLock(f)
+	if err == nil {
+		err = ctx.CheckRevisionLedger(l)
+	}
+	if err != nil {
+		ctx.Err.Printf("Warning: failed to check the revision ledger: %v\n", err)
 	}
-	return cmd.runDefault(ctx, args, p, sm, params)
 }
 
 func (cmd *ensureCommand) validateFlags() error {
@@ -223,6 +434,28 @@ func (cmd *ensureCommand) validateFlags() error {
 		return errors.New("cannot pass both -add and -update")
 	}
 
+	if cmd.reason != "" && !cmd.add {
+		return errors.New("-reason only applies to -add")
+	}
+
+	if cmd.override && (cmd.add || cmd.update || cmd.frozen || cmd.vendorOnly) {
+		return errors.New("-override solves with the overrides it records; cannot pass it with -add, -update, -frozen or -vendor-only")
+	}
+
+	if cmd.until != "" {
+		if !cmd.override {
+			return errors.New("-until only applies to -override")
+		}
+		t, err := time.Parse(dep.OverrideExpiryFormat, cmd.until)
+		if err != nil {
+			return errors.Errorf("-until must be a date such as 2024-06-01, not %q", cmd.until)
+		}
+		if t.Format(dep.OverrideExpiryFormat) <= time.Now().Format(dep.OverrideExpiryFormat) {
+			return errors.Errorf("-until %s has already passed", cmd.until)
+		}
+		cmd.untilDate = t
+	}
+
 	if cmd.vendorOnly {
 		if cmd.update {
 			return errors.New("-vendor-only makes -update a no-op; cannot pass them together")
@@ -234,10 +467,114 @@ func (cmd *ensureCommand) validateFlags() error {
 			// TODO(sdboyer) can't think of anything not snarky right now
 			return errors.New("really?")
 		}
+		if cmd.metadataOnly {
+			return errors.New("-vendor-only does not solve, so -metadata-only is a no-op; cannot pass them together")
+		}
+	}
+
+	if cmd.interactive && !cmd.update {
+		return errors.New("-i only applies to -update")
+	}
+
+	if cmd.force && !cmd.update {
+		return errors.New("-force only applies to -update")
+	}
+
+	if cmd.acceptLicenseChanges && !cmd.update {
+		return errors.New("-accept-license-changes only applies to -update")
+	}
+
+	if cmd.security {
+		if !cmd.update {
+			return errors.New("-security only applies to -update")
+		}
+		if cmd.interactive || cmd.updateStrategy != "" {
+			return errors.New("-security chooses the dependencies and versions to update to; cannot pass it with -i or -update-strategy")
+		}
+	}
+
+	if cmd.updateStrategy != "" {
+		if !cmd.update {
+			return errors.New("-update-strategy only applies to -update")
+		}
+		if _, err := gps.ParseUpdateStrategy(cmd.updateStrategy); err != nil {
+			return err
+		}
+	}
+
+	if cmd.maxAttempts < 0 {
+		return errors.New("-max-attempts may not be negative")
+	}
+
+	if cmd.typecheck {
+		if cmd.noVendor {
+			return errors.New("-typecheck checks the project against the new vendor/; cannot pass it with -no-vendor")
+		}
+		if cmd.dryRun || cmd.frozen {
+			return errors.New("-typecheck runs after vendor/ is written; cannot pass it with -dry-run or -frozen")
+		}
+	}
+
+	if cmd.frozen {
+		if cmd.add || cmd.update {
+			return errors.New("-frozen never changes Gopkg.lock; cannot pass it with -add or -update")
+		}
+		if cmd.vendorOnly {
+			return errors.New("-frozen checks vendor/ against Gopkg.lock; cannot pass it with -vendor-only")
+		}
+		if cmd.dryRun {
+			return errors.New("-frozen never writes anything, making -dry-run redundant; cannot pass them together")
+		}
+		if cmd.estimate || cmd.maxDownload != "" {
+			return errors.New("-frozen never downloads anything; cannot pass it with -estimate or -max-download")
+		}
+	}
+
+	if cmd.maxDownload != "" {
+		if cmd.estimate {
+			return errors.New("-estimate exits before downloading anything; cannot pass it with -max-download")
+		}
+		n, err := parseSize(cmd.maxDownload)
+		if err != nil {
+			return errors.Wrap(err, "-max-download")
+		}
+		cmd.maxDownloadBytes = n
+	}
+
+	switch cmd.hermetic {
+	case "", hermeticWarn, hermeticFail:
+	default:
+		return errors.Errorf("-hermetic must be %q or %q", hermeticWarn, hermeticFail)
 	}
 	return nil
 }
 
+// typeCheckedWriter returns a TreeWriter that, if -typecheck was passed,
+// type-checks p against its newly written vendor directory once tw has
+// written it. If the check fails, the previous Gopkg.lock and vendor/ are
+// restored, along with Gopkg.toml if tw rewrites it.
+func (cmd *ensureCommand) typeCheckedWriter(ctx *dep.Ctx, p *dep.Project, tw dep.TreeWriter) dep.TreeWriter {
+	if !cmd.typecheck {
+		return tw
+	}
+	return dep.NewCheckedWriter(tw, func() error {
+		if ctx.Verbose {
+			ctx.Err.Println("# Type-checking the project against vendor/")
+		}
+		return typeCheckProject(p)
+	})
+}
+
+// writeGoSum updates Gopkg.sum once Gopkg.lock and vendor/ have been written,
+// if the manifest sets go-sum. With -no-vendor it is left alone, as it would
+// no longer describe vendor/.
+func (cmd *ensureCommand) writeGoSum(p *dep.Project) error {
+	if cmd.noVendor {
+		return nil
+	}
+	return p.UpdateGoSum()
+}
+
 func (cmd *ensureCommand) vendorBehavior() dep.VendorBehavior {
 	if cmd.noVendor {
 		return dep.VendorNever
@@ -259,6 +596,9 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 	lock := p.ChangedLock
 	if lock != nil {
 		lsat := verify.LockSatisfiesInputs(p.Lock, p.Manifest, params.RootPackageTree)
+		if ctx.Verbose {
+			printLockSatisfactionWarnings(ctx.Out, lsat)
+		}
 		if !lsat.Satisfied() {
 			if ctx.Verbose {
 				ctx.Out.Println("# Gopkg.lock is out of sync with Gopkg.toml and project code:")
@@ -268,15 +608,28 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 				for _, excess := range lsat.ExcessImports {
 					ctx.Out.Printf("%s: in input-imports, but isn't imported\n", excess)
 				}
+				if lsat.StaleInputImports {
+					ctx.Out.Println("# The locked projects already provide all the imports; only input-imports is stale.")
+				}
 				for pr, unmatched := range lsat.UnmetOverrides {
 					ctx.Out.Printf("%s@%s: not allowed by override %s\n", pr, unmatched.V, unmatched.C)
 				}
 				for pr, unmatched := range lsat.UnmetConstraints {
 					ctx.Out.Printf("%s@%s: not allowed by constraint %s\n", pr, unmatched.V, unmatched.C)
 				}
+				if lsat.ChangedGoVersions != nil {
+					ctx.Out.Println(lsat.ChangedGoVersions)
+				}
+				if lsat.ChangedBuildContext != nil {
+					ctx.Out.Println(lsat.ChangedBuildContext)
+				}
 				ctx.Out.Println()
 			}
 			solve = true
+		} else if params.LockPreference != gps.PreferLocked {
+			// The lock is satisfied, but the manifest asks for newer versions
+			// of some of its projects to be preferred, so there may be some.
+			solve = true
 		} else if cmd.noVendor {
 			// The user said not to touch vendor/, so definitely nothing to do.
 			return nil
@@ -297,25 +650,123 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 		}
 		lock = dep.LockFromSolution(solution, p.Manifest.PruneOptions)
 	}
+	if solve || len(lock.SolveMeta.ToolVersions) == 0 {
+		if err := cmd.recordToolVersions(p, lock, sm); err != nil {
+			return err
+		}
+	}
 
 	status, err := p.VerifyVendor()
 	if err != nil {
 		return errors.Wrap(err, "error while verifying vendor directory")
 	}
-	dw, err := dep.NewDeltaWriter(p.Lock, lock, status, p.Manifest.PruneOptions, filepath.Join(p.AbsRoot, "vendor"), cmd.vendorBehavior())
+	printSkippedVerifications(ctx.Err, status)
+	dw, err := dep.NewDeltaWriter(p.Lock, lock, status, p.Manifest.PruneOptions, p.VendorDir(), cmd.vendorBehavior())
 	if err != nil {
 		return err
 	}
 
 	if cmd.dryRun {
-		return dw.PrintPreparedActions(ctx.Out, ctx.Verbose)
+		return printDryRun(ctx, dw)
 	}
 
 	var logger *log.Logger
 	if ctx.Verbose {
 		logger = ctx.Err
 	}
-	return errors.WithMessage(dw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor")
+	if err := cmd.typeCheckedWriter(ctx, p, dw).Write(p.AbsRoot, sm, true, logger); err != nil {
+		return errors.WithMessage(err, "grouped write of manifest, lock and vendor")
+	}
+	return cmd.writeGoSum(p)
+}
+
+func (cmd *ensureCommand) runFrozen(ctx *dep.Ctx, args []string, p *dep.Project, params gps.SolveParameters) error {
+	if len(args) != 0 {
+		return errors.New("dep ensure -frozen takes no spec arguments")
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists; -frozen requires one to check against", dep.LockName)
+	}
+
+	lsat := verify.LockSatisfiesInputs(p.Lock, p.Manifest, params.RootPackageTree)
+	printLockSatisfactionWarnings(ctx.Err, lsat)
+	divs := appendVerifyErrors(nil, lsat.Err())
+
+	// Prune options are carried over from the manifest without solving, so a
+	// change to them would rewrite the lock even if it is otherwise satisfied.
+	var pruneDivs []string
+	for pr, lpd := range verify.DiffLocks(p.Lock, p.ChangedLock).ProjectDeltas {
+		if lpd.PruneOptsChanged() {
+			pruneDivs = append(pruneDivs, fmt.Sprintf("%s: prune options in %s differ from %s", pr, dep.LockName, dep.ManifestName))
+		}
+	}
+	sort.Strings(pruneDivs)
+	divs = append(divs, pruneDivs...)
+
+	if !cmd.noVendor {
+		vc, err := checkVendor(ctx.Err, p)
+		if err != nil {
+			return err
+		}
+		divs = append(divs, vc.divergences()...)
+	}
+
+	if len(divs) == 0 {
+		if ctx.Verbose {
+			ctx.Out.Printf("%s and vendor/ are in sync with %s and project code\n", dep.LockName, dep.ManifestName)
+		}
+		return nil
+	}
+
+	ctx.Err.Printf("# %s or vendor/ is out of sync with %s and project code:\n", dep.LockName, dep.ManifestName)
+	for _, div := range divs {
+		ctx.Err.Println(div)
+	}
+	ctx.Err.Println()
+	return errors.Errorf("-frozen: found %d divergence(s); run dep ensure to resolve them", len(divs))
+}
+
+// appendVerifyErrors appends the message of each failure in err, which must
+// be nil or have been returned from the verify package, to divs.
+func appendVerifyErrors(divs []string, err error) []string {
+	if errs, ok := err.(verify.Errors); ok {
+		for _, err := range errs {
+			divs = append(divs, err.Error())
+		}
+	} else if err != nil {
+		divs = append(divs, err.Error())
+	}
+	return divs
+}
+
+// printLockSatisfactionWarnings reports the warnings, as opposed to errors,
+// found while checking the lock against its inputs. These never require the
+// lock to change, but hint that Gopkg.toml may not do what was intended.
+func printLockSatisfactionWarnings(logger *log.Logger, lsat verify.LockSatisfaction) {
+	for _, pr := range lsat.IgnoredProjects {
+		logger.Printf("Warning: %s: in %s, but all of its packages are ignored\n", pr, dep.LockName)
+	}
+	for _, pr := range lsat.OverriddenConstraints {
+		logger.Printf("Warning: %s: [[constraint]] has no effect, as there is an [[override]] for it\n", pr)
+	}
+}
+
+// printSkippedVerifications reports the projects whose vendored code does not
+// match their digests in the lock, but which are listed in the manifest's
+// noverify. They are reported every time, so that a hand-patch is not
+// forgotten.
+func printSkippedVerifications(logger *log.Logger, status map[string]verify.VendorStatus) {
+	var prs []string
+	for pr, stat := range status {
+		if stat == verify.DigestMismatchSkipped {
+			prs = append(prs, pr)
+		}
+	}
+	sort.Strings(prs)
+	for _, pr := range prs {
+		logger.Printf("Warning: %s: vendored code does not match the digest in %s; not verified, as it is listed in noverify\n", pr, dep.LockName)
+	}
 }
 
 func (cmd *ensureCommand) runVendorOnly(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -330,20 +781,34 @@ func (cmd *ensureCommand) runVendorOnly(ctx *dep.Ctx, args []string, p *dep.Proj
 	// Pass the same lock as old and new so that the writer will observe no
 	// difference, and write out only ncessary vendor/ changes.
 	dw, err := dep.NewSafeWriter(nil, p.Lock, p.Lock, dep.VendorAlways, p.Manifest.PruneOptions)
-	//dw, err := dep.NewDeltaWriter(p.Lock, p.Lock, p.Manifest.PruneOptions, filepath.Join(p.AbsRoot, "vendor"), dep.VendorAlways)
+	//dw, err := dep.NewDeltaWriter(p.Lock, p.Lock, p.Manifest.PruneOptions, p.VendorDir(), dep.VendorAlways)
 	if err != nil {
 		return err
 	}
+	dw.VendorDir = p.VendorDir()
 
 	if cmd.dryRun {
-		return dw.PrintPreparedActions(ctx.Out, ctx.Verbose)
+		// Projects already matching the lock in vendor/ would only be written
+		// out as they are.
+		status, err := p.VerifyVendor()
+		if err != nil {
+			return errors.Wrap(err, "error while verifying vendor directory")
+		}
+		dw, err := dep.NewDeltaWriter(p.Lock, p.Lock, status, p.Manifest.PruneOptions, p.VendorDir(), dep.VendorAlways)
+		if err != nil {
+			return err
+		}
+		return printDryRun(ctx, dw)
 	}
 
 	var logger *log.Logger
 	if ctx.Verbose {
 		logger = ctx.Err
 	}
-	return errors.WithMessage(dw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor")
+	if err := cmd.typeCheckedWriter(ctx, p, dw).Write(p.AbsRoot, sm, true, logger); err != nil {
+		return errors.WithMessage(err, "grouped write of manifest, lock and vendor")
+	}
+	return cmd.writeGoSum(p)
 }
 
 func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -355,14 +820,29 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		return err
 	}
 
-	// When -update is specified without args, allow every dependency to change
-	// versions, regardless of the lock file.
-	if len(args) == 0 {
-		params.ChangeAll = true
-	}
+	if cmd.security {
+		if len(args) != 0 {
+			return errors.New("-security chooses the dependencies to update; cannot pass it project arguments")
+		}
+		var needed bool
+		var err error
+		if params, needed, err = securityParams(ctx, p.Lock, params); err != nil || !needed {
+			return err
+		}
+	} else {
+		// When -update is specified without args, allow every dependency to
+		// change versions, regardless of the lock file.
+		if len(args) == 0 {
+			params.ChangeAll = true
+		}
+		if cmd.updateStrategy != "" {
+			// Already validated along with the other flags.
+			params.UpdateStrategy, _ = gps.ParseUpdateStrategy(cmd.updateStrategy)
+		}
 
-	if err := validateUpdateArgs(ctx, args, p, sm, &params); err != nil {
-		return err
+		if err := validateUpdateArgs(ctx, args, p, sm, &params); err != nil {
+			return err
+		}
 	}
 
 	// Re-prepare a solver now that our params are complete.
@@ -378,23 +858,35 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		return handleAllTheFailuresOfTheWorld(err)
 	}
 
+	newLock := dep.LockFromSolution(solution, p.Manifest.PruneOptions)
+	if err := cmd.recordToolVersions(p, newLock, sm); err != nil {
+		return err
+	}
+	if err := cmd.checkLicenseChanges(ctx, p.Lock, newLock, sm); err != nil {
+		return err
+	}
+
 	status, err := p.VerifyVendor()
 	if err != nil {
 		return errors.Wrap(err, "error while verifying vendor directory")
 	}
-	dw, err := dep.NewDeltaWriter(p.Lock, dep.LockFromSolution(solution, p.Manifest.PruneOptions), status, p.Manifest.PruneOptions, filepath.Join(p.AbsRoot, "vendor"), cmd.vendorBehavior())
+	printSkippedVerifications(ctx.Err, status)
+	dw, err := dep.NewDeltaWriter(p.Lock, newLock, status, p.Manifest.PruneOptions, p.VendorDir(), cmd.vendorBehavior())
 	if err != nil {
 		return err
 	}
 	if cmd.dryRun {
-		return dw.PrintPreparedActions(ctx.Out, ctx.Verbose)
+		return printDryRun(ctx, dw)
 	}
 
 	var logger *log.Logger
 	if ctx.Verbose {
 		logger = ctx.Err
 	}
-	return errors.Wrap(dw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest, lock and vendor")
+	if err := cmd.typeCheckedWriter(ctx, p, dw).Write(p.AbsRoot, sm, false, logger); err != nil {
+		return errors.Wrap(err, "grouped write of manifest, lock and vendor")
+	}
+	return cmd.writeGoSum(p)
 }
 
 func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -618,7 +1110,7 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 
 	// Prep post-actions and feedback from adds.
 	var reqlist []string
-	appender := dep.NewManifest()
+	added := dep.NewManifest()
 
 	for pr, instr := range addInstructions {
 		for path := range instr.ephReq {
@@ -646,46 +1138,56 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 			if !gps.IsAny(instr.constraint) {
 				pp.Constraint = instr.constraint
 			}
-			appender.Constraints[pr] = pp
+			added.Constraints[pr] = pp
+			if cmd.reason != "" {
+				added.ConstraintMetadata[pr] = map[string]string{dep.MetadataReason: cmd.reason}
+			}
 		}
 	}
 
-	extra, err := appender.MarshalTOML()
-	if err != nil {
-		return errors.Wrap(err, "could not marshal manifest into TOML")
-	}
 	sort.Strings(reqlist)
 
 	status, err := p.VerifyVendor()
 	if err != nil {
 		return errors.Wrap(err, "error while verifying vendor directory")
 	}
-	dw, err := dep.NewDeltaWriter(p.Lock, dep.LockFromSolution(solution, p.Manifest.PruneOptions), status, p.Manifest.PruneOptions, filepath.Join(p.AbsRoot, "vendor"), cmd.vendorBehavior())
+	printSkippedVerifications(ctx.Err, status)
+	newLock := dep.LockFromSolution(solution, p.Manifest.PruneOptions)
+	if err := cmd.recordToolVersions(p, newLock, sm); err != nil {
+		return err
+	}
+	dw, err := dep.NewDeltaWriter(p.Lock, newLock, status, p.Manifest.PruneOptions, p.VendorDir(), cmd.vendorBehavior())
+	if err != nil {
+		return err
+	}
+	// Stage the new rules alongside the lock and vendor changes, so that if
+	// any part of the write fails, all of them are rolled back together.
+	// FIXME(sdboyer) manifest writes ABSOLUTELY need verification - follow up!
+	dw, err = dep.NewManifestRewriter(dw, p.AbsRoot, func(m *dep.Manifest) {
+		for pr, pp := range added.Constraints {
+			m.Constraints[pr] = pp
+			if md, has := added.ConstraintMetadata[pr]; has {
+				m.ConstraintMetadata[pr] = md
+			}
+		}
+	})
 	if err != nil {
 		return err
 	}
 
 	if cmd.dryRun {
-		return dw.PrintPreparedActions(ctx.Out, ctx.Verbose)
+		return printDryRun(ctx, dw)
 	}
 
 	var logger *log.Logger
 	if ctx.Verbose {
 		logger = ctx.Err
 	}
-	if err := errors.Wrap(dw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor"); err != nil {
+	if err := errors.Wrap(cmd.typeCheckedWriter(ctx, p, dw).Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor"); err != nil {
 		return err
 	}
-
-	// FIXME(sdboyer) manifest writes ABSOLUTELY need verification - follow up!
-	f, err := os.OpenFile(filepath.Join(p.AbsRoot, dep.ManifestName), os.O_APPEND|os.O_WRONLY, 0666)
-	if err != nil {
-		return errors.Wrapf(err, "opening %s failed", dep.ManifestName)
-	}
-
-	if _, err := f.Write(extra); err != nil {
-		f.Close()
-		return errors.Wrapf(err, "writing to %s failed", dep.ManifestName)
+	if err := cmd.writeGoSum(p); err != nil {
+		return err
 	}
 
 	switch len(reqlist) {
@@ -711,7 +1213,7 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 		}
 	}
 
-	return errors.Wrapf(f.Close(), "closing %s", dep.ManifestName)
+	return nil
 }
 
 func getProjectConstraint(arg string, sm gps.SourceManager) (gps.ProjectConstraint, string, error) {
@@ -844,6 +1346,13 @@ func validateUpdateArgs(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.Sour
 				return
 			}
 
+			for _, pr := range params.Frozen {
+				if pr == pc.Ident.ProjectRoot {
+					errCh <- errors.Errorf("%s is frozen in %s, pass -force to -update it anyway", pr, dep.ManifestName)
+					return
+				}
+			}
+
 			if pc.Ident.Source != "" {
 				errCh <- errors.Errorf("cannot specify alternate sources on -update (%s)", pc.Ident.Source)
 				return
@@ -0,0 +1,175 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// projectDownload is the estimated download of a project's source.
+type projectDownload struct {
+	id  gps.ProjectIdentifier
+	est gps.DownloadEstimate
+	err error
+}
+
+// downloadProjects returns the identifiers of the projects whose sources an
+// ensure of p would retrieve, as far as can be told without solving: those
+// in the lock, and the direct dependencies.
+func downloadProjects(p *dep.Project, sm gps.SourceManager) ([]gps.ProjectIdentifier, error) {
+	seen := make(map[gps.ProjectRoot]bool)
+	var ids []gps.ProjectIdentifier
+	if p.Lock != nil {
+		for _, lp := range p.Lock.Projects() {
+			seen[lp.Ident().ProjectRoot] = true
+			ids = append(ids, lp.Ident())
+		}
+	}
+
+	direct, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not determine the direct dependencies")
+	}
+	for pr := range direct {
+		if seen[pr] {
+			continue
+		}
+		id := gps.ProjectIdentifier{ProjectRoot: pr, Source: p.Manifest.Constraints[pr].Source}
+		if pp, has := p.Manifest.Ovr[pr]; has {
+			id.Source = pp.Source
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+	return ids, nil
+}
+
+// estimateDownloads estimates the downloads of the sources of ids
+// concurrently.
+func estimateDownloads(sm *gps.SourceMgr, ids []gps.ProjectIdentifier) []projectDownload {
+	dls := make([]projectDownload, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id gps.ProjectIdentifier) {
+			defer wg.Done()
+			est, err := sm.EstimateDownload(context.TODO(), id)
+			dls[i] = projectDownload{id: id, est: est, err: err}
+		}(i, id)
+	}
+	wg.Wait()
+	return dls
+}
+
+// checkDownload estimates how much data the ensure will download into the
+// cache, reporting it per project with -estimate, and failing if it is more
+// than -max-download allows.
+func (cmd *ensureCommand) checkDownload(ctx *dep.Ctx, p *dep.Project, sm *gps.SourceMgr) error {
+	ids, err := downloadProjects(p, sm)
+	if err != nil {
+		return err
+	}
+	dls := estimateDownloads(sm, ids)
+
+	total := totalDownload(dls)
+	exceeded := cmd.maxDownloadBytes > 0 && total > cmd.maxDownloadBytes
+
+	if cmd.estimate {
+		ctx.Out.Print(formatDownloads(dls))
+	} else if exceeded || ctx.Verbose {
+		ctx.Err.Print(formatDownloads(dls))
+	}
+
+	if exceeded {
+		return errors.Errorf("the estimated download of %s is more than -max-download=%s allows; warm the cache, or raise the limit", formatSize(total), cmd.maxDownload)
+	}
+	return nil
+}
+
+// totalDownload returns the total of the known sizes of the sources in dls
+// that are not cached.
+func totalDownload(dls []projectDownload) int64 {
+	var total int64
+	for _, dl := range dls {
+		if dl.err == nil && !dl.est.Cached && dl.est.Bytes > 0 {
+			total += dl.est.Bytes
+		}
+	}
+	return total
+}
+
+// formatDownloads renders dls as a table, followed by their total.
+func formatDownloads(dls []projectDownload) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tSOURCE\tDOWNLOAD")
+	var unknown int
+	for _, dl := range dls {
+		if dl.err != nil || !dl.est.Cached && dl.est.Bytes < 0 {
+			unknown++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", dl.id.ProjectRoot, dl.est.URL, describeDownload(dl))
+	}
+	w.Flush()
+
+	fmt.Fprintf(&buf, "\nEstimated download: %s", formatSize(totalDownload(dls)))
+	if unknown > 0 {
+		fmt.Fprintf(&buf, ", plus %d project(s) of unknown size", unknown)
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// describeDownload describes the estimated download of dl for the table of
+// estimates.
+func describeDownload(dl projectDownload) string {
+	switch {
+	case dl.err != nil:
+		return fmt.Sprintf("unknown (%s)", dl.err)
+	case dl.est.Cached:
+		return "cached"
+	case dl.est.Bytes < 0:
+		return "unknown"
+	}
+	return formatSize(dl.est.Bytes)
+}
+
+// parseSize parses a size in bytes, such as "500MB" or "1.5GiB". Units of KB,
+// MB and GB are decimal, and KiB, MiB and GiB binary; a bare number is a
+// number of bytes.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+		{"B", 1},
+	}
+	num, mult := strings.TrimSpace(s), 1.0
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(num), strings.ToUpper(u.suffix)) {
+			num, mult = strings.TrimSpace(num[:len(num)-len(u.suffix)]), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("invalid size %q; give a number of bytes, optionally with a unit such as MB or GiB", s)
+	}
+	return int64(n * mult), nil
+}
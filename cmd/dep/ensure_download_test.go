@@ -0,0 +1,84 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"1024":     1024,
+		"500MB":    500e6,
+		"500 mb":   500e6,
+		"1.5GiB":   3 << 29,
+		"64KiB":    64 << 10,
+		"2kb":      2000,
+		"10B":      10,
+		" 1 GB ":   1e9,
+		"0":        0,
+		"0.5 KiB":  512,
+		"1.25 MiB": 1310720,
+	}
+	for s, want := range cases {
+		got, err := parseSize(s)
+		if err != nil {
+			t.Errorf("parseSize(%q): unexpected error: %v", s, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseSize(%q) = %d, want %d", s, got, want)
+		}
+	}
+
+	for _, s := range []string{"", "MB", "-1MB", "ten", "5TB"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize(%q): expected an error", s)
+		}
+	}
+}
+
+func TestFormatDownloads(t *testing.T) {
+	dls := []projectDownload{
+		{
+			id:  gps.ProjectIdentifier{ProjectRoot: "github.com/foo/big"},
+			est: gps.DownloadEstimate{URL: "https://github.com/foo/big", Bytes: 3 << 20},
+		},
+		{
+			id:  gps.ProjectIdentifier{ProjectRoot: "github.com/foo/cached"},
+			est: gps.DownloadEstimate{URL: "https://github.com/foo/cached", Cached: true},
+		},
+		{
+			id:  gps.ProjectIdentifier{ProjectRoot: "example.com/bar"},
+			est: gps.DownloadEstimate{URL: "https://example.com/bar", Bytes: -1},
+		},
+		{
+			id:  gps.ProjectIdentifier{ProjectRoot: "github.com/foo/broken"},
+			err: errors.New("no such host"),
+		},
+	}
+
+	if got, want := totalDownload(dls), int64(3<<20); got != want {
+		t.Errorf("expected a total of %d, got %d", want, got)
+	}
+
+	out := formatDownloads(dls)
+	for _, want := range []string{
+		"PROJECT",
+		"github.com/foo/big",
+		"3.0 MiB",
+		"cached",
+		"unknown (no such host)",
+		"Estimated download: 3.0 MiB, plus 2 project(s) of unknown size",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the estimates, got:\n%s", want, out)
+		}
+	}
+}
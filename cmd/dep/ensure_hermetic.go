@@ -0,0 +1,72 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// The values of -hermetic.
+const (
+	hermeticWarn = "warn"
+	hermeticFail = "fail"
+)
+
+// checkHermetic compares the versions of the version control tools on PATH
+// with those recorded in p's lock, warning of any that drift by more than the
+// manifest's tool-tolerance, or failing, before anything is written, with
+// -hermetic=fail.
+func (cmd *ensureCommand) checkHermetic(ctx *dep.Ctx, p *dep.Project) error {
+	if p.Lock == nil || len(p.Lock.SolveMeta.ToolVersions) == 0 {
+		msg := "%s records no tool versions to check against"
+		if p.Manifest.ToolTolerance == "" {
+			msg += "; set tool-tolerance in " + dep.ManifestName + " to record them"
+		}
+		if cmd.hermetic == hermeticFail {
+			return errors.Errorf(msg, dep.LockName)
+		}
+		ctx.Err.Printf("Warning: "+msg+"\n", dep.LockName)
+		return nil
+	}
+
+	drift := dep.CheckToolVersions(p.Lock, p.Manifest.ToolTolerance)
+	if len(drift) == 0 {
+		if ctx.Verbose {
+			ctx.Err.Printf("The version control tools match those recorded in %s.\n", dep.LockName)
+		}
+		return nil
+	}
+
+	tolerance := p.Manifest.ToolTolerance
+	if tolerance == "" {
+		tolerance = dep.ToolTolerateNone
+	}
+	ctx.Err.Printf("The following version control tools differ from %s by more than a tool-tolerance of %q allows:\n\n", dep.LockName, tolerance)
+	for _, d := range drift {
+		ctx.Err.Println("  ✗ ", d)
+	}
+	ctx.Err.Println()
+	if cmd.hermetic == hermeticFail {
+		return errors.Errorf("%d version control tool(s) differ from %s", len(drift), dep.LockName)
+	}
+	return nil
+}
+
+// recordToolVersions records the versions of the version control tools in
+// lock, if the manifest sets tool-tolerance. With -hermetic, the versions
+// already in p's lock are kept, so that drift goes on being reported until a
+// dep ensure without it records the new ones.
+func (cmd *ensureCommand) recordToolVersions(p *dep.Project, lock *dep.Lock, sm gps.SourceManager) error {
+	if p.Manifest.ToolTolerance == "" {
+		return nil
+	}
+	if cmd.hermetic != "" && p.Lock != nil && len(p.Lock.SolveMeta.ToolVersions) > 0 {
+		lock.SolveMeta.ToolVersions = p.Lock.SolveMeta.ToolVersions
+		return nil
+	}
+	return dep.RecordToolVersions(lock, sm)
+}
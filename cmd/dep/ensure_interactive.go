@@ -0,0 +1,184 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// updateCandidate is a locked project that -update could move to a new
+// version, as offered by -i.
+type updateCandidate struct {
+	ident             gps.ProjectIdentifier
+	locked, candidate gps.Version
+}
+
+// runInteractiveUpdate lists the dependencies that -update would change, asks
+// which of them to update, and then updates those in a single solve, as if
+// they had been named on the command line.
+func (cmd *ensureCommand) runInteractiveUpdate(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
+	if len(args) != 0 {
+		return errors.New("-i prompts for the dependencies to update; cannot pass it project arguments")
+	}
+	if p.Lock == nil {
+		return errors.Errorf("-update works by updating the versions recorded in %s, but %s does not exist", dep.LockName, dep.LockName)
+	}
+
+	if err := ctx.ValidateParams(sm, params); err != nil {
+		return err
+	}
+
+	probe := params
+	probe.ChangeAll = true
+	if cmd.updateStrategy != "" {
+		// Already validated along with the other flags.
+		probe.UpdateStrategy, _ = gps.ParseUpdateStrategy(cmd.updateStrategy)
+	}
+	solver, err := gps.Prepare(probe, sm)
+	if err != nil {
+		return errors.Wrap(err, "fastpath solver prepare")
+	}
+	ctx.Err.Println("Solving dependency graph to determine which dependencies can be updated.")
+	solution, err := solver.Solve(context.TODO())
+	if err != nil {
+		return handleAllTheFailuresOfTheWorld(err)
+	}
+
+	candidates := findUpdateCandidates(p.Lock, solution)
+	if len(candidates) == 0 {
+		ctx.Out.Println("All dependencies are already at the latest versions allowed by Gopkg.toml.")
+		return nil
+	}
+
+	for i, c := range candidates {
+		line := strconv.Itoa(i+1) + ") " + string(c.ident.ProjectRoot) + ": " + feedVersion(c.locked) + " -> " + feedVersion(c.candidate)
+		if isBreakingUpdate(c.locked, c.candidate) {
+			line += " (likely breaking)"
+		}
+		ctx.Out.Println(line)
+		if url := changelogURL(c.ident, compareRef(c.locked), compareRef(c.candidate)); url != "" {
+			ctx.Out.Println("     " + url)
+		}
+	}
+
+	selected, err := promptUpdateSelection(ctx, cmd.inputReader(), len(candidates))
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		ctx.Out.Println("No dependencies selected; nothing to do.")
+		return nil
+	}
+
+	roots := make([]string, len(selected))
+	for i, n := range selected {
+		roots[i] = string(candidates[n].ident.ProjectRoot)
+	}
+	return cmd.runUpdate(ctx, roots, p, sm, params)
+}
+
+// findUpdateCandidates returns the projects in l that are at a different
+// revision in solution, in the order of l.
+func findUpdateCandidates(l *dep.Lock, solution gps.Solution) []updateCandidate {
+	solved := make(map[gps.ProjectRoot]gps.LockedProject)
+	for _, sp := range solution.Projects() {
+		solved[sp.Ident().ProjectRoot] = sp
+	}
+
+	var candidates []updateCandidate
+	for _, lp := range l.Projects() {
+		sp, has := solved[lp.Ident().ProjectRoot]
+		if !has {
+			continue
+		}
+		lockedRev, _, _ := gps.VersionComponentStrings(lp.Version())
+		solvedRev, _, _ := gps.VersionComponentStrings(sp.Version())
+		if lockedRev == solvedRev {
+			continue
+		}
+		candidates = append(candidates, updateCandidate{
+			ident:     lp.Ident(),
+			locked:    lp.Version(),
+			candidate: sp.Version(),
+		})
+	}
+	return candidates
+}
+
+// promptUpdateSelection asks which of the n listed updates to apply until it
+// gets a valid answer, returning their indexes. Reaching the end of r selects
+// none of them.
+func promptUpdateSelection(ctx *dep.Ctx, r *bufio.Reader, n int) ([]int, error) {
+	for {
+		ctx.Out.Println("Updates to apply (such as \"1 3-4\", \"all\" or \"none\"):")
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, errors.Wrap(err, "failed to read the selected updates")
+		}
+		selected, perr := parseUpdateSelection(line, n)
+		if perr == nil {
+			return selected, nil
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		ctx.Err.Println(perr)
+	}
+}
+
+// parseUpdateSelection parses an answer to promptUpdateSelection, a list of
+// numbers from 1 to n and ranges of them, or "all" or "none". The indexes of
+// the selected updates are returned in order, without duplicates.
+func parseUpdateSelection(s string, n int) ([]int, error) {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+	if len(fields) == 1 {
+		switch fields[0] {
+		case "all", "a":
+			fields = []string{"1-" + strconv.Itoa(n)}
+		case "none", "n":
+			return nil, nil
+		}
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("no updates given; answer \"none\" to apply none of them")
+	}
+
+	picked := make([]bool, n)
+	for _, f := range fields {
+		lo, hi := f, f
+		if i := strings.Index(f, "-"); i > 0 {
+			lo, hi = f[:i], f[i+1:]
+		}
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, errors.Errorf("%q is not a number or range of the listed updates", f)
+		}
+		to, err := strconv.Atoi(hi)
+		if err != nil || from < 1 || to > n || from > to {
+			return nil, errors.Errorf("%q is not a number or range of the listed updates, from 1 to %d", f, n)
+		}
+		for i := from; i <= to; i++ {
+			picked[i-1] = true
+		}
+	}
+
+	var selected []int
+	for i, p := range picked {
+		if p {
+			selected = append(selected, i)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+)
+
+func TestParseUpdateSelection(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{in: "all\n", want: []int{0, 1, 2, 3}},
+		{in: "none\n", want: nil},
+		{in: "2\n", want: []int{1}},
+		{in: "4, 1 2-3 3\n", want: []int{0, 1, 2, 3}},
+		{in: "\n", wantErr: true},
+		{in: "5\n", wantErr: true},
+		{in: "0-2\n", wantErr: true},
+		{in: "3-2\n", wantErr: true},
+		{in: "foo\n", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := parseUpdateSelection(c.in, 4)
+		if (err != nil) != c.wantErr {
+			t.Errorf("parseUpdateSelection(%q): unexpected error state: %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseUpdateSelection(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestPromptUpdateSelection(t *testing.T) {
+	var stderr bytes.Buffer
+	ctx := &dep.Ctx{
+		Out: log.New(ioutil.Discard, "", 0),
+		Err: log.New(&stderr, "", 0),
+	}
+
+	// Invalid answers are reported, and asked again.
+	got, err := promptUpdateSelection(ctx, bufio.NewReader(strings.NewReader("7\n1 3\n")), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v to be selected, got %v", want, got)
+	}
+	if !strings.Contains(stderr.String(), `"7"`) {
+		t.Errorf("expected the invalid answer to be reported, got %q", stderr.String())
+	}
+
+	// Running out of input selects nothing.
+	got, err = promptUpdateSelection(ctx, bufio.NewReader(strings.NewReader("")), 3)
+	if err != nil || got != nil {
+		t.Errorf("expected nothing to be selected at EOF, got %v, %v", got, err)
+	}
+}
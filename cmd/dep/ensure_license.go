@@ -0,0 +1,121 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// The values of $DEPLICENSEPOLICY, which say how ensure -update treats an
+// update that changes the license of a dependency.
+const (
+	// licensePolicyConfirm asks for the changes to be confirmed, at a prompt
+	// or with -accept-license-changes. It is the default.
+	licensePolicyConfirm = "confirm"
+	// licensePolicyFail fails the update.
+	licensePolicyFail = "fail"
+	// licensePolicyOff skips checking licenses.
+	licensePolicyOff = "off"
+)
+
+// licensePolicyFromEnv returns the license policy set by $DEPLICENSEPOLICY.
+func licensePolicyFromEnv(env []string) (string, error) {
+	switch v := getEnv(env, "DEPLICENSEPOLICY"); v {
+	case "":
+		return licensePolicyConfirm, nil
+	case licensePolicyConfirm, licensePolicyFail, licensePolicyOff:
+		return v, nil
+	default:
+		return "", errors.Errorf("invalid $DEPLICENSEPOLICY %q; must be one of %s, %s or %s", v, licensePolicyConfirm, licensePolicyFail, licensePolicyOff)
+	}
+}
+
+// inputReader returns the reader of the answers to ensure's prompts, which is
+// shared by all of them so that no input buffered by one is lost to the next.
+func (cmd *ensureCommand) inputReader() *bufio.Reader {
+	if cmd.reader == nil {
+		in := cmd.input
+		if in == nil {
+			in = os.Stdin
+		}
+		cmd.reader = bufio.NewReader(in)
+	}
+	return cmd.reader
+}
+
+// interactiveInput reports whether ensure's prompts can be answered: whether
+// its input was replaced, or stdin is a terminal.
+func (cmd *ensureCommand) interactiveInput() bool {
+	if cmd.input != nil {
+		return true
+	}
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// checkLicenseChanges reports the dependencies whose license changes between
+// oldLock and newLock, and returns an error unless ctx.LicensePolicy allows
+// the changes: with the confirm policy, they must be accepted by
+// -accept-license-changes, or at a prompt. With -dry-run, the changes are
+// only reported.
+func (cmd *ensureCommand) checkLicenseChanges(ctx *dep.Ctx, oldLock, newLock *dep.Lock, sm gps.SourceManager) error {
+	if ctx.LicensePolicy == licensePolicyOff {
+		return nil
+	}
+
+	changes, err := dep.LicenseChanges(oldLock, newLock, sm)
+	if err != nil {
+		return errors.Wrap(err, "failed to compare the licenses of the updated dependencies")
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	ctx.Err.Println("The licenses of the following dependencies change with this update:")
+	for _, c := range changes {
+		ctx.Err.Printf("  %s: %s (%s) -> %s (%s)\n", c.ProjectRoot, c.BeforeLicense, feedVersion(c.Before), c.AfterLicense, feedVersion(c.After))
+	}
+	switch {
+	case cmd.dryRun:
+		return nil
+	case ctx.LicensePolicy == licensePolicyFail:
+		return errors.Errorf("$DEPLICENSEPOLICY forbids updates that change the licenses of dependencies; %d would change", len(changes))
+	case cmd.acceptLicenseChanges:
+		return nil
+	case !cmd.interactiveInput():
+		return errors.Errorf("the licenses of %d dependencies would change; pass -accept-license-changes to update them anyway", len(changes))
+	}
+
+	ok, err := promptYesNo(ctx, cmd.inputReader(), "Update them anyway? [y/N]")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("update cancelled, as the licenses of dependencies would change")
+	}
+	return nil
+}
+
+// promptYesNo asks question, and reports whether the answer from r was yes.
+// Any answer other than y or yes, including none, is taken as no.
+func promptYesNo(ctx *dep.Ctx, r *bufio.Reader, question string) (bool, error) {
+	ctx.Out.Println(question)
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.Wrap(err, "failed to read the answer")
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+// relicensingSM serves revision "old" of any project under the MIT license,
+// and any other under the AGPL.
+type relicensingSM struct {
+	gps.SourceManager
+}
+
+func (sm relicensingSM) ExportProject(ctx context.Context, id gps.ProjectIdentifier, v gps.Version, to string) error {
+	text := "GNU AFFERO GENERAL PUBLIC LICENSE Version 3"
+	if v.(gps.PairedVersion).Revision() == "old" {
+		text = "Permission is hereby granted, free of charge, to any person"
+	}
+	if err := os.MkdirAll(to, 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(to, "LICENSE"), []byte(text), 0666)
+}
+
+func TestCheckLicenseChanges(t *testing.T) {
+	pi := gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}
+	oldLock := &dep.Lock{P: []gps.LockedProject{gps.NewLockedProject(pi, gps.NewVersion("v1.0.0").Pair("old"), nil)}}
+	newLock := &dep.Lock{P: []gps.LockedProject{gps.NewLockedProject(pi, gps.NewVersion("v2.0.0").Pair("new"), nil)}}
+
+	for _, c := range []struct {
+		name   string
+		policy string
+		cmd    ensureCommand
+		ok     bool
+	}{
+		{"confirmed", licensePolicyConfirm, ensureCommand{input: strings.NewReader("y\n")}, true},
+		{"declined", licensePolicyConfirm, ensureCommand{input: strings.NewReader("\n")}, false},
+		{"accepted", licensePolicyConfirm, ensureCommand{acceptLicenseChanges: true}, true},
+		{"dry run", licensePolicyConfirm, ensureCommand{dryRun: true}, true},
+		{"forbidden", licensePolicyFail, ensureCommand{acceptLicenseChanges: true}, false},
+	} {
+		ctx := &dep.Ctx{
+			Out:           log.New(ioutil.Discard, "", 0),
+			Err:           log.New(ioutil.Discard, "", 0),
+			LicensePolicy: c.policy,
+		}
+		err := c.cmd.checkLicenseChanges(ctx, oldLock, newLock, relicensingSM{})
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+
+	// With checking off, no project is exported; the embedded nil
+	// SourceManager would panic if one were.
+	ctx := &dep.Ctx{LicensePolicy: licensePolicyOff}
+	cmd := &ensureCommand{}
+	if err := cmd.checkLicenseChanges(ctx, oldLock, newLock, relicensingSM{}); err != nil {
+		t.Errorf("expected no error with checking off, got %v", err)
+	}
+}
+
+func TestLicensePolicyFromEnv(t *testing.T) {
+	if p, err := licensePolicyFromEnv(nil); err != nil || p != licensePolicyConfirm {
+		t.Errorf("expected the default policy to be %s, got %s, %v", licensePolicyConfirm, p, err)
+	}
+	if p, err := licensePolicyFromEnv([]string{"DEPLICENSEPOLICY=fail"}); err != nil || p != licensePolicyFail {
+		t.Errorf("expected the policy to be %s, got %s, %v", licensePolicyFail, p, err)
+	}
+	if _, err := licensePolicyFromEnv([]string{"DEPLICENSEPOLICY=strict"}); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// runOverride records the specs in args as [[override]]s in the manifest,
+// replacing any already there, then solves with them. With -until, the
+// overrides expire on the date given, after which check fails until they are
+// renewed or removed.
+func (cmd *ensureCommand) runOverride(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
+	if len(args) == 0 {
+		return errors.New("must specify at least one project to override with -override")
+	}
+
+	if err := ctx.ValidateParams(sm, params); err != nil {
+		return err
+	}
+
+	rm := *p.Manifest
+	rm.Ovr = make(gps.ProjectConstraints, len(p.Manifest.Ovr)+len(args))
+	for pr, pp := range p.Manifest.Ovr {
+		rm.Ovr[pr] = pp
+	}
+	ovr := make(gps.ProjectConstraints, len(args))
+	for _, arg := range args {
+		pc, path, err := getProjectConstraint(arg, sm)
+		if err != nil {
+			return err
+		}
+		pr := pc.Ident.ProjectRoot
+		if gps.IsAny(pc.Constraint) && pc.Ident.Source == "" {
+			return errors.Errorf("nothing to override %s with; give a version, a source, or both, as in %s@<version>", path, pr)
+		}
+		if _, has := ovr[pr]; has {
+			return errors.Errorf("can only specify rules once per project being overridden; rules were given at least twice for %s", pr)
+		}
+		ovr[pr] = gps.ProjectProperties{Source: pc.Ident.Source, Constraint: pc.Constraint}
+		rm.Ovr[pr] = ovr[pr]
+	}
+	params.Manifest = &rm
+
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return errors.Wrap(err, "prepare solver")
+	}
+	solution, err := solver.Solve(context.TODO())
+	if err != nil {
+		return handleAllTheFailuresOfTheWorld(err)
+	}
+
+	status, err := p.VerifyVendor()
+	if err != nil {
+		return errors.Wrap(err, "error while verifying vendor directory")
+	}
+	printSkippedVerifications(ctx.Err, status)
+	newLock := dep.LockFromSolution(solution, p.Manifest.PruneOptions)
+	if err := cmd.recordToolVersions(p, newLock, sm); err != nil {
+		return err
+	}
+	dw, err := dep.NewDeltaWriter(p.Lock, newLock, status, p.Manifest.PruneOptions, p.VendorDir(), cmd.vendorBehavior())
+	if err != nil {
+		return err
+	}
+	dw, err = dep.NewManifestRewriter(dw, p.AbsRoot, func(m *dep.Manifest) {
+		for pr, pp := range ovr {
+			m.Ovr[pr] = pp
+			if cmd.untilDate.IsZero() {
+				delete(m.OverrideExpiry, pr)
+				continue
+			}
+			if m.OverrideExpiry == nil {
+				m.OverrideExpiry = make(map[gps.ProjectRoot]time.Time)
+			}
+			m.OverrideExpiry[pr] = cmd.untilDate
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmd.dryRun {
+		return printDryRun(ctx, dw)
+	}
+
+	var logger *log.Logger
+	if ctx.Verbose {
+		logger = ctx.Err
+	}
+	if err := errors.Wrap(cmd.typeCheckedWriter(ctx, p, dw).Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor"); err != nil {
+		return err
+	}
+	return cmd.writeGoSum(p)
+}
+
+// expiredOverrides describes each override in m that has expired as of now.
+func expiredOverrides(m *dep.Manifest, now time.Time) []string {
+	var expired []string
+	for _, pr := range m.ExpiredOverrides(now) {
+		expired = append(expired, fmt.Sprintf("%s: override expired on %s", pr, m.OverrideExpiry[pr].Format(dep.OverrideExpiryFormat)))
+	}
+	return expired
+}
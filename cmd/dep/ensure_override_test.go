@@ -0,0 +1,32 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestExpiredOverrides(t *testing.T) {
+	m := dep.NewManifest()
+	m.Ovr["github.com/foo/bar"] = gps.ProjectProperties{Constraint: gps.NewBranch("fix-leak")}
+	m.Ovr["github.com/foo/baz"] = gps.ProjectProperties{Source: "github.com/fork/baz", Constraint: gps.Any()}
+	m.OverrideExpiry = map[gps.ProjectRoot]time.Time{
+		"github.com/foo/bar": time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		"github.com/foo/baz": time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC),
+		// An expiry left behind by an override since removed is ignored.
+		"github.com/foo/qux": time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	got := expiredOverrides(m, time.Date(2024, time.July, 15, 12, 0, 0, 0, time.UTC))
+	want := []string{"github.com/foo/bar: override expired on 2024-06-01"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected expired overrides:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// securityParams sets up params for -security: only the locked projects
+// affected by a known advisory are changed, each to the smallest newer version
+// that is affected by none and satisfies the constraints. Every other project
+// keeps its locked version. It reports false if no project needs to change.
+func securityParams(ctx *dep.Ctx, l *dep.Lock, params gps.SolveParameters) (gps.SolveParameters, bool, error) {
+	if ctx.Advisories == "" {
+		return params, false, errors.New("-security needs a source of advisories; set $DEPADVISORIES to the path or URL of one")
+	}
+	advisories, err := dep.LoadAdvisories(context.TODO(), ctx.Advisories, ctx.NetworkPolicy)
+	if err != nil {
+		return params, false, err
+	}
+
+	frozen := make(map[gps.ProjectRoot]bool, len(params.Frozen))
+	for _, pr := range params.Frozen {
+		frozen[pr] = true
+	}
+
+	var affected bool
+	params.Exclude = advisories.Exclusions()
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		affecting := advisories.Affecting(lp)
+		if len(affecting) == 0 {
+			continue
+		}
+		affected = true
+
+		for _, a := range affecting {
+			ctx.Out.Printf("%s@%s is affected by %s: %s\n", pr, feedVersion(lp.Version()), a.ID, a.Summary)
+		}
+		if frozen[pr] {
+			// Excluding the locked version would only make solving fail.
+			ctx.Err.Printf("Warning: %s is frozen in %s, so it is not updated; pass -force to update it anyway\n", pr, dep.ManifestName)
+			delete(params.Exclude, pr)
+			continue
+		}
+		params.ToChange = append(params.ToChange, pr)
+	}
+
+	if !affected {
+		ctx.Out.Println("No locked dependencies are affected by known advisories.")
+	}
+	if len(params.ToChange) == 0 {
+		return params, false, nil
+	}
+
+	params.ChangeAll = false
+	params.LockPreference = gps.PreferLocked
+	params.UpdateStrategy = gps.MinimizeChanges
+	return params, true, nil
+}
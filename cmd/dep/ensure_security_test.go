@@ -0,0 +1,100 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestSecurityParams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-advisories")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	advisories := filepath.Join(dir, "advisories.toml")
+	err = ioutil.WriteFile(advisories, []byte(`
+[[advisory]]
+  id = "GHSA-0001"
+  name = "github.com/foo/vulnerable"
+  affected = "<1.2.5"
+  summary = "Path traversal"
+
+[[advisory]]
+  id = "GHSA-0002"
+  name = "github.com/foo/frozen"
+  affected = "<2.0.0"
+  summary = "Panic on empty input"
+`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/vulnerable"}, gps.NewVersion("1.2.3").Pair("abc123"), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/frozen"}, gps.NewVersion("1.0.0").Pair("def456"), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/fine"}, gps.NewVersion("1.0.0").Pair("0123ab"), nil),
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	ctx := &dep.Ctx{
+		Out: log.New(&stdout, "", 0),
+		Err: log.New(&stderr, "", 0),
+	}
+	if _, _, err := securityParams(ctx, lock, gps.SolveParameters{}); err == nil {
+		t.Error("expected an error without a source of advisories")
+	}
+
+	ctx.Advisories = advisories
+	params, needed, err := securityParams(ctx, lock, gps.SolveParameters{
+		ChangeAll:      true,
+		LockPreference: gps.PreferNewest,
+		Frozen:         []gps.ProjectRoot{"github.com/foo/frozen"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !needed {
+		t.Fatal("expected an update to be needed")
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/vulnerable"}; !reflect.DeepEqual(params.ToChange, want) {
+		t.Errorf("expected to change %v, got %v", want, params.ToChange)
+	}
+	if params.ChangeAll || params.LockPreference != gps.PreferLocked || params.UpdateStrategy != gps.MinimizeChanges {
+		t.Errorf("expected only the affected projects to move, by the smallest step, got %+v", params)
+	}
+	if _, has := params.Exclude["github.com/foo/frozen"]; has || len(params.Exclude["github.com/foo/vulnerable"]) != 1 {
+		t.Errorf("expected only the affected versions of github.com/foo/vulnerable to be excluded, got %v", params.Exclude)
+	}
+	if !strings.Contains(stdout.String(), "github.com/foo/vulnerable@1.2.3 is affected by GHSA-0001") {
+		t.Errorf("expected the advisory to be reported, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "github.com/foo/frozen is frozen") {
+		t.Errorf("expected the frozen project to be warned about, got %q", stderr.String())
+	}
+
+	// Nothing to do once the affected project has been updated.
+	stdout.Reset()
+	lock.P = lock.P[2:]
+	if _, needed, err := securityParams(ctx, lock, gps.SolveParameters{}); err != nil || needed {
+		t.Errorf("expected no update to be needed, got %v, %v", needed, err)
+	}
+	if !strings.Contains(stdout.String(), "No locked dependencies are affected") {
+		t.Errorf("expected to be told nothing is affected, got %q", stdout.String())
+	}
+}
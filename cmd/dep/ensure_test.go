@@ -45,6 +45,91 @@ func TestInvalidEnsureFlagCombinations(t *testing.T) {
 	}
 	ec.noVendor = false
 
+	ec.frozen, ec.vendorOnly = true, false
+	for _, other := range []*bool{&ec.add, &ec.update, &ec.vendorOnly, &ec.dryRun} {
+		*other = true
+		if err := ec.validateFlags(); err == nil {
+			t.Error("-frozen with -add, -update, -vendor-only or -dry-run should fail validation")
+		}
+		*other = false
+	}
+	ec.noVendor = true
+	if err := ec.validateFlags(); err != nil {
+		t.Errorf("-frozen with -no-vendor should pass validation, got %s", err)
+	}
+	ec.frozen, ec.noVendor = false, false
+
+	ec.updateStrategy = "minimize-changes"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-update-strategy without -update should fail validation")
+	}
+	ec.update, ec.updateStrategy = true, "newest"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-update with an unknown -update-strategy should fail validation")
+	}
+	ec.update, ec.updateStrategy = false, ""
+
+	ec.maxAttempts = -1
+	if err := ec.validateFlags(); err == nil {
+		t.Error("a negative -max-attempts should fail validation")
+	}
+	ec.maxAttempts = 0
+
+	ec.typecheck = true
+	for _, other := range []*bool{&ec.noVendor, &ec.dryRun} {
+		*other = true
+		if err := ec.validateFlags(); err == nil {
+			t.Error("-typecheck with -no-vendor or -dry-run should fail validation")
+		}
+		*other = false
+	}
+	ec.typecheck = false
+
+	ec.reason = "parses the config files"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-reason without -add should fail validation")
+	}
+	ec.reason = ""
+
+	ec.until = "2999-01-01"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-until without -override should fail validation")
+	}
+	ec.override = true
+	for _, until := range []string{"01/06/2999", "2000-01-01"} {
+		ec.until = until
+		if err := ec.validateFlags(); err == nil {
+			t.Errorf("-until %s should fail validation", until)
+		}
+	}
+	ec.until, ec.update = "", true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-override with -update should fail validation")
+	}
+	ec.override, ec.update = false, false
+
+	ec.force = true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-force without -update should fail validation")
+	}
+	ec.force = false
+
+	ec.security = true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-security without -update should fail validation")
+	}
+	ec.update, ec.interactive = true, true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-security with -i should fail validation")
+	}
+	ec.update, ec.interactive, ec.security = false, false, false
+
+	ec.hermetic = "strict"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("an unknown -hermetic should fail validation")
+	}
+	ec.hermetic, ec.vendorOnly = "", true
+
 	// Also verify that the plain ensure path takes no args. This is a shady
 	// test, as lots of other things COULD return errors, and we don't check
 	// anything other than the error being non-nil. For now, it works well
@@ -0,0 +1,260 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+const exportShortHelp = `Export Gopkg.lock to another build system's format`
+const exportLongHelp = `
+Convert the dependencies recorded in Gopkg.lock into a format understood by
+another build system, so that builds driven by that system stay in sync with
+dep's resolution.
+
+Supported formats:
+
+  bazel  go_repository rules for Bazel/Gazelle, one per locked project, with
+         importpath and commit set, and remote and vcs set to the URL and
+         VCS dep retrieves the project from, unless it is a bundle. The rules are left without a
+         sum attribute: Gazelle checks sum against the go.sum hash of a
+         module zip fetched by version, while dep's digest hashes the pruned
+         tree it vendors, so no digest dep records can stand in for it. The
+         digest is included as a comment instead.
+  nix    A deps.nix list of fetchgit entries (url, rev, sha256), one per
+         locked project, fetched from the URL dep retrieves the project
+         from. fetchgit checks the NAR hash of the whole checkout, which dep
+         does not record, so each sha256 is a placeholder of zeros that must
+         be replaced before use, with the hash nix-prefetch-git prints, or
+         the one nix reports when the placeholder fails to match.
+`
+
+// nixPlaceholderSha256 is the sha256 written for each project in a deps.nix
+// export, the value of nixpkgs' lib.fakeSha256.
+const nixPlaceholderSha256 = "0000000000000000000000000000000000000000000000000000"
+
+const (
+	exportFormatBazel = "bazel"
+	exportFormatNix   = "nix"
+)
+
+func (cmd *exportCommand) Name() string      { return "export" }
+func (cmd *exportCommand) Args() string      { return "-format=<format> [-out file]" }
+func (cmd *exportCommand) ShortHelp() string { return exportShortHelp }
+func (cmd *exportCommand) LongHelp() string  { return exportLongHelp }
+func (cmd *exportCommand) Hidden() bool      { return false }
+
+func (cmd *exportCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", "", "output format; one of: "+exportFormatBazel+", "+exportFormatNix)
+	fs.StringVar(&cmd.outFilePath, "out", "", "path to a file to which to write the output. Blank value will be ignored")
+}
+
+type exportCommand struct {
+	format      string
+	outFilePath string
+}
+
+func (cmd *exportCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	switch cmd.format {
+	case exportFormatBazel, exportFormatNix:
+	case "":
+		return errors.New("an export format must be specified with -format")
+	default:
+		return errors.Errorf("unknown export format %q", cmd.format)
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no %s found. Run `dep ensure` to generate lock file", dep.LockName)
+	}
+
+	var out []byte
+	switch cmd.format {
+	case exportFormatBazel:
+		srcs, err := lockedSources(ctx, p, true)
+		if err != nil {
+			return err
+		}
+		out = exportBazel(p.Lock, srcs)
+	case exportFormatNix:
+		srcs, err := lockedSources(ctx, p, false)
+		if err != nil {
+			return err
+		}
+		out = exportNix(p.Lock, srcs)
+	}
+	if cmd.outFilePath == "" {
+		ctx.Out.Print(string(out))
+		return nil
+	}
+
+	if err := ioutil.WriteFile(cmd.outFilePath, out, 0666); err != nil {
+		return fmt.Errorf("error writing output file: %v", err)
+	}
+	return nil
+}
+
+// bazelVCS is the set of source types go_repository can fetch from.
+var bazelVCS = map[string]bool{"git": true, "hg": true, "svn": true, "bzr": true}
+
+// exportBazel renders the lock as a series of Gazelle go_repository rules,
+// fetching each project from its source in srcs.
+func exportBazel(l *dep.Lock, srcs map[gps.ProjectRoot]lockedSource) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated from %s by \"dep export -format=bazel\"; DO NOT EDIT.\n\n", dep.LockName)
+	fmt.Fprintln(&buf, `load("@bazel_gazelle//:deps.bzl", "go_repository")`)
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "def dep_repositories():")
+
+	if len(l.Projects()) == 0 {
+		fmt.Fprintln(&buf, "    pass")
+		return buf.Bytes()
+	}
+
+	for i, lp := range l.Projects() {
+		if i > 0 {
+			fmt.Fprintln(&buf)
+		}
+		id := lp.Ident()
+		rev, _, _ := gps.VersionComponentStrings(lp.Version())
+
+		if vp, ok := lp.(verify.VerifiableProject); ok && !vp.Digest.IsEmpty() {
+			fmt.Fprintf(&buf, "    # dep digest: %s\n", vp.Digest)
+		}
+		fmt.Fprintln(&buf, "    go_repository(")
+		fmt.Fprintf(&buf, "        name = %q,\n", bazelRepoName(id.ProjectRoot))
+		fmt.Fprintf(&buf, "        importpath = %q,\n", string(id.ProjectRoot))
+		if src := srcs[id.ProjectRoot]; src.url != "" && bazelVCS[src.vcs] {
+			fmt.Fprintf(&buf, "        remote = %q,\n", src.url)
+			fmt.Fprintf(&buf, "        vcs = %q,\n", src.vcs)
+		}
+		fmt.Fprintf(&buf, "        commit = %q,\n", rev)
+		fmt.Fprintln(&buf, "    )")
+	}
+
+	return buf.Bytes()
+}
+
+// lockedSource is the source a locked project is retrieved from.
+type lockedSource struct {
+	url string
+	// vcs is the type of the source, as returned by SourceMgr.SourceTypeFor,
+	// if it was asked for.
+	vcs string
+}
+
+// lockedSources returns the source of each project in p's lock. Its URL is
+// the one recorded in the lock, or else the one the source manager retrieves
+// it from, which may involve looking up the project's import path. If withVCS
+// is set, the source manager is also asked for the type of each source.
+func lockedSources(ctx *dep.Ctx, p *dep.Project, withVCS bool) (map[gps.ProjectRoot]lockedSource, error) {
+	srcs := make(map[gps.ProjectRoot]lockedSource)
+	var sm *gps.SourceMgr
+	for _, lp := range p.Lock.Projects() {
+		id := lp.Ident()
+		var src lockedSource
+		if vp, ok := lp.(verify.VerifiableProject); ok && vp.SourceURL != "" {
+			src.url = vp.SourceURL
+			if !withVCS {
+				srcs[id.ProjectRoot] = src
+				continue
+			}
+			id.Source = vp.SourceURL
+		}
+		if sm == nil {
+			var err error
+			if sm, err = ctx.ProjectSourceManager(p); err != nil {
+				return nil, err
+			}
+			sm.UseDefaultSignalHandling()
+			defer sm.Release()
+		}
+		if src.url == "" {
+			url, err := sm.SourceURLFor(id)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to find the source of %s", id)
+			}
+			src.url = url
+		}
+		if withVCS {
+			vcs, err := sm.SourceTypeFor(id)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to find the source of %s", id)
+			}
+			src.vcs = vcs
+		}
+		srcs[id.ProjectRoot] = src
+	}
+	return srcs, nil
+}
+
+// exportNix renders the lock as a deps.nix expression: a list of fetchgit
+// entries, in the format consumed by buildGoPackage's goDeps, fetching each
+// project from its URL in srcs.
+func exportNix(l *dep.Lock, srcs map[gps.ProjectRoot]lockedSource) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated from %s by \"dep export -format=nix\".\n", dep.LockName)
+	fmt.Fprintln(&buf, "# Replace each placeholder sha256 with the NAR hash of the checkout, as")
+	fmt.Fprintln(&buf, "# printed by nix-prefetch-git, before use.")
+	fmt.Fprintln(&buf, "[")
+	for _, lp := range l.Projects() {
+		id := lp.Ident()
+		rev, _, _ := gps.VersionComponentStrings(lp.Version())
+
+		fmt.Fprintln(&buf, "  {")
+		fmt.Fprintf(&buf, "    goPackagePath = %q;\n", string(id.ProjectRoot))
+		fmt.Fprintln(&buf, "    fetch = {")
+		fmt.Fprintln(&buf, `      type = "git";`)
+		fmt.Fprintf(&buf, "      url = %q;\n", srcs[id.ProjectRoot].url)
+		fmt.Fprintf(&buf, "      rev = %q;\n", rev)
+		fmt.Fprintf(&buf, "      sha256 = %q;\n", nixPlaceholderSha256)
+		fmt.Fprintln(&buf, "    };")
+		fmt.Fprintln(&buf, "  }")
+	}
+	fmt.Fprintln(&buf, "]")
+
+	return buf.Bytes()
+}
+
+// bazelRepoName converts a project root into the repository name Gazelle
+// would derive for it: the host's labels are reversed, and all characters
+// that are not valid in a Bazel repository name are replaced with
+// underscores.
+//
+// For example, "github.com/pkg/errors" becomes "com_github_pkg_errors".
+func bazelRepoName(pr gps.ProjectRoot) string {
+	parts := strings.Split(string(pr), "/")
+	host := strings.Split(parts[0], ".")
+	for i, j := 0, len(host)-1; i < j; i, j = i+1, j-1 {
+		host[i], host[j] = host[j], host[i]
+	}
+	parts[0] = strings.Join(host, ".")
+
+	name := strings.Join(parts, "_")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
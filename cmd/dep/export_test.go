@@ -0,0 +1,121 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+)
+
+func exportTestLock(t *testing.T) *dep.Lock {
+	digest, err := verify.ParseVersionedDigest("1:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &dep.Lock{
+		P: []gps.LockedProject{
+			verify.VerifiableProject{
+				LockedProject: gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors"},
+					gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+					[]string{"."},
+				),
+				Digest: digest,
+			},
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "golang.org/x/sys", Source: "https://github.com/golang/sys"},
+				gps.NewBranch("master").Pair("37707fdb30a5b38865cfb95e5aab41707daec7fd"),
+				[]string{"unix"},
+			),
+		},
+	}
+}
+
+func TestBazelRepoName(t *testing.T) {
+	t.Parallel()
+
+	cases := map[gps.ProjectRoot]string{
+		"github.com/pkg/errors":       "com_github_pkg_errors",
+		"golang.org/x/sys":            "org_golang_x_sys",
+		"gopkg.in/yaml.v2":            "in_gopkg_yaml_v2",
+		"github.com/foo-bar/baz.quux": "com_github_foo_bar_baz_quux",
+	}
+	for pr, want := range cases {
+		if got := bazelRepoName(pr); got != want {
+			t.Errorf("bazelRepoName(%q): got %q, want %q", pr, got, want)
+		}
+	}
+}
+
+func TestExportBazel(t *testing.T) {
+	t.Parallel()
+
+	srcs := map[gps.ProjectRoot]lockedSource{
+		"github.com/pkg/errors": {url: "https://github.com/pkg/errors", vcs: "git"},
+		"golang.org/x/sys":      {url: "https://bitbucket.org/golang/sys", vcs: "hg"},
+	}
+	got := string(exportBazel(exportTestLock(t), srcs))
+	wants := []string{
+		`load("@bazel_gazelle//:deps.bzl", "go_repository")`,
+		"    # dep digest: 1:0123456789abcdef",
+		`        name = "com_github_pkg_errors",`,
+		`        importpath = "github.com/pkg/errors",`,
+		`        remote = "https://github.com/pkg/errors",`,
+		`        vcs = "git",`,
+		`        commit = "645ef00459ed84a119197bfb8d8205042c6df63d",`,
+		`        name = "org_golang_x_sys",`,
+		`        remote = "https://bitbucket.org/golang/sys",`,
+		`        vcs = "hg",`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected bazel export to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	bundled := string(exportBazel(exportTestLock(t), map[gps.ProjectRoot]lockedSource{
+		"github.com/pkg/errors": {url: "file:///deps.bundle", vcs: "bundle"},
+	}))
+	if strings.Contains(bundled, "remote =") || strings.Contains(bundled, "vcs =") {
+		t.Errorf("expected projects without a VCS source to be left to Gazelle, got:\n%s", bundled)
+	}
+
+	empty := string(exportBazel(&dep.Lock{}, nil))
+	if !strings.HasSuffix(empty, "def dep_repositories():\n    pass\n") {
+		t.Errorf("expected empty lock to produce a no-op macro, got:\n%s", empty)
+	}
+}
+
+func TestExportNix(t *testing.T) {
+	t.Parallel()
+
+	srcs := map[gps.ProjectRoot]lockedSource{
+		"github.com/pkg/errors": {url: "https://github.com/pkg/errors"},
+		"golang.org/x/sys":      {url: "https://github.com/golang/sys"},
+	}
+	got := string(exportNix(exportTestLock(t), srcs))
+	wants := []string{
+		`    goPackagePath = "github.com/pkg/errors";`,
+		`      url = "https://github.com/pkg/errors";`,
+		`      rev = "645ef00459ed84a119197bfb8d8205042c6df63d";`,
+		`      sha256 = "` + nixPlaceholderSha256 + `";`,
+		`    goPackagePath = "golang.org/x/sys";`,
+		`      url = "https://github.com/golang/sys";`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected nix export to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	if !strings.HasPrefix(got, "# Generated") || !strings.HasSuffix(got, "]\n") {
+		t.Errorf("expected nix export to be a single list expression, got:\n%s", got)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const fmtShortHelp = `Rewrite Gopkg.toml in canonical form`
+const fmtLongHelp = `
+Fmt rewrites Gopkg.toml in canonical form, so that diffs to it stay minimal no
+matter who edits it, or with what. Constraint, override and per-project prune
+stanzas are sorted by name, and stanzas that exactly duplicate another are
+removed. The keys of each table, and the entries of required and ignored, are
+sorted, and duplicate entries removed. Version constraints are written in the
+form dep itself writes them. Comments are kept with the stanza, key or entry
+they describe.
+
+With -check, fmt writes nothing, but exits non-zero if Gopkg.toml is not in
+canonical form. This is intended for use in CI.
+`
+
+func (cmd *fmtCommand) Name() string      { return "fmt" }
+func (cmd *fmtCommand) Args() string      { return "[-check]" }
+func (cmd *fmtCommand) ShortHelp() string { return fmtShortHelp }
+func (cmd *fmtCommand) LongHelp() string  { return fmtLongHelp }
+func (cmd *fmtCommand) Hidden() bool      { return false }
+
+func (cmd *fmtCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.check, "check", false, "fail, without writing anything, if Gopkg.toml is not in canonical form")
+}
+
+type fmtCommand struct {
+	check bool
+}
+
+func (cmd *fmtCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	mp, err := ctx.ManifestPath()
+	if err != nil {
+		return err
+	}
+	orig, err := ioutil.ReadFile(mp)
+	if err != nil {
+		return errors.Wrapf(err, "reading %s failed", dep.ManifestName)
+	}
+
+	formatted, err := dep.FormatManifest(orig)
+	if err != nil {
+		return errors.Wrapf(err, "could not format %s", mp)
+	}
+	if bytes.Equal(orig, formatted) {
+		return nil
+	}
+
+	if cmd.check {
+		return errors.Errorf("%s is not in canonical form; run `dep fmt` to fix it", dep.ManifestName)
+	}
+	if ctx.Verbose {
+		ctx.Err.Printf("Rewriting %s in canonical form\n", mp)
+	}
+	return errors.Wrapf(ioutil.WriteFile(mp, formatted, 0666), "writing %s failed", dep.ManifestName)
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestFmtCommand(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	unformatted := "required = [\"github.com/b/b\", \"github.com/a/a\"]\n"
+	h.TempFile("src/example.com/root/"+dep.ManifestName, unformatted)
+
+	ctx := &dep.Ctx{
+		WorkingDir: h.Path("src/example.com/root"),
+		Out:        log.New(ioutil.Discard, "", 0),
+		Err:        log.New(ioutil.Discard, "", 0),
+	}
+	mp := h.Path("src/example.com/root/" + dep.ManifestName)
+
+	// -check reports the manifest, but leaves it alone.
+	if err := (&fmtCommand{check: true}).Run(ctx, nil); err == nil {
+		t.Error("expected -check to fail for a manifest not in canonical form")
+	}
+	if got, _ := ioutil.ReadFile(mp); string(got) != unformatted {
+		t.Errorf("expected -check not to change the manifest, got:\n%s", got)
+	}
+
+	if err := (&fmtCommand{}).Run(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "required = [\n  \"github.com/a/a\",\n  \"github.com/b/b\",\n]\n"
+	if got, _ := ioutil.ReadFile(mp); string(got) != want {
+		t.Errorf("unexpected formatted manifest:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	if err := (&fmtCommand{check: true}).Run(ctx, nil); err != nil {
+		t.Errorf("expected -check to pass once formatted, got %s", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const generateVersionInfoShortHelp = `Generate build-time version information from Gopkg.lock`
+const generateVersionInfoLongHelp = `
+Generate a description of the exact dependency versions recorded in Gopkg.lock,
+suitable for embedding in a binary so that it can report which dependency
+revisions it was built against.
+
+Two formats are supported:
+
+  go       A Go source file declaring a map from project root to the locked
+           version and revision. This is the default.
+  ldflags  A -X linker flag that sets a string variable to a comma-separated
+           list of root@version#revision entries. Pass it to go build via
+           -ldflags.
+
+Examples:
+
+  dep generate-version-info -out versions_gen.go
+  go build -ldflags "$(dep generate-version-info -format=ldflags -var=main.deps)"
+`
+
+const (
+	versionInfoFormatGo      = "go"
+	versionInfoFormatLdflags = "ldflags"
+)
+
+func (cmd *generateVersionInfoCommand) Name() string { return "generate-version-info" }
+func (cmd *generateVersionInfoCommand) Args() string {
+	return "[-format=go|ldflags] [-pkg name] [-var name] [-out file]"
+}
+func (cmd *generateVersionInfoCommand) ShortHelp() string { return generateVersionInfoShortHelp }
+func (cmd *generateVersionInfoCommand) LongHelp() string  { return generateVersionInfoLongHelp }
+func (cmd *generateVersionInfoCommand) Hidden() bool      { return false }
+
+func (cmd *generateVersionInfoCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", versionInfoFormatGo, "output format: go or ldflags")
+	fs.StringVar(&cmd.pkg, "pkg", "main", "package name for the generated Go file")
+	fs.StringVar(&cmd.varName, "var", "", "variable to populate (default: DependencyVersions for go, main.dependencyVersions for ldflags)")
+	fs.StringVar(&cmd.outFilePath, "out", "", "path to a file to which to write the output. Blank value will be ignored")
+}
+
+type generateVersionInfoCommand struct {
+	format      string
+	pkg         string
+	varName     string
+	outFilePath string
+}
+
+func (cmd *generateVersionInfoCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no %s found. Run `dep ensure` to generate lock file", dep.LockName)
+	}
+
+	var out []byte
+	switch cmd.format {
+	case versionInfoFormatGo:
+		varName := cmd.varName
+		if varName == "" {
+			varName = "DependencyVersions"
+		}
+		out, err = versionInfoGoFile(p.Lock, cmd.pkg, varName)
+		if err != nil {
+			return err
+		}
+	case versionInfoFormatLdflags:
+		varName := cmd.varName
+		if varName == "" {
+			varName = "main.dependencyVersions"
+		}
+		out = []byte(versionInfoLdflags(p.Lock, varName) + "\n")
+	default:
+		return errors.Errorf("unknown format %q, must be one of %s or %s", cmd.format, versionInfoFormatGo, versionInfoFormatLdflags)
+	}
+
+	if cmd.outFilePath == "" {
+		ctx.Out.Print(string(out))
+		return nil
+	}
+
+	if err := ioutil.WriteFile(cmd.outFilePath, out, 0666); err != nil {
+		return fmt.Errorf("error writing output file: %v", err)
+	}
+	return nil
+}
+
+// lockedVersionInfo is the version information recorded for a single locked
+// project.
+type lockedVersionInfo struct {
+	ProjectRoot string
+	Version     string
+	Revision    string
+}
+
+// collectVersionInfo extracts version information for every project in the
+// lock, in the lock's (sorted) order.
+func collectVersionInfo(l *dep.Lock) []lockedVersionInfo {
+	infos := make([]lockedVersionInfo, 0, len(l.Projects()))
+	for _, lp := range l.Projects() {
+		rev, branch, version := gps.VersionComponentStrings(lp.Version())
+		if version == "" {
+			version = branch
+		}
+		infos = append(infos, lockedVersionInfo{
+			ProjectRoot: string(lp.Ident().ProjectRoot),
+			Version:     version,
+			Revision:    rev,
+		})
+	}
+	return infos
+}
+
+// versionInfoGoFile renders a gofmt'd Go source file declaring a map variable
+// populated with the version information recorded in the lock.
+func versionInfoGoFile(l *dep.Lock, pkg, varName string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by \"dep generate-version-info\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "// %s maps each locked dependency's project root to the version\n", varName)
+	fmt.Fprintf(&buf, "// and revision recorded in %s at generation time.\n", dep.LockName)
+	fmt.Fprintf(&buf, "var %s = map[string]struct{ Version, Revision string }{\n", varName)
+	for _, vi := range collectVersionInfo(l) {
+		fmt.Fprintf(&buf, "%q: {Version: %q, Revision: %q},\n", vi.ProjectRoot, vi.Version, vi.Revision)
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	return src, errors.Wrap(err, "failed to format generated version info")
+}
+
+// versionInfoLdflags renders a -X linker flag that sets varName to a
+// comma-separated list of root@version#revision entries.
+func versionInfoLdflags(l *dep.Lock, varName string) string {
+	infos := collectVersionInfo(l)
+	entries := make([]string, 0, len(infos))
+	for _, vi := range infos {
+		entry := vi.ProjectRoot
+		if vi.Version != "" {
+			entry += "@" + vi.Version
+		}
+		entries = append(entries, entry+"#"+vi.Revision)
+	}
+	return fmt.Sprintf("-X %s=%s", varName, strings.Join(entries, ","))
+}
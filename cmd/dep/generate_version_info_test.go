@@ -0,0 +1,68 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func versionInfoTestLock() *dep.Lock {
+	return &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+				gps.NewVersion("v1.0.0").Pair("abc123"),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/baz/qux"},
+				gps.NewBranch("master").Pair("def456"),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/rev/only"},
+				gps.Revision("0123abc"),
+				[]string{"."},
+			),
+		},
+	}
+}
+
+func TestVersionInfoGoFile(t *testing.T) {
+	t.Parallel()
+
+	src, err := versionInfoGoFile(versionInfoTestLock(), "buildinfo", "Deps")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(src)
+	wants := []string{
+		"package buildinfo\n",
+		"var Deps = map[string]struct{ Version, Revision string }{",
+		`"github.com/foo/bar":  {Version: "v1.0.0", Revision: "abc123"},`,
+		`"github.com/baz/qux":  {Version: "master", Revision: "def456"},`,
+		`"github.com/rev/only": {Version: "", Revision: "0123abc"},`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected generated file to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestVersionInfoLdflags(t *testing.T) {
+	t.Parallel()
+
+	got := versionInfoLdflags(versionInfoTestLock(), "main.deps")
+	want := "-X main.deps=github.com/foo/bar@v1.0.0#abc123,github.com/baz/qux@master#def456,github.com/rev/only#0123abc"
+	if got != want {
+		t.Fatalf("unexpected ldflags:\n\t(GOT): %s\n\t(WNT): %s", got, want)
+	}
+}
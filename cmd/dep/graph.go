@@ -0,0 +1,126 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const graphShortHelp = `Show how the project's packages reach its dependencies`
+const graphLongHelp = `
+List each project in Gopkg.lock with the packages of the current project
+through which it is imported, directly or transitively. Packages that are
+only needed because they are listed as required in Gopkg.toml are shown as
+imported via Gopkg.toml. Projects that the current project imports, or
+requires, itself are marked as direct; the others are only depended on
+transitively.
+
+With -packages, the reachable packages of each project are listed as well,
+each with the packages of the current project it is reachable from. Projects
+in Gopkg.lock none of whose packages are reachable are listed as unreachable,
+which suggests that the lock is out of date.
+
+With -json, the same information is written as a JSON array with an entry
+for each project, holding its ProjectRoot, whether it is Direct, and its Via
+and Packages in the form shown by -packages. Each entry also holds the
+project's Depth, the length of the shortest chain of imports from the current
+project to it, counted in projects, so that a direct dependency is at depth 1,
+and its FanIn, the number of distinct projects, counting the current one, that
+import it. Both are 0 for unreachable projects.
+`
+
+func (cmd *graphCommand) Name() string      { return "graph" }
+func (cmd *graphCommand) Args() string      { return "[-packages] [-json]" }
+func (cmd *graphCommand) ShortHelp() string { return graphShortHelp }
+func (cmd *graphCommand) LongHelp() string  { return graphLongHelp }
+func (cmd *graphCommand) Hidden() bool      { return false }
+
+func (cmd *graphCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.packages, "packages", false, "also list the reachable packages of each project")
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+type graphCommand struct {
+	packages bool
+	json     bool
+}
+
+func (cmd *graphCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	graph, err := p.ImportGraph(sm)
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		out, err := json.Marshal(graph)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the import graph")
+		}
+		ctx.Out.Println(string(out))
+		return nil
+	}
+	ctx.Out.Print(formatImportGraph(graph, cmd.packages))
+	return nil
+}
+
+// formatImportGraph renders graph as text, listing the packages of each
+// project if packages is true.
+func formatImportGraph(graph []dep.ProjectReach, packages bool) string {
+	var buf bytes.Buffer
+	for _, pr := range graph {
+		if pr.Direct {
+			fmt.Fprintf(&buf, "%s (direct)\n", pr.ProjectRoot)
+		} else {
+			fmt.Fprintln(&buf, pr.ProjectRoot)
+		}
+		if len(pr.Packages) == 0 {
+			fmt.Fprintln(&buf, "    unreachable")
+			continue
+		}
+
+		if !packages {
+			for _, via := range pr.Via {
+				fmt.Fprintf(&buf, "    via %s\n", via)
+			}
+			continue
+		}
+
+		pkgs := make([]string, 0, len(pr.Packages))
+		for pkg := range pr.Packages {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+		for _, pkg := range pkgs {
+			fmt.Fprintf(&buf, "    %s\n", pkg)
+			for _, via := range pr.Packages[pkg] {
+				fmt.Fprintf(&buf, "        via %s\n", via)
+			}
+		}
+	}
+	return buf.String()
+}
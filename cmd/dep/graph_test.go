@@ -0,0 +1,51 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/dep"
+)
+
+func TestFormatImportGraph(t *testing.T) {
+	graph := []dep.ProjectReach{
+		{
+			ProjectRoot: "github.com/foo/bar",
+			Direct:      true,
+			Via:         []string{"github.com/me/proj", "github.com/me/proj/cmd"},
+			Packages: map[string][]string{
+				"github.com/foo/bar/sub": {"github.com/me/proj/cmd"},
+				"github.com/foo/bar":     {"github.com/me/proj"},
+			},
+		},
+		{
+			ProjectRoot: "github.com/not/reached",
+			Packages:    map[string][]string{},
+		},
+	}
+
+	want := `github.com/foo/bar (direct)
+    via github.com/me/proj
+    via github.com/me/proj/cmd
+github.com/not/reached
+    unreachable
+`
+	if got := formatImportGraph(graph, false); got != want {
+		t.Errorf("unexpected output:\n%s\nwanted:\n%s", got, want)
+	}
+
+	want = `github.com/foo/bar (direct)
+    github.com/foo/bar
+        via github.com/me/proj
+    github.com/foo/bar/sub
+        via github.com/me/proj/cmd
+github.com/not/reached
+    unreachable
+`
+	if got := formatImportGraph(graph, true); got != want {
+		t.Errorf("unexpected -packages output:\n%s\nwanted:\n%s", got, want)
+	}
+}
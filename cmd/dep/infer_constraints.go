@@ -0,0 +1,153 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"sort"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const inferConstraintsShortHelp = `Add constraints for unconstrained dependencies from Gopkg.lock`
+const inferConstraintsLongHelp = `
+Infer-constraints adds a [[constraint]] to Gopkg.toml for each direct
+dependency that has neither a [[constraint]] nor an [[override]], derived from
+the version it is locked at in Gopkg.lock: a caret range, such as ^1.2.0, for a
+semver version, and the branch or version itself otherwise. Dependencies
+locked to a bare revision are listed, but left unconstrained, as there is no
+range to infer from a revision.
+
+As every inferred constraint allows the locked version, Gopkg.lock stays in
+sync; later updates are held to versions compatible with those in use. The
+comments and formatting of Gopkg.toml are kept.
+
+With -dry-run, the inferred constraints are printed instead of written.
+`
+
+func (cmd *inferConstraintsCommand) Name() string      { return "infer-constraints" }
+func (cmd *inferConstraintsCommand) Args() string      { return "[-dry-run]" }
+func (cmd *inferConstraintsCommand) ShortHelp() string { return inferConstraintsShortHelp }
+func (cmd *inferConstraintsCommand) LongHelp() string  { return inferConstraintsLongHelp }
+func (cmd *inferConstraintsCommand) Hidden() bool      { return false }
+
+func (cmd *inferConstraintsCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "print the inferred constraints instead of adding them to Gopkg.toml")
+}
+
+type inferConstraintsCommand struct {
+	dryRun bool
+}
+
+func (cmd *inferConstraintsCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found to infer constraints from; run `dep ensure` to generate it", dep.LockName)
+	}
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	direct, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return errors.Wrap(err, "could not determine the direct dependencies")
+	}
+	inferred, revisions := inferConstraints(p.Manifest, p.Lock, direct)
+	for _, pr := range revisions {
+		ctx.Err.Printf("%s is locked to a bare revision; no constraint was inferred for it\n", pr)
+	}
+	if len(inferred) == 0 {
+		if ctx.Verbose {
+			ctx.Err.Println("Every direct dependency that could be constrained already is")
+		}
+		return nil
+	}
+
+	if cmd.dryRun {
+		m := dep.NewManifest()
+		m.Constraints = inferred
+		out, err := m.MarshalTOML()
+		if err != nil {
+			return err
+		}
+		ctx.Out.Printf("Would have added the following to %s:\n%s", dep.ManifestName, out)
+		return errDryRunChanges
+	}
+
+	mp, err := ctx.ManifestPath()
+	if err != nil {
+		return err
+	}
+	orig, err := ioutil.ReadFile(mp)
+	if err != nil {
+		return errors.Wrapf(err, "reading %s failed", dep.ManifestName)
+	}
+	// Edit the manifest as it is on disk, rather than as loaded for the
+	// project, which has had the current build context applied to it.
+	m, _, err := dep.ReadManifest(bytes.NewReader(orig))
+	if err != nil {
+		return err
+	}
+	for pr, pp := range inferred {
+		m.Constraints[pr] = pp
+	}
+	out, err := m.RewriteTOML(orig)
+	if err != nil {
+		return errors.Wrapf(err, "could not rewrite %s", dep.ManifestName)
+	}
+	if err := ioutil.WriteFile(mp, out, 0666); err != nil {
+		return errors.Wrapf(err, "writing %s failed", dep.ManifestName)
+	}
+	if ctx.Verbose {
+		ctx.Err.Printf("Added %d constraint(s) to %s\n", len(inferred), mp)
+	}
+	return nil
+}
+
+// inferConstraints returns the constraints to add to m for each of the direct
+// dependencies that m has no rule for, derived from the versions they are
+// locked at in l, along with the sorted roots of those that were left out, as
+// they are locked to bare revisions.
+func inferConstraints(m *dep.Manifest, l *dep.Lock, direct map[gps.ProjectRoot]bool) (gps.ProjectConstraints, []gps.ProjectRoot) {
+	inferred := make(gps.ProjectConstraints)
+	var revisions []gps.ProjectRoot
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		if !direct[pr] {
+			continue
+		}
+		if _, has := m.Constraints[pr]; has {
+			continue
+		}
+		if _, has := m.Ovr[pr]; has {
+			continue
+		}
+
+		pp := getProjectPropertiesFromVersion(lp.Version())
+		if pp.Constraint == nil {
+			revisions = append(revisions, pr)
+			continue
+		}
+		pp.Source = lp.Ident().Source
+		inferred[pr] = pp
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i] < revisions[j] })
+	return inferred, revisions
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestInferConstraints(t *testing.T) {
+	locked := func(pr, source string, v gps.Version) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr), Source: source}, v, []string{"."})
+	}
+	l := &dep.Lock{P: []gps.LockedProject{
+		locked("github.com/foo/semver", "", gps.NewVersion("v1.2.0").Pair("abc")),
+		locked("github.com/foo/branch", "github.com/bar/branch", gps.NewBranch("master").Pair("def")),
+		locked("github.com/foo/rev", "", gps.Revision("123")),
+		locked("github.com/foo/constrained", "", gps.NewVersion("v2.0.0").Pair("456")),
+		locked("github.com/foo/overridden", "", gps.NewVersion("v3.0.0").Pair("789")),
+		locked("github.com/foo/transitive", "", gps.NewVersion("v4.0.0").Pair("abd")),
+	}}
+	m := dep.NewManifest()
+	m.Constraints["github.com/foo/constrained"] = gps.ProjectProperties{Constraint: gps.Any()}
+	m.Ovr["github.com/foo/overridden"] = gps.ProjectProperties{Constraint: gps.Any()}
+	direct := map[gps.ProjectRoot]bool{
+		"github.com/foo/semver":      true,
+		"github.com/foo/branch":      true,
+		"github.com/foo/rev":         true,
+		"github.com/foo/constrained": true,
+		"github.com/foo/overridden":  true,
+	}
+
+	inferred, revisions := inferConstraints(m, l, direct)
+
+	caret, err := gps.NewSemverConstraintIC("v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gps.ProjectConstraints{
+		"github.com/foo/semver": {Constraint: caret},
+		"github.com/foo/branch": {Constraint: gps.NewBranch("master"), Source: "github.com/bar/branch"},
+	}
+	if !reflect.DeepEqual(inferred, want) {
+		t.Errorf("expected inferred constraints %v, got %v", want, inferred)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/rev"}; !reflect.DeepEqual(revisions, want) {
+		t.Errorf("expected %v to be left out as locked to revisions, got %v", want, revisions)
+	}
+}
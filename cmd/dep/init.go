@@ -40,18 +40,38 @@ algorithm:
  - Default branch(es) (sorted lexicographically)
  - Non-semver tags (sorted lexicographically)
 
+If the project already has a vendor/ directory, passing -vendor adopts the
+versions it holds: each vendored project is compared against the versions
+available from its source, and locked to the newest version whose files match
+the vendored ones. Vendored copies that have been pruned still match, but any
+local modification prevents a match. This preserves the versions a team has
+already pinned by copying code into vendor/.
+
 An alternate mode can be activated by passing -gopath. In this mode, the version
 of each dependency will reflect the current state of the GOPATH. If a dependency
 doesn't exist in the GOPATH, a version will be selected based on the above
 network version selection algorithm.
 
+With -template, the initial Gopkg.toml is seeded from a template: a Gopkg.toml
+holding an organization's standard prune options, ignored and required
+packages, overrides pointing projects at mirrors of their sources, and policy
+references in its metadata table. The template is given by its path, or by the
+name of a file in the templates directory of the dep cache directory, such as
+"corp" for $GOPATH/pkg/dep/templates/corp.toml. Lists in the template are
+merged with those inferred; its other settings take precedence. Its constraints
+only apply to direct dependencies, supplying their versions where none could
+be inferred.
+
 A Gopkg.toml file will be written with inferred version constraints for all
 direct dependencies. Gopkg.lock will be written with precise versions, and
 vendor/ will be populated with the precise versions written to Gopkg.lock.
+
+With -dry-run, nothing is written; the files that would be are reported, and
+init exits with status 2.
 `
 
 func (cmd *initCommand) Name() string      { return "init" }
-func (cmd *initCommand) Args() string      { return "[root]" }
+func (cmd *initCommand) Args() string      { return "[-dry-run] [root]" }
 func (cmd *initCommand) ShortHelp() string { return initShortHelp }
 func (cmd *initCommand) LongHelp() string  { return initLongHelp }
 func (cmd *initCommand) Hidden() bool      { return false }
@@ -60,12 +80,18 @@ func (cmd *initCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.noExamples, "no-examples", false, "don't include example in Gopkg.toml")
 	fs.BoolVar(&cmd.skipTools, "skip-tools", false, "skip importing configuration from other dependency managers")
 	fs.BoolVar(&cmd.gopath, "gopath", false, "search in GOPATH for dependencies")
+	fs.BoolVar(&cmd.vendor, "vendor", false, "lock dependencies to the versions found in vendor/")
+	fs.StringVar(&cmd.template, "template", "", "seed Gopkg.toml from the named or given `template`")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
 }
 
 type initCommand struct {
 	noExamples bool
 	skipTools  bool
 	gopath     bool
+	vendor     bool
+	template   string
+	dryRun     bool
 }
 
 func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -81,8 +107,10 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		if !filepath.IsAbs(args[0]) {
 			root = filepath.Join(ctx.WorkingDir, args[0])
 		}
-		if err := os.MkdirAll(root, os.FileMode(0777)); err != nil {
-			return errors.Wrapf(err, "init failed: unable to create a directory at %s", root)
+		if !cmd.dryRun {
+			if err := os.MkdirAll(root, os.FileMode(0777)); err != nil {
+				return errors.Wrapf(err, "init failed: unable to create a directory at %s", root)
+			}
 		}
 	}
 
@@ -91,6 +119,14 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	var template *dep.Manifest
+	if cmd.template != "" {
+		template, err = readInitTemplate(ctx, cmd.template)
+		if err != nil {
+			return errors.Wrap(err, "init failed: unable to read the manifest template")
+		}
+	}
+
 	sm, err := ctx.SourceManager()
 	if err != nil {
 		return errors.Wrap(err, "init failed: unable to create a source manager")
@@ -120,6 +156,19 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	// Set default prune options for go-tests and unused-packages
 	p.Manifest.PruneOptions.DefaultOptions = gps.PruneNestedVendorDirs | gps.PruneGoTestFiles | gps.PruneUnusedPackages
 
+	if template != nil {
+		applyInitTemplate(p.Manifest, template, directDeps)
+	}
+
+	if cmd.vendor {
+		ctx.Err.Println("Matching vendored projects to known versions...")
+		vs := newVendorScanner(ctx, directDeps, sm, p.VendorDir())
+		err = vs.InitializeRootManifestAndLock(p.Manifest, p.Lock)
+		if err != nil {
+			return errors.Wrap(err, "init failed: unable to adopt the contents of vendor/")
+		}
+	}
+
 	if cmd.gopath {
 		gs := newGopathScanner(ctx, directDeps, sm)
 		err = gs.InitializeRootManifestAndLock(p.Manifest, p.Lock)
@@ -161,8 +210,17 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 
 	rootAnalyzer.FinalizeRootManifestAndLock(p.Manifest, p.Lock, copyLock)
 
+	if cmd.dryRun {
+		sw, err := dep.NewSafeWriter(p.Manifest, nil, p.Lock, dep.VendorAlways, p.Manifest.PruneOptions)
+		if err != nil {
+			return errors.Wrap(err, "init failed: unable to create a SafeWriter")
+		}
+		sw.VendorDir = p.VendorDir()
+		return printDryRun(ctx, sw)
+	}
+
 	// Pass timestamp (yyyyMMddHHmmss format) as suffix to backup name.
-	vendorbak, err := dep.BackupVendor(filepath.Join(root, "vendor"), time.Now().Format("20060102150405"))
+	vendorbak, err := dep.BackupVendor(p.VendorDir(), time.Now().Format("20060102150405"))
 	if err != nil {
 		return errors.Wrap(err, "init failed: first backup vendor/, delete it, and then retry the previous command: failed to backup existing vendor directory")
 	}
@@ -174,6 +232,7 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	if err != nil {
 		return errors.Wrap(err, "init failed: unable to create a SafeWriter")
 	}
+	sw.VendorDir = p.VendorDir()
 
 	var logger *log.Logger
 	if ctx.Verbose {
@@ -189,9 +248,9 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 // establishProjectAt attempts to set up the provided path as the root for the
 // project to be created.
 //
-// It checks for being within a GOPATH, that there is no pre-existing manifest
-// and lock, and that we can successfully infer the root import path from
-// GOPATH.
+// It checks that the root import path can be inferred, either from GOPATH or
+// from a go.mod module line, and that there is no pre-existing manifest and
+// lock.
 //
 // If successful, it returns a dep.Project, ready for further use.
 func (cmd *initCommand) establishProjectAt(root string, ctx *dep.Ctx) (*dep.Project, error) {
@@ -201,9 +260,9 @@ func (cmd *initCommand) establishProjectAt(root string, ctx *dep.Ctx) (*dep.Proj
 		return nil, errors.Wrapf(err, "init failed: unable to set the root project to %s", root)
 	}
 
-	ctx.GOPATH, err = ctx.DetectProjectGOPATH(p)
+	p.ImportRoot, err = ctx.InferImportRoot(p)
 	if err != nil {
-		return nil, errors.Wrapf(err, "init failed: unable to detect the containing GOPATH")
+		return nil, errors.Wrapf(err, "init failed: unable to determine the import path for the root project %s", root)
 	}
 
 	mf := filepath.Join(root, dep.ManifestName)
@@ -225,11 +284,5 @@ func (cmd *initCommand) establishProjectAt(root string, ctx *dep.Ctx) (*dep.Proj
 		return nil, errors.Errorf("invalid aborted: lock already exists at %s", lf)
 	}
 
-	ip, err := ctx.ImportForAbs(root)
-	if err != nil {
-		return nil, errors.Wrapf(err, "init failed: unable to determine the import path for the root project %s", root)
-	}
-	p.ImportRoot = gps.ProjectRoot(ip)
-
 	return p, nil
 }
@@ -0,0 +1,150 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// initTemplateDir is the directory, below the dep cache directory, holding the
+// templates that init -template can refer to by name.
+const initTemplateDir = "templates"
+
+// initTemplatePath returns the path of the manifest template named by arg: a
+// path to a file, if it has a directory or a .toml extension, or else the name
+// of a template in the templates directory of the dep cache directory.
+func initTemplatePath(ctx *dep.Ctx, arg string) (string, error) {
+	if strings.ContainsRune(arg, filepath.Separator) || strings.ContainsRune(arg, '/') || strings.HasSuffix(arg, ".toml") {
+		path := arg
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.WorkingDir, path)
+		}
+		return path, nil
+	}
+
+	dir := filepath.Join(ctx.CacheDir(), initTemplateDir)
+	path := filepath.Join(dir, arg+".toml")
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.Errorf("no template named %s in %s", arg, dir)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// readInitTemplate reads the manifest template named by arg, as described for
+// initTemplatePath, printing any warnings about its contents.
+func readInitTemplate(ctx *dep.Ctx, arg string) (*dep.Manifest, error) {
+	path, err := initTemplatePath(ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open the template")
+	}
+	defer f.Close()
+
+	t, warns, err := dep.ReadManifest(f)
+	for _, warn := range warns {
+		ctx.Err.Printf("dep: WARNING: template %s: %v\n", path, warn)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid template %s", path)
+	}
+	return t, nil
+}
+
+// applyInitTemplate seeds m, the manifest inferred by init, with the settings of
+// the template t. Lists such as ignored and required are merged, settings the
+// template gives take precedence, and its prune options, if it sets any,
+// replace init's defaults. The template's overrides, such as those pointing
+// projects at mirrors of their sources, are all kept.
+//
+// Constraints are only taken from the template for direct dependencies, so
+// that the manifest does not constrain projects it has no use for. A template
+// constraint supplies its source to the constraint init inferred, and its
+// version only if init inferred none. The import root is never taken from a
+// template, as it belongs to the individual project.
+func applyInitTemplate(m, t *dep.Manifest, directDeps map[gps.ProjectRoot]bool) {
+	m.Ignored = mergeStrings(m.Ignored, t.Ignored)
+	m.Required = mergeStrings(m.Required, t.Required)
+	m.NoVerify = mergeStrings(m.NoVerify, t.NoVerify)
+	m.Freeze = mergeStrings(m.Freeze, t.Freeze)
+	m.TagsOnly = mergeStrings(m.TagsOnly, t.TagsOnly)
+
+	if t.PruneOptions.DefaultOptions != gps.PruneNestedVendorDirs || len(t.PruneOptions.PerProjectOptions) > 0 {
+		m.PruneOptions = t.PruneOptions
+	}
+	if t.VendorDir != "" {
+		m.VendorDir = t.VendorDir
+	}
+	if t.LockPreference != gps.PreferLocked {
+		m.LockPreference = t.LockPreference
+	}
+	if t.GoVersion != "" {
+		m.GoVersion = t.GoVersion
+	}
+	m.GoSum = m.GoSum || t.GoSum
+
+	for pr, pp := range t.Ovr {
+		m.Ovr[pr] = pp
+	}
+	for pr, pp := range t.Constraints {
+		if !directDeps[pr] {
+			continue
+		}
+		if have, ok := m.Constraints[pr]; ok {
+			if pp.Source == "" {
+				pp.Source = have.Source
+			}
+			if have.Constraint != nil && !gps.IsAny(have.Constraint) {
+				pp.Constraint = have.Constraint
+			}
+		}
+		m.Constraints[pr] = pp
+		if md := t.ConstraintMetadata[pr]; len(md) > 0 {
+			m.ConstraintMetadata[pr] = md
+		}
+	}
+	for pr, gv := range t.GoVersions {
+		if _, ovr := t.Ovr[pr]; directDeps[pr] || ovr {
+			if m.GoVersions == nil {
+				m.GoVersions = make(map[gps.ProjectRoot]string)
+			}
+			m.GoVersions[pr] = gv
+		}
+	}
+
+	if len(t.Metadata) > 0 && m.Metadata == nil {
+		m.Metadata = make(map[string]string, len(t.Metadata))
+	}
+	for k, v := range t.Metadata {
+		m.Metadata[k] = v
+	}
+}
+
+// mergeStrings returns the strings in a, followed by those in b that are not in
+// a.
+func mergeStrings(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, s := range a {
+		seen[s] = true
+	}
+	for _, s := range b {
+		if !seen[s] {
+			seen[s] = true
+			a = append(a, s)
+		}
+	}
+	return a
+}
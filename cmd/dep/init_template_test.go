@@ -0,0 +1,119 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+const testInitTemplate = `
+ignored = ["corp.example.com/internal/generated"]
+noverify = ["github.com/patched/lib"]
+go-sum = true
+
+[metadata]
+  policy = "https://corp.example.com/policies/deps"
+
+[[constraint]]
+  name = "github.com/foo/bar"
+  source = "https://mirror.corp.example.com/foo/bar"
+
+[[constraint]]
+  name = "github.com/foo/baz"
+  version = "1.2.0"
+
+[[constraint]]
+  name = "github.com/unused/dep"
+  version = "1.0.0"
+
+[[override]]
+  name = "github.com/transitive/dep"
+  source = "https://mirror.corp.example.com/transitive/dep"
+
+[prune]
+  go-tests = true
+  non-go = true
+`
+
+func TestReadInitTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-init-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := &dep.Ctx{WorkingDir: dir, Cachedir: dir, Err: log.New(ioutil.Discard, "", 0)}
+	if err := os.MkdirAll(filepath.Join(dir, initTemplateDir), 0777); err != nil {
+		t.Fatal(err)
+	}
+	named := filepath.Join(dir, initTemplateDir, "corp.toml")
+	if err := ioutil.WriteFile(named, []byte(testInitTemplate), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "local.toml"), []byte(testInitTemplate), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, arg := range []string{"corp", "local.toml", filepath.Join(".", "local.toml"), named} {
+		if _, err := readInitTemplate(ctx, arg); err != nil {
+			t.Errorf("expected template %s to be read, got %v", arg, err)
+		}
+	}
+	if _, err := readInitTemplate(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "no template named missing") {
+		t.Errorf("expected an error naming the missing template, got %v", err)
+	}
+}
+
+func TestApplyInitTemplate(t *testing.T) {
+	tmpl, _, err := dep.ReadManifest(strings.NewReader(testInitTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := dep.NewManifest()
+	m.Ignored = []string{"github.com/user/project/tools"}
+	m.PruneOptions.DefaultOptions = gps.PruneNestedVendorDirs | gps.PruneGoTestFiles | gps.PruneUnusedPackages
+	m.Constraints["github.com/foo/bar"] = gps.ProjectProperties{Constraint: gps.NewBranch("master")}
+	m.Constraints["github.com/foo/baz"] = gps.ProjectProperties{Constraint: gps.Any()}
+	directDeps := map[gps.ProjectRoot]bool{"github.com/foo/bar": true, "github.com/foo/baz": true}
+
+	applyInitTemplate(m, tmpl, directDeps)
+
+	if want := []string{"github.com/user/project/tools", "corp.example.com/internal/generated"}; !reflect.DeepEqual(m.Ignored, want) {
+		t.Errorf("expected ignored %v, got %v", want, m.Ignored)
+	}
+	if !m.GoSum || len(m.NoVerify) != 1 {
+		t.Errorf("expected the template's go-sum and noverify, got %v and %v", m.GoSum, m.NoVerify)
+	}
+	if m.PruneOptions.DefaultOptions != tmpl.PruneOptions.DefaultOptions {
+		t.Errorf("expected the template's prune options, got %v", m.PruneOptions.DefaultOptions)
+	}
+	if m.Metadata["policy"] != "https://corp.example.com/policies/deps" {
+		t.Errorf("expected the template's policy reference, got %v", m.Metadata)
+	}
+
+	bar := m.Constraints["github.com/foo/bar"]
+	if bar.Source != "https://mirror.corp.example.com/foo/bar" || bar.Constraint.String() != "master" {
+		t.Errorf("expected the inferred branch from the template's mirror, got %s from %s", bar.Constraint, bar.Source)
+	}
+	if baz := m.Constraints["github.com/foo/baz"]; gps.IsAny(baz.Constraint) {
+		t.Error("expected the template's version where none was inferred")
+	}
+	if _, has := m.Constraints["github.com/unused/dep"]; has {
+		t.Error("expected no constraint on a project that is not a direct dependency")
+	}
+	if ovr := m.Ovr["github.com/transitive/dep"]; ovr.Source == "" {
+		t.Error("expected the template's overrides to be kept")
+	}
+}
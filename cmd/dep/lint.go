@@ -0,0 +1,351 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/manifestedit"
+	"github.com/pkg/errors"
+)
+
+const lintShortHelp = `Report common mistakes in Gopkg.toml`
+const lintLongHelp = `
+Lint reports common mistakes in Gopkg.toml, each along with the fix, if there
+is one that can be made without guessing at what was intended:
+
+  subpackage-rule     a [[constraint]] or [[override]] names a package within
+                      a project, rather than its root; fixed by renaming it
+                      to the root
+  shadowed-constraint a [[constraint]] has no effect, as there is also an
+                      [[override]] for the project; fixed by removing the
+                      constraint
+  ignored-required    a package given in required is also ignored, as dep
+                      refuses to solve; fixed by removing the ignored entries
+                      that match it
+  unreachable-source  the source of a [[constraint]] or [[override]] cannot be
+                      reached, nor is it in the cache; not fixed
+  unconstrained-direct
+                      a project that Gopkg.lock records as a direct
+                      dependency has neither a [[constraint]] nor an
+                      [[override]], so that dep ensure -update may move it
+                      to any version; not fixed
+
+With -fix, the fixes are made to Gopkg.toml, keeping its formatting, and the
+comments of all but renamed rules, and only the problems without a fix are
+reported. With -json, the problems are printed as a JSON array, for editors
+and other tools.
+
+Lint exits non-zero if any problem is reported.
+`
+
+func (cmd *lintCommand) Name() string      { return "lint" }
+func (cmd *lintCommand) Args() string      { return "[-fix] [-json]" }
+func (cmd *lintCommand) ShortHelp() string { return lintShortHelp }
+func (cmd *lintCommand) LongHelp() string  { return lintLongHelp }
+func (cmd *lintCommand) Hidden() bool      { return false }
+
+func (cmd *lintCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.fix, "fix", false, "fix the problems that have a fix in Gopkg.toml, and only report the others")
+	fs.BoolVar(&cmd.json, "json", false, "print the problems as a JSON array")
+}
+
+type lintCommand struct {
+	fix  bool
+	json bool
+}
+
+// The checks of lint, as named in lintProblem.Check.
+const (
+	lintSubpackageRule      = "subpackage-rule"
+	lintShadowedConstraint  = "shadowed-constraint"
+	lintIgnoredRequired     = "ignored-required"
+	lintUnreachableSource   = "unreachable-source"
+	lintUnconstrainedDirect = "unconstrained-direct"
+)
+
+// lintProblem is a mistake found in a manifest by one of lint's checks.
+type lintProblem struct {
+	Check   string `json:"check"`
+	Project string `json:"project,omitempty"`
+	Message string `json:"message"`
+	// Fix describes the edit that fixes the problem, or is empty if there is
+	// none; fix makes it.
+	Fix string `json:"fix,omitempty"`
+	fix func(*manifestedit.File) error
+}
+
+func (lp lintProblem) String() string {
+	s := fmt.Sprintf("%s: %s (%s)", dep.ManifestName, lp.Message, lp.Check)
+	if lp.Fix != "" {
+		s += "\n    fix: " + lp.Fix
+	}
+	return s
+}
+
+func (cmd *lintCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	mp, err := ctx.ManifestPath()
+	if err != nil {
+		return err
+	}
+	f, err := manifestedit.ReadFile(mp)
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	l, err := readSiblingLock(mp)
+	if err != nil {
+		return err
+	}
+
+	problems := lintManifest(f.Manifest(), l, sm)
+
+	if cmd.fix {
+		var unfixed []lintProblem
+		fixed := 0
+		for _, lp := range problems {
+			if lp.fix == nil {
+				unfixed = append(unfixed, lp)
+				continue
+			}
+			if err := lp.fix(f); err != nil {
+				ctx.Err.Printf("Warning: could not fix %s: %v\n", lp.Message, err)
+				lp.Fix = ""
+				unfixed = append(unfixed, lp)
+				continue
+			}
+			fixed++
+		}
+		if fixed > 0 {
+			if err := f.WriteFile(mp); err != nil {
+				return errors.Wrapf(err, "writing %s failed", dep.ManifestName)
+			}
+			if ctx.Verbose {
+				ctx.Err.Printf("Fixed %d problem(s) in %s\n", fixed, mp)
+			}
+		}
+		problems = unfixed
+	}
+
+	if cmd.json {
+		if problems == nil {
+			problems = []lintProblem{}
+		}
+		out, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return err
+		}
+		ctx.Out.Println(string(out))
+	} else {
+		for _, lp := range problems {
+			ctx.Out.Println(lp)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("found %d problem(s) in %s", len(problems), dep.ManifestName)
+	}
+	return nil
+}
+
+// readSiblingLock reads the lock next to the manifest at mp, returning nil if
+// there is none.
+func readSiblingLock(mp string) (*dep.Lock, error) {
+	lf, err := os.Open(filepath.Join(filepath.Dir(mp), dep.LockName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer lf.Close()
+
+	l, err := dep.ReadLock(lf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while parsing %s", dep.LockName)
+	}
+	return l, nil
+}
+
+// lintManifest runs each of lint's checks on m, returning the problems found
+// in the order the checks are listed in the help, and by project within each.
+// l is the lock of the project, or nil if it has none, in which case the checks
+// that need it are skipped.
+func lintManifest(m *dep.Manifest, l *dep.Lock, sm gps.SourceManager) []lintProblem {
+	var problems []lintProblem
+	problems = append(problems, lintSubpackageRules(m, sm)...)
+	problems = append(problems, lintShadowedConstraints(m)...)
+	problems = append(problems, lintIgnoredRequiredPackages(m)...)
+	problems = append(problems, lintSources(m, sm)...)
+	problems = append(problems, lintUnconstrainedDirectDeps(m, l)...)
+	return problems
+}
+
+// ruleNames returns the sorted names of the projects with a [[constraint]] or
+// [[override]] in m, each once.
+func ruleNames(m *dep.Manifest) []gps.ProjectRoot {
+	seen := make(map[gps.ProjectRoot]bool)
+	var names []gps.ProjectRoot
+	for _, rules := range []gps.ProjectConstraints{m.Constraints, m.Ovr} {
+		for pr := range rules {
+			if !seen[pr] {
+				seen[pr] = true
+				names = append(names, pr)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func lintSubpackageRules(m *dep.Manifest, sm gps.SourceManager) []lintProblem {
+	var problems []lintProblem
+	for _, name := range ruleNames(m) {
+		root, err := sm.DeduceProjectRoot(string(name))
+		if err != nil || root == name {
+			// Names that cannot be deduced at all fail dep ensure plainly.
+			continue
+		}
+		from, to := string(name), string(root)
+		problems = append(problems, lintProblem{
+			Check:   lintSubpackageRule,
+			Project: from,
+			Message: fmt.Sprintf("the rules on %s name a package within %s, rather than the root of its project", from, to),
+			Fix:     fmt.Sprintf("rename %s to %s", from, to),
+			fix:     func(f *manifestedit.File) error { return f.MoveRules(from, to) },
+		})
+	}
+	return problems
+}
+
+func lintShadowedConstraints(m *dep.Manifest) []lintProblem {
+	var problems []lintProblem
+	for _, name := range ruleNames(m) {
+		_, hasConstraint := m.Constraints[name]
+		_, hasOverride := m.Ovr[name]
+		if !hasConstraint || !hasOverride {
+			continue
+		}
+		pr := string(name)
+		problems = append(problems, lintProblem{
+			Check:   lintShadowedConstraint,
+			Project: pr,
+			Message: fmt.Sprintf("the [[constraint]] on %s has no effect, as there is an [[override]] for it", pr),
+			Fix:     fmt.Sprintf("remove the [[constraint]] on %s", pr),
+			fix: func(f *manifestedit.File) error {
+				f.RemoveConstraint(pr)
+				return nil
+			},
+		})
+	}
+	return problems
+}
+
+func lintIgnoredRequiredPackages(m *dep.Manifest) []lintProblem {
+	var reqs []string
+	for req := range m.RequiredPackages() {
+		reqs = append(reqs, req)
+	}
+	sort.Strings(reqs)
+
+	var problems []lintProblem
+	for _, req := range reqs {
+		var matching []string
+		for _, ig := range m.Ignored {
+			if pkgtree.NewIgnoredRuleset([]string{ig}).IsIgnored(req) {
+				matching = append(matching, ig)
+			}
+		}
+		if len(matching) == 0 {
+			continue
+		}
+		problems = append(problems, lintProblem{
+			Check:   lintIgnoredRequired,
+			Project: req,
+			Message: fmt.Sprintf("%s is required, but also ignored by %s", req, strings.Join(matching, ", ")),
+			Fix:     fmt.Sprintf("remove %s from ignored", strings.Join(matching, ", ")),
+			fix: func(f *manifestedit.File) error {
+				f.RemoveIgnored(matching...)
+				return nil
+			},
+		})
+	}
+	return problems
+}
+
+func lintUnconstrainedDirectDeps(m *dep.Manifest, l *dep.Lock) []lintProblem {
+	if l == nil {
+		return nil
+	}
+	direct := l.DirectDependencies()
+
+	var problems []lintProblem
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		if !direct[pr] {
+			continue
+		}
+		if _, has := m.Constraints[pr]; has {
+			continue
+		}
+		if _, has := m.Ovr[pr]; has {
+			continue
+		}
+		problems = append(problems, lintProblem{
+			Check:   lintUnconstrainedDirect,
+			Project: string(pr),
+			Message: fmt.Sprintf("%s is a direct dependency, but has no [[constraint]] or [[override]]", pr),
+		})
+	}
+	return problems
+}
+
+func lintSources(m *dep.Manifest, sm gps.SourceManager) []lintProblem {
+	var problems []lintProblem
+	for _, name := range ruleNames(m) {
+		for _, rules := range []gps.ProjectConstraints{m.Constraints, m.Ovr} {
+			pp, has := rules[name]
+			if !has || pp.Source == "" {
+				continue
+			}
+			exists, err := sm.SourceExists(gps.ProjectIdentifier{ProjectRoot: name, Source: pp.Source})
+			if err == nil && exists {
+				continue
+			}
+			msg := fmt.Sprintf("the source of %s, %s, cannot be reached", name, pp.Source)
+			if err != nil {
+				msg += ": " + err.Error()
+			}
+			problems = append(problems, lintProblem{
+				Check:   lintUnreachableSource,
+				Project: string(name),
+				Message: msg,
+			})
+			// The same source need not be reported for both rules.
+			break
+		}
+	}
+	return problems
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/manifestedit"
+)
+
+// lintSM deduces the first two path elements after the host as the root of a
+// project, and reaches no source but github.com/foo/fork.
+type lintSM struct {
+	gps.SourceManager
+}
+
+func (lintSM) DeduceProjectRoot(ip string) (gps.ProjectRoot, error) {
+	parts := strings.Split(ip, "/")
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return gps.ProjectRoot(strings.Join(parts, "/")), nil
+}
+
+func (lintSM) SourceExists(id gps.ProjectIdentifier) (bool, error) {
+	return id.Source == "github.com/foo/fork", nil
+}
+
+const lintManifestText = `required = ["github.com/foo/tool/cmd/tool"]
+ignored = ["github.com/foo/tool*", "github.com/foo/other"]
+
+# Pinned for the v1 API.
+[[constraint]]
+  name = "github.com/foo/bar/sub"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/foo/baz"
+  version = "2.0.0"
+
+[[override]]
+  name = "github.com/foo/baz"
+  version = "2.1.0"
+  source = "github.com/foo/fork"
+
+[[constraint]]
+  name = "github.com/foo/gone"
+  source = "github.com/foo/gone-fork"
+`
+
+func TestLintManifest(t *testing.T) {
+	f, err := manifestedit.Parse([]byte(lintManifestText))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := lintManifest(f.Manifest(), nil, lintSM{})
+	var checks []string
+	for _, lp := range problems {
+		checks = append(checks, lp.Check+" "+lp.Project)
+	}
+	want := []string{
+		"subpackage-rule github.com/foo/bar/sub",
+		"shadowed-constraint github.com/foo/baz",
+		"ignored-required github.com/foo/tool/cmd/tool",
+		"unreachable-source github.com/foo/gone",
+	}
+	if strings.Join(checks, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(checks, "\n"))
+	}
+	if problems[3].fix != nil {
+		t.Error("expected no fix for an unreachable source")
+	}
+
+	for _, lp := range problems {
+		if lp.fix == nil {
+			continue
+		}
+		if err := lp.fix(f); err != nil {
+			t.Fatalf("fixing %s: %v", lp.Check, err)
+		}
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := string(out)
+	for _, s := range []string{`name = "github.com/foo/bar"`, `ignored = ["github.com/foo/other"]`} {
+		if !strings.Contains(fixed, s) {
+			t.Errorf("expected %q in the fixed manifest:\n%s", s, fixed)
+		}
+	}
+	if strings.Count(fixed, `name = "github.com/foo/baz"`) != 1 {
+		t.Errorf("expected only the override on github.com/foo/baz to be left:\n%s", fixed)
+	}
+
+	if problems := lintManifest(f.Manifest(), nil, lintSM{}); len(problems) != 1 || problems[0].Check != lintUnreachableSource {
+		t.Errorf("expected only the unreachable source to be left after fixing, got %v", problems)
+	}
+}
+
+func TestLintUnconstrainedDirectDeps(t *testing.T) {
+	f, err := manifestedit.Parse([]byte(lintManifestText))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := func(pr string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr)}, gps.Revision("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"), []string{"."})
+	}
+	l := &dep.Lock{
+		SolveMeta: dep.SolveMeta{InputImports: []string{"github.com/foo/baz", "github.com/foo/loose/sub", "github.com/foo/tool/cmd/tool"}},
+		P:         []gps.LockedProject{locked("github.com/foo/baz"), locked("github.com/foo/loose"), locked("github.com/foo/tool"), locked("github.com/foo/transitive")},
+	}
+
+	var got []string
+	for _, lp := range lintUnconstrainedDirectDeps(f.Manifest(), l) {
+		got = append(got, lp.Project)
+	}
+	want := []string{"github.com/foo/loose", "github.com/foo/tool"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected unconstrained direct dependencies:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	if problems := lintUnconstrainedDirectDeps(f.Manifest(), nil); problems != nil {
+		t.Errorf("expected no problems without a lock, got %v", problems)
+	}
+}
@@ -29,6 +29,13 @@ var (
 	errorExitCode   = 1
 )
 
+// An exitCoder is an error returned by a command that calls for an exit code
+// of its own, in place of errorExitCode.
+type exitCoder interface {
+	error
+	ExitCode() int
+}
+
 type command interface {
 	Name() string           // "foobar"
 	Args() string           // "<baz> [quux...]"
@@ -182,22 +189,91 @@ func (c *Config) Run() int {
 				}
 			}
 
+			vcsPolicy, err := vcsPolicyFromEnv(c.Env)
+			if err != nil {
+				errLogger.Printf("dep: %v\n", err)
+				return errorExitCode
+			}
+
+			protocols, err := protocolsFromEnv(c.Env)
+			if err != nil {
+				errLogger.Printf("dep: %v\n", err)
+				return errorExitCode
+			}
+
+			proxies, err := proxiesFromEnv(c.Env)
+			if err != nil {
+				errLogger.Printf("dep: %v\n", err)
+				return errorExitCode
+			}
+
+			auth, err := authFromEnv(c.Env)
+			if err != nil {
+				errLogger.Printf("dep: %v\n", err)
+				return errorExitCode
+			}
+
+			namespaces, err := namespacesFromEnv(c.Env)
+			if err != nil {
+				errLogger.Printf("dep: %v\n", err)
+				return errorExitCode
+			}
+
+			sourceTemplates, err := sourceTemplatesFromEnv(c.Env)
+			if err != nil {
+				errLogger.Printf("dep: %v\n", err)
+				return errorExitCode
+			}
+
+			licensePolicy, err := licensePolicyFromEnv(c.Env)
+			if err != nil {
+				errLogger.Printf("dep: %v\n", err)
+				return errorExitCode
+			}
+
 			// Set up dep context.
 			ctx := &dep.Ctx{
-				Out:            outLogger,
-				Err:            errLogger,
-				Verbose:        *verbose,
-				DisableLocking: getEnv(c.Env, "DEPNOLOCK") != "",
-				Cachedir:       cachedir,
-				CacheAge:       cacheAge,
+				Out:              outLogger,
+				Err:              errLogger,
+				Verbose:          *verbose,
+				DisableLocking:   getEnv(c.Env, "DEPNOLOCK") != "",
+				LeaseLocking:     getEnv(c.Env, "DEPLEASELOCK") != "",
+				ResumableFetches: getEnv(c.Env, "DEPRESUMABLEFETCH") != "",
+				Cachedir:         cachedir,
+				CacheAge:         cacheAge,
+				CompressCache:    getEnv(c.Env, "DEPCACHECOMPRESS") != "",
+				VCSPolicy:        vcsPolicy,
+				BundleDir:        getEnv(c.Env, "DEPBUNDLEDIR"),
+				Advisories:       getEnv(c.Env, "DEPADVISORIES"),
+				Keyring:          getEnv(c.Env, "DEPKEYRING"),
+				NetworkPolicy:    networkPolicyFromEnv(c.Env),
+				Protocols:        protocols,
+				Proxies:          proxies,
+				Auth:             auth,
+				Namespaces:       namespaces,
+				SourceTemplates:  sourceTemplates,
+				LicensePolicy:    licensePolicy,
+				BuildContext:     buildContextFromEnv(c.Env),
 			}
 
 			GOPATHS := filepath.SplitList(getEnv(c.Env, "GOPATH"))
 			ctx.SetPaths(c.WorkingDir, GOPATHS...)
 
+			if addr := getEnv(c.Env, "DEPMETRICSADDR"); addr != "" {
+				stop, err := serveMetrics(addr)
+				if err != nil {
+					errLogger.Printf("dep: failed to serve metrics on $DEPMETRICSADDR %q: %v\n", addr, err)
+					return errorExitCode
+				}
+				defer stop()
+			}
+
 			// Run the command with the post-flag-processing args.
 			if err := cmd.Run(ctx, flags.Args()); err != nil {
 				errLogger.Printf("%v\n", err)
+				if ec, ok := err.(exitCoder); ok {
+					return ec.ExitCode()
+				}
 				return errorExitCode
 			}
 
@@ -220,7 +296,24 @@ func commandList() []command {
 		&initCommand{},
 		&statusCommand{},
 		&ensureCommand{},
+		&checkCommand{},
+		&fmtCommand{},
+		&lintCommand{},
+		&inferConstraintsCommand{},
 		&pruneCommand{},
+		&generateVersionInfoCommand{},
+		&exportCommand{},
+		&graphCommand{},
+		&daemonCommand{},
+		&serveCacheCommand{},
+		&cacheCommand{},
+		&resolveLockCommand{},
+		&mergeDriverCommand{},
+		&diffAgainstCommand{},
+		&recoverLockCommand{},
+		&bundleSourcesCommand{},
+		&snapshotCommand{},
+		&completionCommand{},
 		&versionCommand{},
 	}
 }
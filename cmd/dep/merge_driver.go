@@ -0,0 +1,200 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const mergeDriverShortHelp = `Merge Gopkg.lock as a git merge driver`
+const mergeDriverLongHelp = `
+Merge the three versions of Gopkg.lock given by git when it is registered as
+a merge driver: the common ancestor, ours, and theirs, writing the result over
+ours. The locks are merged as by dep resolve-lock; the projects that the two
+sides lock differently are solved for again against the project's manifest
+and code, and the digests of their new versions recorded. vendor/ is not
+touched; run dep ensure -vendor-only after the merge.
+
+If the locks cannot be merged this way, such as when Gopkg.toml is in
+conflict too, the usual conflict markers are written instead, for dep
+resolve-lock or a manual resolution, and the merge is reported as conflicted.
+
+With -install, register the driver for the project's Gopkg.lock: it is
+declared in the git configuration of the repository, and assigned to
+Gopkg.lock in the .gitattributes file next to it. The driver is invoked as:
+
+  dep merge-driver %O %A %B %P
+`
+
+func (cmd *mergeDriverCommand) Name() string      { return "merge-driver" }
+func (cmd *mergeDriverCommand) Args() string      { return "[-install] | base ours theirs [path]" }
+func (cmd *mergeDriverCommand) ShortHelp() string { return mergeDriverShortHelp }
+func (cmd *mergeDriverCommand) LongHelp() string  { return mergeDriverLongHelp }
+func (cmd *mergeDriverCommand) Hidden() bool      { return false }
+
+func (cmd *mergeDriverCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.install, "install", false, "register dep as the git merge driver for the project's Gopkg.lock")
+}
+
+type mergeDriverCommand struct {
+	install bool
+}
+
+// mergeDriverAttribute assigns the merge driver to the lock in .gitattributes.
+const mergeDriverAttribute = dep.LockName + " merge=dep"
+
+func (cmd *mergeDriverCommand) Run(ctx *dep.Ctx, args []string) error {
+	if cmd.install {
+		if len(args) > 0 {
+			return errors.New("-install takes no arguments")
+		}
+		return installMergeDriver(ctx)
+	}
+	if len(args) < 3 || len(args) > 4 {
+		return errors.New("expected the ancestor, our and their versions of the lock, and optionally its path")
+	}
+
+	base, ours, theirs, path := args[0], args[1], args[2], dep.LockName
+	if len(args) == 4 {
+		path = args[3]
+		// git runs the driver from the top of the work tree.
+		ctx.WorkingDir = filepath.Join(ctx.WorkingDir, filepath.Dir(filepath.FromSlash(path)))
+	}
+
+	err := mergeLockFiles(ctx, base, ours, theirs)
+	if err == nil {
+		return nil
+	}
+	ctx.Err.Printf("dep: unable to merge %s automatically: %s\n", path, err)
+
+	// Leave the conflicts to be resolved by hand instead.
+	mf := exec.Command("git", "merge-file", "--diff3", "-L", "ours", "-L", "base", "-L", "theirs", ours, base, theirs)
+	if out, ferr := mf.CombinedOutput(); ferr != nil {
+		if _, conflicted := ferr.(*exec.ExitError); !conflicted {
+			return errors.Wrapf(ferr, "git merge-file failed: %s", out)
+		}
+	}
+	return errors.Errorf("left the conflicts in %s to be resolved; dep resolve-lock may resolve them once the rest of the merge is done", path)
+}
+
+// mergeLockFiles merges the locks in the files base, ours and theirs, writing
+// the result over ours. The base file is empty if the lock did not exist in
+// the common ancestor.
+func mergeLockFiles(ctx *dep.Ctx, base, ours, theirs string) error {
+	read := func(path string) (*dep.Lock, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil || len(bytes.TrimSpace(data)) == 0 {
+			return nil, err
+		}
+		l, err := dep.ReadLock(bytes.NewReader(data))
+		return l, errors.Wrapf(err, "unable to read %s", path)
+	}
+
+	bl, err := read(base)
+	if err != nil {
+		return err
+	}
+	ol, err := read(ours)
+	if err != nil {
+		return err
+	}
+	tl, err := read(theirs)
+	if err != nil {
+		return err
+	}
+	if ol == nil || tl == nil {
+		return errors.New("one side of the merge has an empty lock")
+	}
+
+	merged, conflicts := dep.MergeLocks(bl, ol, tl)
+	if len(conflicts) == 0 {
+		return dep.WriteLockFile(ours, merged)
+	}
+
+	p, err := ctx.LoadProjectWithoutLock()
+	if err != nil {
+		return err
+	}
+	p.Lock = merged
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	lock, err := solveMergedLock(ctx, p, sm, merged, conflicts)
+	if err != nil {
+		return err
+	}
+	if err := dep.FillDigests(lock, sm, p.Manifest.PruneOptions); err != nil {
+		return err
+	}
+	reportResolvedConflicts(ctx.Err, conflicts, ol, tl, lock)
+	return dep.WriteLockFile(ours, lock)
+}
+
+// installMergeDriver declares the merge driver in the git configuration of the
+// repository holding the project, and assigns it to the project's lock in the
+// .gitattributes file at the project's root.
+func installMergeDriver(ctx *dep.Ctx) error {
+	p, err := ctx.LoadProjectWithoutLock()
+	if err != nil {
+		return err
+	}
+
+	for _, kv := range [][2]string{
+		{"merge.dep.name", "dep Gopkg.lock merge driver"},
+		{"merge.dep.driver", "dep merge-driver %O %A %B %P"},
+	} {
+		gc := exec.Command("git", "config", kv[0], kv[1])
+		gc.Dir = p.AbsRoot
+		if out, err := gc.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "git config %s failed: %s", kv[0], out)
+		}
+	}
+
+	added, err := addGitAttribute(filepath.Join(p.AbsRoot, ".gitattributes"), mergeDriverAttribute)
+	if err != nil {
+		return err
+	}
+	if added {
+		ctx.Out.Printf("Registered the merge driver for %s; commit .gitattributes to share the assignment.\n", dep.LockName)
+	} else {
+		ctx.Out.Printf("Registered the merge driver for %s.\n", dep.LockName)
+	}
+	return nil
+}
+
+// addGitAttribute appends line to the .gitattributes file at path, creating it
+// as needed, unless it already holds the line. It reports whether it was
+// added.
+func addGitAttribute(path, line string) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if strings.Join(strings.Fields(l), " ") == line {
+			return false, nil
+		}
+	}
+
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, line+"\n"...)
+	return true, errors.Wrapf(ioutil.WriteFile(path, data, 0666), "failed to write %s", path)
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep"
+)
+
+const mergeDriverLock = `[[projects]]
+  digest = "1:0deddd5c9bab3432ba6a2b1e5c4c44bb6bf3b2c8b176c5fe41cdf2c14b549b4f"
+  name = "github.com/foo/bar"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "%s"
+  version = "%s"
+
+[[projects]]
+  digest = "1:0deddd5c9bab3432ba6a2b1e5c4c44bb6bf3b2c8b176c5fe41cdf2c14b549b4f"
+  name = "github.com/foo/baz"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "%s"
+  version = "%s"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  input-imports = ["github.com/foo/bar", "github.com/foo/baz"]
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`
+
+func TestMergeLockFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-merge-driver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, revs ...interface{}) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(mergeDriverLock, revs...)), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// Each side updates a different project.
+	base := write("base", "aaa", "v1.0.0", "ccc", "v1.0.0")
+	ours := write("ours", "bbb", "v1.1.0", "ccc", "v1.0.0")
+	theirs := write("theirs", "aaa", "v1.0.0", "ddd", "v1.1.0")
+
+	if err := mergeLockFiles(&dep.Ctx{}, base, ours, theirs); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(ours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l, err := dep.ReadLock(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"github.com/foo/bar": "v1.1.0", "github.com/foo/baz": "v1.1.0"}
+	for _, lp := range l.Projects() {
+		if got := lp.Version().String(); got != want[string(lp.Ident().ProjectRoot)] {
+			t.Errorf("expected %s to be merged at %s, got %s", lp.Ident().ProjectRoot, want[string(lp.Ident().ProjectRoot)], got)
+		}
+	}
+	if len(l.Projects()) != len(want) {
+		t.Errorf("expected %d projects in the merged lock, got %d", len(want), len(l.Projects()))
+	}
+}
+
+func TestAddGitAttribute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-gitattributes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".gitattributes")
+	if err := ioutil.WriteFile(path, []byte("*.go text"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for i, wantAdded := range []bool{true, false} {
+		added, err := addGitAttribute(path, mergeDriverAttribute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != wantAdded {
+			t.Errorf("call %d: expected added to be %v", i, wantAdded)
+		}
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "*.go text\nGopkg.lock merge=dep\n"; string(got) != want {
+		t.Errorf("expected .gitattributes to hold %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+
+	"github.com/golang/dep/gps"
+)
+
+// serveMetrics starts serving the counters collected by gps on addr, both as
+// expvars at /debug/vars and in the Prometheus text format at /metrics. The
+// returned function stops the server and collection.
+func serveMetrics(addr string) (func(), error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := gps.NewExpvarMetrics("dep")
+	gps.SetMetrics(metrics)
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", metrics)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+
+	return func() {
+		gps.SetMetrics(nil)
+		srv.Close()
+	}, nil
+}
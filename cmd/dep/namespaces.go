@@ -0,0 +1,35 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// namespacesFromEnv builds the sources of the projects under private import
+// path prefixes from $DEPNAMESPACES, a comma-separated list of pattern=source
+// pairs, as described by gps.Namespaces.
+func namespacesFromEnv(env []string) (gps.Namespaces, error) {
+	v := getEnv(env, "DEPNAMESPACES")
+	if v == "" {
+		return nil, nil
+	}
+
+	n := make(gps.Namespaces)
+	for _, pair := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, errors.Errorf("failed to parse $DEPNAMESPACES: %q is not of the form pattern=source", pair)
+		}
+		n[kv[0]] = kv[1]
+	}
+	if err := n.Validate(); err != nil {
+		return nil, errors.Wrap(err, "failed to parse $DEPNAMESPACES")
+	}
+	return n, nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestNamespacesFromEnv(t *testing.T) {
+	n, err := namespacesFromEnv([]string{"DEPNAMESPACES=corp.example.com/{team}/{repo}=ssh://git@git.corp.example.com/{team}/{repo}.git, go.corp.example.com/{repo}=git.corp.example.com/go/{repo}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gps.Namespaces{
+		"corp.example.com/{team}/{repo}": "ssh://git@git.corp.example.com/{team}/{repo}.git",
+		"go.corp.example.com/{repo}":     "git.corp.example.com/go/{repo}",
+	}
+	if !reflect.DeepEqual(n, want) {
+		t.Errorf("expected %v, got %v", want, n)
+	}
+
+	if n, err := namespacesFromEnv(nil); err != nil || n != nil {
+		t.Errorf("expected no namespaces without the variable, got %v, %v", n, err)
+	}
+	for _, v := range []string{"corp.example.com", "corp.example.com/{repo}=", "corp.example.com/repo=https://git.corp.example.com/repo"} {
+		if _, err := namespacesFromEnv([]string{"DEPNAMESPACES=" + v}); err == nil {
+			t.Errorf("expected %q to fail to parse", v)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// perfSteps are the steps broken down by a performance report, in the order
+// they are reported, with the kind of the Event that ends each.
+var perfSteps = []struct {
+	name, kind string
+}{
+	{"deduction", gps.EventPathDeduced},
+	{"fetching", gps.EventSourceFetched},
+	{"listing versions", gps.EventVersionsListed},
+	{"solving", gps.EventSolveFinished},
+	{"vendoring", gps.EventVendorWritten},
+	{"hashing", gps.EventTreeHashed},
+}
+
+// perfFlags are the flags of the commands that can report where their time
+// was spent.
+type perfFlags struct {
+	report  bool
+	profile string
+}
+
+func (pf *perfFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&pf.report, "perf-report", false, "when done, print how long was spent deducing, fetching, listing versions, solving, vendoring and hashing")
+	fs.StringVar(&pf.profile, "perf-profile", "", "write a CPU profile, for go tool pprof, to this `file`")
+}
+
+// start begins collecting what was asked for by pf. The returned function
+// stops collecting, and prints the report, if any; it must be called once the
+// command is done.
+func (pf *perfFlags) start(ctx *dep.Ctx) (func(), error) {
+	var stops []func()
+	if pf.profile != "" {
+		f, err := os.Create(pf.profile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the CPU profile")
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "failed to start the CPU profile")
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			if err := f.Close(); err != nil {
+				ctx.Err.Printf("Warning: failed to write the CPU profile: %v\n", err)
+			}
+		})
+	}
+	if pf.report {
+		pr := newPerfReport()
+		gps.SetEventListener(pr)
+		stops = append(stops, func() {
+			gps.SetEventListener(nil)
+			ctx.Err.Print(pr.format(time.Since(pr.start)))
+		})
+	}
+
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}, nil
+}
+
+// perfStep is the time spent in one of perfSteps.
+type perfStep struct {
+	count  int
+	failed int
+	total  time.Duration
+}
+
+// perfReport collects the time spent in each of perfSteps from the Events sent
+// by gps and dep.
+type perfReport struct {
+	start time.Time
+	mu    sync.Mutex
+	steps map[string]*perfStep
+}
+
+func newPerfReport() *perfReport {
+	return &perfReport{start: time.Now(), steps: make(map[string]*perfStep)}
+}
+
+// HandleEvent implements gps.EventListener.
+func (pr *perfReport) HandleEvent(e gps.Event) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	s, has := pr.steps[e.Kind]
+	if !has {
+		s = new(perfStep)
+		pr.steps[e.Kind] = s
+	}
+	s.count++
+	if e.Err != nil {
+		s.failed++
+	}
+	s.total += e.Duration
+}
+
+// format renders the report as a table of steps, for a command that took
+// wall in all.
+func (pr *perfReport) format(wall time.Duration) string {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Time spent, of %s in all:\n", wall.Round(time.Millisecond))
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tCOUNT\tFAILED\tTIME")
+	for _, step := range perfSteps {
+		s, has := pr.steps[step.kind]
+		if !has {
+			s = new(perfStep)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", step.name, s.count, s.failed, s.total.Round(time.Millisecond))
+	}
+	tw.Flush()
+	buf.WriteString("Steps taken concurrently, or within one another, such as hashing while vendoring, may add up to more than the time in all.\n")
+	return buf.String()
+}
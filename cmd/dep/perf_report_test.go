@@ -0,0 +1,49 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestPerfReport(t *testing.T) {
+	pr := newPerfReport()
+	for _, e := range []gps.Event{
+		{Kind: gps.EventPathDeduced, Duration: 10 * time.Millisecond},
+		{Kind: gps.EventPathDeduced, Duration: 20 * time.Millisecond, Err: errors.New("unknown import path")},
+		{Kind: gps.EventSourceFetched, Duration: 2 * time.Second},
+		{Kind: gps.EventSolveStarted},
+		{Kind: gps.EventSolveFinished, Duration: 1500 * time.Millisecond},
+		{Kind: gps.EventProjectVendored, Duration: time.Second},
+	} {
+		pr.HandleEvent(e)
+	}
+
+	got := pr.format(3 * time.Second)
+	want := []string{
+		"Time spent, of 3s in all:",
+		"STEP              COUNT  FAILED  TIME",
+		"deduction         2      1       30ms",
+		"fetching          1      0       2s",
+		"listing versions  0      0       0s",
+		"solving           1      0       1.5s",
+		"vendoring         0      0       0s",
+		"hashing           0      0       0s",
+	}
+	lines := strings.Split(got, "\n")
+	if len(lines) < len(want) {
+		t.Fatalf("expected at least %d lines, got:\n%s", len(want), got)
+	}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d: expected %q, got %q", i, w, lines[i])
+		}
+	}
+}
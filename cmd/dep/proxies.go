@@ -0,0 +1,45 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// proxiesFromEnv builds the proxies through which hosts are reached from
+// $DEPPROXIES, a comma-separated list of host=proxy pairs, where host is a host
+// pattern and proxy a proxy URL or "direct", and from the PAC file at the path
+// in $DEPPROXYPAC.
+func proxiesFromEnv(env []string) (gps.ProxyConfig, error) {
+	var c gps.ProxyConfig
+	if v := getEnv(env, "DEPPROXIES"); v != "" {
+		c.Hosts = make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return c, errors.Errorf("failed to parse $DEPPROXIES: %q is not of the form host=proxy", pair)
+			}
+			if err := gps.ValidateProxy(kv[1]); err != nil {
+				return c, errors.Wrapf(err, "failed to parse $DEPPROXIES proxy for %s", kv[0])
+			}
+			c.Hosts[kv[0]] = kv[1]
+		}
+	}
+
+	if path := getEnv(env, "DEPPROXYPAC"); path != "" {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, errors.Wrap(err, "failed to read $DEPPROXYPAC")
+		}
+		if c.PAC, err = gps.ParsePAC(src); err != nil {
+			return c, errors.Wrapf(err, "failed to parse $DEPPROXYPAC file %s", path)
+		}
+	}
+	return c, nil
+}
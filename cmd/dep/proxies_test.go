@@ -0,0 +1,39 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestProxiesFromEnv(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempFile("proxy.pac", `function FindProxyForURL(url, host) { return "PROXY proxy.example.com:3128"; }`)
+
+	c, err := proxiesFromEnv([]string{"DEPPROXIES=github.com=http://proxy:3128, *.corp.example.com=direct", "DEPPROXYPAC=" + h.Path("proxy.pac")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"github.com": "http://proxy:3128", "*.corp.example.com": "direct"}; !reflect.DeepEqual(c.Hosts, want) {
+		t.Errorf("expected %v, got %v", want, c.Hosts)
+	}
+	if c.PAC == nil {
+		t.Error("expected the PAC file to be loaded")
+	}
+
+	if c, err := proxiesFromEnv(nil); err != nil || c.Hosts != nil || c.PAC != nil {
+		t.Errorf("expected no proxies without the variables, got %v, %v", c, err)
+	}
+	for _, v := range []string{"DEPPROXIES=github.com", "DEPPROXIES=github.com=ftp://proxy", "DEPPROXYPAC=" + filepath.Join(h.Path("."), "missing.pac")} {
+		if _, err := proxiesFromEnv([]string{v}); err == nil {
+			t.Errorf("expected %q to fail to parse", v)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/verify"
 	"github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
 )
@@ -25,18 +26,23 @@ const pruneLongHelp = `
 Prune was merged into the ensure command.
 Set prune options in the manifest and it will be applied after every ensure.
 dep prune will be removed in a future version of dep, causing this command to exit non-0.
+
+With -dry-run, the directories that would be pruned are reported, and nothing
+is removed. prune then exits with status 2 if vendor/ would change.
 `
 
 type pruneCommand struct {
+	dryRun bool
 }
 
 func (cmd *pruneCommand) Name() string      { return "prune" }
-func (cmd *pruneCommand) Args() string      { return "" }
+func (cmd *pruneCommand) Args() string      { return "[-dry-run]" }
 func (cmd *pruneCommand) ShortHelp() string { return pruneShortHelp }
 func (cmd *pruneCommand) LongHelp() string  { return pruneLongHelp }
 func (cmd *pruneCommand) Hidden() bool      { return true }
 
 func (cmd *pruneCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the directories that would be pruned")
 }
 
 func (cmd *pruneCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -52,7 +58,7 @@ func (cmd *pruneCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
-	sm, err := ctx.SourceManager()
+	sm, err := ctx.ProjectSourceManager(p)
 	if err != nil {
 		return err
 	}
@@ -79,14 +85,15 @@ func (cmd *pruneCommand) Run(ctx *dep.Ctx, args []string) error {
 	}
 
 	pruneLogger := ctx.Err
-	if !ctx.Verbose {
+	if !ctx.Verbose && !cmd.dryRun {
 		pruneLogger = log.New(ioutil.Discard, "", 0)
 	}
-	return pruneProject(p, sm, pruneLogger)
+	return pruneProject(p, sm, pruneLogger, cmd.dryRun)
 }
 
-// pruneProject removes unused packages from a project.
-func pruneProject(p *dep.Project, sm gps.SourceManager, logger *log.Logger) error {
+// pruneProject removes unused packages from a project. With dryRun, it only
+// reports them, returning errDryRunChanges if vendor/ would change.
+func pruneProject(p *dep.Project, sm gps.SourceManager, logger *log.Logger, dryRun bool) error {
 	td, err := ioutil.TempDir(os.TempDir(), "dep")
 	if err != nil {
 		return errors.Wrap(err, "error while creating temp dir for writing manifest/lock/vendor")
@@ -126,7 +133,11 @@ func pruneProject(p *dep.Project, sm gps.SourceManager, logger *log.Logger) erro
 		return err
 	}
 
-	vpath := filepath.Join(p.AbsRoot, "vendor")
+	vpath := p.VendorDir()
+	if dryRun {
+		return pruneDryRun(td, vpath)
+	}
+
 	vendorbak := vpath + ".orig"
 	var failerr error
 	if _, err := os.Stat(vpath); err == nil {
@@ -203,3 +214,24 @@ type byLen []string
 func (a byLen) Len() int           { return len(a) }
 func (a byLen) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byLen) Less(i, j int) bool { return len(a[i]) > len(a[j]) }
+
+// pruneDryRun compares the pruned tree written to td with the vendor directory
+// at vpath, returning errDryRunChanges if they differ. A missing vendor
+// directory differs from any tree.
+func pruneDryRun(td, vpath string) error {
+	if _, err := os.Stat(vpath); os.IsNotExist(err) {
+		return errDryRunChanges
+	}
+	want, err := verify.DigestFromDirectory(td)
+	if err != nil {
+		return errors.Wrap(err, "failed to digest the pruned vendor tree")
+	}
+	got, err := verify.DigestFromDirectory(vpath)
+	if err != nil {
+		return errors.Wrap(err, "failed to digest the vendor directory")
+	}
+	if got.String() != want.String() {
+		return errDryRunChanges
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const recoverLockShortHelp = `Rebuild a lost Gopkg.lock from vendor/`
+const recoverLockLongHelp = `
+Reconstruct Gopkg.lock, as best it can be, from the projects in vendor/, for
+repositories whose lock was lost or corrupted.
+
+Each project in vendor/ is matched against the versions of its source, newest
+first, by comparing the digest of its vendored code with the digest of each
+version as it would be vendored under the prune options in Gopkg.toml. Sources
+are retrieved as needed, so this can take a while, and requires network access
+for projects that are not in the cache. The first version to match is locked.
+
+Projects for which no version matches, as when their vendored code was
+modified, or the version they were vendored at was removed upstream, are
+listed and left out of the lock. Run 'dep ensure' afterwards to choose
+versions for them.
+
+An existing Gopkg.lock is not replaced unless -force is passed. With
+-dry-run, the recovered lock is printed instead of written.
+`
+
+func (cmd *recoverLockCommand) Name() string      { return "recover-lock" }
+func (cmd *recoverLockCommand) Args() string      { return "[-force] [-dry-run]" }
+func (cmd *recoverLockCommand) ShortHelp() string { return recoverLockShortHelp }
+func (cmd *recoverLockCommand) LongHelp() string  { return recoverLockLongHelp }
+func (cmd *recoverLockCommand) Hidden() bool      { return false }
+
+func (cmd *recoverLockCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.force, "force", false, "replace an existing Gopkg.lock")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "print the recovered lock instead of writing it")
+}
+
+type recoverLockCommand struct {
+	force  bool
+	dryRun bool
+}
+
+func (cmd *recoverLockCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	p, err := ctx.LoadProjectWithoutLock()
+	if err != nil {
+		return err
+	}
+
+	lp := filepath.Join(p.AbsRoot, dep.LockName)
+	if _, err := os.Stat(lp); err == nil && !cmd.force && !cmd.dryRun {
+		return errors.Errorf("%s already exists; pass -force to replace it", lp)
+	}
+	if fi, err := os.Stat(p.VendorDir()); err != nil || !fi.IsDir() {
+		return errors.Errorf("no vendor directory found at %s to recover %s from", p.VendorDir(), dep.LockName)
+	}
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	var logger *log.Logger
+	if ctx.Verbose {
+		logger = ctx.Err
+	}
+	lock, unmatched, err := dep.RecoverLock(p, sm, logger)
+	if err != nil {
+		return err
+	}
+
+	if len(unmatched) > 0 {
+		ctx.Err.Printf("No version matches the vendored code of %d project(s), which are left out of %s:\n", len(unmatched), dep.LockName)
+		for _, pr := range unmatched {
+			ctx.Err.Printf("  %s\n", pr)
+		}
+		ctx.Err.Println("Run `dep ensure` to choose versions for them.")
+	}
+
+	if cmd.dryRun {
+		b, err := lock.MarshalTOML()
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal lock to TOML")
+		}
+		ctx.Out.Print(string(b))
+		return nil
+	}
+
+	if err := dep.WriteLockFile(lp, lock); err != nil {
+		return err
+	}
+	ctx.Out.Printf("Recovered %d project(s) into %s\n", len(lock.P), dep.LockName)
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const resolveLockShortHelp = `Resolve merge conflicts in Gopkg.lock`
+const resolveLockLongHelp = `
+Resolve the merge conflicts left in Gopkg.lock by a merge, such as git merge
+or git rebase, by merging the locked projects on each side of the conflicts
+rather than their text.
+
+Projects that both sides lock the same way are kept, as are projects that
+only one side has added. If the conflicts record the lock both sides started
+from, as with git's diff3 conflict style, a project that only one side has
+changed or removed takes that change. Only the projects that the two sides
+have changed in different ways are solved for again; the rest keep their
+merged versions unless a new version of one of those projects needs them to
+change. The result is written to Gopkg.lock, and vendor/ is updated to match
+it unless -no-vendor is passed.
+`
+
+func (cmd *resolveLockCommand) Name() string      { return "resolve-lock" }
+func (cmd *resolveLockCommand) Args() string      { return "[-no-vendor] [-dry-run]" }
+func (cmd *resolveLockCommand) ShortHelp() string { return resolveLockShortHelp }
+func (cmd *resolveLockCommand) LongHelp() string  { return resolveLockLongHelp }
+func (cmd *resolveLockCommand) Hidden() bool      { return false }
+
+func (cmd *resolveLockCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.noVendor, "no-vendor", false, "write Gopkg.lock, but do not update vendor/")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+}
+
+type resolveLockCommand struct {
+	noVendor bool
+	dryRun   bool
+}
+
+func (cmd *resolveLockCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	p, err := ctx.LoadProjectWithoutLock()
+	if err != nil {
+		return err
+	}
+
+	lp := filepath.Join(p.AbsRoot, dep.LockName)
+	lf, err := os.Open(lp)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", lp)
+	}
+	defer lf.Close()
+	base, ours, theirs, err := dep.ReadConflictedLock(lf)
+	if err != nil {
+		return errors.Wrapf(err, "unable to resolve the conflicts in %s", lp)
+	}
+	if base == nil {
+		ctx.Err.Printf("Warning: the conflicts in %s do not record the lock both sides started from, so projects removed on one side are kept; use git's diff3 conflict style to record it\n", dep.LockName)
+	}
+
+	merged, conflicts := dep.MergeLocks(base, ours, theirs)
+	p.Lock = merged
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	lock, err := solveMergedLock(ctx, p, sm, merged, conflicts)
+	if err != nil {
+		return err
+	}
+
+	reportResolvedConflicts(ctx.Out, conflicts, ours, theirs, lock)
+
+	behavior := dep.VendorOnChanged
+	if cmd.noVendor {
+		behavior = dep.VendorNever
+	}
+	status, err := p.VerifyVendor()
+	if err != nil {
+		return errors.Wrap(err, "error while verifying vendor directory")
+	}
+	dw, err := dep.NewDeltaWriter(merged, lock, status, p.Manifest.PruneOptions, p.VendorDir(), behavior)
+	if err != nil {
+		return err
+	}
+	if cmd.dryRun {
+		return printDryRun(ctx, dw)
+	}
+
+	var logger *log.Logger
+	if ctx.Verbose {
+		logger = ctx.Err
+	}
+	if err := dw.Write(p.AbsRoot, sm, true, logger); err != nil {
+		return errors.WithMessage(err, "grouped write of lock and vendor")
+	}
+	return nil
+}
+
+// solveMergedLock solves for p starting from merged, the merge of the two
+// sides of a conflicted lock, allowing the conflicting projects to change.
+func solveMergedLock(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, merged *dep.Lock, conflicts []gps.ProjectRoot) (*dep.Lock, error) {
+	params := p.MakeParams()
+	if ctx.Verbose {
+		params.TraceLogger = ctx.Err
+	}
+	params.Lock = merged
+	params.ToChange = conflicts
+	params.Frozen = withoutRoots(params.Frozen, conflicts)
+
+	if err := ctx.ValidateParams(sm, params); err != nil {
+		return nil, err
+	}
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return nil, errors.Wrap(err, "prepare solver")
+	}
+	solution, err := solver.Solve(context.TODO())
+	if err != nil {
+		return nil, errors.Wrap(err, "Solving failure")
+	}
+	return dep.LockFromSolution(solution, p.Manifest.PruneOptions), nil
+}
+
+// withoutRoots returns the roots in prs that are not in remove. The projects
+// that the two sides of a conflict lock differently cannot stay frozen, as
+// one of them has to change.
+func withoutRoots(prs, remove []gps.ProjectRoot) []gps.ProjectRoot {
+	drop := make(map[gps.ProjectRoot]bool, len(remove))
+	for _, pr := range remove {
+		drop[pr] = true
+	}
+	var kept []gps.ProjectRoot
+	for _, pr := range prs {
+		if !drop[pr] {
+			kept = append(kept, pr)
+		}
+	}
+	return kept
+}
+
+// reportResolvedConflicts writes to logger the version each side of the
+// conflicts locked each of the conflicting projects at, and the version it
+// was resolved to in lock.
+func reportResolvedConflicts(logger *log.Logger, conflicts []gps.ProjectRoot, ours, theirs, lock *dep.Lock) {
+	version := func(l *dep.Lock, pr gps.ProjectRoot) string {
+		for _, lp := range l.Projects() {
+			if lp.Ident().ProjectRoot == pr {
+				return feedVersion(lp.Version())
+			}
+		}
+		return "(none)"
+	}
+	for _, pr := range conflicts {
+		logger.Printf("%s: ours %s, theirs %s, resolved to %s\n", pr, version(ours, pr), version(theirs, pr), version(lock, pr))
+	}
+	if len(conflicts) == 0 {
+		logger.Println("No project is locked differently on both sides; kept the merge of both.")
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const serveCacheShortHelp = `Serve the local source cache to other machines over HTTP`
+const serveCacheLongHelp = `
+Serve the git repositories in the local source cache over HTTP, so that other
+machines can retrieve their dependencies from this one instead of from
+upstream, such as to give a whole team a cache on the local network.
+
+Each project is served as a git repository at /<project root>.git, with the
+branches and tags of its upstream, using the smart HTTP protocol; the git
+command must be installed. Clients use the server as a mirror through
+$DEPSOURCES:
+
+  DEPSOURCES=github.com=http://10.0.0.5:8080/{{project}}.git
+
+A project missing from the cache is retrieved from upstream when it is first
+asked for; one in it is brought up to date with upstream when it was last
+updated more than -refresh ago. If upstream cannot be reached, the copy in
+the cache is served as it is. Only git sources can be served.
+
+The server runs in the foreground until interrupted. The cache is only locked
+while requests are being served, so other dep commands may need to wait for
+them to finish.
+`
+
+// serveCacheNamespace is the git namespace, within each served repository of
+// the cache, under which its upstream's branches and tags are published to
+// clients. The repositories keep the upstream's branches as remote-tracking
+// branches, which clients would not see as branches.
+const serveCacheNamespace = "dep-serve"
+
+func (cmd *serveCacheCommand) Name() string      { return "serve-cache" }
+func (cmd *serveCacheCommand) Args() string      { return "[-addr address] [-refresh duration]" }
+func (cmd *serveCacheCommand) ShortHelp() string { return serveCacheShortHelp }
+func (cmd *serveCacheCommand) LongHelp() string  { return serveCacheLongHelp }
+func (cmd *serveCacheCommand) Hidden() bool      { return false }
+
+func (cmd *serveCacheCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.addr, "addr", ":8080", "address to listen on")
+	fs.DurationVar(&cmd.refresh, "refresh", 5*time.Minute, "update a project from upstream when it was last updated longer ago than this")
+}
+
+type serveCacheCommand struct {
+	addr    string
+	refresh time.Duration
+}
+
+func (cmd *serveCacheCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	if cmd.refresh < 0 {
+		return errors.New("-refresh must not be negative")
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return errors.Wrap(err, "serve-cache requires git")
+	}
+
+	l, err := net.Listen("tcp", cmd.addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: newCacheServer(ctx, git, cmd.refresh)}
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt)
+	defer signal.Stop(sigch)
+	go func() {
+		<-sigch
+		// Let the requests being served finish, so that the cache is
+		// released.
+		srv.Shutdown(context.Background())
+	}()
+
+	ctx.Err.Printf("Serving %s on %s\n", ctx.CacheDir(), l.Addr())
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// cacheServer serves the git repositories in the source cache. A source
+// manager is held while any request is being served, and shared by all of
+// them.
+type cacheServer struct {
+	ctx     *dep.Ctx
+	git     string
+	refresh time.Duration
+
+	mu    sync.Mutex
+	sm    *gps.SourceMgr
+	users int
+
+	// syncMu serializes the updating of sources, and guards updated and
+	// published.
+	syncMu sync.Mutex
+	// updated holds when each project was last updated from upstream, or
+	// failed to be.
+	updated map[gps.ProjectRoot]time.Time
+	// published holds the projects whose refs have been published to clients
+	// since they were last updated.
+	published map[gps.ProjectRoot]bool
+}
+
+func newCacheServer(ctx *dep.Ctx, git string, refresh time.Duration) *cacheServer {
+	return &cacheServer{
+		ctx:       ctx,
+		git:       git,
+		refresh:   refresh,
+		updated:   make(map[gps.ProjectRoot]time.Time),
+		published: make(map[gps.ProjectRoot]bool),
+	}
+}
+
+func (s *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pr, root, ok := parseServeCachePath(r)
+	if !ok {
+		http.Error(w, "not found; only git's smart HTTP protocol is served, at /<project root>.git", http.StatusNotFound)
+		return
+	}
+
+	sm, err := s.acquire()
+	if err != nil {
+		s.ctx.Err.Println(err)
+		http.Error(w, "the source cache is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.release()
+
+	dir, err := s.source(sm, pr)
+	if err != nil {
+		s.ctx.Err.Printf("Unable to serve %s: %s\n", pr, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if s.ctx.Verbose {
+		s.ctx.Err.Printf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+
+	h := &cgi.Handler{
+		Path: s.git,
+		Root: root,
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + dir,
+			"GIT_HTTP_EXPORT_ALL=1",
+			"GIT_NAMESPACE=" + serveCacheNamespace,
+		},
+		Stderr: os.Stderr,
+	}
+	h.ServeHTTP(w, r)
+}
+
+// parseServeCachePath returns the project that r asks for, and the path of its
+// repository, if r is a request of git's smart HTTP protocol for fetching
+// from a repository. Pushes are not served.
+func parseServeCachePath(r *http.Request) (gps.ProjectRoot, string, bool) {
+	var suffix string
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("service") == "git-upload-pack":
+		suffix = ".git/info/refs"
+	case r.Method == http.MethodPost:
+		suffix = ".git/git-upload-pack"
+	default:
+		return "", "", false
+	}
+
+	p := r.URL.Path
+	if !strings.HasPrefix(p, "/") || !strings.HasSuffix(p, suffix) {
+		return "", "", false
+	}
+	pr := strings.TrimSuffix(p[1:], suffix)
+	for _, elem := range strings.Split(pr, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return "", "", false
+		}
+	}
+	return gps.ProjectRoot(pr), strings.TrimSuffix(p, suffix) + ".git", true
+}
+
+// acquire returns the source manager shared by the requests being served,
+// creating it if there are none.
+func (s *cacheServer) acquire() (*gps.SourceMgr, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sm == nil {
+		sm, err := s.ctx.SourceManager()
+		if err != nil {
+			return nil, err
+		}
+		s.sm = sm
+	}
+	s.users++
+	return s.sm, nil
+}
+
+// release lets go of the source manager returned by acquire, releasing it once
+// no request is using it.
+func (s *cacheServer) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users--
+	if s.users == 0 {
+		s.sm.Release()
+		s.sm = nil
+	}
+}
+
+// source returns the directory of the repository of pr in the cache, with its
+// refs published, retrieving or updating it from upstream first as needed.
+func (s *cacheServer) source(sm *gps.SourceMgr, pr gps.ProjectRoot) (string, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	id := gps.ProjectIdentifier{ProjectRoot: pr}
+	last, has := s.updated[pr]
+	update := !has || time.Since(last) >= s.refresh
+	cs, err := sm.CachedSourceFor(context.TODO(), id, update)
+	if err != nil && update {
+		// Serve what there is, if anything, when upstream is unreachable,
+		// and only try again once -refresh has passed.
+		if cs, cerr := sm.CachedSourceFor(context.TODO(), id, false); cerr == nil {
+			s.ctx.Err.Printf("Unable to update %s, serving the cached copy: %s\n", pr, err)
+			s.updated[pr] = time.Now()
+			return s.publish(pr, cs)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if update {
+		s.updated[pr] = time.Now()
+		s.published[pr] = false
+	}
+	return s.publish(pr, cs)
+}
+
+// publish publishes the refs of the repository of cs to clients, if they have
+// not been since it was last updated, and returns its directory.
+func (s *cacheServer) publish(pr gps.ProjectRoot, cs gps.CachedSource) (string, error) {
+	if cs.Type != "git" {
+		return "", errors.Errorf("%s is a %s source; only git sources can be served", pr, cs.Type)
+	}
+	if s.published[pr] {
+		return cs.Dir, nil
+	}
+	if err := publishServedRefs(cs.Dir); err != nil {
+		return "", err
+	}
+	s.published[pr] = true
+	return cs.Dir, nil
+}
+
+// publishServedRefs makes the branches and tags of the upstream of the cache
+// repository in dir those of the serveCacheNamespace namespace within it, and
+// the upstream's default branch its HEAD.
+func publishServedRefs(dir string) error {
+	ns := "refs/namespaces/" + serveCacheNamespace + "/"
+	out, err := gitCommand(dir, "for-each-ref", "--format=%(objectname) %(refname)", "refs/remotes/origin/", "refs/tags/", ns).Output()
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the refs of %s", dir)
+	}
+	refs := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if fields := strings.Fields(sc.Text()); len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+
+	if cmds := servedRefUpdates(refs); cmds != "" {
+		c := gitCommand(dir, "update-ref", "--stdin")
+		c.Stdin = strings.NewReader(cmds)
+		if out, err := c.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "failed to publish the refs of %s: %s", dir, out)
+		}
+	}
+
+	head, err := gitCommand(dir, "symbolic-ref", "-q", "refs/remotes/origin/HEAD").Output()
+	if err != nil {
+		// Without a default branch upstream, as when only tags are fetched,
+		// nothing is published as HEAD.
+		return nil
+	}
+	branch := strings.TrimPrefix(strings.TrimSpace(string(head)), "refs/remotes/origin/")
+	if out, err := gitCommand(dir, "symbolic-ref", ns+"HEAD", ns+"refs/heads/"+branch).CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "failed to publish the default branch of %s: %s", dir, out)
+	}
+	return nil
+}
+
+// servedRefUpdates returns the commands for git update-ref --stdin that make
+// the refs in the serveCacheNamespace namespace of refs, a map of a cache
+// repository's ref names to the objects they point to, match its
+// remote-tracking branches and tags.
+func servedRefUpdates(refs map[string]string) string {
+	ns := "refs/namespaces/" + serveCacheNamespace + "/"
+	want := make(map[string]string)
+	for name, obj := range refs {
+		switch {
+		case strings.HasPrefix(name, "refs/remotes/origin/"):
+			if branch := strings.TrimPrefix(name, "refs/remotes/origin/"); branch != "HEAD" {
+				want[ns+"refs/heads/"+branch] = obj
+			}
+		case strings.HasPrefix(name, "refs/tags/"):
+			want[ns+name] = obj
+		}
+	}
+
+	var cmds []string
+	for name, obj := range want {
+		if refs[name] != obj {
+			cmds = append(cmds, fmt.Sprintf("update %s %s\n", name, obj))
+		}
+	}
+	for name := range refs {
+		if _, has := want[name]; !has && strings.HasPrefix(name, ns) && name != ns+"HEAD" {
+			cmds = append(cmds, fmt.Sprintf("delete %s\n", name))
+		}
+	}
+	sort.Strings(cmds)
+	return strings.Join(cmds, "")
+}
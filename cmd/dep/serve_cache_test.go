@@ -0,0 +1,144 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestParseServeCachePath(t *testing.T) {
+	cases := []struct {
+		method, target string
+		pr             gps.ProjectRoot
+		root           string
+		ok             bool
+	}{
+		{"GET", "/github.com/foo/bar.git/info/refs?service=git-upload-pack", "github.com/foo/bar", "/github.com/foo/bar.git", true},
+		{"POST", "/github.com/foo/bar.git/git-upload-pack", "github.com/foo/bar", "/github.com/foo/bar.git", true},
+		{"POST", "/example.com/repo.git.git/git-upload-pack", "example.com/repo.git", "/example.com/repo.git.git", true},
+		// Dumb HTTP and pushes are not served.
+		{"GET", "/github.com/foo/bar.git/info/refs", "", "", false},
+		{"GET", "/github.com/foo/bar.git/info/refs?service=git-receive-pack", "", "", false},
+		{"POST", "/github.com/foo/bar.git/git-receive-pack", "", "", false},
+		{"GET", "/github.com/foo/bar.git/HEAD", "", "", false},
+		{"POST", "/.git/git-upload-pack", "", "", false},
+		{"POST", "/github.com/../bar.git/git-upload-pack", "", "", false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		pr, root, ok := parseServeCachePath(r)
+		if pr != c.pr || root != c.root || ok != c.ok {
+			t.Errorf("%s %s: expected (%q, %q, %v), got (%q, %q, %v)", c.method, c.target, c.pr, c.root, c.ok, pr, root, ok)
+		}
+	}
+}
+
+func TestServedRefUpdates(t *testing.T) {
+	refs := map[string]string{
+		"refs/remotes/origin/HEAD":                     "aaa",
+		"refs/remotes/origin/master":                   "aaa",
+		"refs/remotes/origin/feature":                  "bbb",
+		"refs/tags/v1.0.0":                             "ccc",
+		"refs/namespaces/dep-serve/HEAD":               "aaa",
+		"refs/namespaces/dep-serve/refs/heads/master":  "aaa",
+		"refs/namespaces/dep-serve/refs/heads/feature": "000",
+		"refs/namespaces/dep-serve/refs/heads/removed": "ddd",
+		"refs/namespaces/dep-serve/refs/tags/v0.9.0":   "eee",
+	}
+	want := "delete refs/namespaces/dep-serve/refs/heads/removed\n" +
+		"delete refs/namespaces/dep-serve/refs/tags/v0.9.0\n" +
+		"update refs/namespaces/dep-serve/refs/heads/feature bbb\n" +
+		"update refs/namespaces/dep-serve/refs/tags/v1.0.0 ccc\n"
+	if got := servedRefUpdates(refs); got != want {
+		t.Errorf("unexpected updates:\n\t(GOT) %q\n\t(WNT) %q", got, want)
+	}
+}
+
+func TestCacheServer(t *testing.T) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "dep-serve-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(dir string, args ...string) string {
+		c := exec.Command(git, append([]string{"-c", "user.name=dep", "-c", "user.email=dep@example.com"}, args...)...)
+		c.Dir = dir
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return string(out)
+	}
+
+	// Set up the cache repository as dep would have cloned it, with the
+	// upstream's branches as remote-tracking branches.
+	upstream := filepath.Join(dir, "upstream")
+	if err := os.Mkdir(upstream, 0777); err != nil {
+		t.Fatal(err)
+	}
+	run(upstream, "init", "-q")
+	run(upstream, "commit", "-q", "--allow-empty", "-m", "initial")
+	run(upstream, "tag", "v1.0.0")
+	run(upstream, "branch", "feature")
+	cachedir := filepath.Join(dir, "cache")
+	repo := filepath.Join(cachedir, "sources", "https---github.com-foo-bar")
+	run(dir, "clone", "-q", upstream, repo)
+	run(repo, "remote", "set-url", "origin", "https://github.com/foo/bar")
+
+	ctx := &dep.Ctx{
+		Cachedir: cachedir,
+		Out:      log.New(ioutil.Discard, "", 0),
+		Err:      log.New(ioutil.Discard, "", 0),
+	}
+	s := newCacheServer(ctx, git, time.Hour)
+	// Consider the project fresh, so that it is not updated from upstream.
+	s.updated["github.com/foo/bar"] = time.Now()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	out := run(dir, "ls-remote", srv.URL+"/github.com/foo/bar.git")
+	for _, ref := range []string{"HEAD", "refs/heads/feature", "refs/tags/v1.0.0"} {
+		if !strings.Contains(out, "\t"+ref+"\n") {
+			t.Errorf("expected %s to be served, got:\n%s", ref, out)
+		}
+	}
+	if strings.Contains(out, "refs/remotes/") || strings.Contains(out, "refs/namespaces/") {
+		t.Errorf("expected only the branches and tags of upstream to be served, got:\n%s", out)
+	}
+	run(dir, "clone", "-q", srv.URL+"/github.com/foo/bar.git", filepath.Join(dir, "clone"))
+
+	resp, err := http.Get(srv.URL + "/github.com/foo/bar.git/info/refs?service=git-receive-pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected pushes to be refused, got %s", resp.Status)
+	}
+	if s.sm != nil {
+		t.Error("expected the source manager to be released once no request is being served")
+	}
+	if _, err := os.Stat(filepath.Join(cachedir, "sm.lock")); !os.IsNotExist(err) {
+		t.Errorf("expected the cache to be unlocked, got %v", err)
+	}
+}
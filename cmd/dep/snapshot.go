@@ -0,0 +1,211 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+const snapshotShortHelp = `Capture, or restore, the whole dep state of the project`
+const snapshotLongHelp = `
+Bundle the state dep works from into a single archive, so that a resolution
+that failed elsewhere, such as in CI, can be reproduced exactly as it
+happened:
+
+  create <archive>          write the archive
+  restore <archive> <dir>   restore the archive into the project
+
+The archive holds Gopkg.toml and Gopkg.lock, the digest of each project's tree
+in vendor/, the versions of dep and Go that took it, and a source bundle of
+each project in Gopkg.lock at its locked version, as dep bundle-sources
+writes them.
+
+Restore replaces the project's Gopkg.toml and Gopkg.lock with those of the
+snapshot, and extracts the source bundle into <dir>. It then reports the
+projects whose trees in vendor/ differ from those captured. Setting
+$DEPBUNDLEDIR to <dir> makes dep serve every project from the bundle, at the
+versions the snapshot was taken with, instead of from upstream sources; for
+example, DEPBUNDLEDIR=<dir> dep ensure -vendor-only writes vendor/ as it was.
+`
+
+func (cmd *snapshotCommand) Name() string      { return "snapshot" }
+func (cmd *snapshotCommand) Args() string      { return "create <archive> | restore <archive> <dir>" }
+func (cmd *snapshotCommand) ShortHelp() string { return snapshotShortHelp }
+func (cmd *snapshotCommand) LongHelp() string  { return snapshotLongHelp }
+func (cmd *snapshotCommand) Hidden() bool      { return false }
+
+func (cmd *snapshotCommand) Register(fs *flag.FlagSet) {}
+
+type snapshotCommand struct{}
+
+func (cmd *snapshotCommand) Run(ctx *dep.Ctx, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "create":
+		return cmd.runCreate(ctx, args[1])
+	case len(args) == 3 && args[0] == "restore":
+		return cmd.runRestore(ctx, args[1], args[2])
+	}
+	return errors.New("expected create <archive>, or restore <archive> <dir>")
+}
+
+// runCreate writes a snapshot of the project to the archive at path.
+func (cmd *snapshotCommand) runCreate(ctx *dep.Ctx, path string) error {
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found. Run `dep ensure` to generate lock file", dep.LockName)
+	}
+
+	s := &dep.Snapshot{
+		Created:    time.Now(),
+		DepVersion: version,
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if s.Manifest, err = ioutil.ReadFile(filepath.Join(p.AbsRoot, dep.ManifestName)); err != nil {
+		return errors.Wrapf(err, "failed to read %s", dep.ManifestName)
+	}
+	if s.Lock, err = ioutil.ReadFile(filepath.Join(p.AbsRoot, dep.LockName)); err != nil {
+		return errors.Wrapf(err, "failed to read %s", dep.LockName)
+	}
+	if s.Vendor, err = p.VendorDigests(); err != nil {
+		return err
+	}
+
+	sm, err := ctx.ProjectSourceManager(p)
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	bundleDir, err := ioutil.TempDir("", "dep-snapshot")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(bundleDir)
+	for _, lp := range p.Lock.Projects() {
+		if ctx.Verbose {
+			ctx.Err.Printf("Bundling %s at %s\n", lp.Ident(), lp.Version())
+		}
+		if err := gps.WriteSourceBundle(context.TODO(), sm, bundleDir, lp.Ident(), []gps.Version{lp.Version()}); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the snapshot archive")
+	}
+	err = dep.WriteSnapshot(f, s, bundleDir)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return errors.Wrapf(err, "failed to write the snapshot archive %s", path)
+	}
+
+	ctx.Out.Printf("Snapshot of %d projects written to %s\n", len(p.Lock.Projects()), path)
+	return nil
+}
+
+// runRestore restores the snapshot archive at path into the project,
+// extracting its source bundle into bundleDir.
+func (cmd *snapshotCommand) runRestore(ctx *dep.Ctx, path, bundleDir string) error {
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if err := fs.EnsureDir(bundleDir, 0777); err != nil {
+		return errors.Wrapf(err, "failed to create bundle directory %s", bundleDir)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the snapshot archive")
+	}
+	s, err := dep.ReadSnapshot(f, bundleDir)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// Check that the snapshot's files can be used before replacing anything.
+	m, _, err := dep.ReadManifest(bytes.NewReader(s.Manifest))
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s in the snapshot", dep.ManifestName)
+	}
+	var l *dep.Lock
+	if s.Lock != nil {
+		if l, err = dep.ReadLock(bytes.NewReader(s.Lock)); err != nil {
+			return errors.Wrapf(err, "invalid %s in the snapshot", dep.LockName)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(p.AbsRoot, dep.ManifestName), s.Manifest, 0666); err != nil {
+		return errors.Wrapf(err, "failed to write %s", dep.ManifestName)
+	}
+	if s.Lock != nil {
+		if err := ioutil.WriteFile(filepath.Join(p.AbsRoot, dep.LockName), s.Lock, 0666); err != nil {
+			return errors.Wrapf(err, "failed to write %s", dep.LockName)
+		}
+	}
+	p.Manifest, p.Lock = m, l
+
+	ctx.Out.Printf("Restored %s and %s from the snapshot taken %s by dep %s (%s, %s)\n",
+		dep.ManifestName, dep.LockName, s.Created.Format(time.RFC3339), s.DepVersion, s.GoVersion, s.Platform)
+	if platform := runtime.GOOS + "/" + runtime.GOARCH; s.GoVersion != runtime.Version() || s.Platform != platform {
+		ctx.Err.Printf("Warning: this is %s on %s; build contexts and digests may differ from the snapshot's\n", runtime.Version(), platform)
+	}
+
+	digests, err := p.VendorDigests()
+	if err != nil {
+		return err
+	}
+	if changed := changedVendorDigests(s.Vendor, digests); len(changed) > 0 {
+		ctx.Err.Println("The following projects in vendor/ differ from the snapshot:")
+		for _, pr := range changed {
+			ctx.Err.Println("  ✗ ", pr)
+		}
+		ctx.Err.Println()
+	}
+	ctx.Out.Printf("Set DEPBUNDLEDIR=%s to serve the locked projects from the snapshot\n", bundleDir)
+	return nil
+}
+
+// changedVendorDigests returns the projects, in order, whose digests in have
+// differ from those in want, or that only one of the two has.
+func changedVendorDigests(want, have map[gps.ProjectRoot]verify.VersionedDigest) []gps.ProjectRoot {
+	var changed []gps.ProjectRoot
+	for pr, vd := range want {
+		if hvd, has := have[pr]; !has || hvd.String() != vd.String() {
+			changed = append(changed, pr)
+		}
+	}
+	for pr := range have {
+		if _, has := want[pr]; !has {
+			changed = append(changed, pr)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
+	return changed
+}
@@ -0,0 +1,35 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+)
+
+func TestChangedVendorDigests(t *testing.T) {
+	digest := func(s string) verify.VersionedDigest {
+		return verify.VersionedDigest{HashVersion: verify.HashVersion, Digest: []byte(s)}
+	}
+	want := map[gps.ProjectRoot]verify.VersionedDigest{
+		"github.com/foo/same":    digest("same"),
+		"github.com/foo/edited":  digest("original"),
+		"github.com/foo/removed": digest("removed"),
+	}
+	have := map[gps.ProjectRoot]verify.VersionedDigest{
+		"github.com/foo/same":   digest("same"),
+		"github.com/foo/edited": digest("edited"),
+		"github.com/foo/added":  digest("added"),
+	}
+
+	got := changedVendorDigests(want, have)
+	wantChanged := []gps.ProjectRoot{"github.com/foo/added", "github.com/foo/edited", "github.com/foo/removed"}
+	if !reflect.DeepEqual(got, wantChanged) {
+		t.Errorf("expected changed projects %v, got %v", wantChanged, got)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// sourceTemplatesFromEnv builds the variables and rules from which templated
+// sources are resolved from $DEPSOURCEVARS, a comma-separated list of
+// name=value pairs, and $DEPSOURCES, a comma-separated list of prefix=template
+// pairs, as described by gps.SourceTemplates.
+func sourceTemplatesFromEnv(env []string) (gps.SourceTemplates, error) {
+	var st gps.SourceTemplates
+	parse := func(key, form string) (map[string]string, error) {
+		v := getEnv(env, key)
+		if v == "" {
+			return nil, nil
+		}
+		m := make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return nil, errors.Errorf("failed to parse $%s: %q is not of the form %s", key, pair, form)
+			}
+			m[kv[0]] = kv[1]
+		}
+		return m, nil
+	}
+
+	var err error
+	if st.Vars, err = parse("DEPSOURCEVARS", "name=value"); err != nil {
+		return st, err
+	}
+	if st.Rules, err = parse("DEPSOURCES", "prefix=source"); err != nil {
+		return st, err
+	}
+	if err := st.Validate(); err != nil {
+		return st, errors.Wrap(err, "invalid $DEPSOURCEVARS or $DEPSOURCES")
+	}
+	return st, nil
+}
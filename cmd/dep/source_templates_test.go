@@ -0,0 +1,42 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestSourceTemplatesFromEnv(t *testing.T) {
+	st, err := sourceTemplatesFromEnv([]string{"DEPSOURCEVARS=host=https://git.corp.example.com", "DEPSOURCES=github.com={{host}}/mirror/{{project}}, gopkg.in={{host}}/gopkg/{{project}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gps.SourceTemplates{
+		Vars: map[string]string{"host": "https://git.corp.example.com"},
+		Rules: map[string]string{
+			"github.com": "{{host}}/mirror/{{project}}",
+			"gopkg.in":   "{{host}}/gopkg/{{project}}",
+		},
+	}
+	if !reflect.DeepEqual(st, want) {
+		t.Errorf("expected %v, got %v", want, st)
+	}
+
+	if st, err := sourceTemplatesFromEnv(nil); err != nil || st.Vars != nil || st.Rules != nil {
+		t.Errorf("expected no templates without the variables, got %v, %v", st, err)
+	}
+	for _, env := range [][]string{
+		{"DEPSOURCEVARS=host"},
+		{"DEPSOURCES=github.com="},
+		{"DEPSOURCES=github.com={{host}}/{{project}}"},
+	} {
+		if _, err := sourceTemplatesFromEnv(env); err == nil {
+			t.Errorf("expected %q to fail to parse", env)
+		}
+	}
+}
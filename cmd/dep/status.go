@@ -14,11 +14,14 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
@@ -27,10 +30,10 @@ import (
 	"github.com/pkg/errors"
 )
 
-const availableTemplateVariables = "ProjectRoot, Constraint, Version, Revision, Latest, and PackageCount."
+const availableTemplateVariables = "ProjectRoot, Constraint, Version, Revision, Latest, PackageCount, Direct, Verification (with -verify), and Group (with -group-by)."
 const availableDefaultTemplateVariables = `.Projects[]{
-	    .ProjectRoot,.Source,.Constraint,.PackageCount,.Packages[],
-	    .Locked{.Branch,.Revision,.Version},.Latest{.Revision,.Version}
+	    .ProjectRoot,.Source,.Constraint,.PackageCount,.Direct,.Packages[],
+	    .Verification,.Reason,.Group,.Locked{.Branch,.Revision,.Version},.Latest{.Revision,.Version}
 	},
 	.Metadata{
 	    .AnalyzerName,.AnalyzerVersion,.InputsDigest,.SolverName,
@@ -48,6 +51,17 @@ With no arguments, print the status of each dependency of the project.
   LATEST      Latest VCS revision available
   PKGS USED   Number of packages from this project that are actually used
 
+With -verify, an additional column reports whether the project's copy in
+vendor/ matches the digest recorded for it in the lock:
+
+  verified       The vendored copy matches the recorded digest
+  hash mismatch  The vendored copy has been modified since it was written
+  missing        The project is not present in vendor/
+  unverifiable   No digest, or a digest from an unsupported version of the
+                 hashing algorithm, is recorded; run dep ensure to record one
+
+Use dep check to fail a build on any of these problems.
+
 You may use the -f flag to create a custom format for the output of the
 dep status command. The available fields you can utilize are as follows:
 ` + availableTemplateVariables + `
@@ -66,7 +80,9 @@ dep status -detail
 
 	Displays a detailed table of the dependencies in the project including
 	the value of any source rules used and full list of packages used from
-	each project (instead of simply a count). Text wrapping may make this
+	each project (instead of simply a count). If the manifest records why
+	any of the dependencies were added, as "dep ensure -add -reason" does,
+	the reasons are shown in a final column. Text wrapping may make this
 	output hard to read.
 
 dep status -f='{{if eq .Constraint "master"}}{{.ProjectRoot}} {{end}}'
@@ -86,11 +102,122 @@ dep status -detail -f='{{range $i, $p := .Projects}}{{if ne .Source "" -}}
 	to the full output document, instead of to packages one at a time.
 	Available flags are as follows: ` + availableDefaultTemplateVariables + `
 
+dep status -size -sort=size
+
+	Displays the number of files and bytes vendored for each dependency,
+	alongside those of its full tree before pruning, largest first. Use
+	-sort=files or -sort=unpruned to order by file count or unpruned size.
+
+dep status -duplicates
+
+	Displays the packages vendored with identical files under more than one
+	project root, as happens when several dependencies include forks or
+	copies of the same utility repository. For each set of copies, the
+	size of one copy and the bytes taken by the others are shown, along
+	with the copy to consolidate onto: the one in the project holding the
+	most of the duplicated packages. The total wasted bytes come last.
+
+dep status -who-constrains github.com/pkg/errors
+
+	Lists every rule that influences the versions of github.com/pkg/errors
+	that may be chosen: the constraint or override on it in Gopkg.toml, and
+	the constraints declared on it by the manifests of the dependencies
+	that import it. Rules without effect, such as constraints superseded by
+	an override, are marked as such. The constraint that results from the
+	rules that apply is shown, along with whether the locked version
+	satisfies it.
+
+dep status -why-version github.com/pkg/errors
+
+	Explains why github.com/pkg/errors is at its locked version, without
+	solving again: whether it was kept from Gopkg.lock, or was the newest
+	version allowed, and the tightest constraint on it, with the project
+	that declared it, and whether the current project imports it directly
+	or only transitively. The decisions are only recorded in Gopkg.lock if
+	Gopkg.toml sets record-decisions = true.
+
+dep status -pressure
+
+	Displays the dependencies that are constrained by two or more of the
+	projects that import them - the root project or other dependencies -
+	where the constraints, taken together, allow no more than a couple of
+	the versions available, and fewer than any of them allows alone. Such
+	dependencies are likely to become impossible to solve for as the
+	dependers move on. For each, the two constraints that allow the fewest
+	versions between them are shown, along with the shortest chain of
+	imports through which each applies, so that they can be loosened.
+
+dep status -test-imports
+
+	Displays the packages of each dependency that are reachable only
+	through the test files of the root project, and whether the whole
+	dependency is needed only by those tests. Test files of dependencies
+	are never considered, as they are not built. Use it to audit what
+	building and vendoring without the root project's tests would leave
+	out.
+
+dep status -cycles
+
+	Displays the sets of projects whose manifests constrain one another in
+	a cycle, such as a dependency that constrains another which, in turn,
+	constrains it back, so that the versions the solver can choose for each
+	depend on those chosen for the others. For each, the shortest cycle is
+	shown with the constraint declared at every step, so that the
+	constraints responsible for surprising resolutions can be found.
+
+dep status -group-by=label
+
+	Groups the dependencies by the value of the given key in the metadata
+	of their constraints in Gopkg.toml, such as a label naming the team
+	that owns them, with those without one last. In table output each
+	group starts with a line giving the number of its dependencies that
+	are outdated, and with -verify, failing verification; other outputs
+	give each dependency's group. It also applies to -detail.
+
+dep status -filter=outdated,direct-only -columns=project,version,latest
+
+	Shows only the dependencies meeting every given condition: outdated,
+	for those with a newer version allowed by their constraint;
+	unverified, for those whose copy in vendor/ fails verification;
+	direct-only, for those imported by the current project, and
+	transitive-only, for the others, as recorded in Gopkg.lock; and
+	<key>=<value>, for those whose constraint metadata in Gopkg.toml has
+	that value for the key, such as label=storage. -columns then limits
+	the table to the given columns, in that order, for piping into other
+	tools. Valid columns are project, source, constraint, version,
+	revision, latest, pkgs, type (direct or transitive), packages, reason
+	and verification. Both also apply to -detail.
+
+dep status -old -feed
+
+	Outputs the dependencies that can be updated within their constraints
+	as a JSON document, for bots that open automated update pull requests.
+	Each change gives the project, its current and candidate versions and
+	revisions, a URL comparing the two on hosts that support one, and
+	whether the update is likely to be a breaking change: a new major
+	version, or a new minor version before 1.0.0.
+
+dep status -metrics -metrics-format=statsd
+
+	Outputs counts summarizing the health of the dependencies, for CI jobs
+	that feed dashboards: the number of locked projects, of those -old
+	would list, of those affected by a known advisory if $DEPADVISORIES is
+	set, and of those failing verification in vendor/, along with the age
+	in days of the oldest locked revision. The default format is the
+	plaintext protocol of Graphite, with one "name value timestamp" line
+	per metric; statsd writes gauges instead.
+
 dep status -json
 
 	Displays the dependency information in JSON format as a list of
 	project objects. Each project object contains keys which correspond
-	to the table column names from the standard 'dep status' command.
+	to the table column names from the standard 'dep status' command,
+	along with whether it is a Direct dependency, its Depth, the length
+	of the shortest chain of imports from the current project to it,
+	counted in projects, and its FanIn, the number of projects importing
+	it. The dependencies deep in the graph that many projects import are
+	the ones a change to has the widest reach. Unreachable dependencies
+	have neither.
 
 Linux:   dep status -dot | dot -T png | display
 MacOS:   dep status -dot | dot -T png | open -f -a /Applications/Preview.app
@@ -130,19 +257,55 @@ func (cmd *statusCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.missing, "missing", false, "only show missing dependencies")
 	fs.StringVar(&cmd.outFilePath, "out", "", "path to a file to which to write the output. Blank value will be ignored")
 	fs.BoolVar(&cmd.detail, "detail", false, "include more detail in the chosen format")
+	fs.BoolVar(&cmd.size, "size", false, "report the disk usage of each dependency, before and after pruning")
+	fs.StringVar(&cmd.sortBy, "sort", "", "with -size, sort by one of: name (default), size, files, unpruned")
+	fs.BoolVar(&cmd.duplicates, "duplicates", false, "only show packages vendored with identical files under more than one project root")
+	fs.BoolVar(&cmd.verify, "verify", false, "include whether each dependency in vendor/ matches its digest in the lock")
+	fs.StringVar(&cmd.whoConstrains, "who-constrains", "", "list the rules that influence the allowed versions of the given project")
+	fs.StringVar(&cmd.whyVersion, "why-version", "", "explain, from the decisions recorded in Gopkg.lock, why the given project is at its version")
+	fs.BoolVar(&cmd.pressure, "pressure", false, "only show dependencies whose dependers' constraints leave few versions to choose from")
+	fs.BoolVar(&cmd.testImports, "test-imports", false, "only show packages and projects reachable solely through the root project's test files")
+	fs.BoolVar(&cmd.cycles, "cycles", false, "only show projects whose manifests constrain one another in a cycle")
+	fs.BoolVar(&cmd.feed, "feed", false, "with -old, output the available updates as a JSON changeset feed, for tools opening update PRs")
+	fs.StringVar(&cmd.groupBy, "group-by", "", "group dependencies by the value of the given key, such as label, in the metadata of their constraints")
+	fs.BoolVar(&cmd.metrics, "metrics", false, "only show counts summarizing the health of the dependencies, as metrics for dashboards")
+	fs.StringVar(&cmd.metricsFormat, "metrics-format", "", "with -metrics, the format of the metrics: graphite (default) or statsd")
+	fs.StringVar(&cmd.filter, "filter", "", "only show dependencies meeting all the given comma-separated `conditions`: outdated, unverified, direct-only, transitive-only, or <key>=<value> for their constraint metadata")
+	fs.StringVar(&cmd.columns, "columns", "", "show only the given comma-separated `columns` of the table, in that order; see -examples")
+	cmd.perf.register(fs)
 }
 
 type statusCommand struct {
-	examples    bool
-	json        bool
-	template    string
-	lock        bool
-	output      string
-	dot         bool
-	old         bool
-	missing     bool
-	outFilePath string
-	detail      bool
+	examples      bool
+	json          bool
+	template      string
+	lock          bool
+	output        string
+	dot           bool
+	old           bool
+	missing       bool
+	outFilePath   string
+	detail        bool
+	size          bool
+	sortBy        string
+	duplicates    bool
+	verify        bool
+	whoConstrains string
+	whyVersion    string
+	pressure      bool
+	testImports   bool
+	cycles        bool
+	groupBy       string
+	feed          bool
+	metrics       bool
+	metricsFormat string
+	filter        string
+	columns       string
+	perf          perfFlags
+
+	// filters and cols are parsed from filter and columns by validateFlags.
+	filters statusFilter
+	cols    []statusColumn
 }
 
 type outputter interface {
@@ -164,10 +327,26 @@ type oldOutputter interface {
 	OldFooter() error
 }
 
-type tableOutput struct{ w *tabwriter.Writer }
+// Only a subset of the outputters should be able to output size statuses.
+type sizeOutputter interface {
+	SizeHeader() error
+	SizeLine(*SizeStatus) error
+	SizeFooter(total *SizeStatus) error
+}
+
+type tableOutput struct {
+	w *tabwriter.Writer
+	// verify adds the verification column to basic and detail output.
+	verify bool
+	// reasons adds the column of reasons, recorded in the manifest for why
+	// each dependency was added, to detail output.
+	reasons bool
+	// columns, if set, are the only columns of detail output.
+	columns []statusColumn
+}
 
 func (out *tableOutput) BasicHeader() error {
-	_, err := fmt.Fprintf(out.w, "PROJECT\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED\n")
+	_, err := fmt.Fprintf(out.w, "PROJECT\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED%s\n", out.verifyHeader())
 	return err
 }
 
@@ -177,19 +356,23 @@ func (out *tableOutput) BasicFooter() error {
 
 func (out *tableOutput) BasicLine(bs *BasicStatus) error {
 	_, err := fmt.Fprintf(out.w,
-		"%s\t%s\t%s\t%s\t%s\t%d\t\n",
+		"%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
 		bs.ProjectRoot,
 		bs.getConsolidatedConstraint(),
 		formatVersion(bs.Version),
 		formatVersion(bs.Revision),
 		bs.getConsolidatedLatest(shortRev),
 		bs.PackageCount,
+		out.verifyCell(bs),
 	)
 	return err
 }
 
 func (out *tableOutput) DetailHeader(metadata *dep.SolveMeta) error {
-	_, err := fmt.Fprintf(out.w, "PROJECT\tSOURCE\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED\n")
+	if len(out.columns) > 0 {
+		return out.columnsHeader()
+	}
+	_, err := fmt.Fprintf(out.w, "PROJECT\tSOURCE\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED%s%s\n", out.reasonHeader(), out.verifyHeader())
 	return err
 }
 
@@ -198,8 +381,11 @@ func (out *tableOutput) DetailFooter(metadata *dep.SolveMeta) error {
 }
 
 func (out *tableOutput) DetailLine(ds *DetailStatus) error {
+	if len(out.columns) > 0 {
+		return out.columnsLine(ds)
+	}
 	_, err := fmt.Fprintf(out.w,
-		"%s\t%s\t%s\t%s\t%s\t%s\t[%s]\t\n",
+		"%s\t%s\t%s\t%s\t%s\t%s\t[%s]\t%s%s\n",
 		ds.ProjectRoot,
 		ds.Source,
 		ds.getConsolidatedConstraint(),
@@ -207,10 +393,40 @@ func (out *tableOutput) DetailLine(ds *DetailStatus) error {
 		formatVersion(ds.Revision),
 		ds.getConsolidatedLatest(shortRev),
 		strings.Join(ds.Packages, ", "),
+		out.reasonCell(ds),
+		out.verifyCell(&ds.BasicStatus),
 	)
 	return err
 }
 
+func (out *tableOutput) reasonHeader() string {
+	if !out.reasons {
+		return ""
+	}
+	return "\tREASON"
+}
+
+func (out *tableOutput) reasonCell(ds *DetailStatus) string {
+	if !out.reasons {
+		return ""
+	}
+	return ds.Reason + "\t"
+}
+
+func (out *tableOutput) verifyHeader() string {
+	if !out.verify {
+		return ""
+	}
+	return "\tVERIFICATION"
+}
+
+func (out *tableOutput) verifyCell(bs *BasicStatus) string {
+	if !out.verify {
+		return ""
+	}
+	return bs.Verification + "\t"
+}
+
 func (out *tableOutput) MissingHeader() error {
 	_, err := fmt.Fprintln(out.w, "PROJECT\tMISSING PACKAGES")
 	return err
@@ -249,12 +465,45 @@ func (out *tableOutput) OldFooter() error {
 	return out.w.Flush()
 }
 
+func (out *tableOutput) SizeHeader() error {
+	_, err := fmt.Fprintf(out.w, "PROJECT\tFILES\tSIZE\tUNPRUNED FILES\tUNPRUNED SIZE\n")
+	return err
+}
+
+func (out *tableOutput) SizeLine(ss *SizeStatus) error {
+	unprunedFiles, unprunedSize := "unknown", "unknown"
+	if !ss.hasError {
+		unprunedFiles, unprunedSize = fmt.Sprint(ss.UnprunedFiles), formatSize(ss.UnprunedBytes)
+	}
+	_, err := fmt.Fprintf(out.w,
+		"%s\t%d\t%s\t%s\t%s\t\n",
+		ss.ProjectRoot,
+		ss.Files,
+		formatSize(ss.Bytes),
+		unprunedFiles,
+		unprunedSize,
+	)
+	return err
+}
+
+func (out *tableOutput) SizeFooter(total *SizeStatus) error {
+	if err := out.SizeLine(total); err != nil {
+		return err
+	}
+	return out.w.Flush()
+}
+
 type jsonOutput struct {
-	w       io.Writer
-	basic   []*rawStatus
-	detail  []rawDetailProject
-	missing []*MissingStatus
-	old     []*rawOldStatus
+	w           io.Writer
+	basic       []*rawStatus
+	detail      []rawDetailProject
+	missing     []*MissingStatus
+	old         []*rawOldStatus
+	size        []*rawSizeStatus
+	duplicates  []*DuplicateStatus
+	pressure    []*rawPressureStatus
+	testImports []*TestImportStatus
+	cycles      []*rawCycleStatus
 }
 
 func (out *jsonOutput) BasicHeader() error {
@@ -318,6 +567,20 @@ func (out *jsonOutput) OldFooter() error {
 	return json.NewEncoder(out.w).Encode(out.old)
 }
 
+func (out *jsonOutput) SizeHeader() error {
+	out.size = []*rawSizeStatus{}
+	return nil
+}
+
+func (out *jsonOutput) SizeLine(ss *SizeStatus) error {
+	out.size = append(out.size, ss.marshalJSON())
+	return nil
+}
+
+func (out *jsonOutput) SizeFooter(total *SizeStatus) error {
+	return json.NewEncoder(out.w).Encode(out.size)
+}
+
 type dotOutput struct {
 	w io.Writer
 	o string
@@ -380,6 +643,8 @@ func (out *templateOutput) BasicLine(bs *BasicStatus) error {
 		Revision:     bs.Revision.String(),
 		Latest:       bs.getConsolidatedLatest(shortRev),
 		PackageCount: bs.PackageCount,
+		Direct:       bs.Direct,
+		Verification: bs.Verification,
 	}
 	return out.tmpl.Execute(out.w, data)
 }
@@ -408,6 +673,9 @@ func (out *templateOutput) DetailLine(ds *DetailStatus) error {
 		PackageCount: ds.PackageCount,
 		Source:       ds.Source,
 		Packages:     ds.Packages,
+		Verification: ds.Verification,
+		Reason:       ds.Reason,
+		Group:        ds.Group,
 	}
 
 	out.detail = append(out.detail, data)
@@ -437,12 +705,18 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	stopPerf, err := cmd.perf.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer stopPerf()
+
 	p, err := ctx.LoadProject()
 	if err != nil {
 		return err
 	}
 
-	sm, err := ctx.SourceManager()
+	sm, err := ctx.ProjectSourceManager(p)
 	if err != nil {
 		return err
 	}
@@ -454,6 +728,28 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 	}
 
 	var buf bytes.Buffer
+	if cmd.feed {
+		if p.Lock == nil {
+			return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+		}
+		err = cmd.runOld(ctx, &feedOutput{w: &buf}, p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
+	if cmd.metrics {
+		if p.Lock == nil {
+			return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+		}
+		now := time.Now()
+		m, err := cmd.runMetrics(ctx, p, sm, sm, now)
+		if err != nil {
+			return err
+		}
+		ctx.Out.Print(formatMetrics(m, cmd.metricsFormat, now))
+		return nil
+	}
+
 	var out outputter
 	switch {
 	case cmd.missing:
@@ -488,7 +784,10 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		}
 	default:
 		out = &tableOutput{
-			w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0),
+			w:       tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0),
+			verify:  cmd.verify,
+			reasons: cmd.detail && hasReasons(p.Manifest),
+			columns: cmd.cols,
 		}
 	}
 
@@ -506,6 +805,69 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	if cmd.size {
+		if _, ok := out.(sizeOutputter); !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runSize(ctx, out.(sizeOutputter), p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
+	if cmd.duplicates {
+		if _, ok := out.(duplicatesOutputter); !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runDuplicates(ctx, out.(duplicatesOutputter), p)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
+	if cmd.whoConstrains != "" {
+		if _, ok := out.(constraintsOutputter); !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runWhoConstrains(ctx, out.(constraintsOutputter), p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
+	if cmd.whyVersion != "" {
+		if _, ok := out.(decisionOutputter); !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runWhyVersion(out.(decisionOutputter), p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
+	if cmd.pressure {
+		if _, ok := out.(pressureOutputter); !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runPressure(ctx, out.(pressureOutputter), p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
+	if cmd.testImports {
+		if _, ok := out.(testImportsOutputter); !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runTestImports(ctx, out.(testImportsOutputter), p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
+	if cmd.cycles {
+		if _, ok := out.(cyclesOutputter); !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runCycles(ctx, out.(cyclesOutputter), p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
 	hasMissingPkgs, errCount, err := cmd.runStatusAll(ctx, out, p, sm)
 	if err != nil {
 		switch err {
@@ -565,6 +927,49 @@ func (cmd *statusCommand) validateFlags() error {
 		opModes = append(opModes, "-detail")
 	}
 
+	if cmd.size {
+		opModes = append(opModes, "-size")
+	}
+
+	if cmd.duplicates {
+		opModes = append(opModes, "-duplicates")
+	}
+
+	if cmd.whoConstrains != "" {
+		opModes = append(opModes, "-who-constrains")
+	}
+
+	if cmd.whyVersion != "" {
+		opModes = append(opModes, "-why-version")
+	}
+
+	if cmd.pressure {
+		opModes = append(opModes, "-pressure")
+	}
+
+	if cmd.testImports {
+		opModes = append(opModes, "-test-imports")
+	}
+
+	if cmd.cycles {
+		opModes = append(opModes, "-cycles")
+	}
+
+	if cmd.metrics {
+		opModes = append(opModes, "-metrics")
+	}
+
+	if cmd.sortBy != "" {
+		if !cmd.size {
+			return errors.New("-sort can only be used with -size")
+		}
+		switch cmd.sortBy {
+		case "name", "size", "files", "unpruned":
+		default:
+			return errors.Errorf("invalid -sort %q; must be one of name, size, files or unpruned", cmd.sortBy)
+		}
+	}
+
 	// Check if any other flags are passed with -dot.
 	if cmd.dot {
 		if cmd.template != "" {
@@ -575,11 +980,78 @@ func (cmd *statusCommand) validateFlags() error {
 			return errors.New("cannot pass multiple output format flags")
 		}
 
-		if len(opModes) > 0 {
+		if len(opModes) > 0 || cmd.verify {
 			return errors.New("-dot generates dependency graph; cannot pass other flags")
 		}
 	}
 
+	if cmd.verify && (cmd.old || cmd.size || cmd.duplicates || cmd.whoConstrains != "" || cmd.whyVersion != "" || cmd.pressure || cmd.testImports || cmd.cycles || cmd.metrics) {
+		return errors.New("-verify can only be used with the default and -detail operating modes")
+	}
+
+	if cmd.feed {
+		if !cmd.old {
+			return errors.New("-feed can only be used with -old")
+		}
+		if cmd.json || cmd.template != "" || cmd.lock || cmd.dot {
+			return errors.New("cannot pass multiple output format flags")
+		}
+	}
+
+	if cmd.metricsFormat != "" {
+		if !cmd.metrics {
+			return errors.New("-metrics-format can only be used with -metrics")
+		}
+		switch cmd.metricsFormat {
+		case "graphite", "statsd":
+		default:
+			return errors.Errorf("invalid -metrics-format %q; must be one of graphite or statsd", cmd.metricsFormat)
+		}
+	}
+
+	if cmd.metrics && (cmd.json || cmd.template != "" || cmd.lock) {
+		return errors.New("cannot pass multiple output format flags")
+	}
+
+	if cmd.groupBy != "" && (cmd.old || cmd.missing || cmd.size || cmd.duplicates || cmd.whoConstrains != "" || cmd.whyVersion != "" || cmd.pressure || cmd.testImports || cmd.cycles || cmd.metrics || cmd.dot || cmd.lock) {
+		return errors.New("-group-by can only be used with the default and -detail operating modes")
+	}
+
+	if cmd.filter != "" || cmd.columns != "" {
+		if cmd.old || cmd.missing || cmd.size || cmd.duplicates || cmd.whoConstrains != "" || cmd.whyVersion != "" || cmd.pressure || cmd.testImports || cmd.cycles || cmd.metrics || cmd.dot {
+			return errors.New("-filter and -columns can only be used with the default and -detail operating modes")
+		}
+	}
+
+	if cmd.filter != "" {
+		f, err := parseStatusFilter(cmd.filter)
+		if err != nil {
+			return err
+		}
+		cmd.filters = f
+		if f.unverified {
+			cmd.verify = true
+		}
+	}
+
+	if cmd.columns != "" {
+		if cmd.json || cmd.template != "" || cmd.lock {
+			return errors.New("-columns only applies to table output; cannot pass it with -json, -f or -lock")
+		}
+		cols, err := parseStatusColumns(cmd.columns)
+		if err != nil {
+			return err
+		}
+		cmd.cols = cols
+		for _, col := range cols {
+			if col.header == "VERIFICATION" {
+				cmd.verify = true
+			}
+		}
+		// The columns are those of -detail, some of which only it collects.
+		cmd.detail = true
+	}
+
 	if cmd.lock {
 		if cmd.template != "" {
 			return errors.New("cannot pass template string with -lock")
@@ -605,6 +1077,11 @@ type OldStatus struct {
 	Constraint  gps.Constraint
 	Revision    gps.Revision
 	Latest      gps.Version
+
+	// The project, its locked version, and the version that the solver
+	// would update it to, for -feed.
+	ident             gps.ProjectIdentifier
+	locked, candidate gps.Version
 }
 
 type rawOldStatus struct {
@@ -646,6 +1123,24 @@ func (os OldStatus) marshalJSON() *rawOldStatus {
 }
 
 func (cmd *statusCommand) runOld(ctx *dep.Ctx, out oldOutputter, p *dep.Project, sm gps.SourceManager) error {
+	oldStatuses, err := collectOldStatuses(ctx, p, sm)
+	if err != nil {
+		return err
+	}
+
+	out.OldHeader()
+	for _, ostat := range oldStatuses {
+		out.OldLine(&ostat)
+	}
+	out.OldFooter()
+
+	return nil
+}
+
+// collectOldStatuses solves for p with every project allowed to change, and
+// returns the locked projects with a constraint that the solution moves to a
+// new revision.
+func collectOldStatuses(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) ([]OldStatus, error) {
 	// While the network churns on ListVersions() requests, statically analyze
 	// code from the current project.
 	ptree := p.RootPackageTree
@@ -671,13 +1166,13 @@ func (cmd *statusCommand) runOld(ctx *dep.Ctx, out oldOutputter, p *dep.Project,
 
 	solver, err := gps.Prepare(params, sm)
 	if err != nil {
-		return errors.Wrap(err, "fastpath solver prepare")
+		return nil, errors.Wrap(err, "fastpath solver prepare")
 	}
 
 	logger.Println("Solving dependency graph to determine which dependencies can be updated.")
 	solution, err := solver.Solve(context.TODO())
 	if err != nil {
-		return errors.Wrap(err, "runOld")
+		return nil, errors.Wrap(err, "runOld")
 	}
 
 	var oldStatuses []OldStatus
@@ -716,18 +1211,166 @@ func (cmd *statusCommand) runOld(ctx *dep.Ctx, out oldOutputter, p *dep.Project,
 				Revision:    gps.Revision(atRev),
 				Latest:      gps.Revision(latestRev),
 				Constraint:  constraint,
+				ident:       proj.Ident(),
+				locked:      proj.Version(),
+				candidate:   sProj.Version(),
 			}
 			oldStatuses = append(oldStatuses, os)
 		}
 	}
 
-	out.OldHeader()
-	for _, ostat := range oldStatuses {
-		out.OldLine(&ostat)
+	return oldStatuses, nil
+}
+
+// SizeStatus contains the disk usage of a single dependency: the files
+// vendored for it, and the files of its full tree at the locked version,
+// before any pruning.
+type SizeStatus struct {
+	ProjectRoot   string
+	Files         int
+	Bytes         int64
+	UnprunedFiles int
+	UnprunedBytes int64
+	hasError      bool
+}
+
+type rawSize struct {
+	Files int
+	Bytes int64
+}
+
+type rawSizeStatus struct {
+	ProjectRoot string
+	Vendored    rawSize
+	Unpruned    *rawSize `json:"Unpruned,omitempty"`
+}
+
+func (ss *SizeStatus) marshalJSON() *rawSizeStatus {
+	raw := &rawSizeStatus{
+		ProjectRoot: ss.ProjectRoot,
+		Vendored:    rawSize{Files: ss.Files, Bytes: ss.Bytes},
 	}
-	out.OldFooter()
+	if !ss.hasError {
+		raw.Unpruned = &rawSize{Files: ss.UnprunedFiles, Bytes: ss.UnprunedBytes}
+	}
+	return raw
+}
 
-	return nil
+func (cmd *statusCommand) runSize(ctx *dep.Ctx, out sizeOutputter, p *dep.Project, sm gps.SourceManager) error {
+	td, err := ioutil.TempDir("", "dep-status")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	lps := p.Lock.Projects()
+	sizes := make([]*SizeStatus, len(lps))
+	errs := make([]error, len(lps))
+
+	var wg sync.WaitGroup
+	for i, lp := range lps {
+		wg.Add(1)
+		go func(i int, lp gps.LockedProject) {
+			defer wg.Done()
+			ss := &SizeStatus{ProjectRoot: string(lp.Ident().ProjectRoot)}
+			sizes[i] = ss
+
+			vendored := filepath.Join(p.VendorDir(), filepath.FromSlash(ss.ProjectRoot))
+			if ss.Files, ss.Bytes, errs[i] = measureTree(vendored); errs[i] != nil {
+				return
+			}
+
+			// Export the project in full to measure what pruning saved.
+			to := filepath.Join(td, strconv.Itoa(i))
+			err := sm.ExportProject(context.TODO(), lp.Ident(), lp.Version(), to)
+			if err == nil {
+				ss.UnprunedFiles, ss.UnprunedBytes, err = measureTree(to)
+				os.RemoveAll(to)
+			}
+			if err != nil {
+				ss.hasError = true
+				ctx.Err.Printf("Unable to determine the unpruned size of %s: %s\n", ss.ProjectRoot, err)
+			}
+		}(i, lp)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return errors.Wrap(err, "failed to measure vendored dependencies")
+		}
+	}
+
+	sortSizeStatuses(sizes, cmd.sortBy)
+
+	total := &SizeStatus{ProjectRoot: "TOTAL"}
+	out.SizeHeader()
+	for _, ss := range sizes {
+		out.SizeLine(ss)
+		total.Files += ss.Files
+		total.Bytes += ss.Bytes
+		total.UnprunedFiles += ss.UnprunedFiles
+		total.UnprunedBytes += ss.UnprunedBytes
+		total.hasError = total.hasError || ss.hasError
+	}
+	return out.SizeFooter(total)
+}
+
+// sortSizeStatuses sorts sizes by the named key. Sizes and file counts sort
+// largest first, so that the heaviest dependencies lead; ties, and the default
+// key, sort by project root.
+func sortSizeStatuses(sizes []*SizeStatus, by string) {
+	key := func(ss *SizeStatus) int64 {
+		switch by {
+		case "size":
+			return ss.Bytes
+		case "files":
+			return int64(ss.Files)
+		case "unpruned":
+			return ss.UnprunedBytes
+		}
+		return 0
+	}
+
+	sort.Slice(sizes, func(i, j int) bool {
+		if ki, kj := key(sizes[i]), key(sizes[j]); ki != kj {
+			return ki > kj
+		}
+		return sizes[i].ProjectRoot < sizes[j].ProjectRoot
+	})
+}
+
+// measureTree returns the number of files, and their total size in bytes, in
+// the tree at root. Symlinks are counted as files and are not followed. A
+// missing root measures as empty.
+func measureTree(root string) (files int, size int64, err error) {
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == root {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size, err
+}
+
+// formatSize formats a number of bytes in binary units, e.g. 1.5 MiB.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
 type rawStatus struct {
@@ -737,6 +1380,11 @@ type rawStatus struct {
 	Revision     string
 	Latest       string
 	PackageCount int
+	Direct       bool
+	Depth        int    `json:"Depth,omitempty"`
+	FanIn        int    `json:"FanIn,omitempty"`
+	Verification string `json:"Verification,omitempty"`
+	Group        string `json:"Group,omitempty"`
 }
 
 // rawDetail is is additional information used for the status when the
@@ -760,6 +1408,12 @@ type rawDetailProject struct {
 	Source       string `json:"Source,omitempty"`
 	Constraint   string
 	PackageCount int
+	Direct       bool
+	Depth        int    `json:"Depth,omitempty"`
+	FanIn        int    `json:"FanIn,omitempty"`
+	Verification string `json:"Verification,omitempty"`
+	Reason       string `json:"Reason,omitempty"`
+	Group        string `json:"Group,omitempty"`
 }
 
 type rawDetailMetadata struct {
@@ -793,8 +1447,21 @@ type BasicStatus struct {
 	Revision     gps.Revision
 	Latest       gps.Version
 	PackageCount int
-	hasOverride  bool
-	hasError     bool
+	// Direct is whether the root project imports or requires the project
+	// itself, rather than only through other dependencies.
+	Direct bool
+	// Depth and FanIn are as in dep.ProjectReach. They are only set for
+	// JSON output, which needs the packages of every project listed.
+	Depth, FanIn int
+	// Verification is the state of the project's copy in vendor/, as given by
+	// formatVendorStatus. It is only set when requested with -verify.
+	Verification string
+	// Group is the value, for the project's constraint, of the metadata key
+	// passed with -group-by.
+	Group       string
+	hasOverride bool
+	isFrozen    bool
+	hasError    bool
 }
 
 // DetailStatus contains all information reported about a single dependency
@@ -804,6 +1471,9 @@ type DetailStatus struct {
 	BasicStatus
 	Packages []string
 	Source   string
+	// Reason is why the dependency was added, as recorded in the metadata of
+	// its constraint in the manifest.
+	Reason string
 }
 
 func (bs *BasicStatus) getConsolidatedConstraint() string {
@@ -819,6 +1489,9 @@ func (bs *BasicStatus) getConsolidatedConstraint() string {
 	if bs.hasOverride {
 		constraint += " (override)"
 	}
+	if bs.isFrozen {
+		constraint += " (frozen)"
+	}
 
 	return constraint
 }
@@ -857,6 +1530,11 @@ func (bs *BasicStatus) marshalJSON() *rawStatus {
 		Revision:     string(bs.Revision),
 		Latest:       bs.getConsolidatedLatest(longRev),
 		PackageCount: bs.PackageCount,
+		Direct:       bs.Direct,
+		Depth:        bs.Depth,
+		FanIn:        bs.FanIn,
+		Verification: bs.Verification,
+		Group:        bs.Group,
 	}
 }
 
@@ -871,7 +1549,23 @@ func (ds *DetailStatus) marshalJSON() *rawDetailProject {
 		Source:       ds.Source,
 		Packages:     ds.Packages,
 		PackageCount: ds.PackageCount,
+		Direct:       ds.Direct,
+		Depth:        ds.Depth,
+		FanIn:        ds.FanIn,
+		Verification: rawStatus.Verification,
+		Reason:       ds.Reason,
+		Group:        rawStatus.Group,
+	}
+}
+
+// hasReasons reports whether m records why any of its dependencies were added.
+func hasReasons(m *dep.Manifest) bool {
+	for _, md := range m.ConstraintMetadata {
+		if md[dep.MetadataReason] != "" {
+			return true
+		}
 	}
+	return false
 }
 
 // MissingStatus contains information about all the missing packages in a project.
@@ -924,6 +1618,13 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 		return slcp[i].Ident().Less(slcp[j].Ident())
 	})
 
+	var vendorStatus map[string]verify.VendorStatus
+	if cmd.verify {
+		if vendorStatus, err = p.VerifyVendor(); err != nil {
+			return false, 0, errors.Wrap(err, "failed to verify vendor")
+		}
+	}
+
 	lsat := verify.LockSatisfiesInputs(p.Lock, p.Manifest, params.RootPackageTree)
 	if lsat.Satisfied() {
 		// If these are equal, we're guaranteed that the lock is a transitively
@@ -940,6 +1641,17 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 		errListVerCh := make(chan error, len(slp))
 
 		var wg sync.WaitGroup
+		direct := p.Lock.DirectDependencies()
+		reach := make(map[gps.ProjectRoot]dep.ProjectReach)
+		if _, ok := out.(*jsonOutput); ok {
+			graph, err := p.ImportGraph(sm)
+			if err != nil {
+				return false, 0, err
+			}
+			for _, pr := range graph {
+				reach[pr.ProjectRoot] = pr
+			}
+		}
 
 		for i, proj := range slp {
 			wg.Add(1)
@@ -949,6 +1661,12 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 				bs := BasicStatus{
 					ProjectRoot:  string(proj.Ident().ProjectRoot),
 					PackageCount: len(proj.Packages()),
+					Direct:       direct[proj.Ident().ProjectRoot],
+					Depth:        reach[proj.Ident().ProjectRoot].Depth,
+					FanIn:        reach[proj.Ident().ProjectRoot].FanIn,
+				}
+				if cmd.verify {
+					bs.Verification = formatVendorStatus(vendorStatus[bs.ProjectRoot])
 				}
 
 				// Get children only for specific outputers
@@ -991,6 +1709,11 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 						bs.Constraint = c.Constraint.Intersect(bs.Constraint)
 					}
 				}
+				for _, pr := range p.Manifest.Freeze {
+					if gps.ProjectRoot(pr) == proj.Ident().ProjectRoot {
+						bs.isFrozen = true
+					}
+				}
 
 				// Only if we have a non-rev and non-plain version do/can we display
 				// anything wrt the version's updateability.
@@ -1043,6 +1766,7 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 				if cmd.detail {
 					ds.Source = proj.Ident().Source
 					ds.Packages = proj.Packages()
+					ds.Reason = p.Manifest.ConstraintMetadata[proj.Ident().ProjectRoot][dep.MetadataReason]
 				}
 
 				dsCh <- &ds
@@ -1089,28 +1813,30 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 			}
 		}
 
-		if cmd.detail {
-			// A map of ProjectRoot and *DetailStatus. This is used in maintain the
-			// order of DetailStatus in output by collecting all the DetailStatus and
-			// then using them in order.
-			dsMap := make(map[string]*DetailStatus)
-			for ds := range dsCh {
-				dsMap[ds.ProjectRoot] = ds
-			}
+		// A map of ProjectRoot and *DetailStatus. This is used in maintain the
+		// order of DetailStatus in output by collecting all the DetailStatus and
+		// then using them in order.
+		dsMap := make(map[string]*DetailStatus)
+		for ds := range dsCh {
+			dsMap[ds.ProjectRoot] = ds
+		}
+		shown := slp
+		if cmd.filter != "" {
+			shown = filterStatuses(cmd.filters, slp, p.Manifest, dsMap)
+		}
+		groups := groupStatuses(shown, p.Manifest, cmd.groupBy, dsMap)
 
-			if err := detailOutputAll(out, slp, dsMap, &p.Lock.SolveMeta); err != nil {
+		if cmd.detail {
+			if err := detailOutputAll(out, groups, dsMap, &p.Lock.SolveMeta); err != nil {
 				return false, 0, err
 			}
 		} else {
-			// A map of ProjectRoot and *BasicStatus. This is used in maintain the
-			// order of BasicStatus in output by collecting all the BasicStatus and
-			// then using them in order.
 			bsMap := make(map[string]*BasicStatus)
-			for bs := range dsCh {
-				bsMap[bs.ProjectRoot] = &bs.BasicStatus
+			for pr, ds := range dsMap {
+				bsMap[pr] = &ds.BasicStatus
 			}
 
-			if err := basicOutputAll(out, slp, bsMap); err != nil {
+			if err := basicOutputAll(out, groups, bsMap); err != nil {
 				return false, 0, err
 			}
 		}
@@ -1187,42 +1913,79 @@ outer:
 	return hasMissingPkgs, 0, errInputDigestMismatch
 }
 
-// basicOutputAll takes an outputter, a project list, and a map of ProjectRoot to *BasicStatus and
-// uses the outputter to output basic header, body lines (in the order of the project list), and
+// formatVendorStatus describes the verification state of a project in vendor/
+// in the terms of the -verify column.
+func formatVendorStatus(vs verify.VendorStatus) string {
+	switch vs {
+	case verify.NoMismatch:
+		return "verified"
+	case verify.DigestMismatchInLock:
+		return "hash mismatch"
+	case verify.NotInTree:
+		return "missing"
+	case verify.DigestMismatchSkipped:
+		return "modified (noverify)"
+	}
+	// EmptyDigestInLock and HashVersionMismatch, which leave nothing to check
+	// the vendored copy against.
+	return "unverifiable"
+}
+
+// basicOutputAll takes an outputter, the groups of projects, and a map of ProjectRoot to *BasicStatus and
+// uses the outputter to output basic header, body lines (in the order of the groups' projects), and
 // footer based on the project information.
-func basicOutputAll(out outputter, slp []gps.LockedProject, bsMap map[string]*BasicStatus) (err error) {
+func basicOutputAll(out outputter, groups []*StatusGroup, bsMap map[string]*BasicStatus) (err error) {
 	if err := out.BasicHeader(); err != nil {
 		return err
 	}
 
 	// Use the collected BasicStatus in outputter.
-	for _, proj := range slp {
-		if err := out.BasicLine(bsMap[string(proj.Ident().ProjectRoot)]); err != nil {
+	for _, g := range groups {
+		if err := groupHeader(out, g); err != nil {
 			return err
 		}
+		for _, proj := range g.projects {
+			if err := out.BasicLine(bsMap[string(proj.Ident().ProjectRoot)]); err != nil {
+				return err
+			}
+		}
 	}
 
 	return out.BasicFooter()
 }
 
-// detailOutputAll takes an outputter, a project list, and a map of ProjectRoot to *DetailStatus and
-// uses the outputter to output detailed header, body lines (in the order of the project list), and
+// detailOutputAll takes an outputter, the groups of projects, and a map of ProjectRoot to *DetailStatus and
+// uses the outputter to output detailed header, body lines (in the order of the groups' projects), and
 // footer based on the project information.
-func detailOutputAll(out outputter, slp []gps.LockedProject, dsMap map[string]*DetailStatus, metadata *dep.SolveMeta) (err error) {
+func detailOutputAll(out outputter, groups []*StatusGroup, dsMap map[string]*DetailStatus, metadata *dep.SolveMeta) (err error) {
 	if err := out.DetailHeader(metadata); err != nil {
 		return err
 	}
 
 	// Use the collected BasicStatus in outputter.
-	for _, proj := range slp {
-		if err := out.DetailLine(dsMap[string(proj.Ident().ProjectRoot)]); err != nil {
+	for _, g := range groups {
+		if err := groupHeader(out, g); err != nil {
 			return err
 		}
+		for _, proj := range g.projects {
+			if err := out.DetailLine(dsMap[string(proj.Ident().ProjectRoot)]); err != nil {
+				return err
+			}
+		}
 	}
 
 	return out.DetailFooter(metadata)
 }
 
+// groupHeader sets apart the projects of g, made with -group-by, if out can.
+func groupHeader(out outputter, g *StatusGroup) error {
+	gout, ok := out.(groupOutputter)
+	if g.Key == "" || !ok {
+		return nil
+	}
+	return gout.GroupHeader(g)
+}
+
 func formatVersion(v gps.Version) string {
 	if v == nil {
 		return ""
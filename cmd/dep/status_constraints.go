@@ -0,0 +1,307 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// Only a subset of the outputters should be able to output constraint origins.
+type constraintsOutputter interface {
+	ConstraintsHeader(*ConstraintsStatus) error
+	ConstraintLine(*ConstraintOrigin) error
+	ConstraintsFooter(*ConstraintsStatus) error
+}
+
+// ConstraintOrigin describes a single rule, from the root manifest or from the
+// manifest of a dependency, that constrains the versions of a project.
+type ConstraintOrigin struct {
+	// Source is "root" for rules in the root manifest, or the root and locked
+	// version of the dependency whose manifest declares the rule.
+	Source string
+	// Type is one of "override" or "constraint", for rules in the root
+	// manifest, or "dependency", for rules in a dependency's manifest.
+	Type       string
+	Constraint gps.Constraint
+	// Ignored gives the reason the rule has no effect, if it has none.
+	Ignored string
+}
+
+// ConstraintsStatus contains everything that influences the allowed versions
+// of a single project.
+type ConstraintsStatus struct {
+	ProjectRoot string
+	Origins     []ConstraintOrigin
+	// Effective is the constraint that results from the rules that apply: the
+	// override if there is one, or else the intersection of the others.
+	Effective gps.Constraint
+	// Locked is the version of the project in the lock, if it is locked.
+	Locked gps.Version
+}
+
+type rawConstraintOrigin struct {
+	Source     string
+	Type       string
+	Constraint string
+	Applies    bool
+	Ignored    string `json:"Ignored,omitempty"`
+}
+
+type rawConstraintsStatus struct {
+	ProjectRoot     string
+	Constraints     []rawConstraintOrigin
+	Effective       string
+	Locked          string `json:"Locked,omitempty"`
+	LockedSatisfies bool   `json:"LockedSatisfies,omitempty"`
+}
+
+func (cs *ConstraintsStatus) marshalJSON() *rawConstraintsStatus {
+	raw := &rawConstraintsStatus{
+		ProjectRoot: cs.ProjectRoot,
+		Constraints: []rawConstraintOrigin{},
+		Effective:   cs.getEffective(),
+	}
+	for _, co := range cs.Origins {
+		raw.Constraints = append(raw.Constraints, rawConstraintOrigin{
+			Source:     co.Source,
+			Type:       co.Type,
+			Constraint: formatConstraint(co.Constraint),
+			Applies:    co.Ignored == "",
+			Ignored:    co.Ignored,
+		})
+	}
+	if cs.Locked != nil {
+		raw.Locked = cs.Locked.String()
+		raw.LockedSatisfies = cs.Effective.Matches(cs.Locked)
+	}
+	return raw
+}
+
+func (cs *ConstraintsStatus) getEffective() string {
+	if s := formatConstraint(cs.Effective); s != "" {
+		return s
+	}
+	return "none"
+}
+
+func (out *tableOutput) ConstraintsHeader(cs *ConstraintsStatus) error {
+	_, err := fmt.Fprintf(out.w, "SOURCE\tTYPE\tCONSTRAINT\tAPPLIES\n")
+	return err
+}
+
+func (out *tableOutput) ConstraintLine(co *ConstraintOrigin) error {
+	applies := "yes"
+	if co.Ignored != "" {
+		applies = "no, " + co.Ignored
+	}
+	_, err := fmt.Fprintf(out.w,
+		"%s\t%s\t%s\t%s\t\n",
+		co.Source,
+		co.Type,
+		formatConstraint(co.Constraint),
+		applies,
+	)
+	return err
+}
+
+func (out *tableOutput) ConstraintsFooter(cs *ConstraintsStatus) error {
+	if _, err := fmt.Fprintf(out.w, "\nEFFECTIVE\t%s\n", cs.getEffective()); err != nil {
+		return err
+	}
+	switch {
+	case cs.Locked == nil:
+		_, err := fmt.Fprintf(out.w, "LOCKED\tnot in lock\n")
+		if err != nil {
+			return err
+		}
+	case cs.Effective.Matches(cs.Locked):
+		_, err := fmt.Fprintf(out.w, "LOCKED\t%s (satisfies effective constraint)\n", formatVersion(cs.Locked))
+		if err != nil {
+			return err
+		}
+	default:
+		_, err := fmt.Fprintf(out.w, "LOCKED\t%s (does not satisfy effective constraint)\n", formatVersion(cs.Locked))
+		if err != nil {
+			return err
+		}
+	}
+	return out.w.Flush()
+}
+
+func (out *jsonOutput) ConstraintsHeader(cs *ConstraintsStatus) error { return nil }
+func (out *jsonOutput) ConstraintLine(co *ConstraintOrigin) error     { return nil }
+func (out *jsonOutput) ConstraintsFooter(cs *ConstraintsStatus) error {
+	return json.NewEncoder(out.w).Encode(cs.marshalJSON())
+}
+
+func (cmd *statusCommand) runWhoConstrains(ctx *dep.Ctx, out constraintsOutputter, p *dep.Project, sm gps.SourceManager) error {
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	target, err := sm.DeduceProjectRoot(cmd.whoConstrains)
+	if err != nil {
+		return errors.Wrapf(err, "could not determine the project of %s", cmd.whoConstrains)
+	}
+
+	directDeps, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return errors.Wrap(err, "failed to get direct dependencies")
+	}
+
+	rootAnalyzer := newRootAnalyzer(true, ctx, directDeps, sm)
+
+	// Look for rules on the target in the manifest of every locked project, at
+	// its locked version.
+	lps := p.Lock.Projects()
+	found := make([]*ConstraintOrigin, len(lps))
+	errs := make([]error, len(lps))
+
+	logger.Println("Collecting project constraints:")
+	var wg sync.WaitGroup
+	for i, lp := range lps {
+		if lp.Ident().ProjectRoot == target {
+			continue
+		}
+
+		wg.Add(1)
+		logger.Printf("(%d/%d) %s\n", i+1, len(lps), lp.Ident().ProjectRoot)
+		go func(i int, lp gps.LockedProject) {
+			defer wg.Done()
+			found[i], errs[i] = dependencyConstraintOn(target, lp, sm, rootAnalyzer)
+		}(i, lp)
+	}
+	wg.Wait()
+
+	var dependers []ConstraintOrigin
+	for i, co := range found {
+		if errs[i] != nil {
+			ctx.Err.Printf("Unable to read the constraints of %s: %s\n", lps[i].Ident().ProjectRoot, errs[i])
+		}
+		if co != nil {
+			dependers = append(dependers, *co)
+		}
+	}
+
+	cs := whoConstrains(target, p.Manifest, directDeps, dependers)
+	for _, lp := range lps {
+		if lp.Ident().ProjectRoot == target {
+			cs.Locked = lp.Version()
+		}
+	}
+
+	if err := out.ConstraintsHeader(cs); err != nil {
+		return err
+	}
+	for i := range cs.Origins {
+		if err := out.ConstraintLine(&cs.Origins[i]); err != nil {
+			return err
+		}
+	}
+	return out.ConstraintsFooter(cs)
+}
+
+// dependencyConstraintOn returns the rule on target in the manifest of the
+// locked project lp, or nil if it has none. Like the solver, it only applies
+// the rule if the packages used from lp import the target.
+func dependencyConstraintOn(target gps.ProjectRoot, lp gps.LockedProject, sm gps.SourceManager, an gps.ProjectAnalyzer) (*ConstraintOrigin, error) {
+	m, _, err := sm.GetManifestAndLock(lp.Ident(), lp.Version(), an)
+	if err != nil {
+		return nil, err
+	}
+	pp, has := m.DependencyConstraints()[target]
+	if !has || pp.Constraint == nil {
+		return nil, nil
+	}
+
+	co := &ConstraintOrigin{
+		Source:     fmt.Sprintf("%s@%s", lp.Ident().ProjectRoot, lp.Version()),
+		Type:       "dependency",
+		Constraint: pp.Constraint,
+	}
+
+	ptree, err := sm.ListPackages(lp.Ident(), lp.Version())
+	if err != nil {
+		// Without the imports, assume the rule applies.
+		return co, err
+	}
+	for _, pkg := range lp.Packages() {
+		ip := string(lp.Ident().ProjectRoot)
+		if pkg != "." {
+			ip += "/" + pkg
+		}
+		poe, has := ptree.Packages[ip]
+		if !has || poe.Err != nil {
+			continue
+		}
+		for _, imp := range poe.P.Imports {
+			if imp == string(target) || strings.HasPrefix(imp, string(target)+"/") {
+				return co, nil
+			}
+		}
+	}
+	co.Ignored = "not imported by the packages used"
+	return co, nil
+}
+
+// whoConstrains combines the rules on target from the root manifest with those
+// found in the manifests of dependencies, noting which of them apply, and
+// computes the resulting effective constraint.
+func whoConstrains(target gps.ProjectRoot, m *dep.Manifest, directDeps map[gps.ProjectRoot]bool, dependers []ConstraintOrigin) *ConstraintsStatus {
+	cs := &ConstraintsStatus{ProjectRoot: string(target)}
+
+	var override gps.Constraint
+	if pp, has := m.Ovr[target]; has && pp.Constraint != nil {
+		override = pp.Constraint
+		cs.Origins = append(cs.Origins, ConstraintOrigin{Source: "root", Type: "override", Constraint: override})
+	}
+	if pp, has := m.Constraints[target]; has && pp.Constraint != nil {
+		co := ConstraintOrigin{Source: "root", Type: "constraint", Constraint: pp.Constraint}
+		switch {
+		case override != nil:
+			co.Ignored = "superseded by override"
+		case !directDeps[target]:
+			co.Ignored = "not a direct dependency"
+		}
+		cs.Origins = append(cs.Origins, co)
+	}
+	for _, co := range dependers {
+		if override != nil && co.Ignored == "" {
+			co.Ignored = "superseded by override"
+		}
+		cs.Origins = append(cs.Origins, co)
+	}
+
+	if override != nil {
+		cs.Effective = override
+		return cs
+	}
+	cs.Effective = gps.Any()
+	for _, co := range cs.Origins {
+		if co.Ignored == "" {
+			cs.Effective = cs.Effective.Intersect(co.Constraint)
+		}
+	}
+	return cs
+}
+
+// formatConstraint formats a constraint as in the CONSTRAINT column of the
+// status table.
+func formatConstraint(c gps.Constraint) string {
+	if v, ok := c.(gps.Version); ok {
+		return formatVersion(v)
+	}
+	return c.String()
+}
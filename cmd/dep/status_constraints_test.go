@@ -0,0 +1,113 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func TestWhoConstrains(t *testing.T) {
+	target := gps.ProjectRoot("github.com/foo/bar")
+	semver := func(s string) gps.Constraint {
+		c, err := gps.NewSemverConstraint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	dependers := []ConstraintOrigin{
+		{Source: "github.com/a/a@v1.0.0", Type: "dependency", Constraint: semver("^1.2.0")},
+		{Source: "github.com/b/b@v2.0.0", Type: "dependency", Constraint: semver("<1.5.0")},
+		{Source: "github.com/c/c@master", Type: "dependency", Constraint: semver("^2.0.0"), Ignored: "not imported by the packages used"},
+	}
+
+	testCases := []struct {
+		name          string
+		manifest      *dep.Manifest
+		direct        bool
+		wantEffective string
+		wantIgnored   []string
+	}{
+		{
+			name:          "direct dependency",
+			manifest:      &dep.Manifest{Constraints: gps.ProjectConstraints{target: {Constraint: semver("^1.0.0")}}},
+			direct:        true,
+			wantEffective: ">=1.2.0, <1.5.0",
+			wantIgnored:   []string{"", "", "", "not imported by the packages used"},
+		},
+		{
+			name:          "transitive dependency",
+			manifest:      &dep.Manifest{Constraints: gps.ProjectConstraints{target: {Constraint: semver("^3.0.0")}}},
+			wantEffective: ">=1.2.0, <1.5.0",
+			wantIgnored:   []string{"not a direct dependency", "", "", "not imported by the packages used"},
+		},
+		{
+			name: "override",
+			manifest: &dep.Manifest{
+				Constraints: gps.ProjectConstraints{target: {Constraint: semver("^1.0.0")}},
+				Ovr:         gps.ProjectConstraints{target: {Constraint: gps.NewBranch("master")}},
+			},
+			direct:        true,
+			wantEffective: "branch master",
+			wantIgnored:   []string{"", "superseded by override", "superseded by override", "superseded by override", "not imported by the packages used"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := whoConstrains(target, tc.manifest, map[gps.ProjectRoot]bool{target: tc.direct}, dependers)
+
+			var ignored []string
+			for _, co := range cs.Origins {
+				ignored = append(ignored, co.Ignored)
+			}
+			if !reflect.DeepEqual(ignored, tc.wantIgnored) {
+				t.Errorf("unexpected reasons for ignoring rules:\n\t(GOT): %q\n\t(WNT): %q", ignored, tc.wantIgnored)
+			}
+			if got := cs.getEffective(); got != tc.wantEffective {
+				t.Errorf("expected effective constraint %q, got %q", tc.wantEffective, got)
+			}
+		})
+	}
+}
+
+func TestConstraintsTableOutput(t *testing.T) {
+	c, _ := gps.NewSemverConstraint("^1.2.0")
+	cs := &ConstraintsStatus{
+		ProjectRoot: "github.com/foo/bar",
+		Origins: []ConstraintOrigin{
+			{Source: "root", Type: "constraint", Constraint: c},
+			{Source: "github.com/a/a@v1.0.0", Type: "dependency", Constraint: gps.NewBranch("master"), Ignored: "superseded by override"},
+		},
+		Effective: c.Intersect(gps.NewVersion("v2.0.0")),
+		Locked:    gps.NewVersion("v2.0.0"),
+	}
+
+	var buf bytes.Buffer
+	out := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)}
+	out.ConstraintsHeader(cs)
+	for i := range cs.Origins {
+		out.ConstraintLine(&cs.Origins[i])
+	}
+	out.ConstraintsFooter(cs)
+
+	for _, want := range []string{
+		"root                   constraint  ^1.2.0         yes",
+		"github.com/a/a@v1.0.0  dependency  branch master  no, superseded by override",
+		"EFFECTIVE  none",
+		"LOCKED     v2.0.0 (does not satisfy effective constraint)",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected table output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
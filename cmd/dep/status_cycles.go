@@ -0,0 +1,292 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// Only a subset of the outputters should be able to output cycle statuses.
+type cyclesOutputter interface {
+	CyclesHeader() error
+	CyclesLine(*CycleStatus) error
+	CyclesFooter() error
+}
+
+// CycleStatus describes a set of projects whose manifests constrain one
+// another in a cycle, so that the version chosen for each of them limits the
+// versions that can be chosen for the others, and so on back to itself.
+type CycleStatus struct {
+	// Projects holds every project in the cycle, or in cycles sharing projects
+	// with it, sorted. The root project is named "root".
+	Projects []string
+	// Path is the shortest cycle through the first of Projects, starting and
+	// ending with it.
+	Path []string
+	// Constraints holds, for each step along Path, the constraint that the
+	// manifest of a project declares on the next.
+	Constraints []gps.Constraint
+}
+
+type rawCycleStatus struct {
+	Projects    []string
+	Path        []string
+	Constraints []string
+}
+
+func (cs *CycleStatus) marshalJSON() *rawCycleStatus {
+	raw := &rawCycleStatus{
+		Projects: cs.Projects,
+		Path:     cs.Path,
+	}
+	for _, c := range cs.Constraints {
+		raw.Constraints = append(raw.Constraints, formatConstraint(c))
+	}
+	return raw
+}
+
+func (out *tableOutput) CyclesHeader() error {
+	_, err := fmt.Fprintf(out.w, "CYCLE\tCONSTRAINTS\n")
+	return err
+}
+
+func (out *tableOutput) CyclesLine(cs *CycleStatus) error {
+	constraints := make([]string, len(cs.Constraints))
+	for i, c := range cs.Constraints {
+		constraints[i] = fmt.Sprintf("%s on %s %s", cs.Path[i], cs.Path[i+1], formatConstraint(c))
+	}
+	_, err := fmt.Fprintf(out.w,
+		"%s\t%s\t\n",
+		strings.Join(cs.Path, " -> "),
+		strings.Join(constraints, "; "),
+	)
+	return err
+}
+
+func (out *tableOutput) CyclesFooter() error {
+	return out.w.Flush()
+}
+
+func (out *jsonOutput) CyclesHeader() error {
+	out.cycles = []*rawCycleStatus{}
+	return nil
+}
+
+func (out *jsonOutput) CyclesLine(cs *CycleStatus) error {
+	out.cycles = append(out.cycles, cs.marshalJSON())
+	return nil
+}
+
+func (out *jsonOutput) CyclesFooter() error {
+	return json.NewEncoder(out.w).Encode(out.cycles)
+}
+
+func (cmd *statusCommand) runCycles(ctx *dep.Ctx, out cyclesOutputter, p *dep.Project, sm gps.SourceManager) error {
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	directDeps, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return errors.Wrap(err, "failed to get direct dependencies")
+	}
+	rootAnalyzer := newRootAnalyzer(true, ctx, directDeps, sm)
+
+	lps := p.Lock.Projects()
+	roots := make([]gps.ProjectRoot, len(lps), len(lps)+1)
+	for i, lp := range lps {
+		roots[i] = lp.Ident().ProjectRoot
+	}
+	// Dependencies may import the root project back.
+	roots = append(roots, p.ImportRoot)
+
+	graph := map[gps.ProjectRoot]*dependerInfo{
+		"root": {constraints: p.Manifest.Constraints, imports: directDeps},
+	}
+
+	logger.Println("Collecting project constraints and imports:")
+	infos := make([]*dependerInfo, len(lps))
+	errs := make([]error, len(lps))
+	var wg sync.WaitGroup
+	for i, lp := range lps {
+		wg.Add(1)
+		logger.Printf("(%d/%d) %s\n", i+1, len(lps), lp.Ident().ProjectRoot)
+		go func(i int, lp gps.LockedProject) {
+			defer wg.Done()
+			infos[i], errs[i] = collectDependerInfo(lp, roots, sm, rootAnalyzer)
+		}(i, lp)
+	}
+	wg.Wait()
+
+	for i, lp := range lps {
+		if errs[i] != nil {
+			ctx.Err.Printf("Unable to read the constraints and imports of %s: %s\n", lp.Ident().ProjectRoot, errs[i])
+			continue
+		}
+		info := infos[i]
+		// Refer to the root project by the name it has in the graph.
+		if info.imports[p.ImportRoot] {
+			delete(info.imports, p.ImportRoot)
+			info.imports["root"] = true
+			if pp, has := info.constraints[p.ImportRoot]; has {
+				constraints := make(gps.ProjectConstraints, len(info.constraints))
+				for pr, cpp := range info.constraints {
+					constraints[pr] = cpp
+				}
+				delete(constraints, p.ImportRoot)
+				constraints["root"] = pp
+				info.constraints = constraints
+			}
+		}
+		graph[lp.Ident().ProjectRoot] = info
+	}
+
+	if err := out.CyclesHeader(); err != nil {
+		return err
+	}
+	for _, cs := range findCycles(graph) {
+		if err := out.CyclesLine(cs); err != nil {
+			return err
+		}
+	}
+	return out.CyclesFooter()
+}
+
+// constraintEdges returns, for each project in graph, the projects in graph
+// that it both imports and declares a constraint on, in order.
+func constraintEdges(graph map[gps.ProjectRoot]*dependerInfo) map[gps.ProjectRoot][]gps.ProjectRoot {
+	edges := make(map[gps.ProjectRoot][]gps.ProjectRoot, len(graph))
+	for pr, info := range graph {
+		for target, pp := range info.constraints {
+			if _, has := graph[target]; has && target != pr && pp.Constraint != nil && info.imports[target] {
+				edges[pr] = append(edges[pr], target)
+			}
+		}
+		sort.Slice(edges[pr], func(i, j int) bool { return edges[pr][i] < edges[pr][j] })
+	}
+	return edges
+}
+
+// findCycles finds the sets of projects in graph whose constraints on one
+// another form cycles, reporting each set along with its shortest cycle
+// through its first project. The result is ordered by that project.
+func findCycles(graph map[gps.ProjectRoot]*dependerInfo) []*CycleStatus {
+	edges := constraintEdges(graph)
+
+	nodes := make([]gps.ProjectRoot, 0, len(graph))
+	for pr := range graph {
+		nodes = append(nodes, pr)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+
+	// Tarjan's algorithm, to find the strongly connected components; each with
+	// more than one project holds at least one cycle.
+	var (
+		index   int
+		indexes = make(map[gps.ProjectRoot]int)
+		lowlink = make(map[gps.ProjectRoot]int)
+		onStack = make(map[gps.ProjectRoot]bool)
+		stack   []gps.ProjectRoot
+		sccs    [][]gps.ProjectRoot
+		connect func(gps.ProjectRoot)
+	)
+	connect = func(v gps.ProjectRoot) {
+		indexes[v], lowlink[v] = index, index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			if _, visited := indexes[w]; !visited {
+				connect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && indexes[w] < lowlink[v] {
+				lowlink[v] = indexes[w]
+			}
+		}
+
+		if lowlink[v] == indexes[v] {
+			var scc []gps.ProjectRoot
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			if len(scc) > 1 {
+				sccs = append(sccs, scc)
+			}
+		}
+	}
+	for _, v := range nodes {
+		if _, visited := indexes[v]; !visited {
+			connect(v)
+		}
+	}
+
+	statuses := make([]*CycleStatus, 0, len(sccs))
+	for _, scc := range sccs {
+		sort.Slice(scc, func(i, j int) bool { return scc[i] < scc[j] })
+		in := make(map[gps.ProjectRoot]bool, len(scc))
+		cs := &CycleStatus{}
+		for _, pr := range scc {
+			in[pr] = true
+			cs.Projects = append(cs.Projects, string(pr))
+		}
+
+		// The shortest way back to the first project, without leaving the
+		// component.
+		start := scc[0]
+		prev := make(map[gps.ProjectRoot]gps.ProjectRoot)
+		queue := []gps.ProjectRoot{start}
+		var last gps.ProjectRoot
+		for len(queue) > 0 && last == "" {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, next := range edges[cur] {
+				if next == start {
+					last = cur
+					break
+				}
+				if _, seen := prev[next]; !seen && in[next] {
+					prev[next] = cur
+					queue = append(queue, next)
+				}
+			}
+		}
+
+		path := []gps.ProjectRoot{start}
+		for cur := last; cur != start; cur = prev[cur] {
+			path = append([]gps.ProjectRoot{cur}, path...)
+		}
+		path = append([]gps.ProjectRoot{start}, path...)
+		for i, pr := range path {
+			cs.Path = append(cs.Path, string(pr))
+			if i+1 < len(path) {
+				cs.Constraints = append(cs.Constraints, graph[pr].constraints[path[i+1]].Constraint)
+			}
+		}
+		statuses = append(statuses, cs)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Projects[0] < statuses[j].Projects[0] })
+	return statuses
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestFindCycles(t *testing.T) {
+	semver := func(s string) gps.ProjectProperties {
+		c, err := gps.NewSemverConstraint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gps.ProjectProperties{Constraint: c}
+	}
+	imports := func(prs ...gps.ProjectRoot) map[gps.ProjectRoot]bool {
+		m := make(map[gps.ProjectRoot]bool)
+		for _, pr := range prs {
+			m[pr] = true
+		}
+		return m
+	}
+
+	// root constrains a, which constrains b, which constrains a back, and
+	// also c, which constrains b back through a longer way around. d
+	// constrains root, which constrains it back. e constrains a without
+	// importing it, and is in no cycle.
+	graph := map[gps.ProjectRoot]*dependerInfo{
+		"root": {
+			constraints: gps.ProjectConstraints{"a": semver("^1.0.0"), "d": semver("^2.0.0")},
+			imports:     imports("a", "d", "e"),
+		},
+		"a": {
+			constraints: gps.ProjectConstraints{"b": semver(">=1.2.0")},
+			imports:     imports("b"),
+		},
+		"b": {
+			constraints: gps.ProjectConstraints{"a": semver("<1.5.0"), "c": semver("^0.3.0")},
+			imports:     imports("a", "c"),
+		},
+		"c": {
+			constraints: gps.ProjectConstraints{"b": semver("^1.0.0")},
+			imports:     imports("b"),
+		},
+		"d": {
+			constraints: gps.ProjectConstraints{"root": semver("^3.0.0")},
+			imports:     imports("root"),
+		},
+		"e": {
+			constraints: gps.ProjectConstraints{"a": semver("^1.1.0")},
+		},
+	}
+
+	got := findCycles(graph)
+	want := []*CycleStatus{
+		{
+			Projects:    []string{"a", "b", "c"},
+			Path:        []string{"a", "b", "a"},
+			Constraints: []gps.Constraint{semver(">=1.2.0").Constraint, semver("<1.5.0").Constraint},
+		},
+		{
+			Projects:    []string{"d", "root"},
+			Path:        []string{"d", "root", "d"},
+			Constraints: []gps.Constraint{semver("^3.0.0").Constraint, semver("^2.0.0").Constraint},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected cycles:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	delete(graph, "d")
+	graph["b"].constraints = gps.ProjectConstraints{"c": semver("^0.3.0")}
+	graph["c"].constraints = nil
+	if got := findCycles(graph); len(got) != 0 {
+		t.Errorf("expected no cycles, got %+v", got)
+	}
+}
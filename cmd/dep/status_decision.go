@@ -0,0 +1,125 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// Only a subset of the outputters should be able to output decisions.
+type decisionOutputter interface {
+	DecisionLine(*DecisionStatus) error
+}
+
+// DecisionStatus describes why a locked project is at its version, as
+// recorded in the lock when it was solved.
+type DecisionStatus struct {
+	ProjectRoot string
+	Version     gps.Version
+	// Direct is whether the root project imports or requires the project
+	// itself.
+	Direct   bool
+	Decision gps.Decision
+	// By names the project that declared the tightest constraint, as "root"
+	// for the root project.
+	By string
+}
+
+type rawDecisionStatus struct {
+	ProjectRoot string
+	Version     string
+	Direct      bool
+	Reason      string
+	Constraint  string `json:"Constraint,omitempty"`
+	By          string `json:"By,omitempty"`
+}
+
+func (ds *DecisionStatus) marshalJSON() *rawDecisionStatus {
+	return &rawDecisionStatus{
+		ProjectRoot: ds.ProjectRoot,
+		Version:     ds.Version.String(),
+		Direct:      ds.Direct,
+		Reason:      ds.Decision.Reason,
+		Constraint:  ds.Decision.Constraint,
+		By:          ds.By,
+	}
+}
+
+// describeDecision explains a gps.Decision reason.
+func describeDecision(reason string) string {
+	switch reason {
+	case gps.DecisionLocked:
+		return fmt.Sprintf("kept at its version in %s", dep.LockName)
+	case gps.DecisionPreferred:
+		return "preferred by the lock of a project that depends on it"
+	case gps.DecisionNewestAllowed:
+		return "the newest version, with nothing ruling it out"
+	case gps.DecisionTightestConstraint:
+		return "the newest version allowed by its tightest constraint"
+	case gps.DecisionNewestCompatible:
+		return "the newest version compatible with the rest of the solution; newer ones were allowed, but conflicted"
+	}
+	return reason
+}
+
+func (out *tableOutput) DecisionLine(ds *DecisionStatus) error {
+	if _, err := fmt.Fprintf(out.w, "PROJECT\t%s\nVERSION\t%s\nTYPE\t%s\nREASON\t%s (%s)\n",
+		ds.ProjectRoot,
+		formatVersion(ds.Version),
+		dependencyType(ds.Direct),
+		describeDecision(ds.Decision.Reason),
+		ds.Decision.Reason,
+	); err != nil {
+		return err
+	}
+	if ds.Decision.Constraint != "" {
+		if _, err := fmt.Fprintf(out.w, "CONSTRAINT\t%s, from %s\n", ds.Decision.Constraint, ds.By); err != nil {
+			return err
+		}
+	}
+	return out.w.Flush()
+}
+
+func (out *jsonOutput) DecisionLine(ds *DecisionStatus) error {
+	return json.NewEncoder(out.w).Encode(ds.marshalJSON())
+}
+
+func (cmd *statusCommand) runWhyVersion(out decisionOutputter, p *dep.Project, sm gps.SourceManager) error {
+	target, err := sm.DeduceProjectRoot(cmd.whyVersion)
+	if err != nil {
+		return errors.Wrapf(err, "could not determine the project of %s", cmd.whyVersion)
+	}
+
+	var lp gps.LockedProject
+	for _, candidate := range p.Lock.Projects() {
+		if candidate.Ident().ProjectRoot == target {
+			lp = candidate
+		}
+	}
+	if lp == nil {
+		return errors.Errorf("%s is not in %s", target, dep.LockName)
+	}
+	d, has := p.Lock.Decisions[target]
+	if !has {
+		return errors.Errorf("%s records no decision for %s; set record-decisions = true in %s to record them each time dep ensure solves", dep.LockName, target, dep.ManifestName)
+	}
+
+	ds := &DecisionStatus{
+		ProjectRoot: string(target),
+		Version:     lp.Version(),
+		Direct:      p.Lock.DirectDependencies()[target],
+		Decision:    d,
+		By:          string(d.By),
+	}
+	if d.By == p.ImportRoot {
+		ds.By = "root"
+	}
+	return out.DecisionLine(ds)
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestDecisionLine(t *testing.T) {
+	ds := &DecisionStatus{
+		ProjectRoot: "github.com/foo/bar",
+		Version:     gps.NewVersion("v1.2.3").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+		Direct:      true,
+		Decision:    gps.Decision{Reason: gps.DecisionTightestConstraint, Constraint: "^1.2.0", By: "github.com/me/root"},
+		By:          "root",
+	}
+
+	var buf bytes.Buffer
+	out := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)}
+	if err := out.DecisionLine(ds); err != nil {
+		t.Fatal(err)
+	}
+	want := `PROJECT     github.com/foo/bar
+VERSION     v1.2.3
+TYPE        direct
+REASON      the newest version allowed by its tightest constraint (tightest-constraint)
+CONSTRAINT  ^1.2.0, from root
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	jout := &jsonOutput{w: &buf}
+	if err := jout.DecisionLine(ds); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"ProjectRoot":"github.com/foo/bar","Version":"v1.2.3","Direct":true,"Reason":"tightest-constraint","Constraint":"^1.2.0","By":"root"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected %s, got %s", want, buf.String())
+	}
+}
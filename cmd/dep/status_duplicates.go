@@ -0,0 +1,208 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// Only a subset of the outputters should be able to output duplicate statuses.
+type duplicatesOutputter interface {
+	DuplicatesHeader() error
+	DuplicatesLine(*DuplicateStatus) error
+	DuplicatesFooter(wasted int64) error
+}
+
+// DuplicateStatus describes a package vendored, file for file, under more than
+// one project root, as happens when a utility repository has been forked or
+// copied into several others.
+type DuplicateStatus struct {
+	// Packages holds the import paths of the identical copies, sorted.
+	Packages []string
+	// Files and Bytes measure a single copy.
+	Files int
+	Bytes int64
+	// Wasted is the number of bytes taken by all but one of the copies.
+	Wasted int64
+	// Keep is the copy to consolidate onto: the one in the project that holds
+	// the most of the duplicated packages.
+	Keep string
+}
+
+func (out *tableOutput) DuplicatesHeader() error {
+	_, err := fmt.Fprintf(out.w, "PACKAGES\tFILES\tSIZE\tWASTED\tKEEP\n")
+	return err
+}
+
+func (out *tableOutput) DuplicatesLine(ds *DuplicateStatus) error {
+	_, err := fmt.Fprintf(out.w,
+		"%s\t%d\t%s\t%s\t%s\t\n",
+		strings.Join(ds.Packages, ", "),
+		ds.Files,
+		formatSize(ds.Bytes),
+		formatSize(ds.Wasted),
+		ds.Keep,
+	)
+	return err
+}
+
+func (out *tableOutput) DuplicatesFooter(wasted int64) error {
+	if _, err := fmt.Fprintf(out.w, "TOTAL\t\t\t%s\t\t\n", formatSize(wasted)); err != nil {
+		return err
+	}
+	return out.w.Flush()
+}
+
+func (out *jsonOutput) DuplicatesHeader() error {
+	out.duplicates = []*DuplicateStatus{}
+	return nil
+}
+
+func (out *jsonOutput) DuplicatesLine(ds *DuplicateStatus) error {
+	out.duplicates = append(out.duplicates, ds)
+	return nil
+}
+
+func (out *jsonOutput) DuplicatesFooter(wasted int64) error {
+	return json.NewEncoder(out.w).Encode(out.duplicates)
+}
+
+func (cmd *statusCommand) runDuplicates(ctx *dep.Ctx, out duplicatesOutputter, p *dep.Project) error {
+	dups, err := findDuplicatePackages(p.VendorDir(), p.Lock.Projects())
+	if err != nil {
+		return errors.Wrap(err, "failed to compare vendored packages")
+	}
+
+	var wasted int64
+	if err := out.DuplicatesHeader(); err != nil {
+		return err
+	}
+	for _, ds := range dups {
+		if err := out.DuplicatesLine(ds); err != nil {
+			return err
+		}
+		wasted += ds.Wasted
+	}
+	return out.DuplicatesFooter(wasted)
+}
+
+// vendoredPackage is a package of a locked project, as found in vendor/.
+type vendoredPackage struct {
+	importPath string
+	pr         gps.ProjectRoot
+	files      int
+	bytes      int64
+}
+
+// findDuplicatePackages finds the packages of the projects in lps that are
+// vendored in vendorDir under more than one project root with identical files.
+// The result is ordered by wasted bytes, largest first.
+func findDuplicatePackages(vendorDir string, lps []gps.LockedProject) ([]*DuplicateStatus, error) {
+	byDigest := make(map[string][]vendoredPackage)
+	for _, lp := range lps {
+		pr := lp.Ident().ProjectRoot
+		for _, pkg := range lp.Packages() {
+			ip := path.Join(string(pr), pkg)
+			dir := filepath.Join(vendorDir, filepath.FromSlash(ip))
+			digest, files, bytes, err := digestPackageDir(dir)
+			if err != nil {
+				return nil, err
+			}
+			if files == 0 {
+				continue
+			}
+			byDigest[digest] = append(byDigest[digest], vendoredPackage{importPath: ip, pr: pr, files: files, bytes: bytes})
+		}
+	}
+
+	// Only copies under different project roots are duplicates.
+	var groups [][]vendoredPackage
+	held := make(map[gps.ProjectRoot]int)
+	for _, pkgs := range byDigest {
+		roots := make(map[gps.ProjectRoot]bool)
+		for _, vp := range pkgs {
+			roots[vp.pr] = true
+		}
+		if len(roots) < 2 {
+			continue
+		}
+		sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].importPath < pkgs[j].importPath })
+		groups = append(groups, pkgs)
+		for pr := range roots {
+			held[pr]++
+		}
+	}
+
+	dups := make([]*DuplicateStatus, 0, len(groups))
+	for _, pkgs := range groups {
+		ds := &DuplicateStatus{Files: pkgs[0].files, Bytes: pkgs[0].bytes}
+		keep := pkgs[0]
+		for _, vp := range pkgs {
+			ds.Packages = append(ds.Packages, vp.importPath)
+			if held[vp.pr] > held[keep.pr] {
+				keep = vp
+			}
+		}
+		ds.Keep = keep.importPath
+		ds.Wasted = ds.Bytes * int64(len(pkgs)-1)
+		dups = append(dups, ds)
+	}
+
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].Wasted != dups[j].Wasted {
+			return dups[i].Wasted > dups[j].Wasted
+		}
+		return dups[i].Packages[0] < dups[j].Packages[0]
+	})
+	return dups, nil
+}
+
+// digestPackageDir returns a digest of the names and contents of the regular
+// files directly within dir, along with their number and total size.
+// Subdirectories, which hold other packages, are not included. A missing dir
+// has no files.
+func digestPackageDir(dir string) (digest string, files int, size int64, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", 0, 0, nil
+		}
+		return "", 0, 0, err
+	}
+
+	h := sha256.New()
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return "", 0, 0, err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", fi.Name(), fi.Size())
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", 0, 0, err
+		}
+		files++
+		size += fi.Size()
+	}
+	return hex.EncodeToString(h.Sum(nil)), files, size, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestFindDuplicatePackages(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	const strutil = "package strutil\n\nfunc Reverse(s string) string { return s }\n"
+	// The same package, copied into three projects, and a second package
+	// copied into two of them, which makes b the project to keep.
+	h.TempFile("vendor/github.com/a/app/strutil/strutil.go", strutil)
+	h.TempFile("vendor/github.com/b/fork/strutil/strutil.go", strutil)
+	h.TempFile("vendor/github.com/b/fork/strutil/sub/ignored.go", "package sub\n")
+	h.TempFile("vendor/github.com/c/lib/internal/strutil/strutil.go", strutil)
+	h.TempFile("vendor/github.com/b/fork/log/log.go", "package log\n")
+	h.TempFile("vendor/github.com/c/lib/log/log.go", "package log\n")
+	// Identical to a's package in name only.
+	h.TempFile("vendor/github.com/d/other/strutil/strutil.go", "package strutil\n")
+	// Duplicates within one project are not reported.
+	h.TempFile("vendor/github.com/d/other/a/doc.go", "package a\n")
+	h.TempFile("vendor/github.com/d/other/b/doc.go", "package a\n")
+
+	mklp := func(pr string, pkgs ...string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr)}, gps.Revision("abc123"), pkgs)
+	}
+	lps := []gps.LockedProject{
+		mklp("github.com/a/app", "strutil"),
+		mklp("github.com/b/fork", "log", "strutil"),
+		mklp("github.com/c/lib", "internal/strutil", "log", "missing"),
+		mklp("github.com/d/other", "a", "b", "strutil"),
+	}
+
+	dups, err := findDuplicatePackages(h.Path("vendor"), lps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(strutil))
+	want := []*DuplicateStatus{
+		{
+			Packages: []string{"github.com/a/app/strutil", "github.com/b/fork/strutil", "github.com/c/lib/internal/strutil"},
+			Files:    1,
+			Bytes:    size,
+			Wasted:   2 * size,
+			Keep:     "github.com/b/fork/strutil",
+		},
+		{
+			Packages: []string{"github.com/b/fork/log", "github.com/c/lib/log"},
+			Files:    1,
+			Bytes:    int64(len("package log\n")),
+			Wasted:   int64(len("package log\n")),
+			Keep:     "github.com/b/fork/log",
+		},
+	}
+	if !reflect.DeepEqual(dups, want) {
+		t.Fatalf("unexpected duplicates:\n\t(GOT): %+v\n\t(WNT): %+v", dups, want)
+	}
+
+	var buf bytes.Buffer
+	out := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)}
+	out.DuplicatesHeader()
+	out.DuplicatesLine(want[1])
+	out.DuplicatesFooter(want[1].Wasted)
+	wantTable := "PACKAGES                                     FILES  SIZE  WASTED  KEEP\n" +
+		"github.com/b/fork/log, github.com/c/lib/log  1      12 B  12 B    github.com/b/fork/log  \n" +
+		"TOTAL                                                     12 B                           \n"
+	if got := buf.String(); got != wantTable {
+		t.Errorf("unexpected table output:\n%s\nexpected:\n%s", got, wantTable)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep/gps"
+)
+
+// feedOutput writes the statuses of -old as a changeset feed, a JSON document
+// meant to be consumed by tools that open automated update pull requests.
+type feedOutput struct {
+	w       io.Writer
+	changes []*FeedChange
+}
+
+// FeedChange is a single update in the document written by -feed.
+type FeedChange struct {
+	ProjectRoot string
+	Source      string `json:",omitempty"`
+	Constraint  string
+	// Current and Candidate are the version in use and the one the project
+	// would be updated to, which are revisions if not on a tag or branch.
+	Current           string
+	CurrentRevision   string
+	Candidate         string
+	CandidateRevision string
+	// ChangelogURL, if known for the project's host, compares Current to
+	// Candidate.
+	ChangelogURL string `json:",omitempty"`
+	// Breaking hints that the update is a new major version, or a new minor
+	// version of a project that has yet to reach 1.0.0.
+	Breaking bool
+}
+
+func (out *feedOutput) OldHeader() error {
+	out.changes = []*FeedChange{}
+	return nil
+}
+
+func (out *feedOutput) OldLine(os *OldStatus) error {
+	out.changes = append(out.changes, newFeedChange(os))
+	return nil
+}
+
+func (out *feedOutput) OldFooter() error {
+	return json.NewEncoder(out.w).Encode(struct{ Changes []*FeedChange }{out.changes})
+}
+
+func newFeedChange(os *OldStatus) *FeedChange {
+	fc := &FeedChange{
+		ProjectRoot:       string(os.ident.ProjectRoot),
+		Source:            os.ident.Source,
+		Constraint:        os.getConsolidatedConstraint(),
+		Current:           feedVersion(os.locked),
+		CurrentRevision:   string(os.Revision),
+		Candidate:         feedVersion(os.candidate),
+		CandidateRevision: os.Latest.String(),
+		Breaking:          isBreakingUpdate(os.locked, os.candidate),
+	}
+	fc.ChangelogURL = changelogURL(os.ident, compareRef(os.locked), compareRef(os.candidate))
+	return fc
+}
+
+// feedVersion returns the tag or branch of v, or its revision if it is on
+// neither.
+func feedVersion(v gps.Version) string {
+	if pv, ok := v.(gps.PairedVersion); ok {
+		return pv.Unpair().String()
+	}
+	return v.String()
+}
+
+// compareRef returns the name that a host can compare v by: its tag if it has
+// one, and its revision otherwise, as branches move.
+func compareRef(v gps.Version) string {
+	if pv, ok := v.(gps.PairedVersion); ok {
+		if pv.Type() == gps.IsBranch {
+			return pv.Revision().String()
+		}
+		return pv.Unpair().String()
+	}
+	return v.String()
+}
+
+// isBreakingUpdate reports whether updating from one semantic version to
+// another is likely to break its users: a major version change, or a minor
+// version change within 0.x. Updates not between semantic versions never are.
+func isBreakingUpdate(from, to gps.Version) bool {
+	if from == nil || to == nil || from.Type() != gps.IsSemver || to.Type() != gps.IsSemver {
+		return false
+	}
+	fv, err := semver.NewVersion(feedVersion(from))
+	if err != nil {
+		return false
+	}
+	tv, err := semver.NewVersion(feedVersion(to))
+	if err != nil {
+		return false
+	}
+	if fv.Major() != tv.Major() {
+		return true
+	}
+	return fv.Major() == 0 && fv.Minor() != tv.Minor()
+}
+
+// changelogURL returns the URL of the page comparing from to to in the project
+// identified by pi, for the hosts known to have one, or "" otherwise.
+func changelogURL(pi gps.ProjectIdentifier, from, to string) string {
+	repo := string(pi.ProjectRoot)
+	if pi.Source != "" {
+		repo = pi.Source
+		for _, scheme := range []string{"https://", "http://", "ssh://", "git://"} {
+			repo = strings.TrimPrefix(repo, scheme)
+		}
+		if strings.HasPrefix(repo, "git@") {
+			repo = strings.Replace(strings.TrimPrefix(repo, "git@"), ":", "/", 1)
+		}
+		repo = strings.TrimSuffix(repo, ".git")
+	}
+
+	parts := strings.Split(repo, "/")
+	switch {
+	case parts[0] == "github.com" && len(parts) >= 3:
+		return "https://" + strings.Join(parts[:3], "/") + "/compare/" + from + "..." + to
+	case parts[0] == "gitlab.com" && len(parts) >= 3:
+		return "https://" + repo + "/compare/" + from + "..." + to
+	}
+	return ""
+}
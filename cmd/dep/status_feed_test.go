@@ -0,0 +1,79 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestFeedOutput(t *testing.T) {
+	from := gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d")
+	to := gps.NewVersion("v0.9.1").Pair("816c9085562cd7ee03e7f8188a1cfd942858cded")
+	os := &OldStatus{
+		ProjectRoot: "github.com/pkg/errors",
+		Constraint:  gps.Any(),
+		Revision:    from.Revision(),
+		Latest:      to.Revision(),
+		ident:       gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors"},
+		locked:      from,
+		candidate:   to,
+	}
+
+	var buf bytes.Buffer
+	out := &feedOutput{w: &buf}
+	out.OldHeader()
+	out.OldLine(os)
+	out.OldFooter()
+
+	want := `{"Changes":[{"ProjectRoot":"github.com/pkg/errors","Constraint":"*",` +
+		`"Current":"v0.8.0","CurrentRevision":"645ef00459ed84a119197bfb8d8205042c6df63d",` +
+		`"Candidate":"v0.9.1","CandidateRevision":"816c9085562cd7ee03e7f8188a1cfd942858cded",` +
+		`"ChangelogURL":"https://github.com/pkg/errors/compare/v0.8.0...v0.9.1","Breaking":true}]}`
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Errorf("unexpected feed:\n\t(GOT) %s\n\t(WNT) %s", got, want)
+	}
+}
+
+func TestIsBreakingUpdate(t *testing.T) {
+	rev := gps.Revision("645ef00459ed84a119197bfb8d8205042c6df63d")
+	cases := []struct {
+		from, to gps.Version
+		want     bool
+	}{
+		{gps.NewVersion("v1.2.0").Pair(rev), gps.NewVersion("v1.3.0").Pair(rev), false},
+		{gps.NewVersion("v1.2.0").Pair(rev), gps.NewVersion("v2.0.0").Pair(rev), true},
+		{gps.NewVersion("0.2.0").Pair(rev), gps.NewVersion("0.2.3").Pair(rev), false},
+		{gps.NewVersion("0.2.0").Pair(rev), gps.NewVersion("0.3.0").Pair(rev), true},
+		{gps.NewBranch("master").Pair(rev), gps.NewBranch("master").Pair(rev), false},
+		{rev, gps.NewVersion("v2.0.0").Pair(rev), false},
+	}
+	for _, c := range cases {
+		if got := isBreakingUpdate(c.from, c.to); got != c.want {
+			t.Errorf("isBreakingUpdate(%s, %s) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestChangelogURL(t *testing.T) {
+	cases := []struct {
+		pi   gps.ProjectIdentifier
+		want string
+	}{
+		{gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors"}, "https://github.com/pkg/errors/compare/a...b"},
+		{gps.ProjectIdentifier{ProjectRoot: "gitlab.com/group/sub/project"}, "https://gitlab.com/group/sub/project/compare/a...b"},
+		{gps.ProjectIdentifier{ProjectRoot: "golang.org/x/net"}, ""},
+		{gps.ProjectIdentifier{ProjectRoot: "golang.org/x/net", Source: "https://github.com/golang/net.git"}, "https://github.com/golang/net/compare/a...b"},
+		{gps.ProjectIdentifier{ProjectRoot: "example.com/foo", Source: "git@github.com:fork/foo.git"}, "https://github.com/fork/foo/compare/a...b"},
+	}
+	for _, c := range cases {
+		if got := changelogURL(c.pi, "a", "b"); got != c.want {
+			t.Errorf("changelogURL(%v) = %q, want %q", c.pi, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// statusFilter selects the dependencies shown by status, as given with
+// -filter. A dependency is shown only if it meets every condition set.
+type statusFilter struct {
+	// outdated selects the dependencies whose latest allowed version is not
+	// the one in use.
+	outdated bool
+	// unverified selects the dependencies whose copy in vendor/ fails
+	// verification.
+	unverified bool
+	// directOnly selects the dependencies imported by the root project, and
+	// transitiveOnly those it only depends on through others, as classified by
+	// the lock.
+	directOnly, transitiveOnly bool
+	// metadata selects the dependencies for whose constraints each key of the
+	// metadata has the given value.
+	metadata map[string]string
+}
+
+// parseStatusFilter parses the comma-separated conditions of -filter.
+func parseStatusFilter(s string) (statusFilter, error) {
+	var f statusFilter
+	for _, cond := range strings.Split(s, ",") {
+		cond = strings.TrimSpace(cond)
+		switch cond {
+		case "outdated":
+			f.outdated = true
+		case "unverified":
+			f.unverified = true
+		case "direct-only":
+			f.directOnly = true
+		case "transitive-only":
+			f.transitiveOnly = true
+		default:
+			eq := strings.IndexByte(cond, '=')
+			if eq <= 0 {
+				return statusFilter{}, errors.Errorf("invalid -filter condition %q; must be one of outdated, unverified, direct-only, transitive-only or <key>=<value>", cond)
+			}
+			if f.metadata == nil {
+				f.metadata = make(map[string]string)
+			}
+			f.metadata[strings.TrimSpace(cond[:eq])] = strings.TrimSpace(cond[eq+1:])
+		}
+	}
+	return f, nil
+}
+
+// filterStatuses returns the projects in slp whose statuses in dsMap meet the
+// conditions of f.
+func filterStatuses(f statusFilter, slp []gps.LockedProject, m *dep.Manifest, dsMap map[string]*DetailStatus) []gps.LockedProject {
+	var shown []gps.LockedProject
+	for _, proj := range slp {
+		pr := proj.Ident().ProjectRoot
+		ds := dsMap[string(pr)]
+		if f.outdated && !ds.isOutdated() {
+			continue
+		}
+		if f.unverified && ds.Verification == formatVendorStatus(verify.NoMismatch) {
+			continue
+		}
+		if f.directOnly && !ds.Direct || f.transitiveOnly && ds.Direct {
+			continue
+		}
+		matches := true
+		for k, v := range f.metadata {
+			if m.ConstraintMetadata[pr][k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			shown = append(shown, proj)
+		}
+	}
+	return shown
+}
+
+// A statusColumn is a column of the table that -columns selects.
+type statusColumn struct {
+	header string
+	cell   func(ds *DetailStatus) string
+}
+
+// statusColumnNames lists the columns that -columns accepts, in the order of
+// the default -detail table.
+var statusColumnNames = []string{"project", "source", "constraint", "version", "revision", "latest", "pkgs", "type", "packages", "reason", "verification"}
+
+var statusColumns = map[string]statusColumn{
+	"project":      {"PROJECT", func(ds *DetailStatus) string { return ds.ProjectRoot }},
+	"source":       {"SOURCE", func(ds *DetailStatus) string { return ds.Source }},
+	"constraint":   {"CONSTRAINT", func(ds *DetailStatus) string { return ds.getConsolidatedConstraint() }},
+	"version":      {"VERSION", func(ds *DetailStatus) string { return formatVersion(ds.Version) }},
+	"revision":     {"REVISION", func(ds *DetailStatus) string { return formatVersion(ds.Revision) }},
+	"latest":       {"LATEST", func(ds *DetailStatus) string { return ds.getConsolidatedLatest(shortRev) }},
+	"pkgs":         {"PKGS USED", func(ds *DetailStatus) string { return fmt.Sprint(ds.PackageCount) }},
+	"type":         {"TYPE", func(ds *DetailStatus) string { return dependencyType(ds.Direct) }},
+	"packages":     {"PACKAGES", func(ds *DetailStatus) string { return strings.Join(ds.Packages, ", ") }},
+	"reason":       {"REASON", func(ds *DetailStatus) string { return ds.Reason }},
+	"verification": {"VERIFICATION", func(ds *DetailStatus) string { return ds.Verification }},
+}
+
+// dependencyType names the kind of dependency of the root project a project is.
+func dependencyType(direct bool) string {
+	if direct {
+		return "direct"
+	}
+	return "transitive"
+}
+
+// parseStatusColumns parses the comma-separated column names of -columns.
+func parseStatusColumns(s string) ([]statusColumn, error) {
+	var cols []statusColumn
+	for _, name := range strings.Split(s, ",") {
+		col, has := statusColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !has {
+			return nil, errors.Errorf("invalid -columns column %q; must be one of %s", name, strings.Join(statusColumnNames, ", "))
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+func (out *tableOutput) columnsHeader() error {
+	headers := make([]string, len(out.columns))
+	for i, col := range out.columns {
+		headers[i] = col.header
+	}
+	_, err := fmt.Fprintln(out.w, strings.Join(headers, "\t"))
+	return err
+}
+
+func (out *tableOutput) columnsLine(ds *DetailStatus) error {
+	cells := make([]string, len(out.columns))
+	for i, col := range out.columns {
+		cells[i] = col.cell(ds)
+	}
+	_, err := fmt.Fprintln(out.w, strings.Join(cells, "\t"))
+	return err
+}
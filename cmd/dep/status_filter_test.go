@@ -0,0 +1,111 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+)
+
+func TestParseStatusFilter(t *testing.T) {
+	f, err := parseStatusFilter("outdated, direct-only,label=storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := statusFilter{outdated: true, directOnly: true, metadata: map[string]string{"label": "storage"}}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("unexpected filter:\n\t(GOT) %+v\n\t(WNT) %+v", f, want)
+	}
+
+	for _, s := range []string{"", "stale", "=storage"} {
+		if _, err := parseStatusFilter(s); err == nil {
+			t.Errorf("expected an error for -filter %q", s)
+		}
+	}
+}
+
+func TestFilterStatuses(t *testing.T) {
+	rev := gps.Revision("3f4c3bea144e112a69bbe5d8d01c1b09a544253f")
+	newer := gps.NewVersion("v1.1.0").Pair("6c2b7d8b1c4e1a3cd70f1f2e8b603b1e8d0c90aa")
+
+	m := dep.NewManifest()
+	m.ConstraintMetadata["github.com/a/a"] = map[string]string{"label": "storage"}
+	m.ConstraintMetadata["github.com/c/c"] = map[string]string{"label": "storage"}
+
+	var slp []gps.LockedProject
+	dsMap := make(map[string]*DetailStatus)
+	for _, pr := range []string{"github.com/a/a", "github.com/b/b", "github.com/c/c", "github.com/d/d"} {
+		slp = append(slp, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr)}, rev, nil))
+		dsMap[pr] = &DetailStatus{BasicStatus: BasicStatus{ProjectRoot: pr, Revision: rev, Latest: rev, Direct: pr != "github.com/b/b", Verification: formatVendorStatus(verify.NoMismatch)}}
+	}
+	dsMap["github.com/b/b"].Latest = newer
+	dsMap["github.com/c/c"].Latest = newer
+	dsMap["github.com/d/d"].Verification = formatVendorStatus(verify.DigestMismatchInLock)
+
+	cases := []struct {
+		filter string
+		want   []string
+	}{
+		{"outdated", []string{"github.com/b/b", "github.com/c/c"}},
+		{"unverified", []string{"github.com/d/d"}},
+		{"direct-only", []string{"github.com/a/a", "github.com/c/c", "github.com/d/d"}},
+		{"transitive-only", []string{"github.com/b/b"}},
+		{"label=storage", []string{"github.com/a/a", "github.com/c/c"}},
+		{"outdated,direct-only", []string{"github.com/c/c"}},
+		{"outdated,unverified", nil},
+	}
+	for _, c := range cases {
+		f, err := parseStatusFilter(c.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, proj := range filterStatuses(f, slp, m, dsMap) {
+			got = append(got, string(proj.Ident().ProjectRoot))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("-filter %s: expected %v, got %v", c.filter, c.want, got)
+		}
+	}
+}
+
+func TestStatusColumns(t *testing.T) {
+	cols, err := parseStatusColumns("Project,latest,version,type")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	out := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0), columns: cols}
+	ds := &DetailStatus{BasicStatus: BasicStatus{
+		ProjectRoot: "github.com/a/a",
+		Version:     gps.NewVersion("v1.0.0"),
+		Revision:    "3f4c3bea144e112a69bbe5d8d01c1b09a544253f",
+		Latest:      gps.NewVersion("v1.1.0").Pair("6c2b7d8b1c4e1a3cd70f1f2e8b603b1e8d0c90aa"),
+		Direct:      true,
+	}}
+	if err := out.DetailHeader(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.DetailLine(ds); err != nil {
+		t.Fatal(err)
+	}
+	out.w.Flush()
+	want := "PROJECT         LATEST  VERSION  TYPE\n" +
+		"github.com/a/a  v1.1.0  v1.0.0   direct\n"
+	if buf.String() != want {
+		t.Errorf("unexpected table:\n\t(GOT) %q\n\t(WNT) %q", buf.String(), want)
+	}
+
+	if _, err := parseStatusColumns("project,age"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+)
+
+// Only a subset of the outputters set apart the groups of dependencies made
+// with -group-by; the others mark each dependency with its group.
+type groupOutputter interface {
+	GroupHeader(*StatusGroup) error
+}
+
+// StatusGroup is a set of the dependencies reported by status that share the
+// same value for a key of the metadata of their constraints.
+type StatusGroup struct {
+	// Key is the metadata key that the dependencies are grouped by, and Value
+	// its value for those in the group. Value is empty for the dependencies
+	// without one.
+	Key   string
+	Value string
+	// Outdated is the number of dependencies in the group whose latest allowed
+	// version is not the one in use, and Unverified the number whose copy in
+	// vendor/ failed verification.
+	Outdated   int
+	Unverified int
+
+	projects []gps.LockedProject
+}
+
+func (out *tableOutput) GroupHeader(g *StatusGroup) error {
+	value := g.Value
+	if value == "" {
+		value = "(none)"
+	}
+	summary := fmt.Sprintf("%d of %d outdated", g.Outdated, len(g.projects))
+	if out.verify {
+		summary += fmt.Sprintf(", %d failing verification", g.Unverified)
+	}
+	_, err := fmt.Fprintf(out.w, "%s: %s (%s)\n", g.Key, value, summary)
+	return err
+}
+
+// groupStatuses groups the projects in slp by the value of key in the metadata
+// of their constraints in m, recording the group of each in dsMap. The groups
+// are ordered by value, with the projects without one last. If key is empty,
+// all of the projects are returned in a single group.
+func groupStatuses(slp []gps.LockedProject, m *dep.Manifest, key string, dsMap map[string]*DetailStatus) []*StatusGroup {
+	if key == "" {
+		return []*StatusGroup{{projects: slp}}
+	}
+
+	byValue := make(map[string]*StatusGroup)
+	for _, proj := range slp {
+		pr := proj.Ident().ProjectRoot
+		value := m.ConstraintMetadata[pr][key]
+		g, has := byValue[value]
+		if !has {
+			g = &StatusGroup{Key: key, Value: value}
+			byValue[value] = g
+		}
+		g.projects = append(g.projects, proj)
+
+		ds := dsMap[string(pr)]
+		ds.Group = value
+		if ds.isOutdated() {
+			g.Outdated++
+		}
+		if ds.Verification != "" && ds.Verification != formatVendorStatus(verify.NoMismatch) {
+			g.Unverified++
+		}
+	}
+
+	groups := make([]*StatusGroup, 0, len(byValue))
+	for _, g := range byValue {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].Value == "") != (groups[j].Value == "") {
+			return groups[j].Value == ""
+		}
+		return groups[i].Value < groups[j].Value
+	})
+	return groups
+}
+
+// isOutdated reports whether the latest version of the project allowed by its
+// constraint is at a different revision than the one in use.
+func (bs *BasicStatus) isOutdated() bool {
+	switch latest := bs.Latest.(type) {
+	case gps.PairedVersion:
+		return latest.Revision() != bs.Revision
+	case gps.Revision:
+		return latest != bs.Revision
+	}
+	return false
+}
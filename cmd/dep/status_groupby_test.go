@@ -0,0 +1,74 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+)
+
+func TestGroupStatuses(t *testing.T) {
+	rev := gps.Revision("3f4c3bea144e112a69bbe5d8d01c1b09a544253f")
+	newer := gps.NewVersion("v1.1.0").Pair("6c2b7d8b1c4e1a3cd70f1f2e8b603b1e8d0c90aa")
+
+	m := dep.NewManifest()
+	m.ConstraintMetadata["github.com/a/a"] = map[string]string{"label": "storage"}
+	m.ConstraintMetadata["github.com/b/b"] = map[string]string{"label": "platform", "reason": "logging"}
+	m.ConstraintMetadata["github.com/c/c"] = map[string]string{"label": "storage"}
+
+	var slp []gps.LockedProject
+	dsMap := make(map[string]*DetailStatus)
+	for _, pr := range []string{"github.com/a/a", "github.com/b/b", "github.com/c/c", "github.com/d/d"} {
+		slp = append(slp, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr)}, rev, nil))
+		dsMap[pr] = &DetailStatus{BasicStatus: BasicStatus{ProjectRoot: pr, Revision: rev, Latest: rev}}
+	}
+	dsMap["github.com/c/c"].Latest = newer
+	dsMap["github.com/a/a"].Verification = formatVendorStatus(verify.NotInTree)
+
+	groups := groupStatuses(slp, m, "label", dsMap)
+	want := []struct {
+		value                string
+		n, outdated, unverif int
+	}{
+		{"platform", 1, 0, 0},
+		{"storage", 2, 1, 1},
+		{"", 1, 0, 0},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("expected %d groups, got %d", len(want), len(groups))
+	}
+	for i, w := range want {
+		g := groups[i]
+		if g.Key != "label" || g.Value != w.value || len(g.projects) != w.n || g.Outdated != w.outdated || g.Unverified != w.unverif {
+			t.Errorf("group %d: expected value %q with %d projects, %d outdated and %d unverified, got %q with %d, %d and %d",
+				i, w.value, w.n, w.outdated, w.unverif, g.Value, len(g.projects), g.Outdated, g.Unverified)
+		}
+	}
+	if got := dsMap["github.com/c/c"].Group; got != "storage" {
+		t.Errorf("expected the group of github.com/c/c to be recorded as storage, got %q", got)
+	}
+
+	if groups := groupStatuses(slp, m, "", dsMap); len(groups) != 1 || len(groups[0].projects) != len(slp) {
+		t.Errorf("expected a single group of all projects without a key")
+	}
+}
+
+func TestGroupHeader(t *testing.T) {
+	var buf bytes.Buffer
+	out := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0), verify: true}
+	g := &StatusGroup{Key: "label", Outdated: 1, Unverified: 2, projects: make([]gps.LockedProject, 3)}
+	if err := out.GroupHeader(g); err != nil {
+		t.Fatal(err)
+	}
+	out.w.Flush()
+	if want := "label: (none) (1 of 3 outdated, 2 failing verification)\n"; buf.String() != want {
+		t.Errorf("unexpected group header:\n\t(GOT) %q\n\t(WNT) %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/depcheck"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+)
+
+// DependencyMetrics holds the counts reported by -metrics, summarizing the
+// health of the project's dependencies.
+type DependencyMetrics struct {
+	// Total is the number of projects in the lock.
+	Total int
+	// Outdated is the number of them that -old would list.
+	Outdated int
+	// Vulnerable is the number of them affected by a known advisory. It is
+	// only known if a source of advisories is configured.
+	Vulnerable      int
+	knowsVulnerable bool
+	// Unverified is the number of them whose copy in vendor/ does not match
+	// the digest recorded in the lock, for whatever reason.
+	Unverified int
+	// OldestAge is how long ago the oldest locked revision was made. It is
+	// only known if the time of at least one revision could be found.
+	OldestAge   time.Duration
+	knowsOldest bool
+}
+
+// revisionTimer finds out when revisions were made, as *gps.SourceMgr does.
+type revisionTimer interface {
+	RevisionTime(gps.ProjectIdentifier, gps.Revision) (time.Time, error)
+}
+
+func (cmd *statusCommand) runMetrics(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, rt revisionTimer, now time.Time) (*DependencyMetrics, error) {
+	lps := p.Lock.Projects()
+	m := &DependencyMetrics{Total: len(lps)}
+
+	old, err := collectOldStatuses(ctx, p, sm)
+	if err != nil {
+		return nil, err
+	}
+	m.Outdated = len(old)
+
+	if ctx.Advisories != "" {
+		advisories, err := dep.LoadAdvisories(context.TODO(), ctx.Advisories, ctx.NetworkPolicy)
+		if err != nil {
+			return nil, err
+		}
+		m.knowsVulnerable = true
+		for _, lp := range lps {
+			if len(advisories.Affecting(lp)) > 0 {
+				m.Vulnerable++
+			}
+		}
+	}
+
+	status, err := depcheck.VendorStatus(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, lp := range lps {
+		if status[string(lp.Ident().ProjectRoot)] != verify.NoMismatch {
+			m.Unverified++
+		}
+	}
+
+	times := make([]time.Time, len(lps))
+	errs := make([]error, len(lps))
+	var wg sync.WaitGroup
+	for i, lp := range lps {
+		wg.Add(1)
+		go func(i int, lp gps.LockedProject) {
+			defer wg.Done()
+			rev, _, _ := gps.VersionComponentStrings(lp.Version())
+			times[i], errs[i] = rt.RevisionTime(lp.Ident(), gps.Revision(rev))
+		}(i, lp)
+	}
+	wg.Wait()
+
+	for i, lp := range lps {
+		if errs[i] != nil {
+			ctx.Err.Printf("Unable to determine the age of %s: %s\n", lp.Ident().ProjectRoot, errs[i])
+			continue
+		}
+		if age := now.Sub(times[i]); !m.knowsOldest || age > m.OldestAge {
+			m.OldestAge, m.knowsOldest = age, true
+		}
+	}
+	return m, nil
+}
+
+// formatMetrics renders m as lines in the given format, either the plaintext
+// protocol of Graphite, stamped with now, or statsd gauges.
+func formatMetrics(m *DependencyMetrics, format string, now time.Time) string {
+	type metric struct {
+		name  string
+		value int64
+	}
+	metrics := []metric{
+		{"dep.deps.total", int64(m.Total)},
+		{"dep.deps.outdated", int64(m.Outdated)},
+	}
+	if m.knowsVulnerable {
+		metrics = append(metrics, metric{"dep.deps.vulnerable", int64(m.Vulnerable)})
+	}
+	metrics = append(metrics, metric{"dep.deps.unverified", int64(m.Unverified)})
+	if m.knowsOldest {
+		metrics = append(metrics, metric{"dep.deps.oldest_age_days", int64(m.OldestAge / (24 * time.Hour))})
+	}
+
+	var buf bytes.Buffer
+	for _, mt := range metrics {
+		switch format {
+		case "statsd":
+			fmt.Fprintf(&buf, "%s:%d|g\n", mt.name, mt.value)
+		default:
+			fmt.Fprintf(&buf, "%s %d %d\n", mt.name, mt.value, now.Unix())
+		}
+	}
+	return buf.String()
+}
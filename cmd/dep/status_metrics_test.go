@@ -0,0 +1,39 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatMetrics(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := &DependencyMetrics{
+		Total:       12,
+		Outdated:    3,
+		Unverified:  1,
+		OldestAge:   400*24*time.Hour + time.Hour,
+		knowsOldest: true,
+	}
+
+	want := "dep.deps.total 12 1527854400\n" +
+		"dep.deps.outdated 3 1527854400\n" +
+		"dep.deps.unverified 1 1527854400\n" +
+		"dep.deps.oldest_age_days 400 1527854400\n"
+	if got := formatMetrics(m, "", now); got != want {
+		t.Errorf("unexpected graphite metrics:\n%s\nwanted:\n%s", got, want)
+	}
+
+	m.Vulnerable, m.knowsVulnerable = 2, true
+	m.knowsOldest = false
+	want = "dep.deps.total:12|g\n" +
+		"dep.deps.outdated:3|g\n" +
+		"dep.deps.vulnerable:2|g\n" +
+		"dep.deps.unverified:1|g\n"
+	if got := formatMetrics(m, "statsd", now); got != want {
+		t.Errorf("unexpected statsd metrics:\n%s\nwanted:\n%s", got, want)
+	}
+}
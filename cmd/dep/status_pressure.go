@@ -0,0 +1,366 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// pressureMaxAllowed is the largest number of available versions that the
+// combined constraints on a project may allow for it to be reported as under
+// pressure.
+const pressureMaxAllowed = 2
+
+// Only a subset of the outputters should be able to output pressure statuses.
+type pressureOutputter interface {
+	PressureHeader() error
+	PressureLine(*PressureStatus) error
+	PressureFooter() error
+}
+
+// PressureStatus describes a project that is constrained by several dependers
+// whose constraints, taken together, leave few of its versions to choose from.
+type PressureStatus struct {
+	ProjectRoot string
+	// Versions is the number of versions available from the project's source.
+	Versions int
+	// Allowed is the number of those versions that satisfy all constraints.
+	Allowed int
+	// Constrainers is the number of dependers that constrain the project.
+	Constrainers int
+	// Tightest holds the two constraints that, between them, allow the fewest
+	// versions.
+	Tightest []PressureConstraint
+}
+
+// PressureConstraint is a constraint on a project, along with the shortest
+// chain of imports from the root project to the depender that declares it.
+type PressureConstraint struct {
+	Constraint gps.Constraint
+	// Allowed is the number of available versions the constraint allows on
+	// its own.
+	Allowed int
+	// Path starts with "root" and ends with the depender.
+	Path []string
+}
+
+type rawPressureConstraint struct {
+	Constraint string
+	Allowed    int
+	Path       []string
+}
+
+type rawPressureStatus struct {
+	ProjectRoot  string
+	Versions     int
+	Allowed      int
+	Constrainers int
+	Tightest     []rawPressureConstraint
+}
+
+func (ps *PressureStatus) marshalJSON() *rawPressureStatus {
+	raw := &rawPressureStatus{
+		ProjectRoot:  ps.ProjectRoot,
+		Versions:     ps.Versions,
+		Allowed:      ps.Allowed,
+		Constrainers: ps.Constrainers,
+	}
+	for _, pc := range ps.Tightest {
+		raw.Tightest = append(raw.Tightest, rawPressureConstraint{
+			Constraint: formatConstraint(pc.Constraint),
+			Allowed:    pc.Allowed,
+			Path:       pc.Path,
+		})
+	}
+	return raw
+}
+
+func (out *tableOutput) PressureHeader() error {
+	_, err := fmt.Fprintf(out.w, "PROJECT\tALLOWED\tCONSTRAINERS\tTIGHTEST CONSTRAINTS\n")
+	return err
+}
+
+func (out *tableOutput) PressureLine(ps *PressureStatus) error {
+	tightest := make([]string, 0, len(ps.Tightest))
+	for _, pc := range ps.Tightest {
+		tightest = append(tightest, fmt.Sprintf("%s from %s", formatConstraint(pc.Constraint), strings.Join(pc.Path, " -> ")))
+	}
+	_, err := fmt.Fprintf(out.w,
+		"%s\t%d of %d\t%d\t%s\t\n",
+		ps.ProjectRoot,
+		ps.Allowed,
+		ps.Versions,
+		ps.Constrainers,
+		strings.Join(tightest, "; "),
+	)
+	return err
+}
+
+func (out *tableOutput) PressureFooter() error {
+	return out.w.Flush()
+}
+
+func (out *jsonOutput) PressureHeader() error {
+	out.pressure = []*rawPressureStatus{}
+	return nil
+}
+
+func (out *jsonOutput) PressureLine(ps *PressureStatus) error {
+	out.pressure = append(out.pressure, ps.marshalJSON())
+	return nil
+}
+
+func (out *jsonOutput) PressureFooter() error {
+	return json.NewEncoder(out.w).Encode(out.pressure)
+}
+
+// dependerInfo holds what a project in the dependency graph contributes to
+// the pressure analysis: the constraints its manifest declares, and the
+// projects imported by the packages used from it.
+type dependerInfo struct {
+	constraints gps.ProjectConstraints
+	imports     map[gps.ProjectRoot]bool
+}
+
+func (cmd *statusCommand) runPressure(ctx *dep.Ctx, out pressureOutputter, p *dep.Project, sm gps.SourceManager) error {
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	directDeps, err := p.GetDirectDependencyNames(sm)
+	if err != nil {
+		return errors.Wrap(err, "failed to get direct dependencies")
+	}
+	rootAnalyzer := newRootAnalyzer(true, ctx, directDeps, sm)
+
+	lps := p.Lock.Projects()
+	roots := make([]gps.ProjectRoot, len(lps))
+	for i, lp := range lps {
+		roots[i] = lp.Ident().ProjectRoot
+	}
+
+	graph := map[gps.ProjectRoot]*dependerInfo{
+		"root": {constraints: p.Manifest.Constraints, imports: directDeps},
+	}
+
+	logger.Println("Collecting project constraints and imports:")
+	infos := make([]*dependerInfo, len(lps))
+	errs := make([]error, len(lps))
+	var wg sync.WaitGroup
+	for i, lp := range lps {
+		wg.Add(1)
+		logger.Printf("(%d/%d) %s\n", i+1, len(lps), lp.Ident().ProjectRoot)
+		go func(i int, lp gps.LockedProject) {
+			defer wg.Done()
+			infos[i], errs[i] = collectDependerInfo(lp, roots, sm, rootAnalyzer)
+		}(i, lp)
+	}
+	wg.Wait()
+
+	for i, lp := range lps {
+		if errs[i] != nil {
+			ctx.Err.Printf("Unable to read the constraints and imports of %s: %s\n", lp.Ident().ProjectRoot, errs[i])
+			continue
+		}
+		graph[lp.Ident().ProjectRoot] = infos[i]
+	}
+
+	versions := make(map[gps.ProjectRoot][]gps.Version)
+	for _, lp := range lps {
+		pr := lp.Ident().ProjectRoot
+		if len(constrainersOf(pr, graph)) < 2 {
+			continue
+		}
+		pvl, err := sm.ListVersions(lp.Ident())
+		if err != nil {
+			ctx.Err.Printf("Unable to list versions of %s: %s\n", pr, err)
+			continue
+		}
+		for _, pv := range pvl {
+			versions[pr] = append(versions[pr], pv)
+		}
+	}
+
+	if err := out.PressureHeader(); err != nil {
+		return err
+	}
+	for _, ps := range analyzePressure(graph, p.Manifest.Ovr, versions) {
+		if err := out.PressureLine(ps); err != nil {
+			return err
+		}
+	}
+	return out.PressureFooter()
+}
+
+// collectDependerInfo reads the constraints in lp's manifest at its locked
+// version, and determines which of the given project roots are imported by the
+// packages used from it.
+func collectDependerInfo(lp gps.LockedProject, roots []gps.ProjectRoot, sm gps.SourceManager, an gps.ProjectAnalyzer) (*dependerInfo, error) {
+	m, _, err := sm.GetManifestAndLock(lp.Ident(), lp.Version(), an)
+	if err != nil {
+		return nil, err
+	}
+	ptree, err := sm.ListPackages(lp.Ident(), lp.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	info := &dependerInfo{
+		constraints: m.DependencyConstraints(),
+		imports:     make(map[gps.ProjectRoot]bool),
+	}
+	for _, pkg := range lp.Packages() {
+		ip := string(lp.Ident().ProjectRoot)
+		if pkg != "." {
+			ip += "/" + pkg
+		}
+		poe, has := ptree.Packages[ip]
+		if !has || poe.Err != nil {
+			continue
+		}
+		for _, imp := range poe.P.Imports {
+			for _, pr := range roots {
+				if pr != lp.Ident().ProjectRoot && (imp == string(pr) || strings.HasPrefix(imp, string(pr)+"/")) {
+					info.imports[pr] = true
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// constrainersOf returns, in order, the projects in graph that both import
+// target and declare a constraint on it. As in the solver, constraints on
+// projects that are not imported have no effect.
+func constrainersOf(target gps.ProjectRoot, graph map[gps.ProjectRoot]*dependerInfo) []gps.ProjectRoot {
+	var constrainers []gps.ProjectRoot
+	for pr, info := range graph {
+		if pp, has := info.constraints[target]; has && pp.Constraint != nil && info.imports[target] {
+			constrainers = append(constrainers, pr)
+		}
+	}
+	sort.Slice(constrainers, func(i, j int) bool { return constrainers[i] < constrainers[j] })
+	return constrainers
+}
+
+// analyzePressure finds the projects whose constraints, from two or more
+// dependers, allow no more than pressureMaxAllowed of the given versions
+// between them, and fewer than any one of those constraints allows alone.
+// Projects with an override in ovr are exempt, as the override replaces their
+// other constraints. The result is ordered from the fewest allowed versions.
+func analyzePressure(graph map[gps.ProjectRoot]*dependerInfo, ovr gps.ProjectConstraints, versions map[gps.ProjectRoot][]gps.Version) []*PressureStatus {
+	var statuses []*PressureStatus
+	for target, vl := range versions {
+		if pp, has := ovr[target]; has && pp.Constraint != nil {
+			continue
+		}
+		constrainers := constrainersOf(target, graph)
+		if len(constrainers) < 2 {
+			continue
+		}
+
+		cs := make([]PressureConstraint, len(constrainers))
+		combined, minAllowed := gps.Any(), len(vl)
+		for i, pr := range constrainers {
+			c := graph[pr].constraints[target].Constraint
+			cs[i] = PressureConstraint{Constraint: c, Allowed: countAllowed(c, vl), Path: importPath(pr, graph)}
+			combined = combined.Intersect(c)
+			if cs[i].Allowed < minAllowed {
+				minAllowed = cs[i].Allowed
+			}
+		}
+
+		allowed := countAllowed(combined, vl)
+		if allowed > pressureMaxAllowed || allowed >= minAllowed {
+			continue
+		}
+
+		// Find the pair of constraints that allow the fewest versions between
+		// them; loosening either is the likeliest way to relieve the pressure.
+		var tightest []PressureConstraint
+		tightestAllowed := len(vl) + 1
+		for i := range cs {
+			for j := i + 1; j < len(cs); j++ {
+				if n := countAllowed(cs[i].Constraint.Intersect(cs[j].Constraint), vl); n < tightestAllowed {
+					tightest, tightestAllowed = []PressureConstraint{cs[i], cs[j]}, n
+				}
+			}
+		}
+
+		statuses = append(statuses, &PressureStatus{
+			ProjectRoot:  string(target),
+			Versions:     len(vl),
+			Allowed:      allowed,
+			Constrainers: len(constrainers),
+			Tightest:     tightest,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Allowed != statuses[j].Allowed {
+			return statuses[i].Allowed < statuses[j].Allowed
+		}
+		return statuses[i].ProjectRoot < statuses[j].ProjectRoot
+	})
+	return statuses
+}
+
+func countAllowed(c gps.Constraint, vl []gps.Version) int {
+	var n int
+	for _, v := range vl {
+		if c.Matches(v) {
+			n++
+		}
+	}
+	return n
+}
+
+// importPath returns the shortest chain of imports from the root project to
+// pr in graph, or just pr if it cannot be reached.
+func importPath(pr gps.ProjectRoot, graph map[gps.ProjectRoot]*dependerInfo) []string {
+	prev := map[gps.ProjectRoot]gps.ProjectRoot{"root": ""}
+	queue := []gps.ProjectRoot{"root"}
+	for len(queue) > 0 && queue[0] != pr {
+		cur := queue[0]
+		queue = queue[1:]
+		info, has := graph[cur]
+		if !has {
+			continue
+		}
+		next := make([]string, 0, len(info.imports))
+		for imp := range info.imports {
+			next = append(next, string(imp))
+		}
+		// Visit in order, so that the path chosen among equally short ones
+		// is deterministic.
+		sort.Strings(next)
+		for _, imp := range next {
+			if _, seen := prev[gps.ProjectRoot(imp)]; !seen {
+				prev[gps.ProjectRoot(imp)] = cur
+				queue = append(queue, gps.ProjectRoot(imp))
+			}
+		}
+	}
+
+	if _, reached := prev[pr]; !reached {
+		return []string{string(pr)}
+	}
+	var path []string
+	for cur := pr; cur != ""; cur = prev[cur] {
+		path = append([]string{string(cur)}, path...)
+	}
+	return path
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestAnalyzePressure(t *testing.T) {
+	semver := func(s string) gps.ProjectProperties {
+		c, err := gps.NewSemverConstraint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gps.ProjectProperties{Constraint: c}
+	}
+	imports := func(prs ...gps.ProjectRoot) map[gps.ProjectRoot]bool {
+		m := make(map[gps.ProjectRoot]bool)
+		for _, pr := range prs {
+			m[pr] = true
+		}
+		return m
+	}
+	versions := func(vs ...string) []gps.Version {
+		var vl []gps.Version
+		for _, v := range vs {
+			vl = append(vl, gps.NewVersion(v))
+		}
+		return vl
+	}
+
+	// root imports a and b; a imports c; b and c both constrain d, as does
+	// root. Only b and c import d.
+	graph := map[gps.ProjectRoot]*dependerInfo{
+		"root": {
+			constraints: gps.ProjectConstraints{"d": semver("^1.0.0")},
+			imports:     imports("a", "b"),
+		},
+		"a": {imports: imports("c")},
+		"b": {
+			constraints: gps.ProjectConstraints{"d": semver(">=1.3.0"), "e": semver("^1.0.0")},
+			imports:     imports("d", "e"),
+		},
+		"c": {
+			constraints: gps.ProjectConstraints{"d": semver("<1.4.0"), "e": semver("^1.1.0")},
+			imports:     imports("d", "e"),
+		},
+	}
+	available := map[gps.ProjectRoot][]gps.Version{
+		"d": versions("v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0", "v1.4.0", "v2.0.0"),
+		// The constraints on e overlap on nearly all of its versions.
+		"e": versions("v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0"),
+	}
+
+	got := analyzePressure(graph, nil, available)
+	if len(got) != 1 {
+		t.Fatalf("expected only d to be under pressure, got %d statuses", len(got))
+	}
+	ps := got[0]
+	if ps.ProjectRoot != "d" || ps.Versions != 6 || ps.Allowed != 1 || ps.Constrainers != 2 {
+		t.Errorf("unexpected pressure status %+v", ps)
+	}
+
+	var paths [][]string
+	for _, pc := range ps.Tightest {
+		paths = append(paths, pc.Path)
+	}
+	wantPaths := [][]string{{"root", "b"}, {"root", "a", "c"}}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("unexpected tightest constraint paths:\n\t(GOT): %v\n\t(WNT): %v", paths, wantPaths)
+	}
+	if ps.Tightest[0].Allowed != 3 || ps.Tightest[1].Allowed != 4 {
+		t.Errorf("unexpected versions allowed by the tightest constraints: %d and %d", ps.Tightest[0].Allowed, ps.Tightest[1].Allowed)
+	}
+
+	// An override replaces the constraints, relieving the pressure.
+	ovr := gps.ProjectConstraints{"d": semver("^1.0.0")}
+	if got := analyzePressure(graph, ovr, available); len(got) != 0 {
+		t.Errorf("expected no pressure on overridden project, got %+v", got[0])
+	}
+}
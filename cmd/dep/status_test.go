@@ -20,6 +20,7 @@ import (
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
 	"github.com/golang/dep/internal/test"
 	"github.com/pkg/errors"
 )
@@ -458,6 +459,15 @@ func TestBasicStatusGetConsolidatedConstraint(t *testing.T) {
 			},
 			wantConstraint: "1.2.1 (override)",
 		},
+		{
+			name: "BasicStatus with Frozen Override",
+			basicStatus: BasicStatus{
+				Constraint:  aSemverConstraint,
+				hasOverride: true,
+				isFrozen:    true,
+			},
+			wantConstraint: "1.2.1 (override) (frozen)",
+		},
 		{
 			name: "BasicStatus with Revision Constraint",
 			basicStatus: BasicStatus{
@@ -840,6 +850,96 @@ func TestValidateFlags(t *testing.T) {
 			cmd:     statusCommand{old: true, template: "foo"},
 			wantErr: nil,
 		},
+		{
+			name:    "size with sort",
+			cmd:     statusCommand{size: true, sortBy: "unpruned"},
+			wantErr: nil,
+		},
+		{
+			name:    "size with old",
+			cmd:     statusCommand{size: true, old: true},
+			wantErr: errors.Wrapf(errors.New("cannot pass multiple operating mode flags"), "[-old -size]"),
+		},
+		{
+			name:    "sort without size",
+			cmd:     statusCommand{sortBy: "size"},
+			wantErr: errors.New("-sort can only be used with -size"),
+		},
+		{
+			name:    "verify with detail",
+			cmd:     statusCommand{verify: true, detail: true},
+			wantErr: nil,
+		},
+		{
+			name:    "verify with size",
+			cmd:     statusCommand{verify: true, size: true},
+			wantErr: errors.New("-verify can only be used with the default and -detail operating modes"),
+		},
+		{
+			name:    "verify with -dot",
+			cmd:     statusCommand{verify: true, dot: true},
+			wantErr: errors.New("-dot generates dependency graph; cannot pass other flags"),
+		},
+		{
+			name:    "who-constrains with detail",
+			cmd:     statusCommand{whoConstrains: "github.com/foo/bar", detail: true},
+			wantErr: errors.Wrapf(errors.New("cannot pass multiple operating mode flags"), "[-detail -who-constrains]"),
+		},
+		{
+			name:    "pressure with verify",
+			cmd:     statusCommand{pressure: true, verify: true},
+			wantErr: errors.New("-verify can only be used with the default and -detail operating modes"),
+		},
+		{
+			name:    "invalid sort",
+			cmd:     statusCommand{size: true, sortBy: "age"},
+			wantErr: errors.New(`invalid -sort "age"; must be one of name, size, files or unpruned`),
+		},
+		{
+			name:    "metrics with format",
+			cmd:     statusCommand{metrics: true, metricsFormat: "statsd"},
+			wantErr: nil,
+		},
+		{
+			name:    "metrics format without metrics",
+			cmd:     statusCommand{metricsFormat: "statsd"},
+			wantErr: errors.New("-metrics-format can only be used with -metrics"),
+		},
+		{
+			name:    "invalid metrics format",
+			cmd:     statusCommand{metrics: true, metricsFormat: "influx"},
+			wantErr: errors.New(`invalid -metrics-format "influx"; must be one of graphite or statsd`),
+		},
+		{
+			name:    "metrics with json",
+			cmd:     statusCommand{metrics: true, json: true},
+			wantErr: errors.New("cannot pass multiple output format flags"),
+		},
+		{
+			name:    "filter with detail",
+			cmd:     statusCommand{filter: "outdated,direct-only", detail: true},
+			wantErr: nil,
+		},
+		{
+			name:    "filter with old",
+			cmd:     statusCommand{filter: "outdated", old: true},
+			wantErr: errors.New("-filter and -columns can only be used with the default and -detail operating modes"),
+		},
+		{
+			name:    "invalid filter",
+			cmd:     statusCommand{filter: "stale"},
+			wantErr: errors.New(`invalid -filter condition "stale"; must be one of outdated, unverified, direct-only, transitive-only or <key>=<value>`),
+		},
+		{
+			name:    "columns with json",
+			cmd:     statusCommand{columns: "project,version", json: true},
+			wantErr: errors.New("-columns only applies to table output; cannot pass it with -json, -f or -lock"),
+		},
+		{
+			name:    "invalid columns",
+			cmd:     statusCommand{columns: "project,age"},
+			wantErr: errors.New(`invalid -columns column "age"; must be one of project, source, constraint, version, revision, latest, pkgs, type, packages, reason, verification`),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -857,6 +957,106 @@ func TestValidateFlags(t *testing.T) {
 	}
 }
 
+func TestStatusVerification(t *testing.T) {
+	for vs, want := range map[verify.VendorStatus]string{
+		verify.NoMismatch:           "verified",
+		verify.DigestMismatchInLock: "hash mismatch",
+		verify.NotInTree:            "missing",
+		verify.EmptyDigestInLock:    "unverifiable",
+		verify.HashVersionMismatch:  "unverifiable",
+	} {
+		if got := formatVendorStatus(vs); got != want {
+			t.Errorf("expected %s to be reported as %q, got %q", vs, want, got)
+		}
+	}
+
+	bs := BasicStatus{
+		ProjectRoot:  "github.com/foo/bar",
+		Revision:     gps.Revision("revxyz"),
+		Verification: "hash mismatch",
+	}
+
+	var buf bytes.Buffer
+	out := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0), verify: true}
+	out.BasicHeader()
+	out.BasicLine(&bs)
+	out.BasicFooter()
+	want := "PROJECT             CONSTRAINT  VERSION  REVISION  LATEST  PKGS USED  VERIFICATION\n" +
+		"github.com/foo/bar                       revxyz            0          hash mismatch  \n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected table output:\n%s\nexpected:\n%s", got, want)
+	}
+
+	buf.Reset()
+	jsonout := &jsonOutput{w: &buf}
+	jsonout.BasicHeader()
+	jsonout.BasicLine(&bs)
+	jsonout.BasicFooter()
+	if !strings.Contains(buf.String(), `"Verification":"hash mismatch"`) {
+		t.Errorf("expected verification state in JSON output, got %s", buf.String())
+	}
+
+	buf.Reset()
+	bs.Verification = ""
+	jsonout.BasicHeader()
+	jsonout.BasicLine(&bs)
+	jsonout.BasicFooter()
+	if strings.Contains(buf.String(), "Verification") {
+		t.Errorf("expected no verification state in JSON output without -verify, got %s", buf.String())
+	}
+}
+
+func TestSizeStatus(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempFile("tree/a.txt", "package a")
+	h.TempFile("tree/sub/LICENSE", "license text")
+
+	files, size, err := measureTree(h.Path("tree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != 2 || size != int64(len("package a")+len("license text")) {
+		t.Errorf("unexpected measurement of tree: %d files, %d bytes", files, size)
+	}
+	if files, size, err = measureTree(filepath.Join(h.Path("."), "missing")); err != nil || files != 0 || size != 0 {
+		t.Errorf("expected missing tree to measure as empty, got %d files, %d bytes, err %v", files, size, err)
+	}
+
+	sizes := []*SizeStatus{
+		{ProjectRoot: "github.com/a/small", Files: 30, Bytes: 100, UnprunedBytes: 5000},
+		{ProjectRoot: "github.com/c/big", Files: 10, Bytes: 2000, UnprunedBytes: 2000},
+		{ProjectRoot: "github.com/b/big", Files: 20, Bytes: 2000, UnprunedBytes: 3000},
+	}
+	for by, want := range map[string][]string{
+		"":         {"github.com/a/small", "github.com/b/big", "github.com/c/big"},
+		"size":     {"github.com/b/big", "github.com/c/big", "github.com/a/small"},
+		"files":    {"github.com/a/small", "github.com/b/big", "github.com/c/big"},
+		"unpruned": {"github.com/a/small", "github.com/b/big", "github.com/c/big"},
+	} {
+		sortSizeStatuses(sizes, by)
+		var got []string
+		for _, ss := range sizes {
+			got = append(got, ss.ProjectRoot)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("sorting by %q: expected %v, got %v", by, want, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	out := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)}
+	out.SizeHeader()
+	out.SizeLine(&SizeStatus{ProjectRoot: "github.com/a/small", Files: 3, Bytes: 1536, UnprunedFiles: 9, UnprunedBytes: 3 << 20})
+	out.SizeFooter(&SizeStatus{ProjectRoot: "TOTAL", Files: 3, Bytes: 1536, hasError: true})
+	want := "PROJECT             FILES  SIZE     UNPRUNED FILES  UNPRUNED SIZE\n" +
+		"github.com/a/small  3      1.5 KiB  9               3.0 MiB  \n" +
+		"TOTAL               3      1.5 KiB  unknown         unknown  \n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected table output:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
 func execStatusTemplate(w io.Writer, format string, data interface{}) error {
 	tpl, err := parseStatusTemplate(format)
 	if err != nil {
@@ -986,3 +1186,33 @@ func TestStatusDetailTemplates(t *testing.T) {
 		})
 	}
 }
+
+func TestDetailLineReason(t *testing.T) {
+	ds := DetailStatus{
+		BasicStatus: BasicStatus{
+			ProjectRoot: "github.com/foo/bar",
+		},
+		Packages: []string{"."},
+		Reason:   "parses the config files",
+	}
+
+	var buf bytes.Buffer
+	tableout := &tableOutput{w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0), reasons: true}
+	tableout.DetailHeader(nil)
+	tableout.DetailLine(&ds)
+	tableout.DetailFooter(nil)
+	for _, want := range []string{"PKGS USED  REASON", "[.]        parses the config files"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Did not find expected Table status: \n\t(GOT) %v \n\t(WNT) %v", buf.String(), want)
+		}
+	}
+
+	buf.Reset()
+	jsonout := &jsonOutput{w: &buf}
+	jsonout.DetailHeader(nil)
+	jsonout.DetailLine(&ds)
+	jsonout.DetailFooter(nil)
+	if want := `"Reason":"parses the config files"`; !strings.Contains(buf.String(), want) {
+		t.Errorf("Did not find expected JSON status: \n\t(GOT) %v \n\t(WNT) %v", buf.String(), want)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/pkg/errors"
+)
+
+// Only a subset of the outputters should be able to output test import
+// statuses.
+type testImportsOutputter interface {
+	TestImportsHeader() error
+	TestImportsLine(*TestImportStatus) error
+	TestImportsFooter() error
+}
+
+// TestImportStatus describes the packages of a locked project that are only
+// reachable through the test files of the root project.
+type TestImportStatus struct {
+	ProjectRoot string
+	// Packages holds the test-only packages, relative to the project root.
+	Packages []string
+	// Entire is true if none of the project's packages are reachable from
+	// the root project's non-test files, so that the project is only needed
+	// to run its tests.
+	Entire bool
+}
+
+func (out *tableOutput) TestImportsHeader() error {
+	_, err := fmt.Fprintf(out.w, "PROJECT\tTEST-ONLY PACKAGES\tTEST-ONLY PROJECT\n")
+	return err
+}
+
+func (out *tableOutput) TestImportsLine(ts *TestImportStatus) error {
+	entire := "no"
+	if ts.Entire {
+		entire = "yes"
+	}
+	_, err := fmt.Fprintf(out.w,
+		"%s\t%s\t%s\t\n",
+		ts.ProjectRoot,
+		strings.Join(ts.Packages, ", "),
+		entire,
+	)
+	return err
+}
+
+func (out *tableOutput) TestImportsFooter() error {
+	return out.w.Flush()
+}
+
+func (out *jsonOutput) TestImportsHeader() error {
+	out.testImports = []*TestImportStatus{}
+	return nil
+}
+
+func (out *jsonOutput) TestImportsLine(ts *TestImportStatus) error {
+	out.testImports = append(out.testImports, ts)
+	return nil
+}
+
+func (out *jsonOutput) TestImportsFooter() error {
+	return json.NewEncoder(out.w).Encode(out.testImports)
+}
+
+func (cmd *statusCommand) runTestImports(ctx *dep.Ctx, out testImportsOutputter, p *dep.Project, sm gps.SourceManager) error {
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	ig := p.Manifest.IgnoredPackages()
+	rm, _ := p.RootPackageTree.ToReachMap(true, false, false, ig)
+	trm, _ := p.RootPackageTree.ToReachMap(true, true, false, ig)
+
+	// Required packages are needed to build, just as if they were imported
+	// by the root project's non-test files.
+	var required []string
+	for ip := range p.Manifest.RequiredPackages() {
+		required = append(required, ip)
+	}
+	nonTest := append(rm.FlattenFn(paths.IsStandardImportPath), required...)
+	withTests := append(trm.FlattenFn(paths.IsStandardImportPath), required...)
+
+	lps := p.Lock.Projects()
+	logger.Println("Collecting the imports of locked packages:")
+	pkgImports := make([]map[string][]string, len(lps))
+	errs := make([]error, len(lps))
+	var wg sync.WaitGroup
+	for i, lp := range lps {
+		wg.Add(1)
+		logger.Printf("(%d/%d) %s\n", i+1, len(lps), lp.Ident().ProjectRoot)
+		go func(i int, lp gps.LockedProject) {
+			defer wg.Done()
+			pkgImports[i], errs[i] = collectPackageImports(lp, sm)
+		}(i, lp)
+	}
+	wg.Wait()
+
+	imports := make(map[string][]string)
+	roots := make([]gps.ProjectRoot, len(lps))
+	for i, lp := range lps {
+		roots[i] = lp.Ident().ProjectRoot
+		if errs[i] != nil {
+			return errors.Wrapf(errs[i], "failed to list the packages of %s", lp.Ident().ProjectRoot)
+		}
+		for ip, imps := range pkgImports[i] {
+			imports[ip] = imps
+		}
+	}
+
+	if err := out.TestImportsHeader(); err != nil {
+		return err
+	}
+	for _, ts := range analyzeTestImports(nonTest, withTests, imports, roots) {
+		if err := out.TestImportsLine(ts); err != nil {
+			return err
+		}
+	}
+	return out.TestImportsFooter()
+}
+
+// collectPackageImports returns the non-test imports of each of the packages
+// of lp that are in use, keyed by import path.
+func collectPackageImports(lp gps.LockedProject, sm gps.SourceManager) (map[string][]string, error) {
+	ptree, err := sm.ListPackages(lp.Ident(), lp.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make(map[string][]string)
+	for _, pkg := range lp.Packages() {
+		ip := string(lp.Ident().ProjectRoot)
+		if pkg != "." {
+			ip += "/" + pkg
+		}
+		if poe, has := ptree.Packages[ip]; has && poe.Err == nil {
+			imports[ip] = poe.P.Imports
+		}
+	}
+	return imports, nil
+}
+
+// analyzeTestImports finds the packages that are reachable from withTests, the
+// external imports of the root project including those of its test files, but
+// not from nonTest, those of its non-test files alone. The imports of other
+// packages are followed through imports, which holds only non-test imports,
+// as the tests of dependencies are never built. The packages are grouped by
+// the project in roots that contains them, ordered by project root.
+func analyzeTestImports(nonTest, withTests []string, imports map[string][]string, roots []gps.ProjectRoot) []*TestImportStatus {
+	reachable := func(from []string) map[string]bool {
+		seen := make(map[string]bool)
+		queue := append([]string(nil), from...)
+		for len(queue) > 0 {
+			ip := queue[0]
+			queue = queue[1:]
+			if seen[ip] || paths.IsStandardImportPath(ip) {
+				continue
+			}
+			seen[ip] = true
+			queue = append(queue, imports[ip]...)
+		}
+		return seen
+	}
+	build, test := reachable(nonTest), reachable(withTests)
+
+	// Order the roots from the longest, so that the first root found to
+	// contain a package is the one that it belongs to.
+	sorted := append([]gps.ProjectRoot(nil), roots...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	rootOf := func(ip string) (gps.ProjectRoot, bool) {
+		for _, pr := range sorted {
+			if ip == string(pr) || strings.HasPrefix(ip, string(pr)+"/") {
+				return pr, true
+			}
+		}
+		return "", false
+	}
+
+	inBuild := make(map[gps.ProjectRoot]bool)
+	for ip := range build {
+		if pr, ok := rootOf(ip); ok {
+			inBuild[pr] = true
+		}
+	}
+
+	byRoot := make(map[gps.ProjectRoot]*TestImportStatus)
+	for ip := range test {
+		if build[ip] {
+			continue
+		}
+		pr, ok := rootOf(ip)
+		if !ok {
+			continue
+		}
+		ts, has := byRoot[pr]
+		if !has {
+			ts = &TestImportStatus{ProjectRoot: string(pr), Entire: !inBuild[pr]}
+			byRoot[pr] = ts
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(ip, string(pr)), "/")
+		if rel == "" {
+			rel = "."
+		}
+		ts.Packages = append(ts.Packages, rel)
+	}
+
+	statuses := make([]*TestImportStatus, 0, len(byRoot))
+	for _, ts := range byRoot {
+		sort.Strings(ts.Packages)
+		statuses = append(statuses, ts)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ProjectRoot < statuses[j].ProjectRoot })
+	return statuses
+}
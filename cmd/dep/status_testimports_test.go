@@ -0,0 +1,39 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestAnalyzeTestImports(t *testing.T) {
+	// The root project's code imports a; its tests also import b and
+	// c/assert. a imports a/sub, and c/assert imports d and fmt. c itself is
+	// imported by a/sub, but not c/assert.
+	nonTest := []string{"github.com/a"}
+	withTests := []string{"github.com/a", "github.com/b", "github.com/c/assert"}
+	imports := map[string][]string{
+		"github.com/a":        {"github.com/a/sub"},
+		"github.com/a/sub":    {"github.com/c"},
+		"github.com/b":        nil,
+		"github.com/c":        nil,
+		"github.com/c/assert": {"github.com/d", "fmt"},
+		"github.com/d":        nil,
+	}
+	roots := []gps.ProjectRoot{"github.com/a", "github.com/b", "github.com/c", "github.com/d"}
+
+	got := analyzeTestImports(nonTest, withTests, imports, roots)
+	want := []*TestImportStatus{
+		{ProjectRoot: "github.com/b", Packages: []string{"."}, Entire: true},
+		{ProjectRoot: "github.com/c", Packages: []string{"assert"}, Entire: false},
+		{ProjectRoot: "github.com/d", Packages: []string{"."}, Entire: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected test-only imports:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+// typeCheckMaxErrors is the number of type errors reported before the rest
+// are summarized.
+const typeCheckMaxErrors = 20
+
+// typeCheckErrors holds the errors found while type-checking a project.
+type typeCheckErrors []error
+
+func (errs typeCheckErrors) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "the project does not type-check against vendor/:")
+	for i, err := range errs {
+		if i == typeCheckMaxErrors {
+			fmt.Fprintf(&buf, "\n  ... and %d more", len(errs)-i)
+			break
+		}
+		fmt.Fprintf(&buf, "\n  %s", err)
+	}
+	return buf.String()
+}
+
+// sourceImporter is a types.ImporterFrom that type-checks imported packages
+// from their source. Import paths are resolved to the packages of the project
+// itself, then to those in its vendor directory, then to the standard library,
+// so that the project is checked against its vendor directory wherever the
+// project is and wherever the directory is, GOPATH and go.mod notwithstanding.
+type sourceImporter struct {
+	ctxt      build.Context
+	root      string
+	absRoot   string
+	vendorDir string
+	fset      *token.FileSet
+	pkgs      map[string]*types.Package
+	failed    map[string]error
+}
+
+func newSourceImporter(p *dep.Project) *sourceImporter {
+	ctxt := build.Default
+	// Only the standard library is found by go/build itself.
+	ctxt.GOPATH = ""
+	// Without cgo, the pure Go implementations of standard library packages
+	// are chosen, which can be type-checked without running cgo.
+	ctxt.CgoEnabled = false
+	// With a file system hook set, go/build never asks the go command to
+	// resolve imports, as it would in module mode.
+	ctxt.IsDir = func(path string) bool {
+		fi, err := os.Stat(path)
+		return err == nil && fi.IsDir()
+	}
+
+	return &sourceImporter{
+		ctxt:      ctxt,
+		root:      string(p.ImportRoot),
+		absRoot:   p.AbsRoot,
+		vendorDir: p.VendorDir(),
+		fset:      token.NewFileSet(),
+		pkgs:      make(map[string]*types.Package),
+		failed:    make(map[string]error),
+	}
+}
+
+// resolve returns the package that path refers to when imported from the
+// package in dir.
+func (si *sourceImporter) resolve(path, dir string) (*build.Package, error) {
+	// The standard library resolves its imports within GOROOT, through its
+	// own vendor directory.
+	if si.ctxt.GOROOT != "" && strings.HasPrefix(dir, filepath.Join(si.ctxt.GOROOT, "src")+string(filepath.Separator)) {
+		return si.ctxt.Import(path, dir, 0)
+	}
+
+	if path == si.root || strings.HasPrefix(path, si.root+"/") {
+		rel := strings.TrimPrefix(strings.TrimPrefix(path, si.root), "/")
+		return si.importDir(filepath.Join(si.absRoot, filepath.FromSlash(rel)), path)
+	}
+	if vdir := filepath.Join(si.vendorDir, filepath.FromSlash(path)); si.ctxt.IsDir(vdir) {
+		return si.importDir(vdir, path)
+	}
+	bp, err := si.ctxt.Import(path, dir, 0)
+	if err != nil {
+		return nil, errors.Errorf("cannot find package %q in %s or the standard library", path, si.vendorDir)
+	}
+	return bp, nil
+}
+
+// importDir returns the package in dir, with the given import path.
+func (si *sourceImporter) importDir(dir, path string) (*build.Package, error) {
+	bp, err := si.ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	bp.ImportPath = path
+	return bp, nil
+}
+
+func (si *sourceImporter) Import(path string) (*types.Package, error) {
+	return si.ImportFrom(path, "", 0)
+}
+
+func (si *sourceImporter) ImportFrom(path, dir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+
+	bp, err := si.resolve(path, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err, has := si.failed[bp.ImportPath]; has {
+		return nil, err
+	}
+	if pkg, has := si.pkgs[bp.ImportPath]; has {
+		if !pkg.Complete() {
+			return nil, errors.Errorf("import cycle through %s", bp.ImportPath)
+		}
+		return pkg, nil
+	}
+
+	pkg, errs := si.check(bp)
+	if len(errs) > 0 {
+		err = errors.Wrapf(errs[0], "type-checking %s", bp.ImportPath)
+		si.failed[bp.ImportPath] = err
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// check parses and type-checks the package described by bp, returning all of
+// the errors found.
+func (si *sourceImporter) check(bp *build.Package) (*types.Package, []error) {
+	var errs []error
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(si.fset, filepath.Join(bp.Dir, name), nil, 0)
+		if err != nil {
+			return nil, []error{err}
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{
+		Importer: si,
+		Error: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	// Record the package before checking it, so that an import cycle is
+	// reported instead of recursing forever.
+	pkg := types.NewPackage(bp.ImportPath, bp.Name)
+	si.pkgs[bp.ImportPath] = pkg
+	types.NewChecker(&conf, si.fset, pkg, nil).Files(files)
+	if len(errs) == 0 {
+		pkg.MarkComplete()
+	}
+	return pkg, errs
+}
+
+// typeCheckProject type-checks each package in p's root package tree against
+// the packages in its vendor directory, returning the errors found. Ignored
+// packages, and those that cannot be read, are skipped.
+func typeCheckProject(p *dep.Project) error {
+	ig := p.Manifest.IgnoredPackages()
+
+	ips := make([]string, 0, len(p.RootPackageTree.Packages))
+	for ip, poe := range p.RootPackageTree.Packages {
+		if poe.Err != nil || ig.IsIgnored(ip) {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	si := newSourceImporter(p)
+	var errs typeCheckErrors
+	for _, ip := range ips {
+		rel := strings.TrimPrefix(strings.TrimPrefix(ip, string(p.ImportRoot)), "/")
+		bp, err := si.importDir(filepath.Join(p.AbsRoot, filepath.FromSlash(rel)), ip)
+		if err != nil {
+			if _, ok := err.(*build.NoGoError); ok {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if _, has := si.pkgs[bp.ImportPath]; has {
+			// Already checked as a dependency of another root package.
+			continue
+		}
+		_, perrs := si.check(bp)
+		errs = append(errs, perrs...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
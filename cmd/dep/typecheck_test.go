@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestTypeCheckProject(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("src/example.com/root/vendor/example.com/dep")
+	h.TempFile("src/example.com/root/root.go", "package root\n\nimport \"example.com/dep\"\n\nvar X int = dep.Answer\n")
+
+	p := &dep.Project{
+		AbsRoot:    h.Path("src/example.com/root"),
+		ImportRoot: "example.com/root",
+		Manifest:   dep.NewManifest(),
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "example.com/root",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"example.com/root": {P: pkgtree.Package{ImportPath: "example.com/root", Name: "root"}},
+			},
+		},
+	}
+
+	h.TempFile("src/example.com/root/vendor/example.com/dep/dep.go", "package dep\n\nconst Answer = 42\n")
+	if err := typeCheckProject(p); err != nil {
+		t.Fatalf("expected project to type-check, got %s", err)
+	}
+
+	// A new version of the dependency that changes the type of Answer no
+	// longer builds with the root project.
+	h.TempFile("src/example.com/root/vendor/example.com/dep/dep.go", "package dep\n\nconst Answer = \"42\"\n")
+	err := typeCheckProject(p)
+	if err == nil {
+		t.Fatal("expected type-checking to fail")
+	}
+	if !strings.Contains(err.Error(), "root.go:5") {
+		t.Errorf("expected the error to point at root.go, got %s", err)
+	}
+}
+
+func TestTypeCheckProjectVendorDir(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	// The project is outside of any GOPATH, has a go.mod, vendors into a
+	// directory of its own choosing, and imports the standard library and a
+	// package of its own.
+	h.TempFile("proj/go.mod", "module example.com/root\n")
+	h.TempFile("proj/root.go", "package root\n\nimport (\n\t\"strings\"\n\n\t\"example.com/dep\"\n\t\"example.com/root/sub\"\n)\n\nvar X = strings.Repeat(dep.Answer, sub.N)\n")
+	h.TempFile("proj/sub/sub.go", "package sub\n\nconst N = 2\n")
+	h.TempFile("proj/third_party/example.com/dep/dep.go", "package dep\n\nconst Answer = \"42\"\n")
+
+	m := dep.NewManifest()
+	m.VendorDir = "third_party"
+	p := &dep.Project{
+		AbsRoot:    h.Path("proj"),
+		ImportRoot: "example.com/root",
+		Manifest:   m,
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "example.com/root",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"example.com/root":     {P: pkgtree.Package{ImportPath: "example.com/root", Name: "root"}},
+				"example.com/root/sub": {P: pkgtree.Package{ImportPath: "example.com/root/sub", Name: "sub"}},
+			},
+		},
+	}
+	if err := typeCheckProject(p); err != nil {
+		t.Fatalf("expected project to type-check against third_party/, got %s", err)
+	}
+
+	h.TempFile("proj/third_party/example.com/dep/dep.go", "package dep\n\nconst Answer = 42\n")
+	if err := typeCheckProject(p); err == nil {
+		t.Fatal("expected type-checking to fail")
+	}
+}
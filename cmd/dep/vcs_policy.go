@@ -0,0 +1,136 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// defaultVCSRetryBackoff is the wait before the first retry of a failed VCS
+// operation when $DEPVCSRETRIES is set but $DEPVCSBACKOFF is not.
+const defaultVCSRetryBackoff = time.Second
+
+// vcsPolicyFromEnv builds the timeouts and retry policy for VCS operations
+// from $DEPVCSTIMEOUT, $DEPVCSRETRIES and $DEPVCSBACKOFF.
+//
+// $DEPVCSTIMEOUT is either a single duration, applied to every operation, or
+// a comma-separated list of op=duration pairs, where op is one of ls-remote,
+// clone, fetch or export.
+func vcsPolicyFromEnv(env []string) (gps.VCSPolicy, error) {
+	var p gps.VCSPolicy
+
+	if v := getEnv(env, "DEPVCSTIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			p.ListTimeout, p.CloneTimeout, p.FetchTimeout, p.ExportTimeout = d, d, d, d
+		} else {
+			for _, pair := range strings.Split(v, ",") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 {
+					return p, errors.Errorf("failed to parse $DEPVCSTIMEOUT: %q is not of the form op=duration", pair)
+				}
+				d, err := time.ParseDuration(kv[1])
+				if err != nil {
+					return p, errors.Wrapf(err, "failed to parse $DEPVCSTIMEOUT duration for %s", kv[0])
+				}
+				switch kv[0] {
+				case "ls-remote":
+					p.ListTimeout = d
+				case "clone":
+					p.CloneTimeout = d
+				case "fetch":
+					p.FetchTimeout = d
+				case "export":
+					p.ExportTimeout = d
+				default:
+					return p, errors.Errorf("failed to parse $DEPVCSTIMEOUT: unknown operation %q, expected ls-remote, clone, fetch or export", kv[0])
+				}
+			}
+		}
+	}
+
+	if v := getEnv(env, "DEPVCSRETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, errors.Errorf("failed to parse $DEPVCSRETRIES: %q is not a non-negative integer", v)
+		}
+		p.Retries = n
+		p.RetryBackoff = defaultVCSRetryBackoff
+	}
+
+	if v := getEnv(env, "DEPVCSBACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return p, errors.Wrap(err, "failed to parse $DEPVCSBACKOFF duration")
+		}
+		p.RetryBackoff = d
+	}
+
+	return p, nil
+}
+
+// networkPolicyFromEnv builds the hosts dep may contact from the
+// comma-separated host patterns in $DEPALLOWHOSTS and $DEPDENYHOSTS.
+func networkPolicyFromEnv(env []string) gps.NetworkPolicy {
+	split := func(v string) []string {
+		var hosts []string
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				hosts = append(hosts, h)
+			}
+		}
+		return hosts
+	}
+	return gps.NetworkPolicy{
+		Allow: split(getEnv(env, "DEPALLOWHOSTS")),
+		Deny:  split(getEnv(env, "DEPDENYHOSTS")),
+	}
+}
+
+// protocolsFromEnv builds the protocols over which to reach sources from
+// $DEPPROTOCOLS, a comma-separated list of key=scheme pairs, where key is a
+// host pattern or a project root.
+func protocolsFromEnv(env []string) (gps.ProtocolPreferences, error) {
+	v := getEnv(env, "DEPPROTOCOLS")
+	if v == "" {
+		return nil, nil
+	}
+
+	p := make(gps.ProtocolPreferences)
+	for _, pair := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("failed to parse $DEPPROTOCOLS: %q is not of the form host=protocol or project=protocol", pair)
+		}
+		p[kv[0]] = kv[1]
+	}
+	if err := p.Validate(); err != nil {
+		return nil, errors.Wrap(err, "failed to parse $DEPPROTOCOLS")
+	}
+	return p, nil
+}
+
+// buildContextFromEnv builds the build context that scoped ignores are applied
+// for from $GOOS and $GOARCH, as the go tool does, and from $DEPBUILDTAGS, a
+// list of build tags separated by commas or spaces.
+func buildContextFromEnv(env []string) gps.BuildContext {
+	bc := gps.BuildContext{
+		GOOS:   getEnv(env, "GOOS"),
+		GOARCH: getEnv(env, "GOARCH"),
+		Tags:   strings.FieldsFunc(getEnv(env, "DEPBUILDTAGS"), func(r rune) bool { return r == ',' || r == ' ' }),
+	}
+	if bc.GOOS == "" {
+		bc.GOOS = runtime.GOOS
+	}
+	if bc.GOARCH == "" {
+		bc.GOARCH = runtime.GOARCH
+	}
+	return bc
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestVCSPolicyFromEnv(t *testing.T) {
+	cases := []struct {
+		env     []string
+		want    gps.VCSPolicy
+		wantErr bool
+	}{
+		{
+			env: nil,
+		},
+		{
+			env: []string{"DEPVCSTIMEOUT=2m"},
+			want: gps.VCSPolicy{
+				ListTimeout:   2 * time.Minute,
+				CloneTimeout:  2 * time.Minute,
+				FetchTimeout:  2 * time.Minute,
+				ExportTimeout: 2 * time.Minute,
+			},
+		},
+		{
+			env: []string{"DEPVCSTIMEOUT=ls-remote=30s, clone=10m", "DEPVCSRETRIES=3"},
+			want: gps.VCSPolicy{
+				ListTimeout:  30 * time.Second,
+				CloneTimeout: 10 * time.Minute,
+				Retries:      3,
+				RetryBackoff: defaultVCSRetryBackoff,
+			},
+		},
+		{
+			env: []string{"DEPVCSRETRIES=2", "DEPVCSBACKOFF=5s"},
+			want: gps.VCSPolicy{
+				Retries:      2,
+				RetryBackoff: 5 * time.Second,
+			},
+		},
+		{
+			env:     []string{"DEPVCSTIMEOUT=checkout=1m"},
+			wantErr: true,
+		},
+		{
+			env:     []string{"DEPVCSTIMEOUT=fetch"},
+			wantErr: true,
+		},
+		{
+			env:     []string{"DEPVCSRETRIES=-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		got, err := vcsPolicyFromEnv(c.env)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%v: unexpected error state, err: %v", c.env, err)
+			continue
+		}
+		if !c.wantErr && got != c.want {
+			t.Errorf("%v: expected policy %+v, got %+v", c.env, c.want, got)
+		}
+	}
+}
+
+func TestNetworkPolicyFromEnv(t *testing.T) {
+	p := networkPolicyFromEnv([]string{"DEPALLOWHOSTS=github.com, *.corp.example.com,", "DEPDENYHOSTS=gist.github.com"})
+	want := gps.NetworkPolicy{
+		Allow: []string{"github.com", "*.corp.example.com"},
+		Deny:  []string{"gist.github.com"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("expected %+v, got %+v", want, p)
+	}
+
+	if p := networkPolicyFromEnv(nil); !reflect.DeepEqual(p, gps.NetworkPolicy{}) {
+		t.Errorf("expected the zero policy without the variables, got %+v", p)
+	}
+}
+
+func TestProtocolsFromEnv(t *testing.T) {
+	p, err := protocolsFromEnv([]string{"DEPPROTOCOLS=github.com=ssh, github.com/corp/public=https"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (gps.ProtocolPreferences{"github.com": "ssh", "github.com/corp/public": "https"}); !reflect.DeepEqual(p, want) {
+		t.Errorf("expected %v, got %v", want, p)
+	}
+
+	if p, err := protocolsFromEnv(nil); err != nil || p != nil {
+		t.Errorf("expected no preferences without the variable, got %v, %v", p, err)
+	}
+	for _, v := range []string{"ssh", "github.com=api", "=ssh"} {
+		if _, err := protocolsFromEnv([]string{"DEPPROTOCOLS=" + v}); err == nil {
+			t.Errorf("expected %q to fail to parse", v)
+		}
+	}
+}
+
+func TestBuildContextFromEnv(t *testing.T) {
+	bc := buildContextFromEnv([]string{"GOOS=windows", "GOARCH=386", "DEPBUILDTAGS=cgo, netgo integration"})
+	want := gps.BuildContext{GOOS: "windows", GOARCH: "386", Tags: []string{"cgo", "netgo", "integration"}}
+	if !reflect.DeepEqual(bc, want) {
+		t.Errorf("expected %v, got %v", want, bc)
+	}
+
+	bc = buildContextFromEnv(nil)
+	if bc.GOOS != runtime.GOOS || bc.GOARCH != runtime.GOARCH || len(bc.Tags) != 0 {
+		t.Errorf("expected the build context to default to that of the running binary, got %v", bc)
+	}
+}
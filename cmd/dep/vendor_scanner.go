@@ -0,0 +1,265 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	fb "github.com/golang/dep/internal/feedback"
+	"github.com/pkg/errors"
+)
+
+// maxVendorCandidates caps the number of versions of each vendored project
+// that are exported and compared against its vendored copy.
+const maxVendorCandidates = 30
+
+// vendorScanner supplies lock data by matching the projects already present
+// in vendor/ against the versions available from their sources. It uses its
+// results to fill in any missing details left by the rootAnalyzer.
+type vendorScanner struct {
+	ctx        *dep.Ctx
+	directDeps map[gps.ProjectRoot]bool
+	sm         gps.SourceManager
+	vendorDir  string
+}
+
+func newVendorScanner(ctx *dep.Ctx, directDeps map[gps.ProjectRoot]bool, sm gps.SourceManager, vendorDir string) *vendorScanner {
+	return &vendorScanner{
+		ctx:        ctx,
+		directDeps: directDeps,
+		sm:         sm,
+		vendorDir:  vendorDir,
+	}
+}
+
+// InitializeRootManifestAndLock fingerprints each project in vendor/ and
+// locks it to the newest version whose files match those vendored. Vendored
+// copies are often pruned, so a version matches if every vendored file is
+// identical to the same file in that version; files in the version that are
+// absent from vendor/ are ignored. Direct dependencies are also constrained
+// to the matched version. Projects already in the root manifest or lock are
+// left alone.
+func (v *vendorScanner) InitializeRootManifestAndLock(rootM *dep.Manifest, rootL *dep.Lock) error {
+	projects, err := v.scanVendor()
+	if err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		return nil
+	}
+
+	locked := make(map[gps.ProjectRoot]bool, len(rootL.P))
+	for _, lp := range rootL.P {
+		locked[lp.Ident().ProjectRoot] = true
+	}
+
+	roots := make([]string, 0, len(projects))
+	for pr := range projects {
+		roots = append(roots, string(pr))
+	}
+	sort.Strings(roots)
+
+	var unmatched []string
+	for _, root := range roots {
+		pr := gps.ProjectRoot(root)
+		if locked[pr] {
+			continue
+		}
+
+		pi := gps.ProjectIdentifier{ProjectRoot: pr}
+		pv, err := v.matchVersion(pi)
+		if err != nil {
+			return err
+		}
+		if pv == nil {
+			unmatched = append(unmatched, root)
+			continue
+		}
+
+		lp := gps.NewLockedProject(pi, pv, projects[pr])
+		rootL.P = append(rootL.P, lp)
+
+		if !v.directDeps[pr] {
+			fb.NewLockedProjectFeedback(lp, fb.DepTypeTransitive).LogFeedback(v.ctx.Err)
+			continue
+		}
+		if _, has := rootM.Constraints[pr]; !has {
+			pp := getProjectPropertiesFromVersion(pv)
+			if pp.Constraint != nil {
+				rootM.Constraints[pr] = pp
+				fb.NewConstraintFeedback(gps.ProjectConstraint{Ident: pi, Constraint: pp.Constraint}, fb.DepTypeDirect).LogFeedback(v.ctx.Err)
+			}
+		}
+		fb.NewLockedProjectFeedback(lp, fb.DepTypeDirect).LogFeedback(v.ctx.Err)
+	}
+
+	if len(unmatched) > 0 {
+		v.ctx.Err.Printf("\nThe following vendored projects did not match any known version:\n  %s\n\nThe most recent version of these projects will be used.\n\n",
+			strings.Join(unmatched, "\n  "))
+	}
+	return nil
+}
+
+// scanVendor finds the packages in vendor/, returning them grouped by project
+// root, relative to that root as in a lock.
+func (v *vendorScanner) scanVendor() (map[gps.ProjectRoot][]string, error) {
+	var ips []string
+	err := filepath.Walk(v.vendorDir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == v.vendorDir {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		name := info.Name()
+		if path != v.vendorDir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		if hasGoFiles(path) {
+			rel, err := filepath.Rel(v.vendorDir, path)
+			if err != nil {
+				return err
+			}
+			if rel != "." {
+				ips = append(ips, filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan vendor directory")
+	}
+
+	projects := make(map[gps.ProjectRoot][]string)
+	for _, ip := range ips {
+		pr, err := v.sm.DeduceProjectRoot(ip)
+		if err != nil {
+			v.ctx.Err.Printf("Unable to determine the project of vendored package %s, ignoring it: %s\n", ip, err)
+			continue
+		}
+		pkg := "."
+		if ip != string(pr) {
+			pkg = strings.TrimPrefix(ip, string(pr)+"/")
+		}
+		projects[pr] = append(projects[pr], pkg)
+	}
+	return projects, nil
+}
+
+// matchVersion returns the newest version of pi whose files match its
+// vendored copy, or nil if none of the candidate versions match.
+func (v *vendorScanner) matchVersion(pi gps.ProjectIdentifier) (gps.PairedVersion, error) {
+	fp, err := fingerprintDir(filepath.Join(v.vendorDir, filepath.FromSlash(string(pi.ProjectRoot))))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fingerprint vendored copy of %s", pi)
+	}
+
+	pvl, err := v.sm.ListVersions(pi)
+	if err != nil {
+		v.ctx.Err.Printf("Unable to list versions of %s: %s\n", pi, err)
+		return nil, nil
+	}
+	gps.SortPairedForUpgrade(pvl)
+	if len(pvl) > maxVendorCandidates {
+		pvl = pvl[:maxVendorCandidates]
+	}
+
+	td, err := ioutil.TempDir("", "dep-vendor")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	for i, pv := range pvl {
+		to := filepath.Join(td, strconv.Itoa(i))
+		if err := v.sm.ExportProject(context.TODO(), pi, pv, to); err != nil {
+			v.ctx.Err.Printf("Unable to export %s at %s: %s\n", pi, pv, err)
+			continue
+		}
+		match, err := fp.matches(to)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			return pv, nil
+		}
+	}
+	return nil, nil
+}
+
+// dirFingerprint holds the SHA-256 of each regular file in a tree, keyed by
+// slash-separated path relative to its root.
+type dirFingerprint map[string][]byte
+
+func fingerprintDir(root string) (dirFingerprint, error) {
+	fp := make(dirFingerprint)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		fp[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	return fp, err
+}
+
+// matches reports whether every file in the fingerprint is present, with the
+// same contents, in the tree at root.
+func (fp dirFingerprint) matches(root string) (bool, error) {
+	for rel, want := range fp {
+		got, err := hashFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(got, want) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// hasGoFiles reports whether dir directly contains any .go files.
+func hasGoFiles(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	return len(matches) > 0
+}
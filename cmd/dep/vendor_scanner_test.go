@@ -0,0 +1,75 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestVendorScanner_AdoptsMatchingVersions(t *testing.T) {
+	h := test.NewHelper(t)
+	h.Parallel()
+	defer h.Cleanup()
+
+	ctx := NewTestContext(h)
+
+	sm := gps.NewMemorySourceManager()
+	for _, v := range []gps.PairedVersion{
+		gps.NewVersion("v1.0.0").Pair("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		gps.NewVersion("v1.1.0").Pair("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+	} {
+		sm.AddVersion(testProject1, v, gps.MemoryProject{
+			Files: map[string][]byte{
+				"dep.go":      []byte("package deptest // " + v.String() + "\n"),
+				"dep_test.go": []byte("package deptest\n"),
+			},
+		})
+	}
+	sm.AddVersion(testProject2, gps.NewVersion("v2.0.0").Pair("cccccccccccccccccccccccccccccccccccccccc"), gps.MemoryProject{
+		Files: map[string][]byte{"dos.go": []byte("package deptestdos\n")},
+	})
+
+	// The vendored copy of the first project is the older version, with its
+	// tests pruned; that of the second has been modified locally.
+	h.TempFile("vendor/"+testProject1+"/dep.go", "package deptest // v1.0.0\n")
+	h.TempFile("vendor/"+testProject2+"/dos.go", "package deptestdos // patched\n")
+
+	directDeps := map[gps.ProjectRoot]bool{
+		testProject1: true,
+		testProject2: true,
+	}
+	rootM := dep.NewManifest()
+	rootL := &dep.Lock{}
+	vs := newVendorScanner(ctx, directDeps, sm, h.Path("vendor"))
+	if err := vs.InitializeRootManifestAndLock(rootM, rootL); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rootL.P) != 1 {
+		t.Fatalf("expected only the unmodified project to be locked, got %v", rootL.P)
+	}
+	lp := rootL.P[0]
+	if lp.Ident().ProjectRoot != testProject1 || lp.Version().String() != "v1.0.0" {
+		t.Errorf("expected %s to be locked at v1.0.0, got %s at %s", testProject1, lp.Ident(), lp.Version())
+	}
+	if pkgs := lp.Packages(); len(pkgs) != 1 || pkgs[0] != "." {
+		t.Errorf("expected the root package to be locked, got %v", pkgs)
+	}
+
+	pp, has := rootM.Constraints[testProject1]
+	if !has {
+		t.Fatalf("expected the root manifest to constrain %s", testProject1)
+	}
+	if pp.Constraint.String() != "^1.0.0" {
+		t.Errorf("expected %s to be constrained to ^1.0.0, got %s", testProject1, pp.Constraint)
+	}
+	if _, has = rootM.Constraints[testProject2]; has {
+		t.Errorf("expected no constraint on the unmatched %s", testProject2)
+	}
+}
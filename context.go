@@ -5,11 +5,14 @@
 package dep
 
 import (
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/dep/gps"
@@ -39,15 +42,32 @@ import (
 //	}
 //
 type Ctx struct {
-	WorkingDir     string        // Where to execute.
-	GOPATH         string        // Selected Go path, containing WorkingDir.
-	GOPATHs        []string      // Other Go paths.
-	ExplicitRoot   string        // An explicitly-set path to use as the project root.
-	Out, Err       *log.Logger   // Required loggers.
-	Verbose        bool          // Enables more verbose logging.
-	DisableLocking bool          // When set, no lock file will be created to protect against simultaneous dep processes.
-	Cachedir       string        // Cache directory loaded from environment.
-	CacheAge       time.Duration // Maximum valid age of cached source data. <=0: Don't cache.
+	WorkingDir       string                  // Where to execute.
+	GOPATH           string                  // Selected Go path, containing WorkingDir.
+	GOPATHs          []string                // Other Go paths.
+	ExplicitRoot     string                  // An explicitly-set path to use as the project root.
+	Out, Err         *log.Logger             // Required loggers.
+	Verbose          bool                    // Enables more verbose logging.
+	DisableLocking   bool                    // When set, no lock file will be created to protect against simultaneous dep processes.
+	LeaseLocking     bool                    // When set, the cache is always protected by a renewed lease file, as it is on network filesystems.
+	ResumableFetches bool                    // When set, git sources are cloned in resumable steps.
+	Cachedir         string                  // Cache directory loaded from environment.
+	CacheAge         time.Duration           // Maximum valid age of cached source data. <=0: Don't cache.
+	CompressCache    bool                    // When set, the sources and metadata in the cache are kept compressed while not in use.
+	VCSPolicy        gps.VCSPolicy           // Timeouts and retries for operations on sources.
+	BundleDir        string                  // When set, sources are served solely from the bundle in this directory.
+	Advisories       string                  // Path or URL of the advisories consulted by ensure -update -security.
+	Keyring          string                  // Path of the keys trusted to sign locked revisions, for check -require-signed.
+	RefreshCache     bool                    // When set, cached source data is fetched again, and the cache updated.
+	MetadataOnly     bool                    // When set, solving reads what it can from the hosts of sources rather than cloning them.
+	NetworkPolicy    gps.NetworkPolicy       // The hosts that may be contacted.
+	Protocols        gps.ProtocolPreferences // The protocols over which to reach sources, by host or project.
+	Proxies          gps.ProxyConfig         // The proxies through which hosts are reached.
+	Auth             gps.AuthProviders       // The providers of the credentials for sources, by host.
+	Namespaces       gps.Namespaces          // The sources of projects under private import path prefixes.
+	SourceTemplates  gps.SourceTemplates     // The variables and rules from which templated sources are resolved.
+	LicensePolicy    string                  // How ensure -update treats changes to the licenses of dependencies.
+	BuildContext     gps.BuildContext        // The build context that scoped ignores in the manifest are applied for.
 }
 
 // SetPaths sets the WorkingDir and GOPATHs fields. If GOPATHs is empty, then
@@ -97,10 +117,8 @@ func defaultGOPATH() string {
 // SourceManager produces an instance of gps's built-in SourceManager
 // initialized to log to the receiver's logger.
 func (c *Ctx) SourceManager() (*gps.SourceMgr, error) {
-	cachedir := c.Cachedir
-	if cachedir == "" {
-		// When `DEPCACHEDIR` isn't set in the env, use the default - `$GOPATH/pkg/dep`.
-		cachedir = filepath.Join(c.GOPATH, "pkg", "dep")
+	cachedir := c.CacheDir()
+	if c.Cachedir == "" {
 		// Create the default cachedir if it does not exist.
 		if err := os.MkdirAll(cachedir, 0777); err != nil {
 			return nil, errors.Wrap(err, "failed to create default cache directory")
@@ -108,21 +126,71 @@ func (c *Ctx) SourceManager() (*gps.SourceMgr, error) {
 	}
 
 	return gps.NewSourceManager(gps.SourceManagerConfig{
-		CacheAge:       c.CacheAge,
-		Cachedir:       cachedir,
-		Logger:         c.Out,
-		DisableLocking: c.DisableLocking,
+		CacheAge:         c.CacheAge,
+		Cachedir:         cachedir,
+		Logger:           c.Out,
+		DisableLocking:   c.DisableLocking,
+		LeaseLocking:     c.LeaseLocking,
+		ResumableFetches: c.ResumableFetches,
+		VCSPolicy:        c.VCSPolicy,
+		BundleDir:        c.BundleDir,
+		RefreshCache:     c.RefreshCache,
+		MetadataOnly:     c.MetadataOnly,
+		NetworkPolicy:    c.NetworkPolicy,
+		Protocols:        c.Protocols,
+		Proxies:          c.Proxies,
+		Auth:             c.Auth,
+		Namespaces:       c.Namespaces,
+		SourceTemplates:  c.SourceTemplates,
+		CompressCache:    c.CompressCache,
 	})
 }
 
+// ProjectSourceManager is like SourceManager, but also applies the hints in the
+// manifest of p about fetching its dependencies, such as its tags-only list.
+func (c *Ctx) ProjectSourceManager(p *Project) (*gps.SourceMgr, error) {
+	sm, err := c.SourceManager()
+	if err != nil {
+		return nil, err
+	}
+	if p.Manifest != nil {
+		for _, pr := range p.Manifest.TagsOnly {
+			sm.FetchTagsOnly(gps.ProjectRoot(pr))
+		}
+	}
+	return sm, nil
+}
+
+// CacheDir returns the cache directory in use: Cachedir, or the default of
+// $GOPATH/pkg/dep if it is not set.
+func (c *Ctx) CacheDir() string {
+	if c.Cachedir == "" {
+		// When `DEPCACHEDIR` isn't set in the env, use the default - `$GOPATH/pkg/dep`.
+		return filepath.Join(c.GOPATH, "pkg", "dep")
+	}
+	return c.Cachedir
+}
+
 // LoadProject starts from the current working directory and searches up the
 // directory tree for a project root.  The search stops when a file with the name
 // ManifestName (Gopkg.toml, by default) is located.
 //
 // The Project contains the parsed manifest as well as a parsed lock file, if
 // present.  The import path is calculated as the remaining path segment
-// below Ctx.GOPATH/src.
+// below Ctx.GOPATH/src, unless the project declares it explicitly; see
+// InferImportRoot.
 func (c *Ctx) LoadProject() (*Project, error) {
+	return c.loadProject(true)
+}
+
+// LoadProjectWithoutLock is like LoadProject, but leaves the Project's Lock
+// unset without reading the lock file, so that a project can be loaded while
+// its lock cannot be parsed, such as while it holds merge conflicts.
+func (c *Ctx) LoadProjectWithoutLock() (*Project, error) {
+	return c.loadProject(false)
+}
+
+func (c *Ctx) loadProject(withLock bool) (*Project, error) {
 	root, err := findProjectRoot(c.WorkingDir)
 	if err != nil {
 		return nil, err
@@ -139,21 +207,6 @@ func (c *Ctx) LoadProject() (*Project, error) {
 		return nil, err
 	}
 
-	c.GOPATH, err = c.DetectProjectGOPATH(p)
-	if err != nil {
-		return nil, err
-	}
-
-	if c.ExplicitRoot != "" {
-		p.ImportRoot = gps.ProjectRoot(c.ExplicitRoot)
-	} else {
-		ip, err := c.ImportForAbs(p.AbsRoot)
-		if err != nil {
-			return nil, errors.Wrap(err, "root project import")
-		}
-		p.ImportRoot = gps.ProjectRoot(ip)
-	}
-
 	mp := filepath.Join(p.AbsRoot, ManifestName)
 	mf, err := os.Open(mp)
 	if err != nil {
@@ -174,12 +227,21 @@ func (c *Ctx) LoadProject() (*Project, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "error while parsing %s", mp)
 	}
+	p.Manifest.BuildContext = c.BuildContext
+
+	p.ImportRoot, err = c.InferImportRoot(p)
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse in the root package tree.
 	ptree, err := p.parseRootPackageTree()
 	if err != nil {
 		return nil, err
 	}
+	if !withLock {
+		return p, nil
+	}
 
 	lp := filepath.Join(p.AbsRoot, LockName)
 	lf, err := os.Open(lp)
@@ -191,6 +253,14 @@ func (c *Ctx) LoadProject() (*Project, error) {
 			return nil, errors.Wrapf(err, "error while parsing %s", lp)
 		}
 
+		// The daemon keeps the version lists of the projects in recently used
+		// locks fresh in the persistent cache, when there is one.
+		if c.CacheAge > 0 {
+			if err := recordRecentProject(c.CacheDir(), p.AbsRoot, time.Now()); err != nil && c.Verbose {
+				c.Err.Printf("dep: unable to record %s as recently used: %s\n", p.AbsRoot, err)
+			}
+		}
+
 		// If there's a current Lock, apply the input and pruneopt changes that we
 		// can know without solving.
 		if p.Lock != nil {
@@ -200,6 +270,7 @@ func (c *Ctx) LoadProject() (*Project, error) {
 			for k, lp := range p.ChangedLock.Projects() {
 				vp := lp.(verify.VerifiableProject)
 				vp.PruneOpts = p.Manifest.PruneOptions.PruneOptionsFor(lp.Ident().ProjectRoot)
+				vp.Assets = p.Manifest.PruneOptions.Assets[lp.Ident().ProjectRoot]
 				p.ChangedLock.P[k] = vp
 			}
 		}
@@ -213,6 +284,20 @@ func (c *Ctx) LoadProject() (*Project, error) {
 	return p, nil
 }
 
+// ManifestPath returns the path to the manifest of the project containing the
+// working directory. Unlike LoadProject, it neither reads nor validates the
+// manifest.
+func (c *Ctx) ManifestPath() (string, error) {
+	root, err := findProjectRoot(c.WorkingDir)
+	if err != nil {
+		return "", err
+	}
+	if err = checkGopkgFilenames(root); err != nil {
+		return "", err
+	}
+	return filepath.Join(root, ManifestName), nil
+}
+
 func externalImportList(rpt pkgtree.PackageTree, m gps.RootManifest) []string {
 	rm, _ := rpt.ToReachMap(true, true, false, m.IgnoredPackages())
 	reach := rm.FlattenFn(paths.IsStandardImportPath)
@@ -240,6 +325,98 @@ func externalImportList(rpt pkgtree.PackageTree, m gps.RootManifest) []string {
 	return reach
 }
 
+// InferImportRoot determines the import path of the project rooted at
+// p.AbsRoot, and sets c.GOPATH accordingly. p.Manifest may be nil, as when
+// initializing a new project. In order of precedence, the import root is
+// taken from:
+//
+//  1. An explicitly-set root (DEPPROJECTROOT).
+//  2. The import-root field of p.Manifest, if p.Manifest is non-nil.
+//  3. The project's location within a GOPATH.
+//  4. The module line of a go.mod file in the project root.
+//
+// Only the third requires the project to live within a GOPATH. If it does not,
+// c.GOPATH is set to the first known GOPATH, if any, as that is still used for
+// the default cache location.
+//
+// ResolveImportRoot reports how the import root was determined.
+func (c *Ctx) InferImportRoot(p *Project) (gps.ProjectRoot, error) {
+	r, err := c.ResolveImportRoot(p)
+	c.GOPATH = r.GOPATH
+	return r.ImportRoot, err
+}
+
+// firstGOPATH returns the first known GOPATH, or the empty string if there are
+// none.
+func (c *Ctx) firstGOPATH() string {
+	if len(c.GOPATHs) == 0 {
+		return ""
+	}
+	return c.GOPATHs[0]
+}
+
+// modFileName is the name of the go.mod-style file from which the project's
+// import root may be read when it lives outside of GOPATH.
+const modFileName = "go.mod"
+
+// readModulePath returns the module path declared in the go.mod-style file at
+// path, or the empty string if the file does not exist or does not declare a
+// module.
+func readModulePath(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "could not read %s", path)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "module" {
+			continue
+		}
+
+		mod := fields[1]
+		if strings.HasPrefix(mod, `"`) || strings.HasPrefix(mod, "`") {
+			mod, err = strconv.Unquote(mod)
+			if err != nil {
+				return "", errors.Wrapf(err, "invalid module line in %s", path)
+			}
+		}
+		return mod, nil
+	}
+
+	return "", nil
+}
+
+// readGoDirective returns the minimum Go version declared by the go directive
+// of the go.mod-style file at path, or the empty string if the file does not
+// exist or does not declare a valid one.
+func readGoDirective(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "could not read %s", path)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "go" {
+			continue
+		}
+		gv, err := gps.ParseGoVersion(fields[1])
+		if err != nil {
+			return "", nil
+		}
+		return gv, nil
+	}
+
+	return "", nil
+}
+
 // DetectProjectGOPATH attempt to find the GOPATH containing the project.
 //
 //  If p.AbsRoot is not a symlink and is within a GOPATH, the GOPATH containing p.AbsRoot is returned.
@@ -260,7 +437,7 @@ func (c *Ctx) DetectProjectGOPATH(p *Project) (string, error) {
 
 	if c.ExplicitRoot != "" {
 		// If an explicit root is set, just use the first GOPATH in the list.
-		return c.GOPATHs[0], nil
+		return c.firstGOPATH(), nil
 	}
 
 	pGOPATH, perr := c.detectGOPATH(p.AbsRoot)
@@ -294,36 +471,56 @@ func (c *Ctx) DetectProjectGOPATH(p *Project) (string, error) {
 	return pGOPATH, nil
 }
 
-// detectGOPATH detects the GOPATH for a given path from ctx.GOPATHs.
+// detectGOPATH detects the GOPATH for a given path from ctx.GOPATHs. If the
+// path is within several, as when GOPATHs are nested, the innermost is
+// returned. A GOPATH that is a symlink also contains the paths below its
+// target. As with the go tool, relative GOPATHs are ignored.
 func (c *Ctx) detectGOPATH(path string) (string, error) {
+	var found, foundForm string
 	for _, gp := range c.GOPATHs {
-		isPrefix, err := fs.HasFilepathPrefix(path, gp)
-		if err != nil {
-			return "", errors.Wrap(err, "failed to detect GOPATH")
+		if !filepath.IsAbs(gp) {
+			continue
 		}
-		if isPrefix {
-			return gp, nil
+		for _, form := range gopathForms(gp) {
+			isPrefix, err := fs.HasFilepathPrefix(path, form)
+			if err != nil {
+				return "", errors.Wrap(err, "failed to detect GOPATH")
+			}
+			if isPrefix && len(form) > len(foundForm) {
+				found, foundForm = gp, form
+			}
 		}
 	}
-	return "", errors.Errorf("%s is not within a known GOPATH/src", path)
+	if found == "" {
+		return "", errors.Errorf("%s is not within a known GOPATH/src", path)
+	}
+	return found, nil
 }
 
 // ImportForAbs returns the import path for an absolute project path by trimming the
 // `$GOPATH/src/` prefix.  Returns an error for paths equal to, or without this prefix.
 func (c *Ctx) ImportForAbs(path string) (string, error) {
-	srcprefix := filepath.Join(c.GOPATH, "src") + string(filepath.Separator)
-	isPrefix, err := fs.HasFilepathPrefix(path, srcprefix)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to find import path")
-	}
-	if isPrefix {
-		if len(path) <= len(srcprefix) {
-			return "", errors.New("dep does not currently support using GOPATH/src as the project root")
+	return c.importForAbsIn(path, c.GOPATH)
+}
+
+// importForAbsIn is ImportForAbs for the GOPATH gp, which may also be reached
+// through the path it resolves to.
+func (c *Ctx) importForAbsIn(path, gp string) (string, error) {
+	for _, form := range gopathForms(gp) {
+		srcprefix := filepath.Join(form, "src") + string(filepath.Separator)
+		isPrefix, err := fs.HasFilepathPrefix(path, srcprefix)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to find import path")
 		}
+		if isPrefix {
+			if len(path) <= len(srcprefix) {
+				return "", errors.New("dep does not currently support using GOPATH/src as the project root")
+			}
 
-		// filepath.ToSlash because we're dealing with an import path now,
-		// not an fs path
-		return filepath.ToSlash(path[len(srcprefix):]), nil
+			// filepath.ToSlash because we're dealing with an import path now,
+			// not an fs path
+			return filepath.ToSlash(path[len(srcprefix):]), nil
+		}
 	}
 
 	return "", errors.Errorf("%s is not within any GOPATH/src", path)
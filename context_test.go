@@ -15,6 +15,7 @@ import (
 	"testing"
 	"unicode"
 
+	"github.com/golang/dep/gps"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -327,6 +328,59 @@ func TestLoadProjectNoSrcDir(t *testing.T) {
 	}
 }
 
+func TestLoadProjectOutsideGOPATH(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile(filepath.Join("manifest", ManifestName), `import-root = "github.com/user/manifest"`)
+	h.TempFile(filepath.Join("gomod", ManifestName), "")
+	h.TempFile(filepath.Join("gomod", modFileName), "module \"github.com/user/gomod\"\n\nrequire github.com/pkg/errors v0.8.0\n")
+	h.TempFile(filepath.Join("both", ManifestName), `import-root = "github.com/user/both"`)
+	h.TempFile(filepath.Join("both", modFileName), "module github.com/user/other\n")
+	h.TempFile(filepath.Join("neither", ManifestName), "")
+	h.TempDir("gopath")
+
+	cases := []struct {
+		wd      string
+		want    gps.ProjectRoot
+		wantErr bool
+	}{
+		{"manifest", "github.com/user/manifest", false},
+		{"gomod", "github.com/user/gomod", false},
+		{"both", "github.com/user/both", false},
+		{"neither", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.wd, func(t *testing.T) {
+			ctx := &Ctx{
+				Out: discardLogger(),
+				Err: discardLogger(),
+			}
+			if err := ctx.SetPaths(h.Path(c.wd), h.Path("gopath")); err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			p, err := ctx.LoadProject()
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error loading a project outside GOPATH with no declared import path, got root %q", p.ImportRoot)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadProject failed: %+v", err)
+			}
+			if p.ImportRoot != c.want {
+				t.Errorf("unexpected import root:\n\t(GOT) %q\n\t(WNT) %q", p.ImportRoot, c.want)
+			}
+			if ctx.GOPATH != h.Path("gopath") {
+				t.Errorf("expected GOPATH to fall back to %q, got %q", h.Path("gopath"), ctx.GOPATH)
+			}
+		})
+	}
+}
+
 func TestLoadProjectGopkgFilenames(t *testing.T) {
 	// We are trying to skip this test on file systems which are case-sensiive. We could
 	// have used `fs.IsCaseSensitiveFilesystem` for this check. However, the code we are
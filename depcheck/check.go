@@ -0,0 +1,134 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package depcheck verifies a checkout of a project managed by dep, as dep
+// check does, so that CI plugins can do so without running dep itself.
+//
+// It only reads the checkout: Gopkg.toml, Gopkg.lock, the project's packages
+// and vendor/. No source manager is created, so nothing is fetched over the
+// network, and dep's cache is neither read nor locked:
+//
+//	res, err := depcheck.Check(".")
+//	if err != nil {
+//		return err
+//	}
+//	if err := res.Err(); err != nil {
+//		// Gopkg.lock or vendor/ is out of sync; err is a verify.Errors.
+//		return err
+//	}
+//
+// The failures returned by Result.Err match the sentinel errors of the
+// verify package, and ErrStaleVendor, under errors.Is and verify.Errors.Is.
+package depcheck
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// ErrStaleVendor indicates that vendor/ was written from a different lock than
+// the one in the checkout, as when Gopkg.lock was changed without vendor/
+// being written again.
+var ErrStaleVendor = errors.New("vendor written from a different " + dep.LockName)
+
+// Result is the result of checking a project.
+type Result struct {
+	verify.RootVerification
+	// Project is the project that was checked.
+	Project *dep.Project
+	// StaleVendor is set if vendor/ records the snapshot of a lock other
+	// than the project's; see VendorStale.
+	StaleVendor bool
+}
+
+// Err returns nil if the project's lock satisfied its inputs and its vendor
+// tree matched the lock. Otherwise, it returns an Errors combining the
+// failures from RootVerification.Err and, if vendor/ is stale,
+// ErrStaleVendor.
+func (r *Result) Err() error {
+	err := r.RootVerification.Err()
+	if !r.StaleVendor {
+		return err
+	}
+	switch err := err.(type) {
+	case nil:
+		return verify.Errors{ErrStaleVendor}
+	case verify.Errors:
+		return append(err, ErrStaleVendor)
+	default:
+		return err
+	}
+}
+
+// Check checks the project whose root is dir, or contains it: that its lock
+// satisfies its manifest and imports, and that each project in its vendor
+// directory matches the digest in the lock. The import root of the project is
+// determined as dep does, from gopaths, or if none are given, from $GOPATH.
+//
+// Check only returns an error if the project could not be checked at all,
+// such as when it has no manifest or lock.
+func Check(dir string, gopaths ...string) (*Result, error) {
+	discard := log.New(ioutil.Discard, "", 0)
+	ctx := &dep.Ctx{Out: discard, Err: discard}
+	if err := ctx.SetPaths(dir, gopaths...); err != nil {
+		return nil, err
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return nil, err
+	}
+	if p.Lock == nil {
+		return nil, errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+
+	params := p.MakeParams()
+	res := &Result{
+		RootVerification: verify.RootVerification{
+			Name:             string(p.ImportRoot),
+			LockSatisfaction: verify.LockSatisfiesInputs(p.Lock, p.Manifest, params.RootPackageTree),
+		},
+		Project: p,
+	}
+	res.VendorStatus, res.VendorErr = VendorStatus(p)
+	if res.VendorErr != nil {
+		return res, nil
+	}
+	res.StaleVendor, res.VendorErr = VendorStale(p)
+	return res, nil
+}
+
+// VendorStatus returns the status of each project in p's vendor directory
+// relative to its lock. Unlike p.VerifyVendor, it does not create the vendor
+// directory if it is absent; every locked project is then reported as missing
+// from it.
+func VendorStatus(p *dep.Project) (map[string]verify.VendorStatus, error) {
+	if _, err := os.Stat(p.VendorDir()); os.IsNotExist(err) {
+		status := make(map[string]verify.VendorStatus, len(p.Lock.Projects()))
+		for _, lp := range p.Lock.Projects() {
+			status[string(lp.Ident().ProjectRoot)] = verify.NotInTree
+		}
+		return status, nil
+	}
+
+	status, err := p.VerifyVendor()
+	return status, errors.Wrap(err, "error while verifying vendor directory")
+}
+
+// VendorStale reports whether p's vendor directory records the snapshot of a
+// lock other than p's, as when Gopkg.lock was changed, or checked out from
+// another revision, without re-vendoring. A vendor directory that is absent,
+// or records no snapshot, is never reported as stale.
+func VendorStale(p *dep.Project) (bool, error) {
+	snap, err := verify.ReadLockSnapshot(p.VendorDir())
+	if err != nil || snap == "" {
+		return false, err
+	}
+	return snap != verify.LockSnapshot(p.Lock), nil
+}
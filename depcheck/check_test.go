@@ -0,0 +1,120 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depcheck
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+)
+
+// matches reports whether err is a verify.Errors matching target, without
+// relying on errors.Is, which not every Go release dep supports has.
+func matches(err, target error) bool {
+	errs, ok := err.(verify.Errors)
+	return ok && errs.Is(target)
+}
+
+func TestCheck(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "depcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	root := filepath.Join(gopath, "src", "example.com", "foo")
+	writeFile := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(dep.ManifestName, "")
+	writeFile(dep.LockName, "[solve-meta]\n  input-imports = []\n")
+	writeFile("main.go", "package main\n\nfunc main() {}\n")
+
+	res, err := Check(root, gopath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Name != "example.com/foo" {
+		t.Errorf("expected the project to be checked as example.com/foo, got %q", res.Name)
+	}
+	if err := res.Err(); err != nil {
+		t.Fatalf("expected an up to date project to pass, got %v", err)
+	}
+
+	writeFile("main.go", "package main\n\nimport _ \"github.com/pkg/errors\"\n\nfunc main() {}\n")
+	writeFile(filepath.Join("vendor", "github.com", "foo", "orphan", "orphan.go"), "package orphan\n")
+	res, err = Check(root, gopath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = res.Err()
+	if !matches(err, verify.ErrMissingFromLock) {
+		t.Errorf("expected the new import and the orphaned vendored project to be reported as missing from the lock, got %v", err)
+	}
+	if res.StaleVendor {
+		t.Error("expected vendor/ without a snapshot not to be stale")
+	}
+
+	if err := verify.WriteLockSnapshot(res.Project.VendorDir(), &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("abc123"), []string{"."}),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	res, err = Check(root, gopath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Err(); !res.StaleVendor || !matches(err, ErrStaleVendor) {
+		t.Errorf("expected vendor/ written from another lock to be stale, got %v", err)
+	}
+}
+
+func TestVendorStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "depcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lock := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("abc123"), []string{"."}),
+		},
+	}
+	p := &dep.Project{AbsRoot: dir, Lock: lock}
+
+	// Neither a missing vendor directory nor one without a snapshot is stale.
+	if stale, err := VendorStale(p); err != nil || stale {
+		t.Fatalf("expected no stale vendor without a snapshot, got %v, %v", stale, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "vendor"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify.WriteLockSnapshot(p.VendorDir(), lock); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := VendorStale(p); err != nil || stale {
+		t.Fatalf("expected vendor written from the lock not to be stale, got %v, %v", stale, err)
+	}
+
+	p.Lock = &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.1.0").Pair("def456"), []string{"."}),
+		},
+	}
+	if stale, err := VendorStale(p); err != nil || !stale {
+		t.Errorf("expected vendor written from another lock to be stale, got %v, %v", stale, err)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// SumName is the name of the file, alongside the lock, in which dep records
+// checksums of the vendored projects in the format of go.sum, if the manifest
+// sets go-sum.
+const SumName = "Gopkg.sum"
+
+// UpdateGoSum rewrites the project's SumName from its Gopkg.lock and vendor
+// directory, if its manifest sets go-sum. It is meant to be called once both
+// have been written.
+func (p *Project) UpdateGoSum() error {
+	if !p.Manifest.GoSum {
+		return nil
+	}
+
+	lp := filepath.Join(p.AbsRoot, LockName)
+	lf, err := os.Open(lp)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", lp)
+	}
+	defer lf.Close()
+	l, err := readLock(lf)
+	if err != nil {
+		return errors.Wrapf(err, "error while parsing %s", lp)
+	}
+
+	sum, err := GoSum(l, p.VendorDir())
+	if err != nil {
+		return err
+	}
+	sp := filepath.Join(p.AbsRoot, SumName)
+	if orig, err := ioutil.ReadFile(sp); err == nil && bytes.Equal(orig, sum) {
+		return nil
+	}
+	return errors.Wrapf(replaceFile(sp, sum), "failed to write %s", sp)
+}
+
+// GoSum returns the lines of a go.sum file for the projects in l, hashed from
+// their copies in vendorDir with the go command's "h1:" algorithm. Projects
+// that are not in vendorDir are left out.
+//
+// Projects are listed by the module path in their go.mod, if they have one,
+// and their project root otherwise. A project locked to a semver tag starting
+// with "v" is listed at that version, as the go command would do; for any
+// other revision, whose commit time the lock does not record, a pseudo-version
+// with a zero time is used. The checksums only match those of the go command
+// for projects that are vendored in full, without pruning.
+func GoSum(l *Lock, vendorDir string) ([]byte, error) {
+	var lines []string
+	for _, lp := range l.Projects() {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(string(lp.Ident().ProjectRoot)))
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			continue
+		}
+
+		mod := string(lp.Ident().ProjectRoot)
+		gomod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		hasGoMod := err == nil
+		if hasGoMod {
+			if mp := modulePath(gomod); mp != "" {
+				mod = mp
+			}
+		} else {
+			// The go command synthesizes a go.mod for projects without one.
+			gomod = []byte("module " + mod + "\n")
+		}
+		v := moduleVersion(lp.Version(), hasGoMod)
+
+		h, err := hashModuleDir(dir, mod+"@"+v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to hash %s", lp.Ident().ProjectRoot)
+		}
+		// The go command hashes go.mod under its bare name.
+		gh := hashModuleFiles([]moduleFile{{name: "go.mod", digest: sha256.Sum256(gomod)}})
+		lines = append(lines, mod+" "+v+" "+h, mod+" "+v+"/go.mod "+gh)
+	}
+	sort.Strings(lines)
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// modulePath returns the path declared by the module directive of a go.mod,
+// or "" if it has none.
+func modulePath(gomod []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(gomod))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+	return ""
+}
+
+// moduleVersion returns the module version that the go command would assign
+// to v, as far as the lock allows: see GoSum.
+func moduleVersion(v gps.Version, hasGoMod bool) string {
+	rev, _, tag := gps.VersionComponentStrings(v)
+	if v.Type() == gps.IsSemver && strings.HasPrefix(tag, "v") {
+		if sv, err := semver.NewVersion(tag); err == nil {
+			if sv.Major() >= 2 && !hasGoMod {
+				return tag + "+incompatible"
+			}
+			return tag
+		}
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	return "v0.0.0-00010101000000-" + rev
+}
+
+type moduleFile struct {
+	name   string
+	digest [sha256.Size]byte
+}
+
+// hashModuleDir hashes the files in dir as those of the module version at
+// prefix, such as "github.com/pkg/errors@v0.8.0". As in a module zip, nested
+// modules and vendor directories are left out.
+func hashModuleDir(dir, prefix string) (string, error) {
+	var files []moduleFile
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if fi.Name() == "vendor" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		mf := moduleFile{name: prefix + "/" + filepath.ToSlash(rel)}
+		copy(mf.digest[:], h.Sum(nil))
+		files = append(files, mf)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hashModuleFiles(files), nil
+}
+
+// hashModuleFiles implements the go command's "h1:" hash: the SHA-256 of a
+// summary listing the SHA-256 and name of each file, sorted by name.
+func hashModuleFiles(files []moduleFile) string {
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%x  %s\n", f.digest, f.name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestGoSum(t *testing.T) {
+	vendor, err := ioutil.TempDir("", "dep-gosum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(vendor)
+
+	files := map[string]string{
+		"github.com/foo/bar/bar.go":           "package bar\n",
+		"github.com/foo/bar/LICENSE":          "MIT\n",
+		"github.com/foo/bar/sub/sub.go":       "package sub\n",
+		"github.com/foo/bar/nested/go.mod":    "module github.com/foo/bar/nested\n",
+		"github.com/foo/bar/nested/nested.go": "package nested\n",
+		"github.com/baz/qux/go.mod":           "module github.com/baz/qux/v2\n",
+		"github.com/baz/qux/qux.go":           "package qux\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(vendor, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rev := gps.Revision("645ef00459ed84a119197bfb8d8205042c6df63d")
+	l := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/baz/qux"}, gps.NewVersion("v2.1.0").Pair(rev), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewBranch("master").Pair(rev), []string{".", "sub"}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/not/vendored"}, rev, []string{"."}),
+		},
+	}
+
+	got, err := GoSum(l, vendor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"github.com/baz/qux/v2 v2.1.0",
+		"github.com/baz/qux/v2 v2.1.0/go.mod",
+		"github.com/foo/bar v0.0.0-00010101000000-645ef00459ed",
+		"github.com/foo/bar v0.0.0-00010101000000-645ef00459ed/go.mod",
+	}
+	lines := strings.Split(strings.TrimSuffix(string(got), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected go.sum lines:\n%s", got)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]+" h1:") {
+			t.Errorf("expected line %d to be for %s, got %s", i, want[i], line)
+		}
+	}
+
+	// The hashes only depend on the files of the project, so removing the
+	// nested module leaves them unchanged.
+	if err := os.RemoveAll(filepath.Join(vendor, "github.com", "foo", "bar", "nested")); err != nil {
+		t.Fatal(err)
+	}
+	again, err := GoSum(l, vendor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(got) {
+		t.Errorf("expected nested modules to be left out of the hash, got:\n%s\nthen:\n%s", got, again)
+	}
+}
+
+func TestHashModuleFiles(t *testing.T) {
+	// The go.mod of github.com/jstemmer/go-junit-report, as listed in the
+	// go.sum of projects using it at v1.0.0.
+	gomod := []byte("module github.com/jstemmer/go-junit-report\n\ngo 1.2\n")
+	h := hashModuleFiles([]moduleFile{{name: "go.mod", digest: sha256.Sum256(gomod)}})
+	if want := "h1:Brl9GWCQeLvo8nXZwPNNblvFj/XSXhF0NWZEnDohbsk="; h != want {
+		t.Errorf("expected hash %s, got %s", want, h)
+	}
+}
+
+func TestModuleVersion(t *testing.T) {
+	rev := gps.Revision("645ef00459ed84a119197bfb8d8205042c6df63d")
+	cases := []struct {
+		v        gps.Version
+		hasGoMod bool
+		want     string
+	}{
+		{gps.NewVersion("v1.2.3").Pair(rev), false, "v1.2.3"},
+		{gps.NewVersion("v2.0.0").Pair(rev), false, "v2.0.0+incompatible"},
+		{gps.NewVersion("v2.0.0").Pair(rev), true, "v2.0.0"},
+		{gps.NewVersion("1.2.3").Pair(rev), false, "v0.0.0-00010101000000-645ef00459ed"},
+		{gps.NewBranch("master").Pair(rev), false, "v0.0.0-00010101000000-645ef00459ed"},
+		{rev, false, "v0.0.0-00010101000000-645ef00459ed"},
+	}
+	for _, c := range cases {
+		if got := moduleVersion(c.v, c.hasGoMod); got != c.want {
+			t.Errorf("moduleVersion(%s, %v) = %s, want %s", c.v, c.hasGoMod, got, c.want)
+		}
+	}
+}
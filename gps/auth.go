@@ -0,0 +1,147 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Credentials are the username and password, or token, with which the sources
+// on a host are reached over HTTPS.
+type Credentials struct {
+	Username string
+	Password string
+	// Expiry is when the credentials expire, or the zero value if they do
+	// not.
+	Expiry time.Time
+}
+
+// An AuthProvider obtains the credentials for the sources on a host, such as
+// the short-lived tokens issued to CI jobs, so that they need not be kept in
+// the environment.
+type AuthProvider interface {
+	// Credentials obtains the credentials for host. client is the HTTP
+	// client to obtain them with, which reaches hosts through the proxies of
+	// the SourceMgr.
+	Credentials(ctx context.Context, client *http.Client, host string) (Credentials, error)
+}
+
+// AuthProviders maps host patterns, in the same form as in NetworkPolicy, to
+// the providers of the credentials for the hosts they match. Host names take
+// precedence over wildcards, and longer wildcards over shorter ones.
+//
+// Credentials are only ever sent over HTTPS: with the go-get metadata requests
+// to the host, and to git, through the GIT_CONFIG_COUNT environment variable,
+// which requires git 2.31 or later. They are not given to other version
+// control systems.
+type AuthProviders map[string]AuthProvider
+
+// authExpiryMargin is how long before they expire that credentials are
+// obtained again, so that no command starts with credentials that expire
+// while it runs.
+const authExpiryMargin = 5 * time.Minute
+
+// authCache holds the credentials obtained from AuthProviders until they are
+// about to expire.
+type authCache struct {
+	providers AuthProviders
+	client    *http.Client
+	mu        sync.Mutex // Guards creds, and serializes obtaining them
+	creds     map[string]Credentials
+}
+
+func newAuthCache(providers AuthProviders, client *http.Client) *authCache {
+	return &authCache{
+		providers: providers,
+		client:    client,
+		creds:     make(map[string]Credentials),
+	}
+}
+
+// credentials returns the credentials for host, and whether any provider
+// matches it at all.
+func (a *authCache) credentials(ctx context.Context, host string) (Credentials, bool, error) {
+	host = strings.ToLower(host)
+	best := ""
+	for pat := range a.providers {
+		if hostMatches(pat, host) && morePrecise(pat, best) {
+			best = pat
+		}
+	}
+	if best == "" {
+		return Credentials{}, false, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, has := a.creds[host]; has && (c.Expiry.IsZero() || time.Until(c.Expiry) > authExpiryMargin) {
+		return c, true, nil
+	}
+	c, err := a.providers[best].Credentials(ctx, a.client, host)
+	if err != nil {
+		return Credentials{}, true, errors.Wrapf(err, "could not obtain credentials for %s", host)
+	}
+	a.creds[host] = c
+	return c, true, nil
+}
+
+// commandEnv returns the environment variables that have git send the
+// credentials for u, if it is an HTTPS URL on a host that a provider matches.
+func (a *authCache) commandEnv(ctx context.Context, u *url.URL) ([]string, error) {
+	if u.Scheme != "https" {
+		return nil, nil
+	}
+	c, found, err := a.credentials(ctx, u.Hostname())
+	if err != nil || !found {
+		return nil, err
+	}
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://" + u.Host + "/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: " + c.basicAuth(),
+	}, nil
+}
+
+func (c Credentials) basicAuth() string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+}
+
+// authTransport sends the credentials for the host of each HTTPS request made
+// through it.
+type authTransport struct {
+	base http.RoundTripper
+	auth *authCache
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	c, found, err := t.auth.credentials(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it is given.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", c.basicAuth())
+	return t.base.RoundTrip(r)
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GitHubAppAuth obtains installation tokens for a GitHub App, which are valid
+// for an hour, for the repositories the installation has access to.
+type GitHubAppAuth struct {
+	AppID          string
+	InstallationID string
+	// Key is the private key of the app, with which the requests for tokens
+	// are signed.
+	Key *rsa.PrivateKey
+	// APIURL is the root of the GitHub API, such as
+	// "https://github.example.com/api/v3" for GitHub Enterprise. If empty, it
+	// is that of github.com.
+	APIURL string
+}
+
+// Credentials implements AuthProvider.
+func (g GitHubAppAuth) Credentials(ctx context.Context, client *http.Client, host string) (Credentials, error) {
+	jwt, err := g.jwt(time.Now())
+	if err != nil {
+		return Credentials{}, err
+	}
+	api := g.APIURL
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	u := fmt.Sprintf("%s/app/installations/%s/access_tokens", strings.TrimSuffix(api, "/"), url.PathEscape(g.InstallationID))
+
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	var tok struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := doTokenRequest(ctx, client, req, &tok); err != nil {
+		return Credentials{}, err
+	}
+	if tok.Token == "" {
+		return Credentials{}, errors.Errorf("no token in the response from %s", u)
+	}
+	return Credentials{Username: "x-access-token", Password: tok.Token, Expiry: tok.ExpiresAt}, nil
+}
+
+// jwt returns the JSON web token with which the app authenticates itself,
+// valid for nine minutes, as GitHub allows ten at most. It is issued a minute
+// early, to allow for clock drift.
+func (g GitHubAppAuth) jwt(now time.Time) (string, error) {
+	if g.Key == nil {
+		return "", errors.New("no private key for the GitHub App")
+	}
+	enc := base64.RawURLEncoding
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": g.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, g.Key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign the GitHub App token request")
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// TokenExchangeAuth exchanges an OIDC ID token, such as the ID tokens issued to
+// GitLab CI jobs, for an access token, through an OAuth 2.0 token exchange
+// (RFC 8693) with a broker trusting the issuer of the ID token.
+type TokenExchangeAuth struct {
+	// URL is the token endpoint of the broker.
+	URL string
+	// IDToken is the ID token to exchange.
+	IDToken string
+	// Username is the username sent along with the access token; if empty,
+	// it is "oauth2".
+	Username string
+}
+
+// Credentials implements AuthProvider. The host is sent to the broker as the
+// resource for which the access token is wanted.
+func (t TokenExchangeAuth) Credentials(ctx context.Context, client *http.Client, host string) (Credentials, error) {
+	if t.IDToken == "" {
+		return Credentials{}, errors.New("no ID token to exchange")
+	}
+	form := url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {t.IDToken},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:id_token"},
+		"resource":           {"https://" + host},
+	}
+	req, err := http.NewRequest("POST", t.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "unable to build HTTP request for URL %q", t.URL)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := doTokenRequest(ctx, client, req, &tok); err != nil {
+		return Credentials{}, err
+	}
+	if tok.AccessToken == "" {
+		return Credentials{}, errors.Errorf("no access token in the response from %s", t.URL)
+	}
+
+	c := Credentials{Username: t.Username, Password: tok.AccessToken}
+	if c.Username == "" {
+		c.Username = "oauth2"
+	}
+	if tok.ExpiresIn > 0 {
+		c.Expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return c, nil
+}
+
+// GitLabJobTokenAuth sends the job token of a GitLab CI job, which is only
+// valid while the job runs, and for the projects the job is allowed to reach.
+type GitLabJobTokenAuth struct {
+	Token string
+}
+
+// Credentials implements AuthProvider.
+func (g GitLabJobTokenAuth) Credentials(ctx context.Context, client *http.Client, host string) (Credentials, error) {
+	if g.Token == "" {
+		return Credentials{}, errors.New("no GitLab CI job token")
+	}
+	return Credentials{Username: "gitlab-ci-token", Password: g.Token}, nil
+}
+
+// doTokenRequest makes a request for a token, decoding the JSON response into
+// v.
+func doTokenRequest(ctx context.Context, client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed HTTP request to URL %q", req.URL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("token request to %s failed with %s: %s", req.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(v), "could not decode the token from %s", req.URL)
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingAuth hands out a new token, expiring after ttl, each time it is
+// asked.
+type countingAuth struct {
+	n   int
+	ttl time.Duration
+}
+
+func (a *countingAuth) Credentials(ctx context.Context, client *http.Client, host string) (Credentials, error) {
+	a.n++
+	c := Credentials{Username: "user", Password: fmt.Sprintf("token%d", a.n)}
+	if a.ttl != 0 {
+		c.Expiry = time.Now().Add(a.ttl)
+	}
+	return c, nil
+}
+
+func TestAuthCache(t *testing.T) {
+	lasting, expiring := &countingAuth{}, &countingAuth{ttl: time.Minute}
+	a := newAuthCache(AuthProviders{
+		"*.example.com":   lasting,
+		"git.example.com": expiring,
+	}, http.DefaultClient)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if c, _, _ := a.credentials(ctx, "foo.example.com"); c.Password != "token1" {
+			t.Errorf("expected the credentials to be kept, got %q", c.Password)
+		}
+	}
+	for i := 1; i <= 2; i++ {
+		if c, _, _ := a.credentials(ctx, "GIT.example.com"); c.Password != fmt.Sprintf("token%d", i) {
+			t.Errorf("expected credentials about to expire to be obtained again, got %q", c.Password)
+		}
+	}
+	if _, found, _ := a.credentials(ctx, "github.com"); found {
+		t.Error("expected no credentials for a host no provider matches")
+	}
+
+	u, _ := url.Parse("https://foo.example.com/repo.git")
+	env, err := a.commandEnv(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://foo.example.com/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("user:token1")),
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("expected %v, got %v", want, env)
+	}
+	u.Scheme = "http"
+	if env, _ := a.commandEnv(ctx, u); env != nil {
+		t.Errorf("expected no credentials to be sent over http, got %v", env)
+	}
+}
+
+func TestAuthTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	a := newAuthCache(AuthProviders{"127.0.0.1": GitLabJobTokenAuth{Token: "job"}}, srv.Client())
+	client := &http.Client{Transport: &authTransport{base: srv.Client().Transport, auth: a}}
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("gitlab-ci-token:job")); got != want {
+		t.Errorf("expected the request to be sent with %q, got %q", want, got)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("expected the original request to be left unmodified")
+	}
+}
+
+func TestGitHubAppAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/app/installations/42/access_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			http.Error(w, "malformed JWT", http.StatusUnauthorized)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var claims struct {
+			Iss string `json:"iss"`
+		}
+		raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		if json.Unmarshal(raw, &claims); claims.Iss != "7" {
+			http.Error(w, "wrong app", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "ghs_abc", "expires_at": %q}`, expires.Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	g := GitHubAppAuth{AppID: "7", InstallationID: "42", Key: key, APIURL: srv.URL + "/"}
+	c, err := g.Credentials(context.Background(), srv.Client(), "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Credentials{Username: "x-access-token", Password: "ghs_abc", Expiry: expires}); !reflect.DeepEqual(c, want) {
+		t.Errorf("expected %v, got %v", want, c)
+	}
+
+	g.AppID = "8"
+	if _, err := g.Credentials(context.Background(), srv.Client(), "github.com"); err == nil {
+		t.Error("expected a rejected token request to fail")
+	}
+}
+
+func TestTokenExchangeAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("subject_token") != "id-token" || r.Form.Get("resource") != "https://gitlab.example.com" {
+			http.Error(w, `{"error": "invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "access", "expires_in": 3600}`)
+	}))
+	defer srv.Close()
+
+	te := TokenExchangeAuth{URL: srv.URL, IDToken: "id-token"}
+	c, err := te.Credentials(context.Background(), srv.Client(), "gitlab.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Username != "oauth2" || c.Password != "access" || time.Until(c.Expiry) < 59*time.Minute {
+		t.Errorf("unexpected credentials %v", c)
+	}
+
+	if _, err := te.Credentials(context.Background(), srv.Client(), "github.com"); err == nil {
+		t.Error("expected a rejected exchange to fail")
+	}
+}
@@ -106,6 +106,9 @@ func (b *bridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 	} else {
 		SortForUpgrade(vl)
 	}
+	if lp, has := b.s.rd.rlm[id.ProjectRoot]; has {
+		vl = b.s.rd.updstrat.order(vl, lp.Version())
+	}
 
 	b.vlists[id] = vl
 	b.s.mtr.pop()
@@ -0,0 +1,149 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// BuildContext describes the builds for which a project's dependencies are
+// solved: their target operating system and architecture, and the build tags
+// that are set.
+type BuildContext struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// HasTag reports whether tag is set in bc.
+func (bc BuildContext) HasTag(tag string) bool {
+	return contains(bc.Tags, tag)
+}
+
+// String returns bc in the form recorded in a lock as its input build context,
+// such as "goos=linux goarch=amd64 tags=cgo,netgo". Empty fields are omitted.
+func (bc BuildContext) String() string {
+	var parts []string
+	if bc.GOOS != "" {
+		parts = append(parts, "goos="+bc.GOOS)
+	}
+	if bc.GOARCH != "" {
+		parts = append(parts, "goarch="+bc.GOARCH)
+	}
+	if len(bc.Tags) > 0 {
+		tags := append([]string(nil), bc.Tags...)
+		sort.Strings(tags)
+		parts = append(parts, "tags="+strings.Join(tags, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ScopedIgnore is a rule ignoring packages only in some build contexts, as
+// when the dependencies of a project's Windows-only code are not wanted by a
+// project that is only ever built for Linux.
+//
+// The rule applies to a build context if its GOOS is one of GOOS, its GOARCH
+// is one of GOARCH, and each of Tags is set in it, or, when prefixed with "!",
+// is not. Empty lists always match, but the rule must give at least one.
+type ScopedIgnore struct {
+	// Packages holds the import paths to ignore, in the form of the ignored
+	// list of a manifest.
+	Packages []string
+	GOOS     []string
+	GOARCH   []string
+	Tags     []string
+}
+
+// Validate returns an error if si ignores no packages, or does not restrict
+// the build contexts it applies to.
+func (si ScopedIgnore) Validate() error {
+	if len(si.Packages) == 0 {
+		return errors.New("a scoped ignore must list the packages to ignore")
+	}
+	if len(si.GOOS) == 0 && len(si.GOARCH) == 0 && len(si.Tags) == 0 {
+		return errors.Errorf("the scoped ignore of %s gives no goos, goarch or tags; list the packages as ignored instead", strings.Join(si.Packages, ", "))
+	}
+	for _, tag := range si.Tags {
+		if name := strings.TrimPrefix(tag, "!"); name == "" || strings.ContainsAny(name, "!, ") {
+			return errors.Errorf("invalid build tag %q in the scoped ignore of %s", tag, strings.Join(si.Packages, ", "))
+		}
+	}
+	return nil
+}
+
+// AppliesTo reports whether si applies to the build context bc.
+func (si ScopedIgnore) AppliesTo(bc BuildContext) bool {
+	if len(si.GOOS) > 0 && !contains(si.GOOS, bc.GOOS) {
+		return false
+	}
+	if len(si.GOARCH) > 0 && !contains(si.GOARCH, bc.GOARCH) {
+		return false
+	}
+	for _, tag := range si.Tags {
+		if strings.HasPrefix(tag, "!") == bc.HasTag(strings.TrimPrefix(tag, "!")) {
+			return false
+		}
+	}
+	return true
+}
+
+// RelevantTo returns the parts of bc that bear on whether any of sis apply:
+// its GOOS and GOARCH only if some rule restricts them, and only those of its
+// tags that some rule names. Build contexts that differ only in other ways
+// ignore the same packages, and are solved alike.
+func (bc BuildContext) RelevantTo(sis []ScopedIgnore) BuildContext {
+	var rel BuildContext
+	for _, si := range sis {
+		if len(si.GOOS) > 0 {
+			rel.GOOS = bc.GOOS
+		}
+		if len(si.GOARCH) > 0 {
+			rel.GOARCH = bc.GOARCH
+		}
+		for _, tag := range si.Tags {
+			name := strings.TrimPrefix(tag, "!")
+			if bc.HasTag(name) && !rel.HasTag(name) {
+				rel.Tags = append(rel.Tags, name)
+			}
+		}
+	}
+	return rel
+}
+
+// IgnoredRulesetFor returns the ruleset ignoring the packages in ignored,
+// along with those of each of sis that applies to bc.
+func IgnoredRulesetFor(ignored []string, sis []ScopedIgnore, bc BuildContext) *pkgtree.IgnoredRuleset {
+	all := append([]string(nil), ignored...)
+	for _, si := range sis {
+		if si.AppliesTo(bc) {
+			all = append(all, si.Packages...)
+		}
+	}
+	return pkgtree.NewIgnoredRuleset(all)
+}
+
+// BuildContextRootManifest is a RootManifest whose ignored packages depend on
+// the build context.
+type BuildContextRootManifest interface {
+	RootManifest
+	// InputBuildContext returns the parts of the build context that
+	// IgnoredPackages depends on, in the form returned by BuildContext.String,
+	// or the empty string if it depends on none.
+	InputBuildContext() string
+}
+
+// InputBuildContext returns the build context m solves for, in the form
+// recorded in a lock as its input build context, or the empty string if the
+// packages m ignores do not depend on it.
+func InputBuildContext(m RootManifest) string {
+	if bm, ok := m.(BuildContextRootManifest); ok {
+		return bm.InputBuildContext()
+	}
+	return ""
+}
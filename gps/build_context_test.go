@@ -0,0 +1,34 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "testing"
+
+func TestScopedIgnoreAppliesTo(t *testing.T) {
+	si := ScopedIgnore{
+		Packages: []string{"golang.org/x/sys/windows"},
+		GOOS:     []string{"linux", "darwin"},
+		Tags:     []string{"!windows_compat"},
+	}
+	if err := si.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for bc, want := range map[*BuildContext]bool{
+		{GOOS: "linux", GOARCH: "amd64"}:                                   true,
+		{GOOS: "darwin", GOARCH: "arm64", Tags: []string{"cgo"}}:           true,
+		{GOOS: "windows", GOARCH: "amd64"}:                                 false,
+		{GOOS: "linux", GOARCH: "amd64", Tags: []string{"windows_compat"}}: false,
+	} {
+		if got := si.AppliesTo(*bc); got != want {
+			t.Errorf("%s: expected AppliesTo to be %v, got %v", bc, want, got)
+		}
+	}
+
+	bc := BuildContext{GOOS: "linux", GOARCH: "amd64", Tags: []string{"netgo", "windows_compat"}}
+	if got, want := bc.RelevantTo([]ScopedIgnore{si}).String(), "goos=linux tags=windows_compat"; got != want {
+		t.Errorf("expected the relevant build context %q, got %q", want, got)
+	}
+}
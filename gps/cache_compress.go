@@ -0,0 +1,210 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A compressed cache keeps its sources and metadata compressed while they are
+// at rest, which is while no SourceMgr is using the cache.
+//
+// Each source directory is replaced by a gzipped tarball of its tree, named
+// by packedSourceExt, and the metadata database by a gzipped copy, named by
+// packedMetadataExt. A source is unpacked when it is first used, and the
+// database when it is opened, whether or not the cache is compressed, so that
+// compression can be turned off at any time. Everything is read from the
+// unpacked forms, so digests of exported trees are unaffected.
+const (
+	packedSourceExt   = ".tar.gz"
+	packedMetadataExt = ".gz"
+)
+
+// unpackedSourceCachePath returns the path of the source cache dir for
+// sourceURL, as sourceCachePath does, after unpacking the source if it is
+// packed.
+func unpackedSourceCachePath(cacheDir, sourceURL string) (string, error) {
+	path := sourceCachePath(cacheDir, sourceURL)
+	return path, unpackSource(path)
+}
+
+// sourceIsCached reports whether the source cache dir for sourceURL exists,
+// packed or not.
+func sourceIsCached(cacheDir, sourceURL string) bool {
+	path := sourceCachePath(cacheDir, sourceURL)
+	for _, p := range []string{path, path + packedSourceExt} {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// packSource replaces the source directory dir with its packed form. The
+// archive is written in full before dir is removed, so that an interruption
+// leaves at least one of them complete.
+func packSource(dir string) error {
+	f, err := ioutil.TempFile(filepath.Dir(dir), filepath.Base(dir)+".pack")
+	if err != nil {
+		return errors.Wrapf(err, "failed to pack %s", dir)
+	}
+	defer os.Remove(f.Name())
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	err = writeTreeToTar(tw, dir)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gzw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), dir+packedSourceExt)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to pack %s", dir)
+	}
+	return errors.Wrapf(os.RemoveAll(dir), "failed to remove %s after packing it", dir)
+}
+
+// unpackSource restores the source directory dir from its packed form, if it
+// is packed. If both exist, packing was interrupted, and the directory is
+// kept. An archive that cannot be unpacked is kept as it is, so that nothing
+// in the cache is lost to a failure to unpack it.
+//
+// The archive was written by packSource, from the cache's own tree, so its
+// symlinks are restored as they were, even those leading outside of it.
+func unpackSource(dir string) error {
+	archive := dir + packedSourceExt
+	if _, err := os.Stat(archive); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return errors.Wrapf(os.Remove(archive), "failed to remove stale %s", archive)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unpack %s", archive)
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unpack %s", archive)
+	}
+
+	tmp := dir + ".unpack"
+	if err = os.RemoveAll(tmp); err != nil {
+		return errors.Wrap(err, "failed to clear incomplete unpacking")
+	}
+	err = extractTarArchive(context.Background(), tar.NewReader(gzr), tmp, false)
+	if err == nil {
+		err = os.Rename(tmp, dir)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return errors.Wrapf(err, "failed to unpack %s", archive)
+	}
+	f.Close()
+	return errors.Wrapf(os.Remove(archive), "failed to remove %s after unpacking it", archive)
+}
+
+// packFile replaces the file at path with a gzipped copy, if it exists.
+func packFile(path string) error {
+	return convertFile(path, path+packedMetadataExt, func(w io.Writer, r io.Reader) error {
+		gzw := gzip.NewWriter(w)
+		if _, err := io.Copy(gzw, r); err != nil {
+			return err
+		}
+		return gzw.Close()
+	})
+}
+
+// unpackFile restores the file at path from its gzipped copy, if it exists.
+func unpackFile(path string) error {
+	return convertFile(path+packedMetadataExt, path, func(w io.Writer, r io.Reader) error {
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, gzr)
+		return err
+	})
+}
+
+// convertFile replaces the file at from, if it exists, with the file at to,
+// written by conv from its contents.
+func convertFile(from, to string, conv func(io.Writer, io.Reader) error) error {
+	src, err := os.Open(from)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := ioutil.TempFile(filepath.Dir(to), filepath.Base(to))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+
+	err = conv(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(dst.Name(), fi.Mode())
+	}
+	if err == nil {
+		err = os.Rename(dst.Name(), to)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert %s to %s", from, to)
+	}
+	src.Close()
+	return os.Remove(from)
+}
+
+// compressCache packs every source directory in cacheDir, and its metadata
+// database. Failures are logged, leaving what failed unpacked.
+func compressCache(cacheDir string, logger *log.Logger) {
+	sources := filepath.Join(cacheDir, "sources")
+	infos, err := ioutil.ReadDir(sources)
+	if err != nil {
+		logger.Println(errors.Wrap(err, "failed to compress the cache"))
+		return
+	}
+	for _, fi := range infos {
+		// Skip incomplete extractions and unpackings.
+		if !fi.IsDir() || strings.HasSuffix(fi.Name(), ".tmp") || strings.HasSuffix(fi.Name(), ".unpack") {
+			continue
+		}
+		if err := packSource(filepath.Join(sources, fi.Name())); err != nil {
+			logger.Println(err)
+		}
+	}
+	if err := packFile(filepath.Join(cacheDir, boltCacheFilename)); err != nil {
+		logger.Println(errors.Wrap(err, "failed to compress the persistent cache"))
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var testSourceLinks = map[string]string{
+	"LINK": "README",
+	"ABS":  "/dev/null",
+	"UP":   "../outside",
+}
+
+func writeTestSourceTree(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string]os.FileMode{
+		"README":              0644,
+		".git/HEAD":           0644,
+		".git/objects/pack/p": 0444,
+		".git/hooks/pre-push": 0755,
+	}
+	for name, mode := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".git", "refs", "tags"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		// Checkouts may hold symlinks leading anywhere, which are kept as
+		// they are.
+		for name, target := range testSourceLinks {
+			if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func checkTestSourceTree(t *testing.T, dir string) {
+	t.Helper()
+	for _, name := range []string{"README", ".git/HEAD", ".git/objects/pack/p", ".git/hooks/pre-push"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != name {
+			t.Errorf("expected %s to hold %q, got %q", name, name, b)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, ".git", "refs", "tags")); err != nil || !fi.IsDir() {
+		t.Errorf("expected the empty directory .git/refs/tags to be kept, got %v", err)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if fi, err := os.Stat(filepath.Join(dir, ".git", "hooks", "pre-push")); err != nil || fi.Mode().Perm() != 0755 {
+		t.Errorf("expected .git/hooks/pre-push to keep its mode, got %v, %v", fi.Mode(), err)
+	}
+	for name, target := range testSourceLinks {
+		if link, err := os.Readlink(filepath.Join(dir, name)); err != nil || link != target {
+			t.Errorf("expected %s to link to %s, got %q, %v", name, target, link, err)
+		}
+	}
+}
+
+func TestPackSource(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "dep-compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	const url = "https://github.com/foo/bar"
+	dir := sourceCachePath(cachedir, url)
+	writeTestSourceTree(t, dir)
+
+	if err := packSource(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the source directory to be removed once packed, got %v", err)
+	}
+	if !sourceIsCached(cachedir, url) {
+		t.Error("expected a packed source to count as cached")
+	}
+
+	path, err := unpackedSourceCachePath(cachedir, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != dir {
+		t.Errorf("expected the source to be unpacked to %s, got %s", dir, path)
+	}
+	if _, err := os.Stat(dir + packedSourceExt); !os.IsNotExist(err) {
+		t.Errorf("expected the archive to be removed once unpacked, got %v", err)
+	}
+	checkTestSourceTree(t, dir)
+
+	// An unpacked source is left as it is.
+	if err := unpackSource(dir); err != nil {
+		t.Fatal(err)
+	}
+	checkTestSourceTree(t, dir)
+}
+
+func TestUnpackSourceInterrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Packing that was interrupted before the directory was removed leaves
+	// both; the directory wins.
+	src := filepath.Join(dir, "both")
+	writeTestSourceTree(t, src)
+	if err := ioutil.WriteFile(src+packedSourceExt, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unpackSource(src); err != nil {
+		t.Fatal(err)
+	}
+	checkTestSourceTree(t, src)
+	if _, err := os.Stat(src + packedSourceExt); !os.IsNotExist(err) {
+		t.Errorf("expected the stale archive to be removed, got %v", err)
+	}
+
+	// A corrupt archive is kept, rather than losing what it holds.
+	corrupt := filepath.Join(dir, "corrupt")
+	if err := ioutil.WriteFile(corrupt+packedSourceExt, []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unpackSource(corrupt); err == nil {
+		t.Error("expected an error unpacking a corrupt archive")
+	}
+	if _, err := os.Stat(corrupt + packedSourceExt); err != nil {
+		t.Errorf("expected the corrupt archive to be kept, got %v", err)
+	}
+	for _, p := range []string{corrupt, corrupt + ".unpack"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to exist, got %v", p, err)
+		}
+	}
+}
+
+func TestCompressCache(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "dep-compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir:      cachedir,
+		CacheAge:      time.Hour,
+		CompressCache: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const url = "https://github.com/foo/bar"
+	dir := sourceCachePath(cachedir, url)
+	writeTestSourceTree(t, dir)
+	sm.Release()
+
+	db := filepath.Join(cachedir, boltCacheFilename)
+	for _, p := range []string{dir, db} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be compressed, got %v", p, err)
+		}
+	}
+	for _, p := range []string{dir + packedSourceExt, db + packedMetadataExt} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s to exist: %v", p, err)
+		}
+	}
+
+	// The cache is read as usual without compression.
+	sm, err = NewSourceManager(SourceManagerConfig{
+		Cachedir: cachedir,
+		CacheAge: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+	if _, err := os.Stat(db); err != nil {
+		t.Errorf("expected the metadata database to be decompressed when opened: %v", err)
+	}
+	if _, err := unpackedSourceCachePath(cachedir, url); err != nil {
+		t.Fatal(err)
+	}
+	checkTestSourceTree(t, dir)
+}
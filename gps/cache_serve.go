@@ -0,0 +1,62 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// CachedSource describes the repository in which a source is kept in the
+// cache.
+type CachedSource struct {
+	// Dir is the directory holding the repository.
+	Dir string
+	// Type is the version control system of the repository, such as "git".
+	Type string
+	// URL is the upstream URL the repository was retrieved from.
+	URL string
+}
+
+// CachedSourceFor retrieves the source of the project identified by id into
+// the cache, if it is not there already, and describes the repository it is
+// kept in, so that the repository can be read directly, such as to serve it to
+// other machines. If update is true, the repository is first brought up to
+// date with its upstream.
+//
+// The repository must only be read while sm is held, as releasing sm may pack
+// it again; see SourceManagerConfig.CompressCache.
+func (sm *SourceMgr) CachedSourceFor(ctx context.Context, id ProjectIdentifier, update bool) (CachedSource, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return CachedSource{}, ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
+	if err != nil {
+		return CachedSource{}, err
+	}
+	return srcg.cachedSource(ctx, update)
+}
+
+func (sg *sourceGateway) cachedSource(ctx context.Context, update bool) (CachedSource, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	wanted := sourceExistsLocally
+	if update {
+		wanted |= sourceHasLatestLocally
+	}
+	if err := sg.require(ctx, wanted); err != nil {
+		return CachedSource{}, err
+	}
+
+	cs := CachedSource{Dir: sourceLocalPath(sg.src), Type: sg.src.sourceType(), URL: sg.src.upstreamURL()}
+	if cs.Dir == "" {
+		return CachedSource{}, errors.Errorf("the source %s is not kept in the cache", cs.URL)
+	}
+	return cs, nil
+}
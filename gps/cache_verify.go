@@ -0,0 +1,341 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// cacheQuarantineDir is the directory of the cache into which corrupted
+// entries are moved.
+const cacheQuarantineDir = "quarantine"
+
+// CacheReport is the result of checking the integrity of a cache.
+type CacheReport struct {
+	// Sources is the number of cached sources that were checked.
+	Sources int
+	// Problems holds the corrupted entries that were found.
+	Problems []CacheProblem
+}
+
+// CacheProblem describes a corrupted entry in the cache, which has been moved
+// into quarantine.
+type CacheProblem struct {
+	// Entry is the path of the entry, relative to the cache directory, such as
+	// "sources/https---github.com-foo-bar".
+	Entry string
+	// Err describes the corruption.
+	Err error
+	// Quarantine is the path, relative to the cache directory, to which the
+	// entry was moved so that it can be inspected.
+	Quarantine string
+}
+
+// VerifyCache checks the integrity of the cache: the structure of the
+// metadata database, and the repository of each cached source, using the
+// checks of its version control system, such as git fsck. It must be called
+// before sm is otherwise used.
+//
+// Corrupted entries are moved into quarantine, so that sources are retrieved
+// afresh when next needed, and the database is replaced by an empty one,
+// rather than the corruption surfacing later as failures to solve. The
+// returned error is only for failures to check or to quarantine.
+func (sm *SourceMgr) VerifyCache(ctx context.Context) (CacheReport, error) {
+	var report CacheReport
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return report, ErrSourceManagerIsReleased
+	}
+	sc := sm.srcCoord
+
+	var problems []CacheProblem
+	if err := sc.verifyMetadata(); err != nil {
+		problems = append(problems, CacheProblem{Entry: boltCacheFilename, Err: err})
+	}
+
+	sources := filepath.Join(sc.cachedir, "sources")
+	infos, err := ioutil.ReadDir(sources)
+	if err != nil {
+		return report, errors.Wrap(err, "failed to list the cached sources")
+	}
+	for _, fi := range infos {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		name := fi.Name()
+		if strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".unpack") {
+			continue
+		}
+		path := filepath.Join(sources, name)
+		var err error
+		switch {
+		case fi.IsDir():
+			var checked bool
+			checked, err = verifySourceRepo(ctx, path)
+			if !checked {
+				continue
+			}
+		case strings.HasSuffix(name, packedSourceExt):
+			err = verifyPackedSource(path)
+		default:
+			continue
+		}
+		report.Sources++
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return report, err
+		}
+		if err != nil {
+			problems = append(problems, CacheProblem{Entry: filepath.Join("sources", name), Err: err})
+		}
+	}
+
+	for _, p := range problems {
+		if p.Entry == boltCacheFilename {
+			p.Quarantine, err = sc.quarantineMetadata()
+		} else {
+			p.Quarantine, err = quarantineCacheEntry(sc.cachedir, p.Entry)
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Problems = append(report.Problems, p)
+	}
+	return report, nil
+}
+
+// QuarantineSource moves the cached repository of the source of the project
+// identified by id into quarantine, then retrieves it afresh, as when it has
+// been found to be corrupted by means other than VerifyCache, such as the
+// trees exported from it not matching the digests recorded for them. It
+// returns the path, relative to the cache directory, to which the repository
+// was moved.
+func (sm *SourceMgr) QuarantineSource(ctx context.Context, id ProjectIdentifier) (string, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return "", ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return srcg.quarantine(ctx)
+}
+
+func (sg *sourceGateway) quarantine(ctx context.Context) (string, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	path := sourceLocalPath(sg.src)
+	if path == "" {
+		return "", errors.Errorf("the source %s is not kept in the cache", sg.src.upstreamURL())
+	}
+	rel, err := filepath.Rel(sg.cachedir, path)
+	if err != nil {
+		return "", err
+	}
+	q, err := quarantineCacheEntry(sg.cachedir, rel)
+	if err != nil {
+		return "", err
+	}
+
+	sg.srcState &^= sourceExistsLocally | sourceHasLatestLocally
+	return q, sg.require(ctx, sourceExistsLocally)
+}
+
+// sourceLocalPath returns the directory in which src is kept in the cache.
+func sourceLocalPath(src source) string {
+	switch s := src.(type) {
+	case *gitSource:
+		return s.repo.LocalPath()
+	case *gopkginSource:
+		return s.repo.LocalPath()
+	case *hgSource:
+		return s.repo.LocalPath()
+	case *bzrSource:
+		return s.repo.LocalPath()
+	case *bundleSource:
+		return s.dest
+	}
+	return ""
+}
+
+// verifySourceRepo checks the repository in dir with its version control
+// system, reporting whether it is a repository that could be checked.
+// Interrupted resumable clones are not checked, as they are incomplete.
+func verifySourceRepo(ctx context.Context, dir string) (bool, error) {
+	var args []string
+	switch {
+	case isFile(filepath.Join(dir, ".git", resumeStateFile)):
+		return false, nil
+	case isDir(filepath.Join(dir, ".git")):
+		args = []string{"git", "fsck", "--no-dangling", "--no-progress"}
+	case isDir(filepath.Join(dir, ".hg")):
+		args = []string{"hg", "verify", "--quiet"}
+	case isDir(filepath.Join(dir, ".bzr")):
+		args = []string{"bzr", "check"}
+	default:
+		return false, nil
+	}
+
+	cmd := commandContext(ctx, args[0], args[1:]...)
+	cmd.SetDir(dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return true, ctx.Err()
+		}
+		return true, errors.Errorf("%s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+// verifyPackedSource checks that the packed source at path can be read in
+// full.
+func verifyPackedSource(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gzr)
+	for {
+		if _, err := tr.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// boltCacheOf returns the persistent cache of sc, or nil if it has none.
+func (sc *sourceCoordinator) boltCacheOf() *boltCache {
+	if mc, ok := sc.cache.(*multiCache); ok {
+		bc, _ := mc.disk.(*boltCache)
+		return bc
+	}
+	return nil
+}
+
+// verifyMetadata checks the structure of the metadata database, whether or
+// not sc has it open.
+func (sc *sourceCoordinator) verifyMetadata() error {
+	path := filepath.Join(sc.cachedir, boltCacheFilename)
+	if isFile(path + packedMetadataExt) {
+		return verifyPackedFile(path + packedMetadataExt)
+	}
+
+	var db *bolt.DB
+	if bc := sc.boltCacheOf(); bc != nil {
+		db = bc.db
+	} else {
+		if !isFile(path) {
+			return nil
+		}
+		var err error
+		db, err = bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+		if err == bolt.ErrTimeout {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	return db.View(func(tx *bolt.Tx) error {
+		var errs []string
+		for err := range tx.Check() {
+			errs = append(errs, err.Error())
+		}
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
+		return nil
+	})
+}
+
+// verifyPackedFile checks that the gzipped file at path can be read in full.
+func verifyPackedFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(ioutil.Discard, gzr)
+	return err
+}
+
+// quarantineMetadata moves the metadata database into quarantine. If sc has
+// it open, it is replaced by an empty one.
+func (sc *sourceCoordinator) quarantineMetadata() (string, error) {
+	entry := boltCacheFilename
+	if isFile(filepath.Join(sc.cachedir, entry+packedMetadataExt)) {
+		entry += packedMetadataExt
+	}
+
+	bc := sc.boltCacheOf()
+	if bc != nil {
+		if err := bc.db.Close(); err != nil {
+			return "", errors.Wrap(err, "failed to close the corrupted metadata database")
+		}
+	}
+	q, err := quarantineCacheEntry(sc.cachedir, entry)
+	if err != nil {
+		return "", err
+	}
+	if bc != nil {
+		fresh, err := newBoltCache(sc.cachedir, bc.epoch, bc.logger)
+		if err != nil {
+			return q, err
+		}
+		bc.db = fresh.db
+	}
+	return q, nil
+}
+
+// quarantineCacheEntry moves the entry at the path rel, relative to cachedir,
+// into its quarantine directory, under a name stamped with the time. It
+// returns the path it was moved to, relative to cachedir.
+func quarantineCacheEntry(cachedir, rel string) (string, error) {
+	if err := os.MkdirAll(filepath.Join(cachedir, cacheQuarantineDir), 0777); err != nil {
+		return "", errors.Wrap(err, "failed to create the quarantine directory")
+	}
+	q := filepath.Join(cacheQuarantineDir, filepath.Base(rel)+"."+time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(filepath.Join(cachedir, rel), filepath.Join(cachedir, q)); err != nil {
+		return "", errors.Wrapf(err, "failed to quarantine %s", rel)
+	}
+	return q, nil
+}
+
+func isDir(path string) bool {
+	is, _ := fs.IsDir(path)
+	return is
+}
+
+func isFile(path string) bool {
+	is, _ := fs.IsRegular(path)
+	return is
+}
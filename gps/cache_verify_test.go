@@ -0,0 +1,132 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// initTestGitRepo creates a git repository in dir with a single commit,
+// returning the path of the object holding the committed file.
+func initTestGitRepo(t *testing.T, dir string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "main.go"},
+		{"-c", "user.name=dep", "-c", "user.email=dep@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	cmd := exec.Command("git", "hash-object", "main.go")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.TrimSpace(string(out))
+	return filepath.Join(dir, ".git", "objects", hash[:2], hash[2:])
+}
+
+func TestVerifyCache(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	cachedir, err := ioutil.TempDir("", "dep-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	sources := filepath.Join(cachedir, "sources")
+	initTestGitRepo(t, filepath.Join(sources, "https---github.com-foo-good"))
+	obj := initTestGitRepo(t, filepath.Join(sources, "https---github.com-foo-bad"))
+	os.Chmod(obj, 0644)
+	if err := ioutil.WriteFile(obj, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sources, "https---github.com-foo-packed"+packedSourceExt), []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Directories that are not repositories, such as extracted bundles, are
+	// not checked.
+	if err := os.MkdirAll(filepath.Join(sources, "bundle"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cachedir, boltCacheFilename), []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := NewSourceManager(SourceManagerConfig{Cachedir: cachedir, CacheAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+
+	report, err := sm.VerifyCache(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sources != 3 {
+		t.Errorf("expected 3 sources to be checked, got %d", report.Sources)
+	}
+
+	problems := make(map[string]CacheProblem)
+	for _, p := range report.Problems {
+		problems[p.Entry] = p
+	}
+	for _, entry := range []string{
+		boltCacheFilename,
+		filepath.Join("sources", "https---github.com-foo-bad"),
+		filepath.Join("sources", "https---github.com-foo-packed"+packedSourceExt),
+	} {
+		p, has := problems[entry]
+		if !has {
+			t.Errorf("expected %s to be found corrupted, got %v", entry, report.Problems)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cachedir, entry)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be moved out of the way, got %v", entry, err)
+		}
+		if !strings.HasPrefix(p.Quarantine, cacheQuarantineDir+string(filepath.Separator)) {
+			t.Errorf("expected %s to be quarantined, got %q", entry, p.Quarantine)
+		} else if _, err := os.Stat(filepath.Join(cachedir, p.Quarantine)); err != nil {
+			t.Errorf("expected %s to be kept in quarantine: %v", entry, err)
+		}
+	}
+	if len(report.Problems) != 3 {
+		t.Errorf("expected 3 problems, got %v", report.Problems)
+	}
+
+	if _, err := os.Stat(filepath.Join(sources, "https---github.com-foo-good")); err != nil {
+		t.Errorf("expected the sound repository to be kept: %v", err)
+	}
+
+	// Once quarantined, the cache is sound.
+	report, err = sm.VerifyCache(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 0 {
+		t.Errorf("expected no problems once quarantined, got %v", report.Problems)
+	}
+}
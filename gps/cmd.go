@@ -5,7 +5,10 @@
 package gps
 
 import (
+	"io"
 	"os"
+	"os/exec"
+	"syscall"
 )
 
 func (c cmd) Args() []string {
@@ -17,7 +20,23 @@ func (c cmd) SetDir(dir string) {
 }
 
 func (c cmd) SetEnv(env []string) {
-	c.Cmd.Env = env
+	// Later values take precedence, so the proxy and credentials are kept.
+	c.Cmd.Env = append(env, c.upstreamEnv...)
+}
+
+func (c cmd) SetStdin(r io.Reader) {
+	c.Cmd.Stdin = r
+}
+
+// exitStatus reports the exit status of the process behind err, if err is
+// an *exec.ExitError, and -1 otherwise.
+func exitStatus(err error) int {
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
+			return ws.ExitStatus()
+		}
+	}
+	return -1
 }
 
 func init() {
@@ -21,6 +21,8 @@ type cmd struct {
 	// ctx is provided by the caller; SIGINT is sent when it is cancelled.
 	ctx context.Context
 	Cmd *exec.Cmd
+	// upstreamEnv carries the proxy and credentials of the command's call.
+	upstreamEnv []string
 }
 
 func commandContext(ctx context.Context, name string, arg ...string) cmd {
@@ -36,7 +38,11 @@ func commandContext(ctx context.Context, name string, arg ...string) cmd {
 		Pgid:    0,
 	}
 
-	return cmd{ctx: ctx, Cmd: c}
+	pe := upstreamEnv(ctx)
+	if len(pe) > 0 {
+		c.Env = append(os.Environ(), pe...)
+	}
+	return cmd{ctx: ctx, Cmd: c, upstreamEnv: pe}
 }
 
 // CombinedOutput is like (*os/exec.Cmd).CombinedOutput except that it
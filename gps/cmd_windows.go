@@ -6,13 +6,21 @@ package gps
 
 import (
 	"context"
+	"os"
 	"os/exec"
 )
 
 type cmd struct {
 	*exec.Cmd
+	// upstreamEnv carries the proxy and credentials of the command's call.
+	upstreamEnv []string
 }
 
 func commandContext(ctx context.Context, name string, arg ...string) cmd {
-	return cmd{Cmd: exec.CommandContext(ctx, name, arg...)}
+	c := exec.CommandContext(ctx, name, arg...)
+	pe := upstreamEnv(ctx)
+	if len(pe) > 0 {
+		c.Env = append(os.Environ(), pe...)
+	}
+	return cmd{Cmd: c, upstreamEnv: pe}
 }
@@ -0,0 +1,132 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+// The reasons for which a Decision records that a version was selected.
+const (
+	// DecisionLocked is for a version kept from the root lock.
+	DecisionLocked = "locked"
+	// DecisionPreferred is for a version preferred by the lock of a project
+	// that depends on it.
+	DecisionPreferred = "preferred"
+	// DecisionNewestAllowed is for the newest version, in the order in which
+	// the update strategy tries them, that nothing ruled out.
+	DecisionNewestAllowed = "newest-allowed"
+	// DecisionTightestConstraint is for the newest version allowed by the
+	// constraints on the project, where its tightest constraint ruled out
+	// newer ones.
+	DecisionTightestConstraint = "tightest-constraint"
+	// DecisionNewestCompatible is for the newest version allowed by the
+	// constraints on the project that was also compatible with the rest of
+	// the solution, where newer versions were allowed, but conflicted.
+	DecisionNewestCompatible = "newest-compatible"
+)
+
+// A Decision records why the solver selected the version of a project that
+// it did, so that it can be explained without solving again.
+type Decision struct {
+	// Reason is one of the Decision constants.
+	Reason string
+	// Constraint is the tightest of the constraints on the project, the one
+	// that allows the fewest of its versions, and By is the project whose
+	// manifest declared it; for the root's overrides, that is the root. Both
+	// are empty if no constraint ruled out any of the project's versions.
+	Constraint string
+	By         ProjectRoot
+}
+
+// recordDecisions records, for each selected project, why its version was
+// selected, as the solve has succeeded.
+func (s *solver) recordDecisions() {
+	s.decisions = make(map[ProjectRoot]Decision)
+	queues := make(map[ProjectRoot]*versionQueue, len(s.vqs))
+	for _, q := range s.vqs {
+		queues[q.id.ProjectRoot] = q
+	}
+
+	for _, sel := range s.sel.projects[1:] {
+		if !sel.first {
+			continue
+		}
+		a := sel.a.a
+		s.decisions[a.id.ProjectRoot] = s.decide(a, queues[a.id.ProjectRoot])
+	}
+}
+
+// decide works out why a was selected from q, the queue of versions it was
+// selected from.
+func (s *solver) decide(a atom, q *versionQueue) Decision {
+	deps := s.sel.getDependenciesOn(a.id)
+	var d Decision
+	switch {
+	case q != nil && q.lockv != nil && q.lockv.identical(a.v):
+		d.Reason = DecisionLocked
+	case q != nil && q.prefv != nil && q.prefv.identical(a.v):
+		d.Reason = DecisionPreferred
+	}
+
+	vl, err := s.b.listVersions(a.id)
+	if err != nil {
+		vl = nil
+	}
+
+	// The tightest constraint is the one allowing the fewest versions.
+	fewest := -1
+	for _, dep := range deps {
+		c := dep.dep.Constraint
+		if IsAny(c) {
+			continue
+		}
+		var n int
+		for _, v := range vl {
+			if c.Matches(v) {
+				n++
+			}
+		}
+		if len(vl) > 0 && n == len(vl) {
+			// It rules out none of the versions.
+			continue
+		}
+		if fewest == -1 || n < fewest {
+			fewest = n
+			d.Constraint = c.String()
+			d.By = dep.depender.id.ProjectRoot
+			if dep.dep.overrConstraint {
+				d.By = ProjectRoot(s.rd.rpt.ImportRoot)
+			}
+		}
+	}
+	if d.Reason != "" {
+		return d
+	}
+
+	// Find the newest version that the constraints on the project, and its
+	// exclusions, allow, noting whether any of them ruled out a newer one.
+	c := s.sel.getConstraint(a.id)
+	ruledOut := false
+	for _, v := range vl {
+		if !c.Matches(v) || s.checkNotExcluded(atom{id: a.id, v: v}) != nil {
+			ruledOut = true
+			continue
+		}
+		switch {
+		case !v.identical(a.v):
+			d.Reason = DecisionNewestCompatible
+		case ruledOut && d.Constraint != "":
+			d.Reason = DecisionTightestConstraint
+		default:
+			d.Reason = DecisionNewestAllowed
+		}
+		return d
+	}
+
+	// The version was not listed, as for a revision named by a constraint.
+	if d.Constraint != "" {
+		d.Reason = DecisionTightestConstraint
+	} else {
+		d.Reason = DecisionNewestAllowed
+	}
+	return d
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordDecisions(t *testing.T) {
+	fix := basicFixture{
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ^1.0.0", "bar *", "baz *", "qux *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("foo 2.0.0"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.1.0"),
+			mkDepspec("baz 1.0.0"),
+			mkDepspec("baz 1.1.0"),
+			mkDepspec("qux 1.0.0"),
+			mkDepspec("qux 1.1.0", "foo ^2.0.0"),
+		},
+		l: mklock("baz 1.0.0"),
+	}
+	want := map[ProjectRoot]Decision{
+		"foo": {Reason: DecisionTightestConstraint, Constraint: "^1.0.0", By: "root"},
+		"bar": {Reason: DecisionNewestAllowed},
+		"baz": {Reason: DecisionLocked},
+		"qux": {Reason: DecisionNewestCompatible},
+	}
+
+	for _, parallel := range []bool{false, true} {
+		params := SolveParameters{
+			RootDir:         string(fix.ds[0].n),
+			RootPackageTree: fix.rootTree(),
+			Manifest:        fix.rootmanifest(),
+			Lock:            fix.l,
+			Parallel:        parallel,
+			RecordDecisions: true,
+			ProjectAnalyzer: naiveAnalyzer{},
+		}
+		res, err := fixSolve(params, newdepspecSM(fix.ds, nil), t)
+		if err != nil {
+			t.Fatalf("parallel=%v: %v", parallel, err)
+		}
+		if got := res.Decisions(); !reflect.DeepEqual(got, want) {
+			t.Errorf("parallel=%v: expected decisions\n\t%v\ngot\n\t%v", parallel, want, got)
+		}
+	}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+	}
+	res, err := fixSolve(params, newdepspecSM(fix.ds, nil), t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Decisions() != nil {
+		t.Errorf("expected no decisions unless asked for, got %v", res.Decisions())
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/armon/go-radix"
 	"github.com/pkg/errors"
@@ -555,10 +556,11 @@ type deducer interface {
 }
 
 type deductionCoordinator struct {
-	suprvsr  *supervisor
-	mut      sync.RWMutex
-	rootxt   *radix.Tree
-	deducext *deducerTrie
+	suprvsr    *supervisor
+	mut        sync.RWMutex
+	rootxt     *radix.Tree
+	deducext   *deducerTrie
+	namespaces Namespaces
 }
 
 func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
@@ -573,12 +575,59 @@ func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
 
 // deduceRootPath takes an import path and attempts to deduce various
 // metadata about it - what type of source should handle it, and where its
-// "root" is (for vcs repositories, the repository root).
+// "root" is (for vcs repositories, the repository root, or a major version
+// root within it).
 //
 // If no errors are encountered, the returned pathDeduction will contain both
 // the root path and a list of maybeSources, which can be subsequently used to
 // create a handler that will manage the particular source.
 func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string) (pathDeduction, error) {
+	start := time.Now()
+	pd, err := dc.deduceRepoRootPath(ctx, path)
+	if err != nil {
+		sendStepEvent(Event{Kind: EventPathDeduced}, start, err)
+		return pd, err
+	}
+
+	// Under semantic import versioning, the import path of each major version
+	// of a project after the first ends in a /vN element, which does not name
+	// a directory of the repository. Such a path is the root of a project of
+	// its own, held in the same source, but restricted to the tags of that
+	// major version by the solver.
+	if root := majorVersionRoot(pd.root, path); root != pd.root {
+		pd.root = root
+		dc.mut.Lock()
+		dc.rootxt.Insert(pd.root, pd.mb)
+		dc.mut.Unlock()
+	}
+	sendStepEvent(Event{Kind: EventPathDeduced, ProjectRoot: ProjectRoot(pd.root)}, start, nil)
+	return pd, nil
+}
+
+// majorVersionRoot returns the root of the major version of the project at the
+// repository root that path is in, which is root itself unless path continues
+// from root with a major version element, as in github.com/foo/bar/v2/baz.
+// gopkg.in roots are left as they are, as they already name a major version.
+func majorVersionRoot(root, path string) string {
+	if strings.HasPrefix(root, "gopkg.in/") || !strings.HasPrefix(path, root+"/") {
+		return root
+	}
+	if _, _, ok := SplitMajorVersion(ProjectRoot(root)); ok {
+		return root
+	}
+	elem := path[len(root)+1:]
+	if i := strings.Index(elem, "/"); i != -1 {
+		elem = elem[:i]
+	}
+	if _, _, ok := SplitMajorVersion(ProjectRoot(root + "/" + elem)); ok {
+		return root + "/" + elem
+	}
+	return root
+}
+
+// deduceRepoRootPath implements deduceRootPath, deducing the root of the
+// repository that path is in.
+func (dc *deductionCoordinator) deduceRepoRootPath(ctx context.Context, path string) (pathDeduction, error) {
 	if err := dc.suprvsr.ctx.Err(); err != nil {
 		return pathDeduction{}, err
 	}
@@ -663,7 +712,13 @@ func (dc *deductionCoordinator) deduceKnownPaths(path string) (pathDeduction, er
 		return pathDeduction{}, err
 	}
 
-	// First, try the root path-based matches
+	// Namespaces configured for private import paths come first, so that
+	// they can cover hosts that would otherwise be deduced.
+	if pd, ok, err := dc.namespaces.deduce(path); ok {
+		return pd, err
+	}
+
+	// Next, try the root path-based matches
 	if _, mtch, has := dc.deducext.LongestPrefix(path); has {
 		root, err := mtch.deduceRoot(path)
 		if err != nil {
@@ -718,6 +773,15 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 
 		pd := pathDeduction{}
 
+		host := u.Host
+		if host == "" {
+			host = strings.SplitN(path, "/", 2)[0]
+		}
+		if err := hmd.suprvsr.network.check(host, opath, ""); err != nil {
+			hmd.deduceErr = err
+			return
+		}
+
 		// Make the HTTP call to attempt to retrieve go-get metadata
 		var root, vcs, reporoot string
 		err = hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
@@ -848,7 +912,7 @@ func doFetchMetadata(ctx context.Context, scheme, path string) (io.ReadCloser, e
 			return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", url)
 		}
 
-		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		resp, err := httpClient(ctx).Do(req.WithContext(ctx))
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed HTTP request to URL %q", url)
 		}
@@ -679,3 +679,37 @@ func TestVanityDeductionSchemeMismatch(t *testing.T) {
 		t.Error("should have errored on scheme mismatch between input and go-get metadata")
 	}
 }
+
+func TestMajorVersionDeduction(t *testing.T) {
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	// Deduce the root of the first major version first, so that the roots
+	// of the later ones are deduced past a cached prefix.
+	for _, fix := range []struct {
+		in, root string
+	}{
+		{"github.com/sdboyer/deptest/foo", "github.com/sdboyer/deptest"},
+		{"github.com/sdboyer/deptest/v2", "github.com/sdboyer/deptest/v2"},
+		{"github.com/sdboyer/deptest/v2/foo", "github.com/sdboyer/deptest/v2"},
+		{"github.com/sdboyer/deptest/v10/foo/v3", "github.com/sdboyer/deptest/v10"},
+		{"github.com/sdboyer/deptest/v1/foo", "github.com/sdboyer/deptest"},
+		{"github.com/sdboyer/deptest/v02", "github.com/sdboyer/deptest"},
+		{"gopkg.in/yaml.v2/v3", "gopkg.in/yaml.v2"},
+	} {
+		pr, err := sm.DeduceProjectRoot(fix.in)
+		if err != nil {
+			t.Errorf("Unexpected err on deducing project root of %s: %s", fix.in, err)
+		} else if string(pr) != fix.root {
+			t.Errorf("Deducer did not return expected root for %s:\n\t(GOT) %s\n\t(WNT) %s", fix.in, pr, fix.root)
+		}
+	}
+
+	pd, err := sm.deduceCoord.deduceRootPath(context.Background(), "github.com/sdboyer/deptest/v2/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := pd.mb[0].(maybeGitSource).url; u.Host != "github.com" || u.Path != "/sdboyer/deptest" {
+		t.Errorf("expected a major version to be held in the repository of the first, got %s", u)
+	}
+}
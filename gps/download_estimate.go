@@ -0,0 +1,175 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// DownloadEstimate is an estimate of how much data retrieving the source of a
+// project into the cache downloads.
+type DownloadEstimate struct {
+	// URL is the source the estimate is for.
+	URL string
+	// Cached is set if the source is already in the cache, so that retrieving
+	// it only fetches what changed since it was cached.
+	Cached bool
+	// Bytes is the estimated size of the source, or -1 if there is no way of
+	// estimating it. It is zero for a cached source.
+	Bytes int64
+}
+
+// sizeAPI describes a hosting service's API reporting the size of a
+// repository, for a host whose repositories are named by their first two path
+// elements.
+type sizeAPI struct {
+	// host is the host of the API.
+	host string
+	// url returns the API URL describing the repository owner/repo.
+	url func(owner, repo string) string
+	// size extracts the size of the repository, in bytes, from the decoded
+	// description, reporting whether it was present.
+	size func(desc map[string]interface{}) (int64, bool)
+}
+
+// sizeAPIs holds the APIs reporting the sizes of repositories, by the host of
+// the repositories.
+var sizeAPIs = map[string]sizeAPI{
+	"github.com": {
+		host: "api.github.com",
+		url: func(owner, repo string) string {
+			return "https://api.github.com/repos/" + owner + "/" + repo
+		},
+		// GitHub reports sizes in kilobytes.
+		size: func(desc map[string]interface{}) (int64, bool) {
+			kb, ok := desc["size"].(float64)
+			return int64(kb) * 1024, ok
+		},
+	},
+	"bitbucket.org": {
+		host: "api.bitbucket.org",
+		url: func(owner, repo string) string {
+			return "https://api.bitbucket.org/2.0/repositories/" + owner + "/" + repo
+		},
+		size: func(desc map[string]interface{}) (int64, bool) {
+			b, ok := desc["size"].(float64)
+			return int64(b), ok
+		},
+	},
+	"gitlab.com": {
+		host: "gitlab.com",
+		url: func(owner, repo string) string {
+			return "https://gitlab.com/api/v4/projects/" + url.PathEscape(owner+"/"+repo) + "?statistics=true"
+		},
+		// Statistics are only reported to authorized users.
+		size: func(desc map[string]interface{}) (int64, bool) {
+			stats, _ := desc["statistics"].(map[string]interface{})
+			b, ok := stats["repository_size"].(float64)
+			return int64(b), ok
+		},
+	},
+}
+
+// EstimateDownload estimates how much data retrieving the source of the
+// project identified by id into a cold cache downloads, without retrieving
+// it. Nothing is downloaded for a source that is already cached.
+//
+// Sizes are estimated from the APIs of the hosting services that report them,
+// which count the whole repository, as a full clone downloads it. The
+// size of a source on any other host is unknown.
+func (sm *SourceMgr) EstimateDownload(ctx context.Context, id ProjectIdentifier) (DownloadEstimate, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return DownloadEstimate{}, ErrSourceManagerIsReleased
+	}
+	return sm.srcCoord.estimateDownload(ctx, id)
+}
+
+func (sc *sourceCoordinator) estimateDownload(ctx context.Context, id ProjectIdentifier) (DownloadEstimate, error) {
+	name, err := sc.templates.sourceFor(id)
+	if err != nil {
+		return DownloadEstimate{}, err
+	}
+	pd, err := sc.deducer.deduceRootPath(ctx, name)
+	if err != nil {
+		return DownloadEstimate{}, err
+	}
+
+	mbs := sc.protocols.apply(string(id.ProjectRoot), pd.mb)
+	for _, m := range mbs {
+		if sourceIsCached(sc.cachedir, m.URL().String()) {
+			return DownloadEstimate{URL: m.URL().String(), Cached: true}, nil
+		}
+	}
+
+	est := DownloadEstimate{Bytes: -1}
+	for _, m := range mbs {
+		up := upstreamURL(m)
+		if up == nil {
+			continue
+		}
+		if est.URL == "" {
+			est.URL = up.String()
+		}
+		api, has := sizeAPIs[strings.ToLower(up.Hostname())]
+		if !has || !sc.supervisor.network.Permits(api.host) {
+			continue
+		}
+		elems := strings.Split(strings.Trim(up.Path, "/"), "/")
+		if len(elems) < 2 {
+			continue
+		}
+		pctx, err := sc.supervisor.withUpstream(ctx, "")
+		if err != nil {
+			return est, err
+		}
+		size, err := fetchRepoSize(pctx, api, elems[0], strings.TrimSuffix(elems[1], ".git"))
+		if err != nil {
+			return est, err
+		}
+		if size >= 0 {
+			est.URL, est.Bytes = up.String(), size
+			break
+		}
+	}
+	return est, nil
+}
+
+// fetchRepoSize asks api for the size of the repository owner/repo, returning
+// -1 if the API does not report it.
+func fetchRepoSize(ctx context.Context, api sizeAPI, owner, repo string) (int64, error) {
+	u := api.url(owner, repo)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed HTTP request to URL %q", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Private repositories, and the limits on unauthenticated use of the
+		// APIs, leave sizes unknown rather than failing the estimate.
+		return -1, nil
+	}
+
+	var desc map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return 0, errors.Wrapf(err, "unable to decode the response from %q", u)
+	}
+	if size, ok := api.size(desc); ok {
+		return size, nil
+	}
+	return -1, nil
+}
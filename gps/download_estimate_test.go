@@ -0,0 +1,63 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestEstimateDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/foo/bar":
+			fmt.Fprint(w, `{"full_name": "foo/bar", "size": 2048}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	orig := sizeAPIs["github.com"]
+	defer func() { sizeAPIs["github.com"] = orig }()
+	api := orig
+	api.url = func(owner, repo string) string { return srv.URL + "/repos/" + owner + "/" + repo }
+	sizeAPIs["github.com"] = api
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+	ctx := context.Background()
+
+	est, err := sm.EstimateDownload(ctx, mkPI("github.com/foo/bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.Cached || est.Bytes != 2048*1024 || est.URL != "https://github.com/foo/bar" {
+		t.Errorf("unexpected estimate for a project of known size: %+v", est)
+	}
+
+	est, err = sm.EstimateDownload(ctx, mkPI("github.com/foo/private"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.Cached || est.Bytes != -1 {
+		t.Errorf("expected the size of a project the API does not describe to be unknown, got %+v", est)
+	}
+
+	if err := os.MkdirAll(sourceCachePath(sm.cachedir, "https://github.com/foo/bar"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	est, err = sm.EstimateDownload(ctx, mkPI("github.com/foo/bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !est.Cached || est.Bytes != 0 {
+		t.Errorf("expected nothing to be downloaded for a cached project, got %+v", est)
+	}
+}
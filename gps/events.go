@@ -0,0 +1,121 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"sync"
+	"time"
+)
+
+// The kinds of Event.
+const (
+	// EventSolveStarted is sent when a Solver starts solving.
+	EventSolveStarted = "solve_started"
+	// EventSolveFinished is sent when a Solver finishes solving, whether or
+	// not it found a solution.
+	EventSolveFinished = "solve_finished"
+	// EventSourceFetched is sent when a source has been cloned into the local
+	// cache, or fetched into it from upstream.
+	EventSourceFetched = "source_fetched"
+	// EventProjectVendored is sent when a project has been written out to a
+	// vendor tree.
+	EventProjectVendored = "project_vendored"
+	// EventVendorWritten is sent when a vendor tree has been written out.
+	EventVendorWritten = "vendor_written"
+	// EventProjectVerified is sent when the vendored code of a project has
+	// been checked against its digest.
+	EventProjectVerified = "project_verified"
+	// EventPathDeduced is sent when the root of an import path, and the
+	// sources that may hold it, have been deduced.
+	EventPathDeduced = "path_deduced"
+	// EventVersionsListed is sent when the versions of a source have been
+	// listed.
+	EventVersionsListed = "versions_listed"
+	// EventTreeHashed is sent when the digest of a tree has been computed.
+	EventTreeHashed = "tree_hashed"
+)
+
+// An Event reports a step taken by dep or gps, for tools that embed them to
+// show progress and timings. The fields set depend on the Kind of the event.
+type Event struct {
+	// Kind is one of the Event constants.
+	Kind string
+	// Time is when the event happened; for an event ending a step, when the
+	// step ended.
+	Time time.Time
+	// Duration is how long the step took, for an event ending a step.
+	Duration time.Duration
+	// Err is the error the step failed with, if any.
+	Err error
+
+	// ProjectRoot and Version identify the project of a project event. For an
+	// EventPathDeduced, ProjectRoot is the deduced root.
+	ProjectRoot ProjectRoot
+	Version     Version
+	// Source is the URL of the source of an EventSourceFetched or
+	// EventVersionsListed.
+	Source string
+	// Clone is true for an EventSourceFetched that cloned the source, rather
+	// than fetching into an existing clone.
+	Clone bool
+	// Status is the result of an EventProjectVerified, as described by
+	// verify.VendorStatus.
+	Status string
+	// Count is the number of projects written for an EventVendorWritten.
+	Count int
+}
+
+// An EventListener receives the Events sent by dep and gps. Events are sent
+// from whichever goroutine takes the step reported, so an implementation must
+// be safe for concurrent use, and should return quickly.
+type EventListener interface {
+	HandleEvent(Event)
+}
+
+// EventListenerFunc adapts an ordinary function into an EventListener.
+type EventListenerFunc func(Event)
+
+// HandleEvent implements EventListener.
+func (f EventListenerFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+var eventSink struct {
+	sync.RWMutex
+	l EventListener
+}
+
+// SetEventListener installs l as the process-wide recipient of Events from
+// gps, its subpackages and dep. Passing nil, the default, discards them.
+func SetEventListener(l EventListener) {
+	eventSink.Lock()
+	eventSink.l = l
+	eventSink.Unlock()
+}
+
+// SendEvent sends e to the EventListener installed with SetEventListener, if
+// any, setting its Time to now if it is unset. It is exported for the use of
+// gps's subpackages and dep.
+func SendEvent(e Event) {
+	eventSink.RLock()
+	l := eventSink.l
+	eventSink.RUnlock()
+
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	l.HandleEvent(e)
+}
+
+// sendStepEvent sends e as ending a step that started at start, and failed
+// with err, if not nil.
+func sendStepEvent(e Event, start time.Time, err error) {
+	now := time.Now()
+	e.Time, e.Duration, e.Err = now, now.Sub(start), err
+	SendEvent(e)
+}
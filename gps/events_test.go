@@ -0,0 +1,81 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+func TestEvents(t *testing.T) {
+	var mu sync.Mutex
+	var events []Event
+	SetEventListener(EventListenerFunc(func(e Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}))
+	defer SetEventListener(nil)
+
+	sm := newTestMemorySourceManager()
+	params := SolveParameters{
+		RootDir:         ".",
+		ProjectAnalyzer: naiveAnalyzer{},
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "example.com/root",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"example.com/root": {
+					P: pkgtree.Package{ImportPath: "example.com/root", Name: "root", Imports: []string{"example.com/dep"}},
+				},
+			},
+		},
+	}
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soln, err := s.Solve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "gps-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteDepTree(dir, soln, sm, CascadingPruneOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+		if e.Time.IsZero() {
+			t.Errorf("expected %s to have a time", e.Kind)
+		}
+	}
+	if want := []string{EventSolveStarted, EventSolveFinished, EventProjectVendored}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("expected events %v, got %v", want, kinds)
+	}
+	if e := events[1]; e.Err != nil || e.Duration < 0 {
+		t.Errorf("expected the solve to finish without error, got %+v", e)
+	}
+	if e := events[2]; e.ProjectRoot != "example.com/dep" || e.Version.String() != "v1.1.0" {
+		t.Errorf("unexpected vendoring event %+v", e)
+	}
+
+	SetEventListener(nil)
+	SendEvent(Event{Kind: EventSolveStarted})
+	if len(events) != 3 {
+		t.Errorf("expected events to be discarded once the listener is unset, got %v", events[3:])
+	}
+}
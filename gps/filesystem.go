@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/golang/dep/gps/vfs"
 	"github.com/pkg/errors"
 )
 
@@ -84,50 +85,65 @@ func (s filesystemState) setup() error {
 // deriveFilesystemState returns a filesystemState based on the state of
 // the filesystem on root.
 func deriveFilesystemState(root string) (filesystemState, error) {
-	fs := filesystemState{root: root}
+	fs, err := deriveFilesystemStateFS(vfs.OS, root)
+	if err != nil {
+		return filesystemState{}, err
+	}
 
-	err := filepath.Walk(fs.root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	for i := range fs.links {
+		l := &fs.links[i]
 
-		if path == fs.root {
-			return nil
+		l.to, err = filepath.EvalSymlinks(filepath.Join(fs.root, l.path))
+		if err != nil && strings.HasSuffix(err.Error(), "too many links") {
+			l.circular = true
+		} else if err != nil && os.IsNotExist(err) {
+			l.broken = true
+		} else if err != nil {
+			return filesystemState{}, err
 		}
+	}
+
+	return fs, nil
+}
 
-		relPath, err := filepath.Rel(fs.root, path)
+// deriveFilesystemStateFS returns a filesystemState based on the state of
+// fsys on root. Symbolic links are recorded, but not resolved, so only the
+// path of each fsLink is set.
+//
+// Nodes are visited in lexical order, as with filepath.Walk.
+func deriveFilesystemStateFS(fsys vfs.FS, root string) (filesystemState, error) {
+	fs := filesystemState{root: root}
+
+	var walk func(relPath string) error
+	walk = func(relPath string) error {
+		fis, err := fsys.ReadDir(filepath.Join(fs.root, relPath))
 		if err != nil {
 			return err
 		}
 
-		if (info.Mode() & os.ModeSymlink) != 0 {
-			l := fsLink{path: relPath}
-
-			l.to, err = filepath.EvalSymlinks(path)
-			if err != nil && strings.HasSuffix(err.Error(), "too many links") {
-				l.circular = true
-			} else if err != nil && os.IsNotExist(err) {
-				l.broken = true
-			} else if err != nil {
-				return err
+		for _, info := range fis {
+			childPath := filepath.Join(relPath, info.Name())
+
+			switch {
+			case (info.Mode() & os.ModeSymlink) != 0:
+				fs.links = append(fs.links, fsLink{path: childPath})
+			case info.IsDir():
+				fs.dirs = append(fs.dirs, childPath)
+				if err := walk(childPath); err != nil {
+					return err
+				}
+			default:
+				fs.files = append(fs.files, childPath)
 			}
-
-			fs.links = append(fs.links, l)
-
-			return nil
 		}
 
-		if info.IsDir() {
-			fs.dirs = append(fs.dirs, relPath)
-
-			return nil
-		}
-
-		fs.files = append(fs.files, relPath)
-
 		return nil
-	})
+	}
 
+	info, err := fsys.Lstat(root)
+	if err == nil && info.IsDir() {
+		err = walk("")
+	}
 	if err != nil {
 		return filesystemState{}, err
 	}
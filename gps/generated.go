@@ -0,0 +1,43 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+// GeneratedFilesManifest is an optional interface for Manifests that declare
+// which of their project's files are generated, such as code with a timestamp
+// in its header. Generated files are left out of the digest of the project's
+// vendored tree, so that regenerating them does not fail its verification.
+type GeneratedFilesManifest interface {
+	Manifest
+	// GeneratedFiles returns the patterns of the generated files, in the
+	// syntax of pkgtree.AssetRuleset.
+	GeneratedFiles() []string
+}
+
+// generatedFilesOf returns the patterns of the generated files declared by m,
+// if any.
+func generatedFilesOf(m Manifest) []string {
+	if gm, ok := m.(GeneratedFilesManifest); ok {
+		return gm.GeneratedFiles()
+	}
+	return nil
+}
+
+// GeneratedFilesOf returns the patterns of the generated files of the project
+// at the given version, as declared by the manifest of that version, if it is
+// a GeneratedFilesManifest, followed by those given for the project by co. The
+// former are those of the project's own Gopkg.toml, read by an, and the
+// latter those of the root project's.
+func GeneratedFilesOf(sm SourceManager, lp LockedProject, an ProjectAnalyzer, co CascadingPruneOptions) ([]string, error) {
+	m, _, err := sm.GetManifestAndLock(lp.Ident(), lp.Version(), an)
+	if err != nil {
+		return nil, err
+	}
+	declared := generatedFilesOf(m)
+	given := co.Generated[lp.Ident().ProjectRoot]
+	if len(declared) == 0 {
+		return given, nil
+	}
+	return append(append([]string(nil), declared...), given...), nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+// GoVersionManifest is an optional interface for Manifests that declare the
+// minimum version of Go required to build their project.
+type GoVersionManifest interface {
+	Manifest
+	// MinGoVersion returns the minimum Go version, in the form returned by
+	// ParseGoVersion, or the empty string if none is declared.
+	MinGoVersion() string
+}
+
+// GoVersionRootManifest is an optional interface for RootManifests that
+// declare a minimum Go version. Versions of dependencies that require a newer
+// Go than the root's minimum are rejected by the solver, as choosing them
+// would make the root project unbuildable with some of the Go versions it
+// claims to support.
+type GoVersionRootManifest interface {
+	RootManifest
+	GoVersionManifest
+	// ProjectGoVersions returns, for individual projects, the Go version to
+	// check their requirements against in place of the root's MinGoVersion.
+	ProjectGoVersions() map[ProjectRoot]string
+}
+
+// ParseGoVersion parses a minimum Go version requirement, such as ">=1.11",
+// "1.11" or "go1.11", returning it in the form "1.11".
+func ParseGoVersion(s string) (string, error) {
+	v := strings.TrimSpace(s)
+	v = strings.TrimSpace(strings.TrimPrefix(v, ">="))
+	v = strings.TrimPrefix(v, "go")
+
+	if _, err := semver.NewVersion(v); err != nil || v == "" || strings.ContainsAny(v, "-+") {
+		return "", errors.Errorf("invalid Go version %q; must be a minimum version, such as \">=1.11\"", s)
+	}
+	return v, nil
+}
+
+// GoVersionExceeds reports whether the Go version need is newer than have.
+// Both must be in the form returned by ParseGoVersion; an empty or invalid
+// version exceeds nothing, and is exceeded by nothing.
+func GoVersionExceeds(need, have string) bool {
+	nv, err := semver.NewVersion(need)
+	if err != nil {
+		return false
+	}
+	hv, err := semver.NewVersion(have)
+	if err != nil {
+		return false
+	}
+	return nv.GreaterThan(hv)
+}
+
+// GoVersionInputs returns the Go versions declared by m that bear on solving,
+// in the form recorded in a lock as its input Go versions: the root's minimum
+// version, such as "1.11", followed by the versions given to individual
+// projects, such as "github.com/user/project@1.12", ordered by project root.
+// It returns nil if m declares none.
+func GoVersionInputs(m RootManifest) []string {
+	gm, ok := m.(GoVersionRootManifest)
+	if !ok {
+		return nil
+	}
+	return goVersionInputs(gm.MinGoVersion(), gm.ProjectGoVersions())
+}
+
+func goVersionInputs(gover string, govers map[ProjectRoot]string) []string {
+	var inputs []string
+	if gover != "" {
+		inputs = append(inputs, gover)
+	}
+	prs := make([]string, 0, len(govers))
+	for pr := range govers {
+		prs = append(prs, string(pr))
+	}
+	sort.Strings(prs)
+	for _, pr := range prs {
+		inputs = append(inputs, pr+"@"+govers[ProjectRoot(pr)])
+	}
+	return inputs
+}
+
+// minGoVersionOf returns the minimum Go version declared by m, if any.
+func minGoVersionOf(m Manifest) string {
+	if gm, ok := m.(GoVersionManifest); ok {
+		return gm.MinGoVersion()
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoVersion(t *testing.T) {
+	for in, want := range map[string]string{
+		">=1.11":   "1.11",
+		">= 1.11":  "1.11",
+		"1.11":     "1.11",
+		"go1.11":   "1.11",
+		"1.11.2":   "1.11.2",
+		"<1.11":    "",
+		"^1.11":    "",
+		"1.11-rc1": "",
+		"":         "",
+	} {
+		got, err := ParseGoVersion(in)
+		if want == "" {
+			if err == nil {
+				t.Errorf("expected an error parsing %q, got %q", in, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("expected %q to parse to %q, got %q (%v)", in, want, got, err)
+		}
+	}
+}
+
+func TestGoVersionExceeds(t *testing.T) {
+	testCases := []struct {
+		need, have string
+		want       bool
+	}{
+		{"1.11", "1.10", true},
+		{"1.10", "1.11", false},
+		{"1.11", "1.11", false},
+		{"1.11.1", "1.11", true},
+		{"", "1.11", false},
+		{"1.11", "", false},
+	}
+
+	for _, tc := range testCases {
+		if got := GoVersionExceeds(tc.need, tc.have); got != tc.want {
+			t.Errorf("GoVersionExceeds(%q, %q) = %v, want %v", tc.need, tc.have, got, tc.want)
+		}
+	}
+}
+
+func TestGoVersionInputs(t *testing.T) {
+	got := goVersionInputs("1.10", map[ProjectRoot]string{
+		"github.com/b/b": "1.12",
+		"github.com/a/a": "1.11",
+	})
+	want := []string{"1.10", "github.com/a/a@1.11", "github.com/b/b@1.12"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("goVersionInputs() = %v, want %v", got, want)
+	}
+	if got := goVersionInputs("", nil); got != nil {
+		t.Errorf("goVersionInputs with no versions = %v, want nil", got)
+	}
+}
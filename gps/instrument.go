@@ -0,0 +1,127 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// The names of the counters reported to Metrics.
+const (
+	// MetricSourceInits counts upstream sources cloned into the local cache.
+	MetricSourceInits = "source_inits"
+	// MetricSourceFetches counts fetches of new data from upstream into
+	// already-cloned sources.
+	MetricSourceFetches = "source_fetches"
+	// MetricSourceFailovers counts sources set up from another of their
+	// candidate URLs, after the first one tried failed or was not allowed.
+	MetricSourceFailovers = "source_failovers"
+	// MetricExports counts code trees written out from the local cache.
+	MetricExports = "exports"
+	// MetricCacheHits and MetricCacheMisses count lookups of version lists,
+	// manifests and locks, and package trees in a source's cache.
+	MetricCacheHits   = "cache_hits"
+	MetricCacheMisses = "cache_misses"
+	// MetricSolveAttempts counts calls to Solver.Solve.
+	MetricSolveAttempts = "solve_attempts"
+	// MetricBacktracks counts the times the solver backtracked after hitting
+	// a failure.
+	MetricBacktracks = "backtracks"
+	// MetricHashComputations counts digests computed from code trees.
+	MetricHashComputations = "hash_computations"
+)
+
+// Metrics receives counts of notable events in the SourceMgr and solver. An
+// implementation must be safe for concurrent use.
+type Metrics interface {
+	// Add adds delta to the counter with the given name.
+	Add(name string, delta int64)
+}
+
+var metricsSink struct {
+	sync.RWMutex
+	m Metrics
+}
+
+// SetMetrics installs m as the process-wide recipient of counts from gps and
+// its subpackages. Passing nil, the default, discards them.
+func SetMetrics(m Metrics) {
+	metricsSink.Lock()
+	metricsSink.m = m
+	metricsSink.Unlock()
+}
+
+// CountMetric adds delta to the named counter of the Metrics installed with
+// SetMetrics, if any. It is exported for the use of gps's subpackages.
+func CountMetric(name string, delta int64) {
+	metricsSink.RLock()
+	m := metricsSink.m
+	metricsSink.RUnlock()
+
+	if m != nil {
+		m.Add(name, delta)
+	}
+}
+
+// countCacheLookup records a hit or miss against a source cache.
+func countCacheLookup(hit bool) {
+	if hit {
+		CountMetric(MetricCacheHits, 1)
+	} else {
+		CountMetric(MetricCacheMisses, 1)
+	}
+}
+
+// ExpvarMetrics is a Metrics that publishes its counters as an expvar.Map.
+//
+// It is also an http.Handler that serves its counters in the Prometheus text
+// exposition format, each prefixed with the name of the map.
+type ExpvarMetrics struct {
+	name string
+	vars *expvar.Map
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics that publishes its counters as the
+// expvar.Map of the given name, creating the map if it has not already been
+// published.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	vars, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		vars = expvar.NewMap(name)
+	}
+	return &ExpvarMetrics{name: name, vars: vars}
+}
+
+// Add implements Metrics.
+func (m *ExpvarMetrics) Add(name string, delta int64) {
+	m.vars.Add(name, delta)
+}
+
+// Get returns the current value of the named counter.
+func (m *ExpvarMetrics) Get(name string) int64 {
+	if v, ok := m.vars.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func (m *ExpvarMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	m.vars.Do(func(kv expvar.KeyValue) {
+		names = append(names, kv.Key)
+	})
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, name := range names {
+		pname := m.name + "_" + name
+		fmt.Fprintf(w, "# TYPE %s counter\n%s %s\n", pname, pname, strconv.FormatInt(m.Get(name), 10))
+	}
+}
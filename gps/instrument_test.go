@@ -0,0 +1,67 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m := NewExpvarMetrics("gpstest")
+	if again := NewExpvarMetrics("gpstest"); again.vars != m.vars {
+		t.Error("expected ExpvarMetrics of the same name to share counters")
+	}
+
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	sm := newTestMemorySourceManager()
+	params := SolveParameters{
+		RootDir:         ".",
+		ProjectAnalyzer: naiveAnalyzer{},
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "example.com/root",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"example.com/root": {
+					P: pkgtree.Package{ImportPath: "example.com/root", Name: "root", Imports: []string{"example.com/dep"}},
+				},
+			},
+		},
+	}
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Solve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Get(MetricSolveAttempts); got != 1 {
+		t.Errorf("expected 1 solve attempt to be counted, got %d", got)
+	}
+
+	CountMetric(MetricCacheHits, 2)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE gpstest_cache_hits counter\ngpstest_cache_hits 2\n",
+		"gpstest_solve_attempts 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected Prometheus output to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	SetMetrics(nil)
+	CountMetric(MetricCacheHits, 1)
+	if got := m.Get(MetricCacheHits); got != 2 {
+		t.Errorf("expected counts to be discarded once metrics are unset, got %d", got)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultLeaseTTL is how long a lease remains valid without being renewed.
+// Leases are renewed several times per TTL, so it only needs to be long enough
+// to tolerate a stalled process and modest clock skew between hosts.
+const defaultLeaseTTL = time.Minute
+
+// A leaseLocker is a locker for cache directories on network filesystems,
+// where neither the fcntl/flock locks nor the PID checks that lockfile relies
+// on can be trusted: the former may be silently unimplemented, and the latter
+// would interpret a PID from another host against the local process table.
+//
+// The lock is a lease file, created exclusively, that records its holder. The
+// holder keeps touching the file's mtime while the lock is held. A lease whose
+// mtime is older than the TTL is considered abandoned, and may be broken by
+// another process. This means a holder that crashes can never wedge other
+// processes indefinitely.
+type leaseLocker struct {
+	path  string
+	token string
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{} // closed to stop lease renewal; nil when not held
+	done chan struct{} // closed when the renewal goroutine exits
+}
+
+var _ locker = &leaseLocker{}
+
+// newLeaseLocker creates a leaseLocker for a lease file at the given absolute
+// path.
+func newLeaseLocker(path string) (*leaseLocker, error) {
+	if !filepath.IsAbs(path) {
+		return nil, errors.Errorf("lease path %q must be absolute", path)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to determine hostname for lease")
+	}
+
+	return &leaseLocker{
+		path:  path,
+		token: fmt.Sprintf("%s %d %d", host, os.Getpid(), time.Now().UnixNano()),
+		ttl:   defaultLeaseTTL,
+	}, nil
+}
+
+// leaseHeldError indicates that a live lease is held by another process. It
+// is temporary; the caller may retry.
+type leaseHeldError struct {
+	path, holder string
+}
+
+func (e leaseHeldError) Error() string {
+	return fmt.Sprintf("lease %s is held by %s", e.path, e.holder)
+}
+
+func (e leaseHeldError) Temporary() bool { return true }
+
+// TryLock attempts to acquire the lease, breaking it first if it has expired.
+func (l *leaseLocker) TryLock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop != nil {
+		return nil
+	}
+
+	// Two attempts: if the first finds an expired lease, it is broken and
+	// creation is retried once.
+	for i := 0; i < 2; i++ {
+		err := l.create()
+		if err == nil {
+			l.stop, l.done = make(chan struct{}), make(chan struct{})
+			go l.renew(l.stop, l.done)
+			return nil
+		}
+		if !os.IsExist(err) {
+			return errors.Wrapf(err, "unable to create lease %s", l.path)
+		}
+
+		fi, err := os.Stat(l.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Released between our attempt and the stat; try again.
+				continue
+			}
+			return errors.Wrapf(err, "unable to stat lease %s", l.path)
+		}
+
+		if time.Since(fi.ModTime()) < l.ttl {
+			return leaseHeldError{path: l.path, holder: l.holder()}
+		}
+		if err := l.breakExpired(fi); err != nil {
+			return err
+		}
+	}
+
+	return leaseHeldError{path: l.path, holder: l.holder()}
+}
+
+// create exclusively creates the lease file and records our token in it.
+func (l *leaseLocker) create() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(l.token)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(l.path)
+		return errors.Wrapf(err, "unable to write lease %s", l.path)
+	}
+
+	// Exclusive creation is not atomic on every network filesystem, so confirm
+	// that the lease we now see is the one we wrote.
+	if !l.owned() {
+		return os.ErrExist
+	}
+	return nil
+}
+
+// breakExpired removes the expired lease described by fi.
+//
+// Removal is done by first renaming the lease aside, which is atomic, so that
+// if several processes race to break the same lease, only one succeeds. If the
+// file that got renamed turns out to be a fresh lease, created after fi was
+// obtained, it is put back.
+func (l *leaseLocker) breakExpired(fi os.FileInfo) error {
+	aside := l.path + ".expired." + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := os.Rename(l.path, aside); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "unable to break expired lease %s", l.path)
+	}
+
+	afi, err := os.Stat(aside)
+	if err == nil && !os.SameFile(fi, afi) {
+		if _, err := os.Stat(l.path); os.IsNotExist(err) {
+			os.Rename(aside, l.path)
+			return nil
+		}
+	}
+
+	os.Remove(aside)
+	return nil
+}
+
+// renew periodically refreshes the lease's mtime until stop is closed.
+func (l *leaseLocker) renew(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(l.ttl / 6)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			now := time.Now()
+			// A failed renewal is retried on the next tick; a single transient
+			// network error shouldn't forfeit the lease.
+			os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// Unlock stops renewing the lease and removes it, provided it is still ours.
+func (l *leaseLocker) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	l.stop, l.done = nil, nil
+
+	if !l.owned() {
+		return errors.Errorf("lease %s was lost before it was released", l.path)
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "unable to remove lease %s", l.path)
+	}
+	return nil
+}
+
+// GetOwner returns the process holding the lease, if it is held by a process
+// on this host. Processes on other hosts can't be represented, so an error is
+// returned for them, as it is when the lease is not held at all.
+func (l *leaseLocker) GetOwner() (*os.Process, error) {
+	host, pid, err := parseLeaseToken(l.holder())
+	if err != nil {
+		return nil, err
+	}
+
+	ourHost, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	if host != ourHost {
+		return nil, errors.Errorf("lease %s is held by a process on %s", l.path, host)
+	}
+	return os.FindProcess(pid)
+}
+
+// holder returns the token recorded in the lease file, or a placeholder if it
+// can't be read.
+func (l *leaseLocker) holder() string {
+	b, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return "an unknown process"
+	}
+	return string(b)
+}
+
+// owned reports whether the lease file currently records our token.
+func (l *leaseLocker) owned() bool {
+	b, err := ioutil.ReadFile(l.path)
+	return err == nil && string(b) == l.token
+}
+
+// parseLeaseToken splits a lease token of the form "<host> <pid> <nonce>".
+func parseLeaseToken(token string) (host string, pid int, err error) {
+	fields := strings.Fields(token)
+	if len(fields) != 3 {
+		return "", 0, errors.Errorf("malformed lease token %q", token)
+	}
+
+	pid, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "malformed lease token %q", token)
+	}
+	return fields[0], pid, nil
+}
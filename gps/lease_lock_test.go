@@ -0,0 +1,173 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestLeaseLocker(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+	lpath := filepath.Join(h.Path("cache"), "sm.lease")
+
+	first, err := newLeaseLocker(lpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newLeaseLocker(lpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = first.TryLock(); err != nil {
+		t.Fatalf("unexpected error acquiring free lease: %s", err)
+	}
+
+	err = second.TryLock()
+	if err == nil {
+		t.Fatal("expected second locker to fail to acquire a held lease")
+	}
+	if te, ok := err.(interface{ Temporary() bool }); !ok || !te.Temporary() {
+		t.Fatalf("expected a temporary error for a held lease, got %T: %s", err, err)
+	}
+
+	owner, err := second.GetOwner()
+	if err != nil {
+		t.Fatalf("unexpected error getting lease owner: %s", err)
+	}
+	if owner.Pid != os.Getpid() {
+		t.Errorf("expected lease to be owned by pid %d, got %d", os.Getpid(), owner.Pid)
+	}
+
+	if err = first.Unlock(); err != nil {
+		t.Fatalf("unexpected error releasing lease: %s", err)
+	}
+	if _, err = os.Stat(lpath); !os.IsNotExist(err) {
+		t.Fatal("expected lease file to be removed on Unlock")
+	}
+
+	if err = second.TryLock(); err != nil {
+		t.Fatalf("unexpected error acquiring released lease: %s", err)
+	}
+	if err = second.Unlock(); err != nil {
+		t.Fatalf("unexpected error releasing lease: %s", err)
+	}
+}
+
+func TestLeaseLockerBreaksExpiredLease(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+	lpath := filepath.Join(h.Path("cache"), "sm.lease")
+
+	// A lease left behind by a crashed process on another host.
+	h.TempFile(filepath.Join("cache", "sm.lease"), "elsewhere 1 1")
+	expired := time.Now().Add(-2 * defaultLeaseTTL)
+	if err := os.Chtimes(lpath, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := newLeaseLocker(lpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = l.GetOwner(); err == nil {
+		t.Error("expected an error getting the owner of a lease held on another host")
+	}
+
+	if err = l.TryLock(); err != nil {
+		t.Fatalf("expected expired lease to be broken, got: %s", err)
+	}
+	if !l.owned() {
+		t.Error("expected lease file to record the new holder")
+	}
+
+	files, err := ioutil.ReadDir(h.Path("cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the new lease to remain after breaking the expired one, got %d files", len(files))
+	}
+
+	if err = l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeaseLockerRenews(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+	lpath := filepath.Join(h.Path("cache"), "sm.lease")
+
+	l, err := newLeaseLocker(lpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.ttl = 60 * time.Millisecond
+
+	if err = l.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+
+	// Without renewal, the lease would expire well before the other locker
+	// tries to acquire it.
+	time.Sleep(4 * l.ttl)
+
+	other, err := newLeaseLocker(lpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.ttl = l.ttl
+	if err = other.TryLock(); err == nil {
+		other.Unlock()
+		t.Fatal("expected renewed lease to still be held")
+	}
+}
+
+func TestSourceManagerLeaseLocking(t *testing.T) {
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(cpath)
+
+	cfg := SourceManagerConfig{
+		Cachedir:     cpath,
+		Logger:       log.New(test.Writer{TB: t}, "", 0),
+		LeaseLocking: true,
+	}
+
+	sm, err := NewSourceManager(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error on SourceManager creation: %s", err)
+	}
+
+	if _, err = NewSourceManager(cfg); err == nil {
+		t.Error("Creating second SourceManager should have failed due to lease contention")
+	} else if _, ok := err.(CouldNotCreateLockError); !ok {
+		t.Errorf("Should have gotten CouldNotCreateLockError error type, but got %T", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(cpath, "sm.lease")); err != nil {
+		t.Error("Global cache lease not created correctly")
+	}
+
+	sm.Release()
+	if _, err = os.Stat(filepath.Join(cpath, "sm.lease")); !os.IsNotExist(err) {
+		t.Error("Global cache lease not cleared correctly on Release()")
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+// LockPreference controls how strongly the solver prefers the versions
+// selected in the root lock over the newest versions allowed by constraints,
+// for projects that have not been expressly marked for change.
+type LockPreference uint8
+
+const (
+	// PreferLocked keeps the locked version of every project, so long as it
+	// still satisfies constraints. This is the default, and favors stability.
+	PreferLocked LockPreference = iota
+
+	// PreferLockedDirect keeps the locked versions of the root project's direct
+	// dependencies, but selects the newest allowed versions of transitive
+	// dependencies.
+	PreferLockedDirect
+
+	// PreferNewest selects the newest allowed version of every project, as if
+	// ChangeAll were set. This favors freshness; locked versions are only used
+	// for projects whose sources cannot be found.
+	PreferNewest
+)
+
+func (lp LockPreference) String() string {
+	switch lp {
+	case PreferLocked:
+		return "all"
+	case PreferLockedDirect:
+		return "direct"
+	case PreferNewest:
+		return "none"
+	}
+	return "unknown"
+}
+
+// prefersLock reports whether the locked version of a project should be tried
+// first. fromRoot indicates whether the root project depends on it directly.
+func (lp LockPreference) prefersLock(fromRoot bool) bool {
+	switch lp {
+	case PreferLockedDirect:
+		return fromRoot
+	case PreferNewest:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SplitMajorVersion splits a project root ending in a major version element,
+// such as github.com/foo/bar/v2, into the root of the project's first major
+// version, github.com/foo/bar, and the major version, 2. ok is false if pr
+// does not end in such an element, which must be "v" followed by a number of
+// at least 2, without leading zeros.
+//
+// Each major version of a project has a root of its own, so two of them can be
+// selected, and vendored, side by side, with the later nested within the
+// earlier.
+func SplitMajorVersion(pr ProjectRoot) (base ProjectRoot, major uint64, ok bool) {
+	s := string(pr)
+	i := strings.LastIndex(s, "/")
+	if i <= 0 || len(s) < i+3 || s[i+1] != 'v' || s[i+2] == '0' {
+		return pr, 0, false
+	}
+	major, err := strconv.ParseUint(s[i+2:], 10, 64)
+	if err != nil || major < 2 {
+		return pr, 0, false
+	}
+	return ProjectRoot(s[:i]), major, true
+}
+
+// filterMajorVersion returns the versions in vl that the project root pr can
+// be selected at: for a root naming a major version, all but the semantic
+// versions of other major versions, and otherwise all of them.
+func filterMajorVersion(pr ProjectRoot, vl []PairedVersion) []PairedVersion {
+	_, major, ok := SplitMajorVersion(pr)
+	if !ok {
+		return vl
+	}
+	filtered := make([]PairedVersion, 0, len(vl))
+	for _, v := range vl {
+		if sv, ok := v.Unpair().(semVersion); ok && sv.sv.Major() != major {
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+	return filtered
+}
+
+// checkMajorVersion ensures that, for a project whose root names its major
+// version, a semantic version selected for it is of that major version. Other
+// versions, such as branches, are left to the project's constraints.
+func (s *solver) checkMajorVersion(pa atom) error {
+	_, major, ok := SplitMajorVersion(pa.id.ProjectRoot)
+	if !ok {
+		return nil
+	}
+	v := pa.v
+	if pv, ok := v.(PairedVersion); ok {
+		v = pv.Unpair()
+	}
+	if sv, ok := v.(semVersion); ok && sv.sv.Major() != major {
+		return &majorVersionFailure{
+			goal:  pa,
+			major: major,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "testing"
+
+func TestSplitMajorVersion(t *testing.T) {
+	for pr, want := range map[ProjectRoot]struct {
+		base  ProjectRoot
+		major uint64
+		ok    bool
+	}{
+		"github.com/foo/bar/v2":  {"github.com/foo/bar", 2, true},
+		"github.com/foo/bar/v10": {"github.com/foo/bar", 10, true},
+		"github.com/foo/bar":     {"github.com/foo/bar", 0, false},
+		"github.com/foo/bar/v1":  {"github.com/foo/bar/v1", 0, false},
+		"github.com/foo/bar/v02": {"github.com/foo/bar/v02", 0, false},
+		"github.com/foo/bar/v2x": {"github.com/foo/bar/v2x", 0, false},
+		"gopkg.in/yaml.v2":       {"gopkg.in/yaml.v2", 0, false},
+		"v2":                     {"v2", 0, false},
+	} {
+		base, major, ok := SplitMajorVersion(pr)
+		if base != want.base || major != want.major || ok != want.ok {
+			t.Errorf("SplitMajorVersion(%q) = %q, %d, %v; want %q, %d, %v", pr, base, major, ok, want.base, want.major, want.ok)
+		}
+	}
+}
+
+func TestFilterMajorVersion(t *testing.T) {
+	vl := []PairedVersion{
+		NewVersion("v1.5.0").Pair("abc1"),
+		NewVersion("v2.0.0").Pair("abc2"),
+		NewVersion("v2.1.0").Pair("abc3"),
+		NewVersion("v3.0.0").Pair("abc4"),
+		NewBranch("master").Pair("abc5"),
+	}
+
+	if got := filterMajorVersion("github.com/foo/bar", vl); len(got) != len(vl) {
+		t.Errorf("expected all versions of a root without a major version, got %v", got)
+	}
+	got := filterMajorVersion("github.com/foo/bar/v2", vl)
+	if len(got) != 3 || got[0].String() != "v2.0.0" || got[1].String() != "v2.1.0" || got[2].String() != "master" {
+		t.Errorf("expected only v2 tags and branches, got %v", got)
+	}
+}
+
+func TestNestingWaves(t *testing.T) {
+	var lps []LockedProject
+	for _, pr := range []ProjectRoot{"github.com/foo/bar/v2", "github.com/foo/bar", "github.com/foo/barbaz", "github.com/foo/bar/v2/v3"} {
+		lps = append(lps, NewLockedProject(ProjectIdentifier{ProjectRoot: pr}, Revision("abc123"), []string{"."}))
+	}
+
+	waves := nestingWaves(lps)
+	if len(waves) != 3 || len(waves[0]) != 2 || waves[1][0].Ident().ProjectRoot != "github.com/foo/bar/v2" || waves[2][0].Ident().ProjectRoot != "github.com/foo/bar/v2/v3" {
+		t.Errorf("expected nested projects to be written after those they are nested in, got %v", waves)
+	}
+}
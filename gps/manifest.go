@@ -82,6 +82,9 @@ type simpleRootManifest struct {
 	c, ovr ProjectConstraints
 	ig     *pkgtree.IgnoredRuleset
 	req    map[string]bool
+	gover  string
+	govers map[ProjectRoot]string
+	gen    []string
 }
 
 func (m simpleRootManifest) DependencyConstraints() ProjectConstraints {
@@ -96,6 +99,15 @@ func (m simpleRootManifest) IgnoredPackages() *pkgtree.IgnoredRuleset {
 func (m simpleRootManifest) RequiredPackages() map[string]bool {
 	return m.req
 }
+func (m simpleRootManifest) MinGoVersion() string {
+	return m.gover
+}
+func (m simpleRootManifest) ProjectGoVersions() map[ProjectRoot]string {
+	return m.govers
+}
+func (m simpleRootManifest) GeneratedFiles() []string {
+	return m.gen
+}
 
 // prepManifest ensures a manifest is prepared and safe for use by the solver.
 // This is mostly about ensuring that no outside routine can modify the manifest
@@ -49,7 +49,10 @@ type maybeGitSource struct {
 
 func (m maybeGitSource) try(ctx context.Context, cachedir string) (source, error) {
 	ustr := m.url.String()
-	path := sourceCachePath(cachedir, ustr)
+	path, err := unpackedSourceCachePath(cachedir, ustr)
+	if err != nil {
+		return nil, err
+	}
 
 	r, err := vcs.NewGitRepo(ustr, path)
 	if err != nil {
@@ -62,7 +65,7 @@ func (m maybeGitSource) try(ctx context.Context, cachedir string) (source, error
 
 	return &gitSource{
 		baseVCSSource: baseVCSSource{
-			repo: &gitRepo{r},
+			repo: &gitRepo{GitRepo: r},
 		},
 	}, nil
 }
@@ -94,7 +97,10 @@ func (m maybeGopkginSource) try(ctx context.Context, cachedir string) (source, e
 	// - just something that's unique to the particular gopkg.in domain context.
 	// So, it's OK to just dumb-join the scheme with the path.
 	aliasURL := m.url.Scheme + "://" + m.opath
-	path := sourceCachePath(cachedir, aliasURL)
+	path, err := unpackedSourceCachePath(cachedir, aliasURL)
+	if err != nil {
+		return nil, err
+	}
 	ustr := m.url.String()
 
 	r, err := vcs.NewGitRepo(ustr, path)
@@ -109,7 +115,7 @@ func (m maybeGopkginSource) try(ctx context.Context, cachedir string) (source, e
 	return &gopkginSource{
 		gitSource: gitSource{
 			baseVCSSource: baseVCSSource{
-				repo: &gitRepo{r},
+				repo: &gitRepo{GitRepo: r},
 			},
 		},
 		major:    m.major,
@@ -135,7 +141,10 @@ type maybeBzrSource struct {
 
 func (m maybeBzrSource) try(ctx context.Context, cachedir string) (source, error) {
 	ustr := m.url.String()
-	path := sourceCachePath(cachedir, ustr)
+	path, err := unpackedSourceCachePath(cachedir, ustr)
+	if err != nil {
+		return nil, err
+	}
 
 	r, err := vcs.NewBzrRepo(ustr, path)
 	if err != nil {
@@ -167,7 +176,10 @@ type maybeHgSource struct {
 
 func (m maybeHgSource) try(ctx context.Context, cachedir string) (source, error) {
 	ustr := m.url.String()
-	path := sourceCachePath(cachedir, ustr)
+	path, err := unpackedSourceCachePath(cachedir, ustr)
+	if err != nil {
+		return nil, err
+	}
 
 	r, err := vcs.NewHgRepo(ustr, path)
 	if err != nil {
@@ -0,0 +1,252 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// MemoryProject is the content of a project at a single version, as served
+// by a MemorySourceManager.
+type MemoryProject struct {
+	// Manifest and Lock are returned from GetManifestAndLock. A nil Manifest
+	// is treated as an empty one.
+	Manifest Manifest
+	Lock     Lock
+	// PackageTree is returned from ListPackages.
+	PackageTree pkgtree.PackageTree
+	// Files holds the contents of the project's tree, keyed by
+	// slash-separated path relative to the project root. ExportProject
+	// writes them out to disk.
+	Files map[string][]byte
+}
+
+type memoryVersion struct {
+	v PairedVersion
+	p MemoryProject
+}
+
+// MemorySourceManager is a SourceManager that serves projects held in memory
+// instead of fetching them from their sources. It is intended for tests of
+// tools that embed the solver, and is safe for concurrent use.
+//
+// Projects are identified solely by their ProjectRoot; the Source of a
+// ProjectIdentifier is ignored.
+type MemorySourceManager struct {
+	mu       sync.RWMutex
+	projects map[ProjectRoot][]memoryVersion
+}
+
+var _ SourceManager = &MemorySourceManager{}
+
+// NewMemorySourceManager returns a MemorySourceManager that serves no
+// projects.
+func NewMemorySourceManager() *MemorySourceManager {
+	return &MemorySourceManager{
+		projects: make(map[ProjectRoot][]memoryVersion),
+	}
+}
+
+// AddVersion makes p available as the content of the project rooted at pr at
+// version v. Versions are listed in the order they were added.
+func (sm *MemorySourceManager) AddVersion(pr ProjectRoot, v PairedVersion, p MemoryProject) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.projects[pr] = append(sm.projects[pr], memoryVersion{v: v, p: p})
+}
+
+func (sm *MemorySourceManager) lookup(pi ProjectIdentifier, v Version) (MemoryProject, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	mvs, has := sm.projects[pi.ProjectRoot]
+	if !has {
+		return MemoryProject{}, errors.Errorf("project %s does not exist", pi)
+	}
+	for _, mv := range mvs {
+		if mv.v.Matches(v) {
+			return mv.p, nil
+		}
+	}
+	return MemoryProject{}, errors.Errorf("project %s has no version %s", pi, v)
+}
+
+// SourceExists implements SourceManager.
+func (sm *MemorySourceManager) SourceExists(pi ProjectIdentifier) (bool, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	_, has := sm.projects[pi.ProjectRoot]
+	return has, nil
+}
+
+// SyncSourceFor implements SourceManager. There is nothing to sync, so it
+// only checks that the project exists.
+func (sm *MemorySourceManager) SyncSourceFor(pi ProjectIdentifier) error {
+	if exists, _ := sm.SourceExists(pi); !exists {
+		return errors.Errorf("source %s does not exist", pi)
+	}
+	return nil
+}
+
+// ListVersions implements VersionLister.
+func (sm *MemorySourceManager) ListVersions(pi ProjectIdentifier) ([]PairedVersion, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	mvs, has := sm.projects[pi.ProjectRoot]
+	if !has {
+		return nil, errors.Errorf("project %s does not exist", pi)
+	}
+	pvl := make([]PairedVersion, len(mvs))
+	for i, mv := range mvs {
+		pvl[i] = mv.v
+	}
+	return pvl, nil
+}
+
+// RevisionPresentIn implements VersionLister.
+func (sm *MemorySourceManager) RevisionPresentIn(pi ProjectIdentifier, r Revision) (bool, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	for _, mv := range sm.projects[pi.ProjectRoot] {
+		if mv.v.Revision() == r {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListPackages implements ManifestFetcher.
+func (sm *MemorySourceManager) ListPackages(pi ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	p, err := sm.lookup(pi, v)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return p.PackageTree.Copy(), nil
+}
+
+// GetManifestAndLock implements ManifestFetcher. The ProjectAnalyzer is not
+// used.
+func (sm *MemorySourceManager) GetManifestAndLock(pi ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	p, err := sm.lookup(pi, v)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Manifest == nil {
+		return SimpleManifest{}, p.Lock, nil
+	}
+	return p.Manifest, p.Lock, nil
+}
+
+// ExportProject implements Exporter, writing the Files of the project at v to
+// the directory to.
+func (sm *MemorySourceManager) ExportProject(ctx context.Context, pi ProjectIdentifier, v Version, to string) error {
+	p, err := sm.lookup(pi, v)
+	if err != nil {
+		return err
+	}
+
+	for name, data := range p.Files {
+		path := filepath.Join(to, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return errors.Wrapf(err, "failed to export %s", pi)
+		}
+		if err := ioutil.WriteFile(path, data, 0666); err != nil {
+			return errors.Wrapf(err, "failed to export %s", pi)
+		}
+	}
+	return nil
+}
+
+// ExportPrunedProject implements Exporter.
+func (sm *MemorySourceManager) ExportPrunedProject(ctx context.Context, lp LockedProject, prune PruneOptions, to string) error {
+	if err := sm.ExportProject(ctx, lp.Ident(), lp.Version(), to); err != nil {
+		return err
+	}
+	return PruneProject(to, lp, prune)
+}
+
+// DeduceProjectRoot implements SourceManager, returning the longest root of
+// an added project that is ip or one of its parents.
+func (sm *MemorySourceManager) DeduceProjectRoot(ip string) (ProjectRoot, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var root ProjectRoot
+	for pr := range sm.projects {
+		if (ip == string(pr) || strings.HasPrefix(ip, string(pr)+"/")) && len(pr) > len(root) {
+			root = pr
+		}
+	}
+	if root == "" {
+		return "", errors.Errorf("no project found for %s", ip)
+	}
+	return root, nil
+}
+
+// SourceURLsForPath implements SourceManager, returning an https URL for the
+// deduced project root of ip.
+func (sm *MemorySourceManager) SourceURLsForPath(ip string) ([]*url.URL, error) {
+	pr, err := sm.DeduceProjectRoot(ip)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse("https://" + string(pr))
+	if err != nil {
+		return nil, err
+	}
+	return []*url.URL{u}, nil
+}
+
+// SourceURLFor implements SourceManager, returning the first of the
+// SourceURLsForPath for pi's source.
+func (sm *MemorySourceManager) SourceURLFor(pi ProjectIdentifier) (string, error) {
+	if exists, _ := sm.SourceExists(pi); !exists {
+		return "", errors.Errorf("source %s does not exist", pi)
+	}
+	urls, err := sm.SourceURLsForPath(pi.normalizedSource())
+	if err != nil {
+		return "", err
+	}
+	return urls[0].String(), nil
+}
+
+// InferConstraint implements SourceManager.
+func (sm *MemorySourceManager) InferConstraint(s string, pi ProjectIdentifier) (Constraint, error) {
+	return inferConstraint(s, pi, sm, func(rev Revision) (Revision, error) {
+		sm.mu.RLock()
+		defer sm.mu.RUnlock()
+
+		var found Revision
+		for _, mv := range sm.projects[pi.ProjectRoot] {
+			if r := mv.v.Revision(); strings.HasPrefix(string(r), string(rev)) {
+				if found != "" && found != r {
+					return "", errors.Errorf("revision %s is ambiguous in %s", rev, pi)
+				}
+				found = r
+			}
+		}
+		if found == "" {
+			return "", errors.Errorf("no revision %s in %s", rev, pi)
+		}
+		return found, nil
+	})
+}
+
+// Release implements SourceManager. A MemorySourceManager holds no locks, so
+// it does nothing.
+func (sm *MemorySourceManager) Release() {}
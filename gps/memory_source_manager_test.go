@@ -0,0 +1,111 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/test"
+)
+
+func newTestMemorySourceManager() *MemorySourceManager {
+	sm := NewMemorySourceManager()
+	for _, v := range []PairedVersion{
+		NewVersion("v1.0.0").Pair("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		NewVersion("v1.1.0").Pair("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+	} {
+		sm.AddVersion("example.com/dep", v, MemoryProject{
+			PackageTree: pkgtree.PackageTree{
+				ImportRoot: "example.com/dep",
+				Packages: map[string]pkgtree.PackageOrErr{
+					"example.com/dep": {
+						P: pkgtree.Package{ImportPath: "example.com/dep", Name: "dep"},
+					},
+				},
+			},
+			Files: map[string][]byte{
+				"dep.go": []byte("package dep // " + v.String() + "\n"),
+			},
+		})
+	}
+	return sm
+}
+
+func TestMemorySourceManagerSolveAndExport(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("vendor")
+
+	sm := newTestMemorySourceManager()
+	params := SolveParameters{
+		RootDir:         h.Path("."),
+		ProjectAnalyzer: naiveAnalyzer{},
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "example.com/root",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"example.com/root": {
+					P: pkgtree.Package{
+						ImportPath: "example.com/root",
+						Name:       "root",
+						Imports:    []string{"example.com/dep"},
+					},
+				},
+			},
+		},
+	}
+
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soln, err := s.Solve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lps := soln.Projects()
+	if len(lps) != 1 {
+		t.Fatalf("expected one project in solution, got %d", len(lps))
+	}
+	if v := lps[0].Version().String(); v != "v1.1.0" {
+		t.Errorf("expected newest version v1.1.0 to be selected, got %s", v)
+	}
+
+	if err = WriteDepTree(h.Path("vendor"), soln, sm, defaultCascadingPruneOptions(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(h.Path("vendor"), "example.com", "dep", "dep.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package dep // v1.1.0\n"; string(data) != want {
+		t.Errorf("unexpected exported contents: %q", data)
+	}
+}
+
+func TestMemorySourceManagerInferConstraint(t *testing.T) {
+	sm := newTestMemorySourceManager()
+	pi := mkPI("example.com/dep")
+
+	c, err := sm.InferConstraint("bbbbbbb", pi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != Revision("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb") {
+		t.Errorf("expected abbreviated revision to be expanded, got %s", c)
+	}
+
+	if _, err = sm.InferConstraint("ccccccc", pi); err == nil {
+		t.Error("expected an error inferring a constraint from an unknown revision")
+	}
+
+	if root, err := sm.DeduceProjectRoot("example.com/dep/sub"); err != nil || root != "example.com/dep" {
+		t.Errorf("unexpected deduced root %q, err %v", root, err)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Namespaces maps the import paths below a prefix directly to the git
+// repositories holding them, so that projects under the prefix need neither
+// go-get metadata to be served for them nor a source of their own in each
+// manifest that uses them.
+//
+// Keys are patterns of project roots: a literal prefix, such as
+// "corp.example.com", followed by one or more elements in braces, such as
+// "{team}/{repo}", each of which matches one element of an import path. The
+// project root of an import path matching a pattern is its first elements, as
+// many as the pattern has. Values are templates of the URLs of the project
+// roots' sources, in which each element of the pattern is replaced by the
+// element it matched, as in "ssh://git@git.corp.example.com/{team}/{repo}.git".
+// A template without a scheme is tried over each scheme that git supports, in
+// turn, as for any other deduced source.
+//
+// The pattern with the most elements matching an import path takes precedence,
+// then the one with the most literal elements. Namespaces take precedence over
+// the rules for deducing the sources of well-known hosts.
+type Namespaces map[string]string
+
+// Validate returns an error if any of the patterns or templates in n are
+// malformed, or a template uses an element its pattern lacks.
+func (n Namespaces) Validate() error {
+	for pattern, tmpl := range n {
+		elems, err := namespaceElems(pattern)
+		if err != nil {
+			return err
+		}
+		src, rest := tmpl, tmpl
+		for _, e := range elems {
+			if e.name != "" {
+				rest = strings.Replace(rest, "{"+e.name+"}", "x", -1)
+			}
+		}
+		if strings.ContainsAny(rest, "{}") {
+			return errors.Errorf("the source %q for namespace %s uses an element the namespace does not have", src, pattern)
+		}
+		if _, err := namespaceURL(rest); err != nil {
+			return errors.Wrapf(err, "invalid source %q for namespace %s", src, pattern)
+		}
+	}
+	return nil
+}
+
+// A namespaceElem is an element of a namespace pattern: either a literal, or
+// the name of a placeholder.
+type namespaceElem struct {
+	literal, name string
+}
+
+// namespaceElems splits a namespace pattern into its elements.
+func namespaceElems(pattern string) ([]namespaceElem, error) {
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	elems := make([]namespaceElem, len(parts))
+	placeholders := 0
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") && len(p) > 2:
+			if i == 0 {
+				return nil, errors.Errorf("namespace %s must begin with a host", pattern)
+			}
+			elems[i].name = p[1 : len(p)-1]
+			placeholders++
+		case p == "" || strings.ContainsAny(p, "{}"):
+			return nil, errors.Errorf("namespace %s has an invalid element %q", pattern, p)
+		case placeholders > 0:
+			return nil, errors.Errorf("namespace %s has the literal element %q after a placeholder", pattern, p)
+		default:
+			elems[i].literal = p
+		}
+	}
+	if placeholders == 0 {
+		return nil, errors.Errorf("namespace %s has no placeholder elements, such as {repo}", pattern)
+	}
+	return elems, nil
+}
+
+// namespaceURL parses the URL of a source expanded from a namespace template.
+func namespaceURL(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errors.New("no host")
+	}
+	if !validateVCSScheme(u.Scheme, "git") {
+		return nil, errors.Errorf("%s is not a valid scheme for accessing git repositories", u.Scheme)
+	}
+	return u, nil
+}
+
+// deduce returns the project root and candidate sources of path, if it is in
+// one of the namespaces. ok is false if it is in none.
+func (n Namespaces) deduce(path string) (pd pathDeduction, ok bool, err error) {
+	parts := strings.Split(path, "/")
+	var best []namespaceElem
+	var bestPattern string
+	for pattern := range n {
+		elems, err := namespaceElems(pattern)
+		if err != nil || len(elems) > len(parts) || !namespaceMatches(elems, parts) {
+			continue
+		}
+		// Of patterns as long, the one with more literal elements wins.
+		if best == nil || len(elems) > len(best) ||
+			len(elems) == len(best) && namespaceLiterals(elems) > namespaceLiterals(best) ||
+			len(elems) == len(best) && namespaceLiterals(elems) == namespaceLiterals(best) && pattern < bestPattern {
+			best, bestPattern = elems, pattern
+		}
+	}
+	if best == nil {
+		return pathDeduction{}, false, nil
+	}
+
+	src := n[bestPattern]
+	for i, e := range best {
+		if e.name != "" {
+			src = strings.Replace(src, "{"+e.name+"}", parts[i], -1)
+		}
+	}
+	root := strings.Join(parts[:len(best)], "/")
+	u, err := namespaceURL(src)
+	if err != nil {
+		return pathDeduction{}, true, errors.Wrapf(err, "invalid source %q for %s", src, root)
+	}
+	if strings.Contains(src, "://") {
+		return pathDeduction{root: root, mb: maybeSources{maybeGitSource{url: u}}}, true, nil
+	}
+
+	mb := make(maybeSources, len(gitSchemes))
+	for k, scheme := range gitSchemes {
+		u2 := *u
+		if scheme == "ssh" {
+			u2.User = url.User("git")
+		}
+		u2.Scheme = scheme
+		mb[k] = maybeGitSource{url: &u2}
+	}
+	return pathDeduction{root: root, mb: mb}, true, nil
+}
+
+// namespaceMatches reports whether the elements of a namespace pattern match
+// the first of parts, the elements of an import path.
+func namespaceMatches(elems []namespaceElem, parts []string) bool {
+	for i, e := range elems {
+		if parts[i] == "" || e.literal != "" && e.literal != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// namespaceLiterals returns the number of literal elements in elems.
+func namespaceLiterals(elems []namespaceElem) int {
+	var n int
+	for _, e := range elems {
+		if e.literal != "" {
+			n++
+		}
+	}
+	return n
+}
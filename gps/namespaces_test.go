@@ -0,0 +1,95 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNamespacesDeduce(t *testing.T) {
+	n := Namespaces{
+		"corp.example.com/{team}/{repo}":  "ssh://git@git.corp.example.com/{team}/{repo}.git",
+		"corp.example.com/tools/{repo}":   "https://tools.corp.example.com/{repo}",
+		"corp.example.com/{team}":         "https://git.corp.example.com/{team}",
+		"github.com/corp-private/{repo}":  "git.corp.example.com/mirror/{repo}",
+		"go.corp.example.com/{repo}/{ns}": "https://git.corp.example.com/{ns}/{repo}",
+	}
+	if err := n.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path, root string
+		urls       []string
+	}{
+		{"corp.example.com/infra/deploy/cmd/deploy", "corp.example.com/infra/deploy",
+			[]string{"ssh://git@git.corp.example.com/infra/deploy.git"}},
+		// The pattern with more literal elements wins.
+		{"corp.example.com/tools/gen", "corp.example.com/tools/gen",
+			[]string{"https://tools.corp.example.com/gen"}},
+		// The longest pattern that matches.
+		{"corp.example.com/infra", "corp.example.com/infra",
+			[]string{"https://git.corp.example.com/infra"}},
+		// Elements can be used in any order.
+		{"go.corp.example.com/lib/platform", "go.corp.example.com/lib/platform",
+			[]string{"https://git.corp.example.com/platform/lib"}},
+		// Without a scheme, every git scheme is tried.
+		{"github.com/corp-private/secrets/pkg", "github.com/corp-private/secrets", []string{
+			"https://git.corp.example.com/mirror/secrets",
+			"ssh://git@git.corp.example.com/mirror/secrets",
+			"git://git.corp.example.com/mirror/secrets",
+			"http://git.corp.example.com/mirror/secrets",
+		}},
+	}
+	for _, c := range cases {
+		pd, ok, err := n.deduce(c.path)
+		if err != nil || !ok {
+			t.Errorf("%s: expected a deduction, got %v, %v", c.path, ok, err)
+			continue
+		}
+		var urls []string
+		for _, m := range pd.mb {
+			urls = append(urls, m.URL().String())
+		}
+		if pd.root != c.root || !reflect.DeepEqual(urls, c.urls) {
+			t.Errorf("%s: expected %s at %v, got %s at %v", c.path, c.root, c.urls, pd.root, urls)
+		}
+	}
+
+	if _, ok, _ := n.deduce("github.com/someone/else"); ok {
+		t.Error("expected a path in no namespace not to be deduced")
+	}
+}
+
+func TestNamespacesValidate(t *testing.T) {
+	for _, n := range []Namespaces{
+		{"{host}/{repo}": "https://{host}/{repo}"},
+		{"corp.example.com": "https://git.corp.example.com"},
+		{"corp.example.com/{repo}/lib": "https://git.corp.example.com/{repo}"},
+		{"corp.example.com/{repo}": "https://git.corp.example.com/{team}/{repo}"},
+		{"corp.example.com/{repo}": "ftp://git.corp.example.com/{repo}"},
+	} {
+		if err := n.Validate(); err == nil {
+			t.Errorf("expected %v to be invalid", n)
+		}
+	}
+}
+
+func TestDeduceNamespacedPath(t *testing.T) {
+	dc := newDeductionCoordinator(newSupervisor(context.Background()))
+	dc.namespaces = Namespaces{"github.com/corp/{repo}": "ssh://git@git.corp.example.com/{repo}.git"}
+
+	// The namespace overrides the usual deduction for github.com, without
+	// any network access.
+	pd, err := dc.deduceRootPath(context.Background(), "github.com/corp/app/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "github.com/corp/app" || len(pd.mb) != 1 || pd.mb[0].URL().String() != "ssh://git@git.corp.example.com/app.git" {
+		t.Errorf("unexpected deduction %s at %v", pd.root, pd.mb)
+	}
+}
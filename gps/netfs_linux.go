@@ -0,0 +1,26 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "syscall"
+
+// Filesystem magic numbers, from statfs(2), of the network filesystems on
+// which flock semantics can't be relied upon.
+var networkFilesystemMagics = map[uint32]bool{
+	0x6969:     true, // NFS_SUPER_MAGIC
+	0x517B:     true, // SMB_SUPER_MAGIC
+	0xFF534D42: true, // CIFS_MAGIC_NUMBER
+	0xFE534D42: true, // SMB2_MAGIC_NUMBER
+	0x5346414F: true, // AFS_SUPER_MAGIC
+}
+
+// isNetworkFilesystem reports whether path resides on a network filesystem.
+func isNetworkFilesystem(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return networkFilesystemMagics[uint32(st.Type)], nil
+}
@@ -0,0 +1,13 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux,!darwin,!windows
+
+package gps
+
+// isNetworkFilesystem reports whether path resides on a network filesystem.
+// Detection is not supported on this platform, so it always reports false.
+func isNetworkFilesystem(path string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDriveTypeW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDriveTypeW")
+
+// driveRemote is the GetDriveType return value for network drives.
+const driveRemote = 4
+
+// isNetworkFilesystem reports whether path resides on a network share, either
+// addressed directly by a UNC path or through a mapped network drive.
+func isNetworkFilesystem(path string) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	vol := filepath.VolumeName(path)
+	if strings.HasPrefix(vol, `\\`) {
+		return true, nil
+	}
+
+	root, err := syscall.UTF16PtrFromString(vol + `\`)
+	if err != nil {
+		return false, err
+	}
+	if err := procGetDriveTypeW.Find(); err != nil {
+		return false, err
+	}
+	t, _, _ := procGetDriveTypeW.Call(uintptr(unsafe.Pointer(root)))
+	return t == driveRemote, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NetworkPolicy restricts the hosts that the SourceMgr may contact, whether
+// to fetch go-get metadata while deducing the root of an import path, or to
+// reach the upstream of a source. The zero value permits every host.
+//
+// Hosts are given as patterns: a host name, such as "github.com", matches only
+// that host, while "*.example.com" matches every host below example.com, but
+// not example.com itself. Matching is case-insensitive, and ignores ports.
+type NetworkPolicy struct {
+	// Allow lists the hosts that may be contacted. If it is empty, every host
+	// not in Deny may be.
+	Allow []string
+	// Deny lists the hosts that may not be contacted, even if they are in
+	// Allow.
+	Deny []string
+}
+
+// Permits reports whether the policy allows host to be contacted.
+func (p NetworkPolicy) Permits(host string) bool {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, pat := range p.Deny {
+		if hostMatches(pat, host) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, pat := range p.Allow {
+		if hostMatches(pat, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns a *NetworkPermissionError if the policy does not allow host to
+// be contacted on behalf of path. Empty hosts, as of local sources, are always
+// allowed.
+func (p NetworkPolicy) check(host, path, url string) error {
+	if host == "" || p.Permits(host) {
+		return nil
+	}
+	return &NetworkPermissionError{Host: host, Path: path, URL: url}
+}
+
+func hostMatches(pat, host string) bool {
+	pat = strings.ToLower(pat)
+	if strings.HasPrefix(pat, "*.") {
+		return strings.HasSuffix(host, pat[1:])
+	}
+	return pat == host
+}
+
+// NetworkPermissionError indicates that a host had to be contacted that the
+// NetworkPolicy of the SourceMgr does not allow.
+type NetworkPermissionError struct {
+	// The host that was not allowed.
+	Host string
+	// The import path or project root on behalf of which it was to be
+	// contacted.
+	Path string
+	// The URL of the source on the host, if it was a source that was to be
+	// reached, rather than go-get metadata.
+	URL string
+}
+
+func (e *NetworkPermissionError) Error() string {
+	if e.URL != "" {
+		return fmt.Sprintf("%s needs the source at %s, but the network policy does not allow contacting %s", e.Path, e.URL, e.Host)
+	}
+	return fmt.Sprintf("%s needs go-get metadata from %s, but the network policy does not allow contacting it", e.Path, e.Host)
+}
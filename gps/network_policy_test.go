@@ -0,0 +1,79 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+	"github.com/pkg/errors"
+)
+
+func TestNetworkPolicyPermits(t *testing.T) {
+	p := NetworkPolicy{
+		Allow: []string{"github.com", "*.corp.example.com"},
+		Deny:  []string{"secret.corp.example.com"},
+	}
+	for host, want := range map[string]bool{
+		"github.com":              true,
+		"GitHub.com:443":          true,
+		"gitlab.com":              false,
+		"git.corp.example.com":    true,
+		"corp.example.com":        false,
+		"secret.corp.example.com": false,
+	} {
+		if got := p.Permits(host); got != want {
+			t.Errorf("expected Permits(%q) to be %v", host, want)
+		}
+	}
+
+	if !(NetworkPolicy{}).Permits("anywhere.com") {
+		t.Error("expected the zero policy to permit every host")
+	}
+	if (NetworkPolicy{Deny: []string{"gitlab.com"}}).Permits("gitlab.com") {
+		t.Error("expected a denied host not to be permitted without an allow list")
+	}
+}
+
+func TestNetworkPolicyDenial(t *testing.T) {
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir:      cpath,
+		Logger:        log.New(test.Writer{TB: t}, "", 0),
+		NetworkPolicy: NetworkPolicy{Deny: []string{"github.com", "example.com"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+
+	// The source of a project on a known host.
+	_, err = sm.SourceExists(mkPI("github.com/sdboyer/gpkt"))
+	perr, ok := errors.Cause(err).(*NetworkPermissionError)
+	if !ok {
+		t.Fatalf("expected a *NetworkPermissionError, got %T: %v", err, err)
+	}
+	if perr.Host != "github.com" || perr.Path != "github.com/sdboyer/gpkt" || perr.URL == "" {
+		t.Errorf("expected the denial to name the host, project and source, got %+v", perr)
+	}
+
+	// The go-get metadata of a vanity import path.
+	_, err = sm.DeduceProjectRoot("example.com/vanity/pkg")
+	perr, ok = errors.Cause(err).(*NetworkPermissionError)
+	if !ok {
+		t.Fatalf("expected a *NetworkPermissionError, got %T: %v", err, err)
+	}
+	if perr.Host != "example.com" || perr.Path != "example.com/vanity/pkg" || perr.URL != "" {
+		t.Errorf("expected the denial to name the host and import path, got %+v", perr)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkgtree
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AssetRuleset comprises a set of patterns for the files, other than Go
+// source, that the packages of a project read at runtime, such as templates,
+// fixtures or schemas kept beside a package and opened relative to its
+// directory. Nothing in the Go code itself marks such references, so they
+// must be declared for tools that remove files from a project's tree, like
+// pruning, to leave them in place.
+//
+// Patterns are slash-separated paths relative to the project root, in the
+// syntax of path.Match, so that "web/templates/*.html" matches the templates
+// of package web. A pattern that matches a directory matches everything
+// beneath it.
+type AssetRuleset struct {
+	patterns []string
+}
+
+// NewAssetRuleset processes a set of patterns into an AssetRuleset. Duplicate
+// and empty patterns are discarded. AssetRulesets are immutable once created.
+//
+// The patterns are expected to have been checked with ValidateAssetPattern;
+// invalid ones never match.
+func NewAssetRuleset(patterns []string) *AssetRuleset {
+	ar := &AssetRuleset{}
+	seen := make(map[string]bool, len(patterns))
+	for _, p := range patterns {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		ar.patterns = append(ar.patterns, p)
+	}
+	sort.Strings(ar.patterns)
+	return ar
+}
+
+// ValidateAssetPattern returns an error if p is not a valid asset pattern: a
+// well-formed path.Match pattern for a clean, slash-separated path within the
+// project root.
+func ValidateAssetPattern(p string) error {
+	switch {
+	case p == "", p == ".", strings.HasPrefix(p, "/"), strings.Contains(p, "\\"),
+		path.Clean(p) != p, p == "..", strings.HasPrefix(p, "../"):
+		return fmt.Errorf("%q is not a clean path relative to the project root", p)
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %s", p, err)
+	}
+	return nil
+}
+
+// IsAsset indicates whether the file at the slash-separated path name,
+// relative to the project root, is matched by the ruleset, either itself or
+// through one of the directories containing it.
+func (ar *AssetRuleset) IsAsset(name string) bool {
+	if name == "" || ar == nil {
+		return false
+	}
+
+	for _, p := range ar.patterns {
+		for dir := name; dir != "."; dir = path.Dir(dir) {
+			if ok, _ := path.Match(p, dir); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Len indicates the number of patterns in the ruleset.
+func (ar *AssetRuleset) Len() int {
+	if ar == nil {
+		return 0
+	}
+	return len(ar.patterns)
+}
+
+// ToSlice converts the contents of the AssetRuleset to a string slice.
+//
+// This operation is symmetrically dual to NewAssetRuleset.
+func (ar *AssetRuleset) ToSlice() []string {
+	if ar.Len() == 0 {
+		return nil
+	}
+	return append([]string(nil), ar.patterns...)
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkgtree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssetRuleset(t *testing.T) {
+	ar := NewAssetRuleset([]string{"web/templates/*.html", "schema", "", "schema"})
+
+	if want := []string{"schema", "web/templates/*.html"}; !reflect.DeepEqual(ar.ToSlice(), want) {
+		t.Errorf("unexpected patterns:\n\t(GOT): %v\n\t(WNT): %v", ar.ToSlice(), want)
+	}
+
+	for _, name := range []string{"web/templates/index.html", "schema/v1.json", "schema"} {
+		if !ar.IsAsset(name) {
+			t.Errorf("expected %q to be an asset", name)
+		}
+	}
+	for _, name := range []string{"web/templates/index.txt", "web/templates/sub/index.html", "web/web.go", "other/schema", ""} {
+		if ar.IsAsset(name) {
+			t.Errorf("expected %q not to be an asset", name)
+		}
+	}
+
+	var empty *AssetRuleset
+	if empty.IsAsset("schema") || empty.Len() != 0 || empty.ToSlice() != nil {
+		t.Error("expected a nil AssetRuleset to match nothing")
+	}
+}
+
+func TestValidateAssetPattern(t *testing.T) {
+	for _, p := range []string{"templates", "web/*.html", "data/[a-z]*.json"} {
+		if err := ValidateAssetPattern(p); err != nil {
+			t.Errorf("expected %q to be valid, got %s", p, err)
+		}
+	}
+	for _, p := range []string{"", ".", "/abs", "../up", "a/../b", "a//b", "dir/", `win\path`, "bad[", "a/[]/b"} {
+		if err := ValidateAssetPattern(p); err == nil {
+			t.Errorf("expected %q to be invalid", p)
+		}
+	}
+}
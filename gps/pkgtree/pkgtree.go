@@ -73,139 +73,245 @@ func ListPackages(fileRoot, importRoot string) (PackageTree, error) {
 		return PackageTree{}, err
 	}
 
-	err = filepath.Walk(fileRoot, func(wp string, fi os.FileInfo, err error) error {
-		if err != nil && err != filepath.SkipDir {
-			if os.IsPermission(err) {
-				return filepath.SkipDir
-			}
-			return err
+	if err = walkPackages(ptree, fileRoot, fileRoot); err != nil {
+		return PackageTree{}, err
+	}
+
+	return ptree, nil
+}
+
+// Update returns t, a PackageTree listed by ListPackages from fileRoot, updated
+// for changes to the directories in dirs, which are absolute or relative to
+// fileRoot, without walking all of fileRoot again. It lets tools that watch a
+// large project keep its PackageTree current cheaply.
+//
+// Each directory is handled according to its state:
+//
+//  - if it no longer exists, its package and those below it are removed;
+//  - if it has a package in t, that package is read again, but not those
+//    below it, which are expected to be in dirs if they changed;
+//  - otherwise, as when it was just created, it is walked, as by
+//    ListPackages, for the packages at or below it.
+//
+// Directories that ListPackages skips, such as those in vendor, are ignored.
+// t itself is not modified.
+func (t PackageTree) Update(fileRoot string, dirs ...string) (PackageTree, error) {
+	fileRoot, err := filepath.Abs(fileRoot)
+	if err != nil {
+		return PackageTree{}, err
+	}
+
+	ptree := PackageTree{
+		ImportRoot: t.ImportRoot,
+		Packages:   make(map[string]PackageOrErr, len(t.Packages)),
+	}
+	for ip, poe := range t.Packages {
+		ptree.Packages[ip] = poe
+	}
+
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(fileRoot, dir)
 		}
-		if !fi.IsDir() {
-			return nil
+		dir = filepath.Clean(dir)
+		rel, err := filepath.Rel(fileRoot, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return PackageTree{}, fmt.Errorf("%s is not within %s", dir, fileRoot)
 		}
-
-		// Skip dirs that are known to hold non-local/dependency code.
-		//
-		// We don't skip _*, or testdata dirs because, while it may be poor
-		// form, importing them is not a compilation error.
-		switch fi.Name() {
-		case "vendor":
-			return filepath.SkipDir
+		if anySkipDir(rel) {
+			continue
 		}
 
-		// Skip dirs that are known to be VCS roots.
-		//
-		// Note that there are some pathological edge cases this doesn't cover,
-		// such as a user using Git for version control, but having a package
-		// named "svn" in a directory named ".svn".
-		if _, ok := vcsRoots[fi.Name()]; ok {
-			return filepath.SkipDir
+		ip := filepath.ToSlash(filepath.Join(t.ImportRoot, strings.TrimPrefix(dir, fileRoot)))
+		fi, err := os.Stat(dir)
+		switch {
+		case os.IsNotExist(err) || err == nil && !fi.IsDir():
+			ptree.removeSubtree(ip)
+			continue
+		case err != nil:
+			return PackageTree{}, err
 		}
 
-		{
-			// For Go 1.9 and earlier:
-			//
-			// The entry error is nil when visiting a directory that itself is
-			// untraversable, as it's still governed by the parent directory's
-			// perms. We have to check readability of the dir here, because
-			// otherwise we'll have an empty package entry when we fail to read any
-			// of the dir's contents.
-			//
-			// If we didn't check here, then the next time this closure is called it
-			// would have an err with the same path as is called this time, as only
-			// then will filepath.Walk have attempted to descend into the directory
-			// and encountered an error.
-			var f *os.File
-			f, err = os.Open(wp)
-			if err != nil {
-				if os.IsPermission(err) {
-					return filepath.SkipDir
-				}
-				return err
+		if _, has := ptree.Packages[ip]; has {
+			delete(ptree.Packages, ip)
+			err = readPackage(ptree, fileRoot, dir)
+			if err == filepath.SkipDir {
+				ptree.removeSubtree(ip)
+				err = nil
 			}
-			f.Close()
+		} else {
+			ptree.removeSubtree(ip)
+			err = walkPackages(ptree, fileRoot, dir)
+		}
+		if err != nil {
+			return PackageTree{}, err
 		}
+	}
 
-		// Compute the import path. Run the result through ToSlash(), so that
-		// windows file paths are normalized to slashes, as is expected of
-		// import paths.
-		ip := filepath.ToSlash(filepath.Join(importRoot, strings.TrimPrefix(wp, fileRoot)))
+	return ptree, nil
+}
 
-		// Find all the imports, across all os/arch combos
-		p := &build.Package{
-			Dir:        wp,
-			ImportPath: ip,
+// anySkipDir reports whether any directory in the relative path rel is
+// skipped when listing packages.
+func anySkipDir(rel string) bool {
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		if skipDir(elem) {
+			return true
 		}
-		err = fillPackage(p)
+	}
+	return false
+}
 
-		if err != nil {
-			switch err.(type) {
-			case gscan.ErrorList, *gscan.Error, *build.NoGoError, *ConflictingImportComments:
-				// Assorted cases in which we've encounter malformed or
-				// nonexistent Go source code.
-				ptree.Packages[ip] = PackageOrErr{
-					Err: err,
-				}
-				return nil
-			default:
-				return err
-			}
+// removeSubtree removes the package at the import path ip, and those below
+// it, from t.
+func (t PackageTree) removeSubtree(ip string) {
+	for p := range t.Packages {
+		if eqOrSlashedPrefix(p, ip) {
+			delete(t.Packages, p)
 		}
+	}
+}
 
-		pkg := Package{
-			ImportPath:  ip,
-			CommentPath: p.ImportComment,
-			Name:        p.Name,
-			Imports:     p.Imports,
-			TestImports: dedupeStrings(p.TestImports, p.XTestImports),
-		}
+// skipDir reports whether the directory of the given name, and all below it,
+// are skipped when listing packages.
+func skipDir(name string) bool {
+	// Skip dirs that are known to hold non-local/dependency code.
+	//
+	// We don't skip _*, or testdata dirs because, while it may be poor
+	// form, importing them is not a compilation error.
+	if name == "vendor" {
+		return true
+	}
 
-		if pkg.CommentPath != "" && !strings.HasPrefix(pkg.CommentPath, importRoot) {
-			ptree.Packages[ip] = PackageOrErr{
-				Err: &NonCanonicalImportRoot{
-					ImportRoot: importRoot,
-					Canonical:  pkg.CommentPath,
-				},
+	// Skip dirs that are known to be VCS roots.
+	//
+	// Note that there are some pathological edge cases this doesn't cover,
+	// such as a user using Git for version control, but having a package
+	// named "svn" in a directory named ".svn".
+	_, ok := vcsRoots[name]
+	return ok
+}
+
+// walkPackages adds the packages in the tree rooted at dir, which is fileRoot
+// or a directory below it, to ptree.
+func walkPackages(ptree PackageTree, fileRoot, dir string) error {
+	return filepath.Walk(dir, func(wp string, fi os.FileInfo, err error) error {
+		if err != nil && err != filepath.SkipDir {
+			if os.IsPermission(err) {
+				return filepath.SkipDir
 			}
+			return err
+		}
+		if !fi.IsDir() {
 			return nil
 		}
+		if skipDir(fi.Name()) {
+			return filepath.SkipDir
+		}
+		return readPackage(ptree, fileRoot, wp)
+	})
+}
 
-		// This area has some...fuzzy rules, but check all the imports for
-		// local/relative/dot-ness, and record an error for the package if we
-		// see any.
-		var lim []string
-		for _, imp := range append(pkg.Imports, pkg.TestImports...) {
-			if build.IsLocalImport(imp) {
-				// Do allow the single-dot, at least for now
-				if imp == "." {
-					continue
-				}
-				lim = append(lim, imp)
+// readPackage adds the package in the directory wp, below fileRoot, to ptree.
+// filepath.SkipDir is returned if the directory cannot be read.
+func readPackage(ptree PackageTree, fileRoot, wp string) error {
+	importRoot := ptree.ImportRoot
+	{
+		// For Go 1.9 and earlier:
+		//
+		// The entry error is nil when visiting a directory that itself is
+		// untraversable, as it's still governed by the parent directory's
+		// perms. We have to check readability of the dir here, because
+		// otherwise we'll have an empty package entry when we fail to read any
+		// of the dir's contents.
+		//
+		// If we didn't check here, then the next time this closure is called it
+		// would have an err with the same path as is called this time, as only
+		// then will filepath.Walk have attempted to descend into the directory
+		// and encountered an error.
+		f, err := os.Open(wp)
+		if err != nil {
+			if os.IsPermission(err) {
+				return filepath.SkipDir
 			}
+			return err
 		}
+		f.Close()
+	}
 
-		if len(lim) > 0 {
-			ptree.Packages[ip] = PackageOrErr{
-				Err: &LocalImportsError{
-					Dir:          wp,
-					ImportPath:   ip,
-					LocalImports: lim,
-				},
-			}
-		} else {
+	// Compute the import path. Run the result through ToSlash(), so that
+	// windows file paths are normalized to slashes, as is expected of
+	// import paths.
+	ip := filepath.ToSlash(filepath.Join(importRoot, strings.TrimPrefix(wp, fileRoot)))
+
+	// Find all the imports, across all os/arch combos
+	p := &build.Package{
+		Dir:        wp,
+		ImportPath: ip,
+	}
+	err := fillPackage(p)
+
+	if err != nil {
+		switch err.(type) {
+		case gscan.ErrorList, *gscan.Error, *build.NoGoError, *ConflictingImportComments:
+			// Assorted cases in which we've encounter malformed or
+			// nonexistent Go source code.
 			ptree.Packages[ip] = PackageOrErr{
-				P: pkg,
+				Err: err,
 			}
+			return nil
+		default:
+			return err
 		}
+	}
+
+	pkg := Package{
+		ImportPath:  ip,
+		CommentPath: p.ImportComment,
+		Name:        p.Name,
+		Imports:     p.Imports,
+		TestImports: dedupeStrings(p.TestImports, p.XTestImports),
+	}
 
+	if pkg.CommentPath != "" && !strings.HasPrefix(pkg.CommentPath, importRoot) {
+		ptree.Packages[ip] = PackageOrErr{
+			Err: &NonCanonicalImportRoot{
+				ImportRoot: importRoot,
+				Canonical:  pkg.CommentPath,
+			},
+		}
 		return nil
-	})
+	}
 
-	if err != nil {
-		return PackageTree{}, err
+	// This area has some...fuzzy rules, but check all the imports for
+	// local/relative/dot-ness, and record an error for the package if we
+	// see any.
+	var lim []string
+	for _, imp := range append(pkg.Imports, pkg.TestImports...) {
+		if build.IsLocalImport(imp) {
+			// Do allow the single-dot, at least for now
+			if imp == "." {
+				continue
+			}
+			lim = append(lim, imp)
+		}
 	}
 
-	return ptree, nil
+	if len(lim) > 0 {
+		ptree.Packages[ip] = PackageOrErr{
+			Err: &LocalImportsError{
+				Dir:          wp,
+				ImportPath:   ip,
+				LocalImports: lim,
+			},
+		}
+	} else {
+		ptree.Packages[ip] = PackageOrErr{
+			P: pkg,
+		}
+	}
+
+	return nil
 }
 
 // fillPackage full of info. Assumes p.Dir is set at a minimum
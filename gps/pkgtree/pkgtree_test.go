@@ -1510,6 +1510,61 @@ func TestListPackages(t *testing.T) {
 }
 
 // Transform Table Test that operates solely on the varied_hidden fixture.
+func TestPackageTreeUpdate(t *testing.T) {
+	root, err := ioutil.TempDir("", "pkgtree-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	write := func(rel, src string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("root.go", "package root\n\nimport _ \"example.com/root/a\"\n")
+	write("a/a.go", "package a\n")
+	write("a/sub/sub.go", "package sub\n")
+	write("gone/gone.go", "package gone\n")
+	write("gone/deeper/deeper.go", "package deeper\n")
+
+	ptree, err := ListPackages(root, "example.com/root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := ptree.Copy()
+
+	write("a/a.go", "package a\n\nimport _ \"github.com/foo/bar\"\n")
+	write("added/nested/nested.go", "package nested\n")
+	write("vendor/github.com/foo/bar/bar.go", "package bar\n")
+	if err := os.RemoveAll(filepath.Join(root, "gone")); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := ptree.Update(root, filepath.Join(root, "a"), "added", "gone", filepath.Join("vendor", "github.com", "foo", "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ListPackages(root, "example.com/root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(updated, want) {
+		t.Errorf("expected the updated tree to match a fresh listing:\n\t(GOT): %#v\n\t(WNT): %#v", updated, want)
+	}
+	if !reflect.DeepEqual(ptree, before) {
+		t.Error("expected the original tree to be left unchanged")
+	}
+
+	if _, err := ptree.Update(root, filepath.Dir(root)); err == nil {
+		t.Error("expected an error updating a directory outside the root")
+	}
+}
+
 func TestTrimHiddenPackages(t *testing.T) {
 	base, err := ListPackages(filepath.Join(getTestdataRootDir(t), "src", "varied_hidden"), "varied")
 	if err != nil {
@@ -0,0 +1,130 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProtocolPreferences sets, per host or per project, the protocol over which
+// the upstreams of sources are reached, in place of those deduced for them.
+// Keys are either project roots, such as "github.com/foo/bar", which also
+// match the project roots below them, or host patterns, as in NetworkPolicy.
+// Values are URL schemes: "ssh", "https", "http" or "git".
+//
+// The longest project root key matching a project takes precedence, then the
+// host keys. Only the candidate sources deduced for a project that use the
+// preferred scheme are tried; if there are none, the first candidate is tried
+// over the preferred scheme instead.
+type ProtocolPreferences map[string]string
+
+// Validate returns an error if any of the preferences names an unknown scheme.
+func (p ProtocolPreferences) Validate() error {
+	for key, scheme := range p {
+		switch scheme {
+		case "ssh", "https", "http", "git":
+		default:
+			return errors.Errorf("unknown protocol %q for %s, expected ssh, https, http or git", scheme, key)
+		}
+	}
+	return nil
+}
+
+// schemeFor returns the preferred scheme for the project root pr, whose
+// source is on host, or the empty string if there is none.
+func (p ProtocolPreferences) schemeFor(pr, host string) string {
+	var best, scheme string
+	for key, s := range p {
+		if strings.Contains(key, "/") && (pr == key || strings.HasPrefix(pr, key+"/")) && len(key) > len(best) {
+			best, scheme = key, s
+		}
+	}
+	if scheme != "" {
+		return scheme
+	}
+
+	for key, s := range p {
+		if !strings.Contains(key, "/") && hostMatches(key, strings.ToLower(host)) {
+			return s
+		}
+	}
+	return ""
+}
+
+// apply narrows mb, the candidate sources deduced for the project root pr, to
+// those using the preferred scheme, if there is one.
+func (p ProtocolPreferences) apply(pr string, mb maybeSources) maybeSources {
+	if len(p) == 0 || len(mb) == 0 {
+		return mb
+	}
+	first := upstreamURL(mb[0])
+	if first == nil {
+		return mb
+	}
+	scheme := p.schemeFor(pr, first.Hostname())
+	if scheme == "" {
+		return mb
+	}
+
+	var out maybeSources
+	for _, m := range mb {
+		if u := upstreamURL(m); u != nil && u.Scheme == scheme {
+			out = append(out, m)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	return maybeSources{withScheme(mb[0], scheme)}
+}
+
+// upstreamURL returns the URL of the upstream of m, or nil if m has none.
+func upstreamURL(m maybeSource) *url.URL {
+	switch m := m.(type) {
+	case maybeGitSource:
+		return m.url
+	case maybeHgSource:
+		return m.url
+	case maybeBzrSource:
+		return m.url
+	case maybeGopkginSource:
+		return m.url
+	}
+	return nil
+}
+
+// withScheme returns m, reaching its upstream over scheme instead. Over ssh,
+// the user defaults to git; the user is dropped for the other schemes, so
+// that credentials are found as they are for any other URL on the host.
+func withScheme(m maybeSource, scheme string) maybeSource {
+	u := *upstreamURL(m)
+	u.Scheme = scheme
+	if scheme == "ssh" {
+		if u.User == nil {
+			u.User = url.User("git")
+		}
+	} else {
+		u.User = nil
+	}
+
+	switch m := m.(type) {
+	case maybeGitSource:
+		m.url = &u
+		return m
+	case maybeHgSource:
+		m.url = &u
+		return m
+	case maybeBzrSource:
+		m.url = &u
+		return m
+	case maybeGopkginSource:
+		m.url = &u
+		return m
+	}
+	return m
+}
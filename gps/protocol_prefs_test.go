@@ -0,0 +1,74 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestProtocolPreferencesApply(t *testing.T) {
+	var deduced maybeSources
+	for _, scheme := range gitSchemes {
+		u := &url.URL{Scheme: scheme, Host: "github.com", Path: "/corp/app"}
+		if scheme == "ssh" {
+			u.User = url.User("git")
+		}
+		deduced = append(deduced, maybeGitSource{url: u})
+	}
+	vanity := maybeSources{maybeGitSource{url: mkurl("https://git.corp.example.com/tools/gen")}}
+
+	prefs := ProtocolPreferences{
+		"github.com":            "ssh",
+		"github.com/corp":       "git",
+		"github.com/corp/app":   "https",
+		"*.corp.example.com":    "ssh",
+		"github.com/corp/other": "http",
+	}
+
+	cases := []struct {
+		pr   string
+		mb   maybeSources
+		want []string
+	}{
+		// The longest matching project root wins.
+		{"github.com/corp/app", deduced, []string{"https://github.com/corp/app"}},
+		{"github.com/corp/app/sub", deduced, []string{"https://github.com/corp/app"}},
+		{"github.com/corp/lib", deduced, []string{"git://github.com/corp/app"}},
+		// Then the host.
+		{"github.com/someone/else", deduced, []string{"ssh://git@github.com/corp/app"}},
+		// A candidate over the preferred scheme is made up if need be.
+		{"git.corp.example.com/tools/gen", vanity, []string{"ssh://git@git.corp.example.com/tools/gen"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, m := range prefs.apply(c.pr, c.mb) {
+			got = append(got, m.URL().String())
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.pr, c.want, got)
+		}
+	}
+
+	// Without a matching preference, every candidate is kept.
+	if got := (ProtocolPreferences{"gitlab.com": "ssh"}).apply("github.com/corp/app", deduced); !reflect.DeepEqual(got, deduced) {
+		t.Errorf("expected the deduced candidates to be kept, got %v", got)
+	}
+	// Switching away from ssh drops the ssh user.
+	ssh := maybeSources{maybeGitSource{url: mkurl("ssh://git@git.corp.example.com/tools/gen")}}
+	if got := (ProtocolPreferences{"git.corp.example.com": "https"}).apply("git.corp.example.com/tools/gen", ssh); got[0].URL().String() != "https://git.corp.example.com/tools/gen" {
+		t.Errorf("expected the source to be reached over https, got %v", got[0].URL())
+	}
+}
+
+func TestProtocolPreferencesValidate(t *testing.T) {
+	if err := (ProtocolPreferences{"github.com": "ssh", "gitlab.com": "https"}).Validate(); err != nil {
+		t.Errorf("expected known schemes to be valid, got %v", err)
+	}
+	if err := (ProtocolPreferences{"github.com": "api"}).Validate(); err == nil {
+		t.Error("expected an unknown scheme to be invalid")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProxyDirect is the proxy that a host is mapped to in ProxyConfig.Hosts to
+// reach it without any proxy.
+const ProxyDirect = "direct"
+
+// ProxyConfig selects the proxy through which the SourceMgr reaches a host,
+// both for the HTTP requests it makes itself, such as for go-get metadata, and
+// for the version control commands it runs to reach sources over HTTP(S). The
+// zero value leaves the choice to the standard environment variables,
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+type ProxyConfig struct {
+	// Hosts maps host patterns, in the same form as in NetworkPolicy, to the
+	// URL of the proxy for the hosts they match, such as
+	// "http://proxy.example.com:3128", or to ProxyDirect. Host names take
+	// precedence over wildcards, and longer wildcards over shorter ones.
+	Hosts map[string]string
+	// PAC, if set, chooses the proxy for the hosts that match no pattern in
+	// Hosts.
+	PAC *PAC
+}
+
+func (c ProxyConfig) empty() bool {
+	return len(c.Hosts) == 0 && c.PAC == nil
+}
+
+// ProxyFor returns the URL of the proxy through which u is to be reached, or
+// nil if it is to be reached directly. If neither Hosts nor PAC chooses one,
+// the proxy is taken from the environment.
+func (c ProxyConfig) ProxyFor(u *url.URL) (*url.URL, error) {
+	proxy, chosen, err := c.choose(u)
+	if err != nil || chosen {
+		return proxy, err
+	}
+	return http.ProxyFromEnvironment(&http.Request{URL: u})
+}
+
+// choose returns the proxy that Hosts or PAC chooses for u, and whether either
+// of them made a choice at all.
+func (c ProxyConfig) choose(u *url.URL) (*url.URL, bool, error) {
+	host := strings.ToLower(u.Hostname())
+	best := ""
+	for pat := range c.Hosts {
+		if hostMatches(pat, host) && morePrecise(pat, best) {
+			best = pat
+		}
+	}
+	if best != "" {
+		proxy, err := parseProxy(c.Hosts[best])
+		return proxy, true, errors.Wrapf(err, "invalid proxy for %s", best)
+	}
+
+	if c.PAC == nil {
+		return nil, false, nil
+	}
+	proxy, err := c.PAC.FindProxy(u)
+	return proxy, true, err
+}
+
+// morePrecise reports whether host pattern a is more precise than b, the empty
+// pattern being the least precise of all.
+func morePrecise(a, b string) bool {
+	switch {
+	case b == "":
+		return true
+	case strings.HasPrefix(a, "*.") != strings.HasPrefix(b, "*."):
+		return !strings.HasPrefix(a, "*.")
+	}
+	return len(a) > len(b)
+}
+
+// parseProxy parses the URL of a proxy, as given in ProxyConfig.Hosts,
+// returning nil for ProxyDirect. A URL without a scheme is taken to be that of
+// an HTTP proxy.
+func parseProxy(s string) (*url.URL, error) {
+	if strings.EqualFold(s, ProxyDirect) {
+		return nil, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.Errorf("unsupported proxy scheme %q in %s", u.Scheme, s)
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("no host in proxy URL %s", s)
+	}
+	return u, nil
+}
+
+// ValidateProxy returns an error if s is neither the URL of a proxy that is
+// supported in ProxyConfig.Hosts, nor ProxyDirect.
+func ValidateProxy(s string) error {
+	_, err := parseProxy(s)
+	return err
+}
+
+// commandEnv returns the environment variables that route the HTTP(S) traffic
+// of version control commands to u through the proxy chosen for it, or none
+// if no proxy was chosen other than that of the environment.
+func (c ProxyConfig) commandEnv(u *url.URL) ([]string, error) {
+	proxy, chosen, err := c.choose(u)
+	if err != nil || !chosen {
+		return nil, errors.Wrapf(err, "could not choose a proxy for %s", u)
+	}
+
+	if proxy == nil {
+		return []string{"NO_PROXY=*", "no_proxy=*"}, nil
+	}
+	p := proxy.String()
+	return []string{
+		"HTTP_PROXY=" + p, "http_proxy=" + p,
+		"HTTPS_PROXY=" + p, "https_proxy=" + p,
+		"ALL_PROXY=" + p, "all_proxy=" + p,
+		"NO_PROXY=", "no_proxy=",
+	}, nil
+}
@@ -0,0 +1,620 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// PAC is a parsed proxy auto-config file, which chooses proxies with its
+// FindProxyForURL(url, host) function.
+//
+// PAC files are JavaScript, but only the subset that such files are commonly
+// written in is supported: var declarations, if and else, return, string
+// literals joined with +, the comparison and logical operators, and calls to
+// the PAC functions isPlainHostName, dnsDomainIs, localHostOrDomainIs,
+// shExpMatch, isResolvable, isInNet and dnsResolve. Any other construct is
+// rejected by ParsePAC, rather than being misread.
+type PAC struct {
+	params [2]string
+	body   []pacStmt
+}
+
+// ParsePAC parses the source of a PAC file.
+func ParsePAC(src []byte) (*PAC, error) {
+	toks, err := pacTokenize(string(src))
+	if err != nil {
+		return nil, err
+	}
+	p := &pacParser{toks: toks}
+	pac, err := p.file()
+	if err != nil {
+		return nil, errors.Wrap(err, "unsupported PAC file")
+	}
+	return pac, nil
+}
+
+// FindProxy evaluates FindProxyForURL for u, returning the URL of the first
+// proxy in the result, or nil if the first is DIRECT, or the result is empty.
+// The later entries of the result, which browsers fail over to, are not used.
+func (pac *PAC) FindProxy(u *url.URL) (*url.URL, error) {
+	vars := map[string]pacValue{
+		pac.params[0]: u.String(),
+		pac.params[1]: strings.ToLower(u.Hostname()),
+	}
+	res, _, err := pacExec(pac.body, vars)
+	if err != nil {
+		return nil, errors.Wrap(err, "evaluating the PAC file failed")
+	}
+	s, _ := res.(string)
+	return parsePACResult(s)
+}
+
+// parsePACResult parses the first entry of the result of FindProxyForURL, such
+// as "PROXY proxy.example.com:3128; DIRECT".
+func parsePACResult(res string) (*url.URL, error) {
+	first := strings.TrimSpace(strings.SplitN(res, ";", 2)[0])
+	fields := strings.Fields(first)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	scheme := ""
+	switch strings.ToUpper(fields[0]) {
+	case "DIRECT":
+		return nil, nil
+	case "PROXY", "HTTP":
+		scheme = "http"
+	case "HTTPS":
+		scheme = "https"
+	case "SOCKS", "SOCKS5":
+		scheme = "socks5"
+	default:
+		return nil, errors.Errorf("unsupported proxy %q returned by the PAC file", first)
+	}
+	if len(fields) != 2 {
+		return nil, errors.Errorf("malformed proxy %q returned by the PAC file", first)
+	}
+	return &url.URL{Scheme: scheme, Host: fields[1]}, nil
+}
+
+// pacValue is either a string or a bool. Values compare as strings do in
+// JavaScript, null being the empty string.
+type pacValue interface{}
+
+func pacTruthy(v pacValue) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+func pacString(v pacValue) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case string:
+		return v
+	}
+	return ""
+}
+
+type pacStmt interface{}
+
+type (
+	pacIf struct {
+		cond      pacExpr
+		then, els []pacStmt
+	}
+	pacReturn struct{ value pacExpr }
+	pacVar    struct {
+		name  string
+		value pacExpr
+	}
+)
+
+type pacExpr interface{}
+
+type (
+	pacLit   struct{ value pacValue }
+	pacIdent struct{ name string }
+	pacCall  struct {
+		fn   string
+		args []pacExpr
+	}
+	pacUnary  struct{ x pacExpr }
+	pacBinary struct {
+		op   string
+		x, y pacExpr
+	}
+)
+
+// pacFuncs are the PAC functions that are supported, by their number of
+// arguments.
+var pacFuncs = map[string]int{
+	"isPlainHostName":     1,
+	"dnsDomainIs":         2,
+	"localHostOrDomainIs": 2,
+	"shExpMatch":          2,
+	"isResolvable":        1,
+	"isInNet":             3,
+	"dnsResolve":          1,
+}
+
+// pacExec executes stmts, returning the value of the return statement reached,
+// if any.
+func pacExec(stmts []pacStmt, vars map[string]pacValue) (pacValue, bool, error) {
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case pacIf:
+			cond, err := pacEval(s.cond, vars)
+			if err != nil {
+				return nil, false, err
+			}
+			branch := s.els
+			if pacTruthy(cond) {
+				branch = s.then
+			}
+			if v, done, err := pacExec(branch, vars); done || err != nil {
+				return v, done, err
+			}
+		case pacReturn:
+			v, err := pacEval(s.value, vars)
+			return v, true, err
+		case pacVar:
+			v, err := pacEval(s.value, vars)
+			if err != nil {
+				return nil, false, err
+			}
+			vars[s.name] = v
+		}
+	}
+	return nil, false, nil
+}
+
+func pacEval(e pacExpr, vars map[string]pacValue) (pacValue, error) {
+	switch e := e.(type) {
+	case pacLit:
+		return e.value, nil
+	case pacIdent:
+		v, has := vars[e.name]
+		if !has {
+			return nil, errors.Errorf("%s is not defined", e.name)
+		}
+		return v, nil
+	case pacUnary:
+		v, err := pacEval(e.x, vars)
+		return !pacTruthy(v), err
+	case pacBinary:
+		x, err := pacEval(e.x, vars)
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "&&":
+			if !pacTruthy(x) {
+				return false, nil
+			}
+		case "||":
+			if pacTruthy(x) {
+				return true, nil
+			}
+		}
+		y, err := pacEval(e.y, vars)
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "&&", "||":
+			return pacTruthy(y), nil
+		case "==", "===":
+			return pacString(x) == pacString(y), nil
+		case "!=", "!==":
+			return pacString(x) != pacString(y), nil
+		default: // "+"
+			return pacString(x) + pacString(y), nil
+		}
+	case pacCall:
+		args := make([]string, len(e.args))
+		for i, a := range e.args {
+			v, err := pacEval(a, vars)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = pacString(v)
+		}
+		return pacCallFunc(e.fn, args), nil
+	}
+	return nil, errors.Errorf("unexpected expression %T", e)
+}
+
+func pacCallFunc(fn string, args []string) pacValue {
+	switch fn {
+	case "isPlainHostName":
+		return !strings.Contains(args[0], ".")
+	case "dnsDomainIs":
+		return strings.HasSuffix(strings.ToLower(args[0]), strings.ToLower(args[1]))
+	case "localHostOrDomainIs":
+		host, hostdom := strings.ToLower(args[0]), strings.ToLower(args[1])
+		return host == hostdom || (!strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+"."))
+	case "shExpMatch":
+		return shExpMatch(args[0], args[1])
+	case "isResolvable":
+		return pacResolve(args[0]) != ""
+	case "isInNet":
+		ip := net.ParseIP(pacResolve(args[0]))
+		pat, mask := net.ParseIP(args[1]).To4(), net.ParseIP(args[2]).To4()
+		if ip == nil || pat == nil || mask == nil || ip.To4() == nil {
+			return false
+		}
+		return ip.To4().Mask(net.IPMask(mask)).Equal(pat.Mask(net.IPMask(mask)))
+	default: // "dnsResolve"
+		return pacResolve(args[0])
+	}
+}
+
+// pacResolve returns the first IPv4 address of host, or the empty string, for
+// null, if it cannot be resolved.
+func pacResolve(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return ""
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// shExpMatch matches s against a shell expression, in which * matches any
+// sequence of characters, including dots and slashes, and ? any one.
+func shExpMatch(s, pat string) bool {
+	re := regexp.QuoteMeta(pat)
+	re = strings.Replace(re, `\*`, ".*", -1)
+	re = strings.Replace(re, `\?`, ".", -1)
+	ok, _ := regexp.MatchString("^"+re+"$", s)
+	return ok
+}
+
+// pacToken is a token of a PAC file: a string literal, with quoted set, an
+// identifier or keyword, or punctuation.
+type pacToken struct {
+	text   string
+	quoted bool
+}
+
+func pacTokenize(src string) ([]pacToken, error) {
+	var toks []pacToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment in PAC file")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			var b bytes.Buffer
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j == len(src) {
+				return nil, errors.New("unterminated string in PAC file")
+			}
+			toks = append(toks, pacToken{text: b.String(), quoted: true})
+			i = j + 1
+		case c == '_' || c == '$' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '$' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, pacToken{text: src[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"===", "!==", "==", "!=", "&&", "||"} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				if !strings.ContainsRune("(){},;!+=", rune(c)) {
+					return nil, errors.Errorf("unexpected character %q in PAC file", c)
+				}
+				op = string(c)
+			}
+			toks = append(toks, pacToken{text: op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+type pacParser struct {
+	toks []pacToken
+	pos  int
+}
+
+func (p *pacParser) peek() pacToken {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return pacToken{}
+}
+
+// is reports whether the next token is the unquoted text s.
+func (p *pacParser) is(s string) bool {
+	t := p.peek()
+	return !t.quoted && t.text == s
+}
+
+func (p *pacParser) expect(s string) error {
+	if !p.is(s) {
+		return p.unexpected()
+	}
+	p.pos++
+	return nil
+}
+
+func (p *pacParser) unexpected() error {
+	if p.pos >= len(p.toks) {
+		return errors.New("unexpected end of file")
+	}
+	return errors.Errorf("unexpected %q", p.peek().text)
+}
+
+func (p *pacParser) ident() (string, error) {
+	t := p.peek()
+	if t.quoted || t.text == "" || !(t.text[0] == '_' || t.text[0] == '$' || unicode.IsLetter(rune(t.text[0]))) {
+		return "", p.unexpected()
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// file parses the whole of a PAC file, which must define FindProxyForURL, and
+// nothing else.
+func (p *pacParser) file() (*PAC, error) {
+	pac := &PAC{}
+	for _, s := range []string{"function", "FindProxyForURL", "("} {
+		if err := p.expect(s); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if pac.params[0], err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	if pac.params[1], err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if pac.body, err = p.block(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, p.unexpected()
+	}
+	return pac, nil
+}
+
+func (p *pacParser) block() ([]pacStmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []pacStmt
+	for !p.is("}") {
+		if p.pos >= len(p.toks) {
+			return nil, p.unexpected()
+		}
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s...)
+	}
+	p.pos++
+	return stmts, nil
+}
+
+// stmt parses a statement, or a block, returning the statements in it.
+func (p *pacParser) stmt() ([]pacStmt, error) {
+	switch {
+	case p.is("{"):
+		return p.block()
+	case p.is(";"):
+		p.pos++
+		return nil, nil
+	case p.is("if"):
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		s := pacIf{cond: cond}
+		if s.then, err = p.stmt(); err != nil {
+			return nil, err
+		}
+		if p.is("else") {
+			p.pos++
+			if s.els, err = p.stmt(); err != nil {
+				return nil, err
+			}
+		}
+		return []pacStmt{s}, nil
+	case p.is("return"):
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		p.skip(";")
+		return []pacStmt{pacReturn{value: v}}, nil
+	case p.is("var"):
+		p.pos++
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		p.skip(";")
+		return []pacStmt{pacVar{name: name, value: v}}, nil
+	}
+	return nil, p.unexpected()
+}
+
+// skip consumes the next token if it is the unquoted text s, such as the
+// optional semicolon ending a statement.
+func (p *pacParser) skip(s string) {
+	if p.is(s) {
+		p.pos++
+	}
+}
+
+// expr parses an expression, with the operators in order of increasing
+// precedence: ||, &&, the comparisons, +, and !.
+func (p *pacParser) expr() (pacExpr, error) {
+	return p.binary(0)
+}
+
+var pacPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "===", "!=", "!=="},
+	{"+"},
+}
+
+func (p *pacParser) binary(level int) (pacExpr, error) {
+	if level == len(pacPrecedence) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range pacPrecedence[level] {
+			if p.is(o) {
+				op = o
+			}
+		}
+		if op == "" {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = pacBinary{op: op, x: x, y: y}
+	}
+}
+
+func (p *pacParser) unary() (pacExpr, error) {
+	if p.is("!") {
+		p.pos++
+		x, err := p.unary()
+		return pacUnary{x: x}, err
+	}
+	if p.is("(") {
+		p.pos++
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+
+	t := p.peek()
+	if t.quoted {
+		p.pos++
+		return pacLit{value: t.text}, nil
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "true", "false":
+		return pacLit{value: name == "true"}, nil
+	case "null":
+		return pacLit{value: ""}, nil
+	}
+	if !p.is("(") {
+		return pacIdent{name: name}, nil
+	}
+
+	n, has := pacFuncs[name]
+	if !has {
+		return nil, errors.Errorf("%s is not a supported function", name)
+	}
+	p.pos++
+	call := pacCall{fn: name}
+	for !p.is(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		a, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, a)
+	}
+	p.pos++
+	if len(call.args) != n {
+		return nil, errors.Errorf("%s takes %d argument(s), not %d", name, n, len(call.args))
+	}
+	return call, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const testPAC = `
+// Internal hosts are reached directly.
+function FindProxyForURL(url, host) {
+	var corp = ".corp.example.com";
+	if (isPlainHostName(host) || dnsDomainIs(host, corp))
+		return "DIRECT";
+	else if (shExpMatch(url, "https://github.com/corp/*")) {
+		return 'PROXY ' + "github-proxy.example.com:8080; DIRECT";
+	}
+	/* Everything else goes out through the default proxy. */
+	return "PROXY proxy.example.com:3128";
+}
+`
+
+func TestProxyConfigProxyFor(t *testing.T) {
+	pac, err := ParsePAC([]byte(testPAC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ProxyConfig{
+		Hosts: map[string]string{
+			"*.example.com":       "http://example-proxy:3128",
+			"*.bar.example.com":   "socks5://bar-proxy:1080",
+			"git.bar.example.com": ProxyDirect,
+			"gitlab.com":          "gitlab-proxy:3128",
+		},
+		PAC: pac,
+	}
+
+	cases := map[string]string{
+		"https://foo.example.com/x":        "http://example-proxy:3128",
+		"https://foo.bar.example.com/x":    "socks5://bar-proxy:1080",
+		"https://git.bar.example.com/x":    "",
+		"https://gitlab.com/x":             "http://gitlab-proxy:3128",
+		"https://git.corp.example.com/x":   "http://example-proxy:3128",
+		"https://github.com/corp/repo":     "http://github-proxy.example.com:8080",
+		"https://github.com/other/repo":    "http://proxy.example.com:3128",
+		"http://intranet/repo":             "",
+		"https://GIT.BAR.EXAMPLE.COM:443/": "",
+	}
+	for in, want := range cases {
+		u, _ := url.Parse(in)
+		proxy, err := c.ProxyFor(u)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", in, err)
+			continue
+		}
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != want {
+			t.Errorf("%s: expected proxy %q, got %q", in, want, got)
+		}
+	}
+
+	// Without a PAC file, hosts that match no pattern are left to the
+	// environment.
+	c.PAC = nil
+	u, _ := url.Parse("https://github.com/corp/repo")
+	if _, chosen, _ := c.choose(u); chosen {
+		t.Error("expected no proxy to be chosen for a host matching no pattern")
+	}
+}
+
+func TestParsePACUnsupported(t *testing.T) {
+	for _, src := range []string{
+		`function FindProxyForURL(url, host) { return myIpAddress(); }`,
+		`function FindProxyForURL(url, host) { for (;;) {} }`,
+		`function FindProxyForURL(url, host) { return "DIRECT"; } function helper() {}`,
+		`function FindProxyForURL(url, host) { return shExpMatch(host); }`,
+		`function FindProxyForURL(url, host) { return "DIRECT"`,
+	} {
+		if _, err := ParsePAC([]byte(src)); err == nil {
+			t.Errorf("expected %q to be rejected", src)
+		}
+	}
+
+	pac, err := ParsePAC([]byte(`function FindProxyForURL(url, host) { return "FTP proxy:21"; }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://github.com/foo/bar")
+	if _, err := pac.FindProxy(u); err == nil {
+		t.Error("expected an unsupported kind of proxy to be reported")
+	}
+}
+
+func TestProxyEnv(t *testing.T) {
+	sup := newSupervisor(context.Background())
+	sup.upstream = newUpstreamSettings(ProxyConfig{Hosts: map[string]string{
+		"github.com":         "http://proxy:3128",
+		"*.corp.example.com": ProxyDirect,
+	}}, nil)
+
+	ctx, err := sup.withUpstream(context.Background(), "https://github.com/foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpClient(ctx) != sup.upstream.client {
+		t.Error("expected the client of the proxy settings to be used")
+	}
+	env := upstreamEnv(ctx)
+	if len(env) == 0 || env[0] != "HTTP_PROXY=http://proxy:3128" {
+		t.Errorf("expected the proxy to be set for commands, got %v", env)
+	}
+	c := commandContext(ctx, "git", "version")
+	c.SetEnv([]string{"HTTPS_PROXY=http://other:3128"})
+	if got := c.Cmd.Env[1:]; !reflect.DeepEqual(got, env) {
+		t.Errorf("expected the proxy to take precedence over the command's own environment, got %v", c.Cmd.Env)
+	}
+
+	// Calls made within the gateway keep the proxy of its upstream.
+	if inner, _ := sup.withUpstream(ctx, ""); !reflect.DeepEqual(upstreamEnv(inner), env) {
+		t.Errorf("expected the proxy to be kept for the calls within, got %v", upstreamEnv(inner))
+	}
+
+	for _, rawurl := range []string{"https://git.corp.example.com/foo", "ssh://git@github.com/foo/bar"} {
+		ctx, err := sup.withUpstream(context.Background(), rawurl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string(nil)
+		if rawurl == "https://git.corp.example.com/foo" {
+			want = []string{"NO_PROXY=*", "no_proxy=*"}
+		}
+		if got := upstreamEnv(ctx); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", rawurl, want, got)
+		}
+	}
+}
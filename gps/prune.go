@@ -12,7 +12,8 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/golang/dep/internal/fs"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/vfs"
 	"github.com/pkg/errors"
 )
 
@@ -54,9 +55,19 @@ type PruneOptionSet struct {
 // The DefaultOptions are the global default pruning rules, expressed as a
 // single PruneOptions bitfield. These global rules will cascade down to
 // individual project rules, unless superseded.
+//
+// Assets holds, for individual projects, the patterns of the files their
+// packages read at runtime, which are kept whatever the pruning rules. See
+// pkgtree.AssetRuleset for the syntax of the patterns.
+//
+// Generated holds, for individual projects, the patterns of their generated
+// files, which are left out of the digests of their vendored trees, in the
+// same syntax. They do not affect pruning.
 type CascadingPruneOptions struct {
 	DefaultOptions    PruneOptions
 	PerProjectOptions map[ProjectRoot]PruneOptionSet
+	Assets            map[ProjectRoot][]string
+	Generated         map[ProjectRoot][]string
 }
 
 // ParsePruneOptions extracts PruneOptions from a string using the standard
@@ -147,6 +158,22 @@ func (o CascadingPruneOptions) PruneOptionsFor(pr ProjectRoot) PruneOptions {
 	return ops
 }
 
+// AssetProject is an optional interface for LockedProjects that declare the
+// files their packages read at runtime, as patterns relative to the project
+// root. Matching files are never pruned.
+type AssetProject interface {
+	LockedProject
+	AssetPatterns() []string
+}
+
+// assetsOf returns the ruleset for the assets declared by lp, if any.
+func assetsOf(lp LockedProject) *pkgtree.AssetRuleset {
+	if ap, ok := lp.(AssetProject); ok {
+		return pkgtree.NewAssetRuleset(ap.AssetPatterns())
+	}
+	return nil
+}
+
 func defaultCascadingPruneOptions() CascadingPruneOptions {
 	return CascadingPruneOptions{
 		DefaultOptions:    PruneNestedVendorDirs,
@@ -181,37 +208,44 @@ var (
 // PruneProject remove excess files according to the options passed, from
 // the lp directory in baseDir.
 func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error {
-	fsState, err := deriveFilesystemState(baseDir)
+	return PruneProjectFS(vfs.OS, baseDir, lp, options)
+}
+
+// PruneProjectFS is like PruneProject, but removes the excess files from
+// baseDir in fsys.
+func PruneProjectFS(fsys vfs.FS, baseDir string, lp LockedProject, options PruneOptions) error {
+	fsState, err := deriveFilesystemStateFS(fsys, baseDir)
 
 	if err != nil {
 		return errors.Wrap(err, "could not derive filesystem state")
 	}
+	assets := assetsOf(lp)
 
 	if (options & PruneNestedVendorDirs) != 0 {
-		if err := pruneVendorDirs(fsState); err != nil {
+		if err := pruneVendorDirs(fsys, fsState); err != nil {
 			return errors.Wrapf(err, "failed to prune nested vendor directories")
 		}
 	}
 
 	if (options & PruneUnusedPackages) != 0 {
-		if _, err := pruneUnusedPackages(lp, fsState); err != nil {
+		if _, err := pruneUnusedPackages(fsys, lp, fsState, assets); err != nil {
 			return errors.Wrap(err, "failed to prune unused packages")
 		}
 	}
 
 	if (options & PruneNonGoFiles) != 0 {
-		if err := pruneNonGoFiles(fsState); err != nil {
+		if err := pruneNonGoFiles(fsys, fsState, assets); err != nil {
 			return errors.Wrap(err, "failed to prune non-Go files")
 		}
 	}
 
 	if (options & PruneGoTestFiles) != 0 {
-		if err := pruneGoTestFiles(fsState); err != nil {
+		if err := pruneGoTestFiles(fsys, fsState); err != nil {
 			return errors.Wrap(err, "failed to prune Go test files")
 		}
 	}
 
-	if err := deleteEmptyDirs(fsState); err != nil {
+	if err := deleteEmptyDirs(fsys, fsState); err != nil {
 		return errors.Wrap(err, "could not delete empty dirs")
 	}
 
@@ -219,10 +253,10 @@ func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error
 }
 
 // pruneVendorDirs deletes all nested vendor directories within baseDir.
-func pruneVendorDirs(fsState filesystemState) error {
+func pruneVendorDirs(fsys vfs.FS, fsState filesystemState) error {
 	for _, dir := range fsState.dirs {
 		if filepath.Base(dir) == "vendor" {
-			err := os.RemoveAll(filepath.Join(fsState.root, dir))
+			err := fsys.RemoveAll(filepath.Join(fsState.root, dir))
 			if err != nil && !os.IsNotExist(err) {
 				return err
 			}
@@ -231,7 +265,7 @@ func pruneVendorDirs(fsState filesystemState) error {
 
 	for _, link := range fsState.links {
 		if filepath.Base(link.path) == "vendor" {
-			err := os.Remove(filepath.Join(fsState.root, link.path))
+			err := fsys.Remove(filepath.Join(fsState.root, link.path))
 			if err != nil && !os.IsNotExist(err) {
 				return err
 			}
@@ -241,14 +275,15 @@ func pruneVendorDirs(fsState filesystemState) error {
 	return nil
 }
 
-// pruneUnusedPackages deletes unimported packages found in fsState.
+// pruneUnusedPackages deletes unimported packages found in fsState, except for
+// the files matching assets.
 // Determining whether packages are imported or not is based on the passed LockedProject.
-func pruneUnusedPackages(lp LockedProject, fsState filesystemState) (map[string]interface{}, error) {
+func pruneUnusedPackages(fsys vfs.FS, lp LockedProject, fsState filesystemState, assets *pkgtree.AssetRuleset) (map[string]interface{}, error) {
 	unusedPackages := calculateUnusedPackages(lp, fsState)
-	toDelete := collectUnusedPackagesFiles(fsState, unusedPackages)
+	toDelete := collectUnusedPackagesFiles(fsState, unusedPackages, assets)
 
 	for _, path := range toDelete {
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		if err := fsys.Remove(path); err != nil && !os.IsNotExist(err) {
 			return nil, err
 		}
 	}
@@ -282,14 +317,14 @@ func calculateUnusedPackages(lp LockedProject, fsState filesystemState) map[stri
 }
 
 // collectUnusedPackagesFiles returns a slice of all files in the unused
-// packages based on fsState.
-func collectUnusedPackagesFiles(fsState filesystemState, unusedPackages map[string]interface{}) []string {
+// packages based on fsState, leaving out those matching assets.
+func collectUnusedPackagesFiles(fsState filesystemState, unusedPackages map[string]interface{}, assets *pkgtree.AssetRuleset) []string {
 	// TODO(ibrasho): is this useful?
 	files := make([]string, 0, len(unusedPackages))
 
 	for _, path := range fsState.files {
 		// Keep preserved files.
-		if isPreservedFile(filepath.Base(path)) {
+		if isPreservedFile(filepath.Base(path)) || assets.IsAsset(filepath.ToSlash(path)) {
 			continue
 		}
 
@@ -336,8 +371,9 @@ func isSourceFile(path string) bool {
 
 // pruneNonGoFiles delete all non-Go files existing in fsState.
 //
-// Files matching licenseFilePrefixes and legalFileSubstrings are not pruned.
-func pruneNonGoFiles(fsState filesystemState) error {
+// Files matching licenseFilePrefixes and legalFileSubstrings are not pruned,
+// nor are those matching assets.
+func pruneNonGoFiles(fsys vfs.FS, fsState filesystemState, assets *pkgtree.AssetRuleset) error {
 	toDelete := make([]string, 0, len(fsState.files)/4)
 
 	for _, path := range fsState.files {
@@ -345,8 +381,8 @@ func pruneNonGoFiles(fsState filesystemState) error {
 			continue
 		}
 
-		// Ignore preserved files.
-		if isPreservedFile(filepath.Base(path)) {
+		// Ignore preserved files and assets.
+		if isPreservedFile(filepath.Base(path)) || assets.IsAsset(filepath.ToSlash(path)) {
 			continue
 		}
 
@@ -354,7 +390,7 @@ func pruneNonGoFiles(fsState filesystemState) error {
 	}
 
 	for _, path := range toDelete {
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		if err := fsys.Remove(path); err != nil && !os.IsNotExist(err) {
 			return err
 		}
 	}
@@ -388,7 +424,7 @@ func isPreservedFile(name string) bool {
 }
 
 // pruneGoTestFiles deletes all Go test files (*_test.go) in fsState.
-func pruneGoTestFiles(fsState filesystemState) error {
+func pruneGoTestFiles(fsys vfs.FS, fsState filesystemState) error {
 	toDelete := make([]string, 0, len(fsState.files)/2)
 
 	for _, path := range fsState.files {
@@ -398,7 +434,7 @@ func pruneGoTestFiles(fsState filesystemState) error {
 	}
 
 	for _, path := range toDelete {
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		if err := fsys.Remove(path); err != nil && !os.IsNotExist(err) {
 			return err
 		}
 	}
@@ -406,19 +442,19 @@ func pruneGoTestFiles(fsState filesystemState) error {
 	return nil
 }
 
-func deleteEmptyDirs(fsState filesystemState) error {
+func deleteEmptyDirs(fsys vfs.FS, fsState filesystemState) error {
 	sort.Sort(sort.Reverse(sort.StringSlice(fsState.dirs)))
 
 	for _, dir := range fsState.dirs {
 		path := filepath.Join(fsState.root, dir)
 
-		notEmpty, err := fs.IsNonEmptyDir(path)
+		notEmpty, err := isNonEmptyDir(fsys, path)
 		if err != nil {
 			return err
 		}
 
 		if !notEmpty {
-			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			if err := fsys.Remove(path); err != nil && !os.IsNotExist(err) {
 				return err
 			}
 		}
@@ -427,6 +463,24 @@ func deleteEmptyDirs(fsState filesystemState) error {
 	return nil
 }
 
+// isNonEmptyDir determines if the path given is a non-empty directory in fsys.
+func isNonEmptyDir(fsys vfs.FS, name string) (bool, error) {
+	fi, err := fsys.Stat(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	} else if !fi.IsDir() {
+		return false, errors.Errorf("%q is not a directory", name)
+	}
+
+	fis, err := fsys.ReadDir(name)
+	if err != nil {
+		return false, err
+	}
+	return len(fis) > 0, nil
+}
+
 func fileExt(name string) string {
 	i := strings.LastIndex(name, ".")
 	if i < 0 {
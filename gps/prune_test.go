@@ -7,8 +7,11 @@ package gps
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/golang/dep/gps/vfs"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -126,6 +129,94 @@ func TestPruneProject(t *testing.T) {
 	}
 }
 
+func TestPruneProjectFS(t *testing.T) {
+	mfs := vfs.NewMemFS()
+	baseDir := filepath.FromSlash("/vendor/github.com/project/repository")
+	for _, f := range []string{"main.go", "main_test.go", "README.md", "LICENSE", "unused/unused.go", "pkg/pkg.go", "vendor/dep/dep.go"} {
+		if err := mfs.WriteFile(filepath.Join(baseDir, filepath.FromSlash(f)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mfs.Symlink("pkg", filepath.Join(baseDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	lp := lockedProject{
+		pi: ProjectIdentifier{
+			ProjectRoot: ProjectRoot("github.com/project/repository"),
+		},
+		pkgs: []string{".", "pkg"},
+	}
+	options := PruneNestedVendorDirs | PruneNonGoFiles | PruneGoTestFiles | PruneUnusedPackages
+
+	if err := PruneProjectFS(mfs, baseDir, lp, options); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := deriveFilesystemStateFS(mfs, baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filesystemState{
+		root:  baseDir,
+		dirs:  []string{"pkg"},
+		files: []string{"LICENSE", "main.go", filepath.Join("pkg", "pkg.go")},
+		links: []fsLink{{path: "link"}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected state after pruning:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
+
+type assetProject struct {
+	lockedProject
+	assets []string
+}
+
+func (ap assetProject) AssetPatterns() []string {
+	return ap.assets
+}
+
+func TestPruneProjectFSKeepsAssets(t *testing.T) {
+	mfs := vfs.NewMemFS()
+	baseDir := filepath.FromSlash("/vendor/github.com/project/repository")
+	for _, f := range []string{"main.go", "README.md", "templates/index.html", "templates/notes.txt", "testdata/schema.json", "unused/unused.go"} {
+		if err := mfs.WriteFile(filepath.Join(baseDir, filepath.FromSlash(f)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lp := assetProject{
+		lockedProject: lockedProject{
+			pi: ProjectIdentifier{
+				ProjectRoot: ProjectRoot("github.com/project/repository"),
+			},
+			pkgs: []string{"."},
+		},
+		assets: []string{"templates/*.html", "testdata"},
+	}
+	if err := PruneProjectFS(mfs, baseDir, lp, PruneNonGoFiles|PruneUnusedPackages); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := deriveFilesystemStateFS(mfs, baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filesystemState{
+		root: baseDir,
+		dirs: []string{"templates", "testdata"},
+		files: []string{
+			"main.go",
+			filepath.Join("templates", "index.html"),
+			filepath.Join("testdata", "schema.json"),
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected state after pruning:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
+
 func TestPruneUnusedPackages(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -249,7 +340,7 @@ func TestPruneUnusedPackages(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			_, err = pruneUnusedPackages(tc.lp, fs)
+			_, err = pruneUnusedPackages(vfs.OS, tc.lp, fs, nil)
 			if tc.err && err == nil {
 				t.Fatalf("expected an error, got nil")
 			} else if !tc.err && err != nil {
@@ -344,7 +435,7 @@ func TestPruneNonGoFiles(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			err = pruneNonGoFiles(fs)
+			err = pruneNonGoFiles(vfs.OS, fs, nil)
 			if tc.err && err == nil {
 				t.Errorf("expected an error, got nil")
 			} else if !tc.err && err != nil {
@@ -441,7 +532,7 @@ func TestPruneGoTestFiles(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			err = pruneGoTestFiles(fs)
+			err = pruneGoTestFiles(vfs.OS, fs)
 			if tc.err && err == nil {
 				t.Fatalf("expected an error, got nil")
 			} else if !tc.err && err != nil {
@@ -685,7 +776,7 @@ func pruneVendorDirsTestCase(tc fsTestCase) func(*testing.T) {
 			t.Fatalf("deriveFilesystemState failed: %s", err)
 		}
 
-		if err := pruneVendorDirs(fs); err != nil {
+		if err := pruneVendorDirs(vfs.OS, fs); err != nil {
 			t.Errorf("pruneVendorDirs err=%q", err)
 		}
 
@@ -779,7 +870,7 @@ func TestDeleteEmptyDirs(t *testing.T) {
 				t.Fatal("unexpected error in fs setup: ", err)
 			}
 
-			if err := deleteEmptyDirs(tc.fs.before); err != nil {
+			if err := deleteEmptyDirs(vfs.OS, tc.fs.before); err != nil {
 				t.Fatal("unexpected error in deleteEmptyDirs: ", err)
 			}
 
@@ -0,0 +1,69 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// RequiredConstraintsManifest is a RootManifest that also constrains the
+// versions of individual required packages, such as the main package of a code
+// generator, which may need pinning independently of a library from the same
+// repository.
+type RequiredConstraintsManifest interface {
+	RootManifest
+	// RequiredConstraints returns the constraints on the versions of required
+	// packages, by import path. Each of the packages must also be returned by
+	// RequiredPackages.
+	RequiredConstraints() map[string]Constraint
+}
+
+// constrainRequired adds the constraints on required packages in rc to those
+// that the root manifest places on the packages' projects.
+//
+// A project is only ever selected at one version, so a required package is
+// constrained independently of the rest of its repository only where the
+// layout of the repository makes it a project of its own, as when its import
+// path deduces to a project root of its own. Otherwise its constraint is
+// intersected with any on its project, and it is an error for the two to admit
+// no version in common. Overrides still take precedence over both.
+func (rd *rootdata) constrainRequired(rc map[string]Constraint, sm SourceManager) error {
+	pkgs := make([]string, 0, len(rc))
+	for pkg := range rc {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	if rd.rm.Deps == nil {
+		rd.rm.Deps = make(ProjectConstraints, len(pkgs))
+	}
+	for _, pkg := range pkgs {
+		if !rd.req[pkg] {
+			return badOptsFailure(fmt.Sprintf("a version was given for %s, which is not a required package", pkg))
+		}
+		if isPathPrefixOrEqual(string(rd.rpt.ImportRoot), pkg) {
+			return badOptsFailure(fmt.Sprintf("a version was given for the required package %s, which is part of the root project", pkg))
+		}
+		pr, err := sm.DeduceProjectRoot(pkg)
+		if err != nil {
+			return errors.Wrapf(err, "could not deduce the project root of the required package %s", pkg)
+		}
+
+		pp := rd.rm.Deps[pr]
+		if pp.Constraint == nil {
+			pp.Constraint = anyConstraint{}
+		}
+		c := pp.Constraint.Intersect(rc[pkg])
+		if c == none {
+			return badOptsFailure(fmt.Sprintf("the required package %s is constrained to %s, which no version of its project %s allowed by %s satisfies; a project can only be used at one version", pkg, rc[pkg], pr, pp.Constraint))
+		}
+		pp.Constraint = c
+		rd.rm.Deps[pr] = pp
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"testing"
+
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+func TestConstrainRequired(t *testing.T) {
+	sm := newdepspecSM([]depspec{
+		mkDepspec("root 0.0.0"),
+		mkDepspec("foo 1.0.0"),
+		mkDepspec("bar 1.0.0"),
+	}, nil)
+	mkrd := func() *rootdata {
+		return &rootdata{
+			req: map[string]bool{"foo/cmd/gen": true, "bar/cmd/tool": true, "root/cmd/x": true},
+			rm:  SimpleManifest{Deps: ProjectConstraints{"foo": {Constraint: mkSVC("^1.0.0")}}},
+			rpt: pkgtree.PackageTree{ImportRoot: "root"},
+		}
+	}
+
+	rd := mkrd()
+	err := rd.constrainRequired(map[string]Constraint{
+		"foo/cmd/gen":  mkSVC("~1.2.0"),
+		"bar/cmd/tool": NewVersion("v1.0.0"),
+	}, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := rd.rm.Deps["foo"].Constraint; c.String() != mkSVC("~1.2.0").String() {
+		t.Errorf("expected the pin to narrow the constraint on foo to ~1.2.0, got %s", c)
+	}
+	if c := rd.rm.Deps["bar"].Constraint; c == nil || !c.Matches(NewVersion("v1.0.0")) {
+		t.Errorf("expected the pin to constrain the otherwise unconstrained bar, got %v", c)
+	}
+
+	for name, rc := range map[string]map[string]Constraint{
+		"conflicting":  {"foo/cmd/gen": mkSVC("^2.0.0")},
+		"not required": {"foo/cmd/other": mkSVC("^1.0.0")},
+		"root package": {"root/cmd/x": mkSVC("^1.0.0")},
+	} {
+		if err := mkrd().constrainRequired(rc, sm); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
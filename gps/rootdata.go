@@ -34,6 +34,39 @@ type rootdata struct {
 	// for lock.
 	chngall bool
 
+	// A map of the projects in the root lock that must be kept at their locked
+	// versions.
+	frozen map[ProjectRoot]bool
+
+	// Constraints matching versions of projects that must not be selected.
+	excl map[ProjectRoot][]Constraint
+
+	// How strongly to prefer versions from the root lock for projects not
+	// marked for change.
+	lockpref LockPreference
+
+	// The order in which to try the versions of locked projects that are
+	// allowed to change.
+	updstrat UpdateStrategy
+
+	// The most attempts the solver may make, or zero for no limit.
+	maxatt int
+
+	// Whether independent groups of dependencies may be solved concurrently.
+	parallel bool
+
+	// Whether to record why each version was selected.
+	recdec bool
+
+	// The minimum Go version of the root project, and the Go versions that
+	// replace it when checking the requirements of individual projects.
+	gover  string
+	govers map[ProjectRoot]string
+
+	// The parts of the build context that the ignored packages depend on, in
+	// the form returned by InputBuildContext.
+	buildctx string
+
 	// A map of the project names listed in the root's lock.
 	rlm map[ProjectRoot]LockedProject
 
@@ -137,6 +170,15 @@ func (rd rootdata) getApplicableConstraints(stdLibFn func(string) bool) []workin
 	return ret
 }
 
+// goVersionFor returns the Go version against which the minimum Go version of
+// the given project must be checked, or the empty string if there is none.
+func (rd rootdata) goVersionFor(pr ProjectRoot) string {
+	if v, has := rd.govers[pr]; has {
+		return v
+	}
+	return rd.gover
+}
+
 func (rd rootdata) combineConstraints() []workingConstraint {
 	return rd.ovr.overrideAll(rd.rm.DependencyConstraints())
 }
@@ -144,9 +186,10 @@ func (rd rootdata) combineConstraints() []workingConstraint {
 // needVersionListFor indicates whether we need a version list for a given
 // project root, based solely on general solver inputs (no constraint checking
 // required). Assuming the argument is not the root project itself, this will be
-// true if any of the following conditions hold:
+// true if the project is not frozen, and any of the following conditions hold:
 //
 //  - ChangeAll is on
+//  - A LockPreference other than PreferLocked is set
 //  - The project is not in the lock
 //  - The project is in the lock, but is also in the list of projects to change
 func (rd rootdata) needVersionsFor(pr ProjectRoot) bool {
@@ -154,7 +197,14 @@ func (rd rootdata) needVersionsFor(pr ProjectRoot) bool {
 		return false
 	}
 
-	if rd.chngall {
+	if rd.frozen[pr] {
+		// only the locked version can be used
+		return false
+	}
+
+	if rd.chngall || rd.lockpref != PreferLocked {
+		// Whether a project is a direct dependency, and so whether its locked
+		// version is preferred, is not known until it is selected.
 		return true
 	}
 
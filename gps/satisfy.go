@@ -33,6 +33,15 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 		if err = s.checkAtomAllowable(pa); err != nil {
 			return err
 		}
+		if err = s.checkGoVersion(pa); err != nil {
+			return err
+		}
+		if err = s.checkNotExcluded(pa); err != nil {
+			return err
+		}
+		if err = s.checkMajorVersion(pa); err != nil {
+			return err
+		}
 	}
 
 	if err = s.checkRequiredPackagesExist(a); err != nil {
@@ -103,6 +112,44 @@ func (s *solver) checkAtomAllowable(pa atom) error {
 	return err
 }
 
+// checkGoVersion ensures that an atom does not require a newer version of Go
+// than the root project allows for it.
+func (s *solver) checkGoVersion(pa atom) error {
+	have := s.rd.goVersionFor(pa.id.ProjectRoot)
+	if have == "" {
+		return nil
+	}
+
+	m, _, err := s.b.GetManifestAndLock(pa.id, pa.v, s.rd.an)
+	if err != nil {
+		// Failing to read the manifest is reported by the later checks that
+		// need its constraints.
+		return nil
+	}
+	if need := minGoVersionOf(m); GoVersionExceeds(need, have) {
+		return &goVersionFailure{
+			goal: pa,
+			need: need,
+			have: have,
+		}
+	}
+	return nil
+}
+
+// checkNotExcluded ensures that the atom's version is not one that the root
+// project excluded.
+func (s *solver) checkNotExcluded(pa atom) error {
+	for _, c := range s.rd.excl[pa.id.ProjectRoot] {
+		if c.Matches(pa.v) {
+			return &excludedVersionFailure{
+				goal: pa,
+				c:    c,
+			}
+		}
+	}
+	return nil
+}
+
 // checkRequiredPackagesExist ensures that all required packages enumerated by
 // existing dependencies on this atom are actually present in the atom.
 func (s *solver) checkRequiredPackagesExist(a atomWithPackages) error {
@@ -202,6 +249,10 @@ func (s *solver) checkDepsDisallowsSelected(a atomWithPackages, cdep completeDep
 func (s *solver) checkIdentMatches(a atomWithPackages, cdep completeDep) error {
 	dep := cdep.workingConstraint
 	if curid, has := s.sel.getIdentFor(dep.Ident.ProjectRoot); has && !curid.equiv(dep.Ident) {
+		if s.sameRevisionIn(dep.Ident) {
+			return nil
+		}
+
 		deps := s.sel.getDependenciesOn(a.a.id)
 		// Fail all the other deps, as there's no way atom can ever be
 		// compatible with them
@@ -221,6 +272,44 @@ func (s *solver) checkIdentMatches(a atomWithPackages, cdep completeDep) error {
 	return nil
 }
 
+// sameRevisionIn reports whether the project named by id has already been
+// selected at a revision that is also present in the source id points to. Such
+// a source, typically a mirror or fork, can serve the exact same code as the
+// source already selected, so the two are unified: the selected atom, along
+// with its source, satisfies the dependency.
+//
+// Unification is not independent of the order in which the solver reaches the
+// dependers: the source kept is the one named by the first of them, and the
+// revision is only looked for in the others. The root project's dependencies
+// are always reached first, so a source it names always takes precedence.
+func (s *solver) sameRevisionIn(id ProjectIdentifier) bool {
+	awp, has := s.sel.selected(id)
+	if !has {
+		return false
+	}
+
+	r, has := revisionOf(awp.a.v)
+	if !has {
+		// Without a revision, there is no way to know whether the two sources
+		// hold the same code.
+		return false
+	}
+
+	present, _ := s.b.RevisionPresentIn(id, r)
+	return present
+}
+
+// revisionOf returns the revision underlying v, if it has one.
+func revisionOf(v Version) (Revision, bool) {
+	switch tv := v.(type) {
+	case Revision:
+		return tv, true
+	case PairedVersion:
+		return tv.Revision(), true
+	}
+	return "", false
+}
+
 // checkRootCaseConflicts ensures that the ProjectRoot specified in the completeDep
 // does not have case conflicts with any existing dependencies.
 //
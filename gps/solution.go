@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
@@ -27,6 +29,21 @@ type Solution interface {
 	SolverName() string
 	// The version of the Solver used in generating this solution.
 	SolverVersion() int
+	// The UpdateStrategy used in generating this solution.
+	UpdateStrategy() UpdateStrategy
+	// The newest minimum Go version declared by the root project or any of
+	// the projects in this solution, or the empty string if none declare one.
+	GoVersion() string
+	// The Go versions declared by the root manifest, in the form returned by
+	// GoVersionInputs.
+	InputGoVersions() []string
+	// The parts of the build context the root's ignored packages depended on,
+	// in the form returned by InputBuildContext.
+	InputBuildContext() string
+	// Why each project in this solution was selected at its version, if the
+	// solve was asked to record it with SolveParameters.RecordDecisions, or
+	// nil.
+	Decisions() map[ProjectRoot]Decision
 	Attempts() int
 }
 
@@ -45,6 +62,21 @@ type solution struct {
 
 	// The solver used in producing this solution
 	solv Solver
+
+	// The update strategy used in producing this solution
+	strat UpdateStrategy
+
+	// The effective minimum Go version of this solution
+	gover string
+
+	// The Go versions declared by the root manifest
+	igv []string
+
+	// The parts of the build context the root's ignored packages depended on
+	ibc string
+
+	// Why each project was selected at its version, if recorded
+	dec map[ProjectRoot]Decision
 }
 
 // WriteProgress informs about the progress of WriteDepTree.
@@ -71,11 +103,15 @@ const concurrentWriters = 16
 // If the goal is to populate a vendor directory, basedir should be the absolute
 // path to that vendor directory, not its parent (a project root, typically).
 //
-// It requires a SourceManager to do the work. Prune options are read from the
-// passed manifest.
+// It requires an Exporter, typically a SourceManager, to do the work. Prune
+// options are read from the passed manifest.
+//
+// Projects nested within others in the lock, such as a second major version
+// of a project at a /vN path beneath it, are written after the projects they
+// are nested in, replacing anything those left at their paths.
 //
 // If onWrite is not nil, it will be called after each project write. Calls are ordered and atomic.
-func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOptions, onWrite func(WriteProgress)) error {
+func WriteDepTree(basedir string, l Lock, sm Exporter, co CascadingPruneOptions, onWrite func(WriteProgress)) error {
 	if l == nil {
 		return fmt.Errorf("must provide non-nil Lock to WriteDepTree")
 	}
@@ -84,18 +120,49 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 		return err
 	}
 
-	g, ctx := errgroup.WithContext(context.TODO())
 	lps := l.Projects()
 	sem := make(chan struct{}, concurrentWriters)
 	var cnt struct {
 		sync.Mutex
 		i int
 	}
+	progress := func(p LockedProject, failure bool) {
+		if onWrite == nil {
+			return
+		}
+		// Increment and call atomically to prevent re-ordering.
+		cnt.Lock()
+		cnt.i++
+		onWrite(WriteProgress{
+			Count:   cnt.i,
+			Total:   len(lps),
+			LP:      p,
+			Failure: failure,
+		})
+		cnt.Unlock()
+	}
 
+	var err error
+	for _, wave := range nestingWaves(lps) {
+		if err = writeDepTreeWave(basedir, wave, sm, co, sem, progress); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		os.RemoveAll(basedir)
+	}
+	return errors.Wrap(err, "failed to write dep tree")
+}
+
+// writeDepTreeWave concurrently writes the projects in lps, none of which is
+// nested within another, for WriteDepTree.
+func writeDepTreeWave(basedir string, lps []LockedProject, sm Exporter, co CascadingPruneOptions, sem chan struct{}, progress func(LockedProject, bool)) error {
+	g, ctx := errgroup.WithContext(context.TODO())
 	for i := range lps {
 		p := lps[i] // per-iteration copy
 
 		g.Go(func() error {
+			start := time.Now()
 			err := func() error {
 				select {
 				case sem <- struct{}{}:
@@ -108,6 +175,11 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 				projectRoot := string(ident.ProjectRoot)
 				to := filepath.FromSlash(filepath.Join(basedir, projectRoot))
 
+				// The path of a nested project belongs to it, not to the
+				// project it is nested in.
+				if err := os.RemoveAll(to); err != nil {
+					return errors.Wrapf(err, "failed to clear the path of %s", projectRoot)
+				}
 				if err := sm.ExportProject(ctx, ident, p.Version(), to); err != nil {
 					return errors.Wrapf(err, "failed to export %s", projectRoot)
 				}
@@ -124,29 +196,36 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 			case context.Canceled, context.DeadlineExceeded:
 				// Don't report "secondary" errors.
 			default:
-				if onWrite != nil {
-					// Increment and call atomically to prevent re-ordering.
-					cnt.Lock()
-					cnt.i++
-					onWrite(WriteProgress{
-						Count:   cnt.i,
-						Total:   len(lps),
-						LP:      p,
-						Failure: err != nil,
-					})
-					cnt.Unlock()
-				}
+				sendStepEvent(Event{Kind: EventProjectVendored, ProjectRoot: p.Ident().ProjectRoot, Version: p.Version()}, start, err)
+				progress(p, err != nil)
 			}
 
 			return err
 		})
 	}
 
-	err := g.Wait()
-	if err != nil {
-		os.RemoveAll(basedir)
+	return g.Wait()
+}
+
+// nestingWaves groups lps by how many of the other projects in lps each is
+// nested within, outermost first, so that each project can be written after
+// those it is nested in.
+func nestingWaves(lps []LockedProject) [][]LockedProject {
+	var waves [][]LockedProject
+	for _, lp := range lps {
+		depth := 0
+		pr := string(lp.Ident().ProjectRoot)
+		for _, other := range lps {
+			if strings.HasPrefix(pr, string(other.Ident().ProjectRoot)+"/") {
+				depth++
+			}
+		}
+		for len(waves) <= depth {
+			waves = append(waves, nil)
+		}
+		waves[depth] = append(waves[depth], lp)
 	}
-	return errors.Wrap(err, "failed to write dep tree")
+	return waves
 }
 
 func (r solution) Projects() []LockedProject {
@@ -176,3 +255,23 @@ func (r solution) SolverName() string {
 func (r solution) SolverVersion() int {
 	return r.solv.Version()
 }
+
+func (r solution) UpdateStrategy() UpdateStrategy {
+	return r.strat
+}
+
+func (r solution) GoVersion() string {
+	return r.gover
+}
+
+func (r solution) InputGoVersions() []string {
+	return r.igv
+}
+
+func (r solution) Decisions() map[ProjectRoot]Decision {
+	return r.dec
+}
+
+func (r solution) InputBuildContext() string {
+	return r.ibc
+}
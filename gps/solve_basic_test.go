@@ -193,10 +193,11 @@ func mkCDep(pdep string, pl ...string) completeDep {
 // A depspec is a fixture representing all the information a SourceManager would
 // ordinarily glean directly from interrogating a repository.
 type depspec struct {
-	n    ProjectRoot
-	v    Version
-	deps []ProjectConstraint
-	pkgs []tpkg
+	n     ProjectRoot
+	v     Version
+	deps  []ProjectConstraint
+	pkgs  []tpkg
+	gover string
 }
 
 // mkDepspec creates a depspec by processing a series of strings, each of which
@@ -224,6 +225,12 @@ func mkDepspec(pi string, deps ...string) depspec {
 	return ds
 }
 
+// withGo sets the minimum Go version declared by a depspec.
+func withGo(gover string, ds depspec) depspec {
+	ds.gover = gover
+	return ds
+}
+
 func mkDep(atom, pdep string, pl ...string) dependency {
 	return dependency{
 		depender: mkAtom(atom),
@@ -389,6 +396,10 @@ type basicFixture struct {
 	r map[ProjectIdentifier]LockedProject
 	// max attempts the solver should need to find solution. 0 means no limit
 	maxAttempts int
+	// max attempts the solver is allowed to make. 0 means no limit
+	budget int
+	// solve independent groups of dependencies in parallel
+	parallel bool
 	// Use downgrade instead of default upgrade sorter
 	downgrade bool
 	// lock file simulator, if one's to be used at all
@@ -401,6 +412,18 @@ type basicFixture struct {
 	changeall bool
 	// individual projects to change
 	changelist []ProjectRoot
+	// how strongly to prefer versions in the lock
+	lockpref LockPreference
+	// the order in which to try versions of locked projects
+	updstrat UpdateStrategy
+	// projects to keep at their locked versions
+	frozen []ProjectRoot
+	// versions of projects that must not be selected
+	exclude map[ProjectRoot][]Constraint
+	// Go versions replacing the root's for individual projects
+	govers map[ProjectRoot]string
+	// the expected effective Go version of the solution, if any
+	goversion string
 	// if the fixture is currently broken/expected to fail, this has a message
 	// recording why
 	broken string
@@ -424,8 +447,10 @@ func (f basicFixture) solution() map[ProjectIdentifier]LockedProject {
 
 func (f basicFixture) rootmanifest() RootManifest {
 	return simpleRootManifest{
-		c:   pcSliceToMap(f.ds[0].deps),
-		ovr: f.ovr,
+		c:      pcSliceToMap(f.ds[0].deps),
+		ovr:    f.ovr,
+		gover:  f.ds[0].gover,
+		govers: f.govers,
 	}
 }
 
@@ -610,6 +635,311 @@ var basicFixtures = map[string]basicFixture{
 		changeall: true,
 		downgrade: true,
 	},
+	"upgrade through lock when newest preferred": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0", "bar 1.0.0"),
+			mkDepspec("foo 1.0.1", "bar 1.0.1"),
+			mkDepspec("foo 1.0.2", "bar 1.0.2"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.0.1"),
+			mkDepspec("bar 1.0.2"),
+		},
+		l: mklock(
+			"foo 1.0.1",
+		),
+		r: mksolution(
+			"foo 1.0.2",
+			"bar 1.0.2",
+		),
+		lockpref: PreferNewest,
+	},
+	"keep locked direct deps, upgrade transitive": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0", "bar *"),
+			mkDepspec("foo 1.0.1", "bar *"),
+			mkDepspec("foo 1.0.2", "bar *"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.0.1"),
+			mkDepspec("bar 1.0.2"),
+		},
+		l: mklock(
+			"foo 1.0.1",
+			"bar 1.0.1",
+		),
+		r: mksolution(
+			"foo 1.0.1",
+			"bar 1.0.2",
+		),
+		lockpref: PreferLockedDirect,
+	},
+	"keep all locked deps": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0", "bar *"),
+			mkDepspec("foo 1.0.1", "bar *"),
+			mkDepspec("foo 1.0.2", "bar *"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.0.1"),
+			mkDepspec("bar 1.0.2"),
+		},
+		l: mklock(
+			"foo 1.0.1",
+			"bar 1.0.1",
+		),
+		r: mksolution(
+			"foo 1.0.1",
+			"bar 1.0.1",
+		),
+	},
+	"update with minimal changes": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.0.1"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("foo 2.0.0"),
+		},
+		l: mklock(
+			"foo 1.0.0",
+		),
+		r: mksolution(
+			"foo 1.0.1",
+		),
+		changeall: true,
+		updstrat:  MinimizeChanges,
+	},
+	"update with minimal changes skips failing versions": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *", "bar 2.0.0"),
+			mkDepspec("foo 1.0.0", "bar 1.0.0"),
+			mkDepspec("foo 1.0.1", "bar 1.0.0"),
+			mkDepspec("foo 1.1.0", "bar 2.0.0"),
+			mkDepspec("foo 1.2.0", "bar 2.0.0"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 2.0.0"),
+		},
+		l: mklock(
+			"foo 1.0.0",
+		),
+		r: mksolution(
+			"foo 1.1.0",
+			"bar 2.0.0",
+		),
+		changeall: true,
+		updstrat:  MinimizeChanges,
+	},
+	"security-only update": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.0.1"),
+			mkDepspec("foo 1.0.2"),
+			mkDepspec("foo 1.1.0"),
+		},
+		l: mklock(
+			"foo 1.0.0",
+		),
+		r: mksolution(
+			"foo 1.0.2",
+		),
+		changeall: true,
+		updstrat:  SecurityOnly,
+	},
+	"excluded locked version moved by the smallest step": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *", "bar *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.0.1"),
+			mkDepspec("foo 1.0.2"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.0.1"),
+		},
+		l: mklock(
+			"foo 1.0.0",
+			"bar 1.0.0",
+		),
+		r: mksolution(
+			"foo 1.0.2",
+			"bar 1.0.0",
+		),
+		changelist: []ProjectRoot{"foo"},
+		updstrat:   MinimizeChanges,
+		exclude:    map[ProjectRoot][]Constraint{"foo": {mkSVC("<1.0.2")}},
+	},
+	"excluded versions leave none allowed": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ~1.0.0"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.1.0"),
+		},
+		exclude: map[ProjectRoot][]Constraint{"foo": {mkSVC("<1.1.0")}},
+		fail: &noVersionError{
+			pn: mkPI("foo"),
+			fails: []failedVersion{
+				{
+					v: NewVersion("1.1.0"),
+					f: &versionNotAllowedFailure{
+						goal:       mkAtom("foo 1.1.0"),
+						failparent: []dependency{mkDep("root", "foo ~1.0.0", "foo")},
+						c:          mkSVC("~1.0.0"),
+					},
+				},
+				{
+					v: NewVersion("1.0.0"),
+					f: &excludedVersionFailure{
+						goal: mkAtom("foo 1.0.0"),
+						c:    mkSVC("<1.1.0"),
+					},
+				},
+			},
+		},
+	},
+	"two major versions of a project side by side": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ^1.0.0", "foo/v2 *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo/v2 2.0.0"),
+			mkDepspec("foo/v2 3.0.0"),
+		},
+		r: mksolution(
+			"foo 1.0.0",
+			"foo/v2 2.0.0",
+		),
+	},
+	"frozen project kept when changing all": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *", "bar *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.0.1"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.0.1"),
+		},
+		l: mklock(
+			"foo 1.0.0",
+			"bar 1.0.0",
+		),
+		r: mksolution(
+			"foo 1.0.0",
+			"bar 1.0.1",
+		),
+		changeall: true,
+		frozen:    []ProjectRoot{"foo"},
+	},
+	"frozen transitive project kept when the lock is not preferred": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0", "bar *"),
+			mkDepspec("foo 1.0.1", "bar *"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.0.1"),
+		},
+		l: mklock(
+			"foo 1.0.0",
+			"bar 1.0.0",
+		),
+		r: mksolution(
+			"foo 1.0.1",
+			"bar 1.0.0",
+		),
+		lockpref: PreferNewest,
+		frozen:   []ProjectRoot{"bar"},
+	},
+	"frozen project not in the lock solved as usual": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.0.1"),
+		},
+		r: mksolution(
+			"foo 1.0.1",
+		),
+		frozen: []ProjectRoot{"foo"},
+	},
+	"frozen project fails rather than move off its locked version": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo ^2.0.0"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 2.0.0"),
+		},
+		l: mklock(
+			"foo 1.0.0",
+		),
+		frozen: []ProjectRoot{"foo"},
+		fail: &noVersionError{
+			pn: mkPI("foo"),
+			fails: []failedVersion{
+				{
+					v: NewVersion("1.0.0"),
+					f: &versionNotAllowedFailure{
+						goal:       mkAtom("foo 1.0.0"),
+						failparent: []dependency{mkDep("root", "foo ^2.0.0", "foo")},
+						c:          mkSVC("^2.0.0"),
+					},
+				},
+			},
+		},
+	},
+	"reject versions requiring a newer go": {
+		ds: []depspec{
+			withGo("1.10", mkDepspec("root 0.0.0", "foo *")),
+			mkDepspec("foo 1.0.0"),
+			withGo("1.10", mkDepspec("foo 1.1.0")),
+			withGo("1.11", mkDepspec("foo 1.2.0")),
+		},
+		r: mksolution(
+			"foo 1.1.0",
+		),
+		goversion: "1.10",
+	},
+	"per-project go version allows a newer go": {
+		ds: []depspec{
+			withGo("1.10", mkDepspec("root 0.0.0", "foo *", "bar *")),
+			withGo("1.10", mkDepspec("foo 1.1.0")),
+			withGo("1.11", mkDepspec("foo 1.2.0")),
+			withGo("1.10", mkDepspec("bar 1.0.0")),
+			withGo("1.11", mkDepspec("bar 1.1.0")),
+		},
+		r: mksolution(
+			"foo 1.2.0",
+			"bar 1.0.0",
+		),
+		govers:    map[ProjectRoot]string{"foo": "1.11"},
+		goversion: "1.11",
+	},
+	"effective go version comes from dependencies": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo *"),
+			withGo("1.9", mkDepspec("foo 1.0.0", "bar *")),
+			withGo("1.11", mkDepspec("bar 1.0.0")),
+		},
+		r: mksolution(
+			"foo 1.0.0",
+			"bar 1.0.0",
+		),
+		goversion: "1.11",
+	},
+	"no version of a project supports the root's go": {
+		ds: []depspec{
+			withGo("1.9", mkDepspec("root 0.0.0", "foo *")),
+			withGo("1.10", mkDepspec("foo 1.0.0")),
+		},
+		fail: &noVersionError{
+			pn: mkPI("foo"),
+			fails: []failedVersion{
+				{
+					v: NewVersion("1.0.0"),
+					f: &goVersionFailure{
+						goal: mkAtom("foo 1.0.0"),
+						need: "1.10",
+						have: "1.9",
+					},
+				},
+			},
+		},
+	},
 	"update one with only one": {
 		ds: []depspec{
 			mkDepspec("root 0.0.0", "foo *"),
@@ -1278,6 +1608,17 @@ func init() {
 
 	basicFixtures["complex backtrack"] = fix
 
+	// The same, with too few attempts allowed to get to the solution.
+	fix.r = nil
+	fix.maxAttempts = 0
+	fix.budget = 3
+	fix.fail = &BudgetExceededError{
+		Attempts: 3,
+		Partial:  []LockedProject{mklp("bar 9.9.0")},
+		Unplaced: []ProjectRoot{"baz", "foo"},
+	}
+	basicFixtures["complex backtrack over budget"] = fix
+
 	for k, fix := range basicFixtures {
 		// Assign the name into the fixture itself
 		fix.n = k
@@ -1473,6 +1814,10 @@ func (sm *depspecSourceManager) SourceURLsForPath(ip string) ([]*url.URL, error)
 	return nil, fmt.Errorf("dummy sm doesn't implement SourceURLsForPath")
 }
 
+func (sm *depspecSourceManager) SourceURLFor(id ProjectIdentifier) (string, error) {
+	return "", fmt.Errorf("dummy sm doesn't implement SourceURLFor")
+}
+
 func (sm *depspecSourceManager) rootSpec() depspec {
 	return sm.specs[0]
 }
@@ -1524,6 +1869,9 @@ func (b *depspecBridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 	} else {
 		SortForUpgrade(vl)
 	}
+	if lp, has := b.s.rd.rlm[id.ProjectRoot]; has {
+		vl = b.s.rd.updstrat.order(vl, lp.Version())
+	}
 
 	b.vlists[id] = vl
 	return vl, nil
@@ -1557,6 +1905,11 @@ func (ds depspec) DependencyConstraints() ProjectConstraints {
 	return pcSliceToMap(ds.deps)
 }
 
+// impl GoVersionManifest interface
+func (ds depspec) MinGoVersion() string {
+	return ds.gover
+}
+
 type fixLock []LockedProject
 
 // impl Lock interface
@@ -0,0 +1,130 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// BatchOptions control how SolveBatch solves its root projects.
+type BatchOptions struct {
+	// Concurrency is the largest number of root projects solved at once, and
+	// of sources prefetched at once. Zero means runtime.NumCPU().
+	Concurrency int
+
+	// Prefetch indicates whether the version lists of the sources named by
+	// the root projects' constraints, overrides and locks are fetched before
+	// any root is solved, for each source once, many at a time. Each solver
+	// otherwise fetches them one at a time, as it comes to them.
+	Prefetch bool
+}
+
+// BatchResult is the outcome of solving one of the root projects of a batch.
+type BatchResult struct {
+	// Solution is the solution for the root project, if one was found.
+	Solution Solution
+	// Err is the error from preparing or solving for the root project, if
+	// any.
+	Err error
+}
+
+// SolveBatch solves for each of roots, as Prepare and Solve would, returning
+// their results in the same order. It is meant for tools that manage many
+// projects centrally, such as a platform keeping hundreds of repositories
+// up to date.
+//
+// All of the solves share sm, and solve concurrently, so that the
+// information sm retrieves about a source, such as its versions, manifests and
+// packages, is retrieved once for the whole batch: a SourceMgr fetches each
+// source at most once however many solvers ask for it, and caches what it
+// reads from it for the others. A root failing to solve does not affect the
+// others.
+//
+// If ctx is canceled, the roots not yet solved have its error as their
+// result.
+func SolveBatch(ctx context.Context, roots []SolveParameters, sm SourceManager, opts BatchOptions) []BatchResult {
+	n := opts.Concurrency
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+
+	if opts.Prefetch {
+		ids := batchSources(roots)
+		forEachConcurrently(n, len(ids), func(i int) {
+			// Errors are left for the solvers to report, for the roots
+			// that need the source.
+			if ctx.Err() == nil {
+				sm.ListVersions(ids[i])
+			}
+		})
+	}
+
+	results := make([]BatchResult, len(roots))
+	forEachConcurrently(n, len(roots), func(i int) {
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			return
+		}
+		s, err := Prepare(roots[i], sm)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].Solution, results[i].Err = s.Solve(ctx)
+	})
+	return results
+}
+
+// batchSources returns the sources named by the constraints, overrides and
+// locks of roots, each once, in order.
+func batchSources(roots []SolveParameters) []ProjectIdentifier {
+	seen := make(map[ProjectIdentifier]bool)
+	var ids []ProjectIdentifier
+	add := func(id ProjectIdentifier) {
+		id = id.normalize()
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, params := range roots {
+		if params.Manifest != nil {
+			for _, pcs := range []ProjectConstraints{params.Manifest.DependencyConstraints(), params.Manifest.Overrides()} {
+				for pr, pp := range pcs {
+					add(ProjectIdentifier{ProjectRoot: pr, Source: pp.Source})
+				}
+			}
+		}
+		if params.Lock != nil {
+			for _, lp := range params.Lock.Projects() {
+				add(lp.Ident())
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+	return ids
+}
+
+// forEachConcurrently calls f with each index below count, running at most n
+// calls at once, and returns once all of them have.
+func forEachConcurrently(n, count int, f func(i int)) {
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSolveBatch(t *testing.T) {
+	// The fixtures share their depspecs, so they can share a source manager.
+	names := []string{"with compatible locked dependency", "upgrade through lock", "downgrade through lock"}
+	sm := newdepspecSM(basicFixtures[names[0]].ds, nil)
+
+	var roots []SolveParameters
+	for _, name := range names {
+		fix := basicFixtures[name]
+		roots = append(roots, SolveParameters{
+			RootDir:         string(fix.ds[0].n),
+			RootPackageTree: fix.rootTree(),
+			Manifest:        fix.rootmanifest(),
+			Lock:            fix.l,
+			Downgrade:       fix.downgrade,
+			ChangeAll:       fix.changeall,
+			ProjectAnalyzer: naiveAnalyzer{},
+			TraceLogger:     log.New(test.Writer{TB: t}, "", 0),
+			stdLibFn:        func(string) bool { return false },
+			mkBridgeFn:      overrideMkBridge,
+		})
+	}
+	// A root that cannot be prepared fails on its own.
+	roots = append(roots, SolveParameters{})
+
+	if got, want := batchSources(roots), []ProjectIdentifier{{ProjectRoot: "foo", Source: "foo"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected sources %v, got %v", want, got)
+	}
+
+	results := SolveBatch(context.Background(), roots, sm, BatchOptions{Concurrency: 2, Prefetch: true})
+	if len(results) != len(roots) {
+		t.Fatalf("expected %d results, got %d", len(roots), len(results))
+	}
+	for i, name := range names {
+		t.Run(name, func(t *testing.T) {
+			fixtureSolveSimpleChecks(basicFixtures[name], results[i].Solution, results[i].Err, t)
+		})
+	}
+	if results[len(names)].Err == nil {
+		t.Error("expected the root without parameters to fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i, res := range SolveBatch(ctx, roots[:1], sm, BatchOptions{}) {
+		if res.Err != context.Canceled {
+			t.Errorf("root %d: expected the batch to be canceled, got %v", i, res.Err)
+		}
+	}
+}
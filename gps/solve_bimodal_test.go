@@ -1064,6 +1064,53 @@ var bimodalFixtures = map[string]bimodalFixture{
 			},
 		},
 	},
+	"unifies mismatched net addrs that share a revision": {
+		ds: []depspec{
+			dsp(mkDepspec("root 1.0.0", "foo 1.0.0", "bar 1.0.0"),
+				pkg("root", "foo", "bar")),
+			dsp(mkDepspec("foo 1.0.0", "bar from baz 1.0.0"),
+				pkg("foo", "bar")),
+			dsp(mkDepspec("bar 1.0.0 barrev"),
+				pkg("bar")),
+			dsp(mkDepspec("baz 1.0.0 barrev"),
+				pkg("bar")),
+			dsp(mkDepspec("baz rbarrev"),
+				pkg("bar")),
+		},
+		r: mksolution(
+			"foo 1.0.0",
+			"bar 1.0.0 barrev",
+		),
+	},
+	"fails with mismatched net addrs that do not share a revision": {
+		ds: []depspec{
+			dsp(mkDepspec("root 1.0.0", "foo 1.0.0", "bar 1.0.0"),
+				pkg("root", "foo", "bar")),
+			dsp(mkDepspec("foo 1.0.0", "bar from baz 1.0.0"),
+				pkg("foo", "bar")),
+			dsp(mkDepspec("bar 1.0.0 barrev"),
+				pkg("bar")),
+			dsp(mkDepspec("baz 1.0.0 bazrev"),
+				pkg("bar")),
+			dsp(mkDepspec("baz rbazrev"),
+				pkg("bar")),
+		},
+		fail: &noVersionError{
+			pn: mkPI("foo"),
+			fails: []failedVersion{
+				{
+					v: NewVersion("1.0.0"),
+					f: &sourceMismatchFailure{
+						shared:   ProjectRoot("bar"),
+						current:  "bar",
+						mismatch: "baz",
+						prob:     mkAtom("foo 1.0.0"),
+						sel:      []dependency{mkDep("root", "foo 1.0.0", "foo")},
+					},
+				},
+			},
+		},
+	},
 	"overridden mismatched net addrs, alt in dep": {
 		ds: []depspec{
 			dsp(mkDepspec("root 0.0.0"),
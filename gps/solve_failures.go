@@ -352,6 +352,91 @@ func (e *sourceMismatchFailure) traceString() string {
 	return buf.String()
 }
 
+// goVersionFailure indicates that an atom was rejected because its manifest
+// declares a minimum Go version newer than the one the root project allows for
+// it.
+type goVersionFailure struct {
+	// The atom that was rejected
+	goal atom
+	// The minimum Go version declared by the atom
+	need string
+	// The Go version allowed by the root project
+	have string
+}
+
+func (e *goVersionFailure) Error() string {
+	str := "Could not introduce %s, as it requires Go %s, but the root project only allows Go %s"
+	return fmt.Sprintf(str, a2vs(e.goal), e.need, e.have)
+}
+
+func (e *goVersionFailure) traceString() string {
+	return fmt.Sprintf("%s requires Go %s, newer than the allowed %s", a2vs(e.goal), e.need, e.have)
+}
+
+// excludedVersionFailure indicates that an atom was rejected because its
+// version was excluded by the root project.
+type excludedVersionFailure struct {
+	// The atom that was rejected
+	goal atom
+	// The exclusion matching the atom's version
+	c Constraint
+}
+
+func (e *excludedVersionFailure) Error() string {
+	str := "Could not introduce %s, as versions %s of it are excluded"
+	return fmt.Sprintf(str, a2vs(e.goal), e.c)
+}
+
+func (e *excludedVersionFailure) traceString() string {
+	return fmt.Sprintf("%s is excluded by %s", a2vs(e.goal), e.c)
+}
+
+// majorVersionFailure indicates that an atom was rejected because its version
+// is of a major version other than the one its project root names.
+type majorVersionFailure struct {
+	// The atom that was rejected
+	goal atom
+	// The major version named by the atom's project root
+	major uint64
+}
+
+func (e *majorVersionFailure) Error() string {
+	str := "Could not introduce %s, as %s only holds major version %d"
+	return fmt.Sprintf(str, a2vs(e.goal), e.goal.id.ProjectRoot, e.major)
+}
+
+func (e *majorVersionFailure) traceString() string {
+	return fmt.Sprintf("%s is not of major version %d", a2vs(e.goal), e.major)
+}
+
+// BudgetExceededError is returned by Solve when solving stops because the
+// MaxAttempts given in SolveParameters have been used up.
+type BudgetExceededError struct {
+	// The number of attempts made.
+	Attempts int
+	// Partial holds the projects of the largest set of mutually compatible
+	// selections found, sorted by project.
+	Partial []LockedProject
+	// Unplaced holds the projects required by Partial that remained to be
+	// placed alongside it, sorted.
+	Unplaced []ProjectRoot
+}
+
+func (e *BudgetExceededError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Gave up after %d attempts. The best partial solution found was:", e.Attempts)
+	for _, lp := range e.Partial {
+		fmt.Fprintf(&buf, "\n\t%s@%s", lp.Ident(), lp.Version())
+	}
+	if len(e.Unplaced) > 0 {
+		fmt.Fprintf(&buf, "\nbut could not also place:")
+		for _, pr := range e.Unplaced {
+			fmt.Fprintf(&buf, "\n\t%s", pr)
+		}
+	}
+	return buf.String()
+}
+
 type errDeppers struct {
 	err     error
 	deppers []atom
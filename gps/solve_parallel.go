@@ -0,0 +1,187 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// solveParallel attempts to solve the root project's dependencies as groups
+// that share no projects, solving each group concurrently with its own
+// solver.
+//
+// Each direct dependency of the root starts out in a group of its own. Groups
+// whose solutions turn out to share a project are merged and solved again,
+// until the solutions of all groups are disjoint; they are then combined. As
+// each group is solved deterministically, and they are merged in the order of
+// their first project, the result does not depend on how the solves are
+// scheduled.
+//
+// If there are fewer than two direct dependencies, or any group fails to
+// solve, nil is returned with a nil error, and the caller should solve all of
+// the dependencies together, as usual. An error is only returned if solving
+// was canceled.
+func (s *solver) solveParallel(ctx context.Context) (map[atom]map[string]struct{}, error) {
+	deps, err := s.intersectConstraintsWithImports(s.rd.combineConstraints(), s.rd.externalImportList(s.stdLibFn))
+	if err != nil {
+		// Let the full solve report it.
+		return nil, nil
+	}
+
+	seen := make(map[ProjectRoot]bool)
+	var groups [][]ProjectRoot
+	for _, dep := range deps {
+		if pr := dep.Ident.ProjectRoot; !seen[pr] {
+			seen[pr] = true
+			groups = append(groups, []ProjectRoot{pr})
+		}
+	}
+	if len(groups) < 2 {
+		return nil, nil
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	results := make([]map[atom]map[string]struct{}, len(groups))
+	for {
+		s.traceParallel(groups)
+		if err := s.solveGroups(ctx, groups, results); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			s.traceParallelFallback()
+			// Only the attempts of the solve that produces the result count.
+			s.attempts = 0
+			return nil, nil
+		}
+
+		// Merge the groups whose solutions share a project, with case-only
+		// variations of a root counting as the same project.
+		parent := make([]int, len(groups))
+		for i := range parent {
+			parent[i] = i
+		}
+		var find func(int) int
+		find = func(i int) int {
+			if parent[i] != i {
+				parent[i] = find(parent[i])
+			}
+			return parent[i]
+		}
+
+		var merged bool
+		owner := make(map[string]int)
+		for i, res := range results {
+			for a := range res {
+				f := toFold(string(a.id.ProjectRoot))
+				j, has := owner[f]
+				if !has {
+					owner[f] = i
+					continue
+				}
+				if ri, rj := find(i), find(j); ri != rj {
+					// Keep the earlier group as the representative.
+					if ri < rj {
+						parent[rj] = ri
+					} else {
+						parent[ri] = rj
+					}
+					merged = true
+				}
+			}
+		}
+
+		if !merged {
+			all := make(map[atom]map[string]struct{})
+			for _, res := range results {
+				for a, pl := range res {
+					all[a] = pl
+				}
+			}
+			return all, nil
+		}
+
+		var ngroups [][]ProjectRoot
+		var nresults []map[atom]map[string]struct{}
+		index := make(map[int]int)
+		for i, g := range groups {
+			r := find(i)
+			if ni, has := index[r]; has {
+				ngroups[ni] = append(ngroups[ni], g...)
+				// The merged group needs to be solved again.
+				nresults[ni] = nil
+				continue
+			}
+			index[r] = len(ngroups)
+			ngroups = append(ngroups, append([]ProjectRoot(nil), g...))
+			nresults = append(nresults, results[i])
+		}
+		for _, g := range ngroups {
+			sort.Slice(g, func(i, j int) bool { return g[i] < g[j] })
+		}
+		groups, results = ngroups, nresults
+	}
+}
+
+// solveGroups concurrently solves each of groups that does not yet have a
+// result, storing the selected atoms in results. The first error encountered,
+// in the order of groups, is returned.
+func (s *solver) solveGroups(ctx context.Context, groups [][]ProjectRoot, results []map[atom]map[string]struct{}) error {
+	errs := make([]error, len(groups))
+	subs := make([]*solver, len(groups))
+	var wg sync.WaitGroup
+	for i, g := range groups {
+		if results[i] != nil {
+			continue
+		}
+
+		sub := s.subSolver(g)
+		subs[i] = sub
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := sub.selectRoot(); err != nil {
+				errs[i] = err
+				return
+			}
+			results[i], errs[i] = sub.solve(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, sub := range subs {
+		if sub != nil {
+			s.attempts += sub.attempts
+			for pr, d := range sub.decisions {
+				if s.decisions == nil {
+					s.decisions = make(map[ProjectRoot]Decision)
+				}
+				s.decisions[pr] = d
+			}
+		}
+		if errs[i] != nil {
+			return errs[i]
+		}
+	}
+	return nil
+}
+
+// subSolver returns a solver sharing the root data of s, restricted to the
+// root's dependencies on the projects in group.
+func (s *solver) subSolver(group []ProjectRoot) *solver {
+	sub := &solver{
+		stdLibFn: s.stdLibFn,
+		rd:       s.rd,
+		mtr:      newMetrics(),
+		group:    make(map[ProjectRoot]bool, len(group)),
+	}
+	for _, pr := range group {
+		sub.group[pr] = true
+	}
+	sub.b = s.newBridge(sub)
+	sub.initQueues()
+	return sub
+}
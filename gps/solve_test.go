@@ -59,6 +59,27 @@ func TestBasicSolves(t *testing.T) {
 	}
 }
 
+// Test all the basic table fixtures again, solving independent groups of
+// dependencies in parallel.
+func TestBasicSolvesParallel(t *testing.T) {
+	names := make([]string, 0, len(basicFixtures))
+	for n := range basicFixtures {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+	for _, n := range names {
+		fix := basicFixtures[n]
+		fix.parallel = true
+		// Attempts are counted across the solves of all the groups.
+		fix.maxAttempts = 0
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			solveBasicsAndCheck(fix, t)
+		})
+	}
+}
+
 func solveBasicsAndCheck(fix basicFixture, t *testing.T) (res Solution, err error) {
 	sm := newdepspecSM(fix.ds, nil)
 	if fix.broken != "" {
@@ -73,6 +94,12 @@ func solveBasicsAndCheck(fix basicFixture, t *testing.T) (res Solution, err erro
 		Downgrade:       fix.downgrade,
 		ChangeAll:       fix.changeall,
 		ToChange:        fix.changelist,
+		LockPreference:  fix.lockpref,
+		UpdateStrategy:  fix.updstrat,
+		Frozen:          fix.frozen,
+		Exclude:         fix.exclude,
+		MaxAttempts:     fix.budget,
+		Parallel:        fix.parallel,
 		ProjectAnalyzer: naiveAnalyzer{},
 	}
 
@@ -81,6 +108,12 @@ func solveBasicsAndCheck(fix basicFixture, t *testing.T) (res Solution, err erro
 	}
 
 	res, err = fixSolve(params, sm, t)
+	if err == nil && res.UpdateStrategy() != fix.updstrat {
+		t.Errorf("expected solution to record update strategy %s, got %s", fix.updstrat, res.UpdateStrategy())
+	}
+	if err == nil && fix.goversion != "" && res.GoVersion() != fix.goversion {
+		t.Errorf("expected solution to record Go version %q, got %q", fix.goversion, res.GoVersion())
+	}
 
 	return fixtureSolveSimpleChecks(fix, res, err, t)
 }
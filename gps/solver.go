@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/armon/go-radix"
 	"github.com/golang/dep/gps/paths"
@@ -84,6 +85,18 @@ type SolveParameters struct {
 	// versions specified in the root lock file should be ignored.
 	ChangeAll bool
 
+	// Frozen is a list of projects that must be kept at the versions recorded
+	// for them in the root lock, whatever ToChange, ChangeAll and
+	// LockPreference indicate. If the locked version of a frozen project can no
+	// longer be used, solving fails rather than moving it to another version.
+	// Frozen projects that are not in the lock are solved as usual.
+	Frozen []ProjectRoot
+
+	// Exclude lists, by project, constraints matching versions that must not
+	// be selected, such as those affected by a known vulnerability. They apply
+	// to every version considered for the project, including the locked one.
+	Exclude map[ProjectRoot][]Constraint
+
 	// Downgrade indicates whether the solver will attempt to upgrade (false) or
 	// downgrade (true) projects that are not locked, or are marked for change.
 	//
@@ -92,6 +105,37 @@ type SolveParameters struct {
 	// typical case.
 	Downgrade bool
 
+	// LockPreference controls how strongly the versions in Lock are preferred
+	// over the newest versions allowed by constraints, for projects that are
+	// not in ToChange. It has no effect when ChangeAll is set.
+	LockPreference LockPreference
+
+	// UpdateStrategy determines the order in which versions are tried for
+	// projects that have a version in Lock, once they are allowed to change.
+	// It is recorded in the resulting Solution.
+	UpdateStrategy UpdateStrategy
+
+	// MaxAttempts limits the number of attempts, as counted by
+	// Solution.Attempts, that the solver may make. Once they are used up,
+	// solving stops and Solve returns a *BudgetExceededError holding the best
+	// partial solution found. Zero means no limit.
+	MaxAttempts int
+
+	// Parallel indicates whether the solver may split the root project's
+	// dependencies into groups that share no projects, and solve the groups
+	// concurrently. The result is the same however the solves are scheduled,
+	// though it may differ from the result of solving all the dependencies
+	// together where their constraints leave a choice of versions. If the
+	// groups cannot all be solved, all of the dependencies are solved
+	// together, as usual. MaxAttempts applies to the solve of each group
+	// separately.
+	Parallel bool
+
+	// RecordDecisions indicates whether the solver should record, for each
+	// project it selects, why it selected the version it did, as reported by
+	// Solution.Decisions.
+	RecordDecisions bool
+
 	// TraceLogger is the logger to use for generating trace output. If set, the
 	// solver will generate informative trace output as it moves through the
 	// solving process.
@@ -115,9 +159,22 @@ type solver struct {
 	// starts moving forward again.
 	attempts int
 
+	// The largest set of projects selected together so far, along with the
+	// projects that remained to be selected at that point. Reported if the
+	// attempt budget runs out.
+	best         map[atom]map[string]struct{}
+	bestUnplaced []ProjectRoot
+
 	// Logger used exclusively for trace output, or nil to suppress.
 	tl *log.Logger
 
+	// The root dependencies this solver is restricted to, or nil for all of
+	// them. Set only on the solvers for the groups of a parallel solve.
+	group map[ProjectRoot]bool
+
+	// Creates a bridge for a solver, using the same SourceManager as this one.
+	newBridge func(*solver) sourceBridge
+
 	// The function to use to recognize standard library import paths.
 	stdLibFn func(string) bool
 
@@ -154,6 +211,10 @@ type solver struct {
 	// added to an existing project.
 	vqs []*versionQueue
 
+	// Why each project was selected at the version it was, recorded once the
+	// solve succeeds, if the root data asks for it.
+	decisions map[ProjectRoot]Decision
+
 	// Contains data and constraining information from the root project
 	rd rootdata
 
@@ -181,22 +242,45 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 	if params.Lock == nil && len(params.ToChange) != 0 {
 		return rootdata{}, badOptsFailure(fmt.Sprintf("update specifically requested for %s, but no lock was provided to upgrade from", params.ToChange))
 	}
+	if params.MaxAttempts < 0 {
+		return rootdata{}, badOptsFailure("MaxAttempts may not be negative")
+	}
 
 	if params.Manifest == nil {
 		params.Manifest = simpleRootManifest{}
 	}
 
 	rd := rootdata{
-		ir:      params.Manifest.IgnoredPackages(),
-		req:     params.Manifest.RequiredPackages(),
-		ovr:     params.Manifest.Overrides(),
-		rpt:     params.RootPackageTree.Copy(),
-		chng:    make(map[ProjectRoot]struct{}),
-		rlm:     make(map[ProjectRoot]LockedProject),
-		chngall: params.ChangeAll,
-		dir:     params.RootDir,
-		an:      params.ProjectAnalyzer,
+		ir:       params.Manifest.IgnoredPackages(),
+		req:      params.Manifest.RequiredPackages(),
+		ovr:      params.Manifest.Overrides(),
+		rpt:      params.RootPackageTree.Copy(),
+		chng:     make(map[ProjectRoot]struct{}),
+		frozen:   make(map[ProjectRoot]bool),
+		excl:     make(map[ProjectRoot][]Constraint, len(params.Exclude)),
+		rlm:      make(map[ProjectRoot]LockedProject),
+		chngall:  params.ChangeAll,
+		lockpref: params.LockPreference,
+		updstrat: params.UpdateStrategy,
+		maxatt:   params.MaxAttempts,
+		parallel: params.Parallel,
+		recdec:   params.RecordDecisions,
+		dir:      params.RootDir,
+		an:       params.ProjectAnalyzer,
+	}
+
+	for pr, cl := range params.Exclude {
+		rd.excl[pr] = append([]Constraint(nil), cl...)
+	}
+
+	if gm, ok := params.Manifest.(GoVersionRootManifest); ok {
+		rd.gover = gm.MinGoVersion()
+		rd.govers = make(map[ProjectRoot]string, len(gm.ProjectGoVersions()))
+		for pr, v := range gm.ProjectGoVersions() {
+			rd.govers[pr] = v
+		}
 	}
+	rd.buildctx = InputBuildContext(params.Manifest)
 
 	// Ensure the required and overrides maps are at least initialized
 	if rd.req == nil {
@@ -262,6 +346,15 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 		rd.chng[p] = struct{}{}
 	}
 
+	for _, p := range params.Frozen {
+		if _, exists := rd.chng[p]; exists {
+			return rootdata{}, badOptsFailure(fmt.Sprintf("cannot update %s as it is frozen", p))
+		}
+		if _, exists := rd.rlm[p]; exists {
+			rd.frozen[p] = true
+		}
+	}
+
 	return rd, nil
 }
 
@@ -279,6 +372,11 @@ func Prepare(params SolveParameters, sm SourceManager) (Solver, error) {
 	if err != nil {
 		return nil, err
 	}
+	if rm, ok := params.Manifest.(RequiredConstraintsManifest); ok {
+		if err := rd.constrainRequired(rm.RequiredConstraints(), sm); err != nil {
+			return nil, err
+		}
+	}
 
 	if params.stdLibFn == nil {
 		params.stdLibFn = paths.IsStandardImportPath
@@ -292,17 +390,24 @@ func Prepare(params SolveParameters, sm SourceManager) (Solver, error) {
 
 	// Set up the bridge and ensure the root dir is in good, working order
 	// before doing anything else.
-	if params.mkBridgeFn == nil {
-		s.b = mkBridge(s, sm, params.Downgrade)
-	} else {
-		s.b = params.mkBridgeFn(s, sm, params.Downgrade)
+	s.newBridge = func(s *solver) sourceBridge {
+		if params.mkBridgeFn == nil {
+			return mkBridge(s, sm, params.Downgrade)
+		}
+		return params.mkBridgeFn(s, sm, params.Downgrade)
 	}
+	s.b = s.newBridge(s)
 	err = s.b.verifyRootDir(params.RootDir)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize stacks and queues
+	s.initQueues()
+	return s, nil
+}
+
+// initQueues initializes the selection stack and the unselected queue.
+func (s *solver) initQueues() {
 	s.sel = &selection{
 		deps:      make(map[ProjectRoot][]dependency),
 		foldRoots: make(map[string]ProjectRoot),
@@ -311,8 +416,6 @@ func Prepare(params SolveParameters, sm SourceManager) (Solver, error) {
 		sl:  make([]bimodalIdentifier, 0),
 		cmp: s.unselectedComparator,
 	}
-
-	return s, nil
 }
 
 // A Solver is the main workhorse of gps: given a set of project inputs, it
@@ -426,25 +529,41 @@ func (s *solver) Solve(ctx context.Context) (Solution, error) {
 	if !atomic.CompareAndSwapInt32(&s.hasrun, 0, 1) {
 		return nil, errors.New("solve method can only be run once per instance")
 	}
+	CountMetric(MetricSolveAttempts, 1)
+	start := time.Now()
+	SendEvent(Event{Kind: EventSolveStarted, Time: start})
 	// Make sure the bridge has the context before we start.
 	//s.b.ctx = ctx
 
 	// Set up a metrics object
 	s.mtr = newMetrics()
 
-	// Prime the queues with the root project
-	if err := s.selectRoot(); err != nil {
-		return nil, err
+	var all map[atom]map[string]struct{}
+	var err error
+	if s.rd.parallel {
+		all, err = s.solveParallel(ctx)
 	}
+	if all == nil && err == nil {
+		// Prime the queues with the root project
+		if err := s.selectRoot(); err != nil {
+			sendStepEvent(Event{Kind: EventSolveFinished}, start, err)
+			return nil, err
+		}
 
-	all, err := s.solve(ctx)
+		all, err = s.solve(ctx)
+	}
+	var gover string
+	if err == nil {
+		gover = s.effectiveGoVersion(all)
+	}
 
 	s.mtr.pop()
 	var soln solution
 	if err == nil {
 		soln = solution{
-			att:  s.attempts,
-			solv: s,
+			att:   s.attempts,
+			solv:  s,
+			strat: s.rd.updstrat,
 		}
 		soln.analyzerInfo = s.rd.an.Info()
 		soln.i = s.rd.externalImportList(s.stdLibFn)
@@ -461,15 +580,43 @@ func (s *solver) Solve(ctx context.Context) (Solution, error) {
 
 			soln.p = append(soln.p, lp)
 		}
+		soln.gover = gover
+		soln.igv = goVersionInputs(s.rd.gover, s.rd.govers)
+		soln.ibc = s.rd.buildctx
+		if s.decisions != nil {
+			soln.dec = make(map[ProjectRoot]Decision, len(all))
+			for pa := range all {
+				if d, has := s.decisions[pa.id.ProjectRoot]; has {
+					soln.dec[pa.id.ProjectRoot] = d
+				}
+			}
+		}
 	}
 
 	s.traceFinish(soln, err)
 	if s.tl != nil {
 		s.mtr.dump(s.tl)
 	}
+	sendStepEvent(Event{Kind: EventSolveFinished}, start, err)
 	return soln, err
 }
 
+// effectiveGoVersion returns the newest of the minimum Go versions declared by
+// the root project and by the selected versions of its dependencies.
+func (s *solver) effectiveGoVersion(all map[atom]map[string]struct{}) string {
+	gover := s.rd.gover
+	for pa := range all {
+		m, _, err := s.b.GetManifestAndLock(pa.id, pa.v, s.rd.an)
+		if err != nil {
+			continue
+		}
+		if need := minGoVersionOf(m); need != "" && (gover == "" || GoVersionExceeds(need, gover)) {
+			gover = need
+		}
+	}
+	return gover
+}
+
 // solve is the top-level loop for the solving process.
 func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error) {
 	// Pull out the donechan once up front so that we're not potentially
@@ -536,6 +683,9 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 			}
 
 			s.vqs = append(s.vqs, queue)
+			if s.rd.maxatt > 0 && len(s.vqs) > len(s.best) {
+				s.recordBest()
+			}
 		} else {
 			s.mtr.push("add-atom")
 			// We're just trying to add packages to an already-selected project.
@@ -585,8 +735,16 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 		}
 	}
 
-	// Getting this far means we successfully found a solution. Combine the
-	// selected projects and packages.
+	// Getting this far means we successfully found a solution.
+	if s.rd.recdec {
+		s.recordDecisions()
+	}
+	return s.selectedAtoms(), nil
+}
+
+// selectedAtoms combines the current selections into the set of selected atoms,
+// each with its selected packages.
+func (s *solver) selectedAtoms() map[atom]map[string]struct{} {
 	projs := make(map[atom]map[string]struct{})
 
 	// Skip the first project. It's always the root, and that shouldn't be
@@ -602,7 +760,38 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 			pm[path] = struct{}{}
 		}
 	}
-	return projs, nil
+	return projs
+}
+
+// recordBest records the current selections as the best partial solution, along
+// with the projects still waiting to be selected.
+func (s *solver) recordBest() {
+	s.best = s.selectedAtoms()
+	s.bestUnplaced = s.bestUnplaced[:0]
+	seen := make(map[ProjectRoot]bool)
+	for _, bmi := range s.unsel.sl {
+		if _, is := s.sel.selected(bmi.id); !is && !seen[bmi.id.ProjectRoot] {
+			seen[bmi.id.ProjectRoot] = true
+			s.bestUnplaced = append(s.bestUnplaced, bmi.id.ProjectRoot)
+		}
+	}
+}
+
+// budgetExceeded returns the error reporting that the attempt budget has run
+// out, holding the best partial solution found.
+func (s *solver) budgetExceeded() error {
+	e := &BudgetExceededError{
+		Attempts: s.attempts,
+		Unplaced: append([]ProjectRoot(nil), s.bestUnplaced...),
+	}
+	for pa, pl := range s.best {
+		e.Partial = append(e.Partial, pa2lp(pa, pl))
+	}
+	sort.Slice(e.Partial, func(i, j int) bool {
+		return e.Partial[i].Ident().Less(e.Partial[j].Ident())
+	})
+	sort.Slice(e.Unplaced, func(i, j int) bool { return e.Unplaced[i] < e.Unplaced[j] })
+	return e
 }
 
 // selectRoot is a specialized selectAtom, used solely to initially
@@ -625,6 +814,10 @@ func (s *solver) selectRoot() error {
 	}
 
 	for _, dep := range deps {
+		if s.group != nil && !s.group[dep.Ident.ProjectRoot] {
+			continue
+		}
+
 		// If we have no lock, or if this dep isn't in the lock, then prefetch
 		// it. See longer explanation in selectAtom() for how we benefit from
 		// parallelism here.
@@ -815,7 +1008,7 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 
 	var lockv Version
 	if len(s.rd.rlm) > 0 {
-		lockv, err = s.getLockVersionIfValid(id)
+		lockv, err = s.getLockVersionIfValid(id, bmi.fromRoot)
 		if err != nil {
 			// Can only get an error here if an upgrade was expressly requested on
 			// code that exists only in vendor
@@ -869,12 +1062,24 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 		prefv = bmi.prefv
 	}
 
+	frozen := s.rd.frozen[id.ProjectRoot] && lockv != nil
+	if frozen {
+		// A frozen project may only have its locked version, so there is no
+		// need to look for a preferred one, nor to ever list the others.
+		prefv = nil
+	}
+
 	q, err := newVersionQueue(id, lockv, prefv, s.b)
 	if err != nil {
 		// TODO(sdboyer) this particular err case needs to be improved to be ONLY for cases
 		// where there's absolutely nothing findable about a given project name
 		return nil, err
 	}
+	if frozen {
+		q.allLoaded = true
+		s.traceCheckQueue(q, bmi, false, 1)
+		return q, s.findValidVersion(q, bmi.pl)
+	}
 
 	// Hack in support for revisions.
 	//
@@ -961,10 +1166,12 @@ func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 //
 // If any of these three conditions are true (or if the id cannot be found in
 // the root lock), then no atom will be returned.
-func (s *solver) getLockVersionIfValid(id ProjectIdentifier) (Version, error) {
-	// If the project is specifically marked for changes, then don't look for a
-	// locked version.
-	if _, explicit := s.rd.chng[id.ProjectRoot]; explicit || s.rd.chngall {
+func (s *solver) getLockVersionIfValid(id ProjectIdentifier, fromRoot bool) (Version, error) {
+	// If the project is specifically marked for changes, or the lock is not to
+	// be preferred for it, then don't look for a locked version - unless it is
+	// frozen, in which case nothing but the locked version will do.
+	_, explicit := s.rd.chng[id.ProjectRoot]
+	if !s.rd.frozen[id.ProjectRoot] && (explicit || s.rd.chngall || !s.rd.lockpref.prefersLock(fromRoot)) {
 		// For projects with an upstream or cache repository, it's safe to
 		// ignore what's in the lock, because there's presumably more versions
 		// to be found and attempted in the repository. If it's only in vendor,
@@ -1011,6 +1218,11 @@ func (s *solver) backtrack(ctx context.Context) (bool, error) {
 		return false, nil
 	}
 
+	if s.rd.maxatt > 0 && s.attempts >= s.rd.maxatt {
+		return false, s.budgetExceeded()
+	}
+
+	CountMetric(MetricBacktracks, 1)
 	donechan := ctx.Done()
 	s.mtr.push("backtrack")
 	defer s.mtr.pop()
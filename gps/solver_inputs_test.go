@@ -147,7 +147,22 @@ func TestBadSolveOpts(t *testing.T) {
 		t.Error("Prepare should have given error on ToChange with item not present in Lock, but gave:", err)
 	}
 
-	params.Lock, params.ToChange = nil, nil
+	params.ToChange, params.Frozen = []ProjectRoot{"bar"}, []ProjectRoot{"bar"}
+	_, err = Prepare(params, sm)
+	if err == nil {
+		t.Errorf("Should have errored on ToChange containing frozen project")
+	} else if !strings.Contains(err.Error(), "cannot update bar as it is frozen") {
+		t.Error("Prepare should have given error on ToChange with frozen item, but gave:", err)
+	}
+
+	params.Lock, params.ToChange, params.Frozen = nil, nil, nil
+	params.MaxAttempts = -1
+	_, err = Prepare(params, sm)
+	if err == nil {
+		t.Errorf("Should have errored on negative MaxAttempts")
+	}
+
+	params.MaxAttempts = 0
 	_, err = Prepare(params, sm)
 	if err != nil {
 		t.Error("Basic conditions satisfied, prepare should have completed successfully, err as:", err)
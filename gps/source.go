@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/golang/dep/gps/pkgtree"
 	"github.com/pkg/errors"
@@ -69,6 +70,22 @@ type sourceCoordinator struct {
 	cachedir   string
 	cache      sourceCache
 	logger     *log.Logger
+
+	// resumableFetches enables resumable cloning in the sources that support
+	// it. It must be set before any sources are created.
+	resumableFetches bool
+	// tagsOnly holds the projects whose sources are to fetch only tags from
+	// their upstreams; see SourceMgr.FetchTagsOnly. Guarded by srcmut.
+	tagsOnly map[ProjectRoot]bool
+	// metadataOnly is set on the sourceGateways created; see
+	// SourceManagerConfig.MetadataOnly.
+	metadataOnly bool
+	// The protocols over which to reach the upstreams of sources.
+	protocols ProtocolPreferences
+	// The templates from which sources are resolved.
+	templates SourceTemplates
+	// The health of the upstreams of sources, across runs.
+	health *sourceHealthTracker
 }
 
 // newSourceCoordinator returns a new sourceCoordinator.
@@ -86,6 +103,7 @@ func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string,
 		srcs:       make(map[string]*sourceGateway),
 		nameToURL:  make(map[string]string),
 		protoSrcs:  make(map[string][]chan srcReturn),
+		health:     loadSourceHealth(cachedir),
 	}
 }
 
@@ -93,6 +111,9 @@ func (sc *sourceCoordinator) close() {
 	if err := sc.cache.close(); err != nil {
 		sc.logger.Println(errors.Wrap(err, "failed to close the source cache"))
 	}
+	if err := sc.health.save(); err != nil {
+		sc.logger.Println(errors.Wrap(err, "failed to record the health of sources"))
+	}
 }
 
 func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id ProjectIdentifier) (*sourceGateway, error) {
@@ -100,7 +121,10 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 		return nil, err
 	}
 
-	normalizedName := id.normalizedSource()
+	normalizedName, err := sc.templates.sourceFor(id)
+	if err != nil {
+		return nil, err
+	}
 
 	sc.srcmut.RLock()
 	if url, has := sc.nameToURL[normalizedName]; has {
@@ -213,7 +237,8 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 	var srcGate *sourceGateway
 	var url, unfoldedURL string
 	var errs errorSlice
-	for _, m := range pd.mb {
+	// Try the candidates that failed on earlier runs last.
+	for i, m := range sc.health.order(sc.protocols.apply(string(id.ProjectRoot), pd.mb)) {
 		url = m.URL().String()
 		if notFolded {
 			// If the normalizedName and foldedNormalName differ, then we're pretty well
@@ -225,20 +250,53 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 			srcGate = sg
 			break
 		}
+		if up := upstreamURL(m); up != nil {
+			if err := sc.supervisor.network.check(up.Hostname(), string(id.ProjectRoot), up.String()); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		start := time.Now()
 		src, err := m.try(ctx, sc.cachedir)
 		if err == nil {
+			if rs, ok := src.(resumableSource); ok && sc.resumableFetches {
+				rs.enableResumableFetches(sc.logger)
+			}
+			if ts, ok := src.(tagsOnlySource); ok && sc.tagsOnly[id.ProjectRoot] {
+				ts.fetchTagsOnly()
+			}
 			cache := sc.cache.newSingleSourceCache(id)
 			srcGate, err = newSourceGateway(ctx, src, sc.supervisor, sc.cachedir, cache)
 			if err == nil {
-				sc.srcs[url] = srcGate
-				break
+				srcGate.metadataOnly = sc.metadataOnly
 			}
 		}
+		if upstreamURL(m) != nil && ctx.Err() == nil {
+			sc.health.record(m.URL().String(), time.Since(start), err)
+		}
+		if err == nil {
+			sc.srcs[url] = srcGate
+			if i > 0 {
+				CountMetric(MetricSourceFailovers, 1)
+			}
+			break
+		}
 		errs = append(errs, err)
 	}
 	if srcGate == nil {
-		doReturn(nil, errs)
-		return nil, errs
+		// If every source was ruled out by the network policy, say so plainly.
+		var err error = errs
+		denied := len(errs) > 0
+		for _, e := range errs {
+			if _, ok := e.(*NetworkPermissionError); !ok {
+				denied = false
+			}
+		}
+		if denied {
+			err = errs[0]
+		}
+		doReturn(nil, err)
+		return nil, err
 	}
 
 	// Record the name -> URL mapping, making sure that we also get the
@@ -268,6 +326,10 @@ type sourceGateway struct {
 	cache    singleSourceCache
 	mu       sync.Mutex // global lock, serializes all behaviors
 	suprvsr  *supervisor
+	// metadataOnly has manifests, locks and package trees that are not
+	// cached read from the hosting service of the source where possible,
+	// rather than from a local copy of it, until one is needed anyway.
+	metadataOnly bool
 }
 
 // newSourceGateway returns a new gateway for src. If the source exists locally,
@@ -373,7 +435,7 @@ func (sg *sourceGateway) exportPrunedVersionTo(ctx context.Context, lp LockedPro
 
 	if fastprune, ok := sg.src.(sourceFastPrune); ok {
 		return sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctExportTree, func(ctx context.Context) error {
-			return fastprune.exportPrunedRevisionTo(ctx, r, lp.Packages(), prune, to)
+			return fastprune.exportPrunedRevisionTo(ctx, r, lp, prune, to)
 		})
 	}
 
@@ -396,10 +458,20 @@ func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 	}
 
 	m, l, has := sg.cache.getManifestAndLock(r, an.Info())
+	countCacheLookup(has)
 	if has {
 		return m, l, nil
 	}
 
+	if fa, ok := an.(MetadataFilesAnalyzer); ok && sg.metadataOnly && sg.srcState&sourceExistsLocally == 0 {
+		m, l, err = sg.hostedManifestAndLock(ctx, pr, r, fa)
+		if err == nil {
+			sg.cache.setManifestAndLock(r, an.Info(), m, l)
+			return m, l, nil
+		}
+		// Fall back to the source itself.
+	}
+
 	err = sg.require(ctx, sourceExistsLocally)
 	if err != nil {
 		return nil, nil, err
@@ -449,10 +521,20 @@ func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Ver
 	}
 
 	ptree, has := sg.cache.getPackageTree(r, pr)
+	countCacheLookup(has)
 	if has {
 		return ptree, nil
 	}
 
+	if sg.metadataOnly && sg.srcState&sourceExistsLocally == 0 {
+		ptree, err = sg.hostedPackageTree(ctx, pr, r)
+		if err == nil {
+			sg.cache.setPackageTree(r, ptree)
+			return ptree, nil
+		}
+		// Fall back to the source itself.
+	}
+
 	err = sg.require(ctx, sourceExistsLocally)
 	if err != nil {
 		return pkgtree.PackageTree{}, err
@@ -537,7 +619,9 @@ func (sg *sourceGateway) listVersions(ctx context.Context) ([]PairedVersion, err
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
 
-	if pvs, ok := sg.cache.getAllVersions(); ok {
+	pvs, ok := sg.cache.getAllVersions()
+	countCacheLookup(ok)
+	if ok {
 		return pvs, nil
 	}
 
@@ -571,6 +655,43 @@ func (sg *sourceGateway) revisionPresentIn(ctx context.Context, r Revision) (boo
 	return present, err
 }
 
+func (sg *sourceGateway) revisionTime(ctx context.Context, r Revision) (time.Time, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	err := sg.require(ctx, sourceExistsLocally)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ds, ok := sg.src.(datedSource)
+	if !ok {
+		return time.Time{}, errors.Errorf("%s sources do not record when revisions were made", sg.src.sourceType())
+	}
+	return ds.revisionTime(r)
+}
+
+func (sg *sourceGateway) revisionReachableFrom(ctx context.Context, r, head Revision) (bool, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	// The local repository must be up to date for head to be in it.
+	err := sg.require(ctx, sourceExistsLocally|sourceHasLatestLocally)
+	if err != nil {
+		return false, err
+	}
+
+	as, ok := sg.src.(ancestrySource)
+	if !ok {
+		return false, errors.Errorf("%s sources do not record the ancestry of revisions", sg.src.sourceType())
+	}
+	present, err := sg.src.revisionPresentIn(r)
+	if err != nil || !present {
+		return false, err
+	}
+	return as.isAncestor(ctx, r, head)
+}
+
 func (sg *sourceGateway) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
@@ -600,10 +721,13 @@ func (sg *sourceGateway) sourceExistsUpstream(ctx context.Context) (sourceState,
 
 // initLocal initializes the source locally and returns the resulting sourceState.
 func (sg *sourceGateway) initLocal(ctx context.Context) (sourceState, error) {
-	if err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
+	start := time.Now()
+	err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
 		err := sg.src.initLocal(ctx)
 		return errors.Wrapf(err, "failed to fetch source for %s", sg.src.upstreamURL())
-	}); err != nil {
+	})
+	sendStepEvent(Event{Kind: EventSourceFetched, Source: sg.src.upstreamURL(), Clone: true}, start, err)
+	if err != nil {
 		return 0, err
 	}
 	return sourceExistsUpstream | sourceExistsLocally | sourceHasLatestLocally, nil
@@ -621,11 +745,14 @@ func (sg *sourceGateway) loadLatestVersionList(ctx context.Context) (sourceState
 		addlState |= as
 	}
 	var pvl []PairedVersion
-	if err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctListVersions, func(ctx context.Context) error {
+	start := time.Now()
+	err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctListVersions, func(ctx context.Context) error {
 		var err error
 		pvl, err = sg.src.listVersions(ctx)
 		return errors.Wrapf(err, "failed to list versions for %s", sg.src.upstreamURL())
-	}); err != nil {
+	})
+	sendStepEvent(Event{Kind: EventVersionsListed, Source: sg.src.upstreamURL()}, start, err)
+	if err != nil {
 		return addlState, err
 	}
 	sg.cache.setVersionMap(pvl)
@@ -638,6 +765,12 @@ func (sg *sourceGateway) loadLatestVersionList(ctx context.Context) (sourceState
 func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (err error) {
 	todo := (^sg.srcState) & wanted
 	var flag sourceState = 1
+	if todo != 0 {
+		// Whatever is done to reach the wanted state goes to the upstream.
+		if ctx, err = sg.suprvsr.withUpstream(ctx, sg.src.upstreamURL()); err != nil {
+			return err
+		}
+	}
 
 	for todo != 0 {
 		if todo&flag != 0 {
@@ -657,9 +790,11 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (err e
 					addlState, err = sg.loadLatestVersionList(ctx)
 				}
 			case sourceHasLatestLocally:
+				start := time.Now()
 				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceFetch, func(ctx context.Context) error {
 					return sg.src.updateLocal(ctx)
 				})
+				sendStepEvent(Event{Kind: EventSourceFetched, Source: sg.src.upstreamURL()}, start, err)
 				addlState = sourceExistsUpstream | sourceExistsLocally
 			}
 
@@ -704,7 +839,39 @@ type source interface {
 	listVersionsRequiresLocal() bool
 }
 
+// datedSource is implemented by sources that record when each of their
+// revisions was made.
+type datedSource interface {
+	source
+	revisionTime(Revision) (time.Time, error)
+}
+
+// ancestrySource is implemented by sources that can tell whether one revision
+// is an ancestor of another.
+type ancestrySource interface {
+	source
+	isAncestor(ctx context.Context, r, of Revision) (bool, error)
+}
+
+// resumableSource is implemented by sources that can resume an interrupted
+// initLocal instead of starting over.
+type resumableSource interface {
+	source
+	// enableResumableFetches makes subsequent calls to initLocal resumable,
+	// reporting their progress to logger.
+	enableResumableFetches(logger *log.Logger)
+}
+
+// tagsOnlySource is implemented by sources that can limit what they fetch from
+// upstream to tags.
+type tagsOnlySource interface {
+	source
+	// fetchTagsOnly makes subsequent calls to initLocal and updateLocal fetch
+	// only tags, and listVersions list only tags.
+	fetchTagsOnly()
+}
+
 type sourceFastPrune interface {
 	source
-	exportPrunedRevisionTo(context.Context, Revision, []string, PruneOptions, string) error
+	exportPrunedRevisionTo(context.Context, Revision, LockedProject, PruneOptions, string) error
 }
@@ -0,0 +1,615 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// A source bundle is a directory of archives, one per project, from which a
+// SourceMgr can serve projects without any network access.
+//
+// Each archive is a gzipped tarball whose first entry, bundleIndexName,
+// describes the project and the versions it holds. It is followed by the code
+// tree of each of those versions, under a directory named for its revision.
+const (
+	bundleIndexName  = "bundle.json"
+	bundleArchiveExt = ".tar.gz"
+)
+
+// bundleIndex describes the contents of a source bundle archive.
+type bundleIndex struct {
+	Root     string          `json:"root"`
+	Source   string          `json:"source,omitempty"`
+	Versions []bundleVersion `json:"versions"`
+}
+
+// bundleVersion records a single version held in a source bundle archive.
+type bundleVersion struct {
+	// Type is one of branch, default-branch, semver, version or revision.
+	Type     string   `json:"type"`
+	Name     string   `json:"name,omitempty"`
+	Revision Revision `json:"revision"`
+}
+
+func newBundleVersion(v Version) (bundleVersion, error) {
+	switch tv := v.(type) {
+	case Revision:
+		return bundleVersion{Type: "revision", Revision: tv}, nil
+	case PairedVersion:
+		bv := bundleVersion{Name: tv.String(), Revision: tv.Revision()}
+		switch tv.Type() {
+		case IsBranch:
+			bv.Type = "branch"
+			if tv.Unpair().(branchVersion).isDefault {
+				bv.Type = "default-branch"
+			}
+		case IsSemver:
+			bv.Type = "semver"
+		case IsVersion:
+			bv.Type = "version"
+		}
+		return bv, nil
+	}
+	return bundleVersion{}, errors.Errorf("version %s has no revision; only revisions and paired versions can be bundled", v)
+}
+
+func (bv bundleVersion) version() (Version, error) {
+	var uv UnpairedVersion
+	switch bv.Type {
+	case "revision":
+		return bv.Revision, nil
+	case "branch":
+		uv = NewBranch(bv.Name)
+	case "default-branch":
+		uv = newDefaultBranch(bv.Name)
+	case "semver", "version":
+		uv = NewVersion(bv.Name)
+	default:
+		return nil, errors.Errorf("unknown version type %q", bv.Type)
+	}
+	return uv.Pair(bv.Revision), nil
+}
+
+// WriteSourceBundle exports each of the given versions of the project pi from
+// sm, and archives them into a source bundle in dir, replacing any existing
+// archive for the project. Each version must be a Revision or a
+// PairedVersion.
+//
+// SourceManagerConfig.BundleDir directs a SourceMgr to serve projects from
+// such a directory.
+func WriteSourceBundle(ctx context.Context, sm Exporter, dir string, pi ProjectIdentifier, versions []Version) error {
+	idx := bundleIndex{
+		Root:   string(pi.ProjectRoot),
+		Source: pi.Source,
+	}
+
+	td, err := ioutil.TempDir("", "dep-bundle")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	exported := make(map[Revision]bool)
+	for _, v := range versions {
+		bv, err := newBundleVersion(v)
+		if err != nil {
+			return errors.Wrapf(err, "failed to bundle %s", pi)
+		}
+		idx.Versions = append(idx.Versions, bv)
+
+		if exported[bv.Revision] {
+			continue
+		}
+		if err := sm.ExportProject(ctx, pi, bv.Revision, filepath.Join(td, string(bv.Revision))); err != nil {
+			return errors.Wrapf(err, "failed to export %s at %s", pi, v)
+		}
+		exported[bv.Revision] = true
+	}
+
+	path := filepath.Join(dir, bundleArchiveName(pi.normalizedSource()))
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create bundle for %s", pi)
+	}
+	defer os.Remove(f.Name())
+
+	err = writeBundleArchive(f, td, idx)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write bundle for %s", pi)
+	}
+	return errors.Wrapf(os.Rename(f.Name(), path), "failed to write bundle for %s", pi)
+}
+
+// bundleArchiveName returns the file name of the archive for the project with
+// the given normalized source name.
+func bundleArchiveName(name string) string {
+	return sanitizer.Replace(name) + bundleArchiveExt
+}
+
+func writeBundleArchive(w io.Writer, root string, idx bundleIndex) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(&tar.Header{Name: bundleIndexName, Mode: 0644, Size: int64(len(b))}); err != nil {
+		return err
+	}
+	if _, err = tw.Write(b); err != nil {
+		return err
+	}
+	if err = writeTreeToTar(tw, root); err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// writeTreeToTar writes the tree under root to tw, with the names of its
+// entries relative to root.
+func writeTreeToTar(tw *tar.Writer, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// openBundleArchive opens the archive at path and reads its index, returning
+// a reader positioned at the first entry after it.
+func openBundleArchive(path string) (*tar.Reader, io.Closer, bundleIndex, error) {
+	var idx bundleIndex
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, idx, err
+	}
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, idx, errors.Wrapf(err, "%s is not a source bundle archive", path)
+	}
+	tr := tar.NewReader(gzr)
+	hdr, err := tr.Next()
+	if err == nil && hdr.Name != bundleIndexName {
+		err = errors.Errorf("first entry is %s, not %s", hdr.Name, bundleIndexName)
+	}
+	if err == nil {
+		err = json.NewDecoder(tr).Decode(&idx)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, idx, errors.Wrapf(err, "%s is not a source bundle archive", path)
+	}
+	return tr, f, idx, nil
+}
+
+// bundleDeducer deduces project roots solely from the projects held in a
+// source bundle, so that no network access is needed.
+type bundleDeducer struct {
+	dir string
+	// archives maps both the root and, if it differs, the source of each
+	// bundled project to the path to its archive.
+	archives map[string]string
+}
+
+func newBundleDeducer(dir string) (*bundleDeducer, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+bundleArchiveExt))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read source bundle %s", dir)
+	}
+	if len(paths) == 0 {
+		return nil, errors.Errorf("no source bundle archives found in %s", dir)
+	}
+
+	bd := &bundleDeducer{
+		dir:      dir,
+		archives: make(map[string]string),
+	}
+	for _, path := range paths {
+		_, c, idx, err := openBundleArchive(path)
+		if err != nil {
+			return nil, err
+		}
+		c.Close()
+
+		bd.archives[idx.Root] = path
+		if idx.Source != "" {
+			bd.archives[idx.Source] = path
+		}
+	}
+	return bd, nil
+}
+
+func (bd *bundleDeducer) deduceRootPath(ctx context.Context, path string) (pathDeduction, error) {
+	var root string
+	for name := range bd.archives {
+		if isPathPrefixOrEqual(name, path) && len(name) > len(root) {
+			root = name
+		}
+	}
+	if root == "" {
+		return pathDeduction{}, errors.Errorf("%s is not in the source bundle at %s", path, bd.dir)
+	}
+
+	return pathDeduction{
+		root: root,
+		mb:   maybeSources{maybeBundleSource{archive: bd.archives[root]}},
+	}, nil
+}
+
+type maybeBundleSource struct {
+	archive string
+}
+
+func (m maybeBundleSource) try(ctx context.Context, cachedir string) (source, error) {
+	_, c, idx, err := openBundleArchive(m.archive)
+	if err != nil {
+		return nil, err
+	}
+	c.Close()
+
+	dest, err := unpackedSourceCachePath(cachedir, m.archive)
+	if err != nil {
+		return nil, err
+	}
+	return &bundleSource{
+		archive: m.archive,
+		dest:    dest,
+		idx:     idx,
+	}, nil
+}
+
+func (m maybeBundleSource) URL() *url.URL {
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(m.archive)}
+}
+
+func (m maybeBundleSource) String() string {
+	return fmt.Sprintf("%T: %s", m, m.archive)
+}
+
+// bundleSource is a source served from a source bundle archive. The archive
+// is extracted into the cache, with the tree of each version in a directory
+// named for its revision.
+type bundleSource struct {
+	archive string
+	dest    string
+	idx     bundleIndex
+}
+
+func (s *bundleSource) existsLocally(ctx context.Context) bool {
+	isDir, _ := fs.IsDir(s.dest)
+	return isDir
+}
+
+func (s *bundleSource) existsUpstream(ctx context.Context) bool {
+	_, err := os.Stat(s.archive)
+	return err == nil
+}
+
+func (s *bundleSource) upstreamURL() string {
+	return s.archive
+}
+
+// initLocal extracts the archive, then moves it into place, so that an
+// interrupted extraction is never mistaken for a complete one.
+func (s *bundleSource) initLocal(ctx context.Context) error {
+	tr, c, idx, err := openBundleArchive(s.archive)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	tmp := s.dest + ".tmp"
+	if err = os.RemoveAll(tmp); err != nil {
+		return errors.Wrap(err, "failed to clear incomplete extraction")
+	}
+	if err = extractBundleArchive(ctx, tr, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return errors.Wrapf(err, "failed to extract %s", s.archive)
+	}
+	if err = os.RemoveAll(s.dest); err != nil {
+		return errors.Wrap(err, "failed to remove previous extraction")
+	}
+	if err = os.Rename(tmp, s.dest); err != nil {
+		return errors.Wrapf(err, "failed to extract %s", s.archive)
+	}
+	s.idx = idx
+	return nil
+}
+
+// extractBundleArchive extracts the entries read from tr, an archive of
+// untrusted origin, beneath to. Entries that would be written, through
+// symlinks, or whose symlinks would resolve, outside of to are refused.
+func extractBundleArchive(ctx context.Context, tr *tar.Reader, to string) error {
+	return extractTarArchive(ctx, tr, to, true)
+}
+
+// extractTarArchive extracts the entries read from tr beneath to. If
+// untrusted, symlinks are checked as for extractBundleArchive; otherwise they
+// are restored as they were written, wherever they point.
+func extractTarArchive(ctx context.Context, tr *tar.Reader, to string, untrusted bool) error {
+	if err := os.MkdirAll(to, 0777); err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(hdr.Name, "/"))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) || filepath.Clean(name) != name {
+			return errors.Errorf("archive entry %q is outside of the archive root", hdr.Name)
+		}
+		path := filepath.Join(to, name)
+		// Entries must not be written through symlinks extracted before them,
+		// which could point anywhere.
+		if untrusted {
+			if err := checkNoSymlinkParents(to, name); err != nil {
+				return errors.Wrapf(err, "archive entry %q", hdr.Name)
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0777)
+		case tar.TypeSymlink:
+			if untrusted {
+				if err := checkSymlinkTarget(to, name, hdr.Linkname); err != nil {
+					return errors.Wrapf(err, "archive entry %q", hdr.Name)
+				}
+			}
+			err = os.Symlink(hdr.Linkname, path)
+		case tar.TypeReg, tar.TypeRegA:
+			err = extractBundleFile(tr, path, hdr.FileInfo().Mode())
+		default:
+			err = errors.Errorf("archive entry %q has unsupported type %q", hdr.Name, hdr.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkNoSymlinkParents returns an error if any of the directories leading to
+// the relative path name within root, other than root itself, is a symlink.
+func checkNoSymlinkParents(root, name string) error {
+	dir := root
+	elems := strings.Split(name, string(filepath.Separator))
+	for _, elem := range elems[:len(elems)-1] {
+		dir = filepath.Join(dir, elem)
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("is within %s, which is a symlink", dir)
+		}
+	}
+	return nil
+}
+
+// checkSymlinkTarget returns an error unless the symlink at the relative path
+// name within root, linking to target, resolves within root. Targets going
+// through other symlinks, or going up from directories not yet extracted, are
+// refused, as entries extracted later could then make them lead elsewhere.
+func checkSymlinkTarget(root, name, target string) error {
+	target = filepath.FromSlash(target)
+	if filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+		return errors.Errorf("links to %q, an absolute path", target)
+	}
+	var elems []string
+	if dir := filepath.Dir(name); dir != "." {
+		elems = strings.Split(dir, string(filepath.Separator))
+	}
+	for _, elem := range strings.Split(target, string(filepath.Separator)) {
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(elems) == 0 {
+				return errors.Errorf("links to %q, outside of the archive root", target)
+			}
+			fi, err := os.Lstat(filepath.Join(append([]string{root}, elems...)...))
+			if err != nil || !fi.IsDir() {
+				return errors.Errorf("links to %q, through a path that is not a directory", target)
+			}
+			elems = elems[:len(elems)-1]
+			continue
+		}
+		elems = append(elems, elem)
+		fi, err := os.Lstat(filepath.Join(append([]string{root}, elems...)...))
+		if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("links to %q, through another symlink", target)
+		}
+	}
+	return nil
+}
+
+func extractBundleFile(r io.Reader, path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// updateLocal extracts the archive again, in case it has been replaced since
+// it was last extracted.
+func (s *bundleSource) updateLocal(ctx context.Context) error {
+	return s.initLocal(ctx)
+}
+
+func (s *bundleSource) maybeClean(ctx context.Context) error {
+	return nil
+}
+
+func (s *bundleSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	var pvl []PairedVersion
+	for _, bv := range s.idx.Versions {
+		v, err := bv.version()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid version in %s", s.archive)
+		}
+		if pv, ok := v.(PairedVersion); ok {
+			pvl = append(pvl, pv)
+		}
+	}
+	return pvl, nil
+}
+
+func (s *bundleSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	dir, err := s.revisionDir(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, l, err := an.DeriveManifestAndLock(dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+	return prepManifest(m), l, nil
+}
+
+func (s *bundleSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	dir, err := s.revisionDir(r)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return pkgtree.ListPackages(dir, string(pr))
+}
+
+func (s *bundleSource) revisionPresentIn(r Revision) (bool, error) {
+	for _, bv := range s.idx.Versions {
+		if bv.Revision == r {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *bundleSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	var found Revision
+	for _, bv := range s.idx.Versions {
+		if strings.HasPrefix(string(bv.Revision), string(r)) {
+			if found != "" && found != bv.Revision {
+				return "", errors.Errorf("revision %s is ambiguous in %s", r, s.archive)
+			}
+			found = bv.Revision
+		}
+	}
+	if found == "" {
+		return "", errors.Errorf("revision %s is not in %s", r, s.archive)
+	}
+	return found, nil
+}
+
+func (s *bundleSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	dir, err := s.revisionDir(r)
+	if err != nil {
+		return err
+	}
+	// Only make the parent dir, as CopyDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	return fs.CopyDir(dir, to)
+}
+
+// revisionDir returns the directory holding the extracted tree of revision r.
+func (s *bundleSource) revisionDir(r Revision) (string, error) {
+	dir := filepath.Join(s.dest, string(r))
+	if isDir, _ := fs.IsDir(dir); !isDir {
+		return "", errors.Errorf("revision %s is not in %s", r, s.archive)
+	}
+	return dir, nil
+}
+
+func (s *bundleSource) sourceType() string {
+	return "bundle"
+}
+
+func (s *bundleSource) existsCallsListVersions() bool {
+	return false
+}
+
+func (s *bundleSource) listVersionsRequiresLocal() bool {
+	return false
+}
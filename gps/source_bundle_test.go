@@ -0,0 +1,192 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSourceBundleSolveAndExport(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("bundle")
+	h.TempDir("cache")
+	h.TempDir("vendor")
+
+	pi := mkPI("example.com/dep")
+	err := WriteSourceBundle(context.Background(), newTestMemorySourceManager(), h.Path("bundle"), pi, []Version{
+		NewVersion("v1.0.0").Pair("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		NewBranch("master").Pair("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir:  h.Path("cache"),
+		BundleDir: h.Path("bundle"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+
+	if root, err := sm.DeduceProjectRoot("example.com/dep/sub"); err != nil || root != "example.com/dep" {
+		t.Errorf("unexpected deduced root %q, err %v", root, err)
+	}
+	if _, err := sm.DeduceProjectRoot("example.com/other"); err == nil {
+		t.Error("expected an error deducing a project absent from the bundle")
+	}
+
+	pvl, err := sm.ListVersions(pi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pvl) != 2 {
+		t.Fatalf("expected the two bundled versions, got %v", pvl)
+	}
+	if c, err := sm.InferConstraint("bbbbbbb", pi); err != nil || c != Revision("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb") {
+		t.Errorf("unexpected inferred constraint %v, err %v", c, err)
+	}
+
+	params := SolveParameters{
+		RootDir:         h.Path("."),
+		ProjectAnalyzer: naiveAnalyzer{},
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "example.com/root",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"example.com/root": {
+					P: pkgtree.Package{
+						ImportPath: "example.com/root",
+						Name:       "root",
+						Imports:    []string{"example.com/dep"},
+					},
+				},
+			},
+		},
+	}
+	s, err := Prepare(params, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soln, err := s.Solve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := soln.Projects()[0].Version().String(); v != "v1.0.0" {
+		t.Errorf("expected bundled version v1.0.0 to be selected, got %s", v)
+	}
+
+	if err = WriteDepTree(h.Path("vendor"), soln, sm, defaultCascadingPruneOptions(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(h.Path("vendor"), "example.com", "dep", "dep.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package dep // v1.0.0\n"; string(data) != want {
+		t.Errorf("unexpected exported contents: %q", data)
+	}
+}
+
+func TestSourceBundleRejectsUnpairedVersions(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("bundle")
+
+	err := WriteSourceBundle(context.Background(), newTestMemorySourceManager(), h.Path("bundle"), mkPI("example.com/dep"), []Version{
+		NewVersion("v1.0.0"),
+	})
+	if err == nil {
+		t.Fatal("expected an error bundling a version without a revision")
+	}
+}
+
+func TestExtractBundleArchiveRejectsEscapes(t *testing.T) {
+	type entry struct {
+		name, link, data string
+	}
+	cases := map[string][]entry{
+		"absolute link":         {{name: "x", link: "/etc"}},
+		"link outside":          {{name: "x", link: "../outside"}},
+		"nested link outside":   {{name: "a/", link: ""}, {name: "a/x", link: "../../outside"}},
+		"write through link":    {{name: "x", link: "."}, {name: "x/passwd", data: "root"}},
+		"link through link":     {{name: "a/", link: ""}, {name: "a/x", link: ".."}, {name: "y", link: "a/x/.."}},
+		"up from missing":       {{name: "a/", link: ""}, {name: "y", link: "a/x/../.."}, {name: "a/x", link: ".."}},
+		"up from file":          {{name: "f", data: "f"}, {name: "y", link: "f/.."}},
+		"parent outside":        {{name: "../x", data: "x"}},
+		"write through nesting": {{name: "a/", link: ""}, {name: "a/b", link: "."}, {name: "a/b/c/f", data: "f"}},
+	}
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := test.NewHelper(t)
+			defer h.Cleanup()
+			h.TempDir("root")
+
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, e := range entries {
+				hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.data))}
+				switch {
+				case e.link != "":
+					hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+				case e.name[len(e.name)-1] == '/':
+					hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+				}
+				if err := tw.WriteHeader(hdr); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := tw.Write([]byte(e.data)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			to := filepath.Join(h.Path("root"), "extract")
+			if err := extractBundleArchive(context.Background(), tar.NewReader(&buf), to); err == nil {
+				t.Fatal("expected the archive to be refused")
+			}
+			if _, err := os.Lstat(filepath.Join(h.Path("root"), "passwd")); !os.IsNotExist(err) {
+				t.Error("expected nothing to be written outside of the extraction root")
+			}
+		})
+	}
+
+	// Links that stay within the root are kept.
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("root")
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "a/f", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "l", Typeflag: tar.TypeSymlink, Linkname: "a/f"},
+		{Name: "a/m", Typeflag: tar.TypeSymlink, Linkname: "../a/f"},
+		{Name: "dangling", Typeflag: tar.TypeSymlink, Linkname: "missing/file"},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	if err := extractBundleArchive(context.Background(), tar.NewReader(&buf), h.Path("root")); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(h.Path("root"), "a", "m")); err != nil || target != "../a/f" {
+		t.Errorf("expected a/m to link to ../a/f, got %q, %v", target, err)
+	}
+}
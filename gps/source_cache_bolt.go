@@ -23,7 +23,7 @@ import (
 
 // boltCacheFilename is a versioned filename for the bolt cache. The version
 // must be incremented whenever incompatible changes are made.
-const boltCacheFilename = "bolt-v1.db"
+const boltCacheFilename = "bolt-v2.db"
 
 // boltCache manages a bolt.DB cache and provides singleSourceCaches.
 type boltCache struct {
@@ -45,6 +45,9 @@ func newBoltCache(cd string, epoch int64, logger *log.Logger) (*boltCache, error
 	} else if !fi.IsDir() {
 		return nil, errors.Wrapf(err, "source cache path is not directory: %s", dir)
 	}
+	if err := unpackFile(path); err != nil {
+		return nil, errors.Wrapf(err, "failed to decompress BoltDB cache file %q", path)
+	}
 	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to open BoltDB cache file %q", path)
@@ -21,6 +21,8 @@ var (
 	cacheKeyComment      = []byte("c")
 	cacheKeyConstraint   = cacheKeyComment
 	cacheKeyError        = []byte("e")
+	cacheKeyGenerated    = []byte("f")
+	cacheKeyGoVersion    = []byte("g")
 	cacheKeyInputImports = []byte("m")
 	cacheKeyIgnored      = []byte("i")
 	cacheKeyImport       = cacheKeyIgnored
@@ -100,6 +102,26 @@ func cachePutManifest(b *bolt.Bucket, m Manifest) error {
 		}
 	}
 
+	if gover := minGoVersionOf(m); gover != "" {
+		if err := b.Put(cacheKeyGoVersion, []byte(gover)); err != nil {
+			return err
+		}
+	}
+
+	if generated := generatedFilesOf(m); len(generated) > 0 {
+		gen, err := b.CreateBucket(cacheKeyGenerated)
+		if err != nil {
+			return err
+		}
+		key := make(nuts.Key, nuts.KeyLen(uint64(len(generated)-1)))
+		for i, p := range generated {
+			key.Put(uint64(i))
+			if err := gen.Put(key, []byte(p)); err != nil {
+				return err
+			}
+		}
+	}
+
 	rm, ok := m.(RootManifest)
 	if !ok {
 		return nil
@@ -194,6 +216,22 @@ func cacheGetManifest(b *bolt.Bucket) (RootManifest, error) {
 		}
 	}
 
+	// Go version
+	if gover := b.Get(cacheKeyGoVersion); gover != nil {
+		m.gover = string(gover)
+	}
+
+	// Generated files
+	if gen := b.Bucket(cacheKeyGenerated); gen != nil {
+		err := gen.ForEach(func(_, v []byte) error {
+			m.gen = append(m.gen, string(v))
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get generated files")
+		}
+	}
+
 	// Ignored
 	if ig := b.Bucket(cacheKeyIgnored); ig != nil {
 		var igslice []string
@@ -113,7 +113,9 @@ func (test singleSourceCacheTest) run(t *testing.T) {
 				"c": true,
 				"d": true,
 			},
-			ig: pkgtree.NewIgnoredRuleset([]string{"a", "b"}),
+			ig:    pkgtree.NewIgnoredRuleset([]string{"a", "b"}),
+			gover: "1.11",
+			gen:   []string{"gen/*.go", "zz_generated.go"},
 		}
 		var l Lock = &safeLock{
 			p: []LockedProject{
@@ -425,6 +427,14 @@ func compareManifests(t *testing.T, want, got Manifest) {
 		}
 	}
 
+	if want, got := minGoVersionOf(want), minGoVersionOf(got); want != got {
+		t.Errorf("unexpected minimum Go version:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	if want, got := generatedFilesOf(want), generatedFilesOf(got); !reflect.DeepEqual(want, got) {
+		t.Errorf("unexpected generated files:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
 	wantRM, wantOK := want.(RootManifest)
 	gotRM, gotOK := got.(RootManifest)
 	if wantOK && !gotOK {
@@ -0,0 +1,159 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sourceHealthName is the name of the file, in the cache directory, holding
+// the health of the upstream sources reached by the SourceMgr.
+const sourceHealthName = "source-health.json"
+
+// SourceHealth records how the attempts to reach the upstream of a source,
+// when setting it up, have fared.
+type SourceHealth struct {
+	// The URL of the source.
+	URL string
+	// The number of attempts that have failed since the last one to succeed.
+	Failures int
+	// The times of the last successful and failed attempts, zero if there
+	// have been none.
+	LastSuccess time.Time
+	LastFailure time.Time
+	// The error of the last failed attempt.
+	LastError string `json:",omitempty"`
+	// How long the last successful attempt took.
+	Latency time.Duration
+}
+
+// Healthy reports whether the last attempt to reach the source succeeded, or
+// none has been made.
+func (h SourceHealth) Healthy() bool {
+	return h.Failures == 0
+}
+
+// sourceHealthTracker keeps the health of sources, persisting it in a cache
+// directory between runs so that sources that failed last time are tried after
+// those that did not.
+type sourceHealthTracker struct {
+	path  string
+	mu    sync.Mutex
+	m     map[string]*SourceHealth
+	dirty bool
+}
+
+// loadSourceHealth reads the health of the sources recorded in cachedir. An
+// unreadable record is started afresh.
+func loadSourceHealth(cachedir string) *sourceHealthTracker {
+	t := &sourceHealthTracker{
+		path: filepath.Join(cachedir, sourceHealthName),
+		m:    make(map[string]*SourceHealth),
+	}
+	hs, err := ReadSourceHealth(cachedir)
+	if err != nil {
+		return t
+	}
+	for i := range hs {
+		t.m[hs[i].URL] = &hs[i]
+	}
+	return t
+}
+
+// order returns mb with the candidates whose last attempts failed moved after
+// the others, fewest failures first, keeping the deduced order otherwise.
+func (t *sourceHealthTracker) order(mb maybeSources) maybeSources {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	failures := func(m maybeSource) int {
+		if h, has := t.m[m.URL().String()]; has {
+			return h.Failures
+		}
+		return 0
+	}
+	out := append(maybeSources(nil), mb...)
+	sort.SliceStable(out, func(i, j int) bool { return failures(out[i]) < failures(out[j]) })
+	return out
+}
+
+// record records an attempt to reach the source at url that took d, and failed
+// with err if it is not nil.
+func (t *sourceHealthTracker) record(url string, d time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, has := t.m[url]
+	if !has {
+		h = &SourceHealth{URL: url}
+		t.m[url] = h
+	}
+	if err != nil {
+		h.Failures++
+		h.LastFailure = time.Now()
+		h.LastError = err.Error()
+	} else {
+		h.Failures = 0
+		h.LastSuccess = time.Now()
+		h.Latency = d
+	}
+	t.dirty = true
+}
+
+// save writes the health of the sources back to the cache directory, if it
+// has changed.
+func (t *sourceHealthTracker) save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.dirty {
+		return nil
+	}
+
+	hs := make([]SourceHealth, 0, len(t.m))
+	for _, h := range t.m {
+		hs = append(hs, *h)
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].URL < hs[j].URL })
+	data, err := json.MarshalIndent(hs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := t.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return err
+	}
+	t.dirty = false
+	return nil
+}
+
+// ReadSourceHealth returns the health recorded in cachedir of the sources
+// that SourceMgrs using it have tried to reach, sorted by URL.
+func ReadSourceHealth(cachedir string) ([]SourceHealth, error) {
+	data, err := ioutil.ReadFile(filepath.Join(cachedir, sourceHealthName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var hs []SourceHealth
+	if err := json.Unmarshal(data, &hs); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", sourceHealthName)
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].URL < hs[j].URL })
+	return hs, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestSourceHealthTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mb := maybeSources{
+		maybeGitSource{url: mkurl("https://github.com/foo/bar")},
+		maybeGitSource{url: mkurl("ssh://git@github.com/foo/bar")},
+		maybeGitSource{url: mkurl("git://github.com/foo/bar")},
+	}
+
+	tr := loadSourceHealth(dir)
+	tr.record("https://github.com/foo/bar", time.Second, errors.New("connection refused"))
+	tr.record("https://github.com/foo/bar", time.Second, errors.New("connection refused"))
+	tr.record("ssh://git@github.com/foo/bar", 2*time.Second, errors.New("permission denied"))
+	tr.record("git://github.com/foo/bar", 3*time.Second, nil)
+	if err := tr.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The record survives into the next run.
+	tr = loadSourceHealth(dir)
+	var got []string
+	for _, m := range tr.order(mb) {
+		got = append(got, m.URL().String())
+	}
+	want := []string{"git://github.com/foo/bar", "ssh://git@github.com/foo/bar", "https://github.com/foo/bar"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected the healthiest sources first, %v, got %v", want, got)
+		}
+	}
+
+	hs, err := ReadSourceHealth(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hs) != 3 {
+		t.Fatalf("expected the health of 3 sources, got %v", hs)
+	}
+	if h := hs[0]; h.URL != "git://github.com/foo/bar" || !h.Healthy() || h.Latency != 3*time.Second || h.LastSuccess.IsZero() {
+		t.Errorf("expected a healthy source, got %+v", h)
+	}
+	if h := hs[1]; h.Healthy() || h.Failures != 2 || h.LastError != "connection refused" || !h.LastSuccess.IsZero() {
+		t.Errorf("expected a source failing twice, got %+v", h)
+	}
+
+	// A success clears the failures.
+	tr.record("https://github.com/foo/bar", time.Second, nil)
+	if got := tr.order(mb); got[0].URL().String() != "https://github.com/foo/bar" {
+		t.Errorf("expected a recovered source to be tried first again, got %v", got[0].URL())
+	}
+}
+
+func TestReadSourceHealthMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if hs, err := ReadSourceHealth(dir); err != nil || len(hs) != 0 {
+		t.Errorf("expected no health without a record, got %v, %v", hs, err)
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// A MetadataFilesAnalyzer is a ProjectAnalyzer that derives manifests and locks
+// from nothing but the files named by MetadataFiles, at the root of the
+// project. When solving from metadata only, those files are fetched on their
+// own from the hosting services that serve them, rather than with the whole
+// source.
+type MetadataFilesAnalyzer interface {
+	ProjectAnalyzer
+	// MetadataFiles names the files, relative to the root of a project, that
+	// DeriveManifestAndLock reads.
+	MetadataFiles() []string
+}
+
+// hostedRepoAPI describes how a hosting service serves the contents of a
+// revision of a repository on its own, for a host whose repositories are named
+// by their first two path elements.
+type hostedRepoAPI struct {
+	// rawHost is the host serving single files.
+	rawHost string
+	// rawURL returns the URL of the file at path in the tree of rev.
+	rawURL func(owner, repo, rev, path string) string
+	// archiveHost is the host serving archives.
+	archiveHost string
+	// archiveURL returns the URL of a gzipped tar archive of the tree of rev,
+	// within a single top-level directory.
+	archiveURL func(owner, repo, rev string) string
+}
+
+// hostedRepoAPIs holds the APIs serving the contents of revisions, by the host
+// of the repositories.
+var hostedRepoAPIs = map[string]hostedRepoAPI{
+	"github.com": {
+		rawHost: "raw.githubusercontent.com",
+		rawURL: func(owner, repo, rev, path string) string {
+			return "https://raw.githubusercontent.com/" + owner + "/" + repo + "/" + rev + "/" + path
+		},
+		archiveHost: "codeload.github.com",
+		archiveURL: func(owner, repo, rev string) string {
+			return "https://codeload.github.com/" + owner + "/" + repo + "/tar.gz/" + rev
+		},
+	},
+	"gitlab.com": {
+		rawHost: "gitlab.com",
+		rawURL: func(owner, repo, rev, path string) string {
+			return "https://gitlab.com/" + owner + "/" + repo + "/-/raw/" + rev + "/" + path
+		},
+		archiveHost: "gitlab.com",
+		archiveURL: func(owner, repo, rev string) string {
+			return "https://gitlab.com/" + owner + "/" + repo + "/-/archive/" + rev + "/" + repo + "-" + rev + ".tar.gz"
+		},
+	},
+	"bitbucket.org": {
+		rawHost: "bitbucket.org",
+		rawURL: func(owner, repo, rev, path string) string {
+			return "https://bitbucket.org/" + owner + "/" + repo + "/raw/" + rev + "/" + path
+		},
+		archiveHost: "bitbucket.org",
+		archiveURL: func(owner, repo, rev string) string {
+			return "https://bitbucket.org/" + owner + "/" + repo + "/get/" + rev + ".tar.gz"
+		},
+	},
+}
+
+// errNotHosted is returned when the contents of a revision cannot be had from
+// its hosting service, so that they must be read from the source itself.
+var errNotHosted = errors.New("not served by the hosting service")
+
+// hostedRepo returns the API serving the contents of revisions of the source
+// with the given upstream URL, and the owner and name of the repository.
+func hostedRepo(upstream string) (api hostedRepoAPI, owner, repo string, ok bool) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return hostedRepoAPI{}, "", "", false
+	}
+	api, ok = hostedRepoAPIs[strings.ToLower(u.Hostname())]
+	if !ok {
+		return hostedRepoAPI{}, "", "", false
+	}
+	elems := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(elems) < 2 {
+		return hostedRepoAPI{}, "", "", false
+	}
+	return api, elems[0], strings.TrimSuffix(elems[1], ".git"), true
+}
+
+// hostedManifestAndLock derives the manifest and lock of revision r of the
+// source from its metadata files, fetched from its hosting service. It returns
+// errNotHosted if the files cannot be fetched, and also if none of them is
+// found, as private repositories cannot be told apart from those without the
+// files.
+//
+// caller must hold sg.mu.
+func (sg *sourceGateway) hostedManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an MetadataFilesAnalyzer) (Manifest, Lock, error) {
+	api, owner, repo, ok := hostedRepo(sg.src.upstreamURL())
+	if !ok || !sg.suprvsr.network.Permits(api.rawHost) {
+		return nil, nil, errNotHosted
+	}
+
+	dir, err := ioutil.TempDir("", "dep-metadata")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	found := false
+	label := fmt.Sprintf("%s:%s", sg.src.upstreamURL(), an.Info())
+	err = sg.suprvsr.do(ctx, label, ctGetManifestAndLock, func(ctx context.Context) error {
+		found = false
+		for _, name := range an.MetadataFiles() {
+			has, err := fetchHostedFile(ctx, api.rawURL(owner, repo, r.String(), name), filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				return err
+			}
+			found = found || has
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, errNotHosted
+	}
+
+	m, l, err := an.DeriveManifestAndLock(dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+	return prepManifest(m), l, nil
+}
+
+// fetchHostedFile writes the file at u to the path to, reporting whether it was
+// found.
+func fetchHostedFile(ctx context.Context, u, to string) (bool, error) {
+	resp, err := getHosted(ctx, u)
+	if err != nil || resp == nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return false, err
+	}
+	f, err := os.Create(to)
+	if err != nil {
+		return false, err
+	}
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return false, errors.Wrapf(err, "failed to read %q", u)
+	}
+	return true, f.Close()
+}
+
+// hostedPackageTree lists the packages of revision r of the source from an
+// archive of its Go files, fetched from its hosting service. It returns
+// errNotHosted if there is no archive to be had.
+//
+// caller must hold sg.mu.
+func (sg *sourceGateway) hostedPackageTree(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	api, owner, repo, ok := hostedRepo(sg.src.upstreamURL())
+	if !ok || !sg.suprvsr.network.Permits(api.archiveHost) {
+		return pkgtree.PackageTree{}, errNotHosted
+	}
+
+	dir, err := ioutil.TempDir("", "dep-packages")
+	if err != nil {
+		return pkgtree.PackageTree{}, errors.Wrap(err, "unable to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	found := false
+	label := fmt.Sprintf("%s:%s", pr, sg.src.upstreamURL())
+	err = sg.suprvsr.do(ctx, label, ctListPackages, func(ctx context.Context) error {
+		u := api.archiveURL(owner, repo, r.String())
+		resp, err := getHosted(ctx, u)
+		if found = resp != nil; err != nil || !found {
+			return err
+		}
+		defer resp.Body.Close()
+		return errors.Wrapf(extractGoFiles(resp.Body, dir), "failed to read the archive at %q", u)
+	})
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	if !found {
+		return pkgtree.PackageTree{}, errNotHosted
+	}
+	return pkgtree.ListPackages(dir, string(pr))
+}
+
+// getHosted gets u, returning a nil response if it is not found. Hosting
+// services also answer so for private repositories.
+func getHosted(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	resp, err := httpClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed HTTP request to URL %q", u)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+		resp.Body.Close()
+		return nil, nil
+	default:
+		resp.Body.Close()
+		return nil, errors.Errorf("failed HTTP request to URL %q: %s", u, resp.Status)
+	}
+}
+
+// extractGoFiles writes the Go files in the gzipped tar archive read from r to
+// dir, stripping the archive's top-level directory. Everything else, including
+// symlinks, is left out, as listing packages reads nothing else.
+func extractGoFiles(r io.Reader, dir string) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return errors.Errorf("invalid path %q in archive", hdr.Name)
+		}
+		i := strings.IndexByte(name, '/')
+		if i < 0 || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		name = name[i+1:]
+
+		to := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+			return err
+		}
+		f, err := os.Create(to)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Masterminds/vcs"
+	"github.com/golang/dep/internal/test"
+)
+
+// filesAnalyzer records the names of the files it finds at the root of the
+// project as the projects its manifest constrains.
+type filesAnalyzer struct{}
+
+func (filesAnalyzer) DeriveManifestAndLock(path string, pr ProjectRoot) (Manifest, Lock, error) {
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := SimpleManifest{Deps: make(ProjectConstraints)}
+	for _, fi := range fis {
+		m.Deps[ProjectRoot(fi.Name())] = ProjectProperties{Constraint: Any()}
+	}
+	return m, nil, nil
+}
+
+func (filesAnalyzer) MetadataFiles() []string { return []string{"Gopkg.toml", "go.mod"} }
+
+func (filesAnalyzer) Info() ProjectAnalyzerInfo {
+	return ProjectAnalyzerInfo{Name: "files-analyzer", Version: 1}
+}
+
+func TestSourceGatewayMetadataOnly(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+
+	const rev = Revision("30605f6ac35fcb075ad0bfa9296f90a7d891523e")
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(zw)
+	for name, body := range map[string]string{
+		"bar-30605f6/bar.go":     "package bar\n\nimport \"github.com/foo/baz\"\n",
+		"bar-30605f6/sub/sub.go": "package sub\n",
+		"bar-30605f6/sub/README": "not Go",
+		"bar-30605f6/Gopkg.toml": "",
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	zw.Close()
+
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/raw/foo/bar/" + string(rev) + "/Gopkg.toml":
+			w.Write([]byte("# manifest\n"))
+		case "/archive/foo/bar/" + string(rev):
+			w.Write(archive.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	saved := hostedRepoAPIs
+	defer func() { hostedRepoAPIs = saved }()
+	hostedRepoAPIs = map[string]hostedRepoAPI{
+		"github.com": {
+			rawHost: "127.0.0.1",
+			rawURL: func(owner, repo, rev, path string) string {
+				return srv.URL + "/raw/" + owner + "/" + repo + "/" + rev + "/" + path
+			},
+			archiveHost: "127.0.0.1",
+			archiveURL: func(owner, repo, rev string) string {
+				return srv.URL + "/archive/" + owner + "/" + repo + "/" + rev
+			},
+		},
+	}
+
+	gateway := func(remote string) *sourceGateway {
+		repo, err := vcs.NewGitRepo(remote, filepath.Join(h.Path("cache"), "repo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &sourceGateway{
+			src:          &gitSource{baseVCSSource: baseVCSSource{repo: &gitRepo{GitRepo: repo}}},
+			cache:        newMemoryCache(),
+			suprvsr:      newSupervisor(context.Background()),
+			metadataOnly: true,
+		}
+	}
+	ctx := context.Background()
+	sg := gateway("https://github.com/foo/bar")
+
+	m, _, err := sg.getManifestAndLock(ctx, "github.com/foo/bar", rev, filesAnalyzer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps := m.DependencyConstraints(); len(deps) != 1 || deps["Gopkg.toml"].Constraint == nil {
+		t.Errorf("expected only the manifest to be fetched, got %v", deps)
+	}
+
+	ptree, err := sg.listPackages(ctx, "github.com/foo/bar", rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkgs []string
+	for ip := range ptree.Packages {
+		pkgs = append(pkgs, ip)
+	}
+	sort.Strings(pkgs)
+	if want := []string{"github.com/foo/bar", "github.com/foo/bar/sub"}; !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("expected packages %v, got %v", want, pkgs)
+	}
+	if sg.srcState&sourceExistsLocally != 0 {
+		t.Error("expected the source not to be cloned")
+	}
+
+	// Cached results are not fetched again.
+	n := len(requested)
+	sg.getManifestAndLock(ctx, "github.com/foo/bar", rev, filesAnalyzer{})
+	sg.listPackages(ctx, "github.com/foo/bar", rev)
+	if len(requested) != n {
+		t.Errorf("expected cached results to be used, got requests %v", requested[n:])
+	}
+
+	// A repository nothing is found for, as for a private one, is left to
+	// its source.
+	other := gateway("https://github.com/foo/private")
+	if _, _, err := other.hostedManifestAndLock(ctx, "github.com/foo/private", rev, filesAnalyzer{}); err != errNotHosted {
+		t.Errorf("expected errNotHosted for missing files, got %v", err)
+	}
+	if _, err := other.hostedPackageTree(ctx, "github.com/foo/private", rev); err != errNotHosted {
+		t.Errorf("expected errNotHosted for a missing archive, got %v", err)
+	}
+	if _, _, err := gateway("https://example.com/foo/bar").hostedManifestAndLock(ctx, "example.com/foo/bar", rev, filesAnalyzer{}); err != errNotHosted {
+		t.Errorf("expected errNotHosted for an unknown host, got %v", err)
+	}
+
+	var bad bytes.Buffer
+	zw = gzip.NewWriter(&bad)
+	tw = tar.NewWriter(zw)
+	body := "package escape\n"
+	tw.WriteHeader(&tar.Header{Name: "bar-30605f6/../../escape.go", Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+	tw.Write([]byte(body))
+	tw.Close()
+	zw.Close()
+	if err := extractGoFiles(&bad, h.Path("cache")); err == nil {
+		t.Error("expected a path escaping the archive to be rejected")
+	}
+}
@@ -70,8 +70,17 @@ func (fl falseLocker) Unlock() error {
 //
 // gps's built-in SourceManager, SourceMgr, is intended to be generic and
 // sufficient for any purpose. It provides some additional semantics around the
-// methods defined here.
+// methods defined here. MemorySourceManager is an implementation that serves
+// projects from memory, for use in tests.
+//
+// Code that needs only part of a SourceManager should accept the narrowest of
+// VersionLister, ManifestFetcher, and Exporter that suffices, so that callers
+// can supply lightweight fakes.
 type SourceManager interface {
+	VersionLister
+	ManifestFetcher
+	Exporter
+
 	// SourceExists checks if a repository exists, either upstream or in the
 	// SourceManager's central repository cache.
 	SourceExists(ProjectIdentifier) (bool, error)
@@ -80,6 +89,32 @@ type SourceManager interface {
 	// fully up to date.
 	SyncSourceFor(ProjectIdentifier) error
 
+	// DeduceProjectRoot takes an import path and deduces the corresponding
+	// project/source root.
+	DeduceProjectRoot(ip string) (ProjectRoot, error)
+
+	// SourceURLsForPath takes an import path and deduces the set of source URLs
+	// that may refer to a canonical upstream source.
+	// In general, these URLs differ only by protocol (e.g. https vs. ssh), not path
+	SourceURLsForPath(ip string) ([]*url.URL, error)
+
+	// SourceURLFor returns the URL of the source from which the
+	// ProjectIdentifier's code is retrieved.
+	SourceURLFor(ProjectIdentifier) (string, error)
+
+	// Release lets go of any locks held by the SourceManager. Once called, it
+	// is no longer allowed to call methods of that SourceManager; all
+	// method calls will immediately result in errors.
+	Release()
+
+	// InferConstraint tries to puzzle out what kind of version is given in a string -
+	// semver, a revision, or as a fallback, a plain tag
+	InferConstraint(s string, pi ProjectIdentifier) (Constraint, error)
+}
+
+// VersionLister is the part of a SourceManager that reports what versions
+// exist in a source.
+type VersionLister interface {
 	// ListVersions retrieves a list of the available versions for a given
 	// repository name.
 	ListVersions(ProjectIdentifier) ([]PairedVersion, error)
@@ -87,7 +122,11 @@ type SourceManager interface {
 	// RevisionPresentIn indicates whether the provided Version is present in
 	// the given repository.
 	RevisionPresentIn(ProjectIdentifier, Revision) (bool, error)
+}
 
+// ManifestFetcher is the part of a SourceManager that reads the contents of a
+// source at a particular version.
+type ManifestFetcher interface {
 	// ListPackages parses the tree of the Go packages at or below root of the
 	// provided ProjectIdentifier, at the provided version.
 	ListPackages(ProjectIdentifier, Version) (pkgtree.PackageTree, error)
@@ -99,7 +138,11 @@ type SourceManager interface {
 	// necessitating that the ProjectIdentifier's ProjectRoot must also be a
 	// repository root.
 	GetManifestAndLock(ProjectIdentifier, Version, ProjectAnalyzer) (Manifest, Lock, error)
+}
 
+// Exporter is the part of a SourceManager that writes out the tree of a source
+// at a particular version.
+type Exporter interface {
 	// ExportProject writes out the tree of the provided import path, at the
 	// provided version, to the provided directory.
 	ExportProject(context.Context, ProjectIdentifier, Version, string) error
@@ -112,24 +155,6 @@ type SourceManager interface {
 	// hash, including colon-separated leaders indicating the version of the
 	// hashing function used, and the prune options that were applied.
 	ExportPrunedProject(context.Context, LockedProject, PruneOptions, string) error
-
-	// DeduceProjectRoot takes an import path and deduces the corresponding
-	// project/source root.
-	DeduceProjectRoot(ip string) (ProjectRoot, error)
-
-	// SourceURLsForPath takes an import path and deduces the set of source URLs
-	// that may refer to a canonical upstream source.
-	// In general, these URLs differ only by protocol (e.g. https vs. ssh), not path
-	SourceURLsForPath(ip string) ([]*url.URL, error)
-
-	// Release lets go of any locks held by the SourceManager. Once called, it
-	// is no longer allowed to call methods of that SourceManager; all
-	// method calls will immediately result in errors.
-	Release()
-
-	// InferConstraint tries to puzzle out what kind of version is given in a string -
-	// semver, a revision, or as a fallback, a plain tag
-	InferConstraint(s string, pi ProjectIdentifier) (Constraint, error)
 }
 
 // A ProjectAnalyzer is responsible for analyzing a given path for Manifest and
@@ -175,6 +200,7 @@ type SourceMgr struct {
 	qch         chan struct{}         // quit chan for signal handler
 	relonce     sync.Once             // once-er to ensure we only release once
 	releasing   int32                 // flag indicating release of sm has begun
+	compress    bool                  // compress the cache on release
 }
 
 var _ SourceManager = &SourceMgr{}
@@ -190,6 +216,48 @@ type SourceManagerConfig struct {
 	Cachedir       string        // Where to store local instances of upstream sources.
 	Logger         *log.Logger   // Optional info/warn logger. Discards if nil.
 	DisableLocking bool          // True if the SourceManager should NOT use a lock file to protect the Cachedir from multiple processes.
+	LeaseLocking   bool          // True to force the lease-based lock used when the Cachedir is on a network filesystem.
+	// True to clone git sources in resumable steps, so that an interrupted
+	// clone picks up where it left off. Requires git 2.19 or later.
+	ResumableFetches bool
+	// Timeouts for, and retries of, operations on sources. The zero value
+	// sets no timeouts and makes no retries.
+	VCSPolicy VCSPolicy
+	// If set, a directory of source bundle archives, as written by
+	// WriteSourceBundle, from which all projects are served. No network
+	// access is made, projects absent from the bundle cannot be found, and
+	// the persistent cache is not used.
+	BundleDir string
+	// True to disregard the data already in the persistent cache, fetching it
+	// again from the sources, while still caching what is fetched. Has no
+	// effect unless CacheAge is positive.
+	RefreshCache bool
+	// The hosts that may be contacted. The zero value allows them all.
+	NetworkPolicy NetworkPolicy
+	// The proxies through which hosts are reached. The zero value uses those
+	// of the environment.
+	Proxies ProxyConfig
+	// The providers of the credentials for the sources on hosts, by host
+	// pattern.
+	Auth AuthProviders
+	// The protocols over which to reach sources, overriding deduction.
+	Protocols ProtocolPreferences
+	// The sources of the projects under private import path prefixes,
+	// overriding deduction.
+	Namespaces Namespaces
+	// The variables and rules from which templated sources are resolved.
+	SourceTemplates SourceTemplates
+	// True to compress the sources and metadata in Cachedir when the
+	// SourceManager is released, leaving them compressed until they are next
+	// used. Has no effect if DisableLocking is set, as other processes could
+	// be using them.
+	CompressCache bool
+	// True to solve from metadata: manifests, locks and package trees that
+	// are not cached are read from the files and archives of the revisions
+	// served by the hosting services of the sources, where they can be,
+	// rather than from local copies of the sources. Sources are only cloned
+	// or fetched once something needs them, such as exporting their code.
+	MetadataOnly bool
 }
 
 // NewSourceManager produces an instance of gps's built-in SourceManager.
@@ -211,25 +279,59 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 	if c.Logger == nil {
 		c.Logger = log.New(ioutil.Discard, "", 0)
 	}
+	if err := c.Protocols.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.Namespaces.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.SourceTemplates.Validate(); err != nil {
+		return nil, err
+	}
 
 	err := fs.EnsureDir(filepath.Join(c.Cachedir, "sources"), 0777)
 	if err != nil {
 		return nil, err
 	}
 
+	var bundle *bundleDeducer
+	if c.BundleDir != "" {
+		if bundle, err = newBundleDeducer(c.BundleDir); err != nil {
+			return nil, err
+		}
+		// Cached data may describe versions that are not in the bundle.
+		c.CacheAge = 0
+	}
+
 	// Fix for #820
 	//
 	// Consult https://godoc.org/github.com/nightlyone/lockfile for the lockfile
 	// behaviour. It's magic. It deals with stale processes, and if there is
 	// a process keeping the lock busy, it will pass back a temporary error that
 	// we can spin on.
+	//
+	// On network filesystems, that magic doesn't hold: flock may be a no-op,
+	// and stale process detection would check another host's PIDs against the
+	// local process table. There, we use a renewed lease file instead, which
+	// likewise reports a busy lock as a temporary error.
+	useLease := c.LeaseLocking
+	if !useLease && !c.DisableLocking {
+		// If detection fails, assume a local filesystem, as we always used to.
+		useLease, _ = isNetworkFilesystem(c.Cachedir)
+	}
 
 	glpath := filepath.Join(c.Cachedir, "sm.lock")
+	if useLease {
+		glpath = filepath.Join(c.Cachedir, "sm.lease")
+	}
 
 	lockfile, err := func() (locker, error) {
 		if c.DisableLocking {
 			return falseLocker{}, nil
 		}
+		if useLease {
+			return newLeaseLocker(glpath)
+		}
 		return lockfile.New(glpath)
 	}()
 
@@ -291,12 +393,21 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 
 	ctx, cf := context.WithCancel(context.TODO())
 	superv := newSupervisor(ctx)
+	superv.policy = c.VCSPolicy
+	superv.network = c.NetworkPolicy
+	if !c.Proxies.empty() || len(c.Auth) > 0 {
+		superv.upstream = newUpstreamSettings(c.Proxies, c.Auth)
+	}
 	deducer := newDeductionCoordinator(superv)
+	deducer.namespaces = c.Namespaces
 
 	var sc sourceCache
 	if c.CacheAge > 0 {
 		// Try to open the BoltDB cache from disk.
 		epoch := time.Now().Add(-c.CacheAge).Unix()
+		if c.RefreshCache {
+			epoch = time.Now().Unix()
+		}
 		boltCache, err := newBoltCache(c.Cachedir, epoch, c.Logger)
 		if err != nil {
 			c.Logger.Println(errors.Wrapf(err, "failed to open persistent cache %q", c.Cachedir))
@@ -307,6 +418,7 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 
 	sm := &SourceMgr{
 		cachedir:    c.Cachedir,
+		compress:    c.CompressCache && !c.DisableLocking,
 		lf:          lockfile,
 		suprvsr:     superv,
 		cancelAll:   cf,
@@ -314,6 +426,13 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 		srcCoord:    newSourceCoordinator(superv, deducer, c.Cachedir, sc, c.Logger),
 		qch:         make(chan struct{}),
 	}
+	if bundle != nil {
+		sm.srcCoord.deducer = bundle
+	}
+	sm.srcCoord.resumableFetches = c.ResumableFetches
+	sm.srcCoord.metadataOnly = c.MetadataOnly
+	sm.srcCoord.protocols = c.Protocols
+	sm.srcCoord.templates = c.SourceTemplates
 
 	return sm, nil
 }
@@ -323,6 +442,27 @@ func (sm *SourceMgr) Cachedir() string {
 	return sm.cachedir
 }
 
+// FetchTagsOnly has the sources of the given projects fetch only the tags of
+// their upstreams, and none of their branches, which keeps the cache small for
+// enormous repositories of which only tagged releases are ever used. Branches
+// of these projects are not listed among their versions, and revisions that no
+// tag reaches are unavailable.
+//
+// Only git sources support this; it has no effect on others. It applies to
+// sources created after it is called, so it should be called before the
+// SourceMgr is first used.
+func (sm *SourceMgr) FetchTagsOnly(roots ...ProjectRoot) {
+	sc := sm.srcCoord
+	sc.srcmut.Lock()
+	defer sc.srcmut.Unlock()
+	if sc.tagsOnly == nil {
+		sc.tagsOnly = make(map[ProjectRoot]bool, len(roots))
+	}
+	for _, pr := range roots {
+		sc.tagsOnly[pr] = true
+	}
+}
+
 // UseDefaultSignalHandling sets up typical os.Interrupt signal handling for a
 // SourceMgr.
 func (sm *SourceMgr) UseDefaultSignalHandling() {
@@ -419,9 +559,18 @@ func (sm *SourceMgr) Release() {
 		// Close the source coordinator.
 		sm.srcCoord.close()
 
-		// Close the file handle for the lock file and remove it from disk
+		// Compress the cache while it is still locked.
+		if sm.compress {
+			compressCache(sm.cachedir, sm.srcCoord.logger)
+		}
+
+		// Close the file handle for the lock file and remove it from disk. A
+		// lease removes itself on Unlock, and only if it's still ours;
+		// removing it unconditionally could clobber another process's lease.
 		sm.lf.Unlock()
-		os.Remove(filepath.Join(sm.cachedir, "sm.lock"))
+		if _, ok := sm.lf.(*leaseLocker); !ok {
+			os.Remove(filepath.Join(sm.cachedir, "sm.lock"))
+		}
 
 		// Close the qch, if non-nil, so the signal handlers run out. This will
 		// also deregister the sig channel, if any has been set up.
@@ -475,6 +624,9 @@ func (sm *SourceMgr) ListPackages(id ProjectIdentifier, v Version) (pkgtree.Pack
 // calls will return a cached version of the first call's results. if upstream
 // is not accessible (network outage, access issues, or the resource actually
 // went away), an error will be returned.
+//
+// For a project root naming a major version, such as github.com/foo/bar/v2,
+// semantic versions of other major versions are left out.
 func (sm *SourceMgr) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return nil, ErrSourceManagerIsReleased
@@ -486,7 +638,11 @@ func (sm *SourceMgr) ListVersions(id ProjectIdentifier) ([]PairedVersion, error)
 		return nil, err
 	}
 
-	return srcg.listVersions(context.TODO())
+	vl, err := srcg.listVersions(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return filterMajorVersion(id.ProjectRoot, vl), nil
 }
 
 // RevisionPresentIn indicates whether the provided Revision is present in the given
@@ -527,6 +683,38 @@ func (sm *SourceMgr) SourceExists(id ProjectIdentifier) (bool, error) {
 	return true, nil
 }
 
+// SourceURLFor returns the URL of the source from which the code for the
+// provided ProjectIdentifier is retrieved. Where deduction yields several
+// possible URLs, it is the one that was successfully reached.
+func (sm *SourceMgr) SourceURLFor(id ProjectIdentifier) (string, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return "", ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return "", err
+	}
+
+	return srcg.src.upstreamURL(), nil
+}
+
+// SourceTypeFor returns the type of the source from which the code for the
+// provided ProjectIdentifier is retrieved: the name of its VCS, such as "git"
+// or "hg", or "bundle" for a bundle archive.
+func (sm *SourceMgr) SourceTypeFor(id ProjectIdentifier) (string, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return "", ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return "", err
+	}
+
+	return srcg.src.sourceType(), nil
+}
+
 // SyncSourceFor will ensure that all local caches and information about a
 // source are up to date with any network-acccesible information.
 //
@@ -574,6 +762,42 @@ func (sm *SourceMgr) ExportPrunedProject(ctx context.Context, lp LockedProject,
 	return srcg.exportPrunedVersionTo(ctx, lp, prune, to)
 }
 
+// RevisionTime returns the time at which the given revision of the
+// ProjectIdentifier's source was made, as recorded by its version control
+// system.
+func (sm *SourceMgr) RevisionTime(id ProjectIdentifier, r Revision) (time.Time, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return time.Time{}, ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return srcg.revisionTime(context.TODO(), r)
+}
+
+// RevisionReachableFrom reports whether the given revision of the
+// ProjectIdentifier's source can be reached from head, the revision a branch
+// or tag currently points to upstream: that is, whether it is head or one of
+// its ancestors. The local copy of the source is brought up to date first, so
+// a revision that was only reachable from commits since discarded upstream,
+// as by a force-push, is not reported as reachable merely because it is still
+// in the cache.
+func (sm *SourceMgr) RevisionReachableFrom(id ProjectIdentifier, r, head Revision) (bool, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return false, ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return false, err
+	}
+
+	return srcg.revisionReachableFrom(context.TODO(), r, head)
+}
+
 // DeduceProjectRoot takes an import path and deduces the corresponding
 // project/source root.
 //
@@ -592,7 +816,7 @@ func (sm *SourceMgr) DeduceProjectRoot(ip string) (ProjectRoot, error) {
 		return "", errors.Errorf("%q is not a valid import path", ip)
 	}
 
-	pd, err := sm.deduceCoord.deduceRootPath(context.TODO(), ip)
+	pd, err := sm.srcCoord.deducer.deduceRootPath(context.TODO(), ip)
 	return ProjectRoot(pd.root), err
 }
 
@@ -600,13 +824,21 @@ func (sm *SourceMgr) DeduceProjectRoot(ip string) (ProjectRoot, error) {
 // string. Preference is given first for branches, then semver constraints, then
 // plain tags, and then revisions.
 func (sm *SourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint, error) {
+	return inferConstraint(s, pi, sm, func(rev Revision) (Revision, error) {
+		return sm.disambiguateRevision(context.TODO(), pi, rev)
+	})
+}
+
+// inferConstraint implements SourceManager.InferConstraint, given the means to
+// list pi's versions and to expand a possibly abbreviated revision from it.
+func inferConstraint(s string, pi ProjectIdentifier, vl VersionLister, disambiguate func(Revision) (Revision, error)) (Constraint, error) {
 	if s == "" {
 		return Any(), nil
 	}
 
 	// Lookup the string in the repository
 	var version PairedVersion
-	versions, err := sm.ListVersions(pi)
+	versions, err := vl.ListVersions(pi)
 	if err != nil {
 		return nil, errors.Wrapf(err, "list versions for %s", pi) // means repo does not exist
 	}
@@ -635,7 +867,7 @@ func (sm *SourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint
 	}
 
 	// Revision, possibly abbreviated
-	r, err := sm.disambiguateRevision(context.TODO(), pi, Revision(s))
+	r, err := disambiguate(Revision(s))
 	if err == nil {
 		return r, nil
 	}
@@ -647,7 +879,7 @@ func (sm *SourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint
 // that may refer to a canonical upstream source.
 // In general, these URLs differ only by protocol (e.g. https vs. ssh), not path
 func (sm *SourceMgr) SourceURLsForPath(ip string) ([]*url.URL, error) {
-	deduced, err := sm.deduceCoord.deduceRootPath(context.TODO(), ip)
+	deduced, err := sm.srcCoord.deducer.deduceRootPath(context.TODO(), ip)
 	if err != nil {
 		return nil, err
 	}
@@ -680,11 +912,14 @@ type durCount struct {
 }
 
 type supervisor struct {
-	ctx     context.Context
-	mu      sync.Mutex // Guards all maps
-	cond    sync.Cond  // Wraps mu so callers can wait until all calls end
-	running map[callInfo]timeCount
-	ran     map[callType]durCount
+	ctx      context.Context
+	mu       sync.Mutex // Guards all maps
+	cond     sync.Cond  // Wraps mu so callers can wait until all calls end
+	running  map[callInfo]timeCount
+	ran      map[callType]durCount
+	policy   VCSPolicy         // Timeouts and retries applied to calls
+	network  NetworkPolicy     // Hosts that calls may contact
+	upstream *upstreamSettings // Proxies and credentials with which calls reach hosts; nil for the environment's
 }
 
 func newSupervisor(ctx context.Context) *supervisor {
@@ -700,22 +935,36 @@ func newSupervisor(ctx context.Context) *supervisor {
 
 // do executes the incoming closure using a conjoined context, and keeps
 // counters to ensure the sourceMgr can't finish Release()ing until after all
-// calls have returned.
+// calls have returned. The closure is subject to the timeouts and retries of
+// the supervisor's VCSPolicy.
 func (sup *supervisor) do(inctx context.Context, name string, typ callType, f func(context.Context) error) error {
 	ci := callInfo{
 		name: name,
 		typ:  typ,
 	}
 
+	inctx, err := sup.withUpstream(inctx, "")
+	if err != nil {
+		return err
+	}
 	octx, err := sup.start(ci)
 	if err != nil {
 		return err
 	}
 
 	cctx, cancelFunc := constext.Cons(inctx, octx)
-	err = f(cctx)
+	err = sup.policy.run(cctx, typ, f)
 	sup.done(ci)
 	cancelFunc()
+
+	switch typ {
+	case ctSourceInit:
+		CountMetric(MetricSourceInits, 1)
+	case ctSourceFetch:
+		CountMetric(MetricSourceFetches, 1)
+	case ctExportTree:
+		CountMetric(MetricExports, 1)
+	}
 	return err
 }
 
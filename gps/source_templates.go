@@ -0,0 +1,115 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sourceVarProject is the variable that always stands for the project root in
+// templated sources.
+const sourceVarProject = "project"
+
+// SourceTemplates resolves templated sources: sources containing variables,
+// written {{name}}, whose values are given per machine rather than in each
+// manifest, such as the host of a company's mirrors. The variable {{project}}
+// always stands for the project root of the source's project.
+//
+// A source given for a project, as in a manifest, may be templated. Rules give
+// the sources of all the projects under a project root prefix that have none
+// of their own, so that a mirroring scheme for every project on a host takes
+// a single rule, such as "github.com" mapping to
+// "{{host}}/mirror/{{project}}".
+//
+// The templates themselves, not the sources they resolve to, are what is kept
+// in identifiers and locks, so that they stay the same from one machine to the
+// next.
+type SourceTemplates struct {
+	// Vars holds the values of the variables, by name.
+	Vars map[string]string
+	// Rules maps project root prefixes, such as "github.com" or
+	// "github.com/foo", to templates. The longest prefix matching a project
+	// root takes precedence.
+	Rules map[string]string
+}
+
+// Validate returns an error if any of the variables of st is misnamed, or any
+// of its rules uses a variable that has no value.
+func (st SourceTemplates) Validate() error {
+	for name := range st.Vars {
+		if name == sourceVarProject {
+			return errors.Errorf("the source variable %q is reserved for the project root", name)
+		}
+		if name == "" || strings.ContainsAny(name, "{} ") {
+			return errors.Errorf("invalid source variable name %q", name)
+		}
+	}
+	for prefix, tmpl := range st.Rules {
+		if prefix == "" || strings.HasSuffix(prefix, "/") {
+			return errors.Errorf("invalid project root prefix %q for source %q", prefix, tmpl)
+		}
+		if _, err := st.expand(tmpl, ProjectRoot(prefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceFor returns the source from which to retrieve the project identified
+// by id: its own, with any variables resolved, or else the source given by the
+// longest matching rule, or else its project root. A source that is the same
+// as the project root counts as none.
+func (st SourceTemplates) sourceFor(id ProjectIdentifier) (string, error) {
+	if id.Source != "" && id.Source != string(id.ProjectRoot) {
+		if !strings.Contains(id.Source, "{{") {
+			return id.Source, nil
+		}
+		return st.expand(id.Source, id.ProjectRoot)
+	}
+
+	pr := string(id.ProjectRoot)
+	var best string
+	for prefix := range st.Rules {
+		if (pr == prefix || strings.HasPrefix(pr, prefix+"/")) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return pr, nil
+	}
+	return st.expand(st.Rules[best], id.ProjectRoot)
+}
+
+// expand replaces the variables in tmpl with their values, and {{project}} with
+// pr.
+func (st SourceTemplates) expand(tmpl string, pr ProjectRoot) (string, error) {
+	var out bytes.Buffer
+	for rest := tmpl; ; {
+		i := strings.Index(rest, "{{")
+		if i < 0 {
+			out.WriteString(rest)
+			break
+		}
+		j := strings.Index(rest[i:], "}}")
+		if j < 0 {
+			return "", errors.Errorf("unterminated variable in source %q", tmpl)
+		}
+		name := strings.TrimSpace(rest[i+2 : i+j])
+		val, has := st.Vars[name]
+		if name == sourceVarProject {
+			val, has = string(pr), true
+		}
+		if !has {
+			return "", errors.Errorf("source %q uses the variable %q, which has no value", tmpl, name)
+		}
+		out.WriteString(rest[:i])
+		out.WriteString(val)
+		rest = rest[i+j+2:]
+	}
+	return out.String(), nil
+}
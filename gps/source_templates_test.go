@@ -0,0 +1,60 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "testing"
+
+func TestSourceTemplates(t *testing.T) {
+	st := SourceTemplates{
+		Vars: map[string]string{"host": "https://git.corp.example.com"},
+		Rules: map[string]string{
+			"github.com":         "{{host}}/mirror/{{project}}",
+			"github.com/corp":    "{{host}}/corp/{{ project }}.git",
+			"golang.org/x/tools": "https://go.googlesource.com/tools",
+		},
+	}
+	if err := st.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		id   ProjectIdentifier
+		want string
+	}{
+		{mkPI("github.com/foo/bar"), "https://git.corp.example.com/mirror/github.com/foo/bar"},
+		{mkPI("github.com/corp/lib"), "https://git.corp.example.com/corp/github.com/corp/lib.git"},
+		{mkPI("github.com/corpus/lib"), "https://git.corp.example.com/mirror/github.com/corpus/lib"},
+		{mkPI("golang.org/x/tools"), "https://go.googlesource.com/tools"},
+		{mkPI("gopkg.in/yaml.v2"), "gopkg.in/yaml.v2"},
+		{mkPI("github.com/foo/bar").normalize(), "https://git.corp.example.com/mirror/github.com/foo/bar"},
+		{ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: "{{host}}/forks/bar"}, "https://git.corp.example.com/forks/bar"},
+		{ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: "https://example.com/bar"}, "https://example.com/bar"},
+	} {
+		got, err := st.sourceFor(c.id)
+		if err != nil {
+			t.Errorf("%s: %v", c.id, err)
+		} else if got != c.want {
+			t.Errorf("%s: expected source %q, got %q", c.id, c.want, got)
+		}
+	}
+
+	if _, err := st.sourceFor(ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: "{{user}}/bar"}); err == nil {
+		t.Error("expected an error for a variable without a value")
+	}
+	if _, err := (SourceTemplates{}).sourceFor(ProjectIdentifier{ProjectRoot: "github.com/foo/bar", Source: "{{host}}/bar"}); err == nil {
+		t.Error("expected an error for a templated source without variables")
+	}
+
+	for name, bad := range map[string]SourceTemplates{
+		"reserved variable": {Vars: map[string]string{"project": "x"}},
+		"unknown variable":  {Rules: map[string]string{"github.com": "{{host}}/{{project}}"}},
+		"unterminated":      {Vars: map[string]string{"host": "x"}, Rules: map[string]string{"github.com": "{{host/{{project}}"}},
+		"trailing slash":    {Rules: map[string]string{"github.com/": "https://example.com/{{project}}"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
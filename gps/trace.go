@@ -92,6 +92,29 @@ func (s *solver) traceBacktrack(bmi bimodalIdentifier, pkgonly bool) {
 	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
 }
 
+// traceParallel is called each time the groups of a parallel solve are
+// solved, with the groups.
+func (s *solver) traceParallel(groups [][]ProjectRoot) {
+	if s.tl == nil {
+		return
+	}
+
+	s.tl.Printf("Solving %v independent groups of dependencies concurrently:", len(groups))
+	for _, g := range groups {
+		s.tl.Printf("  %s", g)
+	}
+}
+
+// traceParallelFallback is called when a group of a parallel solve fails to
+// solve, and all the dependencies are to be solved together instead.
+func (s *solver) traceParallelFallback() {
+	if s.tl == nil {
+		return
+	}
+
+	s.tl.Printf("%s%s a group failed to solve; solving all dependencies together", innerIndent, failChar)
+}
+
 // Called just once after solving has finished, whether success or not
 func (s *solver) traceFinish(sol solution, err error) {
 	if s.tl == nil {
@@ -0,0 +1,123 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"sort"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+// UpdateStrategy determines the order in which the solver tries the versions of
+// a project that has a version in the root lock, once it is free to move away
+// from that version.
+type UpdateStrategy uint8
+
+const (
+	// MaximizeFreshness tries the newest allowed versions first. This is the
+	// default.
+	MaximizeFreshness UpdateStrategy = iota
+
+	// MinimizeChanges tries the versions closest to the locked one first:
+	// newer semver releases from the oldest up, then the locked version, then
+	// the remaining versions in their usual order.
+	MinimizeChanges
+
+	// SecurityOnly tries, newest first, the releases that share the major and
+	// minor version of the locked release, then the locked version itself.
+	// Other versions are only tried if none of those can be selected.
+	SecurityOnly
+)
+
+func (us UpdateStrategy) String() string {
+	switch us {
+	case MaximizeFreshness:
+		return "maximize-freshness"
+	case MinimizeChanges:
+		return "minimize-changes"
+	case SecurityOnly:
+		return "security-only"
+	}
+	return "unknown"
+}
+
+// ParseUpdateStrategy returns the UpdateStrategy with the given name, as
+// returned by its String method.
+func ParseUpdateStrategy(s string) (UpdateStrategy, error) {
+	for _, us := range []UpdateStrategy{MaximizeFreshness, MinimizeChanges, SecurityOnly} {
+		if s == us.String() {
+			return us, nil
+		}
+	}
+	return MaximizeFreshness, errors.Errorf("unknown update strategy %q; must be one of maximize-freshness, minimize-changes or security-only", s)
+}
+
+// order returns a copy of vl, which must already be sorted for upgrade or
+// downgrade, rearranged around the locked version according to the strategy.
+// If the locked version is not a semver release, versions are reordered only
+// by SecurityOnly, which tries the locked version first.
+func (us UpdateStrategy) order(vl []Version, locked Version) []Version {
+	if us == MaximizeFreshness || locked == nil {
+		return vl
+	}
+
+	lsv, ok := semverOf(locked)
+	if !ok {
+		if us != SecurityOnly {
+			return vl
+		}
+		return append([]Version{locked}, vl...)
+	}
+
+	var first, current, rest []Version
+	for _, v := range vl {
+		sv, ok := semverOf(v)
+		switch {
+		case !ok:
+			rest = append(rest, v)
+		case sv.Equal(lsv):
+			current = append(current, v)
+		case us == MinimizeChanges && sv.GreaterThan(lsv):
+			first = append(first, v)
+		case us == SecurityOnly && sv.GreaterThan(lsv) && sv.Major() == lsv.Major() && sv.Minor() == lsv.Minor():
+			first = append(first, v)
+		default:
+			rest = append(rest, v)
+		}
+	}
+
+	if us == MinimizeChanges {
+		sort.SliceStable(first, func(i, j int) bool {
+			isv, _ := semverOf(first[i])
+			jsv, _ := semverOf(first[j])
+			return isv.LessThan(jsv)
+		})
+	} else {
+		sort.SliceStable(first, func(i, j int) bool {
+			isv, _ := semverOf(first[i])
+			jsv, _ := semverOf(first[j])
+			return isv.GreaterThan(jsv)
+		})
+	}
+
+	ordered := make([]Version, 0, len(vl))
+	ordered = append(ordered, first...)
+	ordered = append(ordered, current...)
+	return append(ordered, rest...)
+}
+
+// semverOf returns the semantic version underlying v, if it has one.
+func semverOf(v Version) (semver.Version, bool) {
+	switch tv := v.(type) {
+	case semVersion:
+		return tv.sv, true
+	case versionPair:
+		if sv, ok := tv.v.(semVersion); ok {
+			return sv.sv, true
+		}
+	}
+	return semver.Version{}, false
+}
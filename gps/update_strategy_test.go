@@ -0,0 +1,52 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpdateStrategyOrder(t *testing.T) {
+	vl := []Version{
+		NewVersion("v2.0.0"),
+		NewVersion("v1.2.0"),
+		NewVersion("v1.1.1"),
+		NewVersion("v1.1.0"),
+		NewVersion("v1.0.0"),
+		NewBranch("master"),
+	}
+	locked := NewVersion("v1.1.0").Pair("abc123")
+
+	testCases := []struct {
+		strat  UpdateStrategy
+		locked Version
+		want   []Version
+	}{
+		{MaximizeFreshness, locked, vl},
+		{MinimizeChanges, locked, []Version{vl[2], vl[1], vl[0], vl[3], vl[4], vl[5]}},
+		{SecurityOnly, locked, []Version{vl[2], vl[3], vl[0], vl[1], vl[4], vl[5]}},
+		{MinimizeChanges, NewBranch("master").Pair("abc123"), vl},
+		{SecurityOnly, NewBranch("master").Pair("abc123"), append([]Version{NewBranch("master").Pair("abc123")}, vl...)},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.strat.order(vl, tc.locked); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s with %s locked:\n\t(GOT): %v\n\t(WNT): %v", tc.strat, tc.locked, got, tc.want)
+		}
+	}
+}
+
+func TestParseUpdateStrategy(t *testing.T) {
+	for _, us := range []UpdateStrategy{MaximizeFreshness, MinimizeChanges, SecurityOnly} {
+		got, err := ParseUpdateStrategy(us.String())
+		if err != nil || got != us {
+			t.Errorf("expected %s to parse to itself, got %s (%v)", us, got, err)
+		}
+	}
+	if _, err := ParseUpdateStrategy("newest"); err == nil {
+		t.Error("expected an error parsing an unknown update strategy")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// upstreamSettings carries the proxies and credentials with which the calls of
+// a supervisor reach upstream hosts through their contexts, along with the
+// HTTP clients that apply them.
+type upstreamSettings struct {
+	proxies ProxyConfig
+	auth    *authCache // nil if there are no AuthProviders
+	// client applies both the proxies and the credentials, while proxied,
+	// through which the AuthProviders obtain the credentials, only applies
+	// the proxies.
+	client, proxied *http.Client
+}
+
+func newUpstreamSettings(proxies ProxyConfig, auth AuthProviders) *upstreamSettings {
+	s := &upstreamSettings{
+		proxies: proxies,
+		proxied: &http.Client{
+			// The same settings as http.DefaultTransport, but for the proxy.
+			Transport: &http.Transport{
+				Proxy: func(r *http.Request) (*url.URL, error) {
+					return proxies.ProxyFor(r.URL)
+				},
+				DialContext: (&net.Dialer{
+					Timeout:   30 * time.Second,
+					KeepAlive: 30 * time.Second,
+				}).DialContext,
+				MaxIdleConns:          100,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: 1 * time.Second,
+			},
+		},
+	}
+	s.client = s.proxied
+	if len(auth) > 0 {
+		s.auth = newAuthCache(auth, s.proxied)
+		s.client = &http.Client{Transport: &authTransport{base: s.proxied.Transport, auth: s.auth}}
+	}
+	return s
+}
+
+type upstreamContextKey struct{}
+
+// upstreamCall is the value under upstreamContextKey: the settings in effect,
+// and the environment variables with which the version control commands of
+// the call reach its source.
+type upstreamCall struct {
+	settings *upstreamSettings
+	env      []string
+}
+
+// withUpstream returns ctx, carrying the proxies and credentials of the
+// supervisor, if it has any. If rawurl is not empty, the version control
+// commands run with the returned context reach it through its proxy, and with
+// its credentials.
+func (sup *supervisor) withUpstream(ctx context.Context, rawurl string) (context.Context, error) {
+	if sup.upstream == nil {
+		return ctx, nil
+	}
+	if rawurl == "" {
+		if _, has := ctx.Value(upstreamContextKey{}).(*upstreamCall); has {
+			return ctx, nil
+		}
+		return context.WithValue(ctx, upstreamContextKey{}, &upstreamCall{settings: sup.upstream}), nil
+	}
+
+	env, err := sup.upstream.commandEnv(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, upstreamContextKey{}, &upstreamCall{settings: sup.upstream, env: env}), nil
+}
+
+// commandEnv returns the environment variables with which version control
+// commands reach rawurl, or none at all if it is not an HTTP(S) URL.
+func (s *upstreamSettings) commandEnv(ctx context.Context, rawurl string) ([]string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, nil
+	}
+	env, err := s.proxies.commandEnv(u)
+	if err != nil || s.auth == nil {
+		return env, err
+	}
+	authEnv, err := s.auth.commandEnv(ctx, u)
+	return append(env, authEnv...), err
+}
+
+// httpClient returns the client for the HTTP requests made with ctx.
+func httpClient(ctx context.Context) *http.Client {
+	if uc, has := ctx.Value(upstreamContextKey{}).(*upstreamCall); has {
+		return uc.settings.client
+	}
+	return http.DefaultClient
+}
+
+// upstreamEnv returns the environment variables to add for the version
+// control commands run with ctx.
+func upstreamEnv(ctx context.Context) []string {
+	if uc, has := ctx.Value(upstreamContextKey{}).(*upstreamCall); has {
+		return uc.env
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"time"
+)
+
+// VCSPolicy bounds the time the SourceMgr allows for operations on sources,
+// and sets how it retries those that fail against an upstream. The zero value
+// imposes no timeouts and makes no retries.
+type VCSPolicy struct {
+	// ListTimeout bounds checking that a source exists upstream and listing
+	// its versions, e.g. with git ls-remote.
+	ListTimeout time.Duration
+	// CloneTimeout bounds the initial clone of a source into the cache.
+	CloneTimeout time.Duration
+	// FetchTimeout bounds fetching the latest data into a cached source.
+	FetchTimeout time.Duration
+	// ExportTimeout bounds writing a code tree out of a cached source.
+	ExportTimeout time.Duration
+	// Retries is the number of times an operation against an upstream - a
+	// list, clone or fetch - is retried after it fails or times out.
+	Retries int
+	// RetryBackoff is the wait before the first retry. It doubles before each
+	// subsequent one.
+	RetryBackoff time.Duration
+}
+
+// timeout returns the time allowed for a single attempt at a call of type
+// typ, or zero if it is unbounded.
+func (p VCSPolicy) timeout(typ callType) time.Duration {
+	switch typ {
+	case ctSourcePing, ctListVersions:
+		return p.ListTimeout
+	case ctSourceInit:
+		return p.CloneTimeout
+	case ctSourceFetch:
+		return p.FetchTimeout
+	case ctExportTree:
+		return p.ExportTimeout
+	}
+	return 0
+}
+
+// retries returns the number of times a failed call of type typ may be
+// retried. Only calls that talk to an upstream are retried; other failures
+// are not transient.
+func (p VCSPolicy) retries(typ callType) int {
+	switch typ {
+	case ctSourcePing, ctListVersions, ctSourceInit, ctSourceFetch:
+		return p.Retries
+	}
+	return 0
+}
+
+// run calls f, bounding each attempt by the timeout for typ and retrying
+// failures as the policy allows. Retries stop as soon as ctx is done.
+func (p VCSPolicy) run(ctx context.Context, typ callType, f func(context.Context) error) error {
+	backoff := p.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := p.attempt(ctx, typ, f)
+		if err == nil || attempt >= p.retries(typ) || ctx.Err() != nil {
+			return err
+		}
+
+		if backoff > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return err
+			case <-t.C:
+			}
+			backoff *= 2
+		}
+	}
+}
+
+func (p VCSPolicy) attempt(ctx context.Context, typ callType, f func(context.Context) error) error {
+	d := p.timeout(typ)
+	if d <= 0 {
+		return f(ctx)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	err := f(tctx)
+	if err != nil && tctx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		// Name the limit that was hit, rather than leaving a bare deadline or
+		// killed-process error.
+		return timeoutError{typ: typ, d: d, err: err}
+	}
+	return err
+}
+
+// timeoutError is returned when a call exceeds the timeout set for its type
+// by a VCSPolicy.
+type timeoutError struct {
+	typ callType
+	d   time.Duration
+	err error
+}
+
+func (e timeoutError) Error() string {
+	return e.typ.String() + " timed out after " + e.d.String() + ": " + e.err.Error()
+}
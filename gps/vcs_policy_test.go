@@ -0,0 +1,80 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVCSPolicyRun(t *testing.T) {
+	failing := errors.New("upstream failure")
+
+	t.Run("timeout", func(t *testing.T) {
+		p := VCSPolicy{ListTimeout: 10 * time.Millisecond}
+		err := p.run(context.Background(), ctListVersions, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		if _, ok := err.(timeoutError); !ok {
+			t.Fatalf("expected a timeoutError, got %#v", err)
+		}
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		p := VCSPolicy{Retries: 2, RetryBackoff: time.Millisecond}
+		var calls int
+		err := p.run(context.Background(), ctSourceFetch, func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return failing
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Fatalf("expected success on third attempt, got %v after %d calls", err, calls)
+		}
+
+		calls = 0
+		err = p.run(context.Background(), ctSourceFetch, func(ctx context.Context) error {
+			calls++
+			return failing
+		})
+		if err != failing || calls != 3 {
+			t.Fatalf("expected failure after 3 attempts, got %v after %d calls", err, calls)
+		}
+	})
+
+	t.Run("local calls not retried", func(t *testing.T) {
+		p := VCSPolicy{Retries: 2}
+		var calls int
+		p.run(context.Background(), ctExportTree, func(ctx context.Context) error {
+			calls++
+			return failing
+		})
+		if calls != 1 {
+			t.Fatalf("expected exports not to be retried, got %d calls", calls)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		p := VCSPolicy{Retries: 5, RetryBackoff: time.Hour}
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls int
+		done := make(chan error)
+		go func() {
+			done <- p.run(ctx, ctSourceInit, func(ctx context.Context) error {
+				calls++
+				return failing
+			})
+		}()
+		cancel()
+		if err := <-done; err != failing || calls != 1 {
+			t.Fatalf("expected cancellation to stop retries, got %v after %d calls", err, calls)
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"log"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -38,6 +39,14 @@ type ensureCleaner interface {
 
 type gitRepo struct {
 	*vcs.GitRepo
+
+	// resumable indicates that get should clone the repository in
+	// resumable steps; see getResumable.
+	resumable bool
+	// logger receives progress of resumable clones. Discards if nil.
+	logger *log.Logger
+	// tagsOnly indicates that only tags are to be fetched from upstream.
+	tagsOnly bool
 }
 
 func newVcsRemoteErrorOr(err error, args []string, out, msg string) error {
@@ -55,6 +64,16 @@ func newVcsLocalErrorOr(err error, args []string, out, msg string) error {
 }
 
 func (r *gitRepo) get(ctx context.Context) error {
+	// An interrupted resumable clone is always resumed, even if resumable
+	// fetches have since been disabled, as git clone would refuse to clone
+	// into the non-empty directory left behind.
+	if r.resumable || r.cloneIncomplete() {
+		return r.getResumable(ctx)
+	}
+	if r.tagsOnly {
+		return r.getTagsOnly(ctx)
+	}
+
 	cmd := commandContext(
 		ctx,
 		"git",
@@ -75,15 +94,49 @@ func (r *gitRepo) get(ctx context.Context) error {
 	return nil
 }
 
+// getTagsOnly sets up the repository with only the tags of the upstream, and
+// the history they reach. No branches are fetched, and nothing is checked out;
+// the index is left empty, as exports back it up and restore it.
+//
+// The repository is shared by every project using the same source, so its
+// configuration is that of a normal clone, and the tags are only asked for on
+// the command line; later fetches on behalf of projects that want branches
+// then get them.
+func (r *gitRepo) getTagsOnly(ctx context.Context) error {
+	if err := os.MkdirAll(r.LocalPath(), 0777); err != nil {
+		return errors.Wrap(err, "unable to create repository directory")
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "remote.origin.url", r.Remote()},
+		{"config", "remote.origin.fetch", gitBranchesRefspec},
+	} {
+		if err := r.runGit(ctx, false, "unable to initialize repository", args...); err != nil {
+			return err
+		}
+	}
+	if err := r.runGit(ctx, true, "unable to get repository", "fetch", "--prune", "--progress", "origin", gitTagsRefspec); err != nil {
+		return err
+	}
+	return r.runGit(ctx, false, "unable to initialize index", "read-tree", "--empty")
+}
+
+// gitTagsRefspec is the refspec with which repositories holding only the tags
+// of their upstreams are fetched.
+const gitTagsRefspec = "+refs/tags/*:refs/tags/*"
+
+// gitBranchesRefspec is the refspec with which the branches of upstreams are
+// fetched, as configured by git clone. It is passed explicitly, so that the
+// branches are fetched even into repositories that an earlier version of dep
+// configured to fetch only tags.
+const gitBranchesRefspec = "+refs/heads/*:refs/remotes/origin/*"
+
 func (r *gitRepo) fetch(ctx context.Context) error {
-	cmd := commandContext(
-		ctx,
-		"git",
-		"fetch",
-		"--tags",
-		"--prune",
-		r.RemoteLocation,
-	)
+	args := []string{"fetch", "--tags", "--prune", r.RemoteLocation, gitBranchesRefspec}
+	if r.tagsOnly {
+		args = []string{"fetch", "--prune", r.RemoteLocation, gitTagsRefspec}
+	}
+	cmd := commandContext(ctx, "git", args...)
 	cmd.SetDir(r.LocalPath())
 	// Ensure no prompting for PWs
 	cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
@@ -0,0 +1,172 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// resumeStateFile records, within the .git directory of a repository
+	// being cloned in resumable steps, how many commits of history have been
+	// fetched so far. Its presence marks the clone as incomplete.
+	resumeStateFile = "dep-resume"
+	// resumeMaxDeepen caps the number of commits of history fetched by a
+	// single step of a resumable clone, bounding the work an interruption can
+	// lose.
+	resumeMaxDeepen = 1000
+)
+
+// cloneIncomplete reports whether the repository is a resumable clone that
+// has not yet fetched all of its history.
+func (r *gitRepo) cloneIncomplete() bool {
+	_, err := os.Stat(filepath.Join(r.LocalPath(), ".git", resumeStateFile))
+	return err == nil
+}
+
+// CheckLocal reports whether a usable local copy of the repository exists.
+// An incomplete resumable clone does not count, so that it is resumed by get
+// rather than being fetched into.
+func (r *gitRepo) CheckLocal() bool {
+	return !r.cloneIncomplete() && r.GitRepo.CheckLocal()
+}
+
+// getResumable clones the repository as a series of fetches, each of which
+// persists its progress, so that an interrupted clone of a large repository
+// resumes where it left off rather than restarting from nothing.
+//
+// The first fetch gets only the tips of the upstream branches and tags; each
+// subsequent one deepens the history, doubling the step up to
+// resumeMaxDeepen commits, until it is complete. All fetches use a partial
+// clone filter, so that file contents are only downloaded from upstream when
+// code is exported, and then only for the revisions exported.
+func (r *gitRepo) getResumable(ctx context.Context) error {
+	depth, err := r.readResumeState()
+	if os.IsNotExist(err) {
+		// Record the clone as incomplete before anything else, so that an
+		// interruption at any later point leaves it to be resumed.
+		if err = os.MkdirAll(filepath.Join(r.LocalPath(), ".git"), 0777); err != nil {
+			return errors.Wrap(err, "unable to create repository directory")
+		}
+		if err = r.writeResumeState(0); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "unable to read progress of interrupted clone")
+	} else {
+		r.logf("Resuming clone of %s, %d commits of history fetched so far", r.Remote(), depth)
+	}
+
+	if err = r.initResumable(ctx); err != nil {
+		return err
+	}
+
+	for {
+		args := []string{"fetch", "--filter=blob:none", "--tags", "--progress"}
+		step := depth
+		if depth == 0 {
+			step = 1
+			args = append(args, "--depth=1")
+		} else {
+			if step > resumeMaxDeepen {
+				step = resumeMaxDeepen
+			}
+			args = append(args, "--deepen="+strconv.Itoa(step))
+		}
+		args = append(args, "origin", "+refs/heads/*:refs/remotes/origin/*")
+
+		if err := r.runGit(ctx, true, "unable to get repository", args...); err != nil {
+			return err
+		}
+
+		depth += step
+		if _, err := os.Stat(filepath.Join(r.LocalPath(), ".git", "shallow")); os.IsNotExist(err) {
+			// Git drops the shallow file once no commit is missing its
+			// parents; the history is complete.
+			break
+		}
+
+		if err := r.writeResumeState(depth); err != nil {
+			return err
+		}
+		r.logf("Fetched %d commits of history for %s", depth, r.Remote())
+	}
+
+	// Check out the upstream's default branch, as git clone would, so that
+	// the repository has a working tree and index like any other.
+	if err := r.runGit(ctx, true, "unable to determine default branch", "remote", "set-head", "origin", "--auto"); err != nil {
+		return err
+	}
+	if err := r.runGit(ctx, false, "unable to check out default branch", "checkout", "-q", "origin/HEAD"); err != nil {
+		return err
+	}
+
+	return errors.Wrap(
+		os.Remove(filepath.Join(r.LocalPath(), ".git", resumeStateFile)),
+		"unable to record completed clone",
+	)
+}
+
+// initResumable sets up the repository as a partial clone of the upstream.
+// It is idempotent, so that it can be repeated when resuming.
+func (r *gitRepo) initResumable(ctx context.Context) error {
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "remote.origin.url", r.Remote()},
+		{"config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"},
+		// Mark the remote as the promisor from which git lazily fetches
+		// objects filtered out of earlier fetches. This is what git clone
+		// --filter sets up.
+		{"config", "core.repositoryformatversion", "1"},
+		{"config", "extensions.partialClone", "origin"},
+		{"config", "remote.origin.promisor", "true"},
+		{"config", "remote.origin.partialclonefilter", "blob:none"},
+	} {
+		if err := r.runGit(ctx, false, "unable to initialize repository", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gitRepo) runGit(ctx context.Context, remote bool, msg string, args ...string) error {
+	cmd := commandContext(ctx, "git", args...)
+	cmd.SetDir(r.LocalPath())
+	// Ensure no prompting for PWs
+	cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if remote {
+			return newVcsRemoteErrorOr(err, cmd.Args(), string(out), msg)
+		}
+		return newVcsLocalErrorOr(err, cmd.Args(), string(out), msg)
+	}
+	return nil
+}
+
+func (r *gitRepo) readResumeState() (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(r.LocalPath(), ".git", resumeStateFile))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func (r *gitRepo) writeResumeState(depth int) error {
+	err := ioutil.WriteFile(filepath.Join(r.LocalPath(), ".git", resumeStateFile), []byte(strconv.Itoa(depth)+"\n"), 0666)
+	return errors.Wrap(err, "unable to record clone progress")
+}
+
+func (r *gitRepo) logf(format string, args ...interface{}) {
+	if r.logger != nil {
+		r.logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/vcs"
+	"github.com/golang/dep/internal/test"
+)
+
+// makeUpstreamGitRepo creates a git repository in dir with the given number
+// of commits on its default branch, configured to serve partial clones.
+func makeUpstreamGitRepo(t *testing.T, dir string, commits int) {
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "-q")
+	git("config", "uploadpack.allowFilter", "true")
+	for i := 0; i < commits; i++ {
+		if err := ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte(strconv.Itoa(i)), 0666); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "file.txt")
+		git("commit", "-q", "-m", "commit "+strconv.Itoa(i))
+	}
+	git("tag", "v1.0.0")
+}
+
+func revListCount(t *testing.T, dir string) int {
+	cmd := exec.Command("git", "rev-list", "--count", "--all")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git rev-list failed: %s\n%s", err, out)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestGitRepoResumableGet(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("upstream")
+	h.TempDir("cache")
+
+	const commits = 10
+	makeUpstreamGitRepo(t, h.Path("upstream"), commits)
+	remote := "file://" + filepath.ToSlash(h.Path("upstream"))
+
+	t.Run("fresh", func(t *testing.T) {
+		rep, err := vcs.NewGitRepo(remote, filepath.Join(h.Path("cache"), "fresh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo := &gitRepo{GitRepo: rep, resumable: true}
+
+		if err = repo.get(context.Background()); err != nil {
+			t.Fatalf("resumable clone failed: %s", err)
+		}
+		if !repo.CheckLocal() || repo.cloneIncomplete() {
+			t.Fatal("expected clone to be complete")
+		}
+		if n := revListCount(t, repo.LocalPath()); n != commits {
+			t.Errorf("expected all %d commits to be fetched, got %d", commits, n)
+		}
+
+		// File contents were filtered out of the fetches, so exporting an old
+		// revision has to fetch them lazily.
+		src := &gitSource{baseVCSSource: baseVCSSource{repo: repo}}
+		rev, err := src.disambiguateRevision(context.Background(), Revision("v1.0.0~5"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		to := filepath.Join(h.Path("cache"), "export")
+		if err = src.exportRevisionTo(context.Background(), rev, to); err != nil {
+			t.Fatalf("export from resumable clone failed: %s", err)
+		}
+		if data, err := ioutil.ReadFile(filepath.Join(to, "file.txt")); err != nil || string(data) != "4" {
+			t.Errorf("unexpected exported contents %q, err %v", data, err)
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		rep, err := vcs.NewGitRepo(remote, filepath.Join(h.Path("cache"), "interrupted"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Not resumable: an interrupted clone must be resumed regardless.
+		repo := &gitRepo{GitRepo: rep}
+
+		// Leave behind the state of a clone that fetched the tip of history,
+		// then was interrupted.
+		h.TempDir(filepath.Join("cache", "interrupted", ".git"))
+		if err = repo.writeResumeState(0); err != nil {
+			t.Fatal(err)
+		}
+		if err = repo.initResumable(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err = repo.runGit(context.Background(), true, "fetch failed", "fetch", "--depth=1", "origin"); err != nil {
+			t.Fatal(err)
+		}
+		if err = repo.writeResumeState(1); err != nil {
+			t.Fatal(err)
+		}
+
+		if repo.CheckLocal() {
+			t.Fatal("an incomplete clone should not count as a local copy")
+		}
+		if err = repo.get(context.Background()); err != nil {
+			t.Fatalf("resuming clone failed: %s", err)
+		}
+		if !repo.CheckLocal() || repo.cloneIncomplete() {
+			t.Fatal("expected resumed clone to be complete")
+		}
+		if n := revListCount(t, repo.LocalPath()); n != commits {
+			t.Errorf("expected all %d commits to be fetched, got %d", commits, n)
+		}
+	})
+}
+
+func TestGitRepoTagsOnly(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("upstream")
+	h.TempDir("cache")
+
+	const commits = 3
+	upstream := h.Path("upstream")
+	makeUpstreamGitRepo(t, upstream, commits)
+	commit := func(args ...string) {
+		for _, args := range [][]string{{"commit", "-q", "--allow-empty", "-m", "untagged"}, args} {
+			if len(args) == 0 {
+				continue
+			}
+			cmd := exec.Command("git", args...)
+			cmd.Dir = upstream
+			cmd.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+				"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %s\n%s", args, err, out)
+			}
+		}
+	}
+	// A commit that only a branch reaches must not be fetched.
+	commit()
+
+	rep, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(h.Path("cache"), "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &gitRepo{GitRepo: rep}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: repo}}
+	src.fetchTagsOnly()
+	ctx := context.Background()
+
+	if err = repo.get(ctx); err != nil {
+		t.Fatalf("tags-only get failed: %s", err)
+	}
+	if n := revListCount(t, repo.LocalPath()); n != commits {
+		t.Errorf("expected the %d commits reached by tags to be fetched, got %d", commits, n)
+	}
+
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 1 || vlist[0].String() != "v1.0.0" {
+		t.Errorf("expected only the tag to be listed, got %v", vlist)
+	}
+
+	to := filepath.Join(h.Path("cache"), "export")
+	if err = src.exportRevisionTo(ctx, vlist[0].Revision(), to); err != nil {
+		t.Fatalf("export from tags-only repository failed: %s", err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(to, "file.txt")); err != nil || string(data) != strconv.Itoa(commits-1) {
+		t.Errorf("unexpected exported contents %q, err %v", data, err)
+	}
+
+	commit("tag", "v1.1.0")
+	if err = repo.fetch(ctx); err != nil {
+		t.Fatalf("tags-only fetch failed: %s", err)
+	}
+	if n := revListCount(t, repo.LocalPath()); n != commits+2 {
+		t.Errorf("expected the %d commits reached by tags to be fetched, got %d", commits+2, n)
+	}
+
+	// The cache repository is shared with projects that want branches, which
+	// a normal fetch into it must get, even if an earlier version of dep
+	// configured it to fetch only tags.
+	commit()
+	if out, err := exec.Command("git", "-C", repo.LocalPath(), "config", "remote.origin.fetch", gitTagsRefspec).CombinedOutput(); err != nil {
+		t.Fatalf("git config failed: %s\n%s", err, out)
+	}
+	shared := &gitRepo{GitRepo: rep}
+	if err = shared.fetch(ctx); err != nil {
+		t.Fatalf("fetch into tags-only repository failed: %s", err)
+	}
+	if n := revListCount(t, repo.LocalPath()); n != commits+3 {
+		t.Errorf("expected the %d commits reached by branches to be fetched, got %d", commits+3, n)
+	}
+}
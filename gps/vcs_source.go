@@ -8,10 +8,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/golang/dep/gps/pkgtree"
@@ -55,6 +57,14 @@ func (bs *baseVCSSource) disambiguateRevision(ctx context.Context, r Revision) (
 	return Revision(ci.Commit), nil
 }
 
+func (bs *baseVCSSource) revisionTime(r Revision) (time.Time, error) {
+	ci, err := bs.repo.CommitInfo(string(r))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ci.Date, nil
+}
+
 func (bs *baseVCSSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
 	err := bs.repo.updateVersion(ctx, r.String())
 	if err != nil {
@@ -159,12 +169,99 @@ type gitSource struct {
 	baseVCSSource
 }
 
+func (s *gitSource) enableResumableFetches(logger *log.Logger) {
+	if r, ok := s.repo.(*gitRepo); ok {
+		r.resumable = true
+		r.logger = logger
+	}
+}
+
+func (s *gitSource) fetchTagsOnly() {
+	if r, ok := s.repo.(*gitRepo); ok {
+		r.tagsOnly = true
+	}
+}
+
+func (s *gitSource) tagsOnly() bool {
+	r, ok := s.repo.(*gitRepo)
+	return ok && r.tagsOnly
+}
+
 func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to string) error {
+	return s.checkoutTree(ctx, rev, nil, to)
+}
+
+// exportPrunedRevisionTo writes out the pruned tree of lp at rev. When unused
+// packages are to be pruned, only the files that would survive are checked
+// out, which avoids writing out the whole of a large repository of which few
+// packages are used. The result is the same as that of pruning a full export.
+func (s *gitSource) exportPrunedRevisionTo(ctx context.Context, rev Revision, lp LockedProject, prune PruneOptions, to string) error {
+	var paths []string
+	if prune&PruneUnusedPackages != 0 {
+		var err error
+		if paths, err = s.sparsePaths(ctx, rev, lp.Packages(), assetsOf(lp)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkoutTree(ctx, rev, paths, to); err != nil {
+		return err
+	}
+	return PruneProject(to, lp, prune)
+}
+
+// sparsePaths lists the paths in the tree at rev that survive the pruning of
+// packages other than pkgs: the files of those packages, preserved files such
+// as licenses and assets wherever they are, and all symlinks, which pruning of
+// unused packages leaves alone.
+func (s *gitSource) sparsePaths(ctx context.Context, rev Revision, pkgs []string, assets *pkgtree.AssetRuleset) ([]string, error) {
+	cmd := commandContext(ctx, "git", "ls-tree", "-r", "-z", "--full-tree", rev.String())
+	cmd.SetDir(s.repo.LocalPath())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+
+	imported := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		imported[pkg] = true
+	}
+
+	// Each entry is "<mode> <type> <object>\t<path>".
+	paths := []string{}
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		tab := bytes.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		meta, path := strings.Fields(string(entry[:tab])), string(entry[tab+1:])
+		if len(meta) != 3 || meta[1] != "blob" {
+			// Submodules are not checked out by a full export either.
+			continue
+		}
+
+		dir, base := ".", path
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			dir, base = path[:i], path[i+1:]
+		}
+		if meta[0] == "120000" || imported[dir] || isPreservedFile(base) || assets.IsAsset(path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// checkoutTree writes out the given paths of the tree at rev to the directory
+// to, or the whole tree if paths is nil.
+func (s *gitSource) checkoutTree(ctx context.Context, rev Revision, paths []string, to string) error {
 	r := s.repo
 
 	if err := os.MkdirAll(to, 0777); err != nil {
 		return err
 	}
+	if paths != nil && len(paths) == 0 {
+		return nil
+	}
 
 	// Back up original index
 	idx, bak := filepath.Join(r.LocalPath(), ".git", "index"), filepath.Join(r.LocalPath(), ".git", "origindex")
@@ -196,8 +293,19 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	// down, the sparse checkout controls, as well as restore the original
 	// index and HEAD.
 	{
-		cmd := commandContext(ctx, "git", "checkout-index", "-a", "--prefix="+to)
+		args := []string{"checkout-index", "--prefix=" + to}
+		if paths == nil {
+			args = append(args, "-a")
+		} else {
+			// Only the named paths are checked out, read from stdin to
+			// avoid overflowing the command line.
+			args = append(args, "-z", "--stdin")
+		}
+		cmd := commandContext(ctx, "git", args...)
 		cmd.SetDir(r.LocalPath())
+		if paths != nil {
+			cmd.SetStdin(strings.NewReader(strings.Join(paths, "\x00") + "\x00"))
+		}
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return errors.Wrap(err, string(out))
 		}
@@ -206,6 +314,19 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	return nil
 }
 
+func (s *gitSource) isAncestor(ctx context.Context, r, of Revision) (bool, error) {
+	cmd := commandContext(ctx, "git", "merge-base", "--is-ancestor", r.String(), of.String())
+	cmd.SetDir(s.repo.LocalPath())
+	out, err := cmd.CombinedOutput()
+	if exitStatus(err) == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, string(out))
+	}
+	return true, nil
+}
+
 func (s *gitSource) isValidHash(hash []byte) bool {
 	return gitHashRE.Match(hash)
 }
@@ -268,6 +389,7 @@ func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, er
 	// erroneous non-default branch in their lock file.
 	var headrev Revision
 	var onedef, multidef, defmaster bool
+	tagsOnly := s.tagsOnly()
 
 	smap := make(map[string]int)
 	uniq := 0
@@ -285,6 +407,9 @@ func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, er
 			// If HEAD is present, it's always first
 			headrev = Revision(pair[:40])
 		} else if string(pair[46:51]) == "heads" {
+			if tagsOnly {
+				continue
+			}
 			rev := Revision(pair[:40])
 
 			isdef := rev == headrev
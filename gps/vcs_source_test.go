@@ -17,7 +17,9 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/Masterminds/vcs"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -858,6 +860,200 @@ func Test_hgSource_exportRevisionTo_removeVcsFiles(t *testing.T) {
 	}
 }
 
+func Test_gitSource_exportPrunedRevisionTo_sparse(t *testing.T) {
+	requiresBins(t, "git")
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("upstream")
+	h.TempDir("cache")
+	upstream := h.Path("upstream")
+
+	files := map[string]string{
+		"root.go":         "package root",
+		"LICENSE":         "license",
+		"README.md":       "readme",
+		"sub/a/a.go":      "package a",
+		"sub/a/a.txt":     "text",
+		"other/b/b.go":    "package b",
+		"other/b/COPYING": "copying",
+		"docs/README.md":  "docs",
+	}
+	for name, contents := range files {
+		h.TempFile(filepath.Join("upstream", filepath.FromSlash(name)), contents)
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	rep, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(h.Path("cache"), "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: &gitRepo{GitRepo: rep}}}
+	if err = src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := src.disambiguateRevision(ctx, Revision("HEAD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lp := NewLockedProject(mkPI("example.com/mono"), rev, []string{"sub/a"})
+	for _, prune := range []PruneOptions{PruneUnusedPackages, PruneUnusedPackages | PruneNonGoFiles} {
+		full := filepath.Join(h.Path("cache"), "full")
+		sparse := filepath.Join(h.Path("cache"), "sparse")
+
+		if err := src.exportRevisionTo(ctx, rev, full); err != nil {
+			t.Fatal(err)
+		}
+		if err := PruneProject(full, lp, prune); err != nil {
+			t.Fatal(err)
+		}
+		if err := src.exportPrunedRevisionTo(ctx, rev, lp, prune, sparse); err != nil {
+			t.Fatalf("sparse export failed: %s", err)
+		}
+
+		want, err := deriveFilesystemState(full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := deriveFilesystemState(sparse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.files, want.files) || !reflect.DeepEqual(got.dirs, want.dirs) {
+			t.Errorf("prune %v: sparse export wrote files %v in dirs %v, expected files %v in dirs %v", prune, got.files, got.dirs, want.files, want.dirs)
+		}
+		if _, err := os.Stat(filepath.Join(sparse, "other", "b", "b.go")); !os.IsNotExist(err) {
+			t.Errorf("prune %v: expected unused package to be left out of sparse export", prune)
+		}
+
+		os.RemoveAll(full)
+		os.RemoveAll(sparse)
+	}
+}
+
+func Test_gitSource_revisionTime(t *testing.T) {
+	requiresBins(t, "git")
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("upstream")
+	h.TempDir("cache")
+	upstream := h.Path("upstream")
+	h.TempFile(filepath.Join("upstream", "root.go"), "package root")
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_AUTHOR_DATE=2018-06-01T12:00:00Z", "GIT_COMMITTER_DATE=2018-06-01T12:00:00Z",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	rep, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(h.Path("cache"), "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: &gitRepo{GitRepo: rep}}}
+	if err = src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := src.disambiguateRevision(ctx, Revision("HEAD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := src.revisionTime(rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected the revision to have been made at %s, got %s", want, got)
+	}
+}
+
+func Test_gitSource_isAncestor(t *testing.T) {
+	requiresBins(t, "git")
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("upstream")
+	h.TempDir("cache")
+	upstream := h.Path("upstream")
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	h.TempFile(filepath.Join("upstream", "root.go"), "package root")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	first := Revision(git("rev-parse", "HEAD"))
+	h.TempFile(filepath.Join("upstream", "root.go"), "package root // changed")
+	git("commit", "-q", "-a", "-m", "second")
+	second := Revision(git("rev-parse", "HEAD"))
+
+	rep, err := vcs.NewGitRepo("file://"+filepath.ToSlash(upstream), filepath.Join(h.Path("cache"), "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: &gitRepo{GitRepo: rep}}}
+	if err = src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		r, of Revision
+		want  bool
+	}{
+		{first, second, true},
+		{second, second, true},
+		{second, first, false},
+	} {
+		got, err := src.isAncestor(ctx, c.r, c.of)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("expected isAncestor(%s, %s) to be %v", c.r, c.of, c.want)
+		}
+	}
+}
+
 // Fail a test if the specified binaries aren't installed.
 func requiresBins(t *testing.T, bins ...string) {
 	for _, b := range bins {
@@ -0,0 +1,61 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// VCSToolFor returns the name of the version control tool, such as "git",
+// that handles the source of the project identified by id, or the empty
+// string if its source needs none, as for bundles.
+func (sm *SourceMgr) VCSToolFor(id ProjectIdentifier) (string, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return "", ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return "", err
+	}
+	if _, is := srcg.src.(*bundleSource); is {
+		return "", nil
+	}
+	return srcg.src.sourceType(), nil
+}
+
+// The arguments with which each version control tool reports its version.
+var vcsVersionArgs = map[string][]string{
+	"git": {"--version"},
+	"hg":  {"--version", "--quiet"},
+	"bzr": {"--version"},
+	"svn": {"--version", "--quiet"},
+}
+
+var vcsVersionRe = regexp.MustCompile(`[0-9]+(\.[0-9]+)+`)
+
+// VCSToolVersion returns the version of the named version control tool on
+// PATH, such as "2.20.1" for git.
+func VCSToolVersion(ctx context.Context, tool string) (string, error) {
+	args, has := vcsVersionArgs[tool]
+	if !has {
+		return "", errors.Errorf("unknown version control tool %q", tool)
+	}
+	out, err := commandContext(ctx, tool, args...).CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s %s", tool, strings.Join(args, " "))
+	}
+	first := strings.SplitN(string(out), "\n", 2)[0]
+	v := vcsVersionRe.FindString(first)
+	if v == "" {
+		return "", errors.Errorf("no version in the output of %s %s: %q", tool, strings.Join(args, " "), first)
+	}
+	return v, nil
+}
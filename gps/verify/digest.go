@@ -16,7 +16,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/vfs"
 	"github.com/pkg/errors"
 )
 
@@ -161,7 +165,53 @@ type dirWalkClosure struct {
 // skips symbolic links, and for now, we want the hash to include the symbolic
 // link referents.
 func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
+	return DigestFromDirectoryFS(vfs.OS, osDirname)
+}
+
+// DigestFromDirectoryFS is like DigestFromDirectory, but hashes the specified
+// directory in fsys.
+func DigestFromDirectoryFS(fsys vfs.FS, osDirname string) (VersionedDigest, error) {
+	return digestFromDirectoryFS(fsys, osDirname, DigestExclusions{})
+}
+
+// DigestExclusions describes the parts of a project's tree left out of its
+// digest.
+type DigestExclusions struct {
+	// Nested holds the slash-separated paths, relative to the project root,
+	// of the directories of the projects nested within it; see
+	// NestedProjects.
+	Nested []string
+	// Generated holds the patterns of the project's generated files, in the
+	// syntax of pkgtree.AssetRuleset.
+	Generated []string
+}
+
+// DigestFromDirectoryExcluding is like DigestFromDirectory, but leaves out the
+// parts of the tree in osDirname described by ex.
+func DigestFromDirectoryExcluding(osDirname string, ex DigestExclusions) (VersionedDigest, error) {
+	return digestFromDirectoryFS(vfs.OS, osDirname, ex)
+}
+
+// digestFromDirectoryFS implements DigestFromDirectoryFS, leaving out the
+// parts of the tree described by ex.
+func digestFromDirectoryFS(fsys vfs.FS, osDirname string, ex DigestExclusions) (VersionedDigest, error) {
+	gps.CountMetric(gps.MetricHashComputations, 1)
+	start := time.Now()
+	vd, err := hashDirectoryFS(fsys, osDirname, ex)
+	gps.SendEvent(gps.Event{Kind: gps.EventTreeHashed, Duration: time.Since(start), Err: err})
+	return vd, err
+}
+
+func hashDirectoryFS(fsys vfs.FS, osDirname string, ex DigestExclusions) (VersionedDigest, error) {
 	osDirname = filepath.Clean(osDirname)
+	skip := make(map[string]bool, len(ex.Nested))
+	for _, rel := range ex.Nested {
+		skip[filepath.FromSlash(rel)] = true
+	}
+	var generated *pkgtree.AssetRuleset
+	if len(ex.Generated) > 0 {
+		generated = pkgtree.NewAssetRuleset(ex.Generated)
+	}
 
 	// Create a single hash instance for the entire operation, rather than a new
 	// hash for each node we encounter.
@@ -173,7 +223,7 @@ func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 		someHash:      sha256.New(),
 	}
 
-	err := DirWalk(osDirname, func(osPathname string, info os.FileInfo, err error) error {
+	err := DirWalkFS(fsys, osDirname, func(osPathname string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err // DirWalk received an error during initial Lstat
 		}
@@ -187,6 +237,19 @@ func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 		case "vendor", ".bzr", ".git", ".hg", ".svn":
 			return filepath.SkipDir
 		}
+		if osRelative == ProvenanceName {
+			// Written by dep alongside the project's code, rather than part of it.
+			return nil
+		}
+		if skip[osRelative] {
+			return filepath.SkipDir
+		}
+		if generated.IsAsset(filepath.ToSlash(osRelative)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 
 		// We could make our own enum-like data type for encoding the file type,
 		// but Go's runtime already gives us architecture independent file
@@ -238,7 +301,7 @@ func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 		}
 
 		if mt == os.ModeSymlink { // okay to check for equivalence because we set to this value
-			osRelative, err = os.Readlink(osPathname) // read the symlink referent
+			osRelative, err = fsys.Readlink(osPathname) // read the symlink referent
 			if err != nil {
 				return errors.Wrap(err, "cannot Readlink")
 			}
@@ -247,7 +310,7 @@ func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 		}
 
 		// If we get here, node is a regular file.
-		fh, err := os.Open(osPathname)
+		fh, err := fsys.Open(osPathname)
 		if err != nil {
 			return errors.Wrap(err, "cannot Open")
 		}
@@ -305,6 +368,12 @@ const (
 	// the digest being compared against is not the same as the one used by the
 	// current program.
 	HashVersionMismatch
+
+	// DigestMismatchSkipped is used in place of DigestMismatchInLock,
+	// EmptyDigestInLock or HashVersionMismatch for a dependency whose vendored
+	// code is known to have been modified, and so is not to be verified. It
+	// is reported, but not treated as a failure.
+	DigestMismatchSkipped
 )
 
 func (ls VendorStatus) String() string {
@@ -321,6 +390,8 @@ func (ls VendorStatus) String() string {
 		return "mismatch"
 	case HashVersionMismatch:
 		return "hasher changed"
+	case DigestMismatchSkipped:
+		return "mismatch, skipped"
 	}
 	return "unknown"
 }
@@ -384,10 +455,34 @@ func ParseVersionedDigest(input string) (VersionedDigest, error) {
 // solidus, one particular dependency would be represented as
 // "github.com/alice/alice1".
 func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map[string]VendorStatus, error) {
+	return CheckDepTreeFS(vfs.OS, osDirname, wantDigests)
+}
+
+// CheckDepTreeFS is like CheckDepTree, but verifies the dependency tree in
+// fsys.
+func CheckDepTreeFS(fsys vfs.FS, osDirname string, wantDigests map[string]VersionedDigest) (map[string]VendorStatus, error) {
+	return checkDepTree(fsys, osDirname, wantDigests, nil, func(osPathname string, ex DigestExclusions) (VersionedDigest, error) {
+		return digestFromDirectoryFS(fsys, osPathname, ex)
+	})
+}
+
+// CheckDepTreeExcluding is like CheckDepTree, but leaves the generated files
+// of each project, given by the patterns in generated, out of its digest. The
+// keys to generated are the same as those to wantDigests.
+func CheckDepTreeExcluding(osDirname string, wantDigests map[string]VersionedDigest, generated map[string][]string) (map[string]VendorStatus, error) {
+	return checkDepTree(vfs.OS, osDirname, wantDigests, generated, func(osPathname string, ex DigestExclusions) (VersionedDigest, error) {
+		return digestFromDirectoryFS(vfs.OS, osPathname, ex)
+	})
+}
+
+// checkDepTree implements CheckDepTreeFS, computing the digest of each
+// project directory found with digest, leaving out the directories of the
+// projects nested within it, and its generated files.
+func checkDepTree(fsys vfs.FS, osDirname string, wantDigests map[string]VersionedDigest, generated map[string][]string, digest func(string, DigestExclusions) (VersionedDigest, error)) (map[string]VendorStatus, error) {
 	osDirname = filepath.Clean(osDirname)
 
 	// Ensure top level pathname is a directory
-	fi, err := os.Stat(osDirname)
+	fi, err := fsys.Stat(osDirname)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot Stat")
 	}
@@ -459,13 +554,14 @@ func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map
 		osPathname := filepath.Join(osDirname, currentNode.osRelative)
 
 		if expectedSum, ok := wantDigests[slashPathname]; ok {
+			nested := nestedIn(slashPathname, wantDigests)
 			ls := EmptyDigestInLock
 			if expectedSum.HashVersion != HashVersion {
 				if !expectedSum.IsEmpty() {
 					ls = HashVersionMismatch
 				}
 			} else if len(expectedSum.Digest) > 0 {
-				projectSum, err := DigestFromDirectory(osPathname)
+				projectSum, err := digest(osPathname, DigestExclusions{Nested: nested, Generated: generated[slashPathname]})
 				if err != nil {
 					return nil, errors.Wrap(err, "cannot compute dependency hash")
 				}
@@ -482,16 +578,38 @@ func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map
 				nodes[i].isRequiredAncestor = true
 			}
 
+			// Projects nested within this one, such as another major version
+			// of it, are left out of its digest, and verified on their own.
+			for _, rel := range nested {
+				osChildRelative := filepath.Join(currentNode.osRelative, filepath.FromSlash(rel))
+				fi, err := fsys.Stat(filepath.Join(osDirname, osChildRelative))
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					return nil, errors.Wrap(err, "cannot Stat")
+				}
+				if fi.IsDir() {
+					otherNode := &fsnode{osRelative: osChildRelative, myIndex: len(nodes), parentIndex: currentNode.myIndex}
+					nodes = append(nodes, otherNode)
+					queue = append(queue, otherNode)
+				}
+			}
+
 			// Do not need to process this directory's contents because we
 			// already accounted for its contents while calculating its digest.
 			continue
 		}
 
-		osChildrenNames, err := sortedChildrenFromDirname(osPathname)
+		osChildrenNames, err := sortedChildrenFromDirname(fsys, osPathname)
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot get sorted list of directory children")
 		}
 		for _, osChildName := range osChildrenNames {
+			if currentNode.myIndex == 0 && osChildName == LockSnapshotName {
+				// Recorded alongside the projects, rather than one of them.
+				continue
+			}
 			switch osChildName {
 			case ".", "..", "vendor", ".bzr", ".git", ".hg", ".svn":
 				// skip
@@ -503,7 +621,7 @@ func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map
 				// index set to the index of the current node.
 				otherNode := &fsnode{osRelative: osChildRelative, myIndex: len(nodes), parentIndex: currentNode.myIndex}
 
-				fi, err := os.Stat(osChildPathname)
+				fi, err := fsys.Stat(osChildPathname)
 				if err != nil {
 					return nil, errors.Wrap(err, "cannot Stat")
 				}
@@ -7,9 +7,12 @@ package verify
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/golang/dep/gps/vfs"
 )
 
 // crossBuffer is a test io.Reader that emits a few canned responses.
@@ -233,6 +236,145 @@ func TestVerifyDepTree(t *testing.T) {
 	})
 }
 
+// memFSFrom returns a MemFS holding a copy of the tree rooted at osDirname,
+// with osDirname itself at the root of the MemFS.
+func memFSFrom(t *testing.T, osDirname string) *vfs.MemFS {
+	mfs := vfs.NewMemFS()
+	err := filepath.Walk(osDirname, func(osPathname string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		osRelative, err := filepath.Rel(osDirname, osPathname)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			referent, err := os.Readlink(osPathname)
+			if err != nil {
+				return err
+			}
+			return mfs.Symlink(referent, osRelative)
+		case info.IsDir():
+			return mfs.MkdirAll(osRelative)
+		default:
+			data, err := ioutil.ReadFile(osPathname)
+			if err != nil {
+				return err
+			}
+			return mfs.WriteFile(osRelative, data)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mfs
+}
+
+func TestDigestAndVerifyFS(t *testing.T) {
+	vendorRoot := getTestdataVerifyRoot(t)
+	mfs := memFSFrom(t, vendorRoot)
+
+	wantDigests := make(map[string]VersionedDigest)
+	for _, k := range []string{"github.com/alice/match", "github.com/alice/mismatch", "github.com/bob/match", "launchpad.net/match"} {
+		want, err := DigestFromDirectory(filepath.Join(vendorRoot, k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DigestFromDirectoryFS(mfs, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Digest, want.Digest) {
+			t.Errorf("Digest mismatch for %q between MemFS and OS\n(GOT):\n\t%#v\n(WNT):\n\t%#v", k, got.Digest, want.Digest)
+		}
+		wantDigests[k] = want
+	}
+
+	want, err := CheckDepTree(vendorRoot, wantDigests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := CheckDepTreeFS(mfs, string(filepath.Separator), wantDigests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("Unexpected result count from CheckDepTreeFS:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+	for k, ws := range want {
+		if gs := got[k]; gs != ws {
+			t.Errorf("Key: %q; (GOT): %v; (WNT): %v", k, gs, ws)
+		}
+	}
+}
+
+func TestDigestExcludingGenerated(t *testing.T) {
+	vendorRoot, err := ioutil.TempDir("", "dep-generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(vendorRoot)
+
+	pdir := filepath.Join(vendorRoot, "github.com", "foo", "bar")
+	write := func(rel, contents string) {
+		p := filepath.Join(pdir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("bar.go", "package bar\n")
+	write("zz_generated.go", "// Generated at 10:00.\npackage bar\n")
+	write("gen/types.go", "// Generated at 10:00.\npackage gen\n")
+
+	generated := []string{"zz_generated.go", "gen"}
+	want, err := DigestFromDirectoryExcluding(pdir, DigestExclusions{Generated: generated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := DigestFromDirectory(pdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write("zz_generated.go", "// Generated at 11:00.\npackage bar\n")
+	write("gen/types.go", "// Generated at 11:00.\npackage gen\n")
+	got, err := DigestFromDirectoryExcluding(pdir, DigestExclusions{Generated: generated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Digest, want.Digest) {
+		t.Error("expected regenerated files not to change the digest")
+	}
+
+	status, err := CheckDepTreeExcluding(vendorRoot, map[string]VersionedDigest{"github.com/foo/bar": want}, map[string][]string{"github.com/foo/bar": generated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := status["github.com/foo/bar"]; s != NoMismatch {
+		t.Errorf("expected the regenerated project to verify, got %v", s)
+	}
+	status, err = CheckDepTree(vendorRoot, map[string]VersionedDigest{"github.com/foo/bar": full})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := status["github.com/foo/bar"]; s != DigestMismatchInLock {
+		t.Errorf("expected the regenerated project to mismatch its full digest, got %v", s)
+	}
+
+	write("bar.go", "package baz\n")
+	if got, err = DigestFromDirectoryExcluding(pdir, DigestExclusions{Generated: generated}); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got.Digest, want.Digest) {
+		t.Error("expected a change to a file that is not generated to change the digest")
+	}
+}
+
 func BenchmarkDigestFromDirectory(b *testing.B) {
 	b.Skip("Eliding benchmark of user's Go source directory")
 
@@ -7,9 +7,9 @@ package verify
 import (
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
+	"github.com/golang/dep/gps/vfs"
 	"github.com/pkg/errors"
 )
 
@@ -37,10 +37,15 @@ type DirWalkFunc func(osPathname string, info os.FileInfo, err error) error
 // output deterministic but means that for very large directories DirWalk can be
 // inefficient. Unlike filepath.Walk, DirWalk does follow symbolic links.
 func DirWalk(osDirname string, walkFn DirWalkFunc) error {
+	return DirWalkFS(vfs.OS, osDirname, walkFn)
+}
+
+// DirWalkFS is like DirWalk, but walks the file tree in fsys.
+func DirWalkFS(fsys vfs.FS, osDirname string, walkFn DirWalkFunc) error {
 	osDirname = filepath.Clean(osDirname)
 
 	// Ensure parameter is a directory
-	fi, err := os.Stat(osDirname)
+	fi, err := fsys.Stat(osDirname)
 	if err != nil {
 		return errors.Wrap(err, "cannot read node")
 	}
@@ -64,7 +69,7 @@ func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 
 		// walkFn needs to choose how to handle symbolic links, therefore obtain
 		// lstat rather than stat.
-		fi, err = os.Lstat(osPathname)
+		fi, err = fsys.Lstat(osPathname)
 		if err == nil {
 			err = walkFn(osPathname, fi, nil)
 		} else {
@@ -76,7 +81,7 @@ func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 				if fi.Mode()&os.ModeSymlink > 0 {
 					// Resolve symbolic link referent to determine whether node
 					// is directory or not.
-					fi, err = os.Stat(osPathname)
+					fi, err = fsys.Stat(osPathname)
 					if err != nil {
 						return errors.Wrap(err, "cannot visit node")
 					}
@@ -99,7 +104,7 @@ func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 		}
 
 		if fi.IsDir() {
-			osChildrenNames, err := sortedChildrenFromDirname(osPathname)
+			osChildrenNames, err := sortedChildrenFromDirname(fsys, osPathname)
 			if err != nil {
 				return errors.Wrap(err, "cannot get list of directory children")
 			}
@@ -118,22 +123,16 @@ func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 
 // sortedChildrenFromDirname returns a lexicographically sorted list of child
 // nodes for the specified directory.
-func sortedChildrenFromDirname(osDirname string) ([]string, error) {
-	fh, err := os.Open(osDirname)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot Open")
-	}
-
-	osChildrenNames, err := fh.Readdirnames(0) // 0: read names of all children
+func sortedChildrenFromDirname(fsys vfs.FS, osDirname string) ([]string, error) {
+	fis, err := fsys.ReadDir(osDirname)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot Readdirnames")
+		return nil, errors.Wrap(err, "cannot ReadDir")
 	}
-	sort.Strings(osChildrenNames)
 
-	// Close the file handle to the open directory without masking possible
-	// previous error value.
-	if er := fh.Close(); err == nil {
-		err = errors.Wrap(er, "cannot Close")
+	// ReadDir sorts its results by name.
+	osChildrenNames := make([]string, len(fis))
+	for i, fi := range fis {
+		osChildrenNames[i] = fi.Name()
 	}
-	return osChildrenNames, err
+	return osChildrenNames, nil
 }
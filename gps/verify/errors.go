@@ -0,0 +1,299 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors identifying the categories of verification failure. The
+// errors returned by LockSatisfaction.Err and VendorStatusErr match these
+// under errors.Is, or the Is method of Errors on Go releases without it, so
+// that callers can branch on the category of a failure without inspecting its
+// message. For details, use errors.As or Errors.As with the corresponding
+// error types.
+var (
+	// ErrNoLock indicates that there was no lock to verify.
+	ErrNoLock = errors.New("no lock")
+	// ErrMissingFromLock indicates that something required by the inputs, or
+	// present in vendor, is absent from the lock. See ImportError and
+	// VendorError.
+	ErrMissingFromLock = errors.New("missing from lock")
+	// ErrExcessImport indicates that the lock records an import the inputs no
+	// longer have. See ImportError.
+	ErrExcessImport = errors.New("excess import in lock")
+	// ErrConstraintMismatch indicates that a locked version is not allowed by
+	// a constraint or override rule. See ConstraintMismatchError.
+	ErrConstraintMismatch = errors.New("locked version does not match constraint")
+	// ErrMissingFromVendor indicates that a locked project is absent from
+	// vendor. See VendorError.
+	ErrMissingFromVendor = errors.New("missing from vendor")
+	// ErrDigestMismatch indicates that a vendored project can't be verified
+	// against the digest recorded in the lock, either because they differ, the
+	// digest is absent, or it was produced by a different hash version. See
+	// VendorError.
+	ErrDigestMismatch = errors.New("vendored code does not match lock digest")
+	// ErrGoVersionsChanged indicates that the Go versions declared by the
+	// inputs are not those the lock was solved with. See GoVersionsError.
+	ErrGoVersionsChanged = errors.New("go versions changed since lock was solved")
+	// ErrBuildContextChanged indicates that the ignored packages of the inputs
+	// depend on a build context other than the one the lock was solved for.
+	// See BuildContextError.
+	ErrBuildContextChanged = errors.New("build context changed since lock was solved")
+)
+
+// ImportError describes an import path that is either missing from, or in
+// excess of, a lock's input imports.
+type ImportError struct {
+	ImportPath string
+	// Missing is true if the import is missing from the lock, and false if
+	// the lock has it in excess.
+	Missing bool
+}
+
+func (e *ImportError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("%s: missing from input-imports", e.ImportPath)
+	}
+	return fmt.Sprintf("%s: in input-imports, but isn't imported", e.ImportPath)
+}
+
+// Is makes ImportError match ErrMissingFromLock or ErrExcessImport.
+func (e *ImportError) Is(target error) bool {
+	if e.Missing {
+		return target == ErrMissingFromLock
+	}
+	return target == ErrExcessImport
+}
+
+// ConstraintMismatchError describes a locked project whose version is not
+// allowed by the rule for it in the manifest.
+type ConstraintMismatchError struct {
+	ProjectRoot gps.ProjectRoot
+	ConstraintMismatch
+	// Override is true if the rule that was not met is an override.
+	Override bool
+}
+
+func (e *ConstraintMismatchError) Error() string {
+	kind := "constraint"
+	if e.Override {
+		kind = "override"
+	}
+	return fmt.Sprintf("%s@%s: not allowed by %s %s", e.ProjectRoot, e.V, kind, e.C)
+}
+
+// Is makes ConstraintMismatchError match ErrConstraintMismatch.
+func (e *ConstraintMismatchError) Is(target error) bool {
+	return target == ErrConstraintMismatch
+}
+
+// GoVersionsError describes a change to the Go versions declared by the
+// inputs since the lock was solved, in the form returned by
+// gps.GoVersionInputs.
+type GoVersionsError struct {
+	Lock, Inputs []string
+}
+
+func (e *GoVersionsError) Error() string {
+	list := func(vs []string) string {
+		if len(vs) == 0 {
+			return "none"
+		}
+		return strings.Join(vs, ", ")
+	}
+	return fmt.Sprintf("go versions: %s in input-go-versions, but %s declared", list(e.Lock), list(e.Inputs))
+}
+
+// Is makes GoVersionsError match ErrGoVersionsChanged.
+func (e *GoVersionsError) Is(target error) bool {
+	return target == ErrGoVersionsChanged
+}
+
+// BuildContextError describes a change to the build context since the lock was
+// solved, in the form returned by gps.InputBuildContext.
+type BuildContextError struct {
+	Lock, Inputs string
+}
+
+func (e *BuildContextError) Error() string {
+	show := func(bc string) string {
+		if bc == "" {
+			return "none"
+		}
+		return fmt.Sprintf("%q", bc)
+	}
+	return fmt.Sprintf("build context: %s in input-build-context, but building for %s", show(e.Lock), show(e.Inputs))
+}
+
+// Is makes BuildContextError match ErrBuildContextChanged.
+func (e *BuildContextError) Is(target error) bool {
+	return target == ErrBuildContextChanged
+}
+
+// VendorError describes a project whose vendored code does not agree with the
+// lock.
+type VendorError struct {
+	ProjectRoot gps.ProjectRoot
+	Status      VendorStatus
+}
+
+func (e *VendorError) Error() string {
+	switch e.Status {
+	case NotInTree:
+		return fmt.Sprintf("%s: in lock, but missing from vendor", e.ProjectRoot)
+	case NotInLock:
+		return fmt.Sprintf("%s: in vendor, but not in lock", e.ProjectRoot)
+	case DigestMismatchInLock:
+		return fmt.Sprintf("%s: vendored code does not match the digest in lock", e.ProjectRoot)
+	case HashVersionMismatch:
+		return fmt.Sprintf("%s: digest in lock was made with a different hash version", e.ProjectRoot)
+	case EmptyDigestInLock:
+		return fmt.Sprintf("%s: no digest in lock to verify vendored code against", e.ProjectRoot)
+	case DigestMismatchSkipped:
+		return fmt.Sprintf("%s: vendored code does not match the digest in lock, but is not verified", e.ProjectRoot)
+	}
+	return fmt.Sprintf("%s: %s", e.ProjectRoot, e.Status)
+}
+
+// Is makes VendorError match ErrMissingFromVendor, ErrMissingFromLock, or
+// ErrDigestMismatch, according to its Status.
+func (e *VendorError) Is(target error) bool {
+	switch e.Status {
+	case NotInTree:
+		return target == ErrMissingFromVendor
+	case NotInLock:
+		return target == ErrMissingFromLock
+	case DigestMismatchInLock, HashVersionMismatch, EmptyDigestInLock:
+		return target == ErrDigestMismatch
+	}
+	return false
+}
+
+// Errors is a collection of verification failures. Its Is and As methods
+// match any one of its elements, both when called directly and through
+// errors.Is and errors.As.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d verification failure(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the failures is target, or matches it through
+// its own Is method.
+func (e Errors) Is(target error) bool {
+	comparable := target == nil || reflect.TypeOf(target).Comparable()
+	for _, err := range e {
+		if comparable && err == target {
+			return true
+		}
+		if x, ok := err.(interface{ Is(error) bool }); ok && x.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target, which must be a non-nil pointer, to the first failure
+// assignable to the type it points to, or that sets it through its own As
+// method, and reports whether there was one.
+func (e Errors) As(target interface{}) bool {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		panic("verify: As target must be a non-nil pointer")
+	}
+	typ := val.Type().Elem()
+	for _, err := range e {
+		if reflect.TypeOf(err).AssignableTo(typ) {
+			val.Elem().Set(reflect.ValueOf(err))
+			return true
+		}
+		if x, ok := err.(interface{ As(interface{}) bool }); ok && x.As(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns nil if the lock satisfied its inputs; warnings are not
+// considered. Otherwise, it returns ErrNoLock if there was no lock, or an
+// Errors containing an *ImportError or *ConstraintMismatchError for each
+// failure.
+func (ls LockSatisfaction) Err() error {
+	if !ls.LockExisted {
+		return ErrNoLock
+	}
+
+	var errs Errors
+	for _, ip := range ls.MissingImports {
+		errs = append(errs, &ImportError{ImportPath: ip, Missing: true})
+	}
+	for _, ip := range ls.ExcessImports {
+		errs = append(errs, &ImportError{ImportPath: ip})
+	}
+	errs = appendConstraintMismatches(errs, ls.UnmetOverrides, true)
+	errs = appendConstraintMismatches(errs, ls.UnmetConstraints, false)
+	if ls.ChangedGoVersions != nil {
+		errs = append(errs, ls.ChangedGoVersions)
+	}
+	if ls.ChangedBuildContext != nil {
+		errs = append(errs, ls.ChangedBuildContext)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func appendConstraintMismatches(errs Errors, mismatches map[gps.ProjectRoot]ConstraintMismatch, override bool) Errors {
+	prs := make([]gps.ProjectRoot, 0, len(mismatches))
+	for pr := range mismatches {
+		prs = append(prs, pr)
+	}
+	sort.Slice(prs, func(i, j int) bool { return prs[i] < prs[j] })
+
+	for _, pr := range prs {
+		errs = append(errs, &ConstraintMismatchError{
+			ProjectRoot:        pr,
+			ConstraintMismatch: mismatches[pr],
+			Override:           override,
+		})
+	}
+	return errs
+}
+
+// VendorStatusErr converts the result of CheckDepTree into an error. It
+// returns nil if every project's status is NoMismatch or DigestMismatchSkipped,
+// and otherwise an Errors containing a *VendorError for each project that
+// doesn't match, ordered by project root.
+func VendorStatusErr(status map[string]VendorStatus) error {
+	prs := make([]string, 0, len(status))
+	for pr, stat := range status {
+		if stat != NoMismatch && stat != DigestMismatchSkipped {
+			prs = append(prs, pr)
+		}
+	}
+	if len(prs) == 0 {
+		return nil
+	}
+	sort.Strings(prs)
+
+	errs := make(Errors, 0, len(prs))
+	for _, pr := range prs {
+		errs = append(errs, &VendorError{ProjectRoot: gps.ProjectRoot(pr), Status: status[pr]})
+	}
+	return errs
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+// matches reports whether err matches target through the Is method of Errors,
+// which, unlike errors.Is, is available on every Go release dep supports.
+func matches(err, target error) bool {
+	if errs, ok := err.(Errors); ok {
+		return errs.Is(target)
+	}
+	return err == target
+}
+
+func TestLockSatisfactionErr(t *testing.T) {
+	var zero LockSatisfaction
+	if err := zero.Err(); err != ErrNoLock {
+		t.Errorf("expected ErrNoLock from zero value, got %v", err)
+	}
+
+	sat := LockSatisfaction{LockExisted: true}
+	if err := sat.Err(); err != nil {
+		t.Errorf("expected nil error from satisfied lock, got %v", err)
+	}
+
+	lsat := LockSatisfaction{
+		LockExisted:    true,
+		MissingImports: []string{"foo.com/bar"},
+		ExcessImports:  []string{"baz.com/qux"},
+		UnmetConstraints: map[gps.ProjectRoot]ConstraintMismatch{
+			"foo.com/bar": {C: gps.NewVersion("v2.0.0"), V: gps.NewVersion("v1.0.0")},
+		},
+		IgnoredProjects: []gps.ProjectRoot{"ignored.com/proj"},
+	}
+	err := lsat.Err()
+
+	for _, sentinel := range []error{ErrMissingFromLock, ErrExcessImport, ErrConstraintMismatch} {
+		if !matches(err, sentinel) {
+			t.Errorf("expected error to match %q", sentinel)
+		}
+	}
+	for _, sentinel := range []error{ErrDigestMismatch, ErrMissingFromVendor, ErrNoLock} {
+		if matches(err, sentinel) {
+			t.Errorf("expected error not to match %q", sentinel)
+		}
+	}
+
+	var cme *ConstraintMismatchError
+	if errs, ok := err.(Errors); !ok || !errs.As(&cme) {
+		t.Fatal("expected error to contain a *ConstraintMismatchError")
+	}
+	if cme.ProjectRoot != "foo.com/bar" || cme.Override {
+		t.Errorf("unexpected constraint mismatch: %+v", cme)
+	}
+
+	if errs := err.(Errors); len(errs) != 3 {
+		t.Errorf("expected warnings to be excluded, leaving 3 errors, got %d: %s", len(errs), err)
+	}
+}
+
+func TestVendorStatusErr(t *testing.T) {
+	if err := VendorStatusErr(map[string]VendorStatus{"foo.com/bar": NoMismatch}); err != nil {
+		t.Errorf("expected nil error when all projects match, got %v", err)
+	}
+	if err := VendorStatusErr(map[string]VendorStatus{"foo.com/bar": DigestMismatchSkipped}); err != nil {
+		t.Errorf("expected nil error when mismatches are skipped, got %v", err)
+	}
+
+	err := VendorStatusErr(map[string]VendorStatus{
+		"a.com/match":    NoMismatch,
+		"b.com/mismatch": DigestMismatchInLock,
+		"c.com/notree":   NotInTree,
+		"d.com/nolock":   NotInLock,
+	})
+
+	for _, sentinel := range []error{ErrDigestMismatch, ErrMissingFromVendor, ErrMissingFromLock} {
+		if !matches(err, sentinel) {
+			t.Errorf("expected error to match %q", sentinel)
+		}
+	}
+	if matches(err, ErrExcessImport) {
+		t.Errorf("expected error not to match %q", ErrExcessImport)
+	}
+
+	var ve *VendorError
+	if errs, ok := err.(Errors); !ok || !errs.As(&ve) {
+		t.Fatal("expected error to contain a *VendorError")
+	}
+	if ve.ProjectRoot != "b.com/mismatch" {
+		t.Errorf("expected first vendor error to be for b.com/mismatch, got %s", ve.ProjectRoot)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+// InternalImport is an import, by a package reachable from the root project,
+// of a package inside an internal directory of another project.
+//
+// The go tool allows an internal package to be imported from anywhere within
+// the directory holding the internal directory, whichever project the
+// importer belongs to. An import such as that of github.com/foo/bar/internal/x
+// by github.com/foo/bar/v2, a project of its own, therefore builds only
+// because the projects' import paths happen to nest, and breaks once the
+// internal package is enforced relative to the root of the project it is in.
+type InternalImport struct {
+	// Chain holds the imports leading to the internal package, starting from
+	// a package of the root project, and ending with the importer, followed
+	// by the internal package.
+	Chain []string
+	// Importer and Imported are the projects the last two packages of Chain
+	// are in. Importer is the empty string for the root project.
+	Importer, Imported gps.ProjectRoot
+}
+
+func (ii InternalImport) String() string {
+	importer := string(ii.Importer)
+	if importer == "" {
+		importer = "the root project"
+	}
+	return fmt.Sprintf("%s imports an internal package of %s: %s", importer, ii.Imported, strings.Join(ii.Chain, " -> "))
+}
+
+// CrossProjectInternalImports returns the imports of internal packages of one
+// project by another among the packages reachable from those in rpt, the
+// package tree of the root project, through the packages in vendored, the
+// package trees of the vendored projects by project root. The imports are
+// sorted by their chains, each of which is the shortest found.
+func CrossProjectInternalImports(rpt pkgtree.PackageTree, vendored map[gps.ProjectRoot]pkgtree.PackageTree) []InternalImport {
+	roots := make(map[string]bool, len(vendored))
+	imports := make(map[string][]string)
+	for pr, ptree := range vendored {
+		roots[string(pr)] = true
+		for ip, perr := range ptree.Packages {
+			if perr.Err == nil {
+				imports[ip] = perr.P.Imports
+			}
+		}
+	}
+
+	var queue []string
+	from := make(map[string]string)
+	for ip, perr := range rpt.Packages {
+		if perr.Err != nil {
+			continue
+		}
+		imports[ip] = append(append([]string(nil), perr.P.Imports...), perr.P.TestImports...)
+		from[ip] = ""
+		queue = append(queue, ip)
+	}
+	sort.Strings(queue)
+
+	projectOf := func(ip string) string {
+		if isPathPrefix(string(rpt.ImportRoot), ip) {
+			return ""
+		}
+		pr := ip
+		for !roots[pr] {
+			i := strings.LastIndex(pr, "/")
+			if i == -1 {
+				return ip
+			}
+			pr = pr[:i]
+		}
+		return pr
+	}
+
+	var found []InternalImport
+	for len(queue) > 0 {
+		ip := queue[0]
+		queue = queue[1:]
+		importer := projectOf(ip)
+
+		deps := append([]string(nil), imports[ip]...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, has := imports[dep]; !has {
+				// Not vendored, as for the standard library.
+				continue
+			}
+			if imported := projectOf(dep); imported != importer && isInternal(dep[len(imported):]) {
+				var chain []string
+				for p := ip; ; p = from[p] {
+					chain = append([]string{p}, chain...)
+					if from[p] == "" {
+						break
+					}
+				}
+				found = append(found, InternalImport{
+					Chain:    append(chain, dep),
+					Importer: gps.ProjectRoot(importer),
+					Imported: gps.ProjectRoot(imported),
+				})
+			}
+			if _, seen := from[dep]; !seen {
+				from[dep] = ip
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return strings.Join(found[i].Chain, " ") < strings.Join(found[j].Chain, " ")
+	})
+	return found
+}
+
+// isInternal reports whether the slash-separated path has an internal element.
+func isInternal(path string) bool {
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPathPrefix reports whether path is prefix, or below it.
+func isPathPrefix(prefix, path string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
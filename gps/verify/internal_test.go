@@ -0,0 +1,54 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+func mkPackageTree(root string, imports map[string][]string) pkgtree.PackageTree {
+	ptree := pkgtree.PackageTree{ImportRoot: root, Packages: make(map[string]pkgtree.PackageOrErr)}
+	for ip, imps := range imports {
+		ptree.Packages[ip] = pkgtree.PackageOrErr{P: pkgtree.Package{ImportPath: ip, Imports: imps}}
+	}
+	return ptree
+}
+
+func TestCrossProjectInternalImports(t *testing.T) {
+	rpt := mkPackageTree("root", map[string][]string{
+		"root":               {"fmt", "github.com/foo/bar/v2", "root/internal/util"},
+		"root/internal/util": {"github.com/foo/bar"},
+	})
+	vendored := map[gps.ProjectRoot]pkgtree.PackageTree{
+		"github.com/foo/bar": mkPackageTree("github.com/foo/bar", map[string][]string{
+			"github.com/foo/bar":                {"github.com/foo/bar/internal/codec"},
+			"github.com/foo/bar/internal/codec": {"strings"},
+		}),
+		"github.com/foo/bar/v2": mkPackageTree("github.com/foo/bar/v2", map[string][]string{
+			"github.com/foo/bar/v2":               {"github.com/foo/bar/internal/codec", "github.com/foo/bar/v2/internal/wire"},
+			"github.com/foo/bar/v2/internal/wire": {},
+		}),
+		"github.com/unused/dep": mkPackageTree("github.com/unused/dep", map[string][]string{
+			"github.com/unused/dep": {"github.com/foo/bar/internal/codec"},
+		}),
+	}
+
+	got := CrossProjectInternalImports(rpt, vendored)
+	want := []InternalImport{{
+		Chain:    []string{"root", "github.com/foo/bar/v2", "github.com/foo/bar/internal/codec"},
+		Importer: "github.com/foo/bar/v2",
+		Imported: "github.com/foo/bar",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected only the import across the nested major versions to be reported:\n\t(GOT) %v\n\t(WNT) %v", got, want)
+	}
+	if s := got[0].String(); s != "github.com/foo/bar/v2 imports an internal package of github.com/foo/bar: root -> github.com/foo/bar/v2 -> github.com/foo/bar/internal/codec" {
+		t.Errorf("unexpected description %q", s)
+	}
+}
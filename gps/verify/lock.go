@@ -15,4 +15,18 @@ type VerifiableProject struct {
 	gps.LockedProject
 	PruneOpts gps.PruneOptions
 	Digest    VersionedDigest
+	// SourceURL is the URL of the source from which the file tree was
+	// retrieved, if known.
+	SourceURL string
+	// Assets holds the patterns of the files the project's packages read at
+	// runtime, which are kept in the file tree whatever the PruneOpts.
+	Assets []string
+	// Generated holds the patterns of the project's generated files, which
+	// are left out of the Digest.
+	Generated []string
+}
+
+// AssetPatterns implements gps.AssetProject.
+func (vp VerifiableProject) AssetPatterns() []string {
+	return vp.Assets
 }
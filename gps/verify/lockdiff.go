@@ -58,6 +58,7 @@ type LockedProjectPropertiesDelta struct {
 	RevisionBefore, RevisionAfter   gps.Revision
 	SourceBefore, SourceAfter       string
 	PruneOptsBefore, PruneOptsAfter gps.PruneOptions
+	AssetsBefore, AssetsAfter       []string
 	HashChanged, HashVersionChanged bool
 }
 
@@ -200,6 +201,7 @@ func DiffLockedProjectProperties(lp1, lp2 gps.LockedProject) LockedProjectProper
 
 	if ok1 && ok2 {
 		ld.PruneOptsBefore, ld.PruneOptsAfter = vp1.PruneOpts, vp2.PruneOpts
+		ld.AssetsBefore, ld.AssetsAfter = vp1.Assets, vp2.Assets
 
 		if vp1.Digest.HashVersion != vp2.Digest.HashVersion {
 			ld.HashVersionChanged = true
@@ -209,10 +211,12 @@ func DiffLockedProjectProperties(lp1, lp2 gps.LockedProject) LockedProjectProper
 		}
 	} else if ok1 {
 		ld.PruneOptsBefore = vp1.PruneOpts
+		ld.AssetsBefore = vp1.Assets
 		ld.HashVersionChanged = true
 		ld.HashChanged = true
 	} else if ok2 {
 		ld.PruneOptsAfter = vp2.PruneOpts
+		ld.AssetsAfter = vp2.Assets
 		ld.HashVersionChanged = true
 		ld.HashChanged = true
 	}
@@ -399,10 +403,11 @@ func (ld LockedProjectPropertiesDelta) PackagesChanged() bool {
 	return len(ld.PackagesAdded) > 0 || len(ld.PackagesRemoved) > 0
 }
 
-// PruneOptsChanged returns true if the pruning flags for the project changed
-// between teh first and second locks.
+// PruneOptsChanged returns true if the pruning flags for the project, or the
+// patterns of the assets kept despite them, changed between teh first and
+// second locks.
 func (ld LockedProjectPropertiesDelta) PruneOptsChanged() bool {
-	return ld.PruneOptsBefore != ld.PruneOptsAfter
+	return ld.PruneOptsBefore != ld.PruneOptsAfter || !equalStrings(ld.AssetsBefore, ld.AssetsAfter)
 }
 
 // sortLockedProjects returns a sorted copy of lps, or itself if already sorted.
@@ -5,15 +5,50 @@
 package verify
 
 import (
+	"path"
+	"sort"
+	"strings"
+
 	radix "github.com/armon/go-radix"
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/paths"
 	"github.com/golang/dep/gps/pkgtree"
 )
 
+// Severity indicates how serious a way in which a Lock fails to satisfy its
+// inputs is. Greater values are more severe.
+type Severity uint8
+
+const (
+	// SeverityWarning is for conditions that indicate the inputs likely
+	// don't say what their author intended, but which do not require the Lock
+	// to be regenerated.
+	SeverityWarning Severity = iota + 1
+	// SeverityError is for conditions that mean the Lock must be regenerated
+	// in order to satisfy the inputs.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case 0:
+		return "none"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
 // LockSatisfaction holds the compound result of LockSatisfiesInputs, allowing
 // the caller to inspect each of several orthogonal possible types of failure.
 //
+// Each type of failure has a Severity. LockExisted, MissingImports,
+// ExcessImports, UnmetConstraints, UnmetOverrides, ChangedGoVersions and
+// ChangedBuildContext are errors; IgnoredProjects and OverriddenConstraints
+// are warnings.
+//
 // The zero value assumes that there was no input lock, which necessarily means
 // the inputs were not satisfied. This zero value means we err on the side of
 // failure.
@@ -27,12 +62,53 @@ type LockSatisfaction struct {
 	// ExcessImports is the set of import paths that were present in the Lock
 	// but absent from the inputs.
 	ExcessImports []string
+	// StaleInputImports is set if the input imports the Lock records no longer
+	// match the imports of the inputs, but every import missing from them is
+	// of a package the Lock already provides. The versions in the Lock may
+	// then be fine as they are, and only its record of its inputs out of date,
+	// as when a dependency's package that was only imported transitively comes
+	// to be imported directly. It is not set when there are no MissingImports
+	// or ExcessImports.
+	StaleInputImports bool
 	// UnmatchedConstraints reports any normal, non-override constraint rules that
 	// were not satisfied by the corresponding LockedProject in the Lock.
 	UnmetConstraints map[gps.ProjectRoot]ConstraintMismatch
 	// UnmatchedOverrides reports any override rules that were not satisfied by the
 	// corresponding LockedProject in the Lock.
 	UnmetOverrides map[gps.ProjectRoot]ConstraintMismatch
+	// IgnoredProjects is the set of projects in the Lock all of whose
+	// packages are now ignored by the inputs.
+	IgnoredProjects []gps.ProjectRoot
+	// OverriddenConstraints is the set of projects with a constraint rule
+	// that has no effect, because an override rule exists for the same
+	// project.
+	OverriddenConstraints []gps.ProjectRoot
+	// ChangedGoVersions is set if the Go versions declared by the inputs
+	// differ from those the Lock was solved with. Only InputGoVersionsLocks
+	// record them.
+	ChangedGoVersions *GoVersionsError
+	// ChangedBuildContext is set if the parts of the build context that the
+	// ignored packages of the inputs depend on differ from those the Lock was
+	// solved for. Only InputBuildContextLocks record them.
+	ChangedBuildContext *BuildContextError
+}
+
+// InputGoVersionsLock is an optional interface for Locks that record the Go
+// versions declared by the root manifest they were solved with, in the form
+// returned by gps.GoVersionInputs. They are as much inputs to solving as the
+// imports are, as they rule out versions of dependencies that need a newer Go.
+type InputGoVersionsLock interface {
+	gps.Lock
+	InputGoVersions() []string
+}
+
+// InputBuildContextLock is an optional interface for Locks that record the
+// parts of the build context that the ignored packages of the root manifest
+// they were solved with depended on, in the form returned by
+// gps.InputBuildContext.
+type InputBuildContextLock interface {
+	gps.Lock
+	InputBuildContext() string
 }
 
 // ConstraintMismatch is a two-tuple of a gps.Version, and a gps.Constraint that
@@ -122,13 +198,56 @@ func LockSatisfiesInputs(l gps.Lock, m gps.RootManifest, ptree pkgtree.PackageTr
 			lsat.ExcessImports = append(lsat.ExcessImports, ip)
 		}
 	}
+	sort.Strings(lsat.MissingImports)
+	sort.Strings(lsat.ExcessImports)
+	if len(pkgDiff) > 0 {
+		lsat.StaleInputImports = providesAll(l, lsat.MissingImports)
+	}
+
+	if gl, ok := l.(InputGoVersionsLock); ok {
+		var inputs []string
+		if m != nil {
+			inputs = gps.GoVersionInputs(m)
+		}
+		if !equalStrings(gl.InputGoVersions(), inputs) {
+			lsat.ChangedGoVersions = &GoVersionsError{Lock: gl.InputGoVersions(), Inputs: inputs}
+		}
+	}
+
+	if bl, ok := l.(InputBuildContextLock); ok {
+		var input string
+		if m != nil {
+			input = gps.InputBuildContext(m)
+		}
+		if bl.InputBuildContext() != input {
+			lsat.ChangedBuildContext = &BuildContextError{Lock: bl.InputBuildContext(), Inputs: input}
+		}
+	}
 
 	eff := findEffectualConstraints(m, ininputs)
 	ovr, constraints := m.Overrides(), m.DependencyConstraints()
 
+	for pr := range constraints {
+		if _, has := ovr[pr]; has {
+			lsat.OverriddenConstraints = append(lsat.OverriddenConstraints, pr)
+		}
+	}
+	sort.Slice(lsat.OverriddenConstraints, func(i, j int) bool {
+		return lsat.OverriddenConstraints[i] < lsat.OverriddenConstraints[j]
+	})
+
+	var pinned map[string]gps.Constraint
+	if rcm, ok := m.(gps.RequiredConstraintsManifest); ok {
+		pinned = rcm.RequiredConstraints()
+	}
+
 	for _, lp := range l.Projects() {
 		pr := lp.Ident().ProjectRoot
 
+		if allPackagesIgnored(lp, ig) {
+			lsat.IgnoredProjects = append(lsat.IgnoredProjects, pr)
+		}
+
 		if pp, has := ovr[pr]; has {
 			if !pp.Constraint.Matches(lp.Version()) {
 				lsat.UnmetOverrides[pr] = ConstraintMismatch{
@@ -146,6 +265,15 @@ func LockSatisfiesInputs(l gps.Lock, m gps.RootManifest, ptree pkgtree.PackageTr
 				C: pp.Constraint,
 				V: lp.Version(),
 			}
+			continue
+		}
+
+		// A required package pinned to a version constrains its project as
+		// a constraint rule would.
+		for pkg, c := range pinned {
+			if (pkg == string(pr) || strings.HasPrefix(pkg, string(pr)+"/")) && !c.Matches(lp.Version()) {
+				lsat.UnmetConstraints[pr] = ConstraintMismatch{C: c, V: lp.Version()}
+			}
 		}
 	}
 
@@ -154,28 +282,84 @@ func LockSatisfiesInputs(l gps.Lock, m gps.RootManifest, ptree pkgtree.PackageTr
 
 // Satisfied is a shortcut method that indicates whether there were any ways in
 // which the Lock did not satisfy the inputs. It will return true only if the
-// Lock was satisfactory in all respects vis-a-vis the inputs.
+// Lock was satisfactory in all respects vis-a-vis the inputs; warnings are not
+// considered.
+//
+// It is equivalent to Passed(SeverityError).
 func (ls LockSatisfaction) Satisfied() bool {
-	if !ls.LockExisted {
-		return false
+	return ls.Passed(SeverityError)
+}
+
+// Passed indicates whether the Lock satisfied the inputs, disregarding any
+// failures less severe than threshold. Passed(SeverityWarning) therefore
+// returns false if there were any failures at all.
+func (ls LockSatisfaction) Passed(threshold Severity) bool {
+	return ls.Severity() < threshold
+}
+
+// Severity returns the severity of the most severe way in which the Lock
+// failed to satisfy the inputs, or zero if there were none.
+func (ls LockSatisfaction) Severity() Severity {
+	if !ls.LockExisted || len(ls.MissingImports) > 0 || len(ls.ExcessImports) > 0 ||
+		len(ls.UnmetOverrides) > 0 || len(ls.UnmetConstraints) > 0 || ls.ChangedGoVersions != nil ||
+		ls.ChangedBuildContext != nil {
+		return SeverityError
 	}
 
-	if len(ls.MissingImports) > 0 {
-		return false
+	if len(ls.IgnoredProjects) > 0 || len(ls.OverriddenConstraints) > 0 {
+		return SeverityWarning
 	}
 
-	if len(ls.ExcessImports) > 0 {
-		return false
+	return 0
+}
+
+// providesAll reports whether each of the import paths in ips is of a package
+// of a project in l.
+func providesAll(l gps.Lock, ips []string) bool {
+	provided := make(map[string]bool)
+	for _, lp := range l.Projects() {
+		pr := string(lp.Ident().ProjectRoot)
+		for _, pkg := range lp.Packages() {
+			provided[path.Join(pr, pkg)] = true
+		}
+	}
+	for _, ip := range ips {
+		if !provided[ip] {
+			return false
+		}
 	}
+	return true
+}
 
-	if len(ls.UnmetOverrides) > 0 {
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
 		return false
 	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
 
-	if len(ls.UnmetConstraints) > 0 {
+// allPackagesIgnored reports whether every package the LockedProject
+// provides is ignored by ig.
+func allPackagesIgnored(lp gps.LockedProject, ig *pkgtree.IgnoredRuleset) bool {
+	if ig.Len() == 0 || len(lp.Packages()) == 0 {
 		return false
 	}
 
+	pr := string(lp.Ident().ProjectRoot)
+	for _, pkg := range lp.Packages() {
+		ip := pr
+		if pkg != "." {
+			ip = path.Join(pr, pkg)
+		}
+		if !ig.IsIgnored(ip) {
+			return false
+		}
+	}
 	return true
 }
 
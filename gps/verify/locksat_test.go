@@ -20,13 +20,19 @@ const (
 	excessImports
 	unmatchedOverrides
 	unmatchedConstraints
+	ignoredProjects
+	overriddenConstraints
+
+	// errorDimensions are the dimensions with SeverityError; the remainder
+	// are warnings.
+	errorDimensions = noLock | missingImports | excessImports | unmatchedOverrides | unmatchedConstraints
 )
 
 func (lsd lockUnsatisfactionDimension) String() string {
 	var parts []string
-	for i := uint(0); i < 5; i++ {
+	for i := uint(0); i < 7; i++ {
 		if lsd&(1<<i) != 0 {
-			switch lsd {
+			switch lockUnsatisfactionDimension(1 << i) {
 			case noLock:
 				parts = append(parts, "no lock")
 			case missingImports:
@@ -37,6 +43,10 @@ func (lsd lockUnsatisfactionDimension) String() string {
 				parts = append(parts, "unmatched overrides")
 			case unmatchedConstraints:
 				parts = append(parts, "unmatched constraints")
+			case ignoredProjects:
+				parts = append(parts, "ignored projects")
+			case overriddenConstraints:
+				parts = append(parts, "overridden constraints")
 			}
 		}
 	}
@@ -147,6 +157,28 @@ func TestLockSatisfaction(t *testing.T) {
 			rmt: dup.addIgnore("foo.com/bar"),
 			sat: excessImports,
 		},
+		"fully ignored project": {
+			rmt: dup.addIgnore("foo.com/bar*"),
+			sat: excessImports | ignoredProjects,
+			checkfn: func(t *testing.T, lsat LockSatisfaction) {
+				if len(lsat.IgnoredProjects) != 1 || lsat.IgnoredProjects[0] != "foo.com/bar" {
+					t.Errorf("expected 'foo.com/bar' as sole ignored project, got %s", lsat.IgnoredProjects)
+				}
+			},
+		},
+		"ignored transitive project": {
+			rmt: dup.addIgnore("transitive.com/dependency"),
+			sat: ignoredProjects,
+		},
+		"constraint shadowed by override": {
+			rmt: dup.setConstraint("baz.com/qux", fooversion.Unpair(), "").setOverride("baz.com/qux", bazversion.Unpair(), ""),
+			sat: overriddenConstraints,
+			checkfn: func(t *testing.T, lsat LockSatisfaction) {
+				if len(lsat.OverriddenConstraints) != 1 || lsat.OverriddenConstraints[0] != "baz.com/qux" {
+					t.Errorf("expected 'baz.com/qux' as sole overridden constraint, got %s", lsat.OverriddenConstraints)
+				}
+			},
+		},
 	}
 
 	for name, fix := range tt {
@@ -163,12 +195,18 @@ func TestLockSatisfaction(t *testing.T) {
 				t.Errorf("wanted sat in some dimensions that were unsatisfied: %s", gotsat & ^fix.sat)
 			}
 
-			if lsat.Satisfied() && fix.sat != 0 {
+			if lsat.Satisfied() && fix.sat&errorDimensions != 0 {
 				t.Errorf("Satisfied() incorrectly reporting true when expecting some dimensions to be unsatisfied: %s", fix.sat)
-			} else if !lsat.Satisfied() && fix.sat == 0 {
+			} else if !lsat.Satisfied() && fix.sat&errorDimensions == 0 {
 				t.Error("Satisfied() incorrectly reporting false when expecting all dimensions to be satisfied")
 			}
 
+			if lsat.Passed(SeverityWarning) && fix.sat != 0 {
+				t.Errorf("Passed(SeverityWarning) incorrectly reporting true when expecting some dimensions to be unsatisfied: %s", fix.sat)
+			} else if !lsat.Passed(SeverityWarning) && fix.sat == 0 {
+				t.Error("Passed(SeverityWarning) incorrectly reporting false when expecting all dimensions to be satisfied")
+			}
+
 			if fix.checkfn != nil {
 				fix.checkfn(t, lsat)
 			}
@@ -179,6 +217,9 @@ func TestLockSatisfaction(t *testing.T) {
 	if lsat.Satisfied() {
 		t.Error("zero value of LockSatisfaction should fail")
 	}
+	if lsat.Severity() != SeverityError {
+		t.Errorf("zero value of LockSatisfaction should have severity %s, got %s", SeverityError, lsat.Severity())
+	}
 	if LockSatisfiesInputs(nil, nil, ptree).Satisfied() {
 		t.Error("nil lock to LockSatisfiesInputs should produce failing result")
 	}
@@ -202,6 +243,12 @@ func (ls LockSatisfaction) unsatTypes() lockUnsatisfactionDimension {
 	if len(ls.UnmetConstraints) != 0 {
 		dims |= unmatchedConstraints
 	}
+	if len(ls.IgnoredProjects) != 0 {
+		dims |= ignoredProjects
+	}
+	if len(ls.OverriddenConstraints) != 0 {
+		dims |= overriddenConstraints
+	}
 
 	return dims
 }
@@ -257,3 +304,207 @@ func (rmt rootManifestTransformer) addIgnore(path string) rootManifestTransforme
 		return rm
 	})
 }
+
+type goVersionsLock struct {
+	safeLock
+	govers []string
+}
+
+func (gl goVersionsLock) InputGoVersions() []string {
+	return gl.govers
+}
+
+type goVersionsRootManifest struct {
+	simpleRootManifest
+	gover  string
+	govers map[gps.ProjectRoot]string
+}
+
+func (m goVersionsRootManifest) MinGoVersion() string {
+	return m.gover
+}
+
+func (m goVersionsRootManifest) ProjectGoVersions() map[gps.ProjectRoot]string {
+	return m.govers
+}
+
+func TestLockSatisfactionGoVersions(t *testing.T) {
+	l := goVersionsLock{
+		safeLock: safeLock{i: []string{"foo.com/bar"}},
+		govers:   []string{"1.10", "foo.com/bar@1.11"},
+	}
+	ptree := pkgtree.PackageTree{
+		ImportRoot: "current",
+		Packages: map[string]pkgtree.PackageOrErr{
+			"current": {
+				P: pkgtree.Package{
+					Name:       "current",
+					ImportPath: "current",
+					Imports:    []string{"foo.com/bar"},
+				},
+			},
+		},
+	}
+
+	tt := map[string]struct {
+		gover   string
+		govers  map[gps.ProjectRoot]string
+		changed bool
+	}{
+		"unchanged": {
+			gover:  "1.10",
+			govers: map[gps.ProjectRoot]string{"foo.com/bar": "1.11"},
+		},
+		"changed root version": {
+			gover:   "1.11",
+			govers:  map[gps.ProjectRoot]string{"foo.com/bar": "1.11"},
+			changed: true,
+		},
+		"removed project version": {
+			gover:   "1.10",
+			changed: true,
+		},
+	}
+
+	for name, fix := range tt {
+		fix := fix
+		t.Run(name, func(t *testing.T) {
+			m := goVersionsRootManifest{
+				simpleRootManifest: simpleRootManifest{ig: pkgtree.NewIgnoredRuleset(nil)},
+				gover:              fix.gover,
+				govers:             fix.govers,
+			}
+			lsat := LockSatisfiesInputs(l, m, ptree)
+			if got := lsat.ChangedGoVersions != nil; got != fix.changed {
+				t.Fatalf("wanted changed Go versions to be %v, got %v", fix.changed, got)
+			}
+			if lsat.Satisfied() == fix.changed {
+				t.Errorf("wanted Satisfied() to be %v", !fix.changed)
+			}
+		})
+	}
+}
+
+type buildContextLock struct {
+	safeLock
+	bc string
+}
+
+func (bl buildContextLock) InputBuildContext() string {
+	return bl.bc
+}
+
+type buildContextRootManifest struct {
+	simpleRootManifest
+	bc string
+}
+
+func (m buildContextRootManifest) InputBuildContext() string {
+	return m.bc
+}
+
+func TestLockSatisfactionBuildContext(t *testing.T) {
+	l := buildContextLock{
+		safeLock: safeLock{i: []string{"foo.com/bar"}},
+		bc:       "goos=linux",
+	}
+	ptree := pkgtree.PackageTree{
+		ImportRoot: "current",
+		Packages: map[string]pkgtree.PackageOrErr{
+			"current": {P: pkgtree.Package{Name: "current", ImportPath: "current", Imports: []string{"foo.com/bar"}}},
+		},
+	}
+
+	for bc, changed := range map[string]bool{"goos=linux": false, "goos=windows": true, "": true} {
+		m := buildContextRootManifest{
+			simpleRootManifest: simpleRootManifest{ig: pkgtree.NewIgnoredRuleset(nil)},
+			bc:                 bc,
+		}
+		lsat := LockSatisfiesInputs(l, m, ptree)
+		if got := lsat.ChangedBuildContext != nil; got != changed {
+			t.Errorf("%q: wanted changed build context to be %v, got %v", bc, changed, got)
+		}
+		if lsat.Satisfied() == changed {
+			t.Errorf("%q: wanted Satisfied() to be %v", bc, !changed)
+		}
+	}
+}
+
+type requiredConstraintsRootManifest struct {
+	simpleRootManifest
+	rc map[string]gps.Constraint
+}
+
+func (m requiredConstraintsRootManifest) RequiredConstraints() map[string]gps.Constraint {
+	return m.rc
+}
+
+func TestLockSatisfactionRequiredConstraints(t *testing.T) {
+	l := safeLock{
+		p: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "foo.com/bar"}, gps.NewVersion("v1.1.0").Pair("abc123"), []string{"cmd/gen"}),
+		},
+		i: []string{"foo.com/bar/cmd/gen"},
+	}
+	ptree := pkgtree.PackageTree{
+		ImportRoot: "current",
+		Packages: map[string]pkgtree.PackageOrErr{
+			"current": {P: pkgtree.Package{Name: "current", ImportPath: "current"}},
+		},
+	}
+
+	for pin, satisfied := range map[string]bool{"^1.0.0": true, "~1.2.0": false} {
+		c, err := gps.NewSemverConstraint(pin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := requiredConstraintsRootManifest{
+			simpleRootManifest: simpleRootManifest{
+				ig:  pkgtree.NewIgnoredRuleset(nil),
+				req: map[string]bool{"foo.com/bar/cmd/gen": true},
+			},
+			rc: map[string]gps.Constraint{"foo.com/bar/cmd/gen": c},
+		}
+		lsat := LockSatisfiesInputs(l, m, ptree)
+		if lsat.Satisfied() != satisfied {
+			t.Errorf("pinned to %s: wanted Satisfied() to be %v, got unmet constraints %v", pin, satisfied, lsat.UnmetConstraints)
+		}
+	}
+}
+
+func TestLockSatisfactionStaleInputImports(t *testing.T) {
+	l := safeLock{
+		i: []string{"foo.com/bar"},
+		p: []gps.LockedProject{
+			newVerifiableProject(mkPI("foo.com/bar"), gps.NewVersion("v1.0.0").Pair("foorev1"), []string{".", "subpkg"}),
+		},
+	}
+	mkptree := func(imports ...string) pkgtree.PackageTree {
+		return pkgtree.PackageTree{
+			ImportRoot: "current",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"current": {P: pkgtree.Package{Name: "current", ImportPath: "current", Imports: imports}},
+			},
+		}
+	}
+	rm := simpleRootManifest{}
+
+	for name, tc := range map[string]struct {
+		imports []string
+		stale   bool
+	}{
+		"unchanged":        {[]string{"foo.com/bar"}, false},
+		"locked package":   {[]string{"foo.com/bar", "foo.com/bar/subpkg"}, true},
+		"removed import":   {nil, true},
+		"unlocked package": {[]string{"foo.com/bar", "foo.com/bar/other"}, false},
+		"unlocked project": {[]string{"foo.com/bar", "baz.com/qux"}, false},
+	} {
+		lsat := LockSatisfiesInputs(l, rm, mkptree(tc.imports...))
+		if lsat.StaleInputImports != tc.stale {
+			t.Errorf("%s: wanted StaleInputImports to be %v, got %v (missing %v, excess %v)", name, tc.stale, lsat.StaleInputImports, lsat.MissingImports, lsat.ExcessImports)
+		}
+		if len(lsat.UnmetConstraints) != 0 || len(lsat.UnmetOverrides) != 0 {
+			t.Errorf("%s: expected no unmet constraints, got %v and %v", name, lsat.UnmetConstraints, lsat.UnmetOverrides)
+		}
+	}
+}
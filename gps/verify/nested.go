@@ -0,0 +1,69 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+)
+
+// NestedProjects returns the slash-separated paths, relative to the directory
+// of the project pr, of the projects in l nested directly within it, such as
+// github.com/foo/bar/v2 within github.com/foo/bar. Their code is not part of
+// the digest of pr, and is vendored and verified on its own.
+func NestedProjects(pr gps.ProjectRoot, l gps.Lock) []string {
+	if l == nil {
+		return nil
+	}
+	roots := make(map[string]bool)
+	for _, lp := range l.Projects() {
+		roots[string(lp.Ident().ProjectRoot)] = true
+	}
+	return nestedRoots(string(pr), roots)
+}
+
+// NestedRootsIn is like NestedProjects, for the projects with the given roots.
+func NestedRootsIn(pr gps.ProjectRoot, roots []gps.ProjectRoot) []string {
+	set := make(map[string]bool, len(roots))
+	for _, root := range roots {
+		set[string(root)] = true
+	}
+	return nestedRoots(string(pr), set)
+}
+
+// nestedIn is like NestedProjects, for the projects with digests in
+// wantDigests.
+func nestedIn(slashPathname string, wantDigests map[string]VersionedDigest) []string {
+	roots := make(map[string]bool, len(wantDigests))
+	for root := range wantDigests {
+		roots[root] = true
+	}
+	return nestedRoots(slashPathname, roots)
+}
+
+// nestedRoots returns the paths, relative to root, of the roots nested within it
+// and not within another of the nested roots.
+func nestedRoots(root string, roots map[string]bool) []string {
+	var nested []string
+	for other := range roots {
+		if !strings.HasPrefix(other, root+"/") {
+			continue
+		}
+		intermediate := false
+		for dir := other[:strings.LastIndex(other, "/")]; len(dir) > len(root); dir = dir[:strings.LastIndex(dir, "/")] {
+			if roots[dir] {
+				intermediate = true
+				break
+			}
+		}
+		if !intermediate {
+			nested = append(nested, other[len(root)+1:])
+		}
+	}
+	sort.Strings(nested)
+	return nested
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestNestedProjects(t *testing.T) {
+	l := gps.SimpleLock{}
+	for _, pr := range []gps.ProjectRoot{"github.com/foo/bar", "github.com/foo/bar/v2", "github.com/foo/bar/v2/v3", "github.com/foo/bar/v4", "github.com/foo/barbaz"} {
+		l = append(l, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.Revision("abc123"), []string{"."}))
+	}
+
+	if got, want := NestedProjects("github.com/foo/bar", l), []string{"v2", "v4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected nested projects %v, got %v", want, got)
+	}
+	roots := []gps.ProjectRoot{"github.com/foo/bar", "github.com/foo/bar/v2", "github.com/foo/bar/v2/sub/v3"}
+	if got, want := NestedRootsIn("github.com/foo/bar", roots), []string{"v2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected nested roots %v, got %v", want, got)
+	}
+	if got := NestedProjects("github.com/foo/barbaz", l); len(got) != 0 {
+		t.Errorf("expected no nested projects, got %v", got)
+	}
+}
+
+func TestCheckDepTreeNested(t *testing.T) {
+	vendorRoot, err := ioutil.TempDir("", "dep-nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(vendorRoot)
+
+	for path, contents := range map[string]string{
+		"github.com/foo/bar/bar.go":    "package bar\n",
+		"github.com/foo/bar/v2/bar.go": "package bar // v2\n",
+	} {
+		path = filepath.Join(vendorRoot, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v1, err := DigestFromDirectoryExcluding(filepath.Join(vendorRoot, "github.com", "foo", "bar"), DigestExclusions{Nested: []string{"v2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := DigestFromDirectory(filepath.Join(vendorRoot, "github.com", "foo", "bar", "v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDigests := map[string]VersionedDigest{
+		"github.com/foo/bar":    v1,
+		"github.com/foo/bar/v2": v2,
+	}
+
+	status, err := CheckDepTree(vendorRoot, wantDigests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]VendorStatus{
+		"github.com/foo/bar":    NoMismatch,
+		"github.com/foo/bar/v2": NoMismatch,
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("expected both major versions to verify, got %v", status)
+	}
+
+	// A change to the nested project is not a change to the one it is nested
+	// within.
+	if err := ioutil.WriteFile(filepath.Join(vendorRoot, "github.com", "foo", "bar", "v2", "bar.go"), []byte("package baz\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	status, err = CheckDepTree(vendorRoot, wantDigests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want["github.com/foo/bar/v2"] = DigestMismatchInLock
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("expected only the nested project to mismatch, got %v", status)
+	}
+
+	delete(wantDigests, "github.com/foo/bar/v2")
+	status, err = CheckDepTree(vendorRoot, wantDigests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["github.com/foo/bar"] != DigestMismatchInLock {
+		t.Errorf("expected an unlocked nested directory to be part of its parent, got %v", status)
+	}
+}
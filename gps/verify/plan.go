@@ -0,0 +1,123 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+)
+
+// ActionKind identifies a type of step in a remediation Plan.
+type ActionKind uint8
+
+const (
+	// ActionResolve indicates that the lock must be regenerated by solving.
+	ActionResolve ActionKind = iota + 1
+	// ActionRevendor indicates that a project's vendored code must be
+	// rewritten from the lock.
+	ActionRevendor
+	// ActionRemoveOrphan indicates that a directory in vendor that does not
+	// correspond to any project in the lock must be removed.
+	ActionRemoveOrphan
+)
+
+func (k ActionKind) String() string {
+	switch k {
+	case ActionResolve:
+		return "re-solve"
+	case ActionRevendor:
+		return "re-vendor"
+	case ActionRemoveOrphan:
+		return "remove orphan"
+	}
+	return "unknown"
+}
+
+// An Action is a single step in a remediation Plan.
+type Action struct {
+	Kind ActionKind
+	// ProjectRoot is the project the action applies to. It is empty for
+	// ActionResolve.
+	ProjectRoot gps.ProjectRoot
+	// Reason explains why the action is needed.
+	Reason string
+}
+
+func (a Action) String() string {
+	if a.ProjectRoot == "" {
+		return fmt.Sprintf("%s: %s", a.Kind, a.Reason)
+	}
+	return fmt.Sprintf("%s %s: %s", a.Kind, a.ProjectRoot, a.Reason)
+}
+
+// A Plan is an ordered list of the actions needed to bring a lock and vendor
+// directory back in sync with their inputs. An empty Plan means nothing needs
+// to be done.
+type Plan []Action
+
+// NeedsResolve reports whether the plan includes regenerating the lock.
+func (p Plan) NeedsResolve() bool {
+	for _, a := range p {
+		if a.Kind == ActionResolve {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeRemediationPlan computes the Plan needed to remedy the failures in lsat,
+// as returned from LockSatisfiesInputs, and status, as returned from
+// CheckDepTree.
+//
+// If the lock does not satisfy its inputs, the plan consists solely of an
+// ActionResolve: a new lock invalidates the vendor status, which was computed
+// against the old one, and rewriting vendor from the new lock subsumes any
+// per-project actions. Otherwise, the plan holds an ActionRevendor for each
+// project that is missing from vendor, or whose digest doesn't match, followed
+// by an ActionRemoveOrphan for each vendored directory not in the lock. Each
+// group is ordered by project root.
+//
+// Warnings in lsat never produce actions.
+func MakeRemediationPlan(lsat LockSatisfaction, status map[string]VendorStatus) Plan {
+	if err := lsat.Err(); err != nil {
+		var reasons []string
+		if errs, ok := err.(Errors); ok {
+			for _, err := range errs {
+				reasons = append(reasons, err.Error())
+			}
+		} else {
+			reasons = append(reasons, err.Error())
+		}
+		return Plan{{Kind: ActionResolve, Reason: strings.Join(reasons, "; ")}}
+	}
+
+	var revendor, orphans Plan
+	for spr, stat := range status {
+		pr := gps.ProjectRoot(spr)
+		switch stat {
+		case NotInTree:
+			revendor = append(revendor, Action{Kind: ActionRevendor, ProjectRoot: pr, Reason: "missing from vendor"})
+		case DigestMismatchInLock:
+			revendor = append(revendor, Action{Kind: ActionRevendor, ProjectRoot: pr, Reason: "vendored code does not match the digest in lock"})
+		case HashVersionMismatch:
+			revendor = append(revendor, Action{Kind: ActionRevendor, ProjectRoot: pr, Reason: "digest in lock was made with a different hash version"})
+		case EmptyDigestInLock:
+			revendor = append(revendor, Action{Kind: ActionRevendor, ProjectRoot: pr, Reason: "no digest in lock to verify vendored code against"})
+		case NotInLock:
+			orphans = append(orphans, Action{Kind: ActionRemoveOrphan, ProjectRoot: pr, Reason: "in vendor, but not in lock"})
+		}
+	}
+
+	byRoot := func(p Plan) func(i, j int) bool {
+		return func(i, j int) bool { return p[i].ProjectRoot < p[j].ProjectRoot }
+	}
+	sort.Slice(revendor, byRoot(revendor))
+	sort.Slice(orphans, byRoot(orphans))
+
+	return append(revendor, orphans...)
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMakeRemediationPlan(t *testing.T) {
+	status := map[string]VendorStatus{
+		"a.com/match":    NoMismatch,
+		"b.com/orphan":   NotInLock,
+		"c.com/mismatch": DigestMismatchInLock,
+		"d.com/missing":  NotInTree,
+	}
+
+	plan := MakeRemediationPlan(LockSatisfaction{LockExisted: true}, status)
+	got := make([]ActionKind, len(plan))
+	for i, a := range plan {
+		got[i] = a.Kind
+	}
+	want := []ActionKind{ActionRevendor, ActionRevendor, ActionRemoveOrphan}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected plan kinds:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+	if plan[0].ProjectRoot != "c.com/mismatch" || plan[1].ProjectRoot != "d.com/missing" || plan[2].ProjectRoot != "b.com/orphan" {
+		t.Errorf("unexpected plan order: %v", plan)
+	}
+	if plan.NeedsResolve() {
+		t.Error("plan for satisfied lock should not need a re-solve")
+	}
+
+	unsat := LockSatisfaction{LockExisted: true, MissingImports: []string{"foo.com/bar"}}
+	plan = MakeRemediationPlan(unsat, status)
+	if len(plan) != 1 || plan[0].Kind != ActionResolve {
+		t.Fatalf("expected a lone re-solve for an unsatisfied lock, got %v", plan)
+	}
+	if !plan.NeedsResolve() {
+		t.Error("plan for unsatisfied lock should need a re-solve")
+	}
+
+	clean := MakeRemediationPlan(LockSatisfaction{LockExisted: true}, map[string]VendorStatus{"a.com/match": NoMismatch})
+	if len(clean) != 0 {
+		t.Errorf("expected empty plan when in sync, got %v", clean)
+	}
+}
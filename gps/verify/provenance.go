@@ -0,0 +1,131 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// ProvenanceName is the name of the file at the root of each vendored project
+// that records where its code came from. It is left out of the project's
+// digest.
+const ProvenanceName = ".dep-provenance"
+
+// Provenance records the origin of a vendored project, so that it can be
+// audited from the vendor directory alone, without the lock it was written
+// from.
+type Provenance struct {
+	ProjectRoot gps.ProjectRoot `json:"project"`
+	// Source is the URL of the source the code was retrieved from.
+	Source string `json:"source"`
+	// Version is the version the code was locked to, if other than a bare
+	// revision.
+	Version  string       `json:"version,omitempty"`
+	Revision gps.Revision `json:"revision"`
+	// Digest is the digest of the vendored tree, as recorded in the lock.
+	Digest string `json:"digest"`
+	// Fetched is when the code was written to vendor.
+	Fetched time.Time `json:"fetched"`
+	// Nested holds the paths of the projects nested within this one, which
+	// are left out of its digest, as returned by NestedProjects.
+	Nested []string `json:"nested,omitempty"`
+	// Generated holds the patterns of the project's generated files, which
+	// are also left out of its digest.
+	Generated []string `json:"generated,omitempty"`
+}
+
+// NewProvenance returns the provenance of vp, vendored at the given time. vp
+// must hold the digest of its vendored tree, and the URL of its source.
+func NewProvenance(vp VerifiableProject, fetched time.Time) Provenance {
+	p := Provenance{
+		ProjectRoot: vp.Ident().ProjectRoot,
+		Source:      vp.SourceURL,
+		Digest:      vp.Digest.String(),
+		Fetched:     fetched.UTC(),
+		Generated:   vp.Generated,
+	}
+	switch v := vp.Version().(type) {
+	case gps.PairedVersion:
+		p.Version, p.Revision = v.Unpair().String(), v.Revision()
+	case gps.Revision:
+		p.Revision = v
+	}
+	return p
+}
+
+// WriteProvenance records p in dir, the directory of a vendored project.
+func WriteProvenance(dir string, p Provenance) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode provenance")
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, ProvenanceName), append(data, '\n'), 0666)
+	return errors.Wrapf(err, "failed to write the provenance of %s", p.ProjectRoot)
+}
+
+// ReadProvenance returns the provenance recorded in dir, the directory of a
+// vendored project, or nil if there is none, as for a project vendored by a
+// version of dep that did not record it.
+func ReadProvenance(dir string) (*Provenance, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ProvenanceName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read provenance")
+	}
+	p := new(Provenance)
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, errors.Wrapf(err, "invalid provenance in %s", dir)
+	}
+	return p, nil
+}
+
+// CheckProvenance returns the provenance recorded in dir, the directory of a
+// vendored project, after checking that the code in dir still has the digest
+// it records. An error is returned if it does not; the provenance is nil if
+// dir records none.
+func CheckProvenance(dir string) (*Provenance, error) {
+	p, err := ReadProvenance(dir)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	digest, err := DigestFromDirectoryExcluding(dir, DigestExclusions{Nested: p.Nested, Generated: p.Generated})
+	if err != nil {
+		return nil, err
+	}
+	if digest.String() != p.Digest {
+		return p, errors.Errorf("%s has digest %s, not %s as its provenance records", dir, digest, p.Digest)
+	}
+	return p, nil
+}
+
+// Mismatches returns a description of each way in which p is inconsistent
+// with lp, the locked project it should have been vendored from.
+func (p Provenance) Mismatches(lp VerifiableProject) []string {
+	want := NewProvenance(lp, p.Fetched)
+	var diffs []string
+	if p.ProjectRoot != want.ProjectRoot {
+		diffs = append(diffs, fmt.Sprintf("provenance is of %s", p.ProjectRoot))
+	}
+	if p.Revision != want.Revision {
+		diffs = append(diffs, fmt.Sprintf("provenance records revision %s, not %s", p.Revision, want.Revision))
+	}
+	if want.Source != "" && p.Source != want.Source {
+		diffs = append(diffs, fmt.Sprintf("provenance records source %s, not %s", p.Source, want.Source))
+	}
+	if !lp.Digest.IsEmpty() && p.Digest != want.Digest {
+		diffs = append(diffs, fmt.Sprintf("provenance records digest %s, not %s", p.Digest, want.Digest))
+	}
+	return diffs
+}
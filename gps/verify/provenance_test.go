@@ -0,0 +1,79 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestProvenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-provenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bar.go"), []byte("package bar\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := ReadProvenance(dir); err != nil || p != nil {
+		t.Fatalf("expected no provenance before one is written, got %v, %v", p, err)
+	}
+
+	digest, err := DigestFromDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp := VerifiableProject{
+		LockedProject: gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("abc123"), []string{"."}),
+		Digest:        digest,
+		SourceURL:     "https://github.com/foo/bar",
+	}
+	fetched := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := WriteProvenance(dir, NewProvenance(vp, fetched)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provenance file is not part of the digest it records.
+	p, err := CheckProvenance(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Provenance{
+		ProjectRoot: "github.com/foo/bar",
+		Source:      "https://github.com/foo/bar",
+		Version:     "v1.0.0",
+		Revision:    "abc123",
+		Digest:      digest.String(),
+		Fetched:     fetched,
+	}
+	if !reflect.DeepEqual(*p, want) {
+		t.Fatalf("expected provenance %+v, got %+v", want, *p)
+	}
+	if d := p.Mismatches(vp); len(d) != 0 {
+		t.Errorf("expected no mismatches with the project vendored, got %v", d)
+	}
+
+	moved := vp
+	moved.LockedProject = gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("def456"), []string{"."})
+	moved.SourceURL = "https://example.com/foo/bar"
+	if d := p.Mismatches(moved); len(d) != 2 {
+		t.Errorf("expected the revision and source to mismatch, got %v", d)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bar.go"), []byte("package baz\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckProvenance(dir); err == nil {
+		t.Error("expected altered code not to match its provenance")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// LockSnapshotName is the name of the file at the root of a vendor directory
+// that records the LockSnapshot of the lock it was written from.
+const LockSnapshotName = ".lock-snapshot"
+
+const lockSnapshotPrefix = "sha256:"
+
+// LockSnapshot returns a hash of those properties of the projects in l that
+// determine what is written to vendor: their names, sources, revisions, prune
+// options, assets and packages. Digests are left out, as they are derived from
+// what was written, as are input imports, which a lock can gain or lose
+// without any change to vendor.
+func LockSnapshot(l gps.Lock) string {
+	lps := append([]gps.LockedProject(nil), l.Projects()...)
+	sort.Slice(lps, func(i, j int) bool { return lps[i].Ident().Less(lps[j].Ident()) })
+
+	h := sha256.New()
+	for _, lp := range lps {
+		id := lp.Ident()
+		var rev gps.Revision
+		switch v := lp.Version().(type) {
+		case gps.PairedVersion:
+			rev = v.Revision()
+		case gps.Revision:
+			rev = v
+		}
+		fmt.Fprintf(h, "%s\n%s\n%s\n", id.ProjectRoot, id.Source, rev)
+
+		if vp, ok := lp.(VerifiableProject); ok {
+			fmt.Fprintf(h, "%s\n", vp.PruneOpts)
+			assets := append([]string(nil), vp.Assets...)
+			sort.Strings(assets)
+			fmt.Fprintf(h, "%s\n", strings.Join(assets, " "))
+		} else {
+			fmt.Fprint(h, "\n\n")
+		}
+
+		pkgs := append([]string(nil), lp.Packages()...)
+		sort.Strings(pkgs)
+		fmt.Fprintf(h, "%s\n\n", strings.Join(pkgs, " "))
+	}
+	return lockSnapshotPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// WriteLockSnapshot records the LockSnapshot of l in vendorDir, which must
+// already exist.
+func WriteLockSnapshot(vendorDir string, l gps.Lock) error {
+	path := filepath.Join(vendorDir, LockSnapshotName)
+	err := ioutil.WriteFile(path, []byte(LockSnapshot(l)+"\n"), 0666)
+	return errors.Wrap(err, "failed to write lock snapshot")
+}
+
+// ReadLockSnapshot returns the LockSnapshot recorded in vendorDir, or an empty
+// string if there is none, as for a vendor directory written by a version of
+// dep that did not record one.
+func ReadLockSnapshot(vendorDir string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(vendorDir, LockSnapshotName))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read lock snapshot")
+	}
+
+	snap := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(snap, lockSnapshotPrefix) {
+		return "", errors.Errorf("malformed lock snapshot %q in %s", snap, vendorDir)
+	}
+	return snap, nil
+}
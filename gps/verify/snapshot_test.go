@@ -0,0 +1,82 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestLockSnapshot(t *testing.T) {
+	lp := func(root, rev string, pkgs ...string) VerifiableProject {
+		return VerifiableProject{
+			LockedProject: gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, gps.NewVersion("v1.0.0").Pair(gps.Revision(rev)), pkgs),
+			PruneOpts:     gps.PruneNestedVendorDirs,
+		}
+	}
+	l := safeLock{p: []gps.LockedProject{lp("github.com/foo/bar", "abc123", ".", "baz"), lp("github.com/qux/quux", "def456", ".")}}
+	snap := LockSnapshot(l)
+
+	// Neither order, digests nor input imports matter.
+	digested := lp("github.com/foo/bar", "abc123", "baz", ".")
+	digested.Digest = VersionedDigest{HashVersion: HashVersion, Digest: []byte("digest")}
+	same := safeLock{p: []gps.LockedProject{lp("github.com/qux/quux", "def456", "."), digested}, i: []string{"github.com/foo/bar"}}
+	if got := LockSnapshot(same); got != snap {
+		t.Errorf("expected an equivalent lock to have the same snapshot, got %s and %s", got, snap)
+	}
+
+	for name, other := range map[string]gps.LockedProject{
+		"revision": lp("github.com/foo/bar", "0123ab", ".", "baz"),
+		"packages": lp("github.com/foo/bar", "abc123", "."),
+		"prune": VerifiableProject{
+			LockedProject: lp("github.com/foo/bar", "abc123", ".", "baz").LockedProject,
+			PruneOpts:     gps.PruneNestedVendorDirs | gps.PruneGoTestFiles,
+		},
+	} {
+		diff := safeLock{p: []gps.LockedProject{other, lp("github.com/qux/quux", "def456", ".")}}
+		if LockSnapshot(diff) == snap {
+			t.Errorf("%s: expected the snapshot to change", name)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "lock-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if got, err := ReadLockSnapshot(dir); err != nil || got != "" {
+		t.Errorf("expected no snapshot before one is written, got %q, %v", got, err)
+	}
+	if err := WriteLockSnapshot(dir, l); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadLockSnapshot(dir); err != nil || got != snap {
+		t.Errorf("expected to read back %s, got %q, %v", snap, got, err)
+	}
+
+	// The snapshot is not mistaken for an orphaned project.
+	if err := os.MkdirAll(filepath.Join(dir, "github.com", "foo", "bar"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	status, err := CheckDepTree(dir, map[string]VersionedDigest{"github.com/foo/bar": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := status[LockSnapshotName]; has || len(status) != 1 {
+		t.Errorf("expected only github.com/foo/bar to have a status, got %v", status)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, LockSnapshotName), []byte("garbage\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLockSnapshot(dir); err == nil {
+		t.Error("expected an error for a malformed snapshot")
+	}
+}
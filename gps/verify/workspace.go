@@ -0,0 +1,163 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/vfs"
+)
+
+// WorkspaceRoot is one of the root projects of a workspace, such as a
+// monorepo holding several projects with their own manifests and locks, along
+// with the inputs to verify its lock against.
+type WorkspaceRoot struct {
+	// Name identifies the root in the results, typically by its import path
+	// or directory.
+	Name        string
+	Lock        gps.Lock
+	Manifest    gps.RootManifest
+	PackageTree pkgtree.PackageTree
+	// VendorDir is the vendor tree to check against the digests in Lock,
+	// which may be shared with other roots. The vendor tree is not checked
+	// if it is empty.
+	VendorDir string
+}
+
+// RootVerification is the result of verifying a WorkspaceRoot.
+type RootVerification struct {
+	Name             string
+	LockSatisfaction LockSatisfaction
+	// VendorStatus is the result of CheckDepTreeFS for the root's vendor
+	// tree, or nil if it was not checked.
+	VendorStatus map[string]VendorStatus
+	// VendorErr is the error, if any, that kept the vendor tree from being
+	// checked.
+	VendorErr error
+}
+
+// Err returns nil if the root's lock satisfied its inputs and its vendor tree
+// matched the lock. Otherwise, it returns the error that kept the vendor tree
+// from being checked, or an Errors combining the failures from
+// LockSatisfaction.Err and VendorStatusErr.
+func (rv RootVerification) Err() error {
+	if rv.VendorErr != nil {
+		return rv.VendorErr
+	}
+
+	var errs Errors
+	for _, err := range []error{rv.LockSatisfaction.Err(), VendorStatusErr(rv.VendorStatus)} {
+		switch err := err.(type) {
+		case nil:
+		case Errors:
+			errs = append(errs, err...)
+		default:
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// VerifyWorkspace verifies each of roots, as LockSatisfiesInputs and
+// CheckDepTreeFS would, with up to workers roots verified concurrently; if
+// workers is not positive, runtime.NumCPU is used. The results are in the
+// order of roots.
+//
+// The digest of each vendored project directory is computed only once,
+// however many roots lock it, so that roots sharing a vendor tree do not each
+// hash all of it.
+func VerifyWorkspace(fsys vfs.FS, roots []WorkspaceRoot, workers int) []RootVerification {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	dc := newDigestCache(fsys)
+	results := make([]RootVerification, len(roots))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := range roots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = verifyRoot(fsys, roots[i], dc)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func verifyRoot(fsys vfs.FS, root WorkspaceRoot, dc *digestCache) RootVerification {
+	rv := RootVerification{
+		Name:             root.Name,
+		LockSatisfaction: LockSatisfiesInputs(root.Lock, root.Manifest, root.PackageTree),
+	}
+	if root.VendorDir == "" {
+		return rv
+	}
+
+	sums := make(map[string]VersionedDigest)
+	generated := make(map[string][]string)
+	if root.Lock != nil {
+		for _, lp := range root.Lock.Projects() {
+			if vp, ok := lp.(VerifiableProject); ok {
+				sums[string(lp.Ident().ProjectRoot)] = vp.Digest
+				generated[string(lp.Ident().ProjectRoot)] = vp.Generated
+			} else {
+				sums[string(lp.Ident().ProjectRoot)] = VersionedDigest{}
+			}
+		}
+	}
+	rv.VendorStatus, rv.VendorErr = checkDepTree(fsys, root.VendorDir, sums, generated, dc.digest)
+	return rv
+}
+
+// digestCache computes the digests of directories at most once each, however
+// many goroutines ask for them.
+type digestCache struct {
+	fsys    vfs.FS
+	mu      sync.Mutex
+	digests map[string]*cachedDigest
+}
+
+type cachedDigest struct {
+	once sync.Once
+	vd   VersionedDigest
+	err  error
+}
+
+func newDigestCache(fsys vfs.FS) *digestCache {
+	return &digestCache{
+		fsys:    fsys,
+		digests: make(map[string]*cachedDigest),
+	}
+}
+
+func (dc *digestCache) digest(osDirname string, ex DigestExclusions) (VersionedDigest, error) {
+	osDirname = filepath.Clean(osDirname)
+	key := osDirname + "\x00" + strings.Join(ex.Nested, "\x00") + "\x01" + strings.Join(ex.Generated, "\x00")
+	dc.mu.Lock()
+	cd, has := dc.digests[key]
+	if !has {
+		cd = &cachedDigest{}
+		dc.digests[key] = cd
+	}
+	dc.mu.Unlock()
+
+	cd.once.Do(func() {
+		cd.vd, cd.err = digestFromDirectoryFS(dc.fsys, osDirname, ex)
+	})
+	return cd.vd, cd.err
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/vfs"
+)
+
+func TestVerifyWorkspace(t *testing.T) {
+	mfs := vfs.NewMemFS()
+	vendor := filepath.FromSlash("/vendor")
+	if err := mfs.WriteFile(filepath.Join(vendor, "foo.com", "bar", "bar.go"), []byte("package bar\n")); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := DigestFromDirectoryFS(mfs, filepath.Join(vendor, "foo.com", "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ptree := func(root string) pkgtree.PackageTree {
+		return pkgtree.PackageTree{
+			ImportRoot: root,
+			Packages: map[string]pkgtree.PackageOrErr{
+				root: {
+					P: pkgtree.Package{
+						Name:       "main",
+						ImportPath: root,
+						Imports:    []string{"foo.com/bar"},
+					},
+				},
+			},
+		}
+	}
+	bar := newVerifiableProject(mkPI("foo.com/bar"), gps.NewVersion("v1.0.0").Pair("rev1"), []string{"."})
+	bar.Digest = sum
+	stale := bar
+	stale.Digest = VersionedDigest{HashVersion: HashVersion, Digest: []byte("stale")}
+	missing := newVerifiableProject(mkPI("foo.com/missing"), gps.NewVersion("v1.0.0").Pair("rev2"), []string{"."})
+
+	roots := []WorkspaceRoot{
+		{
+			Name:        "a",
+			Lock:        safeLock{i: []string{"foo.com/bar"}, p: []gps.LockedProject{bar}},
+			Manifest:    simpleRootManifest{},
+			PackageTree: ptree("a"),
+			VendorDir:   vendor,
+		},
+		{
+			Name:        "b",
+			Lock:        safeLock{i: []string{"foo.com/bar"}, p: []gps.LockedProject{stale, missing}},
+			Manifest:    simpleRootManifest{},
+			PackageTree: ptree("b"),
+			VendorDir:   vendor,
+		},
+		{
+			Name:        "c",
+			Lock:        safeLock{p: []gps.LockedProject{bar}},
+			Manifest:    simpleRootManifest{},
+			PackageTree: ptree("c"),
+		},
+	}
+
+	results := VerifyWorkspace(mfs, roots, 2)
+	if len(results) != len(roots) {
+		t.Fatalf("expected %d results, got %d", len(roots), len(results))
+	}
+	for i, rv := range results {
+		if rv.Name != roots[i].Name {
+			t.Errorf("expected result %d to be for %q, got %q", i, roots[i].Name, rv.Name)
+		}
+	}
+
+	if err := results[0].Err(); err != nil {
+		t.Errorf("expected root a to verify, got %s", err)
+	}
+
+	b := results[1]
+	if b.VendorStatus["foo.com/bar"] != DigestMismatchInLock || b.VendorStatus["foo.com/missing"] != NotInTree {
+		t.Errorf("unexpected vendor status for root b: %v", b.VendorStatus)
+	}
+	if err := b.Err(); !matches(err, ErrDigestMismatch) || !matches(err, ErrMissingFromVendor) {
+		t.Errorf("expected root b to fail with a digest mismatch and a missing project, got %v", err)
+	}
+
+	c := results[2]
+	if c.VendorStatus != nil {
+		t.Errorf("expected the vendor tree not to be checked for root c, got %v", c.VendorStatus)
+	}
+	if err := c.Err(); !matches(err, ErrMissingFromLock) {
+		t.Errorf("expected root c to fail with an import missing from its lock, got %v", err)
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLinkHops is the number of symbolic links MemFS will follow while
+// resolving a single name before giving up, mirroring the limit most
+// operating systems impose.
+const maxLinkHops = 255
+
+var (
+	errNotDir      = errors.New("not a directory")
+	errIsDir       = errors.New("is a directory")
+	errNotEmpty    = errors.New("directory not empty")
+	errNotLink     = errors.New("not a symbolic link")
+	errTooManyLink = errors.New("too many links")
+)
+
+// MemFS is an FS held entirely in memory. It is safe for concurrent use.
+//
+// Names are interpreted relative to the root of the MemFS, whether or not
+// they are absolute, so the MemFS can stand in for any directory on the host.
+//
+// In addition to the FS methods, MemFS has methods for populating it.
+type MemFS struct {
+	mu   sync.RWMutex
+	root *memNode
+}
+
+type memNode struct {
+	mode     os.FileMode
+	modTime  time.Time
+	data     []byte              // contents of a regular file
+	target   string              // destination of a symbolic link
+	children map[string]*memNode // entries of a directory
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{root: newMemDir()}
+}
+
+func newMemDir() *memNode {
+	return &memNode{
+		mode:     os.ModeDir | 0777,
+		modTime:  time.Now(),
+		children: make(map[string]*memNode),
+	}
+}
+
+// split converts name to the clean, slash-separated components of its path
+// from the root of the MemFS.
+func split(name string) []string {
+	p := path.Clean("/" + filepath.ToSlash(name))
+	if p == "/" {
+		return nil
+	}
+	return strings.Split(p[1:], "/")
+}
+
+// resolve returns the node for the path with the given components, following
+// any symbolic links along the way. The final component is followed only if
+// followLast is true.
+func (m *MemFS) resolve(elems []string, followLast bool, hops *int) (*memNode, error) {
+	dir, dirElems := m.root, []string(nil)
+	for i, elem := range elems {
+		if !dir.mode.IsDir() {
+			return nil, errNotDir
+		}
+		n, ok := dir.children[elem]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+
+		last := i == len(elems)-1
+		if n.mode&os.ModeSymlink != 0 && (!last || followLast) {
+			if *hops++; *hops > maxLinkHops {
+				return nil, errTooManyLink
+			}
+			target := filepath.ToSlash(n.target)
+			if !path.IsAbs(target) {
+				target = path.Join(append(append([]string{"/"}, dirElems...), target)...)
+			}
+			var err error
+			if n, err = m.resolve(split(target), true, hops); err != nil {
+				return nil, err
+			}
+			dirElems = split(target)
+		} else {
+			dirElems = append(dirElems, elem)
+		}
+		dir = n
+	}
+	return dir, nil
+}
+
+func (m *MemFS) lookup(op, name string, followLast bool) (*memNode, error) {
+	var hops int
+	n, err := m.resolve(split(name), followLast, &hops)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return n, nil
+}
+
+// parent returns the directory that does, or would, contain name, along with
+// the final component of name.
+func (m *MemFS) parent(op, name string) (*memNode, string, error) {
+	elems := split(name)
+	if len(elems) == 0 {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: os.ErrInvalid}
+	}
+
+	var hops int
+	dir, err := m.resolve(elems[:len(elems)-1], true, &hops)
+	if err == nil && !dir.mode.IsDir() {
+		err = errNotDir
+	}
+	if err != nil {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return dir, elems[len(elems)-1], nil
+}
+
+// Open implements FS.
+func (m *MemFS) Open(name string) (File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if n.mode.IsDir() {
+		return &memFile{name: name, isDir: true}, nil
+	}
+	return &memFile{name: name, r: bytes.NewReader(n.data)}, nil
+}
+
+// Stat implements FS.
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(path.Base("/" + filepath.ToSlash(name))), nil
+}
+
+// Lstat implements FS.
+func (m *MemFS) Lstat(name string) (os.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(path.Base("/" + filepath.ToSlash(name))), nil
+}
+
+// Readlink implements FS.
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: errNotLink}
+	}
+	return n.target, nil
+}
+
+// ReadDir implements FS.
+func (m *MemFS) ReadDir(name string) ([]os.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	infos := make([]os.FileInfo, 0, len(n.children))
+	for base, child := range n.children {
+		infos = append(infos, child.info(base))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// Remove implements FS.
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, err := m.parent("remove", name)
+	if err != nil {
+		return err
+	}
+	n, ok := dir.children[base]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if n.mode.IsDir() && len(n.children) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(dir.children, base)
+	return nil
+}
+
+// RemoveAll implements FS.
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, err := m.parent("removeall", name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	delete(dir.children, base)
+	return nil
+}
+
+// MkdirAll creates the named directory, along with any necessary parents. It
+// does nothing if the directory already exists.
+func (m *MemFS) MkdirAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.mkdirAll("mkdir", split(name))
+	return err
+}
+
+func (m *MemFS) mkdirAll(op string, elems []string) (*memNode, error) {
+	dir := m.root
+	for i, elem := range elems {
+		n, ok := dir.children[elem]
+		var err error
+		switch {
+		case !ok:
+			n = newMemDir()
+			dir.children[elem] = n
+		case n.mode&os.ModeSymlink != 0:
+			var hops int
+			n, err = m.resolve(elems[:i+1], true, &hops)
+		}
+		if err == nil && !n.mode.IsDir() {
+			err = errNotDir
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: op, Path: path.Join(elems[:i+1]...), Err: err}
+		}
+		dir = n
+	}
+	return dir, nil
+}
+
+// WriteFile creates the named regular file with the given contents, creating
+// any missing parent directories. An existing file is replaced.
+func (m *MemFS) WriteFile(name string, data []byte) error {
+	return m.create("writefile", name, &memNode{
+		mode:    0666,
+		modTime: time.Now(),
+		data:    append([]byte(nil), data...),
+	})
+}
+
+// Symlink creates newname as a symbolic link to oldname, creating any missing
+// parent directories. As with os.Symlink, oldname need not exist, and a
+// relative oldname is interpreted relative to the directory containing
+// newname.
+func (m *MemFS) Symlink(oldname, newname string) error {
+	return m.create("symlink", newname, &memNode{
+		mode:    os.ModeSymlink | 0777,
+		modTime: time.Now(),
+		target:  oldname,
+	})
+}
+
+func (m *MemFS) create(op, name string, n *memNode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elems := split(name)
+	if len(elems) == 0 {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrInvalid}
+	}
+	dir, err := m.mkdirAll(op, elems[:len(elems)-1])
+	if err != nil {
+		return err
+	}
+	base := elems[len(elems)-1]
+	if old, ok := dir.children[base]; ok && old.mode.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: errIsDir}
+	}
+	dir.children[base] = n
+	return nil
+}
+
+func (n *memNode) info(name string) os.FileInfo {
+	return memFileInfo{
+		name:    name,
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+	}
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi memFileInfo) Sys() interface{}   { return nil }
+
+type memFile struct {
+	name  string
+	isDir bool
+	r     *bytes.Reader
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.isDir {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: errIsDir}
+	}
+	return f.r.Read(p)
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemFS(t *testing.T) {
+	mfs := NewMemFS()
+	p := filepath.FromSlash
+
+	if err := mfs.WriteFile(p("/a/b/file.txt"), []byte("contents")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.WriteFile(p("/a/another.txt"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Symlink("b", p("/a/link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Symlink(p("/nowhere"), p("/a/broken")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Symlink("loop", p("/loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := mfs.Open(p("/a/link/file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "contents" {
+		t.Errorf("unexpected contents read through symlink: %q", data)
+	}
+
+	fi, err := mfs.Stat(p("/a/link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Error("expected Stat to follow symlink to directory")
+	}
+	fi, err = mfs.Lstat(p("/a/link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Error("expected Lstat to describe the symlink itself")
+	}
+	if target, err := mfs.Readlink(p("/a/link")); err != nil || target != "b" {
+		t.Errorf("unexpected Readlink result: %q, %v", target, err)
+	}
+
+	if _, err = mfs.Stat(p("/a/broken")); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error for broken symlink, got %v", err)
+	}
+	if _, err = mfs.Stat(p("/loop")); err == nil {
+		t.Error("expected an error for circular symlink")
+	}
+
+	fis, err := mfs.ReadDir(p("/a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	if want := []string{"another.txt", "b", "broken", "link"}; !equalStrings(names, want) {
+		t.Errorf("unexpected ReadDir result:\n\t(GOT): %v\n\t(WNT): %v", names, want)
+	}
+
+	if err = mfs.Remove(p("/a/b")); err == nil {
+		t.Error("expected an error removing a non-empty directory")
+	}
+	if err = mfs.Remove(p("/a/link")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mfs.Stat(p("/a/b/file.txt")); err != nil {
+		t.Errorf("removing a symlink should not remove its referent: %v", err)
+	}
+	if err = mfs.RemoveAll(p("/a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mfs.Lstat(p("/a")); !os.IsNotExist(err) {
+		t.Errorf("expected /a to be removed, got %v", err)
+	}
+	if err = mfs.RemoveAll(p("/a/b")); err != nil {
+		t.Errorf("expected RemoveAll of a missing path to succeed, got %v", err)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
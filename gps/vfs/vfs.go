@@ -0,0 +1,61 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vfs defines the filesystem interface through which gps prunes
+// exported projects, and gps/verify walks and digests dependency trees, so
+// that library consumers can run pruning and verification against in-memory
+// or other filesystems. OS is backed by the host filesystem, and NewMemFS
+// returns a filesystem held entirely in memory.
+//
+// Writing vendor trees, as gps.WriteDepTree does, still goes through the host
+// filesystem: projects are exported from their sources onto disk by the
+// version control tools, and are pruned and moved into place there.
+package vfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// File is an open file, as returned from FS.Open.
+type File interface {
+	io.Reader
+	io.Closer
+}
+
+// FS is a filesystem. Names passed to its methods use the host's path
+// separator, as with the functions of the same names in package os.
+type FS interface {
+	// Open opens the named file for reading, following symbolic links.
+	Open(name string) (File, error)
+	// Stat returns the FileInfo for the named file, following symbolic
+	// links.
+	Stat(name string) (os.FileInfo, error)
+	// Lstat returns the FileInfo for the named file. If the file is a
+	// symbolic link, the FileInfo describes the link itself.
+	Lstat(name string) (os.FileInfo, error)
+	// Readlink returns the destination of the named symbolic link.
+	Readlink(name string) (string, error)
+	// ReadDir returns the entries of the named directory, sorted by name.
+	ReadDir(name string) ([]os.FileInfo, error)
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+	// RemoveAll removes the named file or directory and everything it
+	// contains. It returns nil if name does not exist.
+	RemoveAll(name string) error
+}
+
+// OS is an FS backed by the host filesystem.
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (File, error)             { return os.Open(name) }
+func (osFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+func (osFS) Lstat(name string) (os.FileInfo, error)     { return os.Lstat(name) }
+func (osFS) Readlink(name string) (string, error)       { return os.Readlink(name) }
+func (osFS) ReadDir(name string) ([]os.FileInfo, error) { return ioutil.ReadDir(name) }
+func (osFS) Remove(name string) error                   { return os.Remove(name) }
+func (osFS) RemoveAll(name string) error                { return os.RemoveAll(name) }
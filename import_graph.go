@@ -0,0 +1,251 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// ProjectReach describes which packages of a locked project are reachable
+// from the root project, and through which of the root project's packages.
+type ProjectReach struct {
+	ProjectRoot gps.ProjectRoot
+	// Direct is whether the root project imports or requires the project
+	// itself, as classified by Lock.DirectDependencies.
+	Direct bool
+	// Depth is the length, in projects, of the shortest chain of imports
+	// from the root project to any package of the project: 1 for a project
+	// the root project imports, 2 for one imported only by those, and so on.
+	Depth int
+	// FanIn is the number of distinct projects, counting the root project,
+	// whose reachable packages import any package of the project.
+	FanIn int
+	// Via holds the import paths of the root packages from which any of the
+	// project's packages are reachable, sorted. Packages reachable only
+	// because they are required by the manifest are reached via ManifestName.
+	Via []string
+	// Packages maps the import path of each reachable package of the project
+	// to the root packages it is reachable from, in the same form as Via.
+	Packages map[string][]string
+}
+
+// ImportGraph returns the reach of the root project into each of its locked
+// projects, as given by ReachImportGraph, listing the packages of the locked
+// projects at their locked versions through sm. The result is in the order of
+// p.Lock.
+func (p *Project) ImportGraph(sm gps.SourceManager) ([]ProjectReach, error) {
+	if p.Lock == nil {
+		return nil, errors.Errorf("the import graph is built from %s, which does not exist", LockName)
+	}
+
+	ig := p.Manifest.IgnoredPackages()
+	root, _ := p.RootPackageTree.ToReachMap(true, true, false, ig)
+
+	lps := p.Lock.Projects()
+	rms := make([]pkgtree.ReachMap, len(lps))
+	errs := make([]error, len(lps))
+	var wg sync.WaitGroup
+	for i, lp := range lps {
+		wg.Add(1)
+		go func(i int, lp gps.LockedProject) {
+			defer wg.Done()
+			ptree, err := sm.ListPackages(lp.Ident(), lp.Version())
+			if err != nil {
+				errs[i] = errors.Wrapf(err, "failed to list the packages of %s", lp.Ident().ProjectRoot)
+				return
+			}
+			rms[i], _ = ptree.ToReachMap(true, false, false, nil)
+		}(i, lp)
+	}
+	wg.Wait()
+
+	deps := make(map[gps.ProjectRoot]pkgtree.ReachMap, len(lps))
+	for i, lp := range lps {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		deps[lp.Ident().ProjectRoot] = rms[i]
+	}
+
+	var required []string
+	for ip := range p.Manifest.RequiredPackages() {
+		required = append(required, ip)
+	}
+
+	byRoot := make(map[gps.ProjectRoot]ProjectReach)
+	for _, pr := range ReachImportGraph(root, required, deps) {
+		byRoot[pr.ProjectRoot] = pr
+	}
+	direct := p.Lock.DirectDependencies()
+	graph := make([]ProjectReach, len(lps))
+	for i, lp := range lps {
+		pr, has := byRoot[lp.Ident().ProjectRoot]
+		if !has {
+			pr = ProjectReach{ProjectRoot: lp.Ident().ProjectRoot, Packages: map[string][]string{}}
+		}
+		pr.Direct = direct[pr.ProjectRoot]
+		graph[i] = pr
+	}
+	return graph, nil
+}
+
+// ReachImportGraph follows the external imports of each package in root, the
+// ReachMap of the root project, through deps, the ReachMaps of the projects it
+// depends on, keyed by project root. Required import paths are followed as if
+// they were imported by a root package named ManifestName. The reach into each
+// project that any package is reachable in is returned, ordered by project
+// root, along with its Depth and FanIn; imports that are in none of the
+// projects in deps are disregarded.
+func ReachImportGraph(root pkgtree.ReachMap, required []string, deps map[gps.ProjectRoot]pkgtree.ReachMap) []ProjectReach {
+	// Order the roots from the longest, so that the first root found to
+	// contain a package is the one that it belongs to.
+	sorted := make([]gps.ProjectRoot, 0, len(deps))
+	for pr := range deps {
+		sorted = append(sorted, pr)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	rootOf := func(ip string) (gps.ProjectRoot, bool) {
+		for _, pr := range sorted {
+			if ip == string(pr) || strings.HasPrefix(ip, string(pr)+"/") {
+				return pr, true
+			}
+		}
+		return "", false
+	}
+
+	// An import to be followed, and the project importing it; the root
+	// project is the empty root.
+	type imported struct {
+		ip string
+		by gps.ProjectRoot
+	}
+
+	via := make(map[string]map[string]bool)
+	importers := make(map[gps.ProjectRoot]map[gps.ProjectRoot]bool)
+	visit := func(from string, imports []string) {
+		seen := make(map[string]bool)
+		queue := make([]imported, 0, len(imports))
+		for _, ip := range imports {
+			queue = append(queue, imported{ip: ip})
+		}
+		for len(queue) > 0 {
+			ip, by := queue[0].ip, queue[0].by
+			queue = queue[1:]
+			if paths.IsStandardImportPath(ip) {
+				continue
+			}
+			pr, ok := rootOf(ip)
+			if !ok {
+				continue
+			}
+			if pr != by {
+				if importers[pr] == nil {
+					importers[pr] = make(map[gps.ProjectRoot]bool)
+				}
+				importers[pr][by] = true
+			}
+			if seen[ip] {
+				continue
+			}
+			seen[ip] = true
+
+			reached := []string{ip}
+			if ie, has := deps[pr][ip]; has {
+				// The packages of the same project that ip imports, directly
+				// or not, are reachable too; their external imports are
+				// already among those of ip.
+				for _, in := range ie.Internal {
+					seen[in] = true
+					reached = append(reached, in)
+				}
+				for _, ext := range ie.External {
+					queue = append(queue, imported{ip: ext, by: pr})
+				}
+			}
+			for _, r := range reached {
+				if via[r] == nil {
+					via[r] = make(map[string]bool)
+				}
+				via[r][from] = true
+			}
+		}
+	}
+
+	for pkg, ie := range root {
+		visit(pkg, ie.External)
+	}
+	if len(required) > 0 {
+		visit(ManifestName, required)
+	}
+
+	byRoot := make(map[gps.ProjectRoot]*ProjectReach)
+	projectVia := make(map[gps.ProjectRoot]map[string]bool)
+	for ip, from := range via {
+		pr, _ := rootOf(ip)
+		reach, has := byRoot[pr]
+		if !has {
+			reach = &ProjectReach{ProjectRoot: pr, Packages: make(map[string][]string)}
+			byRoot[pr] = reach
+			projectVia[pr] = make(map[string]bool)
+		}
+		reach.Packages[ip] = sortedKeys(from)
+		for f := range from {
+			projectVia[pr][f] = true
+		}
+	}
+
+	depths := importDepths(importers)
+	graph := make([]ProjectReach, 0, len(byRoot))
+	for pr, reach := range byRoot {
+		reach.Via = sortedKeys(projectVia[pr])
+		reach.Depth = depths[pr]
+		reach.FanIn = len(importers[pr])
+		graph = append(graph, *reach)
+	}
+	sort.Slice(graph, func(i, j int) bool { return graph[i].ProjectRoot < graph[j].ProjectRoot })
+	return graph
+}
+
+// importDepths returns the Depth of each project in importers, which holds the
+// projects importing each project, with the root project as the empty root.
+func importDepths(importers map[gps.ProjectRoot]map[gps.ProjectRoot]bool) map[gps.ProjectRoot]int {
+	imports := make(map[gps.ProjectRoot][]gps.ProjectRoot)
+	for pr, by := range importers {
+		for b := range by {
+			imports[b] = append(imports[b], pr)
+		}
+	}
+
+	depths := make(map[gps.ProjectRoot]int)
+	queue := []gps.ProjectRoot{""}
+	for len(queue) > 0 {
+		pr := queue[0]
+		queue = queue[1:]
+		for _, next := range imports[pr] {
+			if _, has := depths[next]; has || next == "" {
+				continue
+			}
+			depths[next] = depths[pr] + 1
+			queue = append(queue, next)
+		}
+	}
+	return depths
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+type reach = struct{ Internal, External []string }
+
+func TestReachImportGraph(t *testing.T) {
+	root := pkgtree.ReachMap{
+		"github.com/me/proj":     reach{External: []string{"fmt", "github.com/foo/bar"}},
+		"github.com/me/proj/cmd": reach{External: []string{"github.com/foo/bar/sub", "github.com/unlocked/pkg"}},
+	}
+	deps := map[gps.ProjectRoot]pkgtree.ReachMap{
+		"github.com/foo/bar": {
+			"github.com/foo/bar":          reach{Internal: []string{"github.com/foo/bar/internal"}, External: []string{"github.com/baz/qux"}},
+			"github.com/foo/bar/internal": reach{External: []string{"github.com/baz/qux"}},
+			"github.com/foo/bar/sub":      reach{},
+			"github.com/foo/bar/unused":   reach{},
+		},
+		"github.com/baz/qux": {
+			"github.com/baz/qux": reach{},
+		},
+		"github.com/baz/qux/v2": {
+			"github.com/baz/qux/v2": reach{},
+		},
+		"github.com/req/tool": {
+			"github.com/req/tool/cmd/gen": reach{},
+		},
+		"github.com/not/reached": {
+			"github.com/not/reached": reach{},
+		},
+	}
+
+	got := ReachImportGraph(root, []string{"github.com/req/tool/cmd/gen"}, deps)
+	want := []ProjectReach{
+		{
+			ProjectRoot: "github.com/baz/qux",
+			Via:         []string{"github.com/me/proj"},
+			Depth:       2,
+			FanIn:       1,
+			Packages: map[string][]string{
+				"github.com/baz/qux": {"github.com/me/proj"},
+			},
+		},
+		{
+			ProjectRoot: "github.com/foo/bar",
+			Via:         []string{"github.com/me/proj", "github.com/me/proj/cmd"},
+			Depth:       1,
+			FanIn:       1,
+			Packages: map[string][]string{
+				"github.com/foo/bar":          {"github.com/me/proj"},
+				"github.com/foo/bar/internal": {"github.com/me/proj"},
+				"github.com/foo/bar/sub":      {"github.com/me/proj/cmd"},
+			},
+		},
+		{
+			ProjectRoot: "github.com/req/tool",
+			Via:         []string{ManifestName},
+			Depth:       1,
+			FanIn:       1,
+			Packages: map[string][]string{
+				"github.com/req/tool/cmd/gen": {ManifestName},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected import graph:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
+
+func TestReachImportGraphMetrics(t *testing.T) {
+	root := pkgtree.ReachMap{
+		"github.com/me/proj": reach{External: []string{"github.com/a/a", "github.com/c/c"}},
+	}
+	deps := map[gps.ProjectRoot]pkgtree.ReachMap{
+		"github.com/a/a": {"github.com/a/a": reach{External: []string{"github.com/b/b"}}},
+		"github.com/b/b": {"github.com/b/b": reach{External: []string{"github.com/d/d"}}},
+		"github.com/c/c": {"github.com/c/c": reach{External: []string{"github.com/b/b", "github.com/d/d"}}},
+		"github.com/d/d": {"github.com/d/d": reach{}},
+	}
+
+	want := map[gps.ProjectRoot][2]int{
+		"github.com/a/a": {1, 1},
+		"github.com/b/b": {2, 2},
+		"github.com/c/c": {1, 1},
+		"github.com/d/d": {2, 2},
+	}
+	for _, pr := range ReachImportGraph(root, nil, deps) {
+		if got := [2]int{pr.Depth, pr.FanIn}; got != want[pr.ProjectRoot] {
+			t.Errorf("%s: expected depth and fan-in %v, got %v", pr.ProjectRoot, want[pr.ProjectRoot], got)
+		}
+	}
+}
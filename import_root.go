@@ -0,0 +1,150 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/golang/dep/gps"
+)
+
+// The ways in which the import root of a project can be determined, in order
+// of precedence, as reported in ImportRootResolution.Via.
+const (
+	ImportRootExplicit = "DEPPROJECTROOT"
+	ImportRootManifest = "import-root"
+	ImportRootGOPATH   = "GOPATH"
+	ImportRootModule   = "go.mod"
+)
+
+// ImportRootResolution describes how the import root of a project was
+// determined.
+type ImportRootResolution struct {
+	// ImportRoot is the import path of the project's root.
+	ImportRoot gps.ProjectRoot
+	// Via is how ImportRoot was determined; one of the ImportRoot constants.
+	Via string
+	// GOPATH is the GOPATH containing the project, or, if it is in none, the
+	// first known GOPATH.
+	GOPATH string
+	// Diagnostics lists, in order, the ways of determining the import root
+	// that were tried before Via, and why each did not apply.
+	Diagnostics []string
+}
+
+// ImportRootError is returned when the import root of a project cannot be
+// determined by any means.
+type ImportRootError struct {
+	// Dir is the root directory of the project.
+	Dir string
+	// Diagnostics lists why each way of determining the import root did not
+	// apply, as in ImportRootResolution.
+	Diagnostics []string
+}
+
+func (e *ImportRootError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "could not determine the import path of the project at %s:", e.Dir)
+	for _, d := range e.Diagnostics {
+		fmt.Fprintf(&buf, "\n  * %s", d)
+	}
+	fmt.Fprintf(&buf, "\nmove the project within a GOPATH, or declare its import path with import-root in %s or a module line in %s", ManifestName, modFileName)
+	return buf.String()
+}
+
+// ResolveImportRoot determines the import path of the project rooted at
+// p.AbsRoot, and the GOPATH containing it, as described for InferImportRoot,
+// reporting how it was done. If it cannot be done, the error is an
+// *ImportRootError listing why each way failed, and only the GOPATH of the
+// returned resolution is set.
+//
+// When the project is within several of c.GOPATHs, as when one GOPATH is
+// nested in another, the innermost is used. A GOPATH that is a symlink also
+// contains the projects below its target, so that dep works the same from a
+// working directory reached through either path.
+func (c *Ctx) ResolveImportRoot(p *Project) (*ImportRootResolution, error) {
+	r := &ImportRootResolution{}
+
+	var gperr error
+	r.GOPATH, gperr = c.DetectProjectGOPATH(p)
+	if gperr != nil {
+		r.GOPATH = c.firstGOPATH()
+	}
+
+	if c.ExplicitRoot != "" {
+		r.ImportRoot, r.Via = gps.ProjectRoot(c.ExplicitRoot), ImportRootExplicit
+		return r, nil
+	}
+	r.Diagnostics = append(r.Diagnostics, "$DEPPROJECTROOT is not set")
+
+	if p.Manifest != nil && p.Manifest.ImportRoot != "" {
+		r.ImportRoot, r.Via = p.Manifest.ImportRoot, ImportRootManifest
+		return r, nil
+	}
+	r.Diagnostics = append(r.Diagnostics, fmt.Sprintf("%s has no import-root", ManifestName))
+
+	if gperr == nil {
+		// The GOPATH containing the project is used for the import path even
+		// if it fails, as a project there is expected to be importable from it.
+		ip, err := c.importForAbsIn(p.AbsRoot, r.GOPATH)
+		if err != nil && p.ResolvedAbsRoot != p.AbsRoot {
+			ip, err = c.importForAbsIn(p.ResolvedAbsRoot, r.GOPATH)
+		}
+		if err != nil {
+			return r, &ImportRootError{Dir: p.AbsRoot, Diagnostics: append(r.Diagnostics, err.Error())}
+		}
+		r.ImportRoot, r.Via = gps.ProjectRoot(ip), ImportRootGOPATH
+		return r, nil
+	}
+	r.Diagnostics = append(r.Diagnostics, c.gopathDiagnostics(gperr)...)
+
+	mf := filepath.Join(p.AbsRoot, modFileName)
+	modpath, err := readModulePath(mf)
+	if err != nil {
+		return r, err
+	}
+	if modpath == "" {
+		return r, &ImportRootError{
+			Dir:         p.AbsRoot,
+			Diagnostics: append(r.Diagnostics, fmt.Sprintf("%s does not exist or has no module line", mf)),
+		}
+	}
+	r.ImportRoot, r.Via = gps.ProjectRoot(modpath), ImportRootModule
+	return r, nil
+}
+
+// gopathDiagnostics explains why the project is not within any of c.GOPATHs,
+// given the error from DetectProjectGOPATH.
+func (c *Ctx) gopathDiagnostics(gperr error) []string {
+	if len(c.GOPATHs) == 0 {
+		return []string{"no GOPATH is set"}
+	}
+
+	diags := []string{gperr.Error()}
+	for _, gp := range c.GOPATHs {
+		forms := gopathForms(gp)
+		switch {
+		case !filepath.IsAbs(gp):
+			diags = append(diags, fmt.Sprintf("GOPATH entry %s is not an absolute path, so is ignored", gp))
+		case len(forms) > 1:
+			diags = append(diags, fmt.Sprintf("GOPATH entry %s, also known as %s, was considered", gp, forms[1]))
+		default:
+			diags = append(diags, fmt.Sprintf("GOPATH entry %s was considered", gp))
+		}
+	}
+	return diags
+}
+
+// gopathForms returns the paths by which the GOPATH gp is known: gp itself
+// and, if it is or is below a symlink, the path it resolves to.
+func gopathForms(gp string) []string {
+	forms := []string{gp}
+	if resolved, err := filepath.EvalSymlinks(gp); err == nil && resolved != filepath.Clean(gp) {
+		forms = append(forms, resolved)
+	}
+	return forms
+}
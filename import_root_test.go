@@ -0,0 +1,108 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestResolveImportRoot(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir(filepath.Join("outer", "src", "corp", "nested", "src", "github.com", "user", "inner"))
+	h.TempDir(filepath.Join("outer", "src", "github.com", "user", "outer"))
+	h.TempDir("elsewhere")
+	outer := h.Path("outer")
+	nested := filepath.Join(outer, "src", "corp", "nested")
+
+	project := func(root string) *Project {
+		p := &Project{Manifest: NewManifest()}
+		if err := p.SetRoot(root); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	cases := []struct {
+		name    string
+		gopaths []string
+		root    string
+		want    gps.ProjectRoot
+		gopath  string
+	}{
+		{"outer", []string{outer, nested}, filepath.Join(outer, "src", "github.com", "user", "outer"), "github.com/user/outer", outer},
+		// The innermost of nested GOPATHs is used, whatever their order.
+		{"nested", []string{outer, nested}, filepath.Join(nested, "src", "github.com", "user", "inner"), "github.com/user/inner", nested},
+		{"nested-reversed", []string{nested, outer}, filepath.Join(nested, "src", "github.com", "user", "inner"), "github.com/user/inner", nested},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := &Ctx{GOPATHs: c.gopaths}
+			r, err := ctx.ResolveImportRoot(project(c.root))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.ImportRoot != c.want || r.GOPATH != c.gopath || r.Via != ImportRootGOPATH {
+				t.Errorf("expected %s in %s via GOPATH, got %s in %s via %s", c.want, c.gopath, r.ImportRoot, r.GOPATH, r.Via)
+			}
+		})
+	}
+
+	t.Run("diagnostics", func(t *testing.T) {
+		ctx := &Ctx{GOPATHs: []string{outer, "relative"}}
+		r, err := ctx.ResolveImportRoot(project(h.Path("elsewhere")))
+		rerr, ok := err.(*ImportRootError)
+		if !ok {
+			t.Fatalf("expected an *ImportRootError, got %v", err)
+		}
+		if r.GOPATH != outer {
+			t.Errorf("expected the GOPATH to fall back to %s, got %s", outer, r.GOPATH)
+		}
+		msg := rerr.Error()
+		for _, want := range []string{"import-root", "relative is not an absolute path, so is ignored", outer + " was considered", modFileName} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("expected the error to mention %q:\n%s", want, msg)
+			}
+		}
+	})
+}
+
+func TestResolveImportRootSymlinkedGOPATH(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping symlink test on windows")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir(filepath.Join("real", "src", "github.com", "user", "project"))
+	link := filepath.Join(h.Path("."), "link")
+	if err := os.Symlink(h.Path("real"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	// The GOPATH is set through the symlink, while the working directory is
+	// below its target, as when the shell or an IDE resolves it.
+	ctx := &Ctx{GOPATHs: []string{link}}
+	p := &Project{Manifest: NewManifest()}
+	if err := p.SetRoot(filepath.Join(h.Path("real"), "src", "github.com", "user", "project")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ctx.ResolveImportRoot(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ImportRoot != "github.com/user/project" || r.GOPATH != link {
+		t.Errorf("expected github.com/user/project in %s, got %s in %s", link, r.ImportRoot, r.GOPATH)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// LedgerName is the name of the file, in the cache directory, holding the
+// ledger of the revisions that locked versions have been seen at.
+const LedgerName = "revision-ledger.jsonl"
+
+// A LedgerEntry records that a version of a project was locked at a revision,
+// with the digest its tree had when vendored with the given prune options.
+// Digest and PruneOpts are empty if the lock recorded no digest.
+type LedgerEntry struct {
+	ProjectRoot gps.ProjectRoot `json:"project"`
+	Source      string          `json:"source,omitempty"`
+	Version     string          `json:"version"`
+	Revision    gps.Revision    `json:"revision"`
+	PruneOpts   string          `json:"pruneopts,omitempty"`
+	Digest      string          `json:"digest,omitempty"`
+	Seen        time.Time       `json:"seen"`
+}
+
+// sameVersion reports whether e and o are of the same version of the same
+// project, from the same source.
+func (e LedgerEntry) sameVersion(o LedgerEntry) bool {
+	return e.ProjectRoot == o.ProjectRoot && e.Source == o.Source && e.Version == o.Version
+}
+
+// A LedgerConflict is a locked version whose revision, or digest, differs from
+// the one it had when it was first seen.
+type LedgerConflict struct {
+	First, Now LedgerEntry
+}
+
+func (c LedgerConflict) String() string {
+	if c.First.Revision != c.Now.Revision {
+		return fmt.Sprintf("%s@%s is locked at revision %s, but was at %s when first seen on %s",
+			c.Now.ProjectRoot, c.Now.Version, c.Now.Revision, c.First.Revision, c.First.Seen.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("%s@%s at revision %s has digest %s, but had %s when first seen on %s",
+		c.Now.ProjectRoot, c.Now.Version, c.Now.Revision, c.Now.Digest, c.First.Digest, c.First.Seen.Format("2006-01-02"))
+}
+
+// ReadLedger returns the entries of the ledger at path, in the order they were
+// appended. A missing ledger has no entries.
+func ReadLedger(path string) ([]LedgerEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the revision ledger")
+	}
+	defer f.Close()
+
+	var entries []LedgerEntry
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e LedgerEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, errors.Wrapf(err, "line %d of the revision ledger %s is invalid", n, path)
+		}
+		entries = append(entries, e)
+	}
+	return entries, errors.Wrap(s.Err(), "failed to read the revision ledger")
+}
+
+// AppendLedger appends entries to the ledger at path, creating it if needed.
+func AppendLedger(path string, entries []LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return errors.Wrap(err, "failed to encode revision ledger entry")
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the revision ledger")
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to append to the revision ledger")
+	}
+	return errors.Wrap(f.Close(), "failed to append to the revision ledger")
+}
+
+// CheckLedger compares the projects in l that are locked to a tag or other
+// version, rather than a branch or bare revision, with the entries of a
+// ledger. It returns the conflicts between them and the first entry for the
+// same version, and the new entries that record the projects not yet in the
+// ledger as they are, seen at now.
+//
+// Only the revision is compared when either side has no digest, or the prune
+// options differ, as the digest depends on them.
+func CheckLedger(ledger []LedgerEntry, l *Lock, now time.Time) (conflicts []LedgerConflict, fresh []LedgerEntry) {
+	for _, lp := range l.Projects() {
+		pv, ok := lp.Version().(gps.PairedVersion)
+		if !ok || pv.Type() == gps.IsBranch {
+			continue
+		}
+		e := LedgerEntry{
+			ProjectRoot: lp.Ident().ProjectRoot,
+			Source:      lp.Ident().Source,
+			Version:     pv.Unpair().String(),
+			Revision:    pv.Revision(),
+			Seen:        now,
+		}
+		if vp, ok := lp.(verify.VerifiableProject); ok && !vp.Digest.IsEmpty() {
+			e.PruneOpts, e.Digest = vp.PruneOpts.String(), vp.Digest.String()
+		}
+
+		var first *LedgerEntry
+		known := false
+		for i := range ledger {
+			le := ledger[i]
+			if !le.sameVersion(e) {
+				continue
+			}
+			if first == nil {
+				first = &ledger[i]
+			}
+			if le.Revision == e.Revision && le.PruneOpts == e.PruneOpts && le.Digest == e.Digest {
+				known = true
+			}
+		}
+
+		if first != nil {
+			digestsComparable := first.Digest != "" && e.Digest != "" && first.PruneOpts == e.PruneOpts
+			if first.Revision != e.Revision || (digestsComparable && first.Digest != e.Digest) {
+				conflicts = append(conflicts, LedgerConflict{First: *first, Now: e})
+			}
+		}
+		if !known {
+			fresh = append(fresh, e)
+		}
+	}
+	return conflicts, fresh
+}
+
+// CheckRevisionLedger warns of each project in l whose version is locked at a
+// different revision, or with a different digest, than when it was first seen
+// by dep on this machine, as when a tag is moved upstream, and records those
+// not seen before in the ledger in the cache directory. Nothing is done if the
+// cache directory does not exist yet.
+func (c *Ctx) CheckRevisionLedger(l *Lock) error {
+	if l == nil {
+		return nil
+	}
+	if _, err := os.Stat(c.CacheDir()); err != nil {
+		return nil
+	}
+
+	path := filepath.Join(c.CacheDir(), LedgerName)
+	ledger, err := ReadLedger(path)
+	if err != nil {
+		return err
+	}
+	conflicts, fresh := CheckLedger(ledger, l, time.Now().UTC())
+	if len(conflicts) > 0 {
+		c.Err.Println("Warning: some locked versions differ from when they were first seen, which suggests that they were rewritten upstream:")
+		for _, conflict := range conflicts {
+			c.Err.Printf("  %s\n", conflict)
+		}
+		c.Err.Printf("Check that the new revisions are trustworthy. To trust them from now on, remove the entries for them from %s.\n", path)
+	}
+	return AppendLedger(path, fresh)
+}
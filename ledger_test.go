@@ -0,0 +1,90 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestCheckLedger(t *testing.T) {
+	first := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	now := first.Add(24 * time.Hour)
+	ledger := []LedgerEntry{
+		{ProjectRoot: "github.com/foo/moved", Version: "v1.0.0", Revision: "aaa", Seen: first},
+		{ProjectRoot: "github.com/foo/same", Version: "v1.0.0", Revision: "bbb", Seen: first},
+		// A moved tag keeps being reported until its entries are removed.
+		{ProjectRoot: "github.com/foo/removed", Version: "v1.0.0", Revision: "ccc", Seen: first},
+		{ProjectRoot: "github.com/foo/removed", Version: "v1.0.0", Revision: "ccc2", Seen: first},
+	}
+	lp := func(root string, v gps.Version) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, v, nil)
+	}
+	l := &Lock{P: []gps.LockedProject{
+		lp("github.com/foo/moved", gps.NewVersion("v1.0.0").Pair("aaa2")),
+		lp("github.com/foo/same", gps.NewVersion("v1.0.0").Pair("bbb")),
+		lp("github.com/foo/removed", gps.NewVersion("v1.0.0").Pair("ccc2")),
+		lp("github.com/foo/new", gps.NewVersion("v1.0.0").Pair("ddd")),
+		lp("github.com/foo/branch", gps.NewBranch("master").Pair("eee")),
+		lp("github.com/foo/rev", gps.Revision("fff")),
+	}}
+
+	conflicts, fresh := CheckLedger(ledger, l, now)
+	var moved []gps.ProjectRoot
+	for _, c := range conflicts {
+		moved = append(moved, c.Now.ProjectRoot)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/moved", "github.com/foo/removed"}; !reflect.DeepEqual(moved, want) {
+		t.Errorf("expected conflicts for %v, got %v", want, moved)
+	}
+	if conflicts[0].First.Revision != "aaa" || conflicts[0].Now.Revision != "aaa2" {
+		t.Errorf("unexpected conflict %+v", conflicts[0])
+	}
+
+	want := []LedgerEntry{
+		{ProjectRoot: "github.com/foo/moved", Version: "v1.0.0", Revision: "aaa2", Seen: now},
+		{ProjectRoot: "github.com/foo/new", Version: "v1.0.0", Revision: "ddd", Seen: now},
+	}
+	if !reflect.DeepEqual(fresh, want) {
+		t.Errorf("expected new entries:\n\t%+v\ngot:\n\t%+v", want, fresh)
+	}
+}
+
+func TestLedgerRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, LedgerName)
+
+	if entries, err := ReadLedger(path); err != nil || entries != nil {
+		t.Fatalf("expected a missing ledger to be empty, got %v, %v", entries, err)
+	}
+
+	seen := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	a := LedgerEntry{ProjectRoot: "github.com/foo/a", Version: "v1.0.0", Revision: "aaa", PruneOpts: "NUT", Digest: "1:abc", Seen: seen}
+	b := LedgerEntry{ProjectRoot: "github.com/foo/b", Source: "https://example.com/b", Version: "v2.0.0", Revision: "bbb", Seen: seen}
+	if err := AppendLedger(path, []LedgerEntry{a}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendLedger(path, []LedgerEntry{b}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []LedgerEntry{a, b}; !reflect.DeepEqual(entries, want) {
+		t.Errorf("expected entries:\n\t%+v\ngot:\n\t%+v", want, entries)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// The license identifiers returned by DetectLicense for a tree without a
+// license file, and for one whose license file is not recognized. They are
+// the SPDX terms for each.
+const (
+	LicenseNone    = "NONE"
+	LicenseUnknown = "NOASSERTION"
+)
+
+// licenseFileRE matches the names of the files that hold a project's license.
+var licenseFileRE = regexp.MustCompile(`(?i)^(un)?licen[cs]e|^copying`)
+
+// licensePhrases identifies licenses by phrases from their text, as
+// normalized by normalizeLicenseText, in the order they are tried. The
+// copyleft and other titled licenses are identified by their titles, which
+// must be in the first licenseHeadLen bytes, as their texts mention one
+// another; the GPL, for instance, mentions the AGPL.
+var licensePhrases = []struct {
+	id      string
+	head    bool
+	phrases []string
+}{
+	{"AGPL-3.0", true, []string{"gnu affero general public license version 3"}},
+	{"LGPL-3.0", true, []string{"gnu lesser general public license version 3"}},
+	{"LGPL-2.1", true, []string{"gnu lesser general public license version 2.1"}},
+	{"GPL-3.0", true, []string{"gnu general public license version 3"}},
+	{"GPL-2.0", true, []string{"gnu general public license version 2"}},
+	{"MPL-2.0", true, []string{"mozilla public license version 2.0"}},
+	{"EPL-1.0", true, []string{"eclipse public license v 1.0"}},
+	{"Apache-2.0", true, []string{"apache license version 2.0"}},
+	{"BSD-3-Clause", false, []string{"redistribution and use in source and binary forms", "neither the name of"}},
+	{"BSD-2-Clause", false, []string{"redistribution and use in source and binary forms"}},
+	{"MIT", false, []string{"permission is hereby granted free of charge"}},
+	{"ISC", false, []string{"permission to use copy modify and/or distribute this software for any purpose"}},
+	{"Unlicense", false, []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", false, []string{"cc0 1.0 universal"}},
+}
+
+const licenseHeadLen = 1000
+
+// DetectLicense returns the SPDX identifier of the license of the tree rooted
+// at dir, as recognized from the license file at its root. LicenseNone is
+// returned if there is no license file, and LicenseUnknown if the license is
+// not recognized.
+func DetectLicense(dir string) (string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s", dir)
+	}
+
+	var names []string
+	for _, fi := range fis {
+		if !fi.IsDir() && licenseFileRE.MatchString(fi.Name()) {
+			names = append(names, fi.Name())
+		}
+	}
+	if len(names) == 0 {
+		return LicenseNone, nil
+	}
+	// Prefer LICENSE to LICENSE.md, and so on.
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, names[0]))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read the license of %s", dir)
+	}
+	return identifyLicense(string(data)), nil
+}
+
+// identifyLicense returns the SPDX identifier of the license whose text is
+// text, or LicenseUnknown.
+func identifyLicense(text string) string {
+	text = normalizeLicenseText(text)
+	head := text
+	if len(head) > licenseHeadLen {
+		head = head[:licenseHeadLen]
+	}
+
+	for _, l := range licensePhrases {
+		in := text
+		if l.head {
+			in = head
+		}
+		matched := true
+		for _, p := range l.phrases {
+			if !strings.Contains(in, p) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return l.id
+		}
+	}
+	return LicenseUnknown
+}
+
+// normalizeLicenseText lowercases text, and replaces each run of spaces and
+// punctuation other than '.' and '/' with a single space, so that phrases
+// can be found however the text was wrapped or punctuated.
+func normalizeLicenseText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '/'
+	}), " ")
+}
+
+// LicenseChange describes a project whose license differs between two of its
+// versions.
+type LicenseChange struct {
+	ProjectRoot   gps.ProjectRoot
+	Before, After gps.Version
+	// BeforeLicense and AfterLicense are the licenses of Before and After, as
+	// returned by DetectLicense.
+	BeforeLicense, AfterLicense string
+}
+
+// LicenseChanges returns the projects in both oldLock and newLock that are at
+// a different revision in newLock, and whose detected license differs between
+// the two, in the order of newLock. The trees of both revisions are exported
+// through sm to be inspected.
+func LicenseChanges(oldLock, newLock *Lock, sm gps.SourceManager) ([]LicenseChange, error) {
+	old := make(map[gps.ProjectRoot]gps.LockedProject, len(oldLock.P))
+	for _, lp := range oldLock.P {
+		old[lp.Ident().ProjectRoot] = lp
+	}
+
+	td, err := ioutil.TempDir("", "dep-licenses")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	license := func(id gps.ProjectIdentifier, v gps.Version, name string) (string, error) {
+		to := filepath.Join(td, name)
+		if err := sm.ExportProject(context.TODO(), id, v, to); err != nil {
+			return "", errors.Wrapf(err, "failed to export %s@%s", id.ProjectRoot, v)
+		}
+		defer os.RemoveAll(to)
+		return DetectLicense(to)
+	}
+
+	var changes []LicenseChange
+	for _, lp := range newLock.P {
+		pr := lp.Ident().ProjectRoot
+		olp, has := old[pr]
+		if !has || revisionOf(olp.Version()) == revisionOf(lp.Version()) {
+			continue
+		}
+
+		before, err := license(olp.Ident(), olp.Version(), "before")
+		if err != nil {
+			return nil, err
+		}
+		after, err := license(lp.Ident(), lp.Version(), "after")
+		if err != nil {
+			return nil, err
+		}
+		if before != after {
+			changes = append(changes, LicenseChange{
+				ProjectRoot:   pr,
+				Before:        olp.Version(),
+				After:         lp.Version(),
+				BeforeLicense: before,
+				AfterLicense:  after,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// revisionOf returns the revision of a locked version.
+func revisionOf(v gps.Version) gps.Revision {
+	switch tv := v.(type) {
+	case gps.PairedVersion:
+		return tv.Revision()
+	case gps.Revision:
+		return tv
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestIdentifyLicense(t *testing.T) {
+	for want, text := range map[string]string{
+		"MIT": `The MIT License (MIT)
+
+Copyright (c) 2015 Someone
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal`,
+		"Apache-2.0": `
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/`,
+		"BSD-3-Clause": `Copyright (c) 2009 The Go Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from`,
+		"BSD-2-Clause": `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:`,
+		"AGPL-3.0": `                    GNU AFFERO GENERAL PUBLIC LICENSE
+                       Version 3, 19 November 2007`,
+		// The GPL mentions the AGPL, but only well past its title.
+		"GPL-3.0": "                    GNU GENERAL PUBLIC LICENSE\n                       Version 3, 29 June 2007\n" +
+			strings.Repeat("terms ", licenseHeadLen) + "13. Use with the GNU Affero General Public License version 3.",
+		"LGPL-2.1":     "GNU LESSER GENERAL PUBLIC LICENSE\nVersion 2.1, February 1999",
+		"MPL-2.0":      "Mozilla Public License, version 2.0",
+		LicenseUnknown: "All rights reserved. Do not copy.",
+		"ISC":          "Permission to use, copy, modify, and/or distribute this software for any\npurpose with or without fee is hereby granted",
+		"Unlicense":    "This is free and unencumbered software released into the public domain.",
+		"GPL-2.0":      "GNU GENERAL PUBLIC LICENSE\nVersion 2, June 1991",
+		"EPL-1.0":      "Eclipse Public License - v 1.0",
+		"CC0-1.0":      "Creative Commons Legal Code\n\nCC0 1.0 Universal",
+		"LGPL-3.0":     "GNU LESSER GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007",
+	} {
+		if got := identifyLicense(text); got != want {
+			t.Errorf("expected %s, got %s for:\n%s", want, got, text)
+		}
+	}
+}
+
+func TestDetectLicense(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-license")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if got, err := DetectLicense(dir); err != nil || got != LicenseNone {
+		t.Errorf("expected %s without a license file, got %s, %v", LicenseNone, got, err)
+	}
+
+	write := func(name, text string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("LICENSE.md", "GNU AFFERO GENERAL PUBLIC LICENSE Version 3")
+	write("license", "Permission is hereby granted, free of charge, to any person")
+	if got, err := DetectLicense(dir); err != nil || got != "MIT" {
+		t.Errorf("expected the license in the file named most plainly to be detected, got %s, %v", got, err)
+	}
+}
+
+// licenseSM serves each revision of a project as a tree holding only a
+// license file with the text given for it.
+type licenseSM struct {
+	gps.SourceManager
+	licenses map[gps.Revision]string
+}
+
+func (sm licenseSM) ExportProject(ctx context.Context, id gps.ProjectIdentifier, v gps.Version, to string) error {
+	if err := os.MkdirAll(to, 0777); err != nil {
+		return err
+	}
+	text, has := sm.licenses[v.(gps.PairedVersion).Revision()]
+	if !has {
+		return nil
+	}
+	return ioutil.WriteFile(filepath.Join(to, "LICENSE"), []byte(text), 0666)
+}
+
+func TestLicenseChanges(t *testing.T) {
+	const mit, agpl = "Permission is hereby granted, free of charge", "GNU Affero General Public License, version 3"
+	lp := func(root string, v gps.Version) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, v, nil)
+	}
+	oldLock := &Lock{P: []gps.LockedProject{
+		lp("github.com/foo/relicensed", gps.NewVersion("v1.0.0").Pair("aaa")),
+		lp("github.com/foo/same", gps.NewVersion("v1.0.0").Pair("bbb")),
+		lp("github.com/foo/unchanged", gps.NewVersion("v1.0.0").Pair("ccc")),
+		lp("github.com/foo/dropped", gps.NewVersion("v1.0.0").Pair("ddd")),
+	}}
+	newLock := &Lock{P: []gps.LockedProject{
+		lp("github.com/foo/relicensed", gps.NewVersion("v2.0.0").Pair("aaa2")),
+		lp("github.com/foo/same", gps.NewVersion("v1.1.0").Pair("bbb2")),
+		lp("github.com/foo/unchanged", gps.NewVersion("v1.0.0").Pair("ccc")),
+		lp("github.com/foo/added", gps.NewVersion("v1.0.0").Pair("eee")),
+	}}
+	sm := licenseSM{licenses: map[gps.Revision]string{
+		"aaa": mit, "aaa2": agpl,
+		"bbb": mit, "bbb2": mit,
+		"eee": agpl,
+	}}
+
+	changes, err := LicenseChanges(oldLock, newLock, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected one license change, got %+v", changes)
+	}
+	c := changes[0]
+	if c.ProjectRoot != "github.com/foo/relicensed" || c.BeforeLicense != "MIT" || c.AfterLicense != "AGPL-3.0" || c.After.String() != "v2.0.0" {
+		t.Errorf("unexpected license change %+v", c)
+	}
+}
@@ -7,7 +7,11 @@ package dep
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/verify"
@@ -22,6 +26,10 @@ const LockName = "Gopkg.lock"
 type Lock struct {
 	SolveMeta SolveMeta
 	P         []gps.LockedProject
+	// Decisions records why each project was selected at its version, for
+	// projects whose manifest sets record-decisions. It is nil if none were
+	// recorded.
+	Decisions map[gps.ProjectRoot]gps.Decision
 }
 
 // SolveMeta holds metadata about the solving process that created the lock that
@@ -32,6 +40,25 @@ type SolveMeta struct {
 	SolverName      string
 	SolverVersion   int
 	InputImports    []string
+	// UpdateStrategy is the name of the gps.UpdateStrategy used by the solve,
+	// if it was not the default.
+	UpdateStrategy string
+	// GoVersion is the newest minimum Go version declared by the root project
+	// or any of the locked projects.
+	GoVersion string
+	// InputGoVersions are the Go versions declared by the root manifest, in
+	// the form returned by gps.GoVersionInputs. A change to them makes the
+	// lock out of date, just as one to InputImports does.
+	InputGoVersions []string
+	// InputBuildContext holds the parts of the build context that the root
+	// manifest's ignored packages depended on, in the form returned by
+	// gps.InputBuildContext. Like InputGoVersions, a change to it makes the
+	// lock out of date.
+	InputBuildContext string
+	// ToolVersions are the versions of the version control tools that
+	// handle the sources of the locked projects, as found when the lock was
+	// written, in the form returned by RecordToolVersions.
+	ToolVersions []string
 }
 
 type rawLock struct {
@@ -40,11 +67,16 @@ type rawLock struct {
 }
 
 type solveMeta struct {
-	AnalyzerName    string   `toml:"analyzer-name"`
-	AnalyzerVersion int      `toml:"analyzer-version"`
-	SolverName      string   `toml:"solver-name"`
-	SolverVersion   int      `toml:"solver-version"`
-	InputImports    []string `toml:"input-imports"`
+	AnalyzerName      string   `toml:"analyzer-name"`
+	AnalyzerVersion   int      `toml:"analyzer-version"`
+	SolverName        string   `toml:"solver-name"`
+	SolverVersion     int      `toml:"solver-version"`
+	InputImports      []string `toml:"input-imports"`
+	UpdateStrategy    string   `toml:"update-strategy,omitempty"`
+	GoVersion         string   `toml:"go-version,omitempty"`
+	InputGoVersions   []string `toml:"input-go-versions,omitempty"`
+	InputBuildContext string   `toml:"input-build-context,omitempty"`
+	ToolVersions      []string `toml:"tool-versions,omitempty"`
 }
 
 type rawLockedProject struct {
@@ -53,9 +85,23 @@ type rawLockedProject struct {
 	Revision  string   `toml:"revision"`
 	Version   string   `toml:"version,omitempty"`
 	Source    string   `toml:"source,omitempty"`
+	SourceURL string   `toml:"source-url,omitempty"`
 	Packages  []string `toml:"packages"`
 	PruneOpts string   `toml:"pruneopts"`
+	Assets    []string `toml:"assets,omitempty"`
+	Generated []string `toml:"generated,omitempty"`
 	Digest    string   `toml:"digest"`
+	Direct    bool     `toml:"direct,omitempty"`
+
+	Decision           string `toml:"decision,omitempty"`
+	DecisionConstraint string `toml:"decision-constraint,omitempty"`
+	DecisionBy         string `toml:"decision-by,omitempty"`
+}
+
+// ReadLock returns a Lock read from r. An error is returned if r does not hold
+// a valid lock.
+func ReadLock(r io.Reader) (*Lock, error) {
+	return readLock(r)
 }
 
 func readLock(r io.Reader) (*Lock, error) {
@@ -84,6 +130,11 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 	l.SolveMeta.SolverName = raw.SolveMeta.SolverName
 	l.SolveMeta.SolverVersion = raw.SolveMeta.SolverVersion
 	l.SolveMeta.InputImports = raw.SolveMeta.InputImports
+	l.SolveMeta.UpdateStrategy = raw.SolveMeta.UpdateStrategy
+	l.SolveMeta.GoVersion = raw.SolveMeta.GoVersion
+	l.SolveMeta.InputGoVersions = raw.SolveMeta.InputGoVersions
+	l.SolveMeta.InputBuildContext = raw.SolveMeta.InputBuildContext
+	l.SolveMeta.ToolVersions = raw.SolveMeta.ToolVersions
 
 	for _, ld := range raw.Projects {
 		r := gps.Revision(ld.Revision)
@@ -108,6 +159,9 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 		var err error
 		vp := verify.VerifiableProject{
 			LockedProject: gps.NewLockedProject(id, v, ld.Packages),
+			SourceURL:     ld.SourceURL,
+			Assets:        ld.Assets,
+			Generated:     ld.Generated,
 		}
 		if ld.Digest != "" {
 			vp.Digest, err = verify.ParseVersionedDigest(ld.Digest)
@@ -123,6 +177,17 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 		// Add the vendor pruning bit so that gps doesn't get confused
 		vp.PruneOpts = po | gps.PruneNestedVendorDirs
 
+		if ld.Decision != "" {
+			if l.Decisions == nil {
+				l.Decisions = make(map[gps.ProjectRoot]gps.Decision)
+			}
+			l.Decisions[id.ProjectRoot] = gps.Decision{
+				Reason:     ld.Decision,
+				Constraint: ld.DecisionConstraint,
+				By:         gps.ProjectRoot(ld.DecisionBy),
+			}
+		}
+
 		l.P = append(l.P, vp)
 	}
 
@@ -146,6 +211,25 @@ func (l *Lock) InputImports() []string {
 	return l.SolveMeta.InputImports
 }
 
+// InputGoVersions returns the Go versions declared by the root manifest when
+// the lock was solved, in the form returned by gps.GoVersionInputs.
+func (l *Lock) InputGoVersions() []string {
+	if l == nil {
+		return nil
+	}
+	return l.SolveMeta.InputGoVersions
+}
+
+// InputBuildContext returns the parts of the build context that the root
+// manifest's ignored packages depended on when the lock was solved, in the form
+// returned by gps.InputBuildContext.
+func (l *Lock) InputBuildContext() string {
+	if l == nil {
+		return ""
+	}
+	return l.SolveMeta.InputBuildContext
+}
+
 // HasProjectWithRoot checks if the lock contains a project with the provided
 // ProjectRoot.
 //
@@ -160,6 +244,30 @@ func (l *Lock) HasProjectWithRoot(root gps.ProjectRoot) bool {
 	return false
 }
 
+// DirectDependencies returns the set of projects in the lock that the root
+// project imports, or requires, itself: those holding any of the lock's input
+// imports. All others are only depended on transitively. The map contains only
+// true values.
+func (l *Lock) DirectDependencies() map[gps.ProjectRoot]bool {
+	direct := make(map[gps.ProjectRoot]bool)
+	if l == nil {
+		return direct
+	}
+	for _, ip := range l.SolveMeta.InputImports {
+		var root gps.ProjectRoot
+		for _, lp := range l.P {
+			pr := lp.Ident().ProjectRoot
+			if len(pr) > len(root) && (ip == string(pr) || strings.HasPrefix(ip, string(pr)+"/")) {
+				root = pr
+			}
+		}
+		if root != "" {
+			direct[root] = true
+		}
+	}
+	return direct
+}
+
 func (l *Lock) dup() *Lock {
 	l2 := &Lock{
 		SolveMeta: l.SolveMeta,
@@ -168,7 +276,15 @@ func (l *Lock) dup() *Lock {
 
 	l2.SolveMeta.InputImports = make([]string, len(l.SolveMeta.InputImports))
 	copy(l2.SolveMeta.InputImports, l.SolveMeta.InputImports)
+	l2.SolveMeta.InputGoVersions = append([]string(nil), l.SolveMeta.InputGoVersions...)
+	l2.SolveMeta.ToolVersions = append([]string(nil), l.SolveMeta.ToolVersions...)
 	copy(l2.P, l.P)
+	if l.Decisions != nil {
+		l2.Decisions = make(map[gps.ProjectRoot]gps.Decision, len(l.Decisions))
+		for pr, d := range l.Decisions {
+			l2.Decisions[pr] = d
+		}
+	}
 
 	return l2
 }
@@ -177,11 +293,16 @@ func (l *Lock) dup() *Lock {
 func (l *Lock) toRaw() rawLock {
 	raw := rawLock{
 		SolveMeta: solveMeta{
-			AnalyzerName:    l.SolveMeta.AnalyzerName,
-			AnalyzerVersion: l.SolveMeta.AnalyzerVersion,
-			InputImports:    l.SolveMeta.InputImports,
-			SolverName:      l.SolveMeta.SolverName,
-			SolverVersion:   l.SolveMeta.SolverVersion,
+			AnalyzerName:      l.SolveMeta.AnalyzerName,
+			AnalyzerVersion:   l.SolveMeta.AnalyzerVersion,
+			InputImports:      l.SolveMeta.InputImports,
+			SolverName:        l.SolveMeta.SolverName,
+			SolverVersion:     l.SolveMeta.SolverVersion,
+			UpdateStrategy:    l.SolveMeta.UpdateStrategy,
+			GoVersion:         l.SolveMeta.GoVersion,
+			InputGoVersions:   l.SolveMeta.InputGoVersions,
+			InputBuildContext: l.SolveMeta.InputBuildContext,
+			ToolVersions:      l.SolveMeta.ToolVersions,
 		},
 		Projects: make([]rawLockedProject, 0, len(l.P)),
 	}
@@ -190,6 +311,8 @@ func (l *Lock) toRaw() rawLock {
 		return l.P[i].Ident().Less(l.P[j].Ident())
 	})
 
+	direct := l.DirectDependencies()
+
 	for _, lp := range l.P {
 		id := lp.Ident()
 		ld := rawLockedProject{
@@ -207,7 +330,14 @@ func (l *Lock) toRaw() rawLock {
 		// by failing hard if those expectations aren't met.
 		vp := lp.(verify.VerifiableProject)
 		ld.Digest = vp.Digest.String()
+		ld.SourceURL = vp.SourceURL
 		ld.PruneOpts = (vp.PruneOpts & ^gps.PruneNestedVendorDirs).String()
+		ld.Assets = vp.Assets
+		ld.Generated = vp.Generated
+		ld.Direct = direct[id.ProjectRoot]
+		if d, has := l.Decisions[id.ProjectRoot]; has {
+			ld.Decision, ld.DecisionConstraint, ld.DecisionBy = d.Reason, d.Constraint, string(d.By)
+		}
 
 		raw.Projects = append(raw.Projects, ld)
 	}
@@ -224,6 +354,72 @@ func (l *Lock) MarshalTOML() ([]byte, error) {
 	return buf.Bytes(), errors.Wrap(err, "Unable to marshal lock to TOML string")
 }
 
+// WriteLockFile writes l to path in the form that dep writes Gopkg.lock in.
+func WriteLockFile(path string, l *Lock) error {
+	b, err := l.MarshalTOML()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lock to TOML")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, append(lockFileComment, b...), 0666), "failed to write %s", path)
+}
+
+// FillDigests records, for each project in l without a digest, the digest of
+// its tree as it would be written to vendor/ with the given prune options,
+// along with the URL of its source, as writing vendor/ does. It is for locks
+// that are written without vendor/.
+func FillDigests(l *Lock, sm gps.SourceManager, prune gps.CascadingPruneOptions) error {
+	missing := &Lock{}
+	for _, lp := range l.P {
+		if vp, ok := lp.(verify.VerifiableProject); !ok || vp.Digest.IsEmpty() {
+			missing.P = append(missing.P, lp)
+		}
+	}
+	if len(missing.P) == 0 {
+		return nil
+	}
+
+	td, err := ioutil.TempDir("", "dep-digests")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+	if err := gps.WriteDepTree(td, missing, sm, prune, nil); err != nil {
+		return errors.Wrap(err, "error while writing out dependency trees to hash")
+	}
+
+	for k, lp := range l.P {
+		vp, ok := lp.(verify.VerifiableProject)
+		if ok && !vp.Digest.IsEmpty() {
+			continue
+		}
+		if !ok {
+			vp = verify.VerifiableProject{
+				LockedProject: lp,
+				PruneOpts:     prune.PruneOptionsFor(lp.Ident().ProjectRoot),
+				Assets:        prune.Assets[lp.Ident().ProjectRoot],
+			}
+		}
+		vp.Generated, err = gps.GeneratedFilesOf(sm, lp, Analyzer{}, prune)
+		if err != nil {
+			return errors.Wrapf(err, "error while reading the generated files of %s", lp.Ident().ProjectRoot)
+		}
+		ex := verify.DigestExclusions{
+			Nested:    verify.NestedProjects(lp.Ident().ProjectRoot, l),
+			Generated: vp.Generated,
+		}
+		vp.Digest, err = verify.DigestFromDirectoryExcluding(filepath.Join(td, string(lp.Ident().ProjectRoot)), ex)
+		if err != nil {
+			return errors.Wrapf(err, "error while hashing tree of %s", lp.Ident().ProjectRoot)
+		}
+		vp.SourceURL, err = sm.SourceURLFor(lp.Ident())
+		if err != nil {
+			return errors.Wrapf(err, "error while determining source URL of %s", lp.Ident().ProjectRoot)
+		}
+		l.P[k] = vp
+	}
+	return nil
+}
+
 // LockFromSolution converts a gps.Solution to dep's representation of a lock.
 // It makes sure that that the provided prune options are set correctly, as the
 // solver does not use VerifiableProjects for new selections it makes.
@@ -235,14 +431,26 @@ func LockFromSolution(in gps.Solution, prune gps.CascadingPruneOptions) *Lock {
 
 	l := &Lock{
 		SolveMeta: SolveMeta{
-			AnalyzerName:    in.AnalyzerName(),
-			AnalyzerVersion: in.AnalyzerVersion(),
-			InputImports:    in.InputImports(),
-			SolverName:      in.SolverName(),
-			SolverVersion:   in.SolverVersion(),
+			AnalyzerName:      in.AnalyzerName(),
+			AnalyzerVersion:   in.AnalyzerVersion(),
+			InputImports:      in.InputImports(),
+			SolverName:        in.SolverName(),
+			SolverVersion:     in.SolverVersion(),
+			GoVersion:         in.GoVersion(),
+			InputGoVersions:   in.InputGoVersions(),
+			InputBuildContext: in.InputBuildContext(),
 		},
 		P: make([]gps.LockedProject, 0, len(p)),
 	}
+	if us := in.UpdateStrategy(); us != gps.MaximizeFreshness {
+		l.SolveMeta.UpdateStrategy = us.String()
+	}
+	if dec := in.Decisions(); dec != nil {
+		l.Decisions = make(map[gps.ProjectRoot]gps.Decision, len(dec))
+		for pr, d := range dec {
+			l.Decisions[pr] = d
+		}
+	}
 
 	for _, lp := range p {
 		if vp, ok := lp.(verify.VerifiableProject); ok {
@@ -251,6 +459,7 @@ func LockFromSolution(in gps.Solution, prune gps.CascadingPruneOptions) *Lock {
 			l.P = append(l.P, verify.VerifiableProject{
 				LockedProject: lp,
 				PruneOpts:     prune.PruneOptionsFor(lp.Ident().ProjectRoot),
+				Assets:        prune.Assets[lp.Ident().ProjectRoot],
 			})
 		}
 	}
@@ -0,0 +1,199 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// The markers that delimit the sides of a merge conflict, as written by git
+// and other version control systems. The base is only written by git's diff3
+// conflict style.
+const (
+	conflictOursMarker   = "<<<<<<<"
+	conflictBaseMarker   = "|||||||"
+	conflictSplitMarker  = "======="
+	conflictTheirsMarker = ">>>>>>>"
+)
+
+// isConflictMarker reports whether line starts with marker, followed by
+// nothing or a label.
+func isConflictMarker(line, marker string) bool {
+	return line == marker || strings.HasPrefix(line, marker+" ")
+}
+
+// SplitConflicts separates the sides of the merge conflicts in data, the
+// contents of a file holding conflict markers. Each side gets the lines
+// outside of the conflicts along with its own lines in each of them. The base
+// is only returned if every conflict records it; it is nil otherwise. An error
+// is returned if data holds no conflicts, or unbalanced markers.
+func SplitConflicts(data []byte) (base, ours, theirs []byte, err error) {
+	const (
+		outside = iota
+		inOurs
+		inBase
+		inTheirs
+	)
+
+	var bb, ob, tb bytes.Buffer
+	state, conflicts, withBase := outside, 0, 0
+	r := bufio.NewReader(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		line, rerr := r.ReadString('\n')
+		if line == "" && rerr == io.EOF {
+			break
+		}
+		text := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case isConflictMarker(text, conflictOursMarker):
+			if state != outside {
+				return nil, nil, nil, errors.Errorf("line %d: conflict starts inside another", n)
+			}
+			state = inOurs
+			conflicts++
+		case isConflictMarker(text, conflictBaseMarker):
+			if state != inOurs {
+				return nil, nil, nil, errors.Errorf("line %d: base of a conflict outside of one", n)
+			}
+			state = inBase
+			withBase++
+		case isConflictMarker(text, conflictSplitMarker) && (state == inOurs || state == inBase):
+			state = inTheirs
+		case isConflictMarker(text, conflictTheirsMarker):
+			if state != inTheirs {
+				return nil, nil, nil, errors.Errorf("line %d: conflict ends before their side", n)
+			}
+			state = outside
+		default:
+			switch state {
+			case outside:
+				bb.WriteString(line)
+				ob.WriteString(line)
+				tb.WriteString(line)
+			case inOurs:
+				ob.WriteString(line)
+			case inBase:
+				bb.WriteString(line)
+			case inTheirs:
+				tb.WriteString(line)
+			}
+		}
+
+		if rerr == io.EOF {
+			break
+		}
+	}
+
+	if state != outside {
+		return nil, nil, nil, errors.New("the last conflict is not closed")
+	}
+	if conflicts == 0 {
+		return nil, nil, nil, errors.New("no merge conflicts found")
+	}
+	if withBase == conflicts {
+		base = bb.Bytes()
+	}
+	return base, ob.Bytes(), tb.Bytes(), nil
+}
+
+// ReadConflictedLock reads the sides of the merge conflicts in a lock file
+// from r, as split by SplitConflicts. The base is nil if it is not recorded.
+func ReadConflictedLock(r io.Reader) (base, ours, theirs *Lock, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "Unable to read byte stream")
+	}
+	bd, od, td, err := SplitConflicts(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if bd != nil {
+		if base, err = readLock(bytes.NewReader(bd)); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "the base of the conflicts is not a valid lock")
+		}
+	}
+	if ours, err = readLock(bytes.NewReader(od)); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "our side of the conflicts is not a valid lock")
+	}
+	if theirs, err = readLock(bytes.NewReader(td)); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "their side of the conflicts is not a valid lock")
+	}
+	return base, ours, theirs, nil
+}
+
+// MergeLocks merges the projects of two locks, ours and theirs. Projects that
+// both lock the same way are kept, as are those that only one of them locks.
+// If base, the lock both started from, is not nil, a project that only one of
+// them has changed or removed takes that change.
+//
+// Any other project is a conflict: the two have changed it in different ways.
+// The conflicts are returned sorted, and the merged lock holds our side of
+// each, so that a solve that is allowed to change them can settle them. The
+// merged lock has the solve metadata of ours.
+func MergeLocks(base, ours, theirs *Lock) (*Lock, []gps.ProjectRoot) {
+	byRoot := func(l *Lock) map[gps.ProjectRoot]gps.LockedProject {
+		m := make(map[gps.ProjectRoot]gps.LockedProject)
+		for _, lp := range l.Projects() {
+			m[lp.Ident().ProjectRoot] = lp
+		}
+		return m
+	}
+	b, o, t := byRoot(base), byRoot(ours), byRoot(theirs)
+
+	roots := make([]gps.ProjectRoot, 0, len(o)+len(t))
+	for pr := range o {
+		roots = append(roots, pr)
+	}
+	for pr := range t {
+		if _, has := o[pr]; !has {
+			roots = append(roots, pr)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+
+	same := func(a, b gps.LockedProject) bool {
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+		return a.Eq(b)
+	}
+
+	merged := ours.dup()
+	merged.P = merged.P[:0]
+	var conflicts []gps.ProjectRoot
+	for _, pr := range roots {
+		olp, tlp := o[pr], t[pr]
+		lp := olp
+		switch {
+		case same(olp, tlp):
+		case base != nil && same(b[pr], olp):
+			lp = tlp
+		case base != nil && same(b[pr], tlp):
+		case base == nil && (olp == nil || tlp == nil):
+			if olp == nil {
+				lp = tlp
+			}
+		default:
+			conflicts = append(conflicts, pr)
+			if olp == nil {
+				lp = tlp
+			}
+		}
+		if lp != nil {
+			merged.P = append(merged.P, lp)
+		}
+	}
+	return merged, conflicts
+}
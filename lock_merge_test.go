@@ -0,0 +1,88 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestSplitConflicts(t *testing.T) {
+	data := strings.Join([]string{
+		"a",
+		"<<<<<<< HEAD",
+		"ours",
+		"||||||| merged common ancestors",
+		"base",
+		"=======",
+		"theirs",
+		">>>>>>> feature",
+		"b",
+		"",
+	}, "\n")
+	base, ours, theirs, err := SplitConflicts([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string][]byte{"a\nbase\nb\n": base, "a\nours\nb\n": ours, "a\ntheirs\nb\n": theirs} {
+		if string(got) != name {
+			t.Errorf("expected %q, got %q", name, got)
+		}
+	}
+
+	// Without a base, as in git's default conflict style.
+	base, ours, _, err = SplitConflicts([]byte("<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> feature\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base != nil || string(ours) != "ours\n" {
+		t.Errorf("expected no base and our side, got %q and %q", base, ours)
+	}
+
+	for name, data := range map[string]string{
+		"no conflicts": "a\nb\n",
+		"unclosed":     "<<<<<<< HEAD\nours\n=======\ntheirs\n",
+		"no split":     "<<<<<<< HEAD\nours\n>>>>>>> feature\n",
+		"nested":       "<<<<<<< HEAD\n<<<<<<< HEAD\n",
+	} {
+		if _, _, _, err := SplitConflicts([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMergeLocks(t *testing.T) {
+	lp := func(root, version string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, gps.NewVersion(version).Pair(gps.Revision("rev-"+version)), []string{"."})
+	}
+	lock := func(lps ...gps.LockedProject) *Lock { return &Lock{P: lps} }
+
+	base := lock(lp("a", "1.0.0"), lp("b", "1.0.0"), lp("c", "1.0.0"), lp("d", "1.0.0"))
+	ours := lock(lp("a", "1.1.0"), lp("b", "1.0.0"), lp("c", "1.2.0"), lp("e", "1.0.0"))
+	theirs := lock(lp("a", "1.0.0"), lp("b", "2.0.0"), lp("c", "1.3.0"), lp("d", "1.0.0"), lp("f", "1.0.0"))
+
+	merged, conflicts := MergeLocks(base, ours, theirs)
+	want := lock(lp("a", "1.1.0"), lp("b", "2.0.0"), lp("c", "1.2.0"), lp("e", "1.0.0"), lp("f", "1.0.0"))
+	if !reflect.DeepEqual(merged.P, want.P) {
+		t.Errorf("unexpected merge:\n\t(GOT): %v\n\t(WNT): %v", merged.P, want.P)
+	}
+	if wantc := []gps.ProjectRoot{"c"}; !reflect.DeepEqual(conflicts, wantc) {
+		t.Errorf("expected conflicts %v, got %v", wantc, conflicts)
+	}
+
+	// Without a base, a project removed on one side is kept, and any project
+	// locked differently is a conflict.
+	merged, conflicts = MergeLocks(nil, ours, theirs)
+	want = lock(lp("a", "1.1.0"), lp("b", "1.0.0"), lp("c", "1.2.0"), lp("d", "1.0.0"), lp("e", "1.0.0"), lp("f", "1.0.0"))
+	if !reflect.DeepEqual(merged.P, want.P) {
+		t.Errorf("unexpected merge without base:\n\t(GOT): %v\n\t(WNT): %v", merged.P, want.P)
+	}
+	if wantc := []gps.ProjectRoot{"a", "b", "c"}; !reflect.DeepEqual(conflicts, wantc) {
+		t.Errorf("expected conflicts %v without base, got %v", wantc, conflicts)
+	}
+}
@@ -177,3 +177,179 @@ func TestReadLockErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestLockGoVersion(t *testing.T) {
+	l := &Lock{SolveMeta: SolveMeta{GoVersion: "1.11"}}
+
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `go-version = "1.11"`) {
+		t.Errorf("expected Go version in solve-meta, got:\n%s", got)
+	}
+
+	rl, err := readLock(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rl.SolveMeta.GoVersion != "1.11" {
+		t.Errorf("expected Go version %q after reading the lock, got %q", "1.11", rl.SolveMeta.GoVersion)
+	}
+}
+
+func TestLockUpdateStrategy(t *testing.T) {
+	l := &Lock{
+		SolveMeta: SolveMeta{
+			SolverName:     "gps-cdcl",
+			SolverVersion:  1,
+			UpdateStrategy: gps.SecurityOnly.String(),
+		},
+	}
+
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `update-strategy = "security-only"`) {
+		t.Errorf("expected update strategy in solve-meta, got:\n%s", got)
+	}
+
+	rl, err := readLock(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rl.SolveMeta.UpdateStrategy != l.SolveMeta.UpdateStrategy {
+		t.Errorf("expected update strategy %q after reading the lock, got %q", l.SolveMeta.UpdateStrategy, rl.SolveMeta.UpdateStrategy)
+	}
+
+	// The default strategy is left out.
+	l.SolveMeta.UpdateStrategy = ""
+	if got, _ = l.MarshalTOML(); strings.Contains(string(got), "update-strategy") {
+		t.Errorf("expected no update strategy in solve-meta, got:\n%s", got)
+	}
+}
+
+func TestLockSourceURL(t *testing.T) {
+	l := &Lock{
+		P: []gps.LockedProject{
+			verify.VerifiableProject{
+				LockedProject: gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("example.com/foo")},
+					gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+					[]string{"."},
+				),
+				SourceURL: "https://github.com/example/foo",
+			},
+		},
+	}
+
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `source-url = "https://github.com/example/foo"`) {
+		t.Errorf("expected source URL in project stanza, got:\n%s", got)
+	}
+
+	rl, err := readLock(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if surl := rl.P[0].(verify.VerifiableProject).SourceURL; surl != "https://github.com/example/foo" {
+		t.Errorf("expected source URL %q after reading the lock, got %q", "https://github.com/example/foo", surl)
+	}
+}
+
+func TestLockGenerated(t *testing.T) {
+	l := &Lock{
+		P: []gps.LockedProject{
+			verify.VerifiableProject{
+				LockedProject: gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("example.com/foo")},
+					gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+					[]string{"."},
+				),
+				Generated: []string{"zz_generated.go"},
+			},
+		},
+	}
+
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := readLock(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen := rl.P[0].(verify.VerifiableProject).Generated; !reflect.DeepEqual(gen, []string{"zz_generated.go"}) {
+		t.Errorf("expected the generated files to survive a round trip, got %v in:\n%s", gen, got)
+	}
+}
+
+func TestLockDecisions(t *testing.T) {
+	d := gps.Decision{Reason: gps.DecisionTightestConstraint, Constraint: "^1.0.0", By: "example.com/bar"}
+	l := &Lock{
+		P: []gps.LockedProject{
+			verify.VerifiableProject{
+				LockedProject: gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("example.com/foo")},
+					gps.NewVersion("v1.0.0").Pair("278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"),
+					[]string{"."},
+				),
+			},
+			verify.VerifiableProject{
+				LockedProject: gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("example.com/baz")},
+					gps.NewVersion("v2.0.0").Pair("d1a4ba26e5ac2bc1a61dd0e1f5a8e0b2e7ef7cbc"),
+					[]string{"."},
+				),
+			},
+		},
+		Decisions: map[gps.ProjectRoot]gps.Decision{"example.com/foo": d},
+	}
+
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(got), "decision =") != 1 {
+		t.Errorf("expected a decision for only one project, got:\n%s", got)
+	}
+	rl, err := readLock(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[gps.ProjectRoot]gps.Decision{"example.com/foo": d}; !reflect.DeepEqual(rl.Decisions, want) {
+		t.Errorf("expected the decisions to survive a round trip, got %v in:\n%s", rl.Decisions, got)
+	}
+}
+
+func TestLockDirectDependencies(t *testing.T) {
+	locked := func(pr string) gps.LockedProject {
+		return verify.VerifiableProject{
+			LockedProject: gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr)},
+				gps.Revision("d05d5aca9f895d19e9265839bffeadd74a2d2ecb"),
+				[]string{"."},
+			),
+		}
+	}
+	l := &Lock{
+		SolveMeta: SolveMeta{InputImports: []string{"github.com/foo/bar", "github.com/foo/bar/v2/sub", "github.com/foo/baz/cmd/baz", "github.com/not/locked"}},
+		P:         []gps.LockedProject{locked("github.com/foo/bar"), locked("github.com/foo/bar/v2"), locked("github.com/foo/baz"), locked("github.com/foo/qux")},
+	}
+
+	want := map[gps.ProjectRoot]bool{"github.com/foo/bar": true, "github.com/foo/bar/v2": true, "github.com/foo/baz": true}
+	if got := l.DirectDependencies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected direct dependencies:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	raw := l.toRaw()
+	for _, ld := range raw.Projects {
+		if ld.Direct != want[gps.ProjectRoot(ld.Name)] {
+			t.Errorf("expected %s to be written with direct = %v", ld.Name, want[gps.ProjectRoot(ld.Name)])
+		}
+	}
+}
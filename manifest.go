@@ -8,10 +8,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"path"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/pkgtree"
@@ -22,15 +26,31 @@ import (
 // ManifestName is the manifest file name used by dep.
 const ManifestName = "Gopkg.toml"
 
+// DefaultVendorDir is the directory, relative to the project root, into which
+// dependencies are vendored when the manifest does not specify vendor-dir.
+const DefaultVendorDir = "vendor"
+
 // Errors
 var (
 	errInvalidConstraint   = errors.Errorf("%q must be a TOML array of tables", "constraint")
 	errInvalidOverride     = errors.Errorf("%q must be a TOML array of tables", "override")
 	errInvalidRequired     = errors.Errorf("%q must be a TOML list of strings", "required")
 	errInvalidIgnored      = errors.Errorf("%q must be a TOML list of strings", "ignored")
+	errInvalidNoVerify     = errors.Errorf("%q must be a TOML list of strings", "noverify")
+	errInvalidFreeze       = errors.Errorf("%q must be a TOML list of strings", "freeze")
+	errInvalidTagsOnly     = errors.Errorf("%q must be a TOML list of strings", "tags-only")
 	errInvalidPrune        = errors.Errorf("%q must be a TOML table of booleans", "prune")
 	errInvalidPruneProject = errors.Errorf("%q must be a TOML array of tables", "prune.project")
 	errInvalidMetadata     = errors.New("metadata should be a TOML table")
+	errInvalidVendorDir    = errors.Errorf("%q must be a relative path within the project", "vendor-dir")
+	errInvalidImportRoot   = errors.Errorf("%q must be a non-empty, slash-separated import path", "import-root")
+	errInvalidPreferLocked = errors.Errorf("%q must be one of %q, %q or %q", "prefer-locked", "all", "direct", "none")
+	errInvalidGoVersion    = errors.Errorf("%q must be a minimum Go version, such as %q", "go", ">=1.11")
+	errInvalidGoSum        = errors.Errorf("%q must be a boolean", "go-sum")
+	errInvalidRecordDec    = errors.Errorf("%q must be a boolean", "record-decisions")
+	errInvalidToolTol      = errors.Errorf("%q must be one of %q, %q or %q", "tool-tolerance", ToolTolerateNone, ToolToleratePatch, ToolTolerateMinor)
+	errInvalidGenerated    = errors.Errorf("%q must be a TOML list of strings", "generated")
+	errInvalidScopedIgnore = errors.Errorf("%q must be a TOML array of tables", "scoped-ignore")
 
 	errInvalidProjectRoot = errors.New("ProjectRoot name validation failed")
 
@@ -40,6 +60,10 @@ var (
 	errRootPruneContainsName   = errors.Errorf("%q should not include a name", "prune")
 	errInvalidRootPruneValue   = errors.New("root prune options must be omitted instead of being set to false")
 	errInvalidPruneProjectName = errors.Errorf("%q in %q must be a string", "name", "prune.project")
+	errInvalidPruneAssets      = errors.Errorf("%q in %q must be a TOML list of strings", pruneOptionAssets, "prune.project")
+	errRootPruneAssets         = errors.Errorf("%q can only be set for a project in %q", pruneOptionAssets, "prune.project")
+	errInvalidPruneGenerated   = errors.Errorf("%q in %q must be a TOML list of strings", pruneOptionGenerated, "prune.project")
+	errRootPruneGenerated      = errors.Errorf("%q can only be set for a project in %q", pruneOptionGenerated, "prune.project")
 	errNoName                  = errors.New("no name provided")
 )
 
@@ -48,26 +72,140 @@ type Manifest struct {
 	Constraints gps.ProjectConstraints
 	Ovr         gps.ProjectConstraints
 
-	Ignored  []string
+	Ignored []string
+	// ScopedIgnores holds the rules ignoring packages only in some build
+	// contexts. Those that apply to BuildContext are ignored along with
+	// Ignored.
+	ScopedIgnores []gps.ScopedIgnore
+	// BuildContext is the build context being solved for, which the loader
+	// of the manifest sets.
+	BuildContext gps.BuildContext
+	// Required lists the packages to require, each of which may be followed
+	// by @ and the version to pin it to; see RequiredConstraints.
 	Required []string
 
+	// NoVerify lists the projects whose vendored code is known to have been
+	// modified by hand, so that a mismatch with their digests in the lock is
+	// reported without failing verification or causing them to be rewritten.
+	NoVerify []string
+
+	// Freeze lists the projects that are to be kept at the versions in the
+	// lock, such as fragile dependencies during a release stabilization
+	// period. They are not moved by ensure -update unless it is forced.
+	Freeze []string
+
+	// TagsOnly lists the projects that are only ever used at tagged releases,
+	// so that only their tags need be fetched from upstream. This keeps the
+	// cache small for enormous repositories, but their branches cannot be
+	// used, nor revisions that no tag reaches.
+	TagsOnly []string
+
 	PruneOptions gps.CascadingPruneOptions
+
+	// VendorDir is the slash-separated path, relative to the project root, of
+	// the directory into which dependencies are vendored. The empty string
+	// means DefaultVendorDir.
+	VendorDir string
+
+	// ImportRoot is the import path of the project. If set, it is used in
+	// preference to the import path implied by the project's location within
+	// GOPATH, which allows the project to live outside of any GOPATH.
+	ImportRoot gps.ProjectRoot
+
+	// LockPreference controls how strongly the versions in the lock are
+	// preferred to the newest allowed versions when solving.
+	LockPreference gps.LockPreference
+
+	// GoVersion is the minimum version of Go required by the project, such as
+	// "1.11". Versions of dependencies that require a newer Go are not used.
+	GoVersion string
+
+	// GoVersions holds the Go versions against which the requirements of
+	// individual projects are checked, in place of GoVersion.
+	GoVersions map[gps.ProjectRoot]string
+
+	// GoSum enables the maintenance of SumName, which records checksums of
+	// the vendored projects in the format of go.sum.
+	GoSum bool
+
+	// RecordDecisions has the lock record why each project was selected at
+	// its version, whenever it is solved.
+	RecordDecisions bool
+
+	// ToolTolerance is how far the versions of the version control tools
+	// may drift from those recorded in the lock before dep ensure -hermetic
+	// objects: one of the ToolTolerate constants. If it is empty, the versions
+	// are not recorded at all.
+	ToolTolerance string
+
+	// Generated holds the patterns of the project's generated files, which
+	// are left out of the digests of its vendored trees in the projects that
+	// depend on it; see gps.GeneratedFilesManifest.
+	Generated []string
+
+	// ConstraintMetadata holds the string values of the metadata tables of
+	// constraints, by project. dep ignores them, save for the reason key,
+	// which records why a dependency was added.
+	ConstraintMetadata map[gps.ProjectRoot]map[string]string
+
+	// OverrideExpiry holds the dates on which overrides, meant to be
+	// temporary, expire, by project. An expired override still applies, but
+	// dep check fails until it is renewed or removed.
+	OverrideExpiry map[gps.ProjectRoot]time.Time
+
+	// Metadata holds the string values of the manifest's own metadata table,
+	// such as references to the policies the project follows. dep ignores
+	// them.
+	Metadata map[string]string
 }
 
+// OverrideExpiryFormat is the layout of the dates on which overrides expire.
+const OverrideExpiryFormat = "2006-01-02"
+
+// MetadataReason is the constraint metadata key recording why a dependency was
+// added.
+const MetadataReason = "reason"
+
 type rawManifest struct {
-	Constraints  []rawProject    `toml:"constraint,omitempty"`
-	Overrides    []rawProject    `toml:"override,omitempty"`
-	Ignored      []string        `toml:"ignored,omitempty"`
-	Required     []string        `toml:"required,omitempty"`
-	PruneOptions rawPruneOptions `toml:"prune,omitempty"`
+	Constraints  []rawProject      `toml:"constraint,omitempty"`
+	Overrides    []rawProject      `toml:"override,omitempty"`
+	Ignored      []string          `toml:"ignored,omitempty"`
+	Required     []string          `toml:"required,omitempty"`
+	NoVerify     []string          `toml:"noverify,omitempty"`
+	Freeze       []string          `toml:"freeze,omitempty"`
+	TagsOnly     []string          `toml:"tags-only,omitempty"`
+	VendorDir    string            `toml:"vendor-dir,omitempty"`
+	ImportRoot   string            `toml:"import-root,omitempty"`
+	PreferLocked string            `toml:"prefer-locked,omitempty"`
+	GoVersion    string            `toml:"go,omitempty"`
+	GoSum        bool              `toml:"go-sum,omitempty"`
+	RecordDec    bool              `toml:"record-decisions,omitempty"`
+	ToolTol      string            `toml:"tool-tolerance,omitempty"`
+	Generated    []string          `toml:"generated,omitempty"`
+	PruneOptions rawPruneOptions   `toml:"prune,omitempty"`
+	ScopedIgnore []rawScopedIgnore `toml:"scoped-ignore,omitempty"`
+	// Metadata only ever holds string values; see stringMetadataOnly.
+	Metadata map[string]string `toml:"metadata,omitempty"`
 }
 
 type rawProject struct {
-	Name     string `toml:"name"`
-	Branch   string `toml:"branch,omitempty"`
-	Revision string `toml:"revision,omitempty"`
-	Version  string `toml:"version,omitempty"`
-	Source   string `toml:"source,omitempty"`
+	Name      string `toml:"name"`
+	Branch    string `toml:"branch,omitempty"`
+	Revision  string `toml:"revision,omitempty"`
+	Version   string `toml:"version,omitempty"`
+	Source    string `toml:"source,omitempty"`
+	GoVersion string `toml:"go,omitempty"`
+	// Expires is only valid for overrides; see Manifest.OverrideExpiry.
+	Expires string `toml:"expires,omitempty"`
+	// Metadata only ever holds string values; see stringMetadataOnly.
+	Metadata map[string]string `toml:"metadata,omitempty"`
+}
+
+type rawScopedIgnore struct {
+	Packages []string `toml:"packages"`
+	GOOS     []string `toml:"goos,omitempty"`
+	GOARCH   []string `toml:"goarch,omitempty"`
+	Tags     []string `toml:"tags,omitempty"`
 }
 
 type rawPruneOptions struct {
@@ -75,14 +213,26 @@ type rawPruneOptions struct {
 	NonGoFiles     bool `toml:"non-go,omitempty"`
 	GoTests        bool `toml:"go-tests,omitempty"`
 
-	//Projects []map[string]interface{} `toml:"project,omitempty"`
-	Projects []map[string]interface{}
+	Projects []rawPruneProject `toml:"project,omitempty"`
+}
+
+// rawPruneProject is a [[prune.project]] stanza. Its options are only written
+// when set, as they are tri-state; see fromRawPruneOptions.
+type rawPruneProject struct {
+	Name           string   `toml:"name"`
+	UnusedPackages *bool    `toml:"unused-packages,omitempty"`
+	NonGoFiles     *bool    `toml:"non-go,omitempty"`
+	GoTests        *bool    `toml:"go-tests,omitempty"`
+	Assets         []string `toml:"assets,omitempty"`
+	Generated      []string `toml:"generated,omitempty"`
 }
 
 const (
 	pruneOptionUnusedPackages = "unused-packages"
 	pruneOptionGoTests        = "go-tests"
 	pruneOptionNonGo          = "non-go"
+	pruneOptionAssets         = "assets"
+	pruneOptionGenerated      = "generated"
 )
 
 // Constants to represents per-project prune uint8 values.
@@ -95,8 +245,9 @@ const (
 // NewManifest instantites a new manifest.
 func NewManifest() *Manifest {
 	return &Manifest{
-		Constraints: make(gps.ProjectConstraints),
-		Ovr:         make(gps.ProjectConstraints),
+		Constraints:        make(gps.ProjectConstraints),
+		Ovr:                make(gps.ProjectConstraints),
+		ConstraintMetadata: make(map[gps.ProjectRoot]map[string]string),
 		PruneOptions: gps.CascadingPruneOptions{
 			DefaultOptions:    gps.PruneNestedVendorDirs,
 			PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{},
@@ -146,6 +297,13 @@ func validateManifest(s string) ([]error, error) {
 							case "name":
 							case "branch", "version", "source":
 								ruleProvided = true
+							case "go":
+								ruleProvided = true
+								if gv, ok := value.(string); !ok {
+									warns = append(warns, fmt.Errorf("go in %q should be a string", prop))
+								} else if _, err := gps.ParseGoVersion(gv); err != nil {
+									warns = append(warns, err)
+								}
 							case "revision":
 								ruleProvided = true
 								if valueStr, ok := value.(string); ok {
@@ -158,6 +316,12 @@ func validateManifest(s string) ([]error, error) {
 								if reflect.TypeOf(value).Kind() != reflect.Map {
 									warns = append(warns, fmt.Errorf("metadata in %q should be a TOML table", prop))
 								}
+							case "expires":
+								if prop != "override" {
+									warns = append(warns, fmt.Errorf("expires can only be set in %q", "override"))
+								} else if _, ok := value.(string); !ok {
+									warns = append(warns, fmt.Errorf("expires in %q should be a string", prop))
+								}
 							default:
 								// unknown/invalid key
 								warns = append(warns, fmt.Errorf("invalid key %q in %q", key, prop))
@@ -182,7 +346,7 @@ func validateManifest(s string) ([]error, error) {
 					return warns, errInvalidOverride
 				}
 			}
-		case "ignored", "required":
+		case "ignored", "required", "noverify", "freeze", "tags-only":
 			valid := true
 			if rawList, ok := val.([]interface{}); ok {
 				// Check element type of the array. TOML doesn't let mixing of types in
@@ -201,6 +365,80 @@ func validateManifest(s string) ([]error, error) {
 				if prop == "required" {
 					return warns, errInvalidRequired
 				}
+				if prop == "noverify" {
+					return warns, errInvalidNoVerify
+				}
+				if prop == "freeze" {
+					return warns, errInvalidFreeze
+				}
+				if prop == "tags-only" {
+					return warns, errInvalidTagsOnly
+				}
+			}
+		case "vendor-dir":
+			dir, ok := val.(string)
+			if !ok || !isValidVendorDir(dir) {
+				return warns, errInvalidVendorDir
+			}
+			if path.Base(dir) != DefaultVendorDir {
+				warns = append(warns, fmt.Errorf("vendor-dir %q is not named %q; the go tool will only resolve imports from it if it is placed on GOPATH by other means", dir, DefaultVendorDir))
+			}
+		case "import-root":
+			ir, ok := val.(string)
+			if !ok || !isValidImportRoot(ir) {
+				return warns, errInvalidImportRoot
+			}
+		case "prefer-locked":
+			pl, ok := val.(string)
+			if _, valid := parseLockPreference(pl); !ok || !valid {
+				return warns, errInvalidPreferLocked
+			}
+		case "go":
+			gv, ok := val.(string)
+			if !ok {
+				return warns, errInvalidGoVersion
+			}
+			if _, err := gps.ParseGoVersion(gv); err != nil {
+				return warns, errInvalidGoVersion
+			}
+		case "go-sum":
+			if _, ok := val.(bool); !ok {
+				return warns, errInvalidGoSum
+			}
+		case "record-decisions":
+			if _, ok := val.(bool); !ok {
+				return warns, errInvalidRecordDec
+			}
+		case "tool-tolerance":
+			switch val {
+			case ToolTolerateNone, ToolToleratePatch, ToolTolerateMinor:
+			default:
+				return warns, errInvalidToolTol
+			}
+		case "generated":
+			if err := validatePatterns(val, errInvalidGenerated, `"generated"`); err != nil {
+				return warns, err
+			}
+		case "scoped-ignore":
+			rules, ok := val.([]interface{})
+			if !ok {
+				return warns, errInvalidScopedIgnore
+			}
+			for _, rule := range rules {
+				props, ok := rule.(map[string]interface{})
+				if !ok {
+					return warns, errInvalidScopedIgnore
+				}
+				for key, value := range props {
+					switch key {
+					case "packages", "goos", "goarch", "tags":
+						if list, ok := value.([]interface{}); !ok || len(list) > 0 && reflect.TypeOf(list[0]).Kind() != reflect.String {
+							return warns, errors.Errorf("%q in %q must be a TOML list of strings", key, prop)
+						}
+					default:
+						warns = append(warns, fmt.Errorf("invalid key %q in %q", key, prop))
+					}
+				}
 			}
 		case "prune":
 			pruneWarns, err := validatePruneOptions(val, true)
@@ -216,6 +454,61 @@ func validateManifest(s string) ([]error, error) {
 	return warns, nil
 }
 
+// parseLockPreference parses the value of prefer-locked, reporting whether it
+// is valid. The empty string is the default, PreferLocked.
+func parseLockPreference(s string) (gps.LockPreference, bool) {
+	switch s {
+	case "", "all":
+		return gps.PreferLocked, true
+	case "direct":
+		return gps.PreferLockedDirect, true
+	case "none":
+		return gps.PreferNewest, true
+	}
+	return gps.PreferLocked, false
+}
+
+// isValidImportRoot checks that ir looks like a clean, slash-separated import
+// path.
+func isValidImportRoot(ir string) bool {
+	if ir == "" || ir == "." || strings.ContainsAny(ir, "\\ ") {
+		return false
+	}
+	return path.Clean(ir) == ir && !path.IsAbs(ir) && ir != ".." && !strings.HasPrefix(ir, "../")
+}
+
+// isValidVendorDir checks that dir is a clean, slash-separated relative path
+// that does not escape the project root.
+func isValidVendorDir(dir string) bool {
+	if dir == "" || dir == "." || path.IsAbs(dir) || filepath.IsAbs(dir) || strings.Contains(dir, "\\") {
+		return false
+	}
+	if path.Clean(dir) != dir {
+		return false
+	}
+	return dir != ".." && !strings.HasPrefix(dir, "../")
+}
+
+// validatePatterns checks that val is a list of valid file patterns, in the
+// syntax of pkgtree.AssetRuleset, returning errInvalid if it is not a list of
+// strings. The errors for invalid patterns name the field described by desc.
+func validatePatterns(val interface{}, errInvalid error, desc string) error {
+	patterns, ok := val.([]interface{})
+	if !ok {
+		return errInvalid
+	}
+	for _, p := range patterns {
+		ps, ok := p.(string)
+		if !ok {
+			return errInvalid
+		}
+		if err := pkgtree.ValidateAssetPattern(ps); err != nil {
+			return errors.Wrapf(err, "invalid %s", desc)
+		}
+	}
+	return nil
+}
+
 func validatePruneOptions(val interface{}, root bool) (warns []error, err error) {
 	if reflect.TypeOf(val).Kind() != reflect.Map {
 		return warns, errInvalidPrune
@@ -229,6 +522,20 @@ func validatePruneOptions(val interface{}, root bool) (warns []error, err error)
 			} else if root && !option {
 				return warns, errInvalidRootPruneValue
 			}
+		case pruneOptionAssets:
+			if root {
+				return warns, errRootPruneAssets
+			}
+			if err := validatePatterns(value, errInvalidPruneAssets, fmt.Sprintf("%q in %q", pruneOptionAssets, "prune.project")); err != nil {
+				return warns, err
+			}
+		case pruneOptionGenerated:
+			if root {
+				return warns, errRootPruneGenerated
+			}
+			if err := validatePatterns(value, errInvalidPruneGenerated, fmt.Sprintf("%q in %q", pruneOptionGenerated, "prune.project")); err != nil {
+				return warns, err
+			}
 		case "name":
 			if root {
 				warns = append(warns, errRootPruneContainsName)
@@ -290,7 +597,7 @@ func checkRedundantPruneOptions(co gps.CascadingPruneOptions) (warns []error) {
 // ValidateProjectRoots validates the project roots present in manifest.
 func ValidateProjectRoots(c *Ctx, m *Manifest, sm gps.SourceManager) error {
 	// Channel to receive all the errors
-	errorCh := make(chan error, len(m.Constraints)+len(m.Ovr))
+	errorCh := make(chan error, len(m.Constraints)+len(m.Ovr)+len(m.PruneOptions.PerProjectOptions)+len(m.NoVerify)+len(m.Freeze)+len(m.TagsOnly))
 
 	var wg sync.WaitGroup
 
@@ -316,6 +623,18 @@ func ValidateProjectRoots(c *Ctx, m *Manifest, sm gps.SourceManager) error {
 		wg.Add(1)
 		go validate(pr)
 	}
+	for _, pr := range m.NoVerify {
+		wg.Add(1)
+		go validate(gps.ProjectRoot(pr))
+	}
+	for _, pr := range m.Freeze {
+		wg.Add(1)
+		go validate(gps.ProjectRoot(pr))
+	}
+	for _, pr := range m.TagsOnly {
+		wg.Add(1)
+		go validate(gps.ProjectRoot(pr))
+	}
 
 	wg.Wait()
 	close(errorCh)
@@ -333,6 +652,12 @@ func ValidateProjectRoots(c *Ctx, m *Manifest, sm gps.SourceManager) error {
 	return valErr
 }
 
+// ReadManifest returns a Manifest read from r and a slice of validation
+// warnings. An error is returned if r does not hold a valid manifest.
+func ReadManifest(r io.Reader) (*Manifest, []error, error) {
+	return readManifest(r)
+}
+
 // readManifest returns a Manifest read from r and a slice of validation warnings.
 func readManifest(r io.Reader) (*Manifest, []error, error) {
 	buf := &bytes.Buffer{}
@@ -347,7 +672,11 @@ func readManifest(r io.Reader) (*Manifest, []error, error) {
 	}
 
 	raw := rawManifest{}
-	err = toml.Unmarshal(buf.Bytes(), &raw)
+	tree, err := toml.LoadBytes(buf.Bytes())
+	if err == nil {
+		stringMetadataOnly(tree)
+		err = tree.Unmarshal(&raw)
+	}
 	if err != nil {
 		return nil, warns, errors.Wrap(err, "unable to parse the manifest as TOML")
 	}
@@ -361,23 +690,97 @@ func readManifest(r io.Reader) (*Manifest, []error, error) {
 	return m, warns, nil
 }
 
+// stringMetadataOnly drops the values of the metadata tables of the manifest,
+// and of constraints and overrides, in tree that are not strings, so that the
+// rest can be decoded. dep has no use for other values, which are left as they
+// are in the manifest when it is edited.
+func stringMetadataOnly(tree *toml.Tree) {
+	stringValuesOnly(tree, "metadata")
+	for _, key := range []string{"constraint", "override"} {
+		projects, _ := tree.Get(key).([]*toml.Tree)
+		for _, project := range projects {
+			stringValuesOnly(project, "metadata")
+		}
+	}
+}
+
+// stringValuesOnly drops the values that are not strings from the table at key
+// in tree, if there is one.
+func stringValuesOnly(tree *toml.Tree, key string) {
+	md, ok := tree.Get(key).(*toml.Tree)
+	if !ok {
+		return
+	}
+	strs := make(map[string]interface{})
+	for _, k := range md.Keys() {
+		if v, ok := md.Get(k).(string); ok {
+			strs[k] = v
+		}
+	}
+	st, err := toml.TreeFromMap(strs)
+	if err == nil {
+		tree.Set(key, st)
+	}
+}
+
 func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 	m := NewManifest()
 
 	m.Constraints = make(gps.ProjectConstraints, len(raw.Constraints))
 	m.Ovr = make(gps.ProjectConstraints, len(raw.Overrides))
 	m.Ignored = raw.Ignored
+	for _, rsi := range raw.ScopedIgnore {
+		si := gps.ScopedIgnore{Packages: rsi.Packages, GOOS: rsi.GOOS, GOARCH: rsi.GOARCH, Tags: rsi.Tags}
+		if err := si.Validate(); err != nil {
+			return nil, err
+		}
+		m.ScopedIgnores = append(m.ScopedIgnores, si)
+	}
 	m.Required = raw.Required
+	for _, req := range m.Required {
+		if pkg, ver := splitRequired(req); pkg == "" || ver == "" && strings.Contains(req, "@") {
+			return nil, errors.Errorf("invalid required package %q: a version must follow the import path and @", req)
+		}
+	}
+	m.NoVerify = raw.NoVerify
+	m.Freeze = raw.Freeze
+	m.TagsOnly = raw.TagsOnly
+	m.VendorDir = raw.VendorDir
+	m.ImportRoot = gps.ProjectRoot(raw.ImportRoot)
+	m.LockPreference, _ = parseLockPreference(raw.PreferLocked)
+	m.GoSum = raw.GoSum
+	m.RecordDecisions = raw.RecordDec
+	m.ToolTolerance = raw.ToolTol
+	m.Generated = raw.Generated
+	if len(raw.Metadata) > 0 {
+		m.Metadata = raw.Metadata
+	}
+	if raw.GoVersion != "" {
+		gv, err := gps.ParseGoVersion(raw.GoVersion)
+		if err != nil {
+			return nil, err
+		}
+		m.GoVersion = gv
+	}
 
 	for i := 0; i < len(raw.Constraints); i++ {
 		name, prj, err := toProject(raw.Constraints[i])
 		if err != nil {
 			return nil, err
 		}
+		if err := m.setProjectGoVersion(name, raw.Constraints[i].GoVersion); err != nil {
+			return nil, err
+		}
+		if raw.Constraints[i].onlyGoVersion() {
+			continue
+		}
 		if _, exists := m.Constraints[name]; exists {
 			return nil, errors.Errorf("multiple dependencies specified for %s, can only specify one", name)
 		}
 		m.Constraints[name] = prj
+		if md := raw.Constraints[i].Metadata; len(md) > 0 {
+			m.ConstraintMetadata[name] = md
+		}
 	}
 
 	for i := 0; i < len(raw.Overrides); i++ {
@@ -385,10 +788,26 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 		if err != nil {
 			return nil, err
 		}
+		if err := m.setProjectGoVersion(name, raw.Overrides[i].GoVersion); err != nil {
+			return nil, err
+		}
+		if raw.Overrides[i].onlyGoVersion() {
+			continue
+		}
 		if _, exists := m.Ovr[name]; exists {
 			return nil, errors.Errorf("multiple overrides specified for %s, can only specify one", name)
 		}
 		m.Ovr[name] = prj
+		if exp := raw.Overrides[i].Expires; exp != "" {
+			t, err := time.Parse(OverrideExpiryFormat, exp)
+			if err != nil {
+				return nil, errors.Errorf("invalid expires %q for the override of %s; must be a date such as 2024-06-01", exp, name)
+			}
+			if m.OverrideExpiry == nil {
+				m.OverrideExpiry = make(map[gps.ProjectRoot]time.Time)
+			}
+			m.OverrideExpiry[name] = t
+		}
 	}
 
 	// TODO(sdboyer) it is awful that we have to do this manual extraction
@@ -408,6 +827,32 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 	return m, nil
 }
 
+// onlyGoVersion reports whether a constraint or override gives nothing but a
+// Go version for its project, in which case it constrains nothing else.
+func (raw rawProject) onlyGoVersion() bool {
+	return raw.GoVersion != "" && raw.Branch == "" && raw.Revision == "" && raw.Version == "" && raw.Source == "" && raw.Expires == ""
+}
+
+// setProjectGoVersion records the Go version given for a project in a
+// constraint or override, if any.
+func (m *Manifest) setProjectGoVersion(name gps.ProjectRoot, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if _, exists := m.GoVersions[name]; exists {
+		return errors.Errorf("multiple go versions specified for %s, can only specify one", name)
+	}
+	gv, err := gps.ParseGoVersion(raw)
+	if err != nil {
+		return errors.Wrapf(err, "invalid go version for %s", name)
+	}
+	if m.GoVersions == nil {
+		m.GoVersions = make(map[gps.ProjectRoot]string)
+	}
+	m.GoVersions[name] = gv
+	return nil
+}
+
 func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOptions {
 	opts := gps.CascadingPruneOptions{
 		DefaultOptions:    gps.PruneNestedVendorDirs,
@@ -435,6 +880,7 @@ func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOpti
 	if projprunes, has := prunemap["project"]; has {
 		for _, proj := range projprunes.([]interface{}) {
 			var pr gps.ProjectRoot
+			var assets, generated []string
 			// This should be redundant, but being explicit doesn't hurt.
 			pos := gps.PruneOptionSet{NestedVendor: pvtrue}
 
@@ -448,25 +894,70 @@ func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOpti
 					pos.GoTests = trinary(val)
 				case pruneOptionUnusedPackages:
 					pos.UnusedPackages = trinary(val)
+				case pruneOptionAssets:
+					for _, p := range val.([]interface{}) {
+						assets = append(assets, p.(string))
+					}
+				case pruneOptionGenerated:
+					for _, p := range val.([]interface{}) {
+						generated = append(generated, p.(string))
+					}
 				}
 			}
 			opts.PerProjectOptions[pr] = pos
+			if len(assets) > 0 {
+				if opts.Assets == nil {
+					opts.Assets = make(map[gps.ProjectRoot][]string)
+				}
+				opts.Assets[pr] = pkgtree.NewAssetRuleset(assets).ToSlice()
+			}
+			if len(generated) > 0 {
+				if opts.Generated == nil {
+					opts.Generated = make(map[gps.ProjectRoot][]string)
+				}
+				opts.Generated[pr] = pkgtree.NewAssetRuleset(generated).ToSlice()
+			}
 		}
 	}
 
 	return opts
 }
 
-// toRawPruneOptions converts a gps.RootPruneOption's PruneOptions to rawPruneOptions
-//
-// Will panic if gps.RootPruneOption includes ProjectPruneOptions
-// See https://github.com/golang/dep/pull/1460#discussion_r158128740 for more information
+// toRawPruneOptions converts a gps.CascadingPruneOptions to rawPruneOptions,
+// writing a [[prune.project]] stanza for each project with options of its own.
 func toRawPruneOptions(co gps.CascadingPruneOptions) rawPruneOptions {
-	if len(co.PerProjectOptions) != 0 {
-		panic("toRawPruneOptions cannot convert ProjectOptions to rawPruneOptions")
-	}
 	raw := rawPruneOptions{}
 
+	roots := make(map[gps.ProjectRoot]bool)
+	for pr := range co.PerProjectOptions {
+		roots[pr] = true
+	}
+	for pr := range co.Assets {
+		roots[pr] = true
+	}
+	for pr := range co.Generated {
+		roots[pr] = true
+	}
+	trinary := func(pv uint8) *bool {
+		if pv == pvnone {
+			return nil
+		}
+		b := pv == pvtrue
+		return &b
+	}
+	for pr := range roots {
+		pos := co.PerProjectOptions[pr]
+		raw.Projects = append(raw.Projects, rawPruneProject{
+			Name:           string(pr),
+			UnusedPackages: trinary(pos.UnusedPackages),
+			NonGoFiles:     trinary(pos.NonGoFiles),
+			GoTests:        trinary(pos.GoTests),
+			Assets:         co.Assets[pr],
+			Generated:      co.Generated[pr],
+		})
+	}
+	sort.Slice(raw.Projects, func(i, j int) bool { return raw.Projects[i].Name < raw.Projects[j].Name })
+
 	if (co.DefaultOptions & gps.PruneUnusedPackages) != 0 {
 		raw.UnusedPackages = true
 	}
@@ -532,16 +1023,51 @@ func (m *Manifest) toRaw() rawManifest {
 		Overrides:   make([]rawProject, 0, len(m.Ovr)),
 		Ignored:     m.Ignored,
 		Required:    m.Required,
+		NoVerify:    m.NoVerify,
+		Freeze:      m.Freeze,
+		TagsOnly:    m.TagsOnly,
+		VendorDir:   m.VendorDir,
+		ImportRoot:  string(m.ImportRoot),
+		GoVersion:   m.GoVersion,
+		GoSum:       m.GoSum,
+		RecordDec:   m.RecordDecisions,
+		ToolTol:     m.ToolTolerance,
+		Generated:   m.Generated,
+		Metadata:    m.Metadata,
+	}
+	if m.LockPreference != gps.PreferLocked {
+		raw.PreferLocked = m.LockPreference.String()
+	}
+	for _, si := range m.ScopedIgnores {
+		raw.ScopedIgnore = append(raw.ScopedIgnore, rawScopedIgnore{Packages: si.Packages, GOOS: si.GOOS, GOARCH: si.GOARCH, Tags: si.Tags})
 	}
 
 	for n, prj := range m.Constraints {
-		raw.Constraints = append(raw.Constraints, toRawProject(n, prj))
+		rp := toRawProject(n, prj)
+		if _, has := m.Ovr[n]; !has {
+			rp.GoVersion = m.GoVersions[n]
+		}
+		rp.Metadata = m.ConstraintMetadata[n]
+		raw.Constraints = append(raw.Constraints, rp)
 	}
-	sort.Sort(sortedRawProjects(raw.Constraints))
 
 	for n, prj := range m.Ovr {
-		raw.Overrides = append(raw.Overrides, toRawProject(n, prj))
+		rp := toRawProject(n, prj)
+		rp.GoVersion = m.GoVersions[n]
+		if exp, has := m.OverrideExpiry[n]; has {
+			rp.Expires = exp.Format(OverrideExpiryFormat)
+		}
+		raw.Overrides = append(raw.Overrides, rp)
 	}
+
+	// Go versions are written on the constraint or override for the project,
+	// or else on a constraint that gives nothing but the Go version.
+	for n, gv := range m.GoVersions {
+		if !m.HasConstraintsOn(n) {
+			raw.Constraints = append(raw.Constraints, rawProject{Name: string(n), GoVersion: gv})
+		}
+	}
+	sort.Sort(sortedRawProjects(raw.Constraints))
 	sort.Sort(sortedRawProjects(raw.Overrides))
 
 	raw.PruneOptions = toRawPruneOptions(m.PruneOptions)
@@ -607,12 +1133,62 @@ func (m *Manifest) Overrides() gps.ProjectConstraints {
 	return m.Ovr
 }
 
-// IgnoredPackages returns a set of import paths to ignore.
+// IgnoredPackages returns a set of import paths to ignore: those in Ignored,
+// and those of the ScopedIgnores that apply to BuildContext.
 func (m *Manifest) IgnoredPackages() *pkgtree.IgnoredRuleset {
 	if m == nil {
 		return pkgtree.NewIgnoredRuleset(nil)
 	}
-	return pkgtree.NewIgnoredRuleset(m.Ignored)
+	return gps.IgnoredRulesetFor(m.Ignored, m.ScopedIgnores, m.BuildContext)
+}
+
+// InputBuildContext returns the parts of BuildContext that the ScopedIgnores
+// depend on, in the form recorded in the lock, or the empty string if there are
+// no ScopedIgnores.
+func (m *Manifest) InputBuildContext() string {
+	if m == nil || len(m.ScopedIgnores) == 0 {
+		return ""
+	}
+	return m.BuildContext.RelevantTo(m.ScopedIgnores).String()
+}
+
+// MinGoVersion returns the minimum Go version required by the project.
+func (m *Manifest) MinGoVersion() string {
+	if m == nil {
+		return ""
+	}
+	return m.GoVersion
+}
+
+// ProjectGoVersions returns the Go versions against which the requirements of
+// individual projects are checked, in place of MinGoVersion.
+func (m *Manifest) ProjectGoVersions() map[gps.ProjectRoot]string {
+	if m == nil {
+		return nil
+	}
+	return m.GoVersions
+}
+
+// GeneratedFiles returns the patterns of the project's generated files.
+func (m *Manifest) GeneratedFiles() []string {
+	if m == nil {
+		return nil
+	}
+	return m.Generated
+}
+
+// ExpiredOverrides returns the projects, in order, whose overrides expired on or
+// before the day of now.
+func (m *Manifest) ExpiredOverrides(now time.Time) []gps.ProjectRoot {
+	today := now.Format(OverrideExpiryFormat)
+	var expired []gps.ProjectRoot
+	for pr, exp := range m.OverrideExpiry {
+		if _, has := m.Ovr[pr]; has && exp.Format(OverrideExpiryFormat) <= today {
+			expired = append(expired, pr)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	return expired
 }
 
 // HasConstraintsOn checks if the manifest contains either constraints or
@@ -628,6 +1204,15 @@ func (m *Manifest) HasConstraintsOn(root gps.ProjectRoot) bool {
 	return false
 }
 
+// VendorPath returns the os-specific path, relative to the project root, of
+// the directory into which dependencies are vendored.
+func (m *Manifest) VendorPath() string {
+	if m == nil || m.VendorDir == "" {
+		return DefaultVendorDir
+	}
+	return filepath.FromSlash(m.VendorDir)
+}
+
 // RequiredPackages returns a set of import paths to require.
 func (m *Manifest) RequiredPackages() map[string]bool {
 	if m == nil || m == (*Manifest)(nil) {
@@ -640,8 +1225,41 @@ func (m *Manifest) RequiredPackages() map[string]bool {
 
 	mp := make(map[string]bool, len(m.Required))
 	for _, i := range m.Required {
-		mp[i] = true
+		pkg, _ := splitRequired(i)
+		mp[pkg] = true
 	}
 
 	return mp
 }
+
+// RequiredConstraints returns the versions to which required packages are
+// pinned, by import path. A package is pinned by following it in the required
+// list with @ and a version, in the same syntax as the version of a
+// constraint, as in "github.com/golang/protobuf/protoc-gen-go@1.1.0". This
+// lets a main package, such as a code generator, be pinned independently of
+// the constraint on its project, to the extent the solver allows; see
+// gps.RequiredConstraintsManifest.
+func (m *Manifest) RequiredConstraints() map[string]gps.Constraint {
+	rc := make(map[string]gps.Constraint)
+	for _, req := range m.Required {
+		pkg, ver := splitRequired(req)
+		if ver == "" {
+			continue
+		}
+		c, err := gps.NewSemverConstraintIC(ver)
+		if err != nil {
+			c = gps.NewVersion(ver)
+		}
+		rc[pkg] = c
+	}
+	return rc
+}
+
+// splitRequired splits an entry of the required list into the import path of
+// the package and the version it is pinned to, if any.
+func splitRequired(req string) (pkg, version string) {
+	if i := strings.Index(req, "@"); i >= 0 {
+		return req[:i], req[i+1:]
+	}
+	return req, ""
+}
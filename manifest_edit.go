@@ -0,0 +1,524 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// RewriteTOML returns the manifest encoded as TOML, written as an edit of
+// orig, the current contents of the manifest file, rather than from scratch.
+// Comments, formatting and the order of stanzas are kept; only the keys,
+// stanzas and tables that differ in meaning from orig are replaced, removed or
+// added. New stanzas are placed after the existing ones that would precede
+// them in the order dep writes. In arrays of strings written one item per
+// line, such as ignored, the comments of the items kept are kept with them.
+//
+// If orig is not a valid manifest, or the edit cannot be made faithfully, the
+// manifest is encoded as MarshalTOML would.
+func (m *Manifest) RewriteTOML(orig []byte) ([]byte, error) {
+	want, err := m.MarshalTOML()
+	if err != nil {
+		return nil, err
+	}
+
+	om, _, err := readManifest(bytes.NewReader(orig))
+	if err != nil {
+		return want, nil
+	}
+	have, err := om.MarshalTOML()
+	if err != nil {
+		return want, nil
+	}
+	if bytes.Equal(have, want) {
+		return orig, nil
+	}
+
+	out := editManifest(orig, have, want)
+
+	// Check that the edit means what a fresh encoding would.
+	em, _, err := readManifest(bytes.NewReader(out))
+	if err != nil {
+		return want, nil
+	}
+	if got, err := em.MarshalTOML(); err != nil || !bytes.Equal(got, want) {
+		return want, nil
+	}
+	return out, nil
+}
+
+// manifestUnit is a span of the lines of a manifest file that can be edited
+// on its own: the root table, a table, or an element of an array of tables
+// along with its sub-tables.
+type manifestUnit struct {
+	// id identifies the unit across encodings of a manifest: empty for the
+	// root table, the header for a table, and the header followed by the
+	// name for an element of an array of tables.
+	id   string
+	kind string
+	// start and end delimit the unit's lines, including the comments
+	// directly above its header and any blank lines following it.
+	start, end int
+	header     int
+	// entries holds the key/value pairs of the unit, including those of its
+	// sub-tables, whose keys are prefixed with the sub-table's name.
+	entries   []manifestUnitEntry
+	subtables []manifestSubtable
+}
+
+// manifestUnitEntry is a key/value pair of a unit, which may span several
+// lines.
+type manifestUnitEntry struct {
+	key        string
+	start, end int
+}
+
+// manifestSubtable is a sub-table of an element of an array of tables, such as
+// [constraint.metadata], named by the part of its name after the array's.
+type manifestSubtable struct {
+	name   string
+	header int
+}
+
+func (u *manifestUnit) entry(key string) *manifestUnitEntry {
+	for i := range u.entries {
+		if u.entries[i].key == key {
+			return &u.entries[i]
+		}
+	}
+	return nil
+}
+
+func (u *manifestUnit) subtable(name string) *manifestSubtable {
+	for i := range u.subtables {
+		if u.subtables[i].name == name {
+			return &u.subtables[i]
+		}
+	}
+	return nil
+}
+
+// section returns the name of the sub-table that the entry with key is in, or
+// the empty string for the unit's own table.
+func section(key string) string {
+	if i := strings.Index(key, "."); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// manifestText holds the lines of a manifest file, split into units.
+type manifestText struct {
+	lines []string
+	units []*manifestUnit
+	byID  map[string]*manifestUnit
+}
+
+func splitManifestUnits(b []byte) *manifestText {
+	s := strings.TrimSuffix(strings.Replace(string(b), "\r\n", "\n", -1), "\n")
+	mt := &manifestText{byID: make(map[string]*manifestUnit)}
+	if s != "" {
+		mt.lines = strings.Split(s, "\n")
+	}
+
+	cur := &manifestUnit{header: -1}
+	mt.units = append(mt.units, cur)
+	sub := ""
+	// lead is the first line of the run of comments above the current
+	// line, or -1.
+	lead := -1
+	for i := 0; i < len(mt.lines); i++ {
+		line := strings.TrimSpace(mt.lines[i])
+		switch {
+		case line == "":
+			lead = -1
+			continue
+		case strings.HasPrefix(line, "#"):
+			if lead < 0 {
+				lead = i
+			}
+			continue
+		}
+		start := i
+		if lead >= 0 {
+			start = lead
+		}
+		lead = -1
+
+		if strings.HasPrefix(line, "[") {
+			body, _ := splitInlineComment(line)
+			array := strings.HasPrefix(body, "[[")
+			name := strings.TrimSpace(strings.Trim(body, "[]"))
+			if cur.header >= 0 && strings.HasPrefix(cur.id, "[[") && strings.HasPrefix(name, cur.kind+".") {
+				sub = strings.TrimPrefix(name, cur.kind+".")
+				cur.subtables = append(cur.subtables, manifestSubtable{name: sub, header: i})
+				continue
+			}
+			sub = ""
+			cur.end = start
+			cur = &manifestUnit{kind: name, start: start, header: i}
+			if array {
+				cur.id = "[[" + name + "]]"
+			} else {
+				cur.id = "[" + name + "]"
+			}
+			mt.units = append(mt.units, cur)
+			continue
+		}
+
+		end := arrayEnd(mt.lines, i)
+		key := strings.TrimSpace(line[:strings.Index(line+"=", "=")])
+		if sub != "" {
+			key = sub + "." + key
+		}
+		cur.entries = append(cur.entries, manifestUnitEntry{key: key, start: i, end: end + 1})
+		if key == "name" && strings.HasPrefix(cur.id, "[[") {
+			value, _ := splitInlineComment(line[strings.Index(line, "=")+1:])
+			if name, err := strconv.Unquote(value); err == nil {
+				cur.id += " " + name
+			}
+		}
+		i = end
+	}
+	cur.end = len(mt.lines)
+
+	for _, u := range mt.units {
+		id := u.id
+		for n := 2; mt.byID[id] != nil; n++ {
+			id = u.id + "#" + strconv.Itoa(n)
+		}
+		u.id = id
+		mt.byID[id] = u
+	}
+	return mt
+}
+
+// arrayEnd returns the index of the last line of the entry starting at
+// lines[i], which is later than i only for an array spanning several lines.
+func arrayEnd(lines []string, i int) int {
+	depth := 0
+	for j := i; j < len(lines); j++ {
+		s, _ := splitInlineComment(lines[j])
+		inString := false
+		for k := 0; k < len(s); k++ {
+			switch c := s[k]; {
+			case c == '\\' && inString:
+				k++
+			case c == '"':
+				inString = !inString
+			case c == '[' && !inString:
+				depth++
+			case c == ']' && !inString:
+				depth--
+			}
+		}
+		if depth <= 0 {
+			return j
+		}
+	}
+	return len(lines) - 1
+}
+
+// text returns the lines from start to end, stripped of indentation, for
+// comparison across encodings.
+func (mt *manifestText) text(start, end int) string {
+	var parts []string
+	for _, l := range mt.lines[start:end] {
+		parts = append(parts, strings.TrimSpace(l))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// editManifest edits orig, a manifest file that dep encodes as have, into one
+// that it would encode as want.
+func editManifest(orig, have, want []byte) []byte {
+	o, h, w := splitManifestUnits(orig), splitManifestUnits(have), splitManifestUnits(want)
+
+	// Each new unit is placed after the original unit that is closest
+	// before it in the desired encoding.
+	after := make(map[string][]*manifestUnit)
+	prev := ""
+	for _, u := range w.units {
+		if o.byID[u.id] != nil {
+			prev = u.id
+			continue
+		}
+		after[prev] = append(after[prev], u)
+	}
+
+	var out []string
+	for _, u := range o.units {
+		hu, wu := h.byID[u.id], w.byID[u.id]
+		switch {
+		case hu == nil:
+			// Not a part of the manifest dep knows about.
+			out = append(out, o.lines[u.start:u.end]...)
+		case wu == nil:
+			// Removed.
+		default:
+			out = append(out, editManifestUnit(o, h, w, u, hu, wu)...)
+		}
+
+		if added := after[u.id]; len(added) > 0 {
+			out = insertManifestUnits(out, w, added, u.end < len(o.lines))
+		}
+	}
+
+	// Keep the original number of trailing blank lines.
+	trailing := 0
+	for i := len(o.lines) - 1; i >= 0 && strings.TrimSpace(o.lines[i]) == ""; i-- {
+		trailing++
+	}
+	for len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+		out = out[:len(out)-1]
+	}
+	for ; trailing > 0; trailing-- {
+		out = append(out, "")
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(out, "\n") + "\n")
+}
+
+// editManifestUnit returns the lines of u, a unit of o, with the entries that
+// differ between h and w replaced, removed or added.
+func editManifestUnit(o, h, w *manifestText, u, hu, wu *manifestUnit) []string {
+	// The edits to make, by line of o: the replacement for the entry that
+	// starts there, whether the line is dropped, and the lines to insert
+	// after it. Lines inserted after u.start-1 go first.
+	repl := make(map[int][]string)
+	drop := make(map[int]bool)
+	insert := make(map[int][]string)
+
+	// Where the new entries of each section go: after the last of its
+	// original entries, or else after its header.
+	after := make(map[string]int)
+	indent := make(map[string]string)
+	if u.header >= 0 {
+		after[""] = u.header
+	}
+	for _, st := range u.subtables {
+		after[st.name] = st.header
+	}
+
+	kept := make(map[string]bool)
+	for _, e := range u.entries {
+		sec := section(e.key)
+		after[sec] = e.end - 1
+		indent[sec] = leadingSpace(o.lines[e.start])
+
+		he, we := hu.entry(e.key), wu.entry(e.key)
+		if he == nil || we != nil && h.text(he.start, he.end) == w.text(we.start, we.end) {
+			kept[sec] = true
+			continue
+		}
+		for i := e.start; i < e.end; i++ {
+			drop[i] = true
+		}
+		if we == nil {
+			continue
+		}
+		kept[sec] = true
+		lines := reindent(w.lines[we.start:we.end], indent[sec])
+		if items, ok := editArrayItems(o.lines[e.start:e.end], w.lines[we.start:we.end]); ok {
+			lines = items
+		}
+		// Keep a comment on the same line as a single-line value.
+		if e.end-e.start == 1 && len(lines) == 1 {
+			if _, inline := splitInlineComment(o.lines[e.start]); inline != "" {
+				lines[0] += " " + inline
+			}
+		}
+		repl[e.start] = lines
+	}
+	// Drop the headers of sub-tables left empty.
+	for _, st := range u.subtables {
+		if !kept[st.name] && wu.subtable(st.name) == nil {
+			drop[st.header] = true
+		}
+	}
+
+	// The end of the unit's content, before any blank lines that follow it.
+	last := u.end - 1
+	for last >= u.start && strings.TrimSpace(o.lines[last]) == "" {
+		last--
+	}
+
+	var root []string
+	for _, we := range wu.entries {
+		if u.entry(we.key) != nil {
+			continue
+		}
+		sec := section(we.key)
+		lines := w.lines[we.start:we.end]
+		if _, has := indent[sec]; !has {
+			indent[sec] = leadingSpace(lines[0])
+		}
+		lines = reindent(lines, indent[sec])
+
+		pos, has := after[sec]
+		switch {
+		case has:
+		case sec == "":
+			// The root table has no keys yet: place them after
+			// any leading comments.
+			root = append(root, lines...)
+			continue
+		default:
+			// A new sub-table, placed at the end of the unit.
+			pos = last
+			after[sec] = pos
+			insert[pos] = append(insert[pos], "", w.lines[wu.subtable(sec).header])
+		}
+		insert[pos] = append(insert[pos], lines...)
+	}
+	if len(root) > 0 {
+		if last >= u.start {
+			root = append([]string{""}, root...)
+		}
+		if last == u.end-1 && u.end < len(o.lines) {
+			root = append(root, "")
+		}
+		insert[last] = append(root, insert[last]...)
+	}
+
+	out := append([]string(nil), insert[u.start-1]...)
+	for i := u.start; i < u.end; i++ {
+		if lines, has := repl[i]; has {
+			out = append(out, lines...)
+		} else if !drop[i] {
+			out = append(out, o.lines[i])
+		}
+		out = append(out, insert[i]...)
+	}
+	return out
+}
+
+// insertManifestUnits appends the units added, taken from w, to out, keeping a
+// blank line between them and whatever surrounds them. more is whether any
+// lines follow out in the file.
+func insertManifestUnits(out []string, w *manifestText, added []*manifestUnit, more bool) []string {
+	// Insert before the blank lines that end the preceding unit.
+	end := len(out)
+	for end > 0 && strings.TrimSpace(out[end-1]) == "" {
+		end--
+	}
+	tail := append([]string(nil), out[end:]...)
+	out = out[:end]
+
+	for _, u := range added {
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		lines := w.lines[u.start:u.end]
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		out = append(out, lines...)
+	}
+
+	if len(tail) == 0 && more {
+		tail = []string{""}
+	}
+	return append(out, tail...)
+}
+
+// reindent replaces the indentation of lines, relative to the first of them,
+// with indent.
+// editArrayItems returns orig, an array of strings written one item per line,
+// edited to hold the items of want, an encoding of the array, in their order.
+// The lines of the items kept, along with the comments directly above them,
+// are kept as they are; the comments of the items removed are lost. It
+// returns false if either is not written that way.
+func editArrayItems(orig, want []string) ([]string, bool) {
+	if len(orig) < 2 {
+		return nil, false
+	}
+	head, tail := orig[0], orig[len(orig)-1]
+	if body, _ := splitInlineComment(head); !strings.HasSuffix(body, "[") {
+		return nil, false
+	}
+	if body, _ := splitInlineComment(tail); body != "]" {
+		return nil, false
+	}
+
+	// The lines of each original item, by value, and the comments after the
+	// last of them.
+	items := make(map[string][]string)
+	indent := leadingSpace(head) + "  "
+	// Whether the last item is followed by a comma, as the new ones are then.
+	trailingComma := false
+	var pending []string
+	for _, l := range orig[1 : len(orig)-1] {
+		body, _ := splitInlineComment(l)
+		if body == "" {
+			pending = append(pending, l)
+			continue
+		}
+		value, err := strconv.Unquote(strings.TrimSuffix(body, ","))
+		if err != nil {
+			return nil, false
+		}
+		items[value] = append(pending, l)
+		indent = leadingSpace(l)
+		trailingComma = strings.HasSuffix(body, ",")
+		pending = nil
+	}
+
+	tree, err := toml.Load(strings.Join(want, "\n"))
+	if err != nil || len(tree.Keys()) != 1 {
+		return nil, false
+	}
+	values, ok := tree.Get(tree.Keys()[0]).([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	out := []string{head}
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		lines, has := items[s]
+		if !has {
+			lines = []string{indent + strconv.Quote(s)}
+			if trailingComma {
+				lines[0] += ","
+			}
+		}
+		// Every item but the last needs a separating comma.
+		last := len(lines) - 1
+		body, comment := splitInlineComment(lines[last])
+		if i < len(values)-1 && !strings.HasSuffix(body, ",") {
+			lines = append([]string(nil), lines...)
+			lines[last] = leadingSpace(lines[last]) + body + ","
+			if comment != "" {
+				lines[last] += " " + comment
+			}
+		}
+		out = append(out, lines...)
+	}
+	out = append(out, pending...)
+	return append(out, tail), true
+}
+
+func reindent(lines []string, indent string) []string {
+	base := leadingSpace(lines[0])
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = indent + strings.TrimPrefix(l, base)
+	}
+	return out
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
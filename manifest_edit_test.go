@@ -0,0 +1,271 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestManifestRewriteTOML(t *testing.T) {
+	const orig = `# Project-wide settings.
+required = ["github.com/golang/lint/golint"] # for CI
+
+# Pinned until the v2 API settles.
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0" # keep in step with deptestdos
+
+[[constraint]]
+  # Only used for the examples.
+  name = "github.com/sdboyer/deptestdos"
+  branch = "master"
+
+[prune]
+  go-tests = true
+`
+
+	cases := map[string]struct {
+		edit func(*Manifest)
+		want string
+	}{
+		"unchanged": {
+			edit: func(m *Manifest) {},
+			want: orig,
+		},
+		"changed version": {
+			edit: func(m *Manifest) {
+				pp := m.Constraints["github.com/sdboyer/deptest"]
+				pp.Constraint, _ = gps.NewSemverConstraintIC("1.1.0")
+				m.Constraints["github.com/sdboyer/deptest"] = pp
+			},
+			want: `# Project-wide settings.
+required = ["github.com/golang/lint/golint"] # for CI
+
+# Pinned until the v2 API settles.
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.1.0" # keep in step with deptestdos
+
+[[constraint]]
+  # Only used for the examples.
+  name = "github.com/sdboyer/deptestdos"
+  branch = "master"
+
+[prune]
+  go-tests = true
+`,
+		},
+		"added constraint and source": {
+			edit: func(m *Manifest) {
+				m.Constraints["github.com/sdboyer/deptestdos"] = gps.ProjectProperties{
+					Source:     "github.com/carolynvs/deptestdos",
+					Constraint: gps.NewBranch("master"),
+				}
+				m.Constraints["github.com/sdboyer/deptesttres"] = gps.ProjectProperties{
+					Constraint: gps.NewBranch("master"),
+				}
+			},
+			want: `# Project-wide settings.
+required = ["github.com/golang/lint/golint"] # for CI
+
+# Pinned until the v2 API settles.
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0" # keep in step with deptestdos
+
+[[constraint]]
+  # Only used for the examples.
+  name = "github.com/sdboyer/deptestdos"
+  branch = "master"
+  source = "github.com/carolynvs/deptestdos"
+
+[[constraint]]
+  branch = "master"
+  name = "github.com/sdboyer/deptesttres"
+
+[prune]
+  go-tests = true
+`,
+		},
+		"removed constraint and required": {
+			edit: func(m *Manifest) {
+				delete(m.Constraints, "github.com/sdboyer/deptest")
+				m.Required = nil
+			},
+			want: `# Project-wide settings.
+
+[[constraint]]
+  # Only used for the examples.
+  name = "github.com/sdboyer/deptestdos"
+  branch = "master"
+
+[prune]
+  go-tests = true
+`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, _, err := readManifest(bytes.NewBufferString(orig))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.edit(m)
+
+			got, err := m.RewriteTOML([]byte(orig))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestManifestRewriteTOMLAddsRootKeys(t *testing.T) {
+	const orig = `# A comment about the project.
+
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+`
+	m, _, err := readManifest(bytes.NewBufferString(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Ignored = []string{"github.com/sdboyer/deptest/foo"}
+
+	got, err := m.RewriteTOML([]byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# A comment about the project.
+
+ignored = ["github.com/sdboyer/deptest/foo"]
+
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+`
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
+
+func TestManifestRewriteTOMLMetadata(t *testing.T) {
+	const orig = `[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+
+  [constraint.metadata]
+    owner = "platform" # the platform team
+    priority = 3
+
+[[constraint]]
+  name = "github.com/sdboyer/deptestdos"
+  version = "2.0.0"
+`
+	m, _, err := readManifest(bytes.NewBufferString(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ConstraintMetadata["github.com/sdboyer/deptest"]; len(got) != 1 || got["owner"] != "platform" {
+		t.Fatalf("expected only the string metadata to be read, got %v", got)
+	}
+	m.ConstraintMetadata["github.com/sdboyer/deptest"][MetadataReason] = "used by the tests"
+	m.ConstraintMetadata["github.com/sdboyer/deptestdos"] = map[string]string{MetadataReason: "used by the examples"}
+
+	got, err := m.RewriteTOML([]byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+
+  [constraint.metadata]
+    owner = "platform" # the platform team
+    priority = 3
+    reason = "used by the tests"
+
+[[constraint]]
+  name = "github.com/sdboyer/deptestdos"
+  version = "2.0.0"
+
+  [constraint.metadata]
+    reason = "used by the examples"
+`
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
+
+func TestManifestRewriteTOMLProjectPruneOptions(t *testing.T) {
+	const orig = `[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+
+[prune]
+  go-tests = true
+
+  # Its tests are needed by ours.
+  [[prune.project]]
+    name = "github.com/sdboyer/deptest"
+    go-tests = false
+`
+	m, _, err := readManifest(bytes.NewBufferString(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp := m.Constraints["github.com/sdboyer/deptest"]
+	pp.Constraint, _ = gps.NewSemverConstraintIC("1.1.0")
+	m.Constraints["github.com/sdboyer/deptest"] = pp
+
+	got, err := m.RewriteTOML([]byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(orig, `"1.0.0"`, `"1.1.0"`, 1)
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
+
+func TestManifestRewriteTOMLArrayComments(t *testing.T) {
+	const orig = `ignored = [
+  # Generated code is vendored on its own.
+  "github.com/foo/bar/gen",
+  "github.com/foo/bar/internal", # until it is split out
+  # Only used for the examples.
+  "github.com/foo/bar/examples",
+]
+`
+	m, _, err := readManifest(bytes.NewBufferString(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Ignored = []string{"github.com/foo/bar/gen", "github.com/foo/bar/internal", "github.com/foo/bar/tools"}
+
+	got, err := m.RewriteTOML([]byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `ignored = [
+  # Generated code is vendored on its own.
+  "github.com/foo/bar/gen",
+  "github.com/foo/bar/internal", # until it is split out
+  "github.com/foo/bar/tools",
+]
+`
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
@@ -0,0 +1,535 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// FormatManifest returns the canonical form of the manifest in b:
+//
+//  - [[constraint]], [[override]] and [[prune.project]] stanzas are sorted by
+//    name, and stanzas that duplicate an earlier one exactly are dropped;
+//  - the keys of each table are sorted;
+//  - the entries of required and ignored are sorted and deduplicated;
+//  - the version, branch, revision, source and name of each rule are trimmed
+//    of whitespace, and versions are written in the form dep writes them;
+//  - indentation and blank lines follow the layout of manifests written by
+//    dep itself.
+//
+// Comments are kept with the stanza, key or list entry that directly follows
+// them, or failing that with the one they follow. An error is returned if the
+// result is not a valid manifest.
+func FormatManifest(b []byte) ([]byte, error) {
+	tables, err := parseManifestTables(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeManifestTables(&buf, arrangeManifestTables(tables))
+
+	// Comment groups are written with a blank line on either side, which
+	// may leave several in a row.
+	out := buf.Bytes()
+	for bytes.Contains(out, []byte("\n\n\n")) {
+		out = bytes.Replace(out, []byte("\n\n\n"), []byte("\n\n"), -1)
+	}
+	out = append(bytes.TrimRight(out, "\n"), '\n')
+	if _, _, err := readManifest(bytes.NewReader(out)); err != nil {
+		return nil, errors.Wrap(err, "formatted manifest is invalid")
+	}
+	return out, nil
+}
+
+// manifestTable is a table of a manifest file: the root table, or one headed
+// by a [table] or [[array]] header. Sub-tables of an array element, such as
+// [constraint.metadata], are held in the element's subtables.
+type manifestTable struct {
+	// header is the normalized header line, empty for the root table.
+	header string
+	// name is the table's name, such as "constraint" or "prune.project".
+	name  string
+	array bool
+	// lead holds the comment lines directly above the header.
+	lead []string
+	// float holds comment groups, set apart by blank lines, that come before
+	// the first entry.
+	float     [][]string
+	entries   []*manifestEntry
+	subtables []*manifestTable
+}
+
+// manifestEntry is a key/value pair of a table.
+type manifestEntry struct {
+	comments []string
+	key      string
+	// value is the value as written, and inline any comment following it.
+	value  string
+	inline string
+	// items holds the entries of a multi-line or normalized array.
+	items []manifestItem
+	// float holds comment groups, set apart by blank lines, that follow the
+	// entry.
+	float [][]string
+}
+
+type manifestItem struct {
+	comments []string
+	value    string
+	inline   string
+}
+
+// parseManifestTables splits the manifest text s into its tables.
+func parseManifestTables(s string) ([]*manifestTable, error) {
+	lines := strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+
+	root := &manifestTable{}
+	tables := []*manifestTable{root}
+	cur := root
+	var pending []string
+	sawBlank := false
+
+	// flush files the comments gathered so far as a floating group, when
+	// they are set apart from whatever follows.
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if n := len(cur.entries); n > 0 {
+			cur.entries[n-1].float = append(cur.entries[n-1].float, pending)
+		} else {
+			cur.float = append(cur.float, pending)
+		}
+		pending = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "":
+			sawBlank = true
+			continue
+		case strings.HasPrefix(line, "#"):
+			if sawBlank {
+				flush()
+			}
+			sawBlank = false
+			pending = append(pending, line)
+			continue
+		}
+		if sawBlank {
+			flush()
+		}
+		sawBlank = false
+
+		if strings.Contains(line, `"""`) || strings.Contains(line, "'''") {
+			return nil, errors.Errorf("line %d: multi-line strings are not supported", i+1)
+		}
+
+		if strings.HasPrefix(line, "[") {
+			t, err := parseTableHeader(line)
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", i+1)
+			}
+			t.lead, pending = pending, nil
+			if parent := parentTable(tables, t.name); parent != nil {
+				parent.subtables = append(parent.subtables, t)
+			} else {
+				tables = append(tables, t)
+			}
+			cur = t
+			continue
+		}
+
+		e, next, err := parseEntry(lines, i)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", i+1)
+		}
+		e.comments, pending = append(pending, e.comments...), nil
+		cur.entries = append(cur.entries, e)
+		i = next
+	}
+	flush()
+
+	return tables, nil
+}
+
+// parentTable returns the last table in tables of which a table named name
+// would be a sub-table: the array element for [constraint.metadata], say.
+func parentTable(tables []*manifestTable, name string) *manifestTable {
+	for i := len(tables) - 1; i > 0; i-- {
+		t := tables[i]
+		if t.array && strings.HasPrefix(name, t.name+".") {
+			return t
+		}
+	}
+	return nil
+}
+
+func parseTableHeader(line string) (*manifestTable, error) {
+	t := &manifestTable{}
+	body, inline := splitInlineComment(line)
+	if strings.HasPrefix(body, "[[") {
+		if !strings.HasSuffix(body, "]]") {
+			return nil, errors.Errorf("unterminated table header %s", body)
+		}
+		t.array = true
+		t.name = strings.TrimSpace(body[2 : len(body)-2])
+		t.header = "[[" + t.name + "]]"
+	} else {
+		if !strings.HasSuffix(body, "]") {
+			return nil, errors.Errorf("unterminated table header %s", body)
+		}
+		t.name = strings.TrimSpace(body[1 : len(body)-1])
+		t.header = "[" + t.name + "]"
+	}
+	if inline != "" {
+		t.header += " " + inline
+	}
+	return t, nil
+}
+
+// parseEntry parses the key/value pair starting at lines[i], returning the
+// index of its last line.
+func parseEntry(lines []string, i int) (*manifestEntry, int, error) {
+	line := strings.TrimSpace(lines[i])
+	eq := strings.Index(line, "=")
+	if eq < 0 {
+		return nil, i, errors.Errorf("expected key = value, got %s", line)
+	}
+	e := &manifestEntry{key: strings.TrimSpace(line[:eq])}
+	rest := strings.TrimSpace(line[eq+1:])
+
+	if !strings.HasPrefix(rest, "[") {
+		e.value, e.inline = splitInlineComment(rest)
+		return e, i, nil
+	}
+
+	// An array, which may span several lines. Gather its items, along with
+	// the comments about them.
+	var pending []string
+	rest = rest[1:]
+	for {
+		// Whether an item has been found on the current line, so that a
+		// comment that follows is about it.
+		itemOnLine := false
+		for rest != "" {
+			rest = strings.TrimSpace(rest)
+			switch {
+			case rest == "":
+			case strings.HasPrefix(rest, "#"):
+				if itemOnLine {
+					e.items[len(e.items)-1].inline = rest
+				} else {
+					pending = append(pending, rest)
+				}
+				rest = ""
+			case strings.HasPrefix(rest, "]"):
+				if len(pending) > 0 {
+					// Comments after the last item stay with it.
+					if n := len(e.items); n > 0 {
+						e.items[n-1].comments = append(e.items[n-1].comments, pending...)
+					} else {
+						e.comments = append(e.comments, pending...)
+					}
+				}
+				_, e.inline = splitInlineComment(rest[1:])
+				e.value = "[]"
+				return e, i, nil
+			case strings.HasPrefix(rest, ","):
+				rest = rest[1:]
+			default:
+				v, remain, err := splitArrayItem(rest)
+				if err != nil {
+					return nil, i, err
+				}
+				e.items = append(e.items, manifestItem{comments: pending, value: v})
+				pending = nil
+				rest = remain
+				itemOnLine = true
+			}
+		}
+		i++
+		if i >= len(lines) {
+			return nil, i, errors.Errorf("unterminated array for %s", e.key)
+		}
+		rest = lines[i]
+	}
+}
+
+// splitArrayItem splits the first item off the array contents in s.
+func splitArrayItem(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"`) {
+		end := closingQuote(s)
+		if end < 0 {
+			return "", "", errors.Errorf("unterminated string %s", s)
+		}
+		return s[:end+1], s[end+1:], nil
+	}
+	end := strings.IndexAny(s, ",]#")
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.TrimSpace(s[:end]), s[end:], nil
+}
+
+// splitInlineComment splits a trailing comment off s, taking care not to split
+// within a string.
+func splitInlineComment(s string) (string, string) {
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i:])
+			}
+		}
+	}
+	return strings.TrimSpace(s), ""
+}
+
+// closingQuote returns the index of the quote that ends the basic string at
+// the start of s, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// arrangeManifestTables normalizes tables, returning them in canonical order:
+// the root table, the [[constraint]] and [[override]] stanzas, and then the
+// other tables in their original order, with the [[prune.project]] stanzas
+// following [prune].
+func arrangeManifestTables(tables []*manifestTable) []*manifestTable {
+	for _, t := range tables {
+		normalizeManifestTable(t)
+	}
+
+	stanzas := make(map[string][]*manifestTable)
+	var others []*manifestTable
+	for _, t := range tables[1:] {
+		switch {
+		case t.array && (t.name == "constraint" || t.name == "override" || t.name == "prune.project"):
+			stanzas[t.name] = append(stanzas[t.name], t)
+		default:
+			others = append(others, t)
+		}
+	}
+	for name, st := range stanzas {
+		stanzas[name] = sortStanzas(st)
+	}
+
+	arranged := []*manifestTable{tables[0]}
+	arranged = append(arranged, stanzas["constraint"]...)
+	arranged = append(arranged, stanzas["override"]...)
+	placed := false
+	for _, t := range others {
+		arranged = append(arranged, t)
+		if t.name == "prune" && !t.array {
+			arranged = append(arranged, stanzas["prune.project"]...)
+			placed = true
+		}
+	}
+	if !placed {
+		arranged = append(arranged, stanzas["prune.project"]...)
+	}
+	return arranged
+}
+
+// normalizeManifestTable sorts the keys of t and its sub-tables, and
+// normalizes the values dep interprets.
+func normalizeManifestTable(t *manifestTable) {
+	for _, e := range t.entries {
+		switch {
+		case t.header == "" && (e.key == "required" || e.key == "ignored"):
+			e.items = sortArrayItems(e.items)
+		case t.name == "constraint" || t.name == "override" || t.name == "prune.project":
+			e.value = normalizeRuleValue(e.key, e.value)
+		}
+	}
+	sort.SliceStable(t.entries, func(i, j int) bool { return t.entries[i].key < t.entries[j].key })
+
+	for _, st := range t.subtables {
+		normalizeManifestTable(st)
+	}
+}
+
+// normalizeRuleValue trims the string values of a rule, and writes version
+// constraints as dep itself writes them.
+func normalizeRuleValue(key, value string) string {
+	switch key {
+	case "name", "source", "branch", "revision", "version", "go":
+	default:
+		return value
+	}
+	s, err := strconv.Unquote(value)
+	if err != nil {
+		return value
+	}
+	s = strings.TrimSpace(s)
+	if key == "version" {
+		if c, err := gps.NewSemverConstraintIC(s); err == nil && !gps.IsAny(c) {
+			s = c.ImpliedCaretString()
+		}
+	}
+	return strconv.Quote(s)
+}
+
+// sortArrayItems sorts items by value, dropping those with a value already
+// seen. The comments of a dropped item are kept with the first one.
+func sortArrayItems(items []manifestItem) []manifestItem {
+	seen := make(map[string]int)
+	var sorted []manifestItem
+	for _, it := range items {
+		key := it.value
+		if s, err := strconv.Unquote(it.value); err == nil {
+			key = strconv.Quote(strings.TrimSpace(s))
+			it.value = key
+		}
+		if k, has := seen[key]; has {
+			sorted[k].comments = append(sorted[k].comments, it.comments...)
+			continue
+		}
+		seen[key] = len(sorted)
+		sorted = append(sorted, it)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+	return sorted
+}
+
+// sortStanzas sorts array table elements by name and source, dropping any that
+// are identical to one before them.
+func sortStanzas(st []*manifestTable) []*manifestTable {
+	seen := make(map[string]bool)
+	var kept []*manifestTable
+	for _, t := range st {
+		content := stanzaContent(t)
+		if seen[content] {
+			continue
+		}
+		seen[content] = true
+		kept = append(kept, t)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		ni, si := stanzaValue(kept[i], "name"), stanzaValue(kept[i], "source")
+		nj, sj := stanzaValue(kept[j], "name"), stanzaValue(kept[j], "source")
+		if ni != nj {
+			return ni < nj
+		}
+		return si < sj
+	})
+	return kept
+}
+
+// stanzaContent returns the keys and values of t and its sub-tables, leaving
+// out comments.
+func stanzaContent(t *manifestTable) string {
+	var buf bytes.Buffer
+	buf.WriteString(t.header)
+	for _, e := range t.entries {
+		buf.WriteString("\n" + e.key + "=" + e.value)
+		for _, it := range e.items {
+			buf.WriteString("," + it.value)
+		}
+	}
+	for _, st := range t.subtables {
+		buf.WriteString("\n" + stanzaContent(st))
+	}
+	return buf.String()
+}
+
+func stanzaValue(t *manifestTable, key string) string {
+	for _, e := range t.entries {
+		if e.key == key {
+			if s, err := strconv.Unquote(e.value); err == nil {
+				return s
+			}
+			return e.value
+		}
+	}
+	return ""
+}
+
+// writeManifestTables writes out tables in the layout of manifests written by
+// dep: keys within tables indented by two spaces, arrays written one item per
+// line, and a blank line before each table.
+func writeManifestTables(buf *bytes.Buffer, tables []*manifestTable) {
+	for i, t := range tables {
+		if i > 0 || buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		writeManifestTable(buf, t, "")
+	}
+}
+
+func writeManifestTable(buf *bytes.Buffer, t *manifestTable, indent string) {
+	if t.header != "" {
+		for _, c := range t.lead {
+			buf.WriteString(c + "\n")
+		}
+		buf.WriteString(t.header + "\n")
+		indent = "  "
+	}
+	for _, group := range t.float {
+		writeComments(buf, group, indent)
+		buf.WriteString("\n")
+	}
+	for _, e := range t.entries {
+		writeComments(buf, e.comments, indent)
+		buf.WriteString(indent + e.key + " = ")
+		if e.value == "[]" && len(e.items) > 0 {
+			buf.WriteString("[\n")
+			for _, it := range e.items {
+				writeComments(buf, it.comments, indent+"  ")
+				buf.WriteString(indent + "  " + it.value + ",")
+				if it.inline != "" {
+					buf.WriteString(" " + it.inline)
+				}
+				buf.WriteString("\n")
+			}
+			buf.WriteString(indent + "]")
+		} else {
+			buf.WriteString(e.value)
+		}
+		if e.inline != "" {
+			buf.WriteString(" " + e.inline)
+		}
+		buf.WriteString("\n")
+		for _, group := range e.float {
+			buf.WriteString("\n")
+			writeComments(buf, group, indent)
+			buf.WriteString("\n")
+		}
+	}
+	for _, st := range t.subtables {
+		buf.WriteString("\n")
+		writeManifestTable(buf, st, indent)
+	}
+}
+
+func writeComments(buf *bytes.Buffer, comments []string, indent string) {
+	for _, c := range comments {
+		buf.WriteString(indent + c + "\n")
+	}
+}
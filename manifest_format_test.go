@@ -0,0 +1,168 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"testing"
+)
+
+func TestFormatManifest(t *testing.T) {
+	in := `# Gopkg.toml example
+#
+# Refer to the docs for details.
+
+required = ["github.com/b/cmd",   "github.com/a/cmd", "github.com/b/cmd"]
+ignored = [
+  # Generated code.
+  "github.com/x/gen", # keep ignored
+]
+
+# This constraint pins yaml.
+[[constraint]]
+    name = "gopkg.in/yaml.v2"
+    branch = " v2 "
+
+[[override]]
+name = "github.com/z/z"
+version = "^1.2.0"
+
+[[constraint]]
+  version = ">= 1.0, < 1.5"
+  name = "github.com/a/a"
+  [constraint.metadata]
+  owner = "team-a"
+
+[[constraint]]
+  name = "github.com/a/a"
+  version = ">= 1.0, < 1.5"
+  [constraint.metadata]
+  owner = "team-a"
+
+[prune]
+  unused-packages = true
+  go-tests = true
+
+  [[prune.project]]
+    name = "github.com/z/z"
+    non-go = true
+
+  [[prune.project]]
+    name = "github.com/a/a"
+    non-go = true
+`
+
+	want := `# Gopkg.toml example
+#
+# Refer to the docs for details.
+
+ignored = [
+  # Generated code.
+  "github.com/x/gen", # keep ignored
+]
+required = [
+  "github.com/a/cmd",
+  "github.com/b/cmd",
+]
+
+[[constraint]]
+  name = "github.com/a/a"
+  version = ">=1.0.0, <1.5.0"
+
+[constraint.metadata]
+  owner = "team-a"
+
+# This constraint pins yaml.
+[[constraint]]
+  branch = "v2"
+  name = "gopkg.in/yaml.v2"
+
+[[override]]
+  name = "github.com/z/z"
+  version = "1.2.0"
+
+[prune]
+  go-tests = true
+  unused-packages = true
+
+[[prune.project]]
+  name = "github.com/a/a"
+  non-go = true
+
+[[prune.project]]
+  name = "github.com/z/z"
+  non-go = true
+`
+
+	got, err := FormatManifest([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("unexpected formatted manifest:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	// Formatting is idempotent.
+	again, err := FormatManifest(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(got) {
+		t.Errorf("formatting a formatted manifest changed it:\n%s", again)
+	}
+}
+
+func TestFormatManifestFloatingComments(t *testing.T) {
+	in := `
+[[constraint]]
+  name = "github.com/b/b"
+  version = "1.0.0"
+
+# A note about b, set apart from the next stanza.
+
+[[constraint]]
+  name = "github.com/a/a"
+`
+	want := `
+[[constraint]]
+  name = "github.com/a/a"
+
+[[constraint]]
+  name = "github.com/b/b"
+  version = "1.0.0"
+
+  # A note about b, set apart from the next stanza.
+`
+
+	got, err := FormatManifest([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("unexpected formatted manifest:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+	if again, _ := FormatManifest(got); string(again) != string(got) {
+		t.Errorf("formatting a formatted manifest changed it:\n%s", again)
+	}
+}
+
+func TestFormatManifestInvalid(t *testing.T) {
+	// Stanzas for the same project that differ cannot be deduplicated.
+	in := `
+[[constraint]]
+  name = "github.com/a/a"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/a/a"
+  version = "2.0.0"
+`
+	if _, err := FormatManifest([]byte(in)); err == nil {
+		t.Error("expected an error formatting a manifest with conflicting constraints")
+	}
+
+	if _, err := FormatManifest([]byte("[[constraint]\n")); err == nil {
+		t.Error("expected an error formatting an unparseable manifest")
+	}
+}
@@ -10,9 +10,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/internal/test"
@@ -107,6 +109,327 @@ func TestWriteManifest(t *testing.T) {
 	}
 }
 
+func TestManifestLockPreference(t *testing.T) {
+	for _, pl := range []gps.LockPreference{gps.PreferLocked, gps.PreferLockedDirect, gps.PreferNewest} {
+		m := NewManifest()
+		m.LockPreference = pl
+
+		data, err := m.MarshalTOML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := readManifest(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.LockPreference != pl {
+			t.Errorf("expected prefer-locked = %q to survive a round trip, got %q", pl, got.LockPreference)
+		}
+	}
+
+	if raw := NewManifest().toRaw(); raw.PreferLocked != "" {
+		t.Errorf("expected the default lock preference to be omitted, got %q", raw.PreferLocked)
+	}
+}
+
+func TestManifestGoVersions(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+go = ">=1.10"
+
+[[constraint]]
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+  go = "1.11"
+
+[[constraint]]
+  name = "github.com/foo/baz"
+  go = "go1.12"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.MinGoVersion() != "1.10" {
+		t.Errorf("expected minimum Go version 1.10, got %q", m.MinGoVersion())
+	}
+	want := map[gps.ProjectRoot]string{"github.com/foo/bar": "1.11", "github.com/foo/baz": "1.12"}
+	if !reflect.DeepEqual(m.ProjectGoVersions(), want) {
+		t.Errorf("unexpected project Go versions:\n\t(GOT): %v\n\t(WNT): %v", m.ProjectGoVersions(), want)
+	}
+	if _, has := m.Constraints["github.com/foo/baz"]; has {
+		t.Error("expected a constraint with only a Go version not to constrain the project")
+	}
+
+	data, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := readManifest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GoVersion != m.GoVersion || !reflect.DeepEqual(got.GoVersions, m.GoVersions) || len(got.Constraints) != len(m.Constraints) {
+		t.Errorf("expected Go versions to survive a round trip, got:\n%s", data)
+	}
+}
+
+func TestManifestMetadata(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+[metadata]
+  policy = "https://example.com/policies/deps"
+  reviewers = 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"policy": "https://example.com/policies/deps"}
+	if !reflect.DeepEqual(m.Metadata, want) {
+		t.Fatalf("expected only the string metadata to be read, got %v", m.Metadata)
+	}
+
+	data, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := readManifest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Metadata, want) {
+		t.Errorf("expected metadata to survive a round trip, got:\n%s", data)
+	}
+}
+
+func TestManifestOverrideExpiry(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+[[override]]
+  name = "github.com/foo/bar"
+  branch = "fix-leak"
+  expires = "2024-06-01"
+
+[[override]]
+  name = "github.com/foo/baz"
+  expires = "2024-09-01"
+
+[[override]]
+  name = "github.com/foo/qux"
+  version = "1.0.0"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		now  string
+		want []gps.ProjectRoot
+	}{
+		{"2024-05-31", nil},
+		{"2024-06-01", []gps.ProjectRoot{"github.com/foo/bar"}},
+		{"2025-01-01", []gps.ProjectRoot{"github.com/foo/bar", "github.com/foo/baz"}},
+	}
+	for _, c := range cases {
+		now, _ := time.Parse(OverrideExpiryFormat, c.now)
+		if got := m.ExpiredOverrides(now); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("on %s: expected expired overrides %v, got %v", c.now, c.want, got)
+		}
+	}
+
+	data, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := readManifest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.OverrideExpiry, m.OverrideExpiry) {
+		t.Errorf("expected override expiry to survive a round trip, got:\n%s", data)
+	}
+
+	_, _, err = readManifest(strings.NewReader(`
+[[override]]
+  name = "github.com/foo/bar"
+  branch = "fix-leak"
+  expires = "June 1st"
+`))
+	if err == nil || !strings.Contains(err.Error(), "invalid expires") {
+		t.Errorf("expected an error for an invalid date, got %v", err)
+	}
+}
+
+func TestManifestRequiredConstraints(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+required = ["github.com/golang/protobuf/protoc-gen-go@1.1.0", "github.com/foo/bar/cmd/tool"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"github.com/golang/protobuf/protoc-gen-go": true, "github.com/foo/bar/cmd/tool": true}
+	if !reflect.DeepEqual(m.RequiredPackages(), want) {
+		t.Errorf("expected the required packages without their versions, got %v", m.RequiredPackages())
+	}
+	rc := m.RequiredConstraints()
+	if len(rc) != 1 || !rc["github.com/golang/protobuf/protoc-gen-go"].Matches(gps.NewVersion("v1.1.0")) {
+		t.Errorf("expected protoc-gen-go alone to be pinned to 1.1.0, got %v", rc)
+	}
+
+	if _, _, err := readManifest(strings.NewReader(`required = ["github.com/foo/bar/cmd/tool@"]`)); err == nil {
+		t.Error("expected an error for a required package with an empty version")
+	}
+}
+
+func TestManifestPruneAssets(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+[prune]
+  non-go = true
+
+  [[prune.project]]
+    name = "github.com/foo/bar"
+    assets = ["web/templates/*.html", "schema", "schema"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[gps.ProjectRoot][]string{"github.com/foo/bar": {"schema", "web/templates/*.html"}}
+	if !reflect.DeepEqual(m.PruneOptions.Assets, want) {
+		t.Errorf("unexpected prune assets:\n\t(GOT): %v\n\t(WNT): %v", m.PruneOptions.Assets, want)
+	}
+
+	if _, _, err := readManifest(strings.NewReader(`
+[[prune.project]]
+  name = "github.com/foo/bar"
+  assets = ["../escape"]
+`)); err == nil {
+		t.Error("expected an asset pattern outside the project to be rejected")
+	}
+}
+
+func TestManifestGenerated(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+generated = ["zz_generated.go", "gen/*.go"]
+
+[[prune.project]]
+  name = "github.com/foo/bar"
+  generated = ["bindata.go", "bindata.go"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"zz_generated.go", "gen/*.go"}; !reflect.DeepEqual(m.GeneratedFiles(), want) {
+		t.Errorf("unexpected generated files:\n\t(GOT): %v\n\t(WNT): %v", m.GeneratedFiles(), want)
+	}
+	want := map[gps.ProjectRoot][]string{"github.com/foo/bar": {"bindata.go"}}
+	if !reflect.DeepEqual(m.PruneOptions.Generated, want) {
+		t.Errorf("unexpected prune generated files:\n\t(GOT): %v\n\t(WNT): %v", m.PruneOptions.Generated, want)
+	}
+
+	raw, err := (&Manifest{Generated: m.Generated}).MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "generated = [") {
+		t.Errorf("expected the generated files to be written back, got:\n%s", raw)
+	}
+
+	for _, bad := range []string{
+		`generated = ["../escape"]`,
+		`generated = "zz_generated.go"`,
+		"[prune]\n  generated = [\"zz_generated.go\"]",
+	} {
+		if _, _, err := readManifest(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestManifestScopedIgnores(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+ignored = ["github.com/foo/always"]
+
+[[scoped-ignore]]
+  packages = ["golang.org/x/sys/windows"]
+  goos = ["linux", "darwin"]
+
+[[scoped-ignore]]
+  packages = ["github.com/foo/cgo*"]
+  tags = ["!cgo"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.ScopedIgnores) != 2 {
+		t.Fatalf("expected 2 scoped ignores, got %v", m.ScopedIgnores)
+	}
+
+	for _, tc := range []struct {
+		bc      gps.BuildContext
+		ignored []string
+		input   string
+	}{
+		{gps.BuildContext{GOOS: "linux", GOARCH: "amd64"}, []string{"github.com/foo/always", "golang.org/x/sys/windows", "github.com/foo/cgo/bar"}, "goos=linux"},
+		{gps.BuildContext{GOOS: "windows", GOARCH: "amd64", Tags: []string{"cgo", "netgo"}}, []string{"github.com/foo/always"}, "goos=windows tags=cgo"},
+	} {
+		m.BuildContext = tc.bc
+		ig := m.IgnoredPackages()
+		for _, pkg := range []string{"github.com/foo/always", "golang.org/x/sys/windows", "github.com/foo/cgo/bar"} {
+			want := false
+			for _, ip := range tc.ignored {
+				want = want || ip == pkg
+			}
+			if ig.IsIgnored(pkg) != want {
+				t.Errorf("%s: expected %s to be ignored to be %v", tc.bc, pkg, want)
+			}
+		}
+		if got := m.InputBuildContext(); got != tc.input {
+			t.Errorf("%s: expected the input build context %q, got %q", tc.bc, tc.input, got)
+		}
+	}
+
+	raw, err := (&Manifest{ScopedIgnores: m.ScopedIgnores}).MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "[[scoped-ignore]]") {
+		t.Errorf("expected the scoped ignores to be written back, got:\n%s", raw)
+	}
+
+	for _, bad := range []string{
+		"[[scoped-ignore]]\n  packages = [\"github.com/foo/bar\"]",
+		"[[scoped-ignore]]\n  goos = [\"linux\"]",
+		"[[scoped-ignore]]\n  packages = [\"github.com/foo/bar\"]\n  tags = [\"a,b\"]",
+		"[[scoped-ignore]]\n  packages = \"github.com/foo/bar\"\n  goos = [\"linux\"]",
+		`scoped-ignore = ["github.com/foo/bar"]`,
+	} {
+		if _, _, err := readManifest(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestManifestVendorPath(t *testing.T) {
+	var nilm *Manifest
+	if got := nilm.VendorPath(); got != DefaultVendorDir {
+		t.Errorf("expected nil manifest to use %q, got %q", DefaultVendorDir, got)
+	}
+
+	m := NewManifest()
+	if got := m.VendorPath(); got != DefaultVendorDir {
+		t.Errorf("expected empty vendor-dir to use %q, got %q", DefaultVendorDir, got)
+	}
+
+	m.VendorDir = "src/vendor"
+	if got, want := m.VendorPath(), filepath.FromSlash("src/vendor"); got != want {
+		t.Errorf("expected vendor path %q, got %q", want, got)
+	}
+
+	raw := m.toRaw()
+	if raw.VendorDir != "src/vendor" {
+		t.Errorf("expected vendor-dir to survive conversion to raw manifest, got %q", raw.VendorDir)
+	}
+}
+
 func TestReadManifestErrors(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -312,6 +635,35 @@ func TestValidateManifest(t *testing.T) {
 			wantWarn:  []error{},
 			wantError: nil,
 		},
+		{
+			name: "override with expires",
+			tomlString: `
+			[[override]]
+			  name = "github.com/foo/bar"
+			  branch = "fix-leak"
+			  expires = "2024-06-01"
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "misplaced expires",
+			tomlString: `
+			[[constraint]]
+			  name = "github.com/foo/bar"
+			  branch = "fix-leak"
+			  expires = "2024-06-01"
+
+			[[override]]
+			  name = "github.com/foo/baz"
+			  expires = 20240601
+			`,
+			wantWarn: []error{
+				errors.New("expires can only be set in \"override\""),
+				errors.New("expires in \"override\" should be a string"),
+			},
+			wantError: nil,
+		},
 		{
 			name: "empty override",
 			tomlString: `
@@ -439,6 +791,192 @@ func TestValidateManifest(t *testing.T) {
 			wantWarn:  []error{},
 			wantError: errInvalidPruneProject,
 		},
+		{
+			name: "valid prune project assets",
+			tomlString: `
+			[prune]
+			  non-go = true
+
+			  [[prune.project]]
+			    name = "github.com/org/project"
+			    assets = ["templates/*.html", "testdata"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid prune project assets",
+			tomlString: `
+			[prune]
+			  non-go = true
+
+			  [[prune.project]]
+			    name = "github.com/org/project"
+			    assets = "templates"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidPruneAssets,
+		},
+		{
+			name: "root prune assets",
+			tomlString: `
+			[prune]
+			  non-go = true
+			  assets = ["templates"]
+			`,
+			wantWarn:  []error{},
+			wantError: errRootPruneAssets,
+		},
+		{
+			name: "valid noverify",
+			tomlString: `
+			noverify = ["github.com/foo/bar"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid noverify",
+			tomlString: `
+			noverify = "github.com/foo/bar"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidNoVerify,
+		},
+		{
+			name: "valid freeze",
+			tomlString: `
+			freeze = ["github.com/foo/bar"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid freeze",
+			tomlString: `
+			freeze = "github.com/foo/bar"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidFreeze,
+		},
+		{
+			name: "valid tags-only",
+			tomlString: `
+			tags-only = ["github.com/foo/bar"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid tags-only",
+			tomlString: `
+			tags-only = "github.com/foo/bar"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidTagsOnly,
+		},
+		{
+			name: "valid vendor-dir",
+			tomlString: `
+			vendor-dir = "src/vendor"
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "vendor-dir not named vendor",
+			tomlString: `
+			vendor-dir = "third_party"
+			`,
+			wantWarn: []error{
+				errors.New(`vendor-dir "third_party" is not named "vendor"; the go tool will only resolve imports from it if it is placed on GOPATH by other means`),
+			},
+			wantError: nil,
+		},
+		{
+			name: "vendor-dir escapes project",
+			tomlString: `
+			vendor-dir = "../vendor"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorDir,
+		},
+		{
+			name: "absolute vendor-dir",
+			tomlString: `
+			vendor-dir = "/tmp/vendor"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorDir,
+		},
+		{
+			name: "invalid vendor-dir type",
+			tomlString: `
+			vendor-dir = ["vendor"]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorDir,
+		},
+		{
+			name: "valid import-root",
+			tomlString: `
+			import-root = "github.com/golang/dep"
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "empty import-root",
+			tomlString: `
+			import-root = ""
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidImportRoot,
+		},
+		{
+			name: "unclean import-root",
+			tomlString: `
+			import-root = "github.com/golang/dep/"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidImportRoot,
+		},
+		{
+			name: "valid prefer-locked",
+			tomlString: `
+			prefer-locked = "direct"
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid prefer-locked",
+			tomlString: `
+			prefer-locked = "some"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidPreferLocked,
+		},
+		{
+			name: "valid go versions",
+			tomlString: `
+			go = ">=1.10"
+
+			[[constraint]]
+			  name = "github.com/foo/bar"
+			  go = ">=1.11"
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid go version",
+			tomlString: `
+			go = "<1.10"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidGoVersion,
+		},
 	}
 
 	for _, c := range cases {
@@ -781,6 +1319,28 @@ func TestToRawPruneOptions(t *testing.T) {
 				GoTests:        false,
 			},
 		},
+		{
+			name: "project options",
+			pruneOptions: gps.CascadingPruneOptions{
+				DefaultOptions: 15,
+				PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{
+					"github.com/golang/dep":        {NestedVendor: pvtrue, UnusedPackages: pvfalse},
+					"github.com/carolynvs/deptest": {NestedVendor: pvtrue, NonGoFiles: pvtrue},
+				},
+				Assets:    map[gps.ProjectRoot][]string{"github.com/golang/dep": {"testdata/**"}},
+				Generated: map[gps.ProjectRoot][]string{"github.com/pkg/errors": {"*.pb.go"}},
+			},
+			wantOptions: rawPruneOptions{
+				UnusedPackages: true,
+				NonGoFiles:     true,
+				GoTests:        true,
+				Projects: []rawPruneProject{
+					{Name: "github.com/carolynvs/deptest", NonGoFiles: &[]bool{true}[0]},
+					{Name: "github.com/golang/dep", UnusedPackages: &[]bool{false}[0], Assets: []string{"testdata/**"}},
+					{Name: "github.com/pkg/errors", Generated: []string{"*.pb.go"}},
+				},
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -794,23 +1354,6 @@ func TestToRawPruneOptions(t *testing.T) {
 	}
 }
 
-func TestToRawPruneOptions_Panic(t *testing.T) {
-	pruneOptions := gps.CascadingPruneOptions{
-		DefaultOptions: 1,
-		PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{
-			"github.com/carolynvs/deptest": {
-				NestedVendor: pvtrue,
-			},
-		},
-	}
-	defer func() {
-		if err := recover(); err == nil {
-			t.Error("toRawPruneOptions did not panic with non-empty ProjectOptions")
-		}
-	}()
-	_ = toRawPruneOptions(pruneOptions)
-}
-
 func containsErr(s []error, e error) bool {
 	for _, a := range s {
 		if a.Error() == e.Error() {
@@ -0,0 +1,358 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package manifestedit edits Gopkg.toml files, so that tools such as bots
+// opening automated update PRs can change the rules of a manifest without
+// templating TOML by hand.
+//
+// Each edit is validated as it is made, and the file is rewritten with only
+// the rules that were changed replaced, so that the comments and formatting of
+// the rest of the manifest are preserved:
+//
+//	f, err := manifestedit.ReadFile("Gopkg.toml")
+//	if err != nil {
+//		return err
+//	}
+//	if err := f.SetConstraint("github.com/pkg/errors", manifestedit.Rule{Version: "0.8.1"}); err != nil {
+//		return err
+//	}
+//	return f.WriteFile("Gopkg.toml")
+package manifestedit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/pkg/errors"
+)
+
+// Rule is the version rule of a [[constraint]] or [[override]]. At most one
+// of Version, Branch and Revision may be set; if none is, the rule allows any
+// version.
+type Rule struct {
+	Version  string
+	Branch   string
+	Revision string
+	// Source is the alternate location to fetch the project from, if any.
+	Source string
+	// Expires is the date on which an override, meant to be temporary,
+	// expires; see dep.Manifest.OverrideExpiry. It is zero for an override
+	// that does not expire, and must be for a constraint.
+	Expires time.Time
+}
+
+// File is a manifest being edited.
+type File struct {
+	orig []byte
+	m    *dep.Manifest
+}
+
+// Parse returns a File for editing the manifest in data, which may be empty to
+// start a new manifest.
+func Parse(data []byte) (*File, error) {
+	m, _, err := dep.ReadManifest(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &File{orig: data, m: m}, nil
+}
+
+// ReadFile returns a File for editing the manifest at path.
+func ReadFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	return f, errors.Wrapf(err, "could not parse %s", path)
+}
+
+// Manifest returns the manifest as edited so far. It must not be modified;
+// edits are made through the methods of f.
+func (f *File) Manifest() *dep.Manifest {
+	return f.m
+}
+
+// SetConstraint adds a [[constraint]] on the project at root, or replaces the
+// existing one.
+func (f *File) SetConstraint(root string, r Rule) error {
+	if !r.Expires.IsZero() {
+		return errors.Errorf("the constraint on %s cannot expire; only overrides can", root)
+	}
+	pp, err := ruleProperties(root, r)
+	if err != nil {
+		return err
+	}
+	f.m.Constraints[gps.ProjectRoot(root)] = pp
+	return nil
+}
+
+// RemoveConstraint removes the [[constraint]] on the project at root,
+// reporting whether there was one.
+func (f *File) RemoveConstraint(root string) bool {
+	_, has := f.m.Constraints[gps.ProjectRoot(root)]
+	delete(f.m.Constraints, gps.ProjectRoot(root))
+	return has
+}
+
+// SetOverride adds an [[override]] of the project at root, or replaces the
+// existing one.
+func (f *File) SetOverride(root string, r Rule) error {
+	pp, err := ruleProperties(root, r)
+	if err != nil {
+		return err
+	}
+	f.m.Ovr[gps.ProjectRoot(root)] = pp
+	if r.Expires.IsZero() {
+		delete(f.m.OverrideExpiry, gps.ProjectRoot(root))
+		return nil
+	}
+	if f.m.OverrideExpiry == nil {
+		f.m.OverrideExpiry = make(map[gps.ProjectRoot]time.Time)
+	}
+	f.m.OverrideExpiry[gps.ProjectRoot(root)] = r.Expires
+	return nil
+}
+
+// RemoveOverride removes the [[override]] of the project at root, reporting
+// whether there was one.
+func (f *File) RemoveOverride(root string) bool {
+	_, has := f.m.Ovr[gps.ProjectRoot(root)]
+	delete(f.m.Ovr, gps.ProjectRoot(root))
+	delete(f.m.OverrideExpiry, gps.ProjectRoot(root))
+	return has
+}
+
+// MoveRules moves the [[constraint]] and [[override]] on the project at from,
+// along with their metadata and expiry, to the project at to, as when from names a
+// package within a project rather than its root. An error is returned, and
+// nothing moved, if to already has a rule of a kind that from has. As the moved
+// rules are written anew, comments on them are not kept.
+func (f *File) MoveRules(from, to string) error {
+	if err := checkImportPath(to); err != nil {
+		return err
+	}
+	fr, tr := gps.ProjectRoot(from), gps.ProjectRoot(to)
+	for _, rules := range []gps.ProjectConstraints{f.m.Constraints, f.m.Ovr} {
+		_, fromHas := rules[fr]
+		_, toHas := rules[tr]
+		if fromHas && toHas {
+			return errors.Errorf("cannot move the rules on %s to %s, which already has one of the same kind", from, to)
+		}
+	}
+
+	for _, rules := range []gps.ProjectConstraints{f.m.Constraints, f.m.Ovr} {
+		if pp, has := rules[fr]; has {
+			rules[tr] = pp
+			delete(rules, fr)
+		}
+	}
+	if exp, has := f.m.OverrideExpiry[fr]; has {
+		f.m.OverrideExpiry[tr] = exp
+		delete(f.m.OverrideExpiry, fr)
+	}
+	if md, has := f.m.ConstraintMetadata[fr]; has {
+		if f.m.ConstraintMetadata[tr] == nil {
+			f.m.ConstraintMetadata[tr] = make(map[string]string, len(md))
+		}
+		for k, v := range md {
+			if _, has := f.m.ConstraintMetadata[tr][k]; !has {
+				f.m.ConstraintMetadata[tr][k] = v
+			}
+		}
+		delete(f.m.ConstraintMetadata, fr)
+	}
+	return nil
+}
+
+// AddRequired adds packages to the required list. Packages already listed,
+// whether or not pinned to a version, are skipped; an error is returned, and
+// nothing added, if any is not a valid import path or is ignored.
+func (f *File) AddRequired(pkgs ...string) error {
+	ig := f.m.IgnoredPackages()
+	for _, pkg := range pkgs {
+		if err := checkImportPath(pkg); err != nil {
+			return err
+		}
+		if ig.IsIgnored(pkg) {
+			return errors.Errorf("%s cannot be required, as it is ignored", pkg)
+		}
+	}
+	req := f.m.RequiredPackages()
+	for _, pkg := range pkgs {
+		if !req[pkg] {
+			req[pkg] = true
+			f.m.Required = append(f.m.Required, pkg)
+		}
+	}
+	return nil
+}
+
+// RemoveRequired removes packages, along with any versions they are pinned to,
+// from the required list, reporting whether any were listed.
+func (f *File) RemoveRequired(pkgs ...string) bool {
+	var kept []string
+	removed := false
+	for _, req := range f.m.Required {
+		if contains(pkgs, strings.SplitN(req, "@", 2)[0]) {
+			removed = true
+			continue
+		}
+		kept = append(kept, req)
+	}
+	f.m.Required = kept
+	return removed
+}
+
+// AddIgnored adds packages, or prefixes such as "github.com/user/project/bad*",
+// to the ignored list. Entries already listed are skipped; an error is
+// returned, and nothing added, if any is not a valid import path or prefix, or
+// would ignore a required package.
+func (f *File) AddIgnored(pkgs ...string) error {
+	for _, pkg := range pkgs {
+		prefix := strings.TrimSuffix(pkg, "*")
+		if prefix != pkg {
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+		if err := checkImportPath(prefix); err != nil {
+			return err
+		}
+	}
+
+	m := *f.m
+	m.Ignored = appendMissing(f.m.Ignored, pkgs)
+	ig := m.IgnoredPackages()
+	for req := range f.m.RequiredPackages() {
+		if ig.IsIgnored(req) {
+			return errors.Errorf("required package %s would be ignored", req)
+		}
+	}
+	f.m.Ignored = m.Ignored
+	return nil
+}
+
+// RemoveIgnored removes packages or prefixes from the ignored list, reporting
+// whether any were listed.
+func (f *File) RemoveIgnored(pkgs ...string) bool {
+	var removed bool
+	f.m.Ignored, removed = removeAll(f.m.Ignored, pkgs)
+	return removed
+}
+
+// Bytes returns the contents of the edited manifest. The result is checked to
+// be a valid manifest before it is returned.
+func (f *File) Bytes() ([]byte, error) {
+	out, err := f.m.RewriteTOML(f.orig)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := dep.ReadManifest(bytes.NewReader(out)); err != nil {
+		return nil, errors.Wrap(err, "edited manifest is invalid")
+	}
+	return out, nil
+}
+
+// WriteFile writes the edited manifest to path, keeping the permissions of any
+// file already there.
+func (f *File) WriteFile(path string) error {
+	out, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0666)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode()
+	}
+	return ioutil.WriteFile(path, out, mode)
+}
+
+// ruleProperties validates r, a rule for the project at root, returning the
+// properties it describes.
+func ruleProperties(root string, r Rule) (gps.ProjectProperties, error) {
+	var pp gps.ProjectProperties
+	if err := checkImportPath(root); err != nil {
+		return pp, err
+	}
+
+	set := 0
+	for _, v := range []string{r.Version, r.Branch, r.Revision} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return pp, errors.Errorf("multiple constraints specified for %s, can only specify one", root)
+	}
+
+	switch {
+	case r.Version != "":
+		// Always semver if we can, as the manifest does.
+		c, err := gps.NewSemverConstraintIC(r.Version)
+		if err != nil {
+			c = gps.NewVersion(r.Version)
+		}
+		pp.Constraint = c
+	case r.Branch != "":
+		pp.Constraint = gps.NewBranch(r.Branch)
+	case r.Revision != "":
+		pp.Constraint = gps.Revision(r.Revision)
+	default:
+		pp.Constraint = gps.Any()
+	}
+	pp.Source = r.Source
+	return pp, nil
+}
+
+// checkImportPath returns an error if path cannot be a non-standard import
+// path.
+func checkImportPath(path string) error {
+	switch {
+	case path == "",
+		strings.HasPrefix(path, "/"),
+		strings.HasSuffix(path, "/"),
+		strings.ContainsAny(path, " \t\\"),
+		strings.Contains(path, "//"):
+		return errors.Errorf("%q is not a valid import path", path)
+	case paths.IsStandardImportPath(path):
+		return errors.Errorf("%s is in the standard library", path)
+	}
+	return nil
+}
+
+func appendMissing(list, add []string) []string {
+	for _, s := range add {
+		if !contains(list, s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func removeAll(list, remove []string) ([]string, bool) {
+	var kept []string
+	removed := false
+	for _, s := range list {
+		if contains(remove, s) {
+			removed = true
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return kept, removed
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
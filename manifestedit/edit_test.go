@@ -0,0 +1,128 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package manifestedit
+
+import (
+	"testing"
+	"time"
+)
+
+const testManifest = `# Keep golint around for CI.
+required = ["github.com/golang/lint/golint"]
+
+# Pinned until the v2 API settles.
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+`
+
+func TestFileEdits(t *testing.T) {
+	f, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetConstraint("github.com/sdboyer/deptest", Rule{Version: "1.1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetOverride("github.com/sdboyer/deptestdos", Rule{Branch: "master", Source: "github.com/carolynvs/deptestdos", Expires: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddIgnored("github.com/sdboyer/deptest/bad*"); err != nil {
+		t.Fatal(err)
+	}
+	if !f.RemoveRequired("github.com/golang/lint/golint") {
+		t.Fatal("expected golint to be removed from the required list")
+	}
+	if f.RemoveConstraint("github.com/sdboyer/deptesttres") {
+		t.Fatal("expected no constraint on deptesttres to be removed")
+	}
+
+	got, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Keep golint around for CI.
+ignored = ["github.com/sdboyer/deptest/bad*"]
+
+# Pinned until the v2 API settles.
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.1.0"
+
+[[override]]
+  branch = "master"
+  expires = "2024-06-01"
+  name = "github.com/sdboyer/deptestdos"
+  source = "github.com/carolynvs/deptestdos"
+`
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
+
+func TestFileEditsValidation(t *testing.T) {
+	f, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetConstraint("github.com/sdboyer/deptest", Rule{Version: "1.0.0", Branch: "master"}); err == nil {
+		t.Error("expected a rule with both a version and a branch to be rejected")
+	}
+	if err := f.SetConstraint("github.com/sdboyer/deptest", Rule{Branch: "master", Expires: time.Now()}); err == nil {
+		t.Error("expected a constraint with an expiry to be rejected")
+	}
+	if err := f.SetOverride("fmt", Rule{}); err == nil {
+		t.Error("expected a standard library package to be rejected")
+	}
+	if err := f.AddRequired("github.com/sdboyer/deptest/"); err == nil {
+		t.Error("expected an invalid import path to be rejected")
+	}
+	if err := f.AddIgnored("github.com/golang/lint/*"); err == nil {
+		t.Error("expected ignoring a required package to be rejected")
+	}
+	if err := f.AddRequired("github.com/sdboyer/deptest/cmd"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Keep golint around for CI.
+required = [
+  "github.com/golang/lint/golint",
+  "github.com/sdboyer/deptest/cmd",
+]
+
+# Pinned until the v2 API settles.
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+`
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	f, err := Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetConstraint("github.com/sdboyer/deptest", Rule{Revision: "ff2948a2ac8f538c4ecd55962e919d1e13e74baf"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[constraint]]\n  name = \"github.com/sdboyer/deptest\"\n  revision = \"ff2948a2ac8f538c4ecd55962e919d1e13e74baf\"\n"
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
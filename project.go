@@ -7,8 +7,10 @@ package dep
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/golang/dep/gps"
@@ -133,7 +135,7 @@ type Project struct {
 func (p *Project) VerifyVendor() (map[string]verify.VendorStatus, error) {
 	p.CheckVendor.Do(func() {
 		p.VendorStatus = make(map[string]verify.VendorStatus)
-		vendorDir := filepath.Join(p.AbsRoot, "vendor")
+		vendorDir := p.VendorDir()
 
 		var lps []gps.LockedProject
 		if p.Lock != nil {
@@ -147,16 +149,46 @@ func (p *Project) VerifyVendor() (map[string]verify.VendorStatus, error) {
 		}
 
 		sums := make(map[string]verify.VersionedDigest)
+		generated := make(map[string][]string)
 		for _, lp := range lps {
-			sums[string(lp.Ident().ProjectRoot)] = lp.(verify.VerifiableProject).Digest
+			vp := lp.(verify.VerifiableProject)
+			sums[string(lp.Ident().ProjectRoot)] = vp.Digest
+			generated[string(lp.Ident().ProjectRoot)] = vp.Generated
 		}
 
-		p.VendorStatus, p.CheckVendorErr = verify.CheckDepTree(vendorDir, sums)
+		p.VendorStatus, p.CheckVendorErr = verify.CheckDepTreeExcluding(vendorDir, sums, generated)
+		if p.CheckVendorErr == nil && p.Manifest != nil {
+			skipVerification(p.VendorStatus, p.Manifest.NoVerify)
+		}
+		for _, lp := range lps {
+			pr := lp.Ident().ProjectRoot
+			if status, has := p.VendorStatus[string(pr)]; has {
+				gps.SendEvent(gps.Event{Kind: gps.EventProjectVerified, ProjectRoot: pr, Version: lp.Version(), Status: status.String()})
+			}
+		}
 	})
 
 	return p.VendorStatus, p.CheckVendorErr
 }
 
+// skipVerification marks the projects in noverify whose vendored code could
+// not be verified as DigestMismatchSkipped, so that they are neither reported
+// as failures nor rewritten.
+func skipVerification(status map[string]verify.VendorStatus, noverify []string) {
+	for _, pr := range noverify {
+		switch status[pr] {
+		case verify.DigestMismatchInLock, verify.EmptyDigestInLock, verify.HashVersionMismatch:
+			status[pr] = verify.DigestMismatchSkipped
+		}
+	}
+}
+
+// VendorDir returns the absolute path to the project's vendor directory, as
+// configured by the manifest's vendor-dir, or DefaultVendorDir if unset.
+func (p *Project) VendorDir() string {
+	return filepath.Join(p.AbsRoot, p.Manifest.VendorPath())
+}
+
 // SetRoot sets the project AbsRoot and ResolvedAbsRoot. If root is not a symlink, ResolvedAbsRoot will be set to root.
 func (p *Project) SetRoot(root string) error {
 	rroot, err := filepath.EvalSymlinks(root)
@@ -179,6 +211,11 @@ func (p *Project) MakeParams() gps.SolveParameters {
 
 	if p.Manifest != nil {
 		params.Manifest = p.Manifest
+		params.LockPreference = p.Manifest.LockPreference
+		params.RecordDecisions = p.Manifest.RecordDecisions
+		for _, pr := range p.Manifest.Freeze {
+			params.Frozen = append(params.Frozen, gps.ProjectRoot(pr))
+		}
 	}
 
 	// It should be impossible for p.ChangedLock to be nil if p.Lock is non-nil;
@@ -208,6 +245,17 @@ func (p *Project) parseRootPackageTree() (pkgtree.PackageTree, error) {
 			ig = p.Manifest.IgnoredPackages()
 		}
 		p.RootPackageTree = ptree.TrimHiddenPackages(true, true, ig)
+
+		// ListPackages only knows to skip directories named vendor, so an
+		// alternate vendor-dir has to be excised from the tree explicitly.
+		if p.Manifest != nil && p.Manifest.VendorDir != "" {
+			vprefix := path.Join(string(p.ImportRoot), p.Manifest.VendorDir)
+			for ip := range p.RootPackageTree.Packages {
+				if ip == vprefix || strings.HasPrefix(ip, vprefix+"/") {
+					delete(p.RootPackageTree.Packages, ip)
+				}
+			}
+		}
 	}
 	return p.RootPackageTree, nil
 }
@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -141,6 +143,7 @@ func TestCheckGopkgFilenames(t *testing.T) {
 func TestProjectMakeParams(t *testing.T) {
 	m := NewManifest()
 	m.Ignored = []string{"ignoring this"}
+	m.Freeze = []string{"github.com/foo/fragile"}
 
 	p := Project{
 		AbsRoot:    "someroot",
@@ -159,6 +162,32 @@ func TestProjectMakeParams(t *testing.T) {
 	if solveParam.Lock != p.Lock {
 		t.Error("makeParams() returned gps.SolveParameters with incorrect Lock")
 	}
+
+	if !reflect.DeepEqual(solveParam.Frozen, []gps.ProjectRoot{"github.com/foo/fragile"}) {
+		t.Errorf("makeParams() returned gps.SolveParameters with incorrect Frozen %v", solveParam.Frozen)
+	}
+}
+
+func TestSkipVerification(t *testing.T) {
+	status := map[string]verify.VendorStatus{
+		"github.com/foo/patched":   verify.DigestMismatchInLock,
+		"github.com/foo/unhashed":  verify.EmptyDigestInLock,
+		"github.com/foo/missing":   verify.NotInTree,
+		"github.com/foo/unlisted":  verify.DigestMismatchInLock,
+		"github.com/foo/unchanged": verify.NoMismatch,
+	}
+	skipVerification(status, []string{"github.com/foo/patched", "github.com/foo/unhashed", "github.com/foo/missing", "github.com/foo/unchanged", "github.com/foo/absent"})
+
+	want := map[string]verify.VendorStatus{
+		"github.com/foo/patched":   verify.DigestMismatchSkipped,
+		"github.com/foo/unhashed":  verify.DigestMismatchSkipped,
+		"github.com/foo/missing":   verify.NotInTree,
+		"github.com/foo/unlisted":  verify.DigestMismatchInLock,
+		"github.com/foo/unchanged": verify.NoMismatch,
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("unexpected vendor status:\n\t(GOT): %v\n\t(WNT): %v", status, want)
+	}
 }
 
 func TestBackupVendor(t *testing.T) {
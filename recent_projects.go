@@ -0,0 +1,112 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RecentProjectsName is the name of the file, in the cache directory, that
+// records the projects whose locks have been loaded recently.
+const RecentProjectsName = "recent-projects"
+
+// Projects not loaded for this long are dropped from the record.
+const recentProjectsMaxAge = 30 * 24 * time.Hour
+
+// readRecentProjects reads the record of recent projects in cachedir, mapping
+// each project root to the time it was last loaded. A missing record is empty.
+func readRecentProjects(cachedir string) (map[string]time.Time, error) {
+	seen := make(map[string]time.Time)
+	f, err := os.Open(filepath.Join(cachedir, RecentProjectsName))
+	if os.IsNotExist(err) {
+		return seen, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if t := time.Unix(sec, 0); t.After(seen[fields[1]]) {
+			seen[fields[1]] = t
+		}
+	}
+	return seen, errors.Wrapf(scanner.Err(), "failed to read %s", f.Name())
+}
+
+// recordRecentProject records in cachedir that the lock of the project at root
+// was loaded at now. Concurrent records may overwrite one another, in which
+// case one of them is lost until the project is next loaded.
+func recordRecentProject(cachedir, root string, now time.Time) error {
+	seen, err := readRecentProjects(cachedir)
+	if err != nil {
+		return err
+	}
+	seen[root] = now
+
+	roots := make([]string, 0, len(seen))
+	for r, t := range seen {
+		if now.Sub(t) < recentProjectsMaxAge {
+			roots = append(roots, r)
+		}
+	}
+	sort.Strings(roots)
+
+	tmp, err := ioutil.TempFile(cachedir, RecentProjectsName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, r := range roots {
+		fmt.Fprintf(w, "%d\t%s\n", seen[r].Unix(), r)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(cachedir, RecentProjectsName))
+}
+
+// RecentProjects returns the roots of the projects, sorted, whose locks have
+// been loaded since the given time by a Ctx with the given cache directory
+// and a positive CacheAge.
+func RecentProjects(cachedir string, since time.Time) ([]string, error) {
+	seen, err := readRecentProjects(cachedir)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []string
+	for r, t := range seen {
+		if !t.Before(since) {
+			roots = append(roots, r)
+		}
+	}
+	sort.Strings(roots)
+	return roots, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecentProjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-recent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if roots, err := RecentProjects(dir, time.Time{}); err != nil || len(roots) != 0 {
+		t.Fatalf("expected no recent projects without a record, got %v, %v", roots, err)
+	}
+
+	now := time.Now()
+	for root, at := range map[string]time.Time{
+		"/src/old":   now.Add(-2 * recentProjectsMaxAge),
+		"/src/week":  now.Add(-7 * 24 * time.Hour),
+		"/src/today": now.Add(-time.Hour),
+	} {
+		if err := recordRecentProject(dir, root, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Loading again moves the project up.
+	if err := recordRecentProject(dir, "/src/week", now); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := RecentProjects(dir, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/src/today", "/src/week"}; !reflect.DeepEqual(roots, want) {
+		t.Errorf("expected %v, got %v", want, roots)
+	}
+
+	roots, err = RecentProjects(dir, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/src/today", "/src/week"}; !reflect.DeepEqual(roots, want) {
+		t.Errorf("expected projects past the maximum age to be dropped, got %v", roots)
+	}
+}
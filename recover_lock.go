@@ -0,0 +1,194 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// VendoredProjectRoots returns the roots of the projects in vendorDir, in
+// order, as deduced from their paths within it by sm. The whole tree is
+// searched, as projects may be nested within others, such as the major version
+// root github.com/foo/bar/v2 within github.com/foo/bar.
+func VendoredProjectRoots(vendorDir string, sm gps.SourceManager) ([]gps.ProjectRoot, error) {
+	var roots []gps.ProjectRoot
+	seen := make(map[gps.ProjectRoot]bool)
+	queue := []string{""}
+	for len(queue) > 0 {
+		rel := queue[0]
+		queue = queue[1:]
+
+		fis, err := ioutil.ReadDir(filepath.Join(vendorDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", filepath.Join(vendorDir, rel))
+		}
+		for _, fi := range fis {
+			name := fi.Name()
+			if !fi.IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" {
+				continue
+			}
+			ip := name
+			if rel != "" {
+				ip = rel + "/" + name
+			}
+
+			root, err := sm.DeduceProjectRoot(ip)
+			if err == nil && !seen[root] && (string(root) == ip || strings.HasPrefix(ip, string(root)+"/")) {
+				seen[root] = true
+				roots = append(roots, root)
+			}
+			queue = append(queue, ip)
+		}
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+	return roots, nil
+}
+
+// RecoverLock reconstructs, as best it can, the lock that p's vendor directory
+// was written from, for projects whose Gopkg.lock was lost or corrupted. Each
+// project in vendor is matched against the versions of its source, newest
+// first, by comparing the digest of its vendored tree to that of the tree each
+// version would be vendored as under p's prune options.
+//
+// The projects for which no version matches, because their vendored code was
+// modified or their versions have since disappeared upstream, are returned
+// rather than locked; solving again will choose versions for them.
+func RecoverLock(p *Project, sm gps.SourceManager, logger *log.Logger) (*Lock, []gps.ProjectRoot, error) {
+	if logger == nil {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	roots, err := VendoredProjectRoots(p.VendorDir(), sm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	td, err := ioutil.TempDir("", "dep-recover-lock")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	l := &Lock{
+		SolveMeta: SolveMeta{
+			AnalyzerName:      Analyzer{}.Info().Name,
+			AnalyzerVersion:   Analyzer{}.Info().Version,
+			InputImports:      externalImportList(p.RootPackageTree, p.Manifest),
+			InputGoVersions:   gps.GoVersionInputs(p.Manifest),
+			InputBuildContext: gps.InputBuildContext(p.Manifest),
+		},
+	}
+	var unmatched []gps.ProjectRoot
+	prune := p.Manifest.PruneOptions
+	for i, pr := range roots {
+		logger.Printf("(%d/%d) Matching %s\n", i+1, len(roots), pr)
+		nested := verify.NestedRootsIn(pr, roots)
+		vp, err := recoverProject(pr, filepath.Join(p.VendorDir(), string(pr)), nested, sm, prune, filepath.Join(td, string(pr)))
+		if err != nil {
+			return nil, nil, err
+		}
+		if vp == nil {
+			unmatched = append(unmatched, pr)
+			continue
+		}
+		l.P = append(l.P, *vp)
+	}
+	return l, unmatched, nil
+}
+
+// recoverProject returns the locked project for a version of pr which, when
+// vendored with the given prune options, has the same digest as dir, or nil if
+// there is none. The directories of the projects nested within pr, as returned
+// by verify.NestedRootsIn, and the generated files given for pr by the prune
+// options are left out of both digests. Candidate trees are written beneath
+// scratch.
+func recoverProject(pr gps.ProjectRoot, dir string, nested []string, sm gps.SourceManager, prune gps.CascadingPruneOptions, scratch string) (*verify.VerifiableProject, error) {
+	ex := verify.DigestExclusions{Nested: nested, Generated: prune.Generated[pr]}
+	want, err := verify.DigestFromDirectoryExcluding(dir, ex)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while hashing tree of %s in vendor", pr)
+	}
+
+	// The vendored packages are those that were locked, as pruning of unused
+	// packages only keeps those. Those of nested projects are their own.
+	ptree, err := pkgtree.ListPackages(dir, string(pr))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the vendored packages of %s", pr)
+	}
+	var pkgs []string
+packages:
+	for ip, perr := range ptree.Packages {
+		if perr.Err != nil {
+			continue
+		}
+		if ip == string(pr) {
+			pkgs = append(pkgs, ".")
+			continue
+		}
+		rel := strings.TrimPrefix(ip, string(pr)+"/")
+		for _, n := range nested {
+			if rel == n || strings.HasPrefix(rel, n+"/") {
+				continue packages
+			}
+		}
+		pkgs = append(pkgs, rel)
+	}
+	sort.Strings(pkgs)
+
+	id := gps.ProjectIdentifier{ProjectRoot: pr}
+	pvs, err := sm.ListVersions(id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the versions of %s", pr)
+	}
+	gps.SortPairedForUpgrade(pvs)
+
+	tried := make(map[gps.Revision]bool)
+	for k, pv := range pvs {
+		if tried[pv.Revision()] {
+			continue
+		}
+		tried[pv.Revision()] = true
+
+		vp := verify.VerifiableProject{
+			LockedProject: gps.NewLockedProject(id, pv, pkgs),
+			PruneOpts:     prune.PruneOptionsFor(pr),
+			Assets:        prune.Assets[pr],
+			Generated:     prune.Generated[pr],
+		}
+		to := filepath.Join(scratch, pv.Revision().String())
+		if err := sm.ExportPrunedProject(context.TODO(), vp, vp.PruneOpts, to); err != nil {
+			return nil, errors.Wrapf(err, "failed to export %s@%s (%d/%d)", pr, pv, k+1, len(pvs))
+		}
+		got, err := verify.DigestFromDirectoryExcluding(to, ex)
+		os.RemoveAll(to)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error while hashing tree of %s@%s", pr, pv)
+		}
+		if !bytes.Equal(got.Digest, want.Digest) {
+			continue
+		}
+
+		vp.Digest = want
+		vp.SourceURL, err = sm.SourceURLFor(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error while determining source URL of %s", pr)
+		}
+		return &vp, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// recoverSM deduces github.com project roots, including major version roots
+// such as github.com/foo/bar/v2, and serves each revision of a
+// project as a single Go file holding the revision.
+type recoverSM struct {
+	gps.SourceManager
+	versions map[gps.ProjectRoot][]gps.PairedVersion
+}
+
+func (sm recoverSM) DeduceProjectRoot(ip string) (gps.ProjectRoot, error) {
+	parts := strings.Split(ip, "/")
+	if parts[0] != "github.com" || len(parts) < 3 {
+		return "", errors.Errorf("cannot deduce %s", ip)
+	}
+	if len(parts) > 3 {
+		if _, _, ok := gps.SplitMajorVersion(gps.ProjectRoot(strings.Join(parts[:4], "/"))); ok {
+			return gps.ProjectRoot(strings.Join(parts[:4], "/")), nil
+		}
+	}
+	return gps.ProjectRoot(strings.Join(parts[:3], "/")), nil
+}
+
+func (sm recoverSM) ListVersions(id gps.ProjectIdentifier) ([]gps.PairedVersion, error) {
+	return sm.versions[id.ProjectRoot], nil
+}
+
+func (sm recoverSM) ExportPrunedProject(ctx context.Context, lp gps.LockedProject, prune gps.PruneOptions, to string) error {
+	return writeRevisionFile(to, lp.Version().(gps.PairedVersion).Revision())
+}
+
+func (sm recoverSM) SourceURLFor(id gps.ProjectIdentifier) (string, error) {
+	return "https://" + string(id.ProjectRoot) + ".git", nil
+}
+
+func writeRevisionFile(dir string, rev gps.Revision) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "rev.go"), []byte("package rev // "+string(rev)+"\n"), 0666)
+}
+
+func TestRecoverLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-recover-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vendor := filepath.Join(dir, "vendor")
+	for pr, rev := range map[string]gps.Revision{
+		"github.com/foo/bar":      "bbb",
+		"github.com/foo/modified": "local",
+	} {
+		if err := writeRevisionFile(filepath.Join(vendor, filepath.FromSlash(pr)), rev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(vendor, "other.org", "pkg"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := recoverSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		"github.com/foo/bar": {
+			gps.NewBranch("master").Pair("ccc"),
+			gps.NewVersion("v1.0.0").Pair("aaa"),
+			gps.NewVersion("v1.1.0").Pair("bbb"),
+			gps.NewBranch("release").Pair("bbb"),
+		},
+		"github.com/foo/modified": {gps.NewVersion("v1.0.0").Pair("ddd")},
+	}}
+
+	roots, err := VendoredProjectRoots(vendor, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/bar", "github.com/foo/modified"}; !reflect.DeepEqual(roots, want) {
+		t.Fatalf("expected vendored projects %v, got %v", want, roots)
+	}
+
+	p := &Project{AbsRoot: dir, Manifest: NewManifest()}
+	lock, unmatched, err := RecoverLock(p, sm, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/modified"}; !reflect.DeepEqual(unmatched, want) {
+		t.Errorf("expected %v to be unmatched, got %v", want, unmatched)
+	}
+	if len(lock.P) != 1 {
+		t.Fatalf("expected one recovered project, got %v", lock.P)
+	}
+	lp := lock.P[0]
+	if lp.Ident().ProjectRoot != "github.com/foo/bar" || lp.Version().String() != "v1.1.0" {
+		t.Errorf("expected github.com/foo/bar to be locked to the matching tag v1.1.0, got %s@%s", lp.Ident().ProjectRoot, lp.Version())
+	}
+	if want := []string{"."}; !reflect.DeepEqual(lp.Packages(), want) {
+		t.Errorf("expected the vendored packages %v to be locked, got %v", want, lp.Packages())
+	}
+}
+
+func TestRecoverLockNested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-recover-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The major version root github.com/foo/bar/v2 is vendored within
+	// github.com/foo/bar, and each is matched on its own.
+	vendor := filepath.Join(dir, "vendor")
+	for pr, rev := range map[string]gps.Revision{
+		"github.com/foo/bar":    "aaa",
+		"github.com/foo/bar/v2": "bbb",
+	} {
+		if err := writeRevisionFile(filepath.Join(vendor, filepath.FromSlash(pr)), rev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sm := recoverSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		"github.com/foo/bar":    {gps.NewVersion("v1.0.0").Pair("aaa")},
+		"github.com/foo/bar/v2": {gps.NewVersion("v2.0.0").Pair("bbb")},
+	}}
+
+	roots, err := VendoredProjectRoots(vendor, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/bar", "github.com/foo/bar/v2"}; !reflect.DeepEqual(roots, want) {
+		t.Fatalf("expected vendored projects %v, got %v", want, roots)
+	}
+
+	p := &Project{AbsRoot: dir, Manifest: NewManifest()}
+	lock, unmatched, err := RecoverLock(p, sm, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unmatched) != 0 {
+		t.Errorf("expected all projects to be matched, got %v unmatched", unmatched)
+	}
+	if len(lock.P) != 2 {
+		t.Fatalf("expected two recovered projects, got %v", lock.P)
+	}
+	for i, want := range []string{"v1.0.0", "v2.0.0"} {
+		if got := lock.P[i].Version().String(); got != want {
+			t.Errorf("expected %s to be locked to %s, got %s", lock.P[i].Ident().ProjectRoot, want, got)
+		}
+		if want := []string{"."}; !reflect.DeepEqual(lock.P[i].Packages(), want) {
+			t.Errorf("expected the packages %v of %s to be locked, got %v", want, lock.P[i].Ident().ProjectRoot, lock.P[i].Packages())
+		}
+	}
+}
+
+func TestRecoverLockGenerated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-recover-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The vendored project holds a file generated after it was vendored,
+	// which is not part of its digest.
+	pdir := filepath.Join(dir, "vendor", "github.com", "foo", "bar")
+	if err := writeRevisionFile(pdir, "aaa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(pdir, "rev.pb.go"), []byte("package rev\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := recoverSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		"github.com/foo/bar": {gps.NewVersion("v1.0.0").Pair("aaa")},
+	}}
+	p := &Project{AbsRoot: dir, Manifest: NewManifest()}
+	p.Manifest.PruneOptions.Generated = map[gps.ProjectRoot][]string{"github.com/foo/bar": {"*.pb.go"}}
+	lock, unmatched, err := RecoverLock(p, sm, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unmatched) != 0 || len(lock.P) != 1 {
+		t.Fatalf("expected github.com/foo/bar to be matched, got %v locked and %v unmatched", lock.P, unmatched)
+	}
+	vp := lock.P[0].(verify.VerifiableProject)
+	if want := []string{"*.pb.go"}; !reflect.DeepEqual(vp.Generated, want) {
+		t.Errorf("expected the generated files %v to be locked, got %v", want, vp.Generated)
+	}
+}
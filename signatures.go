@@ -0,0 +1,205 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang/dep/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// SignaturesName is the name of the file, in the project root, holding the
+// detached signatures of locked revisions.
+const SignaturesName = "Gopkg.sigs"
+
+// A Signature is a maintainer's signature of a revision of a project, made
+// with the key whose fingerprint is Key. What is signed is SignedMessage.
+type Signature struct {
+	ProjectRoot gps.ProjectRoot `toml:"name"`
+	Revision    gps.Revision    `toml:"revision"`
+	Key         string          `toml:"key"`
+	// Signature is the base64-encoded signature: PKCS #1 v1.5 for an RSA
+	// key, and ASN.1 DER for an ECDSA key, both over the SHA-256 hash of the
+	// message, as openssl dgst -sha256 -sign makes them.
+	Signature string `toml:"signature"`
+}
+
+type rawSignatures struct {
+	Signatures []Signature `toml:"signature"`
+}
+
+// SignedMessage returns the message signed for revision rev of the project
+// pr: a version line, followed by the project root and the revision, each on
+// a line of its own.
+func SignedMessage(pr gps.ProjectRoot, rev gps.Revision) []byte {
+	return []byte(fmt.Sprintf("dep-signature-v1\n%s\n%s\n", pr, rev))
+}
+
+// ReadSignatures returns the signatures in the file at path. A missing file
+// has no signatures.
+func ReadSignatures(path string) ([]Signature, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", path)
+	}
+	var raw rawSignatures
+	if err := toml.Unmarshal(b, &raw); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", path)
+	}
+	return raw.Signatures, nil
+}
+
+// A Keyring holds the public keys of the maintainers whose signatures are
+// trusted, by fingerprint.
+type Keyring map[string]crypto.PublicKey
+
+// ReadKeyring reads a keyring from the file at path, holding the PEM-encoded
+// RSA or ECDSA public keys of the maintainers, each in a PUBLIC KEY block, as
+// openssl pkey -pubout writes them.
+func ReadKeyring(path string) (Keyring, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the keyring")
+	}
+
+	kr := make(Keyring)
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse a key in %s", path)
+		}
+		fp, err := KeyFingerprint(pub)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unsupported key in %s", path)
+		}
+		kr[fp] = pub
+	}
+	if len(kr) == 0 {
+		return nil, errors.Errorf("no public keys in %s", path)
+	}
+	return kr, nil
+}
+
+// KeyFingerprint returns the fingerprint by which signatures name the public
+// key pub: the first 16 hex digits of the SHA-256 hash of its PKIX encoding.
+func KeyFingerprint(pub crypto.PublicKey) (string, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return "", errors.Errorf("keys of type %T are not supported", pub)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Verify returns an error unless s is a valid signature by a key in kr.
+func (kr Keyring) Verify(s Signature) error {
+	pub, has := kr[s.Key]
+	if !has {
+		return errors.Errorf("signed with key %s, which is not in the keyring", s.Key)
+	}
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return errors.Wrap(err, "malformed signature")
+	}
+	sum := sha256.Sum256(SignedMessage(s.ProjectRoot, s.Revision))
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+			return errors.Errorf("invalid signature by key %s", s.Key)
+		}
+	case *ecdsa.PublicKey:
+		var rs struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &rs); err != nil || len(rest) > 0 || !ecdsa.Verify(pub, sum[:], rs.R, rs.S) {
+			return errors.Errorf("invalid signature by key %s", s.Key)
+		}
+	}
+	return nil
+}
+
+// An UnsignedProject is a project whose locked revision has no valid signature
+// by a key in the keyring.
+type UnsignedProject struct {
+	ProjectRoot gps.ProjectRoot
+	Revision    gps.Revision
+	// Invalid holds the reasons each signature of the revision was rejected;
+	// it is empty if there were none.
+	Invalid []string
+}
+
+func (u UnsignedProject) String() string {
+	if len(u.Invalid) == 0 {
+		return fmt.Sprintf("%s at %s: no signature", u.ProjectRoot, u.Revision)
+	}
+	s := fmt.Sprintf("%s at %s: no valid signature", u.ProjectRoot, u.Revision)
+	for _, reason := range u.Invalid {
+		s += "\n    " + reason
+	}
+	return s
+}
+
+// CheckSignatures returns the projects in l whose locked revision has no valid
+// signature in sigs by a key in kr, in the order of l.
+func CheckSignatures(l *Lock, sigs []Signature, kr Keyring) []UnsignedProject {
+	byProject := make(map[gps.ProjectRoot][]Signature)
+	for _, s := range sigs {
+		byProject[s.ProjectRoot] = append(byProject[s.ProjectRoot], s)
+	}
+
+	var unsigned []UnsignedProject
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		rev, _, _ := gps.VersionComponentStrings(lp.Version())
+		u := UnsignedProject{ProjectRoot: pr, Revision: gps.Revision(rev)}
+		valid := false
+		for _, s := range byProject[pr] {
+			if s.Revision != u.Revision {
+				continue
+			}
+			if err := kr.Verify(s); err != nil {
+				u.Invalid = append(u.Invalid, err.Error())
+				continue
+			}
+			valid = true
+			break
+		}
+		if !valid {
+			sort.Strings(u.Invalid)
+			unsigned = append(unsigned, u)
+		}
+	}
+	return unsigned
+}
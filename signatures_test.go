@@ -0,0 +1,126 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestCheckSignatures(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keyring bytes.Buffer
+	for _, pub := range []crypto.PublicKey{&rsaKey.PublicKey, &ecKey.PublicKey} {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pem.Encode(&keyring, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+	h.TempFile("keyring.pem", keyring.String())
+	kr, err := ReadKeyring(h.Path("keyring.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kr) != 2 {
+		t.Fatalf("expected 2 keys in the keyring, got %d", len(kr))
+	}
+
+	sign := func(key crypto.Signer, pr, rev string) string {
+		fp, err := KeyFingerprint(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(SignedMessage(gps.ProjectRoot(pr), gps.Revision(rev)))
+		var sig []byte
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		case *ecdsa.PrivateKey:
+			var r, s *big.Int
+			if r, s, err = ecdsa.Sign(rand.Reader, key, sum[:]); err == nil {
+				sig, err = asn1.Marshal(struct{ R, S *big.Int }{r, s})
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("[[signature]]\n  name = %q\n  revision = %q\n  key = %q\n  signature = %q\n\n",
+			pr, rev, fp, base64.StdEncoding.EncodeToString(sig))
+	}
+	sigs := sign(rsaKey, "github.com/foo/rsa", "aaa") +
+		sign(ecKey, "github.com/foo/ecdsa", "bbb") +
+		sign(ecKey, "github.com/foo/old", "ccc") +
+		sign(untrusted, "github.com/foo/untrusted", "ddd")
+	// A signature of one project does not hold for another.
+	sigs += strings.Replace(sign(rsaKey, "github.com/foo/rsa", "eee"), "github.com/foo/rsa", "github.com/foo/forged", 1)
+	h.TempFile(SignaturesName, sigs)
+
+	signatures, err := ReadSignatures(h.Path(SignaturesName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := func(pr, rev string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr)}, gps.NewVersion("v1.0.0").Pair(gps.Revision(rev)), []string{"."})
+	}
+	l := &Lock{P: []gps.LockedProject{
+		locked("github.com/foo/rsa", "aaa"),
+		locked("github.com/foo/ecdsa", "bbb"),
+		locked("github.com/foo/old", "ccd"),
+		locked("github.com/foo/untrusted", "ddd"),
+		locked("github.com/foo/forged", "eee"),
+		locked("github.com/foo/none", "ggg"),
+	}}
+
+	var got []string
+	for _, u := range CheckSignatures(l, signatures, kr) {
+		got = append(got, fmt.Sprintf("%s %d", u.ProjectRoot, len(u.Invalid)))
+	}
+	want := []string{
+		"github.com/foo/old 0",
+		"github.com/foo/untrusted 1",
+		"github.com/foo/forged 1",
+		"github.com/foo/none 0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected unsigned projects:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	if sigs, err := ReadSignatures(filepath.Join(h.Path("."), "missing")); err != nil || sigs != nil {
+		t.Errorf("expected no signatures from a missing file, got %v, %v", sigs, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// A snapshot archive is a gzipped tarball holding the state of a project: its
+// manifest and lock, as ManifestName and LockName, a snapshotIndexName entry
+// describing the rest, and the source bundle of its locked projects, under
+// snapshotBundleDir; see gps.WriteSourceBundle.
+const (
+	snapshotIndexName = "snapshot.json"
+	snapshotBundleDir = "bundle"
+)
+
+// Snapshot is the state of a project captured by WriteSnapshot, from which a
+// resolution can later be reproduced exactly as it happened.
+type Snapshot struct {
+	// Created is when the snapshot was taken.
+	Created time.Time
+	// DepVersion, GoVersion and Platform describe the dep that took the
+	// snapshot, as dep version reports them.
+	DepVersion, GoVersion, Platform string
+	// Manifest and Lock hold the contents of the project's manifest and lock
+	// files.
+	Manifest, Lock []byte
+	// Vendor holds the digests of the trees of the locked projects found in
+	// the vendor directory, by project root; see VendorDigests.
+	Vendor map[gps.ProjectRoot]verify.VersionedDigest
+}
+
+// snapshotIndex is the encoding of the parts of a Snapshot not held in files
+// of their own.
+type snapshotIndex struct {
+	Created    time.Time         `json:"created"`
+	DepVersion string            `json:"dep-version,omitempty"`
+	GoVersion  string            `json:"go-version,omitempty"`
+	Platform   string            `json:"platform,omitempty"`
+	Vendor     map[string]string `json:"vendor"`
+}
+
+// VendorDigests returns the digests of the trees of the projects in p's lock
+// that are present in its vendor directory, computed as they are for the lock.
+func (p *Project) VendorDigests() (map[gps.ProjectRoot]verify.VersionedDigest, error) {
+	digests := make(map[gps.ProjectRoot]verify.VersionedDigest)
+	if p.Lock == nil {
+		return digests, nil
+	}
+	for _, lp := range p.Lock.Projects() {
+		pr := lp.Ident().ProjectRoot
+		dir := filepath.Join(p.VendorDir(), filepath.FromSlash(string(pr)))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		ex := verify.DigestExclusions{Nested: verify.NestedProjects(pr, p.Lock)}
+		if vp, ok := lp.(verify.VerifiableProject); ok {
+			ex.Generated = vp.Generated
+		}
+		vd, err := verify.DigestFromDirectoryExcluding(dir, ex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to hash the vendored tree of %s", pr)
+		}
+		digests[pr] = vd
+	}
+	return digests, nil
+}
+
+// WriteSnapshot writes s to w as a snapshot archive, along with the source
+// bundle in bundleDir.
+func WriteSnapshot(w io.Writer, s *Snapshot, bundleDir string) error {
+	idx := snapshotIndex{
+		Created:    s.Created.UTC(),
+		DepVersion: s.DepVersion,
+		GoVersion:  s.GoVersion,
+		Platform:   s.Platform,
+		Vendor:     make(map[string]string, len(s.Vendor)),
+	}
+	for pr, vd := range s.Vendor {
+		idx.Vendor[string(pr)] = vd.String()
+	}
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the snapshot index")
+	}
+
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0666, Size: int64(len(data)), ModTime: idx.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := add(snapshotIndexName, b); err != nil {
+		return err
+	}
+	if err := add(ManifestName, s.Manifest); err != nil {
+		return err
+	}
+	if s.Lock != nil {
+		if err := add(LockName, s.Lock); err != nil {
+			return err
+		}
+	}
+
+	fis, err := ioutil.ReadDir(bundleDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the source bundle")
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(bundleDir, fi.Name()))
+		if err != nil {
+			return errors.Wrap(err, "failed to read the source bundle")
+		}
+		if err := add(path.Join(snapshotBundleDir, fi.Name()), data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// ReadSnapshot reads the snapshot archive in r, extracting its source bundle
+// into bundleDir, which must exist.
+func ReadSnapshot(r io.Reader, bundleDir string) (*Snapshot, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a snapshot archive")
+	}
+	tr := tar.NewReader(gzr)
+
+	s := &Snapshot{}
+	var idx *snapshotIndex
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the snapshot archive")
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s from the snapshot archive", hdr.Name)
+		}
+		switch name := path.Clean(hdr.Name); name {
+		case snapshotIndexName:
+			idx = &snapshotIndex{}
+			if err := json.Unmarshal(buf.Bytes(), idx); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse %s in the snapshot archive", snapshotIndexName)
+			}
+		case ManifestName:
+			s.Manifest = buf.Bytes()
+		case LockName:
+			s.Lock = buf.Bytes()
+		default:
+			dir, file := path.Split(name)
+			if dir != snapshotBundleDir+"/" || file == "" || strings.HasPrefix(file, ".") {
+				return nil, errors.Errorf("unexpected entry %q in the snapshot archive", hdr.Name)
+			}
+			if err := ioutil.WriteFile(filepath.Join(bundleDir, file), buf.Bytes(), 0666); err != nil {
+				return nil, errors.Wrap(err, "failed to extract the source bundle")
+			}
+		}
+	}
+	if idx == nil || s.Manifest == nil {
+		return nil, errors.Errorf("not a snapshot archive: %s or %s is missing", snapshotIndexName, ManifestName)
+	}
+
+	s.Created = idx.Created
+	s.DepVersion, s.GoVersion, s.Platform = idx.DepVersion, idx.GoVersion, idx.Platform
+	s.Vendor = make(map[gps.ProjectRoot]verify.VersionedDigest, len(idx.Vendor))
+	for pr, d := range idx.Vendor {
+		vd, err := verify.ParseVersionedDigest(d)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid digest for %s in the snapshot archive", pr)
+		}
+		s.Vendor[gps.ProjectRoot(pr)] = vd
+	}
+	return s, nil
+}
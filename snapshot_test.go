@@ -0,0 +1,79 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("src/root/vendor/github.com/foo/bar")
+	h.TempFile("src/root/vendor/github.com/foo/bar/bar.go", "package bar\n")
+	h.TempDir("bundle")
+	h.TempFile("bundle/github.com-foo-bar.tar.gz", "not really an archive")
+	h.TempDir("restored")
+
+	p := &Project{
+		AbsRoot:  h.Path("src/root"),
+		Manifest: NewManifest(),
+		Lock: &Lock{P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("aaa"), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/missing"}, gps.NewVersion("v1.0.0").Pair("bbb"), []string{"."}),
+		}},
+	}
+	digests, err := p.VendorDigests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := verify.DigestFromDirectory(filepath.Join(p.VendorDir(), "github.com", "foo", "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(digests) != 1 || digests["github.com/foo/bar"].String() != want.String() {
+		t.Fatalf("expected only the vendored project to have a digest, got %v", digests)
+	}
+
+	s := &Snapshot{
+		Created:    time.Date(2018, time.July, 1, 12, 0, 0, 0, time.UTC),
+		DepVersion: "v0.5.0",
+		GoVersion:  "go1.10.3",
+		Platform:   "linux/amd64",
+		Manifest:   []byte("[[constraint]]\n  name = \"github.com/foo/bar\"\n  version = \"1.0.0\"\n"),
+		Lock:       []byte("# lock\n"),
+		Vendor:     digests,
+	}
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, s, h.Path("bundle")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadSnapshot(bytes.NewReader(buf.Bytes()), h.Path("restored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("unexpected snapshot:\n\t(GOT) %+v\n\t(WNT) %+v", got, s)
+	}
+	data, err := ioutil.ReadFile(h.Path("restored/github.com-foo-bar.tar.gz"))
+	if err != nil || string(data) != "not really an archive" {
+		t.Errorf("expected the source bundle to be extracted, got %q, %v", data, err)
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader([]byte("not gzipped")), h.Path("restored")); err == nil {
+		t.Error("expected an error reading something other than a snapshot")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// The tolerances of tool-tolerance: how far the version of a tool may drift
+// from the one recorded in the lock.
+const (
+	// ToolTolerateNone requires the version to be the same.
+	ToolTolerateNone = "none"
+	// ToolToleratePatch allows the patch version to differ.
+	ToolToleratePatch = "patch"
+	// ToolTolerateMinor allows the minor and patch versions to differ.
+	ToolTolerateMinor = "minor"
+)
+
+// A vcsToolFinder finds the version control tool that handles the source of a
+// project, as *gps.SourceMgr does.
+type vcsToolFinder interface {
+	VCSToolFor(gps.ProjectIdentifier) (string, error)
+}
+
+// RecordToolVersions records in the solve metadata of l the versions of the
+// version control tools that handle the sources of its projects, in the form
+// "git 2.20.1". Nothing is recorded if sm cannot tell which tools those are.
+func RecordToolVersions(l *Lock, sm gps.SourceManager) error {
+	tf, ok := sm.(vcsToolFinder)
+	if !ok {
+		return nil
+	}
+
+	tools := make(map[string]bool)
+	for _, lp := range l.P {
+		tool, err := tf.VCSToolFor(lp.Ident())
+		if err != nil {
+			return errors.Wrapf(err, "failed to determine the version control tool for %s", lp.Ident().ProjectRoot)
+		}
+		if tool != "" {
+			tools[tool] = true
+		}
+	}
+
+	var tvs []string
+	for tool := range tools {
+		v, err := gps.VCSToolVersion(context.TODO(), tool)
+		if err != nil {
+			return err
+		}
+		tvs = append(tvs, tool+" "+v)
+	}
+	sort.Strings(tvs)
+	l.SolveMeta.ToolVersions = tvs
+	return nil
+}
+
+// ToolDrift is a version control tool whose version differs from the one
+// recorded in a lock by more than is tolerated.
+type ToolDrift struct {
+	Tool string
+	// Locked is the version recorded in the lock, and Current the version
+	// found now, or empty if the tool could not be run.
+	Locked, Current string
+}
+
+func (d ToolDrift) String() string {
+	if d.Current == "" {
+		return fmt.Sprintf("%s: %s recorded in %s, but it could not be run", d.Tool, d.Locked, LockName)
+	}
+	return fmt.Sprintf("%s: %s recorded in %s, but %s found", d.Tool, d.Locked, LockName, d.Current)
+}
+
+// CheckToolVersions compares the versions of the tools recorded in l with
+// those found on PATH, returning those that differ by more than tolerance,
+// one of the ToolTolerate constants, or ToolTolerateNone if it is empty.
+func CheckToolVersions(l *Lock, tolerance string) []ToolDrift {
+	var drift []ToolDrift
+	for _, tv := range l.SolveMeta.ToolVersions {
+		fields := strings.Fields(tv)
+		if len(fields) != 2 {
+			continue
+		}
+		d := ToolDrift{Tool: fields[0], Locked: fields[1]}
+		d.Current, _ = gps.VCSToolVersion(context.TODO(), d.Tool)
+		if d.Current == "" || !toolVersionTolerated(d.Locked, d.Current, tolerance) {
+			drift = append(drift, d)
+		}
+	}
+	return drift
+}
+
+// toolVersionTolerated reports whether the version current of a tool is
+// within tolerance of the version locked.
+func toolVersionTolerated(locked, current, tolerance string) bool {
+	var n int
+	switch tolerance {
+	case ToolToleratePatch:
+		n = 2
+	case ToolTolerateMinor:
+		n = 1
+	default:
+		return locked == current
+	}
+	l, c := strings.Split(locked, "."), strings.Split(current, ".")
+	if len(l) < n || len(c) < n {
+		return locked == current
+	}
+	for i := 0; i < n; i++ {
+		if l[i] != c[i] {
+			return false
+		}
+	}
+	return true
+}
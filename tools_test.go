@@ -0,0 +1,77 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestToolVersionTolerated(t *testing.T) {
+	cases := []struct {
+		locked, current, tolerance string
+		want                       bool
+	}{
+		{"2.20.1", "2.20.1", "", true},
+		{"2.20.1", "2.20.2", "", false},
+		{"2.20.1", "2.20.2", ToolTolerateNone, false},
+		{"2.20.1", "2.20.2", ToolToleratePatch, true},
+		{"2.20.1", "2.21.0", ToolToleratePatch, false},
+		{"2.20.1", "2.21.0", ToolTolerateMinor, true},
+		{"2.20.1", "3.0.0", ToolTolerateMinor, false},
+		{"4.5", "4.5", ToolToleratePatch, true},
+		{"4", "4", ToolToleratePatch, true},
+		{"4", "5", ToolToleratePatch, false},
+	}
+	for _, c := range cases {
+		if got := toolVersionTolerated(c.locked, c.current, c.tolerance); got != c.want {
+			t.Errorf("toolVersionTolerated(%q, %q, %q) = %v, want %v", c.locked, c.current, c.tolerance, got, c.want)
+		}
+	}
+}
+
+func TestCheckToolVersions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not on PATH")
+	}
+	current, err := gps.VCSToolVersion(context.Background(), "git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &Lock{SolveMeta: SolveMeta{ToolVersions: []string{"git " + current}}}
+	if drift := CheckToolVersions(l, ToolTolerateNone); len(drift) != 0 {
+		t.Errorf("expected no drift from the current version of git, got %v", drift)
+	}
+
+	l.SolveMeta.ToolVersions = []string{"git 0.0.1"}
+	want := []ToolDrift{{Tool: "git", Locked: "0.0.1", Current: current}}
+	if drift := CheckToolVersions(l, ToolTolerateMinor); !reflect.DeepEqual(drift, want) {
+		t.Errorf("expected drift %v, got %v", want, drift)
+	}
+}
+
+func TestLockToolVersions(t *testing.T) {
+	l := &Lock{SolveMeta: SolveMeta{ToolVersions: []string{"git 2.20.1", "hg 4.8.2"}}}
+	got, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "tool-versions") {
+		t.Errorf("expected the tool versions in the lock, got:\n%s", got)
+	}
+	rl, err := readLock(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rl.SolveMeta.ToolVersions, l.SolveMeta.ToolVersions) {
+		t.Errorf("expected the tool versions to survive a round trip, got %v in:\n%s", rl.SolveMeta.ToolVersions, got)
+	}
+}
@@ -5,6 +5,7 @@
 package dep
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"fmt"
@@ -12,6 +13,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/verify"
@@ -67,12 +72,19 @@ var lockFileComment = []byte(`# This file is autogenerated, do not edit; changes
 // It is not impervious to errors (writing to disk is hard), but it should
 // guard against non-arcane failure conditions.
 type SafeWriter struct {
-	Manifest     *Manifest
+	Manifest *Manifest
+	// VendorDir is the path to the vendor directory to write. If relative, it
+	// is interpreted relative to the root passed to Write. If empty,
+	// DefaultVendorDir is used.
+	VendorDir    string
 	lock         *Lock
 	lockDiff     verify.LockDelta
 	writeVendor  bool
 	writeLock    bool
 	pruneOptions gps.CascadingPruneOptions
+	// check, if set, is run once the new files are in place; see
+	// NewCheckedWriter.
+	check func() error
 }
 
 // NewSafeWriter sets up a SafeWriter to write a set of manifest, lock, and
@@ -106,6 +118,15 @@ func NewSafeWriter(manifest *Manifest, oldLock, newLock *Lock, vendor VendorBeha
 		if sw.lockDiff.Changed(anyExceptHash) {
 			sw.writeLock = true
 		}
+		// Decisions are not part of the lock's inputs or selections, but
+		// recording them is the point of solving with them on.
+		if newLock.Decisions != nil && !reflect.DeepEqual(oldLock.Decisions, newLock.Decisions) {
+			sw.writeLock = true
+		}
+		// Nor are the versions of the tools, once they are being recorded.
+		if newLock.SolveMeta.ToolVersions != nil && !reflect.DeepEqual(oldLock.SolveMeta.ToolVersions, newLock.SolveMeta.ToolVersions) {
+			sw.writeLock = true
+		}
 	} else if newLock != nil {
 		sw.writeLock = true
 	}
@@ -134,6 +155,11 @@ func (sw *SafeWriter) HasManifest() bool {
 	return sw.Manifest != nil
 }
 
+// HasChanges reports whether Write would write anything.
+func (sw *SafeWriter) HasChanges() bool {
+	return sw.HasManifest() || sw.writeLock || sw.writeVendor
+}
+
 // VendorBehavior defines when the vendor directory should be written.
 type VendorBehavior int
 
@@ -184,12 +210,15 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 
 	if !sw.HasManifest() && !sw.writeLock && !sw.writeVendor {
 		// nothing to do
+		if sw.check != nil {
+			return sw.check()
+		}
 		return nil
 	}
 
 	mpath := filepath.Join(root, ManifestName)
 	lpath := filepath.Join(root, LockName)
-	vpath := filepath.Join(root, "vendor")
+	vpath := sw.vendorPath(root)
 
 	td, err := ioutil.TempDir(os.TempDir(), "dep")
 	if err != nil {
@@ -198,19 +227,22 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 	defer os.RemoveAll(td)
 
 	if sw.HasManifest() {
-		// Always write the example text to the bottom of the TOML file.
-		tb, err := sw.Manifest.MarshalTOML()
+		var tb, initOutput []byte
+		if orig, rerr := ioutil.ReadFile(mpath); rerr == nil {
+			// Edit the existing manifest, so as to keep its comments.
+			tb, err = sw.Manifest.RewriteTOML(orig)
+		} else {
+			tb, err = sw.Manifest.MarshalTOML()
+
+			// If examples are enabled, use the example text
+			if examples {
+				initOutput = exampleTOML
+			}
+		}
 		if err != nil {
 			return errors.Wrap(err, "failed to marshal manifest to TOML")
 		}
 
-		var initOutput []byte
-
-		// If examples are enabled, use the example text
-		if examples {
-			initOutput = exampleTOML
-		}
-
 		if err = ioutil.WriteFile(filepath.Join(td, ManifestName), append(initOutput, tb...), 0666); err != nil {
 			return errors.Wrap(err, "failed to write manifest file to temp dir")
 		}
@@ -223,18 +255,40 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 				logger.Println(progress)
 			}
 		}
+		start := time.Now()
 		err = gps.WriteDepTree(filepath.Join(td, "vendor"), sw.lock, sm, sw.pruneOptions, onWrite)
+		gps.SendEvent(gps.Event{Kind: gps.EventVendorWritten, Duration: time.Since(start), Err: err, Count: len(sw.lock.Projects())})
 		if err != nil {
 			return errors.Wrap(err, "error while writing out vendor tree")
 		}
 
 		for k, lp := range sw.lock.Projects() {
 			vp := lp.(verify.VerifiableProject)
-			vp.Digest, err = verify.DigestFromDirectory(filepath.Join(td, "vendor", string(lp.Ident().ProjectRoot)))
+			pdir := filepath.Join(td, "vendor", string(lp.Ident().ProjectRoot))
+			vp.Generated, err = gps.GeneratedFilesOf(sm, lp, Analyzer{}, sw.pruneOptions)
+			if err != nil {
+				return errors.Wrapf(err, "error while reading the generated files of %s", lp.Ident().ProjectRoot)
+			}
+			nested := verify.NestedProjects(lp.Ident().ProjectRoot, sw.lock)
+			vp.Digest, err = verify.DigestFromDirectoryExcluding(pdir, verify.DigestExclusions{Nested: nested, Generated: vp.Generated})
 			if err != nil {
 				return errors.Wrapf(err, "error while hashing tree of %s in vendor", lp.Ident().ProjectRoot)
 			}
+			vp.SourceURL, err = sm.SourceURLFor(lp.Ident())
+			if err != nil {
+				return errors.Wrapf(err, "error while determining source URL of %s", lp.Ident().ProjectRoot)
+			}
 			sw.lock.P[k] = vp
+
+			prov := verify.NewProvenance(vp, start)
+			prov.Nested = nested
+			if err := verify.WriteProvenance(pdir, prov); err != nil {
+				return err
+			}
+		}
+
+		if err := verify.WriteLockSnapshot(filepath.Join(td, "vendor"), sw.lock); err != nil {
+			return err
 		}
 	}
 
@@ -322,13 +376,38 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 			restore = append(restore, pathpair{from: vendorbak, to: vpath})
 		}
 
-		// Move in the new one.
+		// Move in the new one, making sure a nested vendor-dir has a parent
+		// to land in.
+		failerr = os.MkdirAll(filepath.Dir(vpath), os.FileMode(0777))
+		if failerr != nil {
+			goto fail
+		}
 		failerr = fs.RenameWithFallback(filepath.Join(td, "vendor"), vpath)
 		if failerr != nil {
 			goto fail
 		}
 	}
 
+	if sw.check != nil {
+		if failerr = sw.check(); failerr != nil {
+			// Take the new files back out, so that the originals can be moved
+			// back into place.
+			if sw.HasManifest() {
+				os.Remove(mpath)
+			}
+			if sw.writeLock {
+				os.Remove(lpath)
+			}
+			if sw.writeVendor {
+				if vendorbak != "" && hasDotGit(vpath) {
+					fs.RenameWithFallback(filepath.Join(vpath, ".git"), filepath.Join(vendorbak, ".git"))
+				}
+				os.RemoveAll(vpath)
+			}
+			goto fail
+		}
+	}
+
 	// Renames all went smoothly. The deferred os.RemoveAll will get the temp
 	// dir, but if we wrote vendor, we have to clean that up directly
 	if sw.writeVendor {
@@ -347,6 +426,18 @@ fail:
 	return failerr
 }
 
+// vendorPath returns the absolute path to the vendor directory that will be
+// written beneath root.
+func (sw *SafeWriter) vendorPath(root string) string {
+	switch {
+	case sw.VendorDir == "":
+		return filepath.Join(root, DefaultVendorDir)
+	case filepath.IsAbs(sw.VendorDir):
+		return sw.VendorDir
+	}
+	return filepath.Join(root, sw.VendorDir)
+}
+
 // PrintPreparedActions logs the actions a call to Write would perform.
 func (sw *SafeWriter) PrintPreparedActions(output *log.Logger, verbose bool) error {
 	if output == nil {
@@ -407,6 +498,10 @@ type DeltaWriter struct {
 	vendorDir string
 	changed   map[gps.ProjectRoot]changeType
 	behavior  VendorBehavior
+	prune     gps.CascadingPruneOptions
+	// check, if set, is run once the new lock and vendor directory are in
+	// place; see NewCheckedWriter.
+	check func() error
 }
 
 type changeType uint8
@@ -420,6 +515,7 @@ const (
 	missingFromTree
 	projectAdded
 	projectRemoved
+	nestedChanged
 )
 
 // NewDeltaWriter prepares a vendor writer that will construct a vendor
@@ -432,6 +528,7 @@ func NewDeltaWriter(oldLock, newLock *Lock, status map[string]verify.VendorStatu
 		vendorDir: vendorDir,
 		changed:   make(map[gps.ProjectRoot]changeType),
 		behavior:  behavior,
+		prune:     prune,
 	}
 
 	if newLock == nil {
@@ -442,7 +539,12 @@ func NewDeltaWriter(oldLock, newLock *Lock, status map[string]verify.VendorStatu
 	if err != nil && os.IsNotExist(err) {
 		// Provided dir does not exist, so there's no disk contents to compare
 		// against. Fall back to the old SafeWriter.
-		return NewSafeWriter(nil, oldLock, newLock, behavior, prune)
+		sw, err := NewSafeWriter(nil, oldLock, newLock, behavior, prune)
+		if err != nil {
+			return nil, err
+		}
+		sw.VendorDir = vendorDir
+		return sw, nil
 	}
 
 	sw.lockDiff = verify.DiffLocks(oldLock, newLock)
@@ -483,18 +585,53 @@ func NewDeltaWriter(oldLock, newLock *Lock, status map[string]verify.VendorStatu
 		}
 	}
 
+	// Projects nested within one another, such as two major versions of a
+	// project, share a directory in vendor, so they are moved or rewritten
+	// together.
+	roots := make(map[gps.ProjectRoot]bool, len(newLock.P)+len(sw.changed))
+	for _, lp := range newLock.P {
+		roots[lp.Ident().ProjectRoot] = true
+	}
+	for pr := range sw.changed {
+		roots[pr] = true
+	}
+	changedFamilies := make(map[gps.ProjectRoot]bool)
+	for pr := range sw.changed {
+		changedFamilies[outermostRoot(pr, roots)] = true
+	}
+	for _, lp := range newLock.P {
+		pr := lp.Ident().ProjectRoot
+		if _, has := sw.changed[pr]; !has && changedFamilies[outermostRoot(pr, roots)] {
+			sw.changed[pr] = nestedChanged
+		}
+	}
+
 	return sw, nil
 }
 
+// outermostRoot returns the outermost of the roots that pr is nested within,
+// or pr itself if it is nested within none of them.
+func outermostRoot(pr gps.ProjectRoot, roots map[gps.ProjectRoot]bool) gps.ProjectRoot {
+	outer := pr
+	for dir := string(pr); strings.Contains(dir, "/"); {
+		dir = dir[:strings.LastIndex(dir, "/")]
+		if roots[gps.ProjectRoot(dir)] {
+			outer = gps.ProjectRoot(dir)
+		}
+	}
+	return outer
+}
+
 // Write executes the planned changes.
 //
 // This writes recreated projects to a new directory, then moves in existing,
 // unchanged projects from the original vendor directory. If any failures occur,
 // reasonable attempts are made to roll back the changes.
-func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, logger *log.Logger) error {
+func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, logger *log.Logger) (err error) {
 	// TODO(sdboyer) remove path from the signature for this
-	if path != filepath.Dir(dw.vendorDir) {
-		return errors.Errorf("target path (%q) must be the parent of the original vendor path (%q)", path, dw.vendorDir)
+	rel, err := filepath.Rel(path, dw.vendorDir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.Errorf("target path (%q) must contain the original vendor path (%q)", path, dw.vendorDir)
 	}
 
 	if logger == nil {
@@ -503,6 +640,8 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 
 	lpath := filepath.Join(path, LockName)
 	vpath := dw.vendorDir
+	origLock, lerr := ioutil.ReadFile(lpath)
+	hadLock := lerr == nil
 
 	// Write the modified projects to a new adjacent directory. We use an
 	// adjacent directory to minimize the possibility of cross-filesystem renames
@@ -512,11 +651,28 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 	if _, err := os.Stat(vnewpath); err == nil {
 		return errors.Errorf("scratch directory %s already exists, please remove it", vnewpath)
 	}
-	err := os.MkdirAll(vnewpath, os.FileMode(0777))
+	voldpath := filepath.Join(filepath.Dir(vpath), ".vendor-old")
+	if _, err := os.Stat(voldpath); err == nil {
+		return errors.Errorf("scratch directory %s already exists, please remove it", voldpath)
+	}
+	err = os.MkdirAll(vnewpath, os.FileMode(0777))
 	if err != nil {
 		return errors.Wrapf(err, "error while creating scratch directory at %s", vnewpath)
 	}
 
+	// If any step fails, undo those already taken, latest first, so that the
+	// lock and vendor are left as they were found. Nothing can be done about
+	// errors while undoing, so they are ignored.
+	var undo []func()
+	defer func() {
+		if err != nil {
+			for i := len(undo) - 1; i >= 0; i-- {
+				undo[i]()
+			}
+		}
+	}()
+	undo = append(undo, func() { os.RemoveAll(vnewpath) })
+
 	// Write out all the deltas to the newpath
 	projs := make(map[gps.ProjectRoot]gps.LockedProject)
 	for _, lp := range dw.lock.Projects() {
@@ -529,7 +685,26 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 	if len(dw.changed) > 0 {
 		logger.Println("\n# Bringing vendor into sync")
 	}
-	for pr, reason := range dw.changed {
+	// As for logging, events are only sent if a new vendor dir is left behind.
+	vendorStart := time.Now()
+	sendEvent := func(e gps.Event) {
+		if dw.behavior != VendorNever {
+			gps.SendEvent(e)
+		}
+	}
+	defer func() {
+		sendEvent(gps.Event{Kind: gps.EventVendorWritten, Duration: time.Since(vendorStart), Err: err, Count: i})
+	}()
+	// Projects are written in order, so that those nested within others, such
+	// as a later major version of a project, are written after them.
+	changedRoots := make([]string, 0, len(dw.changed))
+	for pr := range dw.changed {
+		changedRoots = append(changedRoots, string(pr))
+	}
+	sort.Strings(changedRoots)
+	for _, spr := range changedRoots {
+		pr := gps.ProjectRoot(spr)
+		reason := dw.changed[pr]
 		if reason == projectRemoved {
 			dropped = append(dropped, pr)
 			continue
@@ -537,7 +712,16 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 
 		to := filepath.FromSlash(filepath.Join(vnewpath, string(pr)))
 		po := projs[pr].(verify.VerifiableProject).PruneOpts
-		if err := sm.ExportPrunedProject(context.TODO(), projs[pr], po, to); err != nil {
+		start := time.Now()
+		err := sm.ExportPrunedProject(context.TODO(), projs[pr], po, to)
+		sendEvent(gps.Event{
+			Kind:        gps.EventProjectVendored,
+			Duration:    time.Since(start),
+			Err:         err,
+			ProjectRoot: pr,
+			Version:     projs[pr].Version(),
+		})
+		if err != nil {
 			return errors.Wrapf(err, "failed to export %s", pr)
 		}
 
@@ -551,10 +735,19 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 			logger.Printf("(%d/%d) Wrote %s@%s: %s", i, tot, id, v, changeExplanation(reason, lpd))
 		}
 
-		digest, err := verify.DigestFromDirectory(to)
+		generated, err := gps.GeneratedFilesOf(sm, projs[pr], Analyzer{}, dw.prune)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read the generated files of %s", pr)
+		}
+		nested := verify.NestedProjects(pr, dw.lock)
+		digest, err := verify.DigestFromDirectoryExcluding(to, verify.DigestExclusions{Nested: nested, Generated: generated})
 		if err != nil {
 			return errors.Wrapf(err, "failed to hash %s", pr)
 		}
+		surl, err := sm.SourceURLFor(id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to determine source URL of %s", pr)
+		}
 
 		// Update the new Lock with verification information.
 		for k, lp := range dw.lock.P {
@@ -565,6 +758,13 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 					LockedProject: lp,
 					PruneOpts:     po,
 					Digest:        digest,
+					SourceURL:     surl,
+					Generated:     generated,
+				}
+				prov := verify.NewProvenance(dw.lock.P[k].(verify.VerifiableProject), start)
+				prov.Nested = nested
+				if err := verify.WriteProvenance(to, prov); err != nil {
+					return err
 				}
 			}
 		}
@@ -576,18 +776,43 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 		return errors.Wrap(err, "failed to marshal lock to TOML")
 	}
 
+	undo = append(undo, func() {
+		if hadLock {
+			ioutil.WriteFile(lpath, origLock, 0666)
+		} else {
+			os.Remove(lpath)
+		}
+	})
 	if err = ioutil.WriteFile(lpath, append(lockFileComment, l...), 0666); err != nil {
 		return errors.Wrap(err, "failed to write new lock file")
 	}
 
 	if dw.behavior == VendorNever {
-		return os.RemoveAll(vnewpath)
+		if err = os.RemoveAll(vnewpath); err != nil {
+			return err
+		}
+		if dw.check != nil {
+			return dw.check()
+		}
+		return nil
+	}
+
+	if err = verify.WriteLockSnapshot(vnewpath, dw.lock); err != nil {
+		return err
 	}
 
 	// Changed projects are fully populated. Now, iterate over the lock's
 	// projects and move any remaining ones not in the changed list to vnewpath.
+	lockRoots := make(map[gps.ProjectRoot]bool, len(projs))
+	for pr := range projs {
+		lockRoots[pr] = true
+	}
 	for _, lp := range dw.lock.Projects() {
 		pr := lp.Ident().ProjectRoot
+		if _, has := dw.changed[pr]; !has && outermostRoot(pr, lockRoots) != pr {
+			// Moved along with the project it is nested within.
+			continue
+		}
 		tgt := filepath.Join(vnewpath, string(pr))
 		err := os.MkdirAll(filepath.Dir(tgt), os.FileMode(0777))
 		if err != nil {
@@ -595,10 +820,12 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 		}
 
 		if _, has := dw.changed[pr]; !has {
-			err = fs.RenameWithFallback(filepath.Join(vpath, string(pr)), tgt)
+			src := filepath.Join(vpath, string(pr))
+			err = fs.RenameWithFallback(src, tgt)
 			if err != nil {
 				return errors.Wrapf(err, "error moving unchanged project %s into scratch vendor dir", pr)
 			}
+			undo = append(undo, func() { fs.RenameWithFallback(tgt, src) })
 		}
 	}
 
@@ -609,20 +836,37 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 
 	// Ensure vendor/.git is preserved if present
 	if hasDotGit(vpath) {
-		err = fs.RenameWithFallback(filepath.Join(vpath, ".git"), filepath.Join(vnewpath, "vendor/.git"))
+		gitpath, newgitpath := filepath.Join(vpath, ".git"), filepath.Join(vnewpath, "vendor/.git")
+		err = fs.RenameWithFallback(gitpath, newgitpath)
 		if _, ok := err.(*os.LinkError); ok {
 			return errors.Wrap(err, "failed to preserve vendor/.git")
 		}
+		if err == nil {
+			undo = append(undo, func() { fs.RenameWithFallback(newgitpath, gitpath) })
+		}
 	}
-	err = os.RemoveAll(vpath)
+
+	// Set the original vendor directory aside, rather than removing it, until
+	// the new one is in place.
+	err = fs.RenameWithFallback(vpath, voldpath)
 	if err != nil {
-		return errors.Wrap(err, "failed to remove original vendor directory")
+		return errors.Wrap(err, "failed to move aside original vendor directory")
 	}
+	undo = append(undo, func() { fs.RenameWithFallback(voldpath, vpath) })
 	err = fs.RenameWithFallback(vnewpath, vpath)
 	if err != nil {
 		return errors.Wrap(err, "failed to put new vendor directory into place")
 	}
+	undo = append(undo, func() { fs.RenameWithFallback(vpath, vnewpath) })
+
+	if dw.check != nil {
+		if err = dw.check(); err != nil {
+			return err
+		}
+	}
 
+	// Nothing we can really do about an error at this point, so ignore it.
+	os.RemoveAll(voldpath)
 	return nil
 }
 
@@ -656,6 +900,9 @@ func changeExplanation(c changeType, lpd verify.LockedProjectDelta) string {
 		// value from the input param in place.
 		old := lpd.PruneOptsBefore & ^gps.PruneNestedVendorDirs
 		new := lpd.PruneOptsAfter & ^gps.PruneNestedVendorDirs
+		if old == new {
+			return "asset patterns changed"
+		}
 		return fmt.Sprintf("prune options changed (%s -> %s)", old, new)
 	case hashMismatch:
 		return "hash of vendored tree didn't match digest in Gopkg.lock"
@@ -667,6 +914,8 @@ func changeExplanation(c changeType, lpd verify.LockedProjectDelta) string {
 		return "new project"
 	case missingFromTree:
 		return "missing from vendor"
+	case nestedChanged:
+		return "shares its vendor directory with a changed project"
 	default:
 		panic(fmt.Sprintf("unrecognized changeType value %v", c))
 	}
@@ -674,16 +923,25 @@ func changeExplanation(c changeType, lpd verify.LockedProjectDelta) string {
 	return ""
 }
 
+// HasChanges reports whether Write would change the lock, or any project in the
+// vendor directory.
+func (dw *DeltaWriter) HasChanges() bool {
+	return dw.lockDiff.Changed(anyExceptHash) || len(dw.changed) > 0 && dw.behavior != VendorNever
+}
+
 // PrintPreparedActions indicates what changes the DeltaWriter plans to make.
 func (dw *DeltaWriter) PrintPreparedActions(output *log.Logger, verbose bool) error {
-	if verbose {
-		l, err := dw.lock.MarshalTOML()
-		if err != nil {
-			return errors.Wrap(err, "ensure DryRun cannot serialize lock")
+	// With no changes, the lock would only be written out as it is.
+	if dw.lockDiff.Changed(anyExceptHash) || len(dw.changed) > 0 {
+		if verbose {
+			l, err := dw.lock.MarshalTOML()
+			if err != nil {
+				return errors.Wrap(err, "ensure DryRun cannot serialize lock")
+			}
+			output.Printf("Would have written the following %s (hash digests may be incorrect):\n%s\n", LockName, string(l))
+		} else {
+			output.Printf("Would have written %s.\n", LockName)
 		}
-		output.Printf("Would have written the following %s (hash digests may be incorrect):\n%s\n", LockName, string(l))
-	} else {
-		output.Printf("Would have written %s.\n", LockName)
 	}
 
 	projs := make(map[gps.ProjectRoot]gps.LockedProject)
@@ -712,9 +970,134 @@ func (dw *DeltaWriter) PrintPreparedActions(output *log.Logger, verbose bool) er
 // Gopkg.lock, vendor, and possibly Gopkg.toml.
 type TreeWriter interface {
 	PrintPreparedActions(output *log.Logger, verbose bool) error
+	// HasChanges reports whether Write would change anything on disk.
+	HasChanges() bool
 	Write(path string, sm gps.SourceManager, examples bool, logger *log.Logger) error
 }
 
+// NewManifestRewriter returns a TreeWriter that rewrites the manifest beneath
+// root, applying edit to its contents, then performs the writes of tw. Only
+// the rules that edit changes are rewritten, so that the comments and
+// formatting of the rest of the manifest are preserved.
+//
+// If tw fails to write, the original manifest is restored. As tw rolls back
+// its own changes on failure, as SafeWriter and DeltaWriter do, the manifest,
+// lock and vendor directory are updated together or not at all.
+func NewManifestRewriter(tw TreeWriter, root string, edit func(*Manifest)) (TreeWriter, error) {
+	mpath := filepath.Join(root, ManifestName)
+	orig, err := ioutil.ReadFile(mpath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s failed", ManifestName)
+	}
+	m, _, err := readManifest(bytes.NewReader(orig))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s failed", ManifestName)
+	}
+
+	edit(m)
+	rewritten, err := m.RewriteTOML(orig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal manifest to TOML")
+	}
+	return manifestRewriter{TreeWriter: tw, orig: orig, rewritten: rewritten}, nil
+}
+
+type manifestRewriter struct {
+	TreeWriter
+	orig, rewritten []byte
+}
+
+func (mr manifestRewriter) changed() bool {
+	return !bytes.Equal(mr.orig, mr.rewritten)
+}
+
+func (mr manifestRewriter) HasChanges() bool {
+	return mr.changed() || mr.TreeWriter.HasChanges()
+}
+
+func (mr manifestRewriter) PrintPreparedActions(output *log.Logger, verbose bool) error {
+	if mr.changed() {
+		if verbose {
+			output.Printf("Would have written the following %s:\n%s\n", ManifestName, string(mr.rewritten))
+		} else {
+			output.Printf("Would have updated %s.\n", ManifestName)
+		}
+	}
+	return mr.TreeWriter.PrintPreparedActions(output, verbose)
+}
+
+func (mr manifestRewriter) Write(path string, sm gps.SourceManager, examples bool, logger *log.Logger) error {
+	if !mr.changed() {
+		return mr.TreeWriter.Write(path, sm, examples, logger)
+	}
+
+	mpath := filepath.Join(path, ManifestName)
+	if err := replaceFile(mpath, mr.rewritten); err != nil {
+		return errors.Wrapf(err, "writing to %s failed", ManifestName)
+	}
+
+	if err := mr.TreeWriter.Write(path, sm, examples, logger); err != nil {
+		if rerr := replaceFile(mpath, mr.orig); rerr != nil {
+			return errors.Wrapf(err, "failed to restore %s (%v) after error", ManifestName, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// NewCheckedWriter returns a TreeWriter that performs the writes of tw, then
+// runs check once the new manifest, lock and vendor directory are in place,
+// but before those they replace are discarded. If check fails, the originals
+// are restored and its error is returned, as when tw fails to write.
+//
+// tw must have been returned by NewSafeWriter, NewDeltaWriter or
+// NewManifestRewriter; with any other TreeWriter, check is run once tw has
+// written, and nothing is restored if it fails.
+func NewCheckedWriter(tw TreeWriter, check func() error) TreeWriter {
+	switch w := tw.(type) {
+	case *SafeWriter:
+		cw := *w
+		cw.check = check
+		return &cw
+	case *DeltaWriter:
+		cw := *w
+		cw.check = check
+		return &cw
+	case manifestRewriter:
+		w.TreeWriter = NewCheckedWriter(w.TreeWriter, check)
+		return w
+	}
+	return checkedWriter{TreeWriter: tw, check: check}
+}
+
+type checkedWriter struct {
+	TreeWriter
+	check func() error
+}
+
+func (cw checkedWriter) Write(path string, sm gps.SourceManager, examples bool, logger *log.Logger) error {
+	if err := cw.TreeWriter.Write(path, sm, examples, logger); err != nil {
+		return err
+	}
+	return cw.check()
+}
+
+// replaceFile replaces the contents of the file at path with data, writing
+// them to an adjacent file first so that the original is never left partially
+// written.
+func replaceFile(path string, data []byte) error {
+	tmp := path + ".new"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := fs.RenameWithFallback(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
 // trimSHA checks if revision is a valid SHA1 digest and trims to 10 characters.
 func trimSHA(revision gps.Revision) string {
 	if len(revision) == 40 {
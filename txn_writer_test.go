@@ -6,12 +6,14 @@ package dep
 
 import (
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/verify"
 	"github.com/golang/dep/internal/test"
 	"github.com/pkg/errors"
 )
@@ -469,3 +471,197 @@ func TestSafeWriter_VendorDotGitPreservedWithForceVendor(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type stubTreeWriter struct {
+	err error
+}
+
+func (stubTreeWriter) PrintPreparedActions(output *log.Logger, verbose bool) error { return nil }
+
+func (stubTreeWriter) HasChanges() bool { return false }
+
+func (tw stubTreeWriter) Write(path string, sm gps.SourceManager, examples bool, logger *log.Logger) error {
+	return tw.err
+}
+
+func TestManifestRewriter_RestoresManifestOnFailure(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	const orig = "# Pinned for the examples.\n[[constraint]]\n  name = \"github.com/sdboyer/deptest\"\n  version = \"1.0.0\"\n"
+	h.TempFile(ManifestName, orig)
+	edit := func(m *Manifest) {
+		c, _ := gps.NewSemverConstraintIC("2.0.0")
+		m.Constraints["github.com/sdboyer/deptestdos"] = gps.ProjectProperties{Constraint: c}
+	}
+	const want = orig + "\n[[constraint]]\n  name = \"github.com/sdboyer/deptestdos\"\n  version = \"2.0.0\"\n"
+
+	tw, err := NewManifestRewriter(stubTreeWriter{err: errors.New("write failed")}, h.Path("."), edit)
+	h.Must(err)
+	if err := tw.Write(h.Path("."), nil, false, nil); err == nil {
+		t.Fatal("expected the failure of the wrapped writer to be returned")
+	}
+	readManifest := func() string {
+		data, err := ioutil.ReadFile(h.Path(ManifestName))
+		h.Must(err)
+		return string(data)
+	}
+	if got := readManifest(); got != orig {
+		t.Fatalf("expected the original manifest to be restored, got:\n%s", got)
+	}
+
+	tw, err = NewManifestRewriter(stubTreeWriter{}, h.Path("."), edit)
+	h.Must(err)
+	if err := tw.Write(h.Path("."), nil, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readManifest(); got != want {
+		t.Fatalf("expected the rule to be added to the manifest, got:\n%s", got)
+	}
+}
+
+func TestDeltaWriter_RollsBackOnFailure(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	const origLock = "# original lock\n"
+	h.TempFile(LockName, origLock)
+	h.TempFile("vendor/github.com/sdboyer/deptest/dep.go", "package deptest\n")
+
+	vp := func(pr gps.ProjectRoot, rev gps.Revision) gps.LockedProject {
+		return verify.VerifiableProject{
+			LockedProject: gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+		}
+	}
+	oldLock := &Lock{P: []gps.LockedProject{vp("github.com/sdboyer/deptest", "aaa")}}
+	newLock := &Lock{P: []gps.LockedProject{
+		vp("github.com/sdboyer/deptest", "aaa"),
+		vp("github.com/sdboyer/deptestdos", "bbb"),
+	}}
+	status := map[string]verify.VendorStatus{"github.com/sdboyer/deptest": verify.NoMismatch}
+
+	dw, err := NewDeltaWriter(oldLock, newLock, status, defaultCascadingPruneOptions(), h.Path("vendor"), VendorOnChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The memory source manager has no projects, so the export of the added
+	// project fails.
+	if err = dw.Write(h.Path("."), gps.NewMemorySourceManager(), false, nil); err == nil {
+		t.Fatal("expected the export of an unknown project to fail")
+	}
+
+	if data, err := ioutil.ReadFile(h.Path(LockName)); err != nil || string(data) != origLock {
+		t.Errorf("expected the original lock to be left in place, got %q (err %v)", data, err)
+	}
+	h.MustExist(h.Path("vendor/github.com/sdboyer/deptest/dep.go"))
+	h.MustNotExist(filepath.Join(h.Path("."), ".vendor-new"))
+}
+
+func TestCheckedWriter_RollsBackOnFailedCheck(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	const origLock = "# original lock\n"
+	h.TempFile(LockName, origLock)
+	h.TempFile("vendor/github.com/sdboyer/deptest/dep.go", "package deptest\n")
+
+	vp := func(pr gps.ProjectRoot, rev gps.Revision) gps.LockedProject {
+		return verify.VerifiableProject{
+			LockedProject: gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+		}
+	}
+	oldLock := &Lock{P: []gps.LockedProject{vp("github.com/sdboyer/deptest", "aaa")}}
+	newLock := &Lock{P: []gps.LockedProject{
+		vp("github.com/sdboyer/deptest", "aaa"),
+		vp("github.com/sdboyer/deptestdos", "bbb"),
+	}}
+	status := map[string]verify.VendorStatus{"github.com/sdboyer/deptest": verify.NoMismatch}
+
+	sm := gps.NewMemorySourceManager()
+	sm.AddVersion("github.com/sdboyer/deptestdos", gps.NewVersion("v1.0.0").Pair("bbb"), gps.MemoryProject{
+		Files: map[string][]byte{"dos.go": []byte("package deptestdos\n")},
+	})
+
+	dw, err := NewDeltaWriter(oldLock, newLock, status, defaultCascadingPruneOptions(), h.Path("vendor"), VendorOnChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := false
+	tw := NewCheckedWriter(dw, func() error {
+		checked = true
+		h.MustExist(h.Path("vendor/github.com/sdboyer/deptestdos/dos.go"))
+		return errors.New("check failed")
+	})
+	if err = tw.Write(h.Path("."), sm, false, nil); err == nil || err.Error() != "check failed" {
+		t.Fatalf("expected the failure of the check to be returned, got %v", err)
+	}
+	if !checked {
+		t.Fatal("expected the check to be run")
+	}
+
+	if data, err := ioutil.ReadFile(h.Path(LockName)); err != nil || string(data) != origLock {
+		t.Errorf("expected the original lock to be restored, got %q (err %v)", data, err)
+	}
+	h.MustExist(h.Path("vendor/github.com/sdboyer/deptest/dep.go"))
+	h.MustNotExist(filepath.Join(h.Path("vendor"), "github.com", "sdboyer", "deptestdos"))
+	h.MustNotExist(filepath.Join(h.Path("."), ".vendor-new"))
+	h.MustNotExist(filepath.Join(h.Path("."), ".vendor-old"))
+}
+
+func TestCheckedWriter_SafeWriterRestoresLock(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	const origLock = "# original lock\n"
+	h.TempFile(LockName, origLock)
+
+	sw, err := NewSafeWriter(nil, nil, &Lock{}, VendorNever, defaultCascadingPruneOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := NewCheckedWriter(sw, func() error {
+		if data, err := ioutil.ReadFile(h.Path(LockName)); err != nil || string(data) == origLock {
+			t.Errorf("expected the new lock to be in place during the check, got %q (err %v)", data, err)
+		}
+		return errors.New("check failed")
+	})
+	if err = tw.Write(h.Path("."), nil, false, nil); err == nil {
+		t.Fatal("expected the failure of the check to be returned")
+	}
+	if data, err := ioutil.ReadFile(h.Path(LockName)); err != nil || string(data) != origLock {
+		t.Errorf("expected the original lock to be restored, got %q (err %v)", data, err)
+	}
+}
+
+func TestDeltaWriter_HasChanges(t *testing.T) {
+	vp := func(pr gps.ProjectRoot, rev gps.Revision) gps.LockedProject {
+		return verify.VerifiableProject{
+			LockedProject: gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+		}
+	}
+	lock := &Lock{P: []gps.LockedProject{vp("github.com/sdboyer/deptest", "aaa")}}
+	moved := &Lock{P: []gps.LockedProject{vp("github.com/sdboyer/deptest", "bbb")}}
+	inSync := map[string]verify.VendorStatus{"github.com/sdboyer/deptest": verify.NoMismatch}
+	stale := map[string]verify.VendorStatus{"github.com/sdboyer/deptest": verify.DigestMismatchInLock}
+
+	for name, tc := range map[string]struct {
+		newLock  *Lock
+		status   map[string]verify.VendorStatus
+		behavior VendorBehavior
+		want     bool
+	}{
+		"in sync":               {lock, inSync, VendorOnChanged, false},
+		"lock changed":          {moved, inSync, VendorOnChanged, true},
+		"vendor stale":          {lock, stale, VendorOnChanged, true},
+		"vendor stale, ignored": {lock, stale, VendorNever, false},
+	} {
+		dw, err := NewDeltaWriter(lock, tc.newLock, tc.status, defaultCascadingPruneOptions(), "vendor", tc.behavior)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if got := dw.HasChanges(); got != tc.want {
+			t.Errorf("%s: expected HasChanges to be %t, got %t", name, tc.want, got)
+		}
+	}
+}